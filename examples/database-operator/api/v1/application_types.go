@@ -0,0 +1,132 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ApplicationDatabase is the Database an Application creates for its web tier
+type ApplicationDatabase struct {
+	// +kubebuilder:validation:Optional
+	// ClassName names the DatabaseClass of the Database
+	ClassName string `json:"className,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MinLength=1
+	// Image is the database container image; required unless the DatabaseClass sets it
+	Image string `json:"image,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=1
+	// Replicas is the number of database instances
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100000
+	// Storage is the size of the persistent volume claim (in MiB)
+	Storage int32 `json:"storage"`
+
+	// +kubebuilder:validation:Optional
+	// DatabaseName is the name of the database to create
+	DatabaseName string `json:"databaseName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// UserName is the database user name
+	UserName string `json:"userName,omitempty"`
+}
+
+// ApplicationWeb is the web tier of an Application
+type ApplicationWeb struct {
+	// +kubebuilder:validation:MinLength=1
+	// Image is the web container image
+	Image string `json:"image"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=1
+	// Replicas is the number of web pods
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=8080
+	// Port is the port the web container listens on
+	Port int32 `json:"port,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Env is added to the connection variables; a variable set here overrides the
+	// connection variable of the same name
+	Env []corev1.EnvVar `json:"env,omitempty"`
+}
+
+// ApplicationSpec defines the desired state of Application
+type ApplicationSpec struct {
+	// Database is the Database the web tier connects to
+	Database ApplicationDatabase `json:"database"`
+
+	// Web is the web tier, started once the Database is ready
+	Web ApplicationWeb `json:"web"`
+}
+
+// ApplicationStatus defines the observed state of Application
+type ApplicationStatus struct {
+	// +kubebuilder:validation:Optional
+	// Phase is WaitingForDatabase, Progressing, Ready or Degraded
+	Phase string `json:"phase,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ObservedGeneration is the generation observed by the controller
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// DatabaseName is the name of the created Database
+	DatabaseName string `json:"databaseName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ConnectionSecretName is the name of the Secret holding the connection variables of
+	// the web tier
+	ConnectionSecretName string `json:"connectionSecretName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// DeploymentName is the name of the web Deployment, once the Database was first ready
+	DeploymentName string `json:"deploymentName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Conditions represent the latest available observations
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=app,categories=databases
+//+kubebuilder:printcolumn:name="PHASE",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="WAITING",type=string,JSONPath=`.status.conditions[?(@.type=="WaitingForDatabase")].status`
+//+kubebuilder:printcolumn:name="WEB",type=string,JSONPath=`.status.conditions[?(@.type=="WebReady")].status`
+//+kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Application bundles a Database and the web tier using it. The controller creates the
+// Database, publishes its connection details in a Secret the web pods read their environment
+// from, starts the web tier only once the Database is ready, and rolls both up into Ready.
+type Application struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ApplicationSpec   `json:"spec,omitempty"`
+	Status ApplicationStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ApplicationList contains a list of Application
+type ApplicationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Application `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Application{}, &ApplicationList{})
+}
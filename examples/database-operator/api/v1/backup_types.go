@@ -0,0 +1,129 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Phases of a Backup
+const (
+	// BackupPending is the phase of a Backup waiting for its Database to be ready
+	BackupPending = "Pending"
+
+	// BackupRunning is the phase of a Backup while its Job dumps and uploads the Database
+	BackupRunning = "Running"
+
+	// BackupCompleted is the phase of a Backup whose dump was uploaded
+	BackupCompleted = "Completed"
+
+	// BackupFailed is the phase of a Backup whose Job failed; create a new Backup to retry
+	BackupFailed = "Failed"
+)
+
+// BackupDestination is the S3-compatible bucket a Backup is uploaded to
+type BackupDestination struct {
+	// +kubebuilder:validation:MinLength=1
+	// Bucket is the name of the bucket
+	Bucket string `json:"bucket"`
+
+	// +kubebuilder:validation:Optional
+	// Endpoint is the URL of an S3-compatible service, e.g. MinIO; empty uses AWS S3
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Region is the region of the bucket
+	Region string `json:"region,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Prefix is prepended to the object key of the dump
+	Prefix string `json:"prefix,omitempty"`
+
+	// +kubebuilder:validation:MinLength=1
+	// CredentialsSecretName names a Secret in the namespace of the Backup holding the
+	// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, and optionally AWS_SESSION_TOKEN, of the bucket
+	CredentialsSecretName string `json:"credentialsSecretName"`
+}
+
+// BackupSpec defines the desired state of Backup
+type BackupSpec struct {
+	// +kubebuilder:validation:MinLength=1
+	// DatabaseName is the name of the Database to back up, in the namespace of the Backup
+	DatabaseName string `json:"databaseName"`
+
+	// Destination is the bucket the dump is uploaded to
+	Destination BackupDestination `json:"destination"`
+
+	// +kubebuilder:validation:Optional
+	// UploaderImage is the image uploading the dump; it must provide the aws CLI. Defaults to
+	// the operator's uploader image.
+	UploaderImage string `json:"uploaderImage,omitempty"`
+}
+
+// BackupStatus defines the observed state of Backup
+type BackupStatus struct {
+	// +kubebuilder:validation:Optional
+	// Phase is Pending, Running, Completed or Failed
+	Phase string `json:"phase,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ObservedGeneration is the generation observed by the controller
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// JobName is the name of the Job taking the Backup
+	JobName string `json:"jobName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Location is the URL of the uploaded dump, e.g. s3://backups/shop/orders/nightly.dump
+	Location string `json:"location,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// StartTime is when the Job started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// CompletionTime is when the Job finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Conditions represent the latest available observations
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories=databases
+//+kubebuilder:printcolumn:name="DATABASE",type=string,JSONPath=`.spec.databaseName`
+//+kubebuilder:printcolumn:name="PHASE",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="LOCATION",type=string,JSONPath=`.status.location`,priority=1
+//+kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Backup is a one-off backup of a Database. Once the Database is ready, the controller starts a
+// Job that dumps it with pg_dump and uploads the dump to an S3-compatible bucket, and records
+// the outcome. The spec cannot change: the Job records what was backed up, and a new backup is
+// a new Backup.
+type Backup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable"
+	Spec   BackupSpec   `json:"spec,omitempty"`
+	Status BackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// BackupList contains a list of Backup
+type BackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Backup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Backup{}, &BackupList{})
+}
+
+// IsFinished returns true once the Backup completed or failed
+func (b *Backup) IsFinished() bool {
+	return b.Status.Phase == BackupCompleted || b.Status.Phase == BackupFailed
+}
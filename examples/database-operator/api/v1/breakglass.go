@@ -0,0 +1,6 @@
+package v1
+
+// BreakGlassAnnotation set to "true" on a Database makes the operator leave the Database and
+// its children as they are, reporting their state only, until the annotation is removed. The
+// Database webhooks admit it unchanged. On a pod it skips the pod policy webhook.
+const BreakGlassAnnotation = "database.my.domain/break-glass"
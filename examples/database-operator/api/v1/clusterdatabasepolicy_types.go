@@ -0,0 +1,63 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterDatabasePolicySpec defines the desired state of ClusterDatabasePolicy
+type ClusterDatabasePolicySpec struct {
+	// +kubebuilder:validation:Optional
+	// NamespaceSelector selects the namespaces the policy applies to; empty selects all namespaces
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ClientSelector selects the pods, in the same namespace as the database, that may connect
+	// to database pods; empty allows every pod in the namespace
+	ClientSelector *metav1.LabelSelector `json:"clientSelector,omitempty"`
+}
+
+// ClusterDatabasePolicyStatus defines the observed state of ClusterDatabasePolicy
+type ClusterDatabasePolicyStatus struct {
+	// +kubebuilder:validation:Optional
+	// ObservedGeneration is the generation observed by the controller
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Namespaces are the namespaces the policy is applied in, sorted
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Conditions represent the latest available observations
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster,shortName=cdbp,categories=databases
+//+kubebuilder:printcolumn:name="NAMESPACES",type=string,JSONPath=`.status.namespaces`
+//+kubebuilder:printcolumn:name="REASON",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+//+kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterDatabasePolicy restricts network access to database pods in every selected namespace.
+// It is cluster-scoped: a namespace admin cannot remove it, and new namespaces matching the
+// selector are covered as soon as they are created.
+type ClusterDatabasePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterDatabasePolicySpec   `json:"spec,omitempty"`
+	Status ClusterDatabasePolicyStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterDatabasePolicyList contains a list of ClusterDatabasePolicy
+type ClusterDatabasePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterDatabasePolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterDatabasePolicy{}, &ClusterDatabasePolicyList{})
+}
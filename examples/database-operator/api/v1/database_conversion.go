@@ -0,0 +1,4 @@
+package v1
+
+// Hub marks v1 as the version Databases are stored in and the other versions convert through
+func (*Database) Hub() {}
@@ -0,0 +1,59 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DatabaseClassSpec defines the presets of a DatabaseClass. Every field is optional, and
+// each fills the field of the same name that a Database leaves unset.
+type DatabaseClassSpec struct {
+	// +kubebuilder:validation:Optional
+	// Image is the database container image
+	Image string `json:"image,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Resources are the compute resources of the database container
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// StorageClass is the storage class of the data volume
+	StorageClass string `json:"storageClass,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// PriorityClassName is the PriorityClass of the database pods
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Parameters are PostgreSQL server settings; a Database overrides them one by one
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster,shortName=dbclass,categories=databases
+//+kubebuilder:printcolumn:name="IMAGE",type=string,JSONPath=`.spec.image`
+//+kubebuilder:printcolumn:name="STORAGECLASS",type=string,JSONPath=`.spec.storageClass`
+//+kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// DatabaseClass is a preset curated by the cluster admin, like a StorageClass: Databases
+// name it in spec.className instead of repeating the image, resources and parameters the
+// admin has vetted. It is cluster-scoped so every namespace shares the same presets.
+type DatabaseClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DatabaseClassSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DatabaseClassList contains a list of DatabaseClass
+type DatabaseClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatabaseClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatabaseClass{}, &DatabaseClassList{})
+}
@@ -0,0 +1,20 @@
+package v1
+
+import "your.domain/project/health"
+
+// ComputeHealth returns the kstatus health of a Database. A Database is Current once its
+// pods are ready for the latest spec, Failed while it is Stalled, e.g. with an invalid spec,
+// and InProgress otherwise, including while errors are retried.
+func ComputeHealth(database *Database) health.Result {
+	return health.Compute(health.Input{
+		Object:             database,
+		ObservedGeneration: &database.Status.ObservedGeneration,
+		Conditions:         database.Status.Conditions,
+	})
+}
+
+// IsDegraded returns true if the Database serves with only part of its replicas ready for
+// longer than the operator tolerates
+func (d *Database) IsDegraded() bool {
+	return health.IsDegraded(d.Status.Conditions)
+}
@@ -2,6 +2,7 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -12,15 +13,27 @@ type DatabaseSpec struct {
 	// Replicas is the number of database instances
 	Replicas int32 `json:"replicas"`
 
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:MinLength=1
-	// Image is the database container image
-	Image string `json:"image"`
+	// Image is the database container image; required unless the DatabaseClass sets it
+	Image string `json:"image,omitempty"`
 
 	// +kubebuilder:validation:Minimum=1
 	// +kubebuilder:validation:Maximum=100000
 	// Storage is the size of the persistent volume claim (in MiB)
 	Storage int32 `json:"storage"`
 
+	// +kubebuilder:validation:Optional
+	// ClassName names the DatabaseClass whose presets fill the fields this spec leaves
+	// unset. The presets are applied on every reconcile, so edits to the class reach every
+	// Database using it.
+	ClassName string `json:"className,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Parameters are PostgreSQL server settings, passed as -c name=value; they override
+	// the parameters of the class one by one
+	Parameters map[string]string `json:"parameters,omitempty"`
+
 	// +kubebuilder:validation:Optional
 	// DatabaseName is the name of the database to create
 	DatabaseName string `json:"databaseName,omitempty"`
@@ -39,12 +52,284 @@ type DatabaseSpec struct {
 
 	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
 	// +kubebuilder:validation:Optional
-	// ServiceType is the Kubernetes service type
+	// +database:deprecated:replacement=spec.service.type
+	// ServiceType is the Kubernetes service type.
+	// Deprecated: use spec.service.type instead.
 	ServiceType corev1.ServiceType `json:"serviceType,omitempty"`
 
+	// +kubebuilder:validation:Optional
+	// Service configures the Service clients connect through
+	Service *ServiceSpec `json:"service,omitempty"`
+
 	// +kubebuilder:validation:Optional
 	// StorageClass is the storage class to use
 	StorageClass string `json:"storageClass,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Resources are the compute resources of the database container
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// PriorityClassName is the PriorityClass of every pod the operator creates for the
+	// Database, so critical databases are preempted and evicted after batch workloads
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ImagePullSecrets are Secrets in the Database namespace used to pull the database image
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// RequeuePolicy overrides the operator-wide periodic resync intervals for this Database
+	RequeuePolicy *RequeuePolicy `json:"requeuePolicy,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ImageUpdatePolicy lets the operator move spec.image to newer tags of the same repository
+	ImageUpdatePolicy *ImageUpdatePolicy `json:"imageUpdatePolicy,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Standby makes the Database a read-only replica of a primary in another namespace or
+	// cluster, for disaster recovery. It can only be set when the Database is created.
+	Standby *StandbySpec `json:"standby,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Maintenance schedules maintenance tasks such as VACUUM ANALYZE into a recurring window
+	Maintenance *MaintenanceSpec `json:"maintenance,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Monitoring configures the monitoring integrations of the Database
+	Monitoring *MonitoringSpec `json:"monitoring,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ZoneSpread spreads the database pods across zones and reports how they are placed
+	ZoneSpread *ZoneSpreadSpec `json:"zoneSpread,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// VerticalScaling lets the operator recommend, and optionally apply, the requests and
+	// limits of the database container from its usage history
+	VerticalScaling *VerticalScalingPolicy `json:"verticalScaling,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet
+	// Workload is the kind of workload running the database pods. Changing it from
+	// Deployment to StatefulSet migrates a running Database in place, keeping its volume;
+	// status.workload reports the kind that runs it. There is no way back.
+	Workload WorkloadKind `json:"workload,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// HighAvailability runs the database as a primary and Replicas-1 streaming replicas in a
+	// StatefulSet, with a volume per pod; it requires workload StatefulSet. The first pod is
+	// the primary, the Service sends clients to it, and status.primary names it. It can only be
+	// set when the Database is created.
+	HighAvailability bool `json:"highAvailability,omitempty"`
+}
+
+// ServiceSpec configures the Service of a Database
+type ServiceSpec struct {
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +kubebuilder:validation:Optional
+	// Type is the Kubernetes service type; defaults to ClusterIP
+	Type corev1.ServiceType `json:"type,omitempty"`
+}
+
+// WorkloadKind is the kind of workload running the database pods
+type WorkloadKind string
+
+const (
+	// WorkloadDeployment runs the database pods with a Deployment, the default
+	WorkloadDeployment WorkloadKind = "Deployment"
+
+	// WorkloadStatefulSet runs the database pods with a StatefulSet
+	WorkloadStatefulSet WorkloadKind = "StatefulSet"
+)
+
+// VerticalScalingMode is what the operator does with a resource recommendation
+type VerticalScalingMode string
+
+const (
+	// VerticalScalingRecommend records the recommendation in status.recommendation
+	VerticalScalingRecommend VerticalScalingMode = "Recommend"
+
+	// VerticalScalingApply also sets spec.resources to the recommendation while the
+	// maintenance window is open
+	VerticalScalingApply VerticalScalingMode = "Apply"
+)
+
+// VerticalScalingPolicy bounds the resources the operator recommends for a Database
+type VerticalScalingPolicy struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Recommend;Apply
+	// +kubebuilder:default=Recommend
+	// Mode is Recommend or Apply; Apply requires spec.maintenance, since new resources
+	// restart the database pods
+	Mode VerticalScalingMode `json:"mode,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// MinAllowed are the smallest CPU and memory requests recommended
+	MinAllowed corev1.ResourceList `json:"minAllowed,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// MaxAllowed are the largest CPU and memory requests recommended
+	MaxAllowed corev1.ResourceList `json:"maxAllowed,omitempty"`
+}
+
+// ZoneSpreadSpec is a topology spread constraint over the database pods
+type ZoneSpreadSpec struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// MaxSkew is the largest allowed difference between the replicas in the fullest and the
+	// emptiest zone
+	MaxSkew int32 `json:"maxSkew,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=DoNotSchedule;ScheduleAnyway
+	// +kubebuilder:default=ScheduleAnyway
+	// WhenUnsatisfiable is DoNotSchedule to keep pods pending rather than exceed MaxSkew,
+	// or ScheduleAnyway to only prefer a spread placement
+	WhenUnsatisfiable corev1.UnsatisfiableConstraintAction `json:"whenUnsatisfiable,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// TopologyKey is the node label naming the zone; defaults to topology.kubernetes.io/zone
+	TopologyKey string `json:"topologyKey,omitempty"`
+}
+
+// MonitoringSpec configures monitoring integrations
+type MonitoringSpec struct {
+	// +kubebuilder:validation:Optional
+	// Enabled provisions a Grafana dashboard for the Database's metrics
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// Weekday is a day of the week
+// +kubebuilder:validation:Enum=Monday;Tuesday;Wednesday;Thursday;Friday;Saturday;Sunday
+type Weekday string
+
+// MaintenanceWindow is a recurring period in which disruptive work may run
+type MaintenanceWindow struct {
+	// +kubebuilder:validation:Optional
+	// Days the window opens on; every day when empty
+	Days []Weekday `json:"days,omitempty"`
+
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	// Start is the time of day the window opens, as HH:MM in UTC
+	Start string `json:"start"`
+
+	// Duration is how long the window stays open, at most 24h
+	Duration metav1.Duration `json:"duration"`
+}
+
+// MaintenanceTaskType is a maintenance operation
+type MaintenanceTaskType string
+
+const (
+	// MaintenanceVacuumAnalyze reclaims dead rows and refreshes planner statistics of every database
+	MaintenanceVacuumAnalyze MaintenanceTaskType = "VacuumAnalyze"
+
+	// MaintenanceReindex rebuilds the indexes of every database without blocking writes
+	MaintenanceReindex MaintenanceTaskType = "Reindex"
+)
+
+// MaintenanceSpec configures scheduled maintenance
+type MaintenanceSpec struct {
+	// Window is when maintenance tasks may run; a task still running when it closes is stopped
+	Window MaintenanceWindow `json:"window"`
+
+	// +kubebuilder:validation:MinItems=1
+	// Tasks are the maintenance tasks to run
+	Tasks []MaintenanceTask `json:"tasks"`
+}
+
+// MaintenanceTask is one scheduled maintenance task
+type MaintenanceTask struct {
+	// +kubebuilder:validation:Enum=VacuumAnalyze;Reindex
+	// Type is VacuumAnalyze or Reindex
+	Type MaintenanceTaskType `json:"type"`
+
+	// +kubebuilder:validation:Optional
+	// Interval is the minimum time between two runs, e.g. 168h to reindex in one window a
+	// week; the task runs in every window when unset
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// StandbySource is where a standby gets the primary's changes from
+type StandbySource string
+
+const (
+	// StandbyStreaming replicates from the primary over a replication connection
+	StandbyStreaming StandbySource = "Streaming"
+
+	// StandbyWALArchive replays WAL files the primary archives, e.g. to object storage in
+	// the standby's region, and needs no connection to the primary after the bootstrap
+	StandbyWALArchive StandbySource = "WALArchive"
+)
+
+// StandbySpec configures a standby Database
+type StandbySpec struct {
+	// +kubebuilder:validation:MinLength=1
+	// PrimaryConnectionSecret names a Secret in the Database namespace with the keys host,
+	// port, username and password of a replication user on the primary. The standby is
+	// bootstrapped with a base backup over this connection. For WALArchive it also holds
+	// restoreCommand, the restore_command that fetches a WAL file.
+	PrimaryConnectionSecret string `json:"primaryConnectionSecret"`
+
+	// +kubebuilder:validation:Enum=Streaming;WALArchive
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=Streaming
+	// Source is Streaming or WALArchive
+	Source StandbySource `json:"source,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Promote turns the standby into a writable primary by restarting it without recovery.
+	// Promotion cannot be undone: the promoted Database has diverged from its old primary.
+	Promote bool `json:"promote,omitempty"`
+}
+
+// RequeuePolicy controls how often a Database is re-checked when no events arrive
+type RequeuePolicy struct {
+	// +kubebuilder:validation:Optional
+	// ReadyInterval is the resync interval while the Database is ready
+	ReadyInterval *metav1.Duration `json:"readyInterval,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// NotReadyInterval is the resync interval while the Database is not ready
+	NotReadyInterval *metav1.Duration `json:"notReadyInterval,omitempty"`
+}
+
+// ImageUpdateMode is what the operator does when a newer tag is within the update range
+type ImageUpdateMode string
+
+const (
+	// ImageUpdateNotify records the newest tag in the AvailableImageAnnotation
+	ImageUpdateNotify ImageUpdateMode = "Notify"
+
+	// ImageUpdateApply sets spec.image to the newest tag
+	ImageUpdateApply ImageUpdateMode = "Apply"
+)
+
+// Annotations written by the image updater
+const (
+	// AvailableImageAnnotation holds the newest image within the update range
+	AvailableImageAnnotation = "database.my.domain/available-image"
+
+	// AutomatedImageAnnotation holds the image the updater last applied. An update that sets
+	// spec.image to this value is automated, and the validating webhook only admits it
+	// within the update range.
+	AutomatedImageAnnotation = "database.my.domain/automated-image"
+)
+
+// ImageUpdatePolicy selects the tags the operator may update a Database to. Images pinned to
+// a digest are never updated.
+type ImageUpdatePolicy struct {
+	// +kubebuilder:validation:MinLength=1
+	// Range is the versions tags must fall in, as space-separated comparisons, e.g. ">=15.2 <16".
+	// Only tags with the same variant suffix as the current one (e.g. -alpine) are considered.
+	Range string `json:"range"`
+
+	// +kubebuilder:validation:Enum=Notify;Apply
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=Notify
+	// Mode is Notify to only annotate the Database with the newest tag, or Apply to update spec.image
+	Mode ImageUpdateMode `json:"mode,omitempty"`
 }
 
 // DatabaseStatus defines the observed state of Database
@@ -62,6 +347,7 @@ type DatabaseStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 
 	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=32
 	// Conditions represent the latest available observations
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 
@@ -72,16 +358,233 @@ type DatabaseStatus struct {
 	// +kubebuilder:validation:Optional
 	// DeploymentName is the name of the created deployment
 	DeploymentName string `json:"deploymentName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Workload is the kind of workload running the database pods; it only changes to
+	// StatefulSet once a migration completed
+	Workload WorkloadKind `json:"workload,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Primary is the pod accepting writes, with spec.highAvailability
+	Primary string `json:"primary,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=200
+	// Pods is the observed state of each database pod
+	Pods []DatabasePodStatus `json:"pods,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// DesiredStateHash is a hash of the desired state applied by the last reconcile
+	DesiredStateHash string `json:"desiredStateHash,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=100
+	// History holds the most recent reconcile outcomes, newest first. It is only kept when
+	// the operator runs with --status-history-limit.
+	History []ReconcileRecord `json:"history,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// PromotedAt is when a standby Database was promoted to a primary
+	PromotedAt *metav1.Time `json:"promotedAt,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Stats summarizes connections and slow queries. It is only kept when the operator runs
+	// with --stats-status-summary.
+	Stats *DatabaseStats `json:"stats,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Maintenance is the outcome of the latest run of each maintenance task
+	Maintenance []MaintenanceTaskStatus `json:"maintenance,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Resources sums the compute and storage the Database's children request, for chargeback
+	Resources *ResourceSummary `json:"resources,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=64
+	// Zones is how many database pods run in each zone, with spec.zoneSpread
+	Zones []ZoneReplicas `json:"zones,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Recommendation is the requests and limits suggested for the database container, with
+	// spec.verticalScaling
+	Recommendation *ResourceRecommendation `json:"recommendation,omitempty"`
+}
+
+// ResourceRecommendation is a suggested size of the database container
+type ResourceRecommendation struct {
+	// Requests are the recommended CPU and memory requests
+	Requests corev1.ResourceList `json:"requests"`
+
+	// +kubebuilder:validation:Optional
+	// Limits keep the ratio of limits to requests of spec.resources
+	Limits corev1.ResourceList `json:"limits,omitempty"`
+
+	// Since is when the oldest usage sample the recommendation is based on was taken
+	Since metav1.Time `json:"since"`
+
+	// Samples is the number of usage samples the recommendation is based on
+	Samples int32 `json:"samples"`
+
+	// +kubebuilder:validation:Optional
+	// AppliedAt is when the recommendation was last written to spec.resources
+	AppliedAt *metav1.Time `json:"appliedAt,omitempty"`
+}
+
+// ZoneReplicas is the number of scheduled database pods in a zone
+type ZoneReplicas struct {
+	// Zone is the value of the topology label of the zone's nodes
+	Zone string `json:"zone"`
+
+	// Replicas is the number of scheduled database pods in the zone
+	Replicas int32 `json:"replicas"`
+}
+
+// ResourceSummary is the compute and storage footprint of a Database
+type ResourceSummary struct {
+	// Pods is the number of running or pending pods counted, including maintenance Jobs
+	Pods int32 `json:"pods"`
+
+	// +kubebuilder:validation:Optional
+	// Requests are the CPU and memory requests of the counted pods, as the scheduler sees them
+	Requests corev1.ResourceList `json:"requests,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Storage is the capacity of the data volume
+	Storage *resource.Quantity `json:"storage,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Usage is the live CPU and memory usage of the counted pods from metrics-server. It is
+	// only reported when the operator runs with --report-resource-usage.
+	Usage corev1.ResourceList `json:"usage,omitempty"`
+}
+
+// MaintenanceTaskStatus is the latest run of a maintenance task
+type MaintenanceTaskStatus struct {
+	// Type is the task
+	Type MaintenanceTaskType `json:"type"`
+
+	// +kubebuilder:validation:Optional
+	// JobName is the Job of the latest run
+	JobName string `json:"jobName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// StartTime is when the latest run started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// CompletionTime is when the latest run finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Result is Running, Succeeded or Failed
+	Result string `json:"result,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Message explains a failed run
+	Message string `json:"message,omitempty"`
+}
+
+// DatabaseStats is a snapshot of pg_stat_activity and pg_stat_statements
+type DatabaseStats struct {
+	// CollectedAt is when the snapshot was taken
+	CollectedAt metav1.Time `json:"collectedAt"`
+
+	// Connections counts client connections by state, e.g. active, idle or
+	// "idle in transaction"
+	Connections map[string]int32 `json:"connections,omitempty"`
+
+	// MaxConnections is the server's max_connections setting
+	MaxConnections int32 `json:"maxConnections"`
+
+	// LongestTransactionSeconds is the age of the oldest open transaction
+	LongestTransactionSeconds int64 `json:"longestTransactionSeconds"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=50
+	// SlowQueries are the statements with the highest mean execution time. They need the
+	// pg_stat_statements extension and are empty without it.
+	SlowQueries []SlowQuery `json:"slowQueries,omitempty"`
+}
+
+// SlowQuery is a normalized statement from pg_stat_statements
+type SlowQuery struct {
+	// QueryID identifies the normalized statement
+	QueryID string `json:"queryID"`
+
+	// Query is the normalized statement text, truncated; constants are replaced by parameters
+	Query string `json:"query"`
+
+	// Calls is how often the statement ran since the statistics were last reset
+	Calls int64 `json:"calls"`
+
+	// MeanMilliseconds is the mean execution time
+	MeanMilliseconds int64 `json:"meanMilliseconds"`
+}
+
+// DatabasePodStatus is a summary of a single database pod for first-line debugging
+type DatabasePodStatus struct {
+	// Name is the name of the pod
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Optional
+	// Role is the role of the pod in the database topology (e.g. primary, replica)
+	Role string `json:"role,omitempty"`
+
+	// Ready indicates whether the pod passes its readiness checks
+	Ready bool `json:"ready"`
+
+	// Restarts is the total number of container restarts in the pod
+	Restarts int32 `json:"restarts"`
+
+	// +kubebuilder:validation:Optional
+	// Node is the name of the node the pod is scheduled on
+	Node string `json:"node,omitempty"`
+}
+
+// ReconcileRecord is the outcome of one or more consecutive reconciles that ended the same way
+type ReconcileRecord struct {
+	// Time is when the first of these reconciles finished, or the latest for failures
+	Time metav1.Time `json:"time"`
+
+	// Outcome is the phase the reconcile left the Database in
+	Outcome string `json:"outcome"`
+
+	// +kubebuilder:validation:Optional
+	// Reason is the reason of the Ready condition after the reconcile
+	Reason string `json:"reason,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Error is the error the reconcile failed with, if any
+	Error string `json:"error,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Duration is how long the reconcile at Time took
+	Duration metav1.Duration `json:"duration,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Trigger is the change that led to the reconcile: Created, SpecChanged,
+	// DesiredStateChanged, or Event for watch events and periodic resyncs
+	Trigger string `json:"trigger,omitempty"`
+
+	// Count is the number of consecutive failed reconciles with this outcome, reason, error
+	// and trigger; repeated successes are not counted
+	Count int32 `json:"count"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
-//+kubebuilder:resource:shortName=db
+//+kubebuilder:storageversion
+//+kubebuilder:resource:shortName=db,categories=all;databases
 //+kubebuilder:printcolumn:name="PHASE",type=string,JSONPath=`.status.phase`
 //+kubebuilder:printcolumn:name="READY",type=string,JSONPath=`.status.readyReplicas`
+//+kubebuilder:printcolumn:name="REASON",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+//+kubebuilder:printcolumn:name="PRIMARY",type=string,JSONPath=`.status.pods[?(@.role=="primary")].name`,priority=1
 //+kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
 
-// Database is the Schema for the databases API
+// Database is the Schema for the databases API. kubectl get databases shows why a Database
+// is not ready, and -o wide its primary pod; kubectl get all and kubectl get databases list
+// it with the other resources of those categories.
 type Database struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -116,8 +619,9 @@ func (d *Database) SetCondition(conditionType string, status metav1.ConditionSta
 	found := false
 	for i, condition := range d.Status.Conditions {
 		if condition.Type == conditionType {
-			if condition.Status != newCondition.Status {
-				condition.LastTransitionTime = newCondition.LastTransitionTime
+			// The transition time only moves when the status does
+			if condition.Status == newCondition.Status && !condition.LastTransitionTime.IsZero() {
+				newCondition.LastTransitionTime = condition.LastTransitionTime
 			}
 			d.Status.Conditions[i] = newCondition
 			found = true
@@ -0,0 +1,140 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Phases of a Restore
+const (
+	// RestorePending is the phase of a Restore waiting for its Database to be ready, or for its
+	// Backup to complete
+	RestorePending = "Pending"
+
+	// RestoreRestoring is the phase of a Restore while its Job downloads and loads the dump
+	RestoreRestoring = "Restoring"
+
+	// RestoreCompleted is the phase of a Restore whose dump was loaded into the Database
+	RestoreCompleted = "Completed"
+
+	// RestoreFailed is the phase of a Restore whose Job or Backup failed; create a new Restore
+	// to retry
+	RestoreFailed = "Failed"
+)
+
+// RestoreSource is the dump a Restore loads: the dump of a Backup, or one at a URL
+// +kubebuilder:validation:XValidation:rule="has(self.backupName) != has(self.location)",message="exactly one of backupName and location is required"
+// +kubebuilder:validation:XValidation:rule="!has(self.location) || has(self.credentialsSecretName)",message="credentialsSecretName is required with location"
+// +kubebuilder:validation:XValidation:rule="!has(self.backupName) || !(has(self.endpoint) || has(self.region) || has(self.credentialsSecretName))",message="the bucket of a Backup is taken from the Backup"
+type RestoreSource struct {
+	// +kubebuilder:validation:Optional
+	// BackupName names a Backup in the namespace of the Restore. The Restore waits until the
+	// Backup completed and downloads its dump with the Backup's destination settings.
+	BackupName string `json:"backupName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^s3://[^/]+/.+`
+	// Location is the URL of a dump written by pg_dump --format=custom, e.g.
+	// s3://backups/postgres/shop/orders/nightly.dump
+	Location string `json:"location,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Endpoint is the URL of an S3-compatible service holding Location; empty uses AWS S3
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Region is the region of the bucket holding Location
+	Region string `json:"region,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// CredentialsSecretName names a Secret in the namespace of the Restore holding the
+	// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, and optionally AWS_SESSION_TOKEN, of the
+	// bucket holding Location
+	CredentialsSecretName string `json:"credentialsSecretName,omitempty"`
+}
+
+// RestoreSpec defines the desired state of Restore
+type RestoreSpec struct {
+	// +kubebuilder:validation:MinLength=1
+	// DatabaseName is the name of the Database to restore into, in the namespace of the Restore
+	DatabaseName string `json:"databaseName"`
+
+	// Source is the dump to restore
+	Source RestoreSource `json:"source"`
+
+	// +kubebuilder:validation:Optional
+	// DownloaderImage is the image downloading the dump; it must provide the aws CLI. Defaults
+	// to the operator's uploader image.
+	DownloaderImage string `json:"downloaderImage,omitempty"`
+}
+
+// RestoreStatus defines the observed state of Restore
+type RestoreStatus struct {
+	// +kubebuilder:validation:Optional
+	// Phase is Pending, Restoring, Completed or Failed
+	Phase string `json:"phase,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ObservedGeneration is the generation observed by the controller
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// JobName is the name of the Job running the Restore
+	JobName string `json:"jobName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Location is the URL of the dump being restored
+	Location string `json:"location,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// StartTime is when the Job started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// CompletionTime is when the Job finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Conditions represent the latest available observations
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:categories=databases
+//+kubebuilder:printcolumn:name="DATABASE",type=string,JSONPath=`.spec.databaseName`
+//+kubebuilder:printcolumn:name="BACKUP",type=string,JSONPath=`.spec.source.backupName`
+//+kubebuilder:printcolumn:name="PHASE",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="LOCATION",type=string,JSONPath=`.status.location`,priority=1
+//+kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Restore loads a dump into an existing Database, replacing the objects the dump contains.
+// Once the Database is ready, and the Backup completed when the dump is a Backup's, the
+// controller starts a Job that downloads the dump and loads it with pg_restore in a single
+// transaction, so a failed Restore leaves the Database as it was. Like a Backup, a Restore
+// runs once and its spec cannot change.
+type Restore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable"
+	Spec   RestoreSpec   `json:"spec,omitempty"`
+	Status RestoreStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// RestoreList contains a list of Restore
+type RestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Restore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Restore{}, &RestoreList{})
+}
+
+// IsFinished returns true once the Restore completed or failed
+func (r *Restore) IsFinished() bool {
+	return r.Status.Phase == RestoreCompleted || r.Status.Phase == RestoreFailed
+}
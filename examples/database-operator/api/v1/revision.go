@@ -0,0 +1,12 @@
+package v1
+
+// SchemaRevisionAnnotation holds the highest SchemaRevision of the operators that wrote a
+// Database, as a decimal integer
+const SchemaRevisionAnnotation = "database.my.domain/schema-revision"
+
+// SchemaRevision is the revision of the Database schema this package describes. Increase it
+// with every field added to the API: an operator built with an older revision does not know
+// the field, and would drop it from any Database it updates.
+//
+// The API version only changes for incompatible schemas, so it cannot tell the two apart.
+const SchemaRevision = 1
@@ -0,0 +1,90 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SkillsQuotaSpec defines the desired state of SkillsQuota
+type SkillsQuotaSpec struct {
+	// +kubebuilder:validation:Optional
+	// NamespaceSelector selects the namespaces the limits apply to, each on its own; empty
+	// selects all namespaces
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// MaxDatabases is the number of Databases a namespace may hold; unset is unlimited
+	MaxDatabases *int32 `json:"maxDatabases,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// MaxStorage is the storage, in MiB, the Databases of a namespace may claim in total;
+	// unset is unlimited
+	MaxStorage *int64 `json:"maxStorage,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// MaxReplicas is the number of replicas the Databases of a namespace may run in total;
+	// unset is unlimited
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+}
+
+// NamespaceUsage is what the Databases of one namespace use of a quota
+type NamespaceUsage struct {
+	Namespace string `json:"namespace"`
+
+	// Databases is the number of Databases in the namespace
+	Databases int32 `json:"databases"`
+
+	// Storage is the storage, in MiB, claimed by the Databases in the namespace
+	Storage int64 `json:"storage"`
+
+	// Replicas is the number of replicas of the Databases in the namespace
+	Replicas int32 `json:"replicas"`
+}
+
+// SkillsQuotaStatus defines the observed state of SkillsQuota
+type SkillsQuotaStatus struct {
+	// +kubebuilder:validation:Optional
+	// ObservedGeneration is the generation observed by the controller
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Usage is the usage of every selected namespace, sorted by namespace
+	Usage []NamespaceUsage `json:"usage,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Conditions represent the latest available observations
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster,shortName=sq,categories=databases
+//+kubebuilder:printcolumn:name="MAX DATABASES",type=integer,JSONPath=`.spec.maxDatabases`
+//+kubebuilder:printcolumn:name="EXCEEDED",type=string,JSONPath=`.status.conditions[?(@.type=="Exceeded")].status`
+//+kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// SkillsQuota limits the Databases of every selected namespace. The validating webhook of
+// Databases rejects creates and scale-ups that would exceed it; the controller reports the
+// usage of each namespace and flags namespaces over a limit that was lowered after the fact.
+type SkillsQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SkillsQuotaSpec   `json:"spec,omitempty"`
+	Status SkillsQuotaStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SkillsQuotaList contains a list of SkillsQuota
+type SkillsQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SkillsQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SkillsQuota{}, &SkillsQuotaList{})
+}
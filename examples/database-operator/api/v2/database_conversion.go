@@ -0,0 +1,191 @@
+package v2
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// conversionAnnotation keeps the fields of a Database that the other version cannot hold, so
+// an object read and written back through either version keeps them: on a stored v1 object it
+// holds v2 fields, on a v2 object it holds v1 fields
+const conversionAnnotation = "database.my.domain/conversion"
+
+// mebibyte is the unit of the v1 storage size
+const mebibyte = 1 << 20
+
+// conversionData are the fields kept in the conversionAnnotation
+type conversionData struct {
+	// ServiceType is the deprecated v1 spec.serviceType, which v2 reads as spec.service.type
+	ServiceType corev1.ServiceType `json:"serviceType,omitempty"`
+
+	// ServiceFromType is set when the v1 spec.service had no type and v2 took it from
+	// spec.serviceType
+	ServiceFromType bool `json:"serviceFromType,omitempty"`
+
+	// StorageSize is the v2 spec.storage.size when it is not a whole number of MiB, or not
+	// written in binary units, e.g. 1500M or 10G
+	StorageSize *resource.Quantity `json:"storageSize,omitempty"`
+}
+
+// ConvertTo converts this Database to the hub version, v1
+func (src *Database) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*databasev1.Database)
+	restored, err := getConversionData(src)
+	if err != nil {
+		return err
+	}
+	spec := src.Spec.DeepCopy()
+
+	size := spec.Storage.Size
+	mebibytes := (size.Value() + mebibyte - 1) / mebibyte
+	if mebibytes > math.MaxInt32 {
+		return fmt.Errorf("spec.storage.size %s is too large", size.String())
+	}
+	var kept conversionData
+	if written := storageSize(int32(mebibytes)); size.String() != written.String() {
+		kept.StorageSize = &size
+	}
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = databasev1.DatabaseSpec{
+		Replicas:          spec.Instances,
+		Image:             spec.Image,
+		Storage:           int32(mebibytes),
+		StorageClass:      spec.Storage.StorageClassName,
+		ClassName:         spec.ClassName,
+		Parameters:        spec.Parameters,
+		ConfigMapName:     spec.ConfigMapName,
+		Service:           spec.Service,
+		Resources:         spec.Resources,
+		PriorityClassName: spec.PriorityClassName,
+		ImagePullSecrets:  spec.ImagePullSecrets,
+		RequeuePolicy:     spec.RequeuePolicy,
+		ImageUpdatePolicy: spec.ImageUpdatePolicy,
+		Standby:           spec.Standby,
+		Maintenance:       spec.Maintenance,
+		Monitoring:        spec.Monitoring,
+		ZoneSpread:        spec.ZoneSpread,
+		VerticalScaling:   spec.VerticalScaling,
+		Workload:          spec.Workload,
+		HighAvailability:  spec.HighAvailability,
+	}
+	if bootstrap := spec.Bootstrap; bootstrap != nil {
+		dst.Spec.DatabaseName = bootstrap.Database
+		dst.Spec.UserName = bootstrap.Owner
+		dst.Spec.PasswordSecretName = bootstrap.PasswordSecretName
+	}
+	// serviceType comes back unless a v2 client changed the type since; v1 rejects the two
+	// differing
+	if restored.ServiceType != "" && spec.Service != nil && spec.Service.Type == restored.ServiceType {
+		dst.Spec.ServiceType = restored.ServiceType
+		if restored.ServiceFromType {
+			dst.Spec.Service = nil
+		}
+	}
+	dst.Status = *src.Status.DeepCopy()
+	return setConversionData(dst, kept)
+}
+
+// ConvertFrom converts the hub version, v1, to this Database
+func (dst *Database) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*databasev1.Database)
+	restored, err := getConversionData(src)
+	if err != nil {
+		return err
+	}
+	spec := src.Spec.DeepCopy()
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = DatabaseSpec{
+		Instances: spec.Replicas,
+		Image:     spec.Image,
+		Storage: StorageSpec{
+			Size:             storageSize(spec.Storage),
+			StorageClassName: spec.StorageClass,
+		},
+		ClassName:         spec.ClassName,
+		Parameters:        spec.Parameters,
+		ConfigMapName:     spec.ConfigMapName,
+		Service:           spec.Service,
+		Resources:         spec.Resources,
+		PriorityClassName: spec.PriorityClassName,
+		ImagePullSecrets:  spec.ImagePullSecrets,
+		RequeuePolicy:     spec.RequeuePolicy,
+		ImageUpdatePolicy: spec.ImageUpdatePolicy,
+		Standby:           spec.Standby,
+		Maintenance:       spec.Maintenance,
+		Monitoring:        spec.Monitoring,
+		ZoneSpread:        spec.ZoneSpread,
+		VerticalScaling:   spec.VerticalScaling,
+		Workload:          spec.Workload,
+		HighAvailability:  spec.HighAvailability,
+	}
+	// The size as a v2 client wrote it, unless a v1 client changed it since
+	if size := restored.StorageSize; size != nil && (size.Value()+mebibyte-1)/mebibyte == int64(spec.Storage) {
+		dst.Spec.Storage.Size = *size
+	}
+	if spec.DatabaseName != "" || spec.UserName != "" || spec.PasswordSecretName != "" {
+		dst.Spec.Bootstrap = &BootstrapSpec{
+			Database:           spec.DatabaseName,
+			Owner:              spec.UserName,
+			PasswordSecretName: spec.PasswordSecretName,
+		}
+	}
+
+	var kept conversionData
+	if spec.ServiceType != "" {
+		kept.ServiceType = spec.ServiceType
+		if spec.Service == nil || spec.Service.Type == "" {
+			kept.ServiceFromType = true
+			dst.Spec.Service = &databasev1.ServiceSpec{Type: spec.ServiceType}
+		}
+	}
+	dst.Status = *src.Status.DeepCopy()
+	return setConversionData(dst, kept)
+}
+
+// storageSize is the v1 storage size in MiB as a quantity
+func storageSize(mebibytes int32) resource.Quantity {
+	return *resource.NewQuantity(int64(mebibytes)*mebibyte, resource.BinarySI)
+}
+
+// getConversionData returns the fields kept in the conversionAnnotation of obj
+func getConversionData(obj metav1.Object) (conversionData, error) {
+	var data conversionData
+	if value, ok := obj.GetAnnotations()[conversionAnnotation]; ok {
+		if err := json.Unmarshal([]byte(value), &data); err != nil {
+			return data, fmt.Errorf("invalid annotation %s: %w", conversionAnnotation, err)
+		}
+	}
+	return data, nil
+}
+
+// setConversionData replaces the conversionAnnotation of obj with data, and removes it when
+// there is nothing to keep
+func setConversionData(obj metav1.Object, data conversionData) error {
+	annotations := obj.GetAnnotations()
+	delete(annotations, conversionAnnotation)
+	if data != (conversionData{}) {
+		value, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[conversionAnnotation] = string(value)
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	obj.SetAnnotations(annotations)
+	return nil
+}
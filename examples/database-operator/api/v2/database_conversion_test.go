@@ -0,0 +1,130 @@
+package v2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func newV1Database() *databasev1.Database {
+	return &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop", Labels: map[string]string{"team": "checkout"}},
+		Spec: databasev1.DatabaseSpec{
+			Replicas:           3,
+			Image:              "postgres:15",
+			Storage:            1536,
+			StorageClass:       "fast",
+			DatabaseName:       "orders",
+			UserName:           "app",
+			PasswordSecretName: "orders-password",
+			ServiceType:        corev1.ServiceTypeNodePort,
+			Parameters:         map[string]string{"max_connections": "200"},
+			Workload:           databasev1.WorkloadStatefulSet,
+			HighAvailability:   true,
+		},
+		Status: databasev1.DatabaseStatus{Phase: "Running", ReadyReplicas: 3, Primary: "orders-0"},
+	}
+}
+
+func TestDatabase_ConvertFrom(t *testing.T) {
+	hub := newV1Database()
+	database := &Database{}
+	require.NoError(t, database.ConvertFrom(hub))
+
+	assert.Equal(t, int32(3), database.Spec.Instances)
+	assert.Equal(t, "1536Mi", database.Spec.Storage.Size.String())
+	assert.Equal(t, "fast", database.Spec.Storage.StorageClassName)
+	assert.Equal(t, &BootstrapSpec{Database: "orders", Owner: "app", PasswordSecretName: "orders-password"}, database.Spec.Bootstrap)
+	assert.Equal(t, &databasev1.ServiceSpec{Type: corev1.ServiceTypeNodePort}, database.Spec.Service, "serviceType is read as service.type")
+	assert.Equal(t, hub.Status, database.Status)
+	assert.Equal(t, "checkout", database.Labels["team"])
+
+	// Nothing is shared with the hub
+	database.Spec.Parameters["max_connections"] = "100"
+	database.Labels["team"] = "payments"
+	assert.Equal(t, "200", hub.Spec.Parameters["max_connections"])
+	assert.Equal(t, "checkout", hub.Labels["team"])
+}
+
+func TestDatabase_RoundTrip(t *testing.T) {
+	t.Run("v1", func(t *testing.T) {
+		for name, mutate := range map[string]func(*databasev1.Database){
+			"serviceType":              func(*databasev1.Database) {},
+			"serviceType with service": func(d *databasev1.Database) { d.Spec.Service = &databasev1.ServiceSpec{Type: d.Spec.ServiceType} },
+			"service":                  func(d *databasev1.Database) { d.Spec.Service, d.Spec.ServiceType = &databasev1.ServiceSpec{}, "" },
+			"no bootstrap": func(d *databasev1.Database) {
+				d.Spec.DatabaseName, d.Spec.UserName, d.Spec.PasswordSecretName = "", "", ""
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				hub := newV1Database()
+				mutate(hub)
+				database := &Database{}
+				require.NoError(t, database.ConvertFrom(hub))
+				back := &databasev1.Database{}
+				require.NoError(t, database.ConvertTo(back))
+				assert.Equal(t, hub, back)
+			})
+		}
+	})
+
+	t.Run("v2", func(t *testing.T) {
+		for _, size := range []string{"1Gi", "1536Mi", "1500M", "10G"} {
+			t.Run(size, func(t *testing.T) {
+				database := &Database{
+					ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop"},
+					Spec: DatabaseSpec{
+						Instances: 1,
+						Image:     "postgres:15",
+						Storage:   StorageSpec{Size: resource.MustParse(size)},
+						Bootstrap: &BootstrapSpec{Database: "orders"},
+					},
+				}
+				hub := &databasev1.Database{}
+				require.NoError(t, database.ConvertTo(hub))
+				back := &Database{}
+				require.NoError(t, back.ConvertFrom(hub))
+				assert.Equal(t, size, back.Spec.Storage.Size.String())
+				assert.Empty(t, back.Annotations)
+				back.Spec.Storage.Size = database.Spec.Storage.Size
+				assert.Equal(t, database, back)
+			})
+		}
+	})
+}
+
+func TestDatabase_ConvertTo(t *testing.T) {
+	database := &Database{Spec: DatabaseSpec{Instances: 1, Storage: StorageSpec{Size: resource.MustParse("1500M")}}}
+	hub := &databasev1.Database{}
+	require.NoError(t, database.ConvertTo(hub))
+	assert.Equal(t, int32(1431), hub.Spec.Storage, "Rounded up to whole MiB")
+	assert.Equal(t, `{"storageSize":"1500M"}`, hub.Annotations[conversionAnnotation])
+
+	// A v1 client changing the size wins over the one kept for v2
+	hub.Spec.Storage = 2048
+	require.NoError(t, database.ConvertFrom(hub))
+	assert.Equal(t, "2Gi", database.Spec.Storage.Size.String())
+
+	database.Spec.Storage.Size = resource.MustParse("3000Ti")
+	assert.EqualError(t, database.ConvertTo(hub), "spec.storage.size 3000Ti is too large")
+}
+
+func TestDatabase_ConvertToServiceType(t *testing.T) {
+	hub := newV1Database()
+	database := &Database{}
+	require.NoError(t, database.ConvertFrom(hub))
+
+	// A v2 client changing the type drops serviceType, which v1 would reject as a conflict
+	database.Spec.Service.Type = corev1.ServiceTypeLoadBalancer
+	back := &databasev1.Database{}
+	require.NoError(t, database.ConvertTo(back))
+	assert.Empty(t, back.Spec.ServiceType)
+	assert.Equal(t, &databasev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer}, back.Spec.Service)
+	assert.Empty(t, back.Annotations)
+}
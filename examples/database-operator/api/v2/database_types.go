@@ -0,0 +1,161 @@
+package v2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// DatabaseSpec defines the desired state of Database. Against v1, replicas is renamed to
+// instances, the storage size and class are grouped under storage with the size as a
+// quantity, the database, user and password secret are grouped under bootstrap, and the
+// deprecated serviceType is gone. Fields whose schema did not change reuse the v1 types, so a
+// change to one of them changes both versions.
+type DatabaseSpec struct {
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// Instances is the number of database pods
+	Instances int32 `json:"instances"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MinLength=1
+	// Image is the database container image; required unless the DatabaseClass sets it
+	Image string `json:"image,omitempty"`
+
+	// Storage is the persistent volume of the database
+	Storage StorageSpec `json:"storage"`
+
+	// +kubebuilder:validation:Optional
+	// ClassName names the DatabaseClass whose presets fill the fields this spec leaves unset
+	ClassName string `json:"className,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Parameters are PostgreSQL server settings, passed as -c name=value
+	Parameters map[string]string `json:"parameters,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Bootstrap is what the database is created with
+	Bootstrap *BootstrapSpec `json:"bootstrap,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ConfigMapName is the name of the configmap with additional settings
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Service configures the Service clients connect through
+	Service *databasev1.ServiceSpec `json:"service,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Resources are the compute resources of the database container
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// PriorityClassName is the PriorityClass of every pod the operator creates for the Database
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ImagePullSecrets are Secrets in the Database namespace used to pull the database image
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// RequeuePolicy overrides the operator-wide periodic resync intervals for this Database
+	RequeuePolicy *databasev1.RequeuePolicy `json:"requeuePolicy,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ImageUpdatePolicy lets the operator move spec.image to newer tags of the same repository
+	ImageUpdatePolicy *databasev1.ImageUpdatePolicy `json:"imageUpdatePolicy,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Standby makes the Database a read-only replica of a primary in another namespace or
+	// cluster. It can only be set when the Database is created.
+	Standby *databasev1.StandbySpec `json:"standby,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Maintenance schedules maintenance tasks such as VACUUM ANALYZE into a recurring window
+	Maintenance *databasev1.MaintenanceSpec `json:"maintenance,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Monitoring configures the monitoring integrations of the Database
+	Monitoring *databasev1.MonitoringSpec `json:"monitoring,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ZoneSpread spreads the database pods across zones and reports how they are placed
+	ZoneSpread *databasev1.ZoneSpreadSpec `json:"zoneSpread,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// VerticalScaling lets the operator recommend, and optionally apply, the requests and
+	// limits of the database container from its usage history
+	VerticalScaling *databasev1.VerticalScalingPolicy `json:"verticalScaling,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet
+	// Workload is the kind of workload running the database pods
+	Workload databasev1.WorkloadKind `json:"workload,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// HighAvailability runs the database as a primary and Instances-1 streaming replicas. It
+	// can only be set when the Database is created.
+	HighAvailability bool `json:"highAvailability,omitempty"`
+}
+
+// StorageSpec is the persistent volume of a Database
+type StorageSpec struct {
+	// Size is the size of the volume, e.g. 10Gi. v1 stores it in MiB, so sizes that are not
+	// a whole number of MiB are rounded up for the volume.
+	Size resource.Quantity `json:"size"`
+
+	// +kubebuilder:validation:Optional
+	// StorageClassName is the storage class of the volume
+	StorageClassName string `json:"storageClassName,omitempty"`
+}
+
+// BootstrapSpec is what a Database is created with
+type BootstrapSpec struct {
+	// +kubebuilder:validation:Optional
+	// Database is the name of the database to create
+	Database string `json:"database,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Owner is the user owning the database
+	Owner string `json:"owner,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// PasswordSecretName is the name of the secret containing the password of the owner
+	PasswordSecretName string `json:"passwordSecretName,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:unservedversion
+//+kubebuilder:resource:shortName=db,categories=all;databases
+//+kubebuilder:printcolumn:name="PHASE",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="READY",type=string,JSONPath=`.status.readyReplicas`
+//+kubebuilder:printcolumn:name="REASON",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+//+kubebuilder:printcolumn:name="PRIMARY",type=string,JSONPath=`.status.pods[?(@.role=="primary")].name`,priority=1
+//+kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Database is the Schema for the databases API. The status is the same as in v1. v2 is not
+// served unless the conversion webhook is: config/crd/patches/webhook_in_databases.yaml serves
+// it and converts through the operator.
+type Database struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DatabaseSpec              `json:"spec,omitempty"`
+	Status databasev1.DatabaseStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DatabaseList contains a list of Database
+type DatabaseList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Database `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Database{}, &DatabaseList{})
+}
@@ -0,0 +1,22 @@
+// Package v2 contains API Schema definitions for the my.domain v2 API group. v2 renames and
+// regroups fields of v1; v1 stays the storage version, and the conversion webhook converts
+// between the two through it.
+// +kubebuilder:object:generate=true
+// +groupName=my.domain
+package v2
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+var (
+	// GroupVersion is group version used to register these objects
+	GroupVersion = schema.GroupVersion{Group: "my.domain", Version: "v2"}
+
+	// SchemeBuilder is used to add go types to the GroupVersionKind scheme
+	SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+
+	// AddToScheme adds the types in this group-version to the given scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
@@ -0,0 +1,197 @@
+// Package apitest checks the API types registered in a scheme, so a new type or field cannot
+// break serialization or defaulting unnoticed:
+//
+//   - every exported field has a json tag; without one the field is written under its Go
+//     name, which the CRD schema does not list, so the API server prunes it
+//   - fuzzed objects survive a JSON round trip unchanged
+//   - defaulting a valid object twice gives the same object as defaulting it once, and the
+//     defaulted object passes validation, as a create and as an update to itself
+//
+// CheckGroupVersion runs the checks over every kind of a group version in the scheme:
+//
+//	apitest.CheckGroupVersion(t, scheme, databasev1.GroupVersion, map[string]apitest.Kind{
+//		"Database": {New: validDatabase, Defaulter: &DatabaseDefaulter{}, Validator: &DatabaseValidator{}},
+//	})
+//
+// A kind registered without an entry fails the test, so adding a type means deciding how it
+// is defaulted and validated. List kinds are only round tripped.
+package apitest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"k8s.io/apimachinery/pkg/api/apitesting/fuzzer"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metafuzzer "k8s.io/apimachinery/pkg/apis/meta/fuzzer"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// RoundTrips is the number of fuzzed objects round tripped per kind
+const RoundTrips = 50
+
+// metaPkgPath is the package of the option and watch types every group version has
+var metaPkgPath = reflect.TypeOf(metav1.Status{}).PkgPath()
+
+// Kind is how objects of one kind are defaulted and validated
+type Kind struct {
+	// New returns a valid object as a user would create it, before defaulting
+	New func() runtime.Object
+
+	// Defaulter and Validator are the webhooks of the kind; nil skips their checks
+	Defaulter admission.CustomDefaulter
+	Validator admission.CustomValidator
+}
+
+// CheckGroupVersion runs the checks over every kind of gv registered in the scheme, in a
+// subtest per kind
+func CheckGroupVersion(t *testing.T, scheme *runtime.Scheme, gv schema.GroupVersion, kinds map[string]Kind) {
+	t.Helper()
+	known := scheme.KnownTypes(gv)
+	if len(known) == 0 {
+		t.Fatalf("no kinds of %s are registered in the scheme", gv)
+	}
+	names := make([]string, 0, len(known))
+	for name, typ := range known {
+		// AddToGroupVersion registers the option and watch types of metav1 with every group
+		if typ.PkgPath() != metaPkgPath {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		typ := known[name]
+		kind, configured := kinds[name]
+		t.Run(name, func(t *testing.T) {
+			for _, path := range MissingJSONTags(typ) {
+				t.Errorf("%s has no json tag", path)
+			}
+			for seed := int64(0); seed < RoundTrips; seed++ {
+				if err := RoundTrip(scheme, gv.WithKind(name), seed); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if strings.HasSuffix(name, "List") {
+				return
+			}
+			if !configured {
+				t.Fatalf("%s is registered but has no entry in the kinds to check", name)
+			}
+			if err := kind.CheckDefaulting(context.Background()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	for name := range kinds {
+		if _, ok := known[name]; !ok {
+			t.Errorf("%s is not a kind of %s", name, gv)
+		}
+	}
+}
+
+// MissingJSONTags returns the paths of the exported fields of a struct type, and of the
+// struct types it contains from the same package, that have no json tag or are tagged "-"
+func MissingJSONTags(typ reflect.Type) []string {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	pkgPath := typ.PkgPath()
+	var missing []string
+	visited := map[reflect.Type]bool{}
+	var walk func(typ reflect.Type, path string)
+	walk = func(typ reflect.Type, path string) {
+		for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+			typ = typ.Elem()
+		}
+		// Types from other packages, like metav1.ObjectMeta, are checked by their owners
+		if typ.Kind() != reflect.Struct || typ.PkgPath() != pkgPath || visited[typ] {
+			return
+		}
+		visited[typ] = true
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fieldPath := path + "." + field.Name
+			tag, ok := field.Tag.Lookup("json")
+			if !ok || tag == "-" {
+				missing = append(missing, fieldPath)
+				continue
+			}
+			walk(field.Type, fieldPath)
+		}
+	}
+	walk(typ, typ.Name())
+	return missing
+}
+
+// RoundTrip fuzzes an object of a kind with a seed, encodes it to JSON and decodes it into a
+// new object, and returns an error showing what did not survive
+func RoundTrip(scheme *runtime.Scheme, gvk schema.GroupVersionKind, seed int64) error {
+	original, err := scheme.New(gvk)
+	if err != nil {
+		return err
+	}
+	fuzzer.FuzzerFor(metafuzzer.Funcs, rand.NewSource(seed), serializer.NewCodecFactory(scheme)).Fuzz(original)
+
+	data, err := json.Marshal(original)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s (seed %d): %w", gvk.Kind, seed, err)
+	}
+	decoded, err := scheme.New(gvk)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		return fmt.Errorf("failed to decode %s (seed %d): %w", gvk.Kind, seed, err)
+	}
+	if !apiequality.Semantic.DeepEqual(original, decoded) {
+		return fmt.Errorf("%s (seed %d) changed in a JSON round trip (-original +decoded):\n%s",
+			gvk.Kind, seed, cmp.Diff(original, decoded, cmpopts.EquateEmpty()))
+	}
+	return nil
+}
+
+// CheckDefaulting defaults a new object twice and validates the result
+func (k Kind) CheckDefaulting(ctx context.Context) error {
+	if k.New == nil {
+		return nil
+	}
+	obj := k.New()
+	if k.Defaulter != nil {
+		if err := k.Defaulter.Default(ctx, obj); err != nil {
+			return fmt.Errorf("failed to default: %w", err)
+		}
+		again := obj.DeepCopyObject()
+		if err := k.Defaulter.Default(ctx, again); err != nil {
+			return fmt.Errorf("failed to default a defaulted object: %w", err)
+		}
+		if !apiequality.Semantic.DeepEqual(obj, again) {
+			return fmt.Errorf("defaulting is not idempotent (-once +twice):\n%s", cmp.Diff(obj, again, cmpopts.EquateEmpty()))
+		}
+	}
+	if k.Validator != nil {
+		if _, err := k.Validator.ValidateCreate(ctx, obj); err != nil {
+			return fmt.Errorf("defaulted object fails validation: %w", err)
+		}
+		if _, err := k.Validator.ValidateUpdate(ctx, obj, obj.DeepCopyObject()); err != nil {
+			return fmt.Errorf("defaulted object fails validation as an update to itself: %w", err)
+		}
+	}
+	return nil
+}
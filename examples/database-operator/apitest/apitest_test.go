@@ -0,0 +1,100 @@
+package apitest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// widget is an API type with the mistakes the checks catch
+type widget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec widgetSpec `json:"spec"`
+}
+
+type widgetSpec struct {
+	Size   int32 `json:"size"`
+	Color  string
+	Secret string   `json:"-"`
+	Nested []nested `json:"nested,omitempty"`
+}
+
+type nested struct {
+	Name string
+}
+
+func (w *widget) DeepCopyObject() runtime.Object {
+	out := *w
+	w.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec.Nested = append([]nested(nil), w.Spec.Nested...)
+	return &out
+}
+
+var widgetKind = schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+
+func TestMissingJSONTags(t *testing.T) {
+	assert.Equal(t, []string{"widget.Spec.Color", "widget.Spec.Secret", "widget.Spec.Nested.Name"},
+		MissingJSONTags(reflect.TypeOf(&widget{})))
+}
+
+func TestRoundTrip(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(widgetKind, &widget{})
+
+	err := RoundTrip(scheme, widgetKind, 1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Widget (seed 1) changed in a JSON round trip")
+	assert.Contains(t, err.Error(), "Secret:")
+}
+
+// sizeDefaulter doubles the size instead of setting it once
+type sizeDefaulter struct{}
+
+func (sizeDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	obj.(*widget).Spec.Size *= 2
+	return nil
+}
+
+// sizeValidator rejects widgets larger than 2
+type sizeValidator struct{}
+
+func (sizeValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	if obj.(*widget).Spec.Size > 2 {
+		return nil, errors.New("too large")
+	}
+	return nil, nil
+}
+
+func (v sizeValidator) ValidateUpdate(ctx context.Context, _, obj runtime.Object) (admission.Warnings, error) {
+	return v.ValidateCreate(ctx, obj)
+}
+
+func (sizeValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func TestKind_CheckDefaulting(t *testing.T) {
+	ctx := context.Background()
+	newWidget := func(size int32) func() runtime.Object {
+		return func() runtime.Object { return &widget{Spec: widgetSpec{Size: size}} }
+	}
+
+	err := Kind{New: newWidget(1), Defaulter: sizeDefaulter{}}.CheckDefaulting(ctx)
+	assert.ErrorContains(t, err, "defaulting is not idempotent")
+
+	// Defaulting 0 twice is idempotent, but 2 is accepted and 4 is not
+	assert.NoError(t, Kind{New: newWidget(0), Defaulter: sizeDefaulter{}, Validator: sizeValidator{}}.CheckDefaulting(ctx))
+	assert.NoError(t, Kind{New: newWidget(2), Validator: sizeValidator{}}.CheckDefaulting(ctx))
+	err = Kind{New: newWidget(4), Validator: sizeValidator{}}.CheckDefaulting(ctx)
+	assert.ErrorContains(t, err, "defaulted object fails validation: too large")
+}
@@ -0,0 +1,350 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/dependency"
+)
+
+// conditionWebReady is the Application condition of the web tier. It is rolled up into
+// Ready together with WaitingForDatabase, which the database gate sets.
+const conditionWebReady = "WebReady"
+
+// Phases of an Application
+const (
+	applicationWaitingForDatabase = "WaitingForDatabase"
+	applicationProgressing        = "Progressing"
+	applicationReady              = "Ready"
+	applicationDegraded           = "Degraded"
+)
+
+const (
+	// applicationNameLabel selects the web pods of an Application
+	applicationNameLabel = "database.my.domain/application"
+
+	// connectionChecksumAnnotation records the connection Secret content the web pods were
+	// started with, so they restart with the new password when it is rotated
+	connectionChecksumAnnotation = "database.my.domain/connection-checksum"
+)
+
+// ApplicationReconciler creates the Database and the web tier of an Application, and wires
+// them together.
+//
+// The ordering between the two is carried by the Database status, not by the order of calls:
+//   - the Database is created first, and the database gate requeues the Application on every
+//     status update of the Database controller
+//   - the connection Secret is derived from the password Secret the Database controller
+//     generates, and watched through it, so a rotated password reaches the web pods
+//   - the web Deployment is only created once the Database reports Ready for its current
+//     generation. After that, a Database that becomes unready leaves the web tier running and
+//     the Application Degraded: scaling the web tier down would turn a database blip into an
+//     outage of every page, including those that need no database.
+type ApplicationReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Switches can turn the controller off at runtime; nil keeps it on
+	Switches *Switches
+}
+
+//+kubebuilder:rbac:groups=my.domain,resources=applications,verbs=get;list;watch
+//+kubebuilder:rbac:groups=my.domain,resources=applications/status,verbs=get;update;patch
+
+// Reconcile creates the Database, then the connection Secret and the web tier once the
+// Database is ready, and rolls their state up into the Application status
+func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	app := &databasev1.Application{}
+	if err := r.Get(ctx, req.NamespacedName, app); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !app.DeletionTimestamp.IsZero() {
+		// The children are garbage collected through their owner references
+		return ctrl.Result{}, nil
+	}
+
+	database, err := r.reconcileApplicationDatabase(ctx, app)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to reconcile Database: %w", err)
+	}
+	databaseReady, err := r.databaseGate().WaitFor(ctx, app, &app.Status.Conditions, database.Name, "Ready")
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	web := &appsv1.Deployment{}
+	err = r.Get(ctx, types.NamespacedName{Name: applicationWebName(app), Namespace: app.Namespace}, web)
+	if client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	if apierrors.IsNotFound(err) {
+		web = nil
+	}
+
+	// Nothing is started before the Database was ready once; the gate requeues
+	if web == nil && !databaseReady {
+		return ctrl.Result{}, r.updateApplicationStatus(ctx, app, database, databaseReady, nil)
+	}
+
+	secret, err := r.reconcileConnectionSecret(ctx, app, database)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to reconcile connection Secret: %w", err)
+	}
+	if secret == nil {
+		// The password Secret is gone; the Database controller recreates it
+		return ctrl.Result{}, r.updateApplicationStatus(ctx, app, database, databaseReady, web)
+	}
+	if web, err = r.reconcileWeb(ctx, app, secret); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to reconcile web Deployment: %w", err)
+	}
+	return ctrl.Result{}, r.updateApplicationStatus(ctx, app, database, databaseReady, web)
+}
+
+// databaseGate gates the web tier of an Application on the Ready condition of its Database
+func (r *ApplicationReconciler) databaseGate() *dependency.Gate {
+	return &dependency.Gate{
+		Kind:      "Database",
+		Object:    &databasev1.Database{},
+		Owner:     &databasev1.Application{},
+		OwnerList: &databasev1.ApplicationList{},
+		References: func(o client.Object) []string {
+			return []string{applicationDatabaseName(o.(*databasev1.Application))}
+		},
+		Reader: r.Client,
+	}
+}
+
+// reconcileApplicationDatabase creates or updates the Database of the Application. Only the
+// fields the Application sets are written, so defaults applied to the Database stay.
+func (r *ApplicationReconciler) reconcileApplicationDatabase(ctx context.Context, app *databasev1.Application) (*databasev1.Database, error) {
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: applicationDatabaseName(app), Namespace: app.Namespace},
+	}
+	_, err := controllerutil.CreateOrPatch(ctx, r.Client, database, func() error {
+		spec := app.Spec.Database
+		database.Spec.Replicas = spec.Replicas
+		if database.Spec.Replicas == 0 {
+			database.Spec.Replicas = 1
+		}
+		database.Spec.Storage = spec.Storage
+		if spec.ClassName != "" {
+			database.Spec.ClassName = spec.ClassName
+		}
+		if spec.Image != "" {
+			database.Spec.Image = spec.Image
+		}
+		if spec.DatabaseName != "" {
+			database.Spec.DatabaseName = spec.DatabaseName
+		}
+		if spec.UserName != "" {
+			database.Spec.UserName = spec.UserName
+		}
+		return controllerutil.SetControllerReference(app, database, r.Scheme)
+	})
+	return database, err
+}
+
+// reconcileConnectionSecret publishes the connection details of the Database under the
+// libpq variable names, plus DATABASE_URL, so most frameworks connect without configuration.
+// It returns nil while the password Secret does not exist.
+func (r *ApplicationReconciler) reconcileConnectionSecret(ctx context.Context, app *databasev1.Application, database *databasev1.Database) (*corev1.Secret, error) {
+	password := &corev1.Secret{}
+	key := types.NamespacedName{Name: passwordSecretName(database), Namespace: database.Namespace}
+	if err := r.Get(ctx, key, password); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	user, dbname := loginNames(database)
+	host := serviceName(database) + "." + database.Namespace + ".svc"
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, string(password.Data["password"])),
+		Host:     net.JoinHostPort(host, "5432"),
+		Path:     "/" + dbname,
+		RawQuery: "sslmode=disable",
+	}
+	// A password kept in a secret manager stays there: the connection Secret passes the
+	// reference on for the application to resolve, and the URL carries no password
+	passwordKey, passwordValue := "PGPASSWORD", password.Data["password"]
+	if ref, ok := password.Data[passwordReferenceKey]; ok {
+		dsn.User = url.User(user)
+		passwordKey, passwordValue = "PGPASSWORD_REF", ref
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: applicationConnectionSecretName(app), Namespace: app.Namespace},
+	}
+	_, err := controllerutil.CreateOrPatch(ctx, r.Client, secret, func() error {
+		secret.Data = map[string][]byte{
+			"PGHOST":       []byte(host),
+			"PGPORT":       []byte("5432"),
+			"PGDATABASE":   []byte(dbname),
+			"PGUSER":       []byte(user),
+			passwordKey:    passwordValue,
+			"DATABASE_URL": []byte(dsn.String()),
+		}
+		return controllerutil.SetControllerReference(app, secret, r.Scheme)
+	})
+	return secret, err
+}
+
+// reconcileWeb creates or updates the web Deployment, reading its environment from the
+// connection Secret
+func (r *ApplicationReconciler) reconcileWeb(ctx context.Context, app *databasev1.Application, secret *corev1.Secret) (*appsv1.Deployment, error) {
+	web := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: applicationWebName(app), Namespace: app.Namespace},
+	}
+	labels := map[string]string{applicationNameLabel: app.Name}
+
+	_, err := controllerutil.CreateOrPatch(ctx, r.Client, web, func() error {
+		if web.Spec.Selector == nil {
+			// The selector is immutable
+			web.Spec.Selector = &metav1.LabelSelector{MatchLabels: labels}
+		}
+		web.Spec.Replicas = app.Spec.Web.Replicas
+		web.Spec.Template.Labels = labels
+		web.Spec.Template.Annotations = map[string]string{
+			connectionChecksumAnnotation: dataChecksum(nil, secret.Data),
+		}
+
+		port := app.Spec.Web.Port
+		if port == 0 {
+			port = 8080
+		}
+		web.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name:  "web",
+			Image: app.Spec.Web.Image,
+			Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: port}},
+			EnvFrom: []corev1.EnvFromSource{{
+				SecretRef: &corev1.SecretEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+				},
+			}},
+			// Variables in env take precedence over those from envFrom
+			Env: app.Spec.Web.Env,
+		}}
+		return controllerutil.SetControllerReference(app, web, r.Scheme)
+	})
+	return web, err
+}
+
+// updateApplicationStatus rolls the state of the Database, as recorded by the database gate,
+// and of the web Deployment up into the Application status. web is nil while the web tier was
+// not started.
+func (r *ApplicationReconciler) updateApplicationStatus(ctx context.Context, app *databasev1.Application, database *databasev1.Database, databaseReady bool, web *appsv1.Deployment) error {
+	waiting := meta.FindStatusCondition(app.Status.Conditions, r.databaseGate().ConditionType())
+
+	webCondition := metav1.Condition{
+		Type:               conditionWebReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             applicationWaitingForDatabase,
+		Message:            fmt.Sprintf("The web tier starts once Database %s is ready", database.Name),
+		ObservedGeneration: app.Generation,
+	}
+	if web != nil {
+		desired := int32(1)
+		if web.Spec.Replicas != nil {
+			desired = *web.Spec.Replicas
+		}
+		webCondition.Reason = "Progressing"
+		webCondition.Message = fmt.Sprintf("Waiting for web replicas: %d/%d", web.Status.AvailableReplicas, desired)
+		if web.Status.ObservedGeneration >= web.Generation &&
+			web.Status.UpdatedReplicas == desired && web.Status.AvailableReplicas == desired {
+			webCondition.Status = metav1.ConditionTrue
+			webCondition.Reason = "Available"
+			webCondition.Message = fmt.Sprintf("%d web replicas are available", desired)
+		}
+	}
+
+	readyCondition := metav1.Condition{
+		Type:               "Ready",
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: app.Generation,
+	}
+	switch {
+	case web == nil:
+		app.Status.Phase = applicationWaitingForDatabase
+		readyCondition.Reason = applicationWaitingForDatabase
+		readyCondition.Message = waiting.Message
+	case !databaseReady:
+		app.Status.Phase = applicationDegraded
+		readyCondition.Reason = "DatabaseNotReady"
+		readyCondition.Message = waiting.Message
+	case webCondition.Status != metav1.ConditionTrue:
+		app.Status.Phase = applicationProgressing
+		readyCondition.Reason = "Progressing"
+		readyCondition.Message = webCondition.Message
+	default:
+		app.Status.Phase = applicationReady
+		readyCondition.Status = metav1.ConditionTrue
+		readyCondition.Reason = "Ready"
+		readyCondition.Message = "The Database and the web tier are ready"
+	}
+
+	app.Status.ObservedGeneration = app.Generation
+	app.Status.DatabaseName = database.Name
+	app.Status.ConnectionSecretName = ""
+	app.Status.DeploymentName = ""
+	if web != nil {
+		app.Status.ConnectionSecretName = applicationConnectionSecretName(app)
+		app.Status.DeploymentName = web.Name
+	}
+	meta.SetStatusCondition(&app.Status.Conditions, webCondition)
+	meta.SetStatusCondition(&app.Status.Conditions, readyCondition)
+	return r.Status().Update(ctx, app)
+}
+
+// findApplicationForPasswordSecret maps the password Secret of a Database to the Application
+// controlling the Database, so a rotated password is copied to the connection Secret
+func (r *ApplicationReconciler) findApplicationForPasswordSecret(ctx context.Context, o client.Object) []reconcile.Request {
+	name, ok := o.GetLabels()[databaseNameLabel]
+	if !ok {
+		return nil
+	}
+	database := &databasev1.Database{}
+	if err := getDatabaseForLabel(ctx, r.Client, o.GetNamespace(), name, database); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.FromContext(ctx).Error(err, "failed to get Database", "database", name)
+		}
+		return nil
+	}
+	owner := metav1.GetControllerOf(database)
+	if owner == nil || owner.Kind != "Application" || owner.APIVersion != databasev1.GroupVersion.String() {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: owner.Name, Namespace: o.GetNamespace()}}}
+}
+
+// SetupWithManager sets up the controller with the Manager
+func (r *ApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&databasev1.Application{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Secret{}).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.findApplicationForPasswordSecret),
+		)
+	// Status updates of the Database are what moves the Application forward
+	if err := r.databaseGate().SetupWithManager(mgr, b); err != nil {
+		return err
+	}
+	return b.Complete(r.Switches.Controller(ApplicationController, r))
+}
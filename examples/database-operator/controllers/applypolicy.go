@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/policy"
+)
+
+// conditionPolicyCompliant is False while a child of a Database breaks an apply policy and is
+// therefore not written; it is only set when apply policies are configured
+const conditionPolicyCompliant = "PolicyCompliant"
+
+// Reasons of the PolicyCompliant condition
+const (
+	reasonPolicyViolation   = "PolicyViolation"
+	reasonPoliciesSatisfied = "PoliciesSatisfied"
+	reasonPoliciesInvalid   = "ApplyPoliciesInvalid"
+)
+
+// policyRecheckInterval is how soon a blocked Database is reconciled again. The policies
+// ConfigMap is not watched, so a fixed policy is picked up by this requeue.
+const policyRecheckInterval = time.Minute
+
+// ApplyPolicySource loads the policies the children of Databases must satisfy before they are
+// written from a ConfigMap maintained by the cluster admin. Every key holds one policy:
+//
+//	apiVersion: v1
+//	kind: ConfigMap
+//	metadata:
+//	  name: database-operator-apply-policies
+//	  namespace: database-operator-system
+//	data:
+//	  pinned-images: |
+//	    kinds: [Deployment, StatefulSet]
+//	    expression: object.spec.template.spec.containers.all(c, !c.image.endsWith(':latest'))
+//	    message: database images must be pinned
+//	  internal-services: |
+//	    kinds: [Service]
+//	    expression: "!has(object.spec.type) || object.spec.type == 'ClusterIP'"
+//
+// See package policy for the expressions. The ConfigMap is read on every reconcile and the
+// policies are compiled again only when it changes.
+type ApplyPolicySource struct {
+	client.Reader
+
+	// Namespace and Name locate the ConfigMap; an empty Name disables apply policies
+	Namespace string
+	Name      string
+
+	mu      sync.Mutex
+	version string
+	set     *policy.Set
+}
+
+// BindFlags registers flags for the policies ConfigMap, using the current values as defaults
+func (s *ApplyPolicySource) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.Namespace, "apply-policies-configmap-namespace", s.Namespace,
+		"Namespace of the ConfigMap holding the CEL policies children are checked against before they are written.")
+	fs.StringVar(&s.Name, "apply-policies-configmap-name", s.Name,
+		"Name of the ConfigMap holding the CEL policies children are checked against before they are written. Empty disables apply policies.")
+}
+
+// enabled reports whether apply policies are configured
+func (s *ApplyPolicySource) enabled() bool {
+	return s != nil && s.Name != ""
+}
+
+// Load returns the current policies. A missing ConfigMap means no policies.
+func (s *ApplyPolicySource) Load(ctx context.Context) (*policy.Set, error) {
+	if !s.enabled() {
+		return nil, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := s.Get(ctx, types.NamespacedName{Namespace: s.Namespace, Name: s.Name}, configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get apply policies ConfigMap: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if configMap.ResourceVersion != "" && configMap.ResourceVersion == s.version {
+		return s.set, nil
+	}
+	policies, err := policy.Parse(configMap.Data)
+	if err != nil {
+		return nil, fmt.Errorf("apply policies ConfigMap %s/%s: %w", s.Namespace, s.Name, err)
+	}
+	set, err := policy.Compile(policies)
+	if err != nil {
+		return nil, fmt.Errorf("apply policies ConfigMap %s/%s: %w", s.Namespace, s.Name, err)
+	}
+	s.version, s.set = configMap.ResourceVersion, set
+	return set, nil
+}
+
+// policyViolationError is returned instead of writing a child that breaks apply policies
+type policyViolationError struct {
+	violations []policy.Violation
+}
+
+func (e *policyViolationError) Error() string {
+	messages := make([]string, 0, len(e.violations))
+	for _, v := range e.violations {
+		messages = append(messages, v.String())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// asPolicyViolation returns the violations of a child step error, merging those of steps that
+// ran in parallel, if every failure is a violation
+func asPolicyViolation(err error) (*policyViolationError, bool) {
+	if aggregate, ok := err.(kerrors.Aggregate); ok {
+		merged := &policyViolationError{}
+		for _, e := range aggregate.Errors() {
+			violation, ok := asPolicyViolation(e)
+			if !ok {
+				return nil, false
+			}
+			merged.violations = append(merged.violations, violation.violations...)
+		}
+		return merged, len(merged.violations) > 0
+	}
+	var violation *policyViolationError
+	ok := errors.As(err, &violation)
+	return violation, ok
+}
+
+// checkApplyPolicies evaluates the apply policies against a child about to be written
+func (r *DatabaseReconciler) checkApplyPolicies(ctx context.Context, obj client.Object) error {
+	if !r.ApplyPolicies.enabled() {
+		return nil
+	}
+	set, err := r.ApplyPolicies.Load(ctx)
+	if err != nil || set.Len() == 0 {
+		return err
+	}
+	gvk, err := apiutil.GVKForObject(obj, r.Client.Scheme())
+	if err != nil {
+		return err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	name := client.ObjectKeyFromObject(obj).String()
+	if violations := set.Evaluate(gvk.Kind, name, content); len(violations) > 0 {
+		return &policyViolationError{violations: violations}
+	}
+	return nil
+}
+
+// blockOnPolicy reports a child that was not written because it breaks apply policies. The
+// Database keeps its other children and is checked again after policyRecheckInterval.
+func (r *DatabaseReconciler) blockOnPolicy(ctx context.Context, database *databasev1.Database, violation *policyViolationError) (ctrl.Result, error) {
+	r.warn(database, nil, reasonPolicyViolation, "Reconcile", violation.Error())
+	database.Status.Phase = "Blocked"
+	database.SetCondition(conditionPolicyCompliant, metav1.ConditionFalse, reasonPolicyViolation, violation.Error())
+	database.SetCondition("Ready", metav1.ConditionFalse, reasonPolicyViolation, violation.Error())
+	r.recordHistory(ctx, database, violation)
+	if err := r.Status().Update(ctx, database); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: policyRecheckInterval}, nil
+}
+
+// observeApplyPolicies records that every child satisfied the apply policies, or drops the
+// condition once they are no longer configured
+func (r *DatabaseReconciler) observeApplyPolicies(database *databasev1.Database) {
+	if !r.ApplyPolicies.enabled() {
+		meta.RemoveStatusCondition(&database.Status.Conditions, conditionPolicyCompliant)
+		return
+	}
+	database.SetCondition(conditionPolicyCompliant, metav1.ConditionTrue, reasonPoliciesSatisfied, "Every child satisfies the apply policies")
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/policy"
+)
+
+func TestDatabaseReconciler_ApplyPolicies(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "orders",
+			Namespace:  "default",
+			Generation: 1,
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{Replicas: 3, Image: "postgres:15", Storage: 1024},
+	}
+	policies := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "database-operator-apply-policies", Namespace: "database-operator-system"},
+		Data: map[string]string{
+			"small-deployments": "kinds: [Deployment]\nexpression: object.spec.replicas <= 2\nmessage: at most 2 replicas\n",
+		},
+	}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database, policies).
+		WithStatusSubresource(database).
+		Build()
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &DatabaseReconciler{
+		Client:        fakeClient,
+		Scheme:        scheme,
+		Recorder:      recorder,
+		ApplyPolicies: &ApplyPolicySource{Reader: fakeClient, Namespace: policies.Namespace, Name: policies.Name},
+	}
+	ctx := context.Background()
+	key := client.ObjectKeyFromObject(database)
+	deploymentKey := client.ObjectKey{Namespace: "default", Name: deploymentName(database)}
+
+	// The Deployment breaks the policy and is not written; the children before it are
+	result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, policyRecheckInterval, result.RequeueAfter)
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.Equal(t, "Blocked", database.Status.Phase)
+	condition := database.GetCondition(conditionPolicyCompliant)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "Deployment default/"+deploymentName(database)+" violates policy small-deployments: at most 2 replicas", condition.Message)
+	assert.Contains(t, <-recorder.Events, reasonPolicyViolation)
+	err = fakeClient.Get(ctx, deploymentKey, &appsv1.Deployment{})
+	assert.True(t, apierrors.IsNotFound(err), "The Deployment was not created")
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Namespace: "default", Name: serviceName(database)}, &corev1.Service{}))
+
+	// A broken policy stops the reconcile before any write
+	policies.Data["typo"] = "expression: object.spec.replicas <\n"
+	require.NoError(t, fakeClient.Update(ctx, policies))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	assert.ErrorContains(t, err, "policy typo")
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.Equal(t, reasonPoliciesInvalid, database.GetCondition("Ready").Reason)
+
+	// Once the policy allows it, the Deployment is written
+	delete(policies.Data, "typo")
+	policies.Data["small-deployments"] = "kinds: [Deployment]\nexpression: object.spec.replicas <= 3\n"
+	require.NoError(t, fakeClient.Update(ctx, policies))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, deploymentKey, &appsv1.Deployment{}))
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.Equal(t, metav1.ConditionTrue, database.GetCondition(conditionPolicyCompliant).Status)
+
+	// Without policies the condition is dropped
+	reconciler.ApplyPolicies = nil
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.Nil(t, database.GetCondition(conditionPolicyCompliant))
+}
+
+func TestAsPolicyViolation(t *testing.T) {
+	service := &policyViolationError{violations: []policy.Violation{{Policy: "internal", Kind: "Service", Name: "default/orders", Message: "must be ClusterIP"}}}
+	secret := &policyViolationError{violations: []policy.Violation{{Policy: "labelled", Kind: "Secret", Name: "default/orders", Message: "needs labels"}}}
+
+	violation, ok := asPolicyViolation(fmt.Errorf("failed to reconcile Service: %w", service))
+	require.True(t, ok)
+	assert.Equal(t, service, violation)
+
+	// Violations of children reconciled in parallel are reported together
+	violation, ok = asPolicyViolation(kerrors.NewAggregate([]error{service, secret}))
+	require.True(t, ok)
+	assert.Len(t, violation.violations, 2)
+	assert.Contains(t, violation.Error(), "; Secret default/orders violates policy labelled")
+
+	_, ok = asPolicyViolation(kerrors.NewAggregate([]error{service, errors.New("connection refused")}))
+	assert.False(t, ok, "Other failures are reported as such")
+}
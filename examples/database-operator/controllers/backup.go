@@ -0,0 +1,287 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/dependency"
+)
+
+// conditionBackupComplete is the Backup condition recording the outcome of its Job
+const conditionBackupComplete = "Complete"
+
+// DefaultUploaderImage uploads the dumps of Backups that set no uploader image
+const DefaultUploaderImage = "amazon/aws-cli:2.15.30"
+
+// backupVolume is the emptyDir the dump of a Backup is written to and uploaded from, and the
+// dump of a Restore downloaded to and loaded from
+const (
+	backupVolume    = "dump"
+	backupMountPath = "/backup"
+)
+
+// BackupReconciler takes the Backups of Databases. Each Backup runs one Job: an init container
+// of the database image dumps the Database with pg_dump into an emptyDir, and the uploader
+// container copies the dump to the bucket with the aws CLI, so a dump is only uploaded once it
+// is complete. The Job is owned by the Backup and kept as the record of the run.
+//
+// The Job only starts once the Database is ready; the database gate requeues the Backup when
+// it becomes so. A failed Job is not retried: the Backup reports it, and a new Backup retries.
+type BackupReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// UploaderImage is the default uploader image; empty uses DefaultUploaderImage
+	UploaderImage string
+
+	// Passwords mounts the password of Databases that keep it in a secret manager
+	Passwords *ExternalPasswords
+
+	// Switches can turn the controller off at runtime; nil keeps it on
+	Switches *Switches
+}
+
+//+kubebuilder:rbac:groups=my.domain,resources=backups,verbs=get;list;watch
+//+kubebuilder:rbac:groups=my.domain,resources=backups/status,verbs=get;update;patch
+
+// Reconcile starts the Job of a Backup once its Database is ready and records its outcome
+func (r *BackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	backup := &databasev1.Backup{}
+	if err := r.Get(ctx, req.NamespacedName, backup); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !backup.DeletionTimestamp.IsZero() || backup.IsFinished() {
+		// The Job is garbage collected with the Backup; the dump stays in the bucket
+		return ctrl.Result{}, nil
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: backup.Namespace, Name: backupJobName(backup)}, job)
+	if client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	if apierrors.IsNotFound(err) {
+		ready, err := r.databaseGate().WaitFor(ctx, backup, &backup.Status.Conditions, backup.Spec.DatabaseName, "Ready")
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !ready {
+			waiting := meta.FindStatusCondition(backup.Status.Conditions, r.databaseGate().ConditionType())
+			r.setBackupStatus(backup, databasev1.BackupPending, metav1.ConditionFalse, "WaitingForDatabase", waiting.Message)
+			return ctrl.Result{}, r.Status().Update(ctx, backup)
+		}
+		if job, err = r.startBackup(ctx, backup); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to start backup: %w", err)
+		}
+	}
+
+	backup.Status.JobName = job.Name
+	backup.Status.Location = backupLocation(backup)
+	if job.Status.StartTime != nil {
+		backup.Status.StartTime = job.Status.StartTime
+	} else if backup.Status.StartTime == nil {
+		now := metav1.Now()
+		backup.Status.StartTime = &now
+	}
+	completed, failed, message := jobOutcome(job)
+	switch {
+	case completed == nil:
+		// Owns(&batchv1.Job{}) reconciles again when it finishes
+		r.setBackupStatus(backup, databasev1.BackupRunning, metav1.ConditionFalse, "Running",
+			fmt.Sprintf("Job %s is dumping Database %s", job.Name, backup.Spec.DatabaseName))
+	case failed:
+		backup.Status.CompletionTime = completed
+		r.setBackupStatus(backup, databasev1.BackupFailed, metav1.ConditionFalse, "Failed", message)
+	default:
+		backup.Status.CompletionTime = completed
+		r.setBackupStatus(backup, databasev1.BackupCompleted, metav1.ConditionTrue, "Uploaded",
+			fmt.Sprintf("Database %s was uploaded to %s", backup.Spec.DatabaseName, backup.Status.Location))
+	}
+	return ctrl.Result{}, r.Status().Update(ctx, backup)
+}
+
+// databaseGate gates the Job of a Backup on the Ready condition of its Database
+func (r *BackupReconciler) databaseGate() *dependency.Gate {
+	return &dependency.Gate{
+		Kind:      "Database",
+		Object:    &databasev1.Database{},
+		Owner:     &databasev1.Backup{},
+		OwnerList: &databasev1.BackupList{},
+		References: func(o client.Object) []string {
+			return []string{o.(*databasev1.Backup).Spec.DatabaseName}
+		},
+		Reader: r.Client,
+	}
+}
+
+// setBackupStatus sets the phase and the Complete condition of a Backup
+func (r *BackupReconciler) setBackupStatus(backup *databasev1.Backup, phase string, status metav1.ConditionStatus, reason, message string) {
+	backup.Status.Phase = phase
+	backup.Status.ObservedGeneration = backup.Generation
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+		Type:               conditionBackupComplete,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: backup.Generation,
+	})
+}
+
+// startBackup creates the Job of a Backup
+func (r *BackupReconciler) startBackup(ctx context.Context, backup *databasev1.Backup) (*batchv1.Job, error) {
+	database := &databasev1.Database{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: backup.Namespace, Name: backup.Spec.DatabaseName}, database); err != nil {
+		return nil, err
+	}
+	user, dbname := loginNames(database)
+	destination := backup.Spec.Destination
+	dump := backupMountPath + "/" + dbname + ".dump"
+	uploaderImage := backup.Spec.UploaderImage
+	if uploaderImage == "" {
+		uploaderImage = r.UploaderImage
+	}
+
+	job := dumpJob(backupJobName(backup), backup.Namespace, database, backupMountPath,
+		corev1.Container{
+			Name:    "dump",
+			Image:   database.Spec.Image,
+			Command: []string{"pg_dump", "--format=custom", "--file=" + dump},
+			Env:     databaseEnv(database, user, dbname),
+		},
+		s3Container("upload", uploaderImage, s3Copy(dump, backupLocation(backup), destination.Endpoint),
+			destination.Region, destination.CredentialsSecretName))
+	r.Passwords.mountPassword(database, &job.Spec.Template.Spec)
+	if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// dumpJob returns the Job of a Backup or Restore: an init container and a container sharing
+// the dump volume, run once with the pull secrets and priority class of the Database
+func dumpJob(name, namespace string, database *databasev1.Database, mountPath string, init, container corev1.Container) *batchv1.Job {
+	backoffLimit := int32(0)
+	allowPrivilegeEscalation := false
+	// pg_dump, pg_restore and the aws CLI stream the dump; none needs much beyond the Database itself
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("50m"),
+			corev1.ResourceMemory: resource.MustParse("64Mi"),
+		},
+	}
+	for _, c := range []*corev1.Container{&init, &container} {
+		c.VolumeMounts = []corev1.VolumeMount{{Name: backupVolume, MountPath: mountPath}}
+		c.Resources = resources
+		c.SecurityContext = &corev1.SecurityContext{AllowPrivilegeEscalation: &allowPrivilegeEscalation}
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: batchv1.JobSpec{
+			// A failed run is reported; retrying is creating a new one
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy:     corev1.RestartPolicyNever,
+					ImagePullSecrets:  database.Spec.ImagePullSecrets,
+					PriorityClassName: database.Spec.PriorityClassName,
+					InitContainers:    []corev1.Container{init},
+					Containers:        []corev1.Container{container},
+					Volumes: []corev1.Volume{{
+						Name:         backupVolume,
+						VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// databaseEnv connects the PostgreSQL client tools to a Database
+func databaseEnv(database *databasev1.Database, user, dbname string) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{Name: "PGHOST", Value: serviceName(database)},
+		{Name: "PGUSER", Value: user},
+		{Name: "PGDATABASE", Value: dbname},
+		{
+			Name: "PGPASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: passwordSecretName(database)},
+					Key:                  "password",
+				},
+			},
+		},
+	}
+}
+
+// s3Container returns a container of the given image, DefaultUploaderImage when empty, running
+// an aws CLI command against a bucket in region with the credentials of the named Secret
+func s3Container(name, image string, command []string, region, credentialsSecretName string) corev1.Container {
+	if image == "" {
+		image = DefaultUploaderImage
+	}
+	var env []corev1.EnvVar
+	if region != "" {
+		env = append(env, corev1.EnvVar{Name: "AWS_DEFAULT_REGION", Value: region})
+	}
+	return corev1.Container{
+		Name:    name,
+		Image:   image,
+		Command: command,
+		Env:     env,
+		EnvFrom: []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: credentialsSecretName},
+			},
+		}},
+	}
+}
+
+// s3Copy is the aws CLI command copying a file to or from a bucket; an empty endpoint uses AWS S3
+func s3Copy(from, to, endpoint string) []string {
+	command := []string{"aws", "s3", "cp", from, to}
+	if endpoint != "" {
+		command = append(command, "--endpoint-url", endpoint)
+	}
+	return command
+}
+
+// backupLocation is the URL the dump of a Backup is uploaded to. The key is unique per Backup,
+// so a new Backup never overwrites an earlier dump.
+func backupLocation(backup *databasev1.Backup) string {
+	destination := backup.Spec.Destination
+	key := path.Join(destination.Prefix, backup.Namespace, backup.Spec.DatabaseName, backup.Name+".dump")
+	return "s3://" + destination.Bucket + "/" + key
+}
+
+// SetupWithManager sets up the controller with the Manager
+func (r *BackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&databasev1.Backup{}).
+		Owns(&batchv1.Job{})
+	// Backups waiting for their Database start when it becomes ready
+	if err := r.databaseGate().SetupWithManager(mgr, b); err != nil {
+		return err
+	}
+	return b.Complete(r.Switches.Controller(BackupController, r))
+}
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/secretstore"
+)
+
+func newBackupFixture(t *testing.T, objects ...client.Object) (*BackupReconciler, client.Client) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, batchv1.AddToScheme(scheme))
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(&databasev1.Backup{}, &databasev1.Database{}, &batchv1.Job{}).
+		Build()
+	return &BackupReconciler{Client: fakeClient, Scheme: scheme}, fakeClient
+}
+
+func newNightlyBackup() *databasev1.Backup {
+	return &databasev1.Backup{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "default"},
+		Spec: databasev1.BackupSpec{
+			DatabaseName: "orders",
+			Destination: databasev1.BackupDestination{
+				Bucket:                "backups",
+				Endpoint:              "http://minio.storage.svc:9000",
+				Region:                "eu-west-1",
+				Prefix:                "postgres",
+				CredentialsSecretName: "minio-credentials",
+			},
+		},
+	}
+}
+
+func newBackupDatabase() *databasev1.Database {
+	return &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "default", Generation: 1},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024, UserName: "app"},
+	}
+}
+
+// finishJob stands in for the Job controller
+func finishJob(t *testing.T, c client.Client, key types.NamespacedName, conditionType batchv1.JobConditionType, message string) {
+	ctx := context.Background()
+	job := &batchv1.Job{}
+	require.NoError(t, c.Get(ctx, key, job))
+	job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+		Type:               conditionType,
+		Status:             corev1.ConditionTrue,
+		Reason:             string(conditionType),
+		Message:            message,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+	})
+	require.NoError(t, c.Status().Update(ctx, job))
+}
+
+func TestBackupReconciler_DumpsAndUploads(t *testing.T) {
+	reconciler, fakeClient := newBackupFixture(t, newNightlyBackup(), newBackupDatabase())
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "nightly", Namespace: "default"}
+	jobKey := types.NamespacedName{Name: "nightly-backup", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	// Nothing runs before the Database is ready
+	backup := &databasev1.Backup{}
+	require.NoError(t, fakeClient.Get(ctx, key, backup))
+	assert.Equal(t, databasev1.BackupPending, backup.Status.Phase)
+	assert.Equal(t, "WaitingForDatabase", meta.FindStatusCondition(backup.Status.Conditions, conditionBackupComplete).Reason)
+	assert.True(t, apierrors.IsNotFound(fakeClient.Get(ctx, jobKey, &batchv1.Job{})))
+
+	setDatabaseReady(t, fakeClient, types.NamespacedName{Name: "orders", Namespace: "default"}, metav1.ConditionTrue)
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	job := &batchv1.Job{}
+	require.NoError(t, fakeClient.Get(ctx, jobKey, job))
+	assert.True(t, metav1.IsControlledBy(job, backup))
+	podSpec := job.Spec.Template.Spec
+	require.Len(t, podSpec.InitContainers, 1)
+	dump := podSpec.InitContainers[0]
+	assert.Equal(t, "postgres:15", dump.Image)
+	assert.Equal(t, []string{"pg_dump", "--format=custom", "--file=/backup/app.dump"}, dump.Command)
+	assert.Contains(t, dump.Env, corev1.EnvVar{Name: "PGHOST", Value: "orders"})
+	require.Len(t, podSpec.Containers, 1)
+	upload := podSpec.Containers[0]
+	assert.Equal(t, DefaultUploaderImage, upload.Image)
+	assert.Equal(t, []string{"aws", "s3", "cp", "/backup/app.dump", "s3://backups/postgres/default/orders/nightly.dump",
+		"--endpoint-url", "http://minio.storage.svc:9000"}, upload.Command)
+	assert.Equal(t, []corev1.EnvVar{{Name: "AWS_DEFAULT_REGION", Value: "eu-west-1"}}, upload.Env)
+	assert.Equal(t, "minio-credentials", upload.EnvFrom[0].SecretRef.Name)
+
+	require.NoError(t, fakeClient.Get(ctx, key, backup))
+	assert.Equal(t, databasev1.BackupRunning, backup.Status.Phase)
+	assert.Equal(t, "nightly-backup", backup.Status.JobName)
+	assert.Equal(t, "s3://backups/postgres/default/orders/nightly.dump", backup.Status.Location)
+	assert.NotNil(t, backup.Status.StartTime)
+
+	finishJob(t, fakeClient, jobKey, batchv1.JobComplete, "")
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, backup))
+	assert.Equal(t, databasev1.BackupCompleted, backup.Status.Phase)
+	assert.NotNil(t, backup.Status.CompletionTime)
+	condition := meta.FindStatusCondition(backup.Status.Conditions, conditionBackupComplete)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "Uploaded", condition.Reason)
+
+	// A finished Backup is left alone, even once its Job is gone
+	require.NoError(t, fakeClient.Delete(ctx, job))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.True(t, apierrors.IsNotFound(fakeClient.Get(ctx, jobKey, &batchv1.Job{})), "A Backup runs once")
+}
+
+func TestBackupReconciler_Failed(t *testing.T) {
+	backup := newNightlyBackup()
+	backup.Spec.UploaderImage = "minio/mc-aws:1.0"
+	reconciler, fakeClient := newBackupFixture(t, backup, newBackupDatabase())
+	reconciler.UploaderImage = "registry.example.com/aws-cli:2"
+	setDatabaseReady(t, fakeClient, types.NamespacedName{Name: "orders", Namespace: "default"}, metav1.ConditionTrue)
+
+	ctx := context.Background()
+	key := client.ObjectKeyFromObject(backup)
+	jobKey := types.NamespacedName{Name: "nightly-backup", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	job := &batchv1.Job{}
+	require.NoError(t, fakeClient.Get(ctx, jobKey, job))
+	assert.Equal(t, "minio/mc-aws:1.0", job.Spec.Template.Spec.Containers[0].Image, "The Backup's image wins")
+
+	finishJob(t, fakeClient, jobKey, batchv1.JobFailed, "Job has reached the specified backoff limit")
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, backup))
+	assert.Equal(t, databasev1.BackupFailed, backup.Status.Phase)
+	condition := meta.FindStatusCondition(backup.Status.Conditions, conditionBackupComplete)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "Failed: Job has reached the specified backoff limit", condition.Message)
+}
+
+func TestBackupReconciler_ExternalPassword(t *testing.T) {
+	reconciler, fakeClient := newBackupFixture(t, newNightlyBackup(), newBackupDatabase())
+	reconciler.Passwords = &ExternalPasswords{Manager: secretstore.NewMemory()}
+	setDatabaseReady(t, fakeClient, types.NamespacedName{Name: "orders", Namespace: "default"}, metav1.ConditionTrue)
+
+	ctx := context.Background()
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "nightly", Namespace: "default"}})
+	require.NoError(t, err)
+
+	job := &batchv1.Job{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "nightly-backup", Namespace: "default"}, job))
+	podSpec := job.Spec.Template.Spec
+	dump := podSpec.InitContainers[0]
+	assert.Equal(t, []string{"sh", "-c", `PGPASSWORD="$(cat /var/run/secrets/database/password)" exec "$@"`, "--",
+		"pg_dump", "--format=custom", "--file=/backup/app.dump"}, dump.Command)
+	for _, env := range dump.Env {
+		assert.NotEqual(t, "PGPASSWORD", env.Name)
+	}
+	assert.Len(t, podSpec.Volumes, 2, "The dump and the password")
+	assert.Len(t, podSpec.Containers[0].VolumeMounts, 1, "The upload does not read the password")
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// conditionBreakGlass is True while the operator only reports on a Database
+const conditionBreakGlass = "BreakGlass"
+
+// Reasons of the BreakGlass condition
+const (
+	reasonOperatorBreakGlass = "OperatorBreakGlass"
+	reasonDatabaseBreakGlass = "DatabaseBreakGlass"
+	reasonBreakGlassOff      = "BreakGlassOff"
+)
+
+// breakGlassReason returns why the Database must be left as it is, empty if it may be changed
+func (r *DatabaseReconciler) breakGlassReason(database *databasev1.Database) string {
+	switch {
+	case r.Switches.InBreakGlass(nil):
+		return reasonOperatorBreakGlass
+	case r.Switches.InBreakGlass(database):
+		return reasonDatabaseBreakGlass
+	}
+	return ""
+}
+
+// observeBreakGlass reports the state of a Database without changing it or its children: not
+// even its finalizer, so a Database deleted during break-glass waits until it ends. The spec
+// is not applied, so observedGeneration stays where it was. Children edited by hand are listed
+// in the BreakGlass condition, so it is clear what the operator will revert once it ends.
+func (r *DatabaseReconciler) observeBreakGlass(ctx context.Context, database *databasev1.Database, reason string) (ctrl.Result, error) {
+	deployment := &appsv1.Deployment{}
+	key := types.NamespacedName{Name: deploymentName(database), Namespace: database.Namespace}
+	if err := r.Get(ctx, key, deployment); client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	pods, err := r.listDatabasePods(ctx, database)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	database.Status.ReadyReplicas = deployment.Status.ReadyReplicas
+	database.Status.Pods = podStatuses(pods)
+
+	message := "Break-glass is on: the operator reports the state of the Database but does not change it or its children"
+	if drift := breakGlassDrift(database, deployment); len(drift) > 0 {
+		message += "; " + strings.Join(drift, "; ")
+	}
+	if current := database.GetCondition(conditionBreakGlass); current == nil || current.Status != metav1.ConditionTrue {
+		r.warn(database, nil, reason, "BreakGlass", message)
+	}
+	database.SetCondition(conditionBreakGlass, metav1.ConditionTrue, reason, message)
+	pruneStatus(database)
+	if err := r.Status().Update(ctx, database); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Removing the annotation queues a reconcile; the end of operator-wide break-glass does not
+	if reason == reasonOperatorBreakGlass {
+		return ctrl.Result{RequeueAfter: r.Switches.retryAfter()}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// breakGlassDrift describes the hand edits to the children of a Database
+func breakGlassDrift(database *databasev1.Database, deployment *appsv1.Deployment) []string {
+	var drift []string
+	if deployment.Name == "" {
+		return []string{fmt.Sprintf("Deployment %s does not exist", deploymentName(database))}
+	}
+	if applied, ok := deployment.Annotations[appliedGenerationAnnotation]; ok && applied != strconv.FormatInt(deployment.Generation, 10) {
+		drift = append(drift, fmt.Sprintf("Deployment %s was changed since the operator last applied it", deployment.Name))
+	}
+	if replicas := deployment.Spec.Replicas; replicas != nil && *replicas != database.Spec.Replicas {
+		drift = append(drift, fmt.Sprintf("Deployment %s runs %d replicas, the spec asks for %d", deployment.Name, *replicas, database.Spec.Replicas))
+	}
+	return drift
+}
+
+// endBreakGlass records that the operator manages the Database again, and reports whether
+// the status changed
+func endBreakGlass(database *databasev1.Database) bool {
+	current := database.GetCondition(conditionBreakGlass)
+	if current == nil || current.Status != metav1.ConditionTrue {
+		return false
+	}
+	database.SetCondition(conditionBreakGlass, metav1.ConditionFalse, reasonBreakGlassOff,
+		"Break-glass is off; the operator manages the Database again")
+	return true
+}
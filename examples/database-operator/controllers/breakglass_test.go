@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestDatabaseReconciler_BreakGlass(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "orders",
+			Namespace:   "default",
+			Generation:  4,
+			Annotations: map[string]string{databasev1.BreakGlassAnnotation: "true"},
+		},
+		Spec:   databasev1.DatabaseSpec{Replicas: 2, Image: "postgres:15", Storage: 1024},
+		Status: databasev1.DatabaseStatus{ObservedGeneration: 3},
+	}
+	// Scaled down by hand during the incident
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        deploymentName(database),
+			Namespace:   "default",
+			Generation:  6,
+			Annotations: map[string]string{appliedGenerationAnnotation: "5"},
+		},
+		Spec:   appsv1.DeploymentSpec{Replicas: ptr.To[int32](1)},
+		Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+	}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database, deployment).
+		WithStatusSubresource(database).
+		Build()
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme, Recorder: recorder}
+	ctx := context.Background()
+	key := client.ObjectKeyFromObject(database)
+
+	result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, ctrl.Result{}, result, "Removing the annotation queues the next reconcile")
+
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.Empty(t, database.Finalizers, "Not even the finalizer is added")
+	assert.Equal(t, int64(3), database.Status.ObservedGeneration, "The spec was not applied")
+	assert.Equal(t, int32(1), database.Status.ReadyReplicas, "The state is still reported")
+	condition := database.GetCondition(conditionBreakGlass)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, reasonDatabaseBreakGlass, condition.Reason)
+	assert.Contains(t, condition.Message, "was changed since the operator last applied it")
+	assert.Contains(t, condition.Message, "runs 1 replicas, the spec asks for 2")
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, reasonDatabaseBreakGlass)
+
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(deployment), deployment))
+	assert.Equal(t, int32(1), *deployment.Spec.Replicas, "The hand edit is kept")
+
+	// Reconciling again does not repeat the event
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Empty(t, recorder.Events)
+
+	// Operator-wide break-glass is checked again after the switches interval
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	database.Annotations = nil
+	require.NoError(t, fakeClient.Update(ctx, database))
+	reconciler.Switches = &Switches{Interval: 10 * time.Second, BreakGlass: true}
+	result, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, ctrl.Result{RequeueAfter: 10 * time.Second}, result)
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.Equal(t, reasonOperatorBreakGlass, database.GetCondition(conditionBreakGlass).Reason)
+}
+
+func TestEndBreakGlass(t *testing.T) {
+	database := &databasev1.Database{}
+	assert.False(t, endBreakGlass(database), "Nothing to end")
+	assert.Nil(t, database.GetCondition(conditionBreakGlass))
+
+	database.SetCondition(conditionBreakGlass, metav1.ConditionTrue, reasonDatabaseBreakGlass, "on")
+	assert.True(t, endBreakGlass(database))
+	assert.Equal(t, metav1.ConditionFalse, database.GetCondition(conditionBreakGlass).Status)
+	assert.False(t, endBreakGlass(database), "Ended once")
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// maxPooledBuffer bounds the buffers kept for reuse, so that one unusually large status does
+// not stay allocated for the lifetime of the operator
+const maxPooledBuffer = 1 << 20
+
+// encodeBuffer is a reusable buffer for the encodings the reconcile hot path only hashes or
+// measures. json.Marshal returns a new slice on every call, which for the rendered pod spec
+// and the status adds tens of kilobytes of garbage to every reconcile of every Database.
+type encodeBuffer struct {
+	bytes.Buffer
+	encoder *json.Encoder
+}
+
+var encodeBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := &encodeBuffer{}
+		buf.encoder = json.NewEncoder(&buf.Buffer)
+		return buf
+	},
+}
+
+// getEncodeBuffer returns an empty buffer from the pool; put returns it
+func getEncodeBuffer() *encodeBuffer {
+	buf := encodeBuffers.Get().(*encodeBuffer)
+	buf.Reset()
+	return buf
+}
+
+// put returns the buffer to the pool. Bytes returned by the buffer must not be used afterwards.
+func (b *encodeBuffer) put() {
+	if b.Cap() <= maxPooledBuffer {
+		encodeBuffers.Put(b)
+	}
+}
+
+// encodeJSON replaces the content of the buffer with the JSON encoding of v, byte for byte
+// what json.Marshal returns, and returns it. The bytes are only valid until the next use of
+// the buffer.
+func (b *encodeBuffer) encodeJSON(v interface{}) ([]byte, error) {
+	b.Reset()
+	if err := b.encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	// Encode ends the value with a newline that json.Marshal does not write
+	data := b.Bytes()
+	return data[:len(data)-1], nil
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeBuffer(t *testing.T) {
+	database := newHotPathDatabase()
+	database.Annotations["description"] = "<orders> & \"payments\""
+
+	buf := getEncodeBuffer()
+	defer buf.put()
+	for _, v := range []interface{}{
+		database,
+		[]interface{}{database.Spec, database.Labels},
+		map[string]string{"large": strings.Repeat("x", 64<<10)},
+		// A shorter value after a longer one does not keep the end of the longer one
+		"short",
+		nil,
+	} {
+		want, err := json.Marshal(v)
+		require.NoError(t, err)
+		got, err := buf.encodeJSON(v)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(got), "The encoding is the one of json.Marshal")
+	}
+
+	_, err := buf.encodeJSON(func() {})
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+const (
+	// templateHashAnnotation records the hash of the pod template the operator rendered
+	templateHashAnnotation = "database.my.domain/template-hash"
+
+	// deploymentHashAnnotation records the hash of the Deployment fields outside the pod template
+	deploymentHashAnnotation = "database.my.domain/deployment-hash"
+
+	// appliedGenerationAnnotation records the Deployment generation the operator last rendered
+	// against. Any later spec edit, e.g. by hand, bumps the generation past it.
+	appliedGenerationAnnotation = "database.my.domain/applied-generation"
+)
+
+// changeClass is how a change to a Database reaches its Deployment
+type changeClass int
+
+const (
+	// changeNone means nothing the Deployment is rendered from changed
+	changeNone changeClass = iota
+
+	// changeStatusOnly means the Database changed, e.g. its requeue policy, but nothing the
+	// Deployment is rendered from
+	changeStatusOnly
+
+	// changeOnline means the Deployment changes in place without restarting pods, e.g. replicas
+	// or Deployment labels
+	changeOnline
+
+	// changeRestart means the pod template changes and the Deployment rolls its pods
+	changeRestart
+)
+
+func (c changeClass) String() string {
+	switch c {
+	case changeNone:
+		return "None"
+	case changeStatusOnly:
+		return "StatusOnly"
+	case changeOnline:
+		return "Online"
+	case changeRestart:
+		return "Restart"
+	}
+	return "Unknown"
+}
+
+// deploymentHashes are the hashes of the rendered Deployment, split by whether a change
+// restarts the pods
+type deploymentHashes struct {
+	Template   string
+	Deployment string
+}
+
+// hashOf returns a short stable hash of the JSON encoding of values. encoding/json sorts map
+// keys, so the hash does not depend on map order. The hashes are recorded on the children, so
+// the encoding must not change: a different hash rolls the pods of every Database.
+func hashOf(values ...interface{}) string {
+	buf := getEncodeBuffer()
+	defer buf.put()
+	// Encoding plain API types cannot fail
+	data, _ := buf.encodeJSON(values)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:8])
+}
+
+// classifyDeploymentChange compares the hashes recorded on the Deployment with the desired
+// ones. Rendering the Deployment is only needed for online and restart changes.
+func classifyDeploymentChange(deployment *appsv1.Deployment, desired deploymentHashes, database *databasev1.Database) changeClass {
+	annotations := deployment.Annotations
+
+	// Deployments rendered before the hashes were recorded are rendered once more to record
+	// them; the template is the same, so the pods are not restarted
+	recorded, ok := annotations[templateHashAnnotation]
+	if !ok {
+		return changeOnline
+	}
+	if recorded != desired.Template {
+		return changeRestart
+	}
+
+	// A hand edit is reverted in place. If it touched the pod template, reverting it rolls
+	// the pods once more.
+	if annotations[deploymentHashAnnotation] != desired.Deployment ||
+		annotations[appliedGenerationAnnotation] != strconv.FormatInt(deployment.Generation, 10) {
+		return changeOnline
+	}
+
+	if database.Status.DesiredStateHash != desiredStateHash(database) {
+		return changeStatusOnly
+	}
+	return changeNone
+}
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestClassifyDeploymentChange(t *testing.T) {
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+	database.Status.DesiredStateHash = desiredStateHash(database)
+
+	desired := deploymentHashes{Template: "template", Deployment: "deployment"}
+	recorded := func(template, deployment, generation string) *appsv1.Deployment {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Generation: 3,
+			Annotations: map[string]string{
+				templateHashAnnotation:      template,
+				deploymentHashAnnotation:    deployment,
+				appliedGenerationAnnotation: generation,
+			},
+		}}
+	}
+
+	requeueChanged := database.DeepCopy()
+	requeueChanged.Spec.RequeuePolicy = &databasev1.RequeuePolicy{ReadyInterval: &metav1.Duration{}}
+
+	tests := []struct {
+		name       string
+		deployment *appsv1.Deployment
+		database   *databasev1.Database
+		expected   changeClass
+	}{
+		{
+			name:       "unchanged",
+			deployment: recorded("template", "deployment", "3"),
+			database:   database,
+			expected:   changeNone,
+		},
+		{
+			name:       "change that no child depends on",
+			deployment: recorded("template", "deployment", "3"),
+			database:   requeueChanged,
+			expected:   changeStatusOnly,
+		},
+		{
+			name:       "replicas or Deployment labels",
+			deployment: recorded("template", "old", "3"),
+			database:   database,
+			expected:   changeOnline,
+		},
+		{
+			name:       "edited by hand",
+			deployment: recorded("template", "deployment", "2"),
+			database:   database,
+			expected:   changeOnline,
+		},
+		{
+			name:       "pod template",
+			deployment: recorded("old", "deployment", "3"),
+			database:   database,
+			expected:   changeRestart,
+		},
+		{
+			name:       "rendered before hashes were recorded",
+			deployment: &appsv1.Deployment{},
+			database:   database,
+			expected:   changeOnline,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, classifyDeploymentChange(tt.deployment, desired, tt.database))
+		})
+	}
+}
+
+func TestDatabaseReconciler_SkipsUnchangedDeployment(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-db",
+			Namespace:  "default",
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+		},
+	}
+
+	patches := 0
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				if _, ok := obj.(*appsv1.Deployment); ok {
+					patches++
+				}
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+	reconcileAndGet := func() *appsv1.Deployment {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		require.NoError(t, err)
+		deployment := &appsv1.Deployment{}
+		require.NoError(t, fakeClient.Get(ctx, key, deployment))
+		return deployment
+	}
+
+	created := reconcileAndGet()
+	reconcileAndGet()
+	assert.Equal(t, 0, patches, "An unchanged Deployment is not patched")
+
+	// Scaling changes the Deployment in place
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	database.Spec.Replicas = 2
+	require.NoError(t, fakeClient.Update(ctx, database))
+	scaled := reconcileAndGet()
+	assert.Equal(t, 1, patches)
+	assert.Equal(t, int32(2), *scaled.Spec.Replicas)
+	assert.Equal(t, created.Annotations[templateHashAnnotation], scaled.Annotations[templateHashAnnotation])
+	assert.Equal(t, created.Spec.Template, scaled.Spec.Template, "Scaling does not restart the pods")
+
+	// A new image changes the pod template
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	database.Spec.Image = "postgres:16"
+	require.NoError(t, fakeClient.Update(ctx, database))
+	upgraded := reconcileAndGet()
+	assert.Equal(t, 2, patches)
+	assert.NotEqual(t, created.Annotations[templateHashAnnotation], upgraded.Annotations[templateHashAnnotation])
+	assert.Equal(t, "postgres:16", upgraded.Spec.Template.Spec.Containers[0].Image)
+}
+
+// BenchmarkHashOf measures the hashes every reconcile of every Database computes.
+// Run with: go test -run '^$' -bench HashOf -benchmem ./controllers/
+func BenchmarkHashOf(b *testing.B) {
+	database := newHotPathDatabase()
+	podSpec := renderPodSpec(database)
+	labels := selectorLabels(database)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		hashOf(labels, podSpec)
+		desiredStateHash(database)
+	}
+}
+
+func TestHashOfEncoding(t *testing.T) {
+	// The hashes recorded on Deployments before the encode buffers were pooled must still match,
+	// or upgrading the operator rolls the pods of every Database
+	database := newHotPathDatabase()
+	assert.Equal(t, "5f661a46d8aa66f8", hashOf(selectorLabels(database), renderPodSpec(database)))
+	assert.Equal(t, "022fda0d0351c1a1", desiredStateHash(database))
+}
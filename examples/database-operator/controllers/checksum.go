@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+const (
+	// secretChecksumAnnotation records the password Secret content the pods were started with
+	secretChecksumAnnotation = "database.my.domain/secret-checksum"
+
+	// configChecksumAnnotation records the ConfigMap content the pods were started with
+	configChecksumAnnotation = "database.my.domain/config-checksum"
+)
+
+// podTemplateChecksums returns the annotations that tie the pod template to the content
+// of the mounted Secret and ConfigMap. Changing content changes the pod template,
+// which makes the Deployment roll its pods exactly when the content changes.
+func (r *DatabaseReconciler) podTemplateChecksums(ctx context.Context, database *databasev1.Database) (map[string]string, error) {
+	checksums := map[string]string{}
+
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Name: passwordSecretName(database), Namespace: database.Namespace}
+	if err := r.Get(ctx, key, secret); client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+	checksums[secretChecksumAnnotation] = dataChecksum(secret.StringData, secret.Data)
+
+	if database.Spec.ConfigMapName != "" {
+		cm := &corev1.ConfigMap{}
+		key := types.NamespacedName{Name: database.Spec.ConfigMapName, Namespace: database.Namespace}
+		if err := r.Get(ctx, key, cm); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		checksums[configChecksumAnnotation] = dataChecksum(cm.Data, cm.BinaryData)
+	}
+
+	return checksums, nil
+}
+
+// dataChecksum returns a stable SHA-256 over string and binary data, independent of map order.
+// Each entry is hashed as "b/" or "s/", the key, a zero byte, the value and a zero byte, in
+// key order, so binary entries come first.
+func dataChecksum(stringData map[string]string, binaryData map[string][]byte) string {
+	buf := getEncodeBuffer()
+	defer buf.put()
+
+	keys := make([]string, 0, max(len(stringData), len(binaryData)))
+	for k := range binaryData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf.WriteString("b/")
+		buf.WriteString(k)
+		buf.WriteByte(0)
+		buf.Write(binaryData[k])
+		buf.WriteByte(0)
+	}
+
+	keys = keys[:0]
+	for k := range stringData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf.WriteString("s/")
+		buf.WriteString(k)
+		buf.WriteByte(0)
+		buf.WriteString(stringData[k])
+		buf.WriteByte(0)
+	}
+
+	hash := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestDataChecksum(t *testing.T) {
+	a := dataChecksum(map[string]string{"a": "1", "b": "2"}, nil)
+	b := dataChecksum(map[string]string{"b": "2", "a": "1"}, nil)
+	assert.Equal(t, a, b, "Checksum should not depend on map order")
+
+	changed := dataChecksum(map[string]string{"a": "1", "b": "3"}, nil)
+	assert.NotEqual(t, a, changed, "Checksum should change with content")
+
+	// Moving a value between keys must not collide
+	assert.NotEqual(t,
+		dataChecksum(map[string]string{"ab": "c"}, nil),
+		dataChecksum(map[string]string{"a": "bc"}, nil))
+
+	// String and binary data with the same key are distinct
+	assert.NotEqual(t,
+		dataChecksum(map[string]string{"k": "v"}, nil),
+		dataChecksum(nil, map[string][]byte{"k": []byte("v")}))
+
+	// The checksums are recorded on pod templates; a different one rolls the pods
+	assert.Equal(t, "98b44fdd16edd2f038d80bb0e7f554ba4a9137688aa4813be6bcd750231643ae", dataChecksum(
+		map[string]string{"POSTGRES_DB": "orders", "max_connections": "200"},
+		map[string][]byte{"ca.crt": []byte("certificate")}))
+}
+
+func TestDatabaseReconciler_DeploymentRollsOnSecretChange(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-db",
+			Namespace: "default",
+			UID:       "test-uid",
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-db-password",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{"password": []byte("first")},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database, secret).
+		Build()
+
+	reconciler := &DatabaseReconciler{
+		Client: fakeClient,
+		Scheme: scheme,
+	}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+
+	require.NoError(t, reconciler.reconcileDeployment(ctx, database))
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	first := deployment.Spec.Template.Annotations[secretChecksumAnnotation]
+	assert.NotEmpty(t, first)
+
+	// Reconciling again without changes keeps the pod template stable
+	require.NoError(t, reconciler.reconcileDeployment(ctx, database))
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	assert.Equal(t, first, deployment.Spec.Template.Annotations[secretChecksumAnnotation])
+
+	// Changing the password changes the pod template, which rolls the pods
+	secret.Data["password"] = []byte("second")
+	require.NoError(t, fakeClient.Update(ctx, secret))
+	require.NoError(t, reconciler.reconcileDeployment(ctx, database))
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	assert.NotEqual(t, first, deployment.Spec.Template.Annotations[secretChecksumAnnotation])
+}
+
+// BenchmarkDataChecksum measures the checksum of a mounted ConfigMap, taken on every reconcile.
+// Run with: go test -run '^$' -bench DataChecksum -benchmem ./controllers/
+func BenchmarkDataChecksum(b *testing.B) {
+	stringData := map[string]string{}
+	for i := 0; i < 20; i++ {
+		stringData[fmt.Sprintf("setting_%d", i)] = strings.Repeat("v", 64)
+	}
+	binaryData := map[string][]byte{"ca.crt": make([]byte, 2048)}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dataChecksum(stringData, binaryData)
+	}
+}
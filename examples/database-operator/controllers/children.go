@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/apiclient"
+)
+
+// childStep reconciles one child resource.
+// Reason is the Ready condition reason reported when the step fails; tag labels its API
+// requests in the client metrics.
+type childStep struct {
+	reason string
+	tag    string
+	run    func(ctx context.Context, database *databasev1.Database) error
+}
+
+// childStages groups the child steps by dependency. Steps within a stage are independent
+// and may run in parallel; a stage only starts once the previous one succeeded.
+// The Deployment comes after the Secrets and ConfigMap because its pod template references
+// them and carries checksums of their content; maintenance Jobs connect to the database it runs.
+func (r *DatabaseReconciler) childStages() [][]childStep {
+	return [][]childStep{
+		{
+			{reason: "PVCCreateFailed", tag: "pvc", run: r.reconcilePVC},
+			{reason: "SecretCreateFailed", tag: "secret", run: r.reconcileSecret},
+			{reason: "ConfigMapCreateFailed", tag: "configmap", run: r.reconcileConfigMap},
+			{reason: "ServiceCreateFailed", tag: "service", run: r.reconcileService},
+			{reason: "ImagePullSecretFailed", tag: "image-pull-secrets", run: r.reconcileImagePullSecrets},
+			{reason: "StandbySourceUnavailable", tag: "standby", run: r.reconcileStandby},
+			{reason: "DashboardFailed", tag: "dashboard", run: r.reconcileDashboard},
+		},
+		{
+			{reason: "DeploymentCreateFailed", tag: "deployment", run: r.reconcileWorkload},
+		},
+		{
+			{reason: "MaintenanceJobFailed", tag: "maintenance", run: r.reconcileMaintenance},
+		},
+	}
+}
+
+// reconcileChildren runs every child step and returns the reason of the first failed
+// step (in declaration order) together with the errors of all failed steps.
+// With ChildConcurrency above 1, steps of a stage run in parallel, at most ChildConcurrency at a time.
+func (r *DatabaseReconciler) reconcileChildren(ctx context.Context, database *databasev1.Database) (string, error) {
+	for _, stage := range r.childStages() {
+		if reason, err := r.runStage(ctx, database, stage); err != nil {
+			return reason, err
+		}
+	}
+	return "", nil
+}
+
+// runStage runs the steps of one stage, sequentially or with bounded parallelism
+func (r *DatabaseReconciler) runStage(ctx context.Context, database *databasev1.Database, steps []childStep) (string, error) {
+	errs := make([]error, len(steps))
+
+	if r.ChildConcurrency <= 1 {
+		// Sequential: stop at the first failure, like the steps always did
+		for i, step := range steps {
+			if errs[i] = step.run(apiclient.WithTag(ctx, step.tag), database); errs[i] != nil {
+				return step.reason, errs[i]
+			}
+		}
+		return "", nil
+	}
+
+	// Steps do not cancel each other: a failing Secret should not hide a failing Service.
+	// Each step records its own error, so the group never fails early.
+	var group errgroup.Group
+	group.SetLimit(r.ChildConcurrency)
+	for i, step := range steps {
+		i, step := i, step
+		group.Go(func() error {
+			errs[i] = step.run(apiclient.WithTag(ctx, step.tag), database)
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	reason := ""
+	var failed []error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if reason == "" {
+			reason = steps[i].reason
+		}
+		failed = append(failed, err)
+	}
+	return reason, kerrors.NewAggregate(failed)
+}
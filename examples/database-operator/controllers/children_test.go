@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func newChildTestReconciler(t testing.TB, concurrency int, funcs interceptor.Funcs) (*DatabaseReconciler, *databasev1.Database) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-db",
+			Namespace: "default",
+			UID:       "test-uid",
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas:      1,
+			Image:         "postgres:15",
+			Storage:       1024,
+			ConfigMapName: "test-db-config",
+			ServiceType:   "ClusterIP",
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithInterceptorFuncs(funcs).
+		Build()
+
+	return &DatabaseReconciler{
+		Client:           fakeClient,
+		Scheme:           scheme,
+		ChildConcurrency: concurrency,
+	}, database
+}
+
+func TestDatabaseReconciler_ReconcileChildrenInParallel(t *testing.T) {
+	reconciler, database := newChildTestReconciler(t, 4, interceptor.Funcs{})
+
+	ctx := context.Background()
+	reason, err := reconciler.reconcileChildren(ctx, database)
+	require.NoError(t, err)
+	assert.Empty(t, reason)
+
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+	assert.NoError(t, reconciler.Get(ctx, key, &corev1.PersistentVolumeClaim{}))
+	assert.NoError(t, reconciler.Get(ctx, key, &corev1.Service{}))
+	assert.NoError(t, reconciler.Get(ctx, key, &appsv1.Deployment{}))
+	assert.NoError(t, reconciler.Get(ctx, types.NamespacedName{Name: "test-db-password", Namespace: "default"}, &corev1.Secret{}))
+	assert.NoError(t, reconciler.Get(ctx, types.NamespacedName{Name: "test-db-config", Namespace: "default"}, &corev1.ConfigMap{}))
+}
+
+func TestDatabaseReconciler_ReconcileChildrenAggregatesErrors(t *testing.T) {
+	failCreate := interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			switch obj.(type) {
+			case *corev1.ConfigMap, *corev1.Service:
+				return fmt.Errorf("create %T denied", obj)
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	}
+
+	reconciler, database := newChildTestReconciler(t, 4, failCreate)
+
+	ctx := context.Background()
+	reason, err := reconciler.reconcileChildren(ctx, database)
+	require.Error(t, err)
+
+	assert.Equal(t, "ConfigMapCreateFailed", reason, "Reason should come from the first failed step in declaration order")
+	assert.Contains(t, err.Error(), "ConfigMap")
+	assert.Contains(t, err.Error(), "Service")
+
+	err = reconciler.Get(ctx, types.NamespacedName{Name: "test-db", Namespace: "default"}, &appsv1.Deployment{})
+	assert.Error(t, err, "Deployment should wait for its dependencies to succeed")
+}
+
+// BenchmarkReconcileChildren compares sequential and parallel child reconciliation
+// against an API server with 5ms latency per request.
+// Run with: go test -run '^$' -bench ReconcileChildren ./controllers/
+func BenchmarkReconcileChildren(b *testing.B) {
+	delay := func() { time.Sleep(5 * time.Millisecond) }
+	slow := interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			delay()
+			return c.Get(ctx, key, obj, opts...)
+		},
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			delay()
+			return c.Patch(ctx, obj, patch, opts...)
+		},
+	}
+
+	for _, concurrency := range []int{1, 4} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			reconciler, database := newChildTestReconciler(b, concurrency, slow)
+			ctx := context.Background()
+
+			// Create the children once so every iteration measures a steady-state reconcile
+			_, err := reconciler.reconcileChildren(ctx, database)
+			require.NoError(b, err)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := reconciler.reconcileChildren(ctx, database); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// classNameIndex indexes Databases by the DatabaseClass they name
+const classNameIndex = ".spec.className"
+
+// reasonClassUnavailable is the Ready reason of a Database whose class cannot be applied
+const reasonClassUnavailable = "ClassUnavailable"
+
+//+kubebuilder:rbac:groups=my.domain,resources=databaseclasses,verbs=get;list;watch
+
+// applyClass fills the fields a Database leaves unset from its class. Parameters are merged
+// by name, the Database winning. Fields the user set are never overwritten.
+func applyClass(class *databasev1.DatabaseClass, database *databasev1.Database) {
+	if database.Spec.Image == "" {
+		database.Spec.Image = class.Spec.Image
+	}
+	if database.Spec.Resources == nil && class.Spec.Resources != nil {
+		database.Spec.Resources = class.Spec.Resources.DeepCopy()
+	}
+	if database.Spec.StorageClass == "" {
+		database.Spec.StorageClass = class.Spec.StorageClass
+	}
+	if database.Spec.PriorityClassName == "" {
+		database.Spec.PriorityClassName = class.Spec.PriorityClassName
+	}
+	if len(class.Spec.Parameters) > 0 {
+		parameters := make(map[string]string, len(class.Spec.Parameters)+len(database.Spec.Parameters))
+		for name, value := range class.Spec.Parameters {
+			parameters[name] = value
+		}
+		for name, value := range database.Spec.Parameters {
+			parameters[name] = value
+		}
+		database.Spec.Parameters = parameters
+	}
+}
+
+// applyDatabaseClass applies the class of the in-memory Database. Unlike the operator
+// defaults, the class is never persisted into the spec, so an edit to the class reaches every
+// Database using it on its next reconcile. A missing class is retried rather than ignored:
+// reconciling without it would roll the pods to a spec nobody asked for.
+func (r *DatabaseReconciler) applyDatabaseClass(ctx context.Context, database *databasev1.Database) error {
+	name := database.Spec.ClassName
+	if name == "" {
+		return nil
+	}
+	class := &databasev1.DatabaseClass{}
+	if err := r.Get(ctx, client.ObjectKey{Name: name}, class); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("DatabaseClass %s not found", name)
+		}
+		return fmt.Errorf("failed to get DatabaseClass %s: %w", name, err)
+	}
+	applyClass(class, database)
+	if database.Spec.Image == "" {
+		return fmt.Errorf("neither the Database nor DatabaseClass %s sets an image", name)
+	}
+	return nil
+}
+
+// postgresArgs runs the server with the parameters as -c name=value, sorted so the pod
+// template only changes when a parameter does
+func postgresArgs(parameters map[string]string) []string {
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := []string{"postgres"}
+	for _, name := range names {
+		args = append(args, "-c", name+"="+parameters[name])
+	}
+	return args
+}
+
+// validateClass rejects a className that names no DatabaseClass, and a Database without an
+// image whose class sets none either
+func (v *DatabaseValidator) validateClass(ctx context.Context, database *databasev1.Database) (field.ErrorList, error) {
+	name := database.Spec.ClassName
+	if v.Reader == nil || name == "" {
+		return nil, nil
+	}
+	class := &databasev1.DatabaseClass{}
+	err := v.Reader.Get(ctx, client.ObjectKey{Name: name}, class)
+	if apierrors.IsNotFound(err) {
+		return field.ErrorList{field.NotFound(field.NewPath("spec", "className"), name)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DatabaseClass %s: %w", name, err)
+	}
+	if database.Spec.Image == "" && class.Spec.Image == "" {
+		return field.ErrorList{field.Required(field.NewPath("spec", "image"),
+			fmt.Sprintf("DatabaseClass %s sets no image", name))}, nil
+	}
+	return nil, nil
+}
+
+// indexClassName is the classNameIndex function
+func indexClassName(obj client.Object) []string {
+	database, ok := obj.(*databasev1.Database)
+	if !ok || database.Spec.ClassName == "" {
+		return nil
+	}
+	return []string{database.Spec.ClassName}
+}
+
+// findDatabasesForClass maps a DatabaseClass to the Databases in every namespace that use it
+func (r *DatabaseReconciler) findDatabasesForClass(ctx context.Context, o client.Object) []reconcile.Request {
+	var list databasev1.DatabaseList
+	if err := r.List(ctx, &list, client.MatchingFields{classNameIndex: o.GetName()}, client.UnsafeDisableDeepCopy); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list Databases", "class", o.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, item := range list.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: item.Name, Namespace: item.Namespace},
+		})
+	}
+	return requests
+}
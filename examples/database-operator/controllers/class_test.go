@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func newStandardClass() *databasev1.DatabaseClass {
+	return &databasev1.DatabaseClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "standard", Generation: 1},
+		Spec: databasev1.DatabaseClassSpec{
+			Image:        "postgres:15",
+			StorageClass: "standard-ssd",
+			Resources: &corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+			},
+			Parameters: map[string]string{"max_connections": "200", "shared_buffers": "256MB"},
+		},
+	}
+}
+
+func newClassFixture(t *testing.T, objects ...client.Object) (*DatabaseReconciler, client.Client) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(&databasev1.Database{}).
+		WithIndex(&databasev1.Database{}, classNameIndex, indexClassName).
+		Build()
+	return &DatabaseReconciler{Client: fakeClient, Scheme: scheme, Defaults: newDefaultsSource(fakeClient)}, fakeClient
+}
+
+func TestApplyClass(t *testing.T) {
+	database := &databasev1.Database{Spec: databasev1.DatabaseSpec{
+		ClassName:  "standard",
+		Image:      "postgres:16",
+		Parameters: map[string]string{"max_connections": "500", "work_mem": "64MB"},
+	}}
+	applyClass(newStandardClass(), database)
+
+	assert.Equal(t, "postgres:16", database.Spec.Image, "Fields the Database sets win")
+	assert.Equal(t, "standard-ssd", database.Spec.StorageClass)
+	require.NotNil(t, database.Spec.Resources)
+	assert.True(t, resource.MustParse("4Gi").Equal(database.Spec.Resources.Limits[corev1.ResourceMemory]))
+	assert.Equal(t, map[string]string{
+		"max_connections": "500",
+		"shared_buffers":  "256MB",
+		"work_mem":        "64MB",
+	}, database.Spec.Parameters, "Parameters are merged by name")
+}
+
+func TestDatabaseReconciler_AppliesDatabaseClass(t *testing.T) {
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default", Finalizers: []string{databaseFinalizer}},
+		Spec: databasev1.DatabaseSpec{
+			Replicas:   1,
+			Storage:    1024,
+			ClassName:  "standard",
+			Parameters: map[string]string{"max_connections": "500"},
+		},
+	}
+	// The class is more specific than the operator defaults, which only fill what is left
+	reconciler, fakeClient := newClassFixture(t, database, newStandardClass(), newDefaultsConfigMap())
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	container := deployment.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "registry.example.com/mirror/postgres:15", container.Image)
+	assert.Equal(t, []string{"postgres", "-c", "max_connections=500", "-c", "shared_buffers=256MB"}, container.Args)
+	assert.True(t, resource.MustParse("4Gi").Equal(container.Resources.Limits[corev1.ResourceMemory]))
+	assert.Equal(t, "database-critical", deployment.Spec.Template.Spec.PriorityClassName)
+	pvc := &corev1.PersistentVolumeClaim{}
+	require.NoError(t, fakeClient.Get(ctx, key, pvc))
+	assert.Equal(t, "standard-ssd", *pvc.Spec.StorageClassName)
+
+	stored := &databasev1.Database{}
+	require.NoError(t, fakeClient.Get(ctx, key, stored))
+	assert.Empty(t, stored.Spec.Image, "The class is applied in memory only")
+
+	// An edit to the class maps to the Database and reaches its pods
+	class := &databasev1.DatabaseClass{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: "standard"}, class))
+	class.Spec.Parameters["shared_buffers"] = "512MB"
+	require.NoError(t, fakeClient.Update(ctx, class))
+	assert.Equal(t, []reconcile.Request{{NamespacedName: key}}, reconciler.findDatabasesForClass(ctx, class))
+
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Args, "shared_buffers=512MB")
+}
+
+func TestDatabaseReconciler_MissingDatabaseClass(t *testing.T) {
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default", Finalizers: []string{databaseFinalizer}},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Storage: 1024, ClassName: "standard"},
+	}
+	reconciler, fakeClient := newClassFixture(t, database)
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	assert.EqualError(t, err, "DatabaseClass standard not found", "Retried until the class exists")
+
+	err = fakeClient.Get(ctx, key, &appsv1.Deployment{})
+	assert.True(t, apierrors.IsNotFound(err), "Nothing is rendered without the class")
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	ready := database.GetCondition("Ready")
+	require.NotNil(t, ready)
+	assert.Equal(t, reasonClassUnavailable, ready.Reason)
+
+	// A class without an image cannot stand in for the Database's
+	class := newStandardClass()
+	class.Spec.Image = ""
+	require.NoError(t, fakeClient.Create(ctx, class))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	assert.EqualError(t, err, "neither the Database nor DatabaseClass standard sets an image")
+}
+
+func TestDatabaseValidator_Class(t *testing.T) {
+	noImage := newStandardClass()
+	noImage.Name = "no-image"
+	noImage.Spec.Image = ""
+	_, fakeClient := newClassFixture(t, newStandardClass(), noImage)
+	validator := &DatabaseValidator{Reader: fakeClient}
+	ctx := context.Background()
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Storage: 1024, ClassName: "standard"},
+	}
+	_, err := validator.ValidateCreate(ctx, database)
+	assert.NoError(t, err)
+
+	for className, field := range map[string]string{"premium": "spec.className", "no-image": "spec.image"} {
+		invalid := database.DeepCopy()
+		invalid.Spec.ClassName = className
+		_, err = validator.ValidateCreate(ctx, invalid)
+		assert.True(t, apierrors.IsInvalid(err), className)
+		assert.Contains(t, err.Error(), field, className)
+	}
+
+	// Without a class the image is required, as before classes existed
+	withoutClass := database.DeepCopy()
+	withoutClass.Spec.ClassName = ""
+	_, err = validator.ValidateUpdate(ctx, database, withoutClass)
+	assert.True(t, apierrors.IsInvalid(err))
+	assert.Contains(t, err.Error(), "spec.image")
+}
@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// clusterPolicyOwnerIndex indexes NetworkPolicies by the name of the ClusterDatabasePolicy
+// that controls them
+const clusterPolicyOwnerIndex = ".metadata.controller.clusterDatabasePolicy"
+
+// ClusterDatabasePolicyReconciler fans a ClusterDatabasePolicy out into one NetworkPolicy per
+// selected namespace.
+//
+// Cluster scope changes a few things compared to the namespaced Database:
+//   - requests carry no namespace, and the children live in many namespaces
+//   - a cluster-scoped owner may own namespaced children, so the NetworkPolicies carry a
+//     controller reference and are garbage collected with the policy; but a namespace that
+//     stops matching the selector must be cleaned up explicitly, which needs an index of the
+//     children by owner across all namespaces
+//   - the set of children depends on namespaces, so namespace events are mapped back to the
+//     policies that select, or used to select, them
+//   - RBAC is necessarily cluster-wide: the policy, namespaces and the children in every
+//     namespace
+type ClusterDatabasePolicyReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Namespaces limits the fan-out to the namespaces the operator watches; the cache holds
+	// no NetworkPolicies elsewhere. Empty means all namespaces.
+	Namespaces []string
+
+	// Switches can turn the controller off, or into break-glass mode, at runtime; nil keeps
+	// it on, and only the break-glass annotation applies
+	Switches *Switches
+}
+
+//+kubebuilder:rbac:groups=my.domain,resources=clusterdatabasepolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=my.domain,resources=clusterdatabasepolicies/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile applies the policy to every selected namespace and removes it from the others
+func (r *ClusterDatabasePolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	policy := &databasev1.ClusterDatabasePolicy{}
+	if err := r.Get(ctx, req.NamespacedName, policy); err != nil {
+		// The garbage collector deletes the NetworkPolicies of a deleted policy
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !policy.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	// NetworkPolicies edited by hand during an incident are left alone
+	if r.Switches.InBreakGlass(policy) {
+		log.FromContext(ctx).Info("Break-glass is on, leaving the NetworkPolicies as they are")
+		return ctrl.Result{RequeueAfter: r.Switches.retryAfter()}, nil
+	}
+
+	namespaceSelector, clientSelector, err := clusterPolicySelectors(policy)
+	if err != nil {
+		// Retrying cannot fix an invalid selector; wait for the spec to change
+		return ctrl.Result{}, r.updatePolicyStatus(ctx, policy, nil, "InvalidSelector", err)
+	}
+
+	namespaces, err := listSelectedNamespaces(ctx, r.Client, namespaceSelector, r.Namespaces)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for _, namespace := range namespaces {
+		if err := r.reconcileNetworkPolicy(ctx, policy, namespace, clientSelector); err != nil {
+			// A namespace may start terminating between the list and the create
+			if !isNamespaceTerminatingError(err) {
+				return ctrl.Result{}, r.updatePolicyStatus(ctx, policy, nil, "ApplyFailed", err)
+			}
+		}
+	}
+
+	if err := r.pruneNetworkPolicies(ctx, policy, sets.New(namespaces...)); err != nil {
+		return ctrl.Result{}, r.updatePolicyStatus(ctx, policy, nil, "PruneFailed", err)
+	}
+
+	return ctrl.Result{}, r.updatePolicyStatus(ctx, policy, namespaces, "", nil)
+}
+
+// reconcileNetworkPolicy applies the policy's NetworkPolicy in one namespace
+func (r *ClusterDatabasePolicyReconciler) reconcileNetworkPolicy(ctx context.Context, policy *databasev1.ClusterDatabasePolicy, namespace string, clientSelector *metav1.LabelSelector) error {
+	networkPolicy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterPolicyChildName(policy), Namespace: namespace},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, networkPolicy, func() error {
+		if networkPolicy.Labels == nil {
+			networkPolicy.Labels = map[string]string{}
+		}
+		networkPolicy.Labels["app.kubernetes.io/managed-by"] = "database-operator"
+
+		port := intstr.FromInt(5432)
+		protocol := corev1.ProtocolTCP
+		networkPolicy.Spec = networkingv1.NetworkPolicySpec{
+			// Every database pod in the namespace, whichever Database runs it
+			PodSelector: metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      databaseNameLabel,
+					Operator: metav1.LabelSelectorOpExists,
+				}},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From:  []networkingv1.NetworkPolicyPeer{{PodSelector: clientSelector}},
+				Ports: []networkingv1.NetworkPolicyPort{{Protocol: &protocol, Port: &port}},
+			}},
+		}
+		// A cluster-scoped owner is valid for namespaced children; the reverse is not
+		return controllerutil.SetControllerReference(policy, networkPolicy, r.Scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply NetworkPolicy in namespace %s: %w", namespace, err)
+	}
+	return nil
+}
+
+// pruneNetworkPolicies deletes the policy's NetworkPolicies outside the selected namespaces.
+// Owner references only cover deletion of the policy itself, not namespaces that stop matching.
+func (r *ClusterDatabasePolicyReconciler) pruneNetworkPolicies(ctx context.Context, policy *databasev1.ClusterDatabasePolicy, selected sets.Set[string]) error {
+	var list networkingv1.NetworkPolicyList
+	if err := r.List(ctx, &list, client.MatchingFields{clusterPolicyOwnerIndex: policy.Name}); err != nil {
+		return fmt.Errorf("failed to list NetworkPolicies: %w", err)
+	}
+	for i := range list.Items {
+		networkPolicy := &list.Items[i]
+		if selected.Has(networkPolicy.Namespace) || !metav1.IsControlledBy(networkPolicy, policy) {
+			continue
+		}
+		if err := r.Delete(ctx, networkPolicy); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete NetworkPolicy in namespace %s: %w", networkPolicy.Namespace, err)
+		}
+		log.FromContext(ctx).Info("Removed policy from namespace", "namespace", networkPolicy.Namespace)
+	}
+	return nil
+}
+
+// updatePolicyStatus records the namespaces the policy is applied in, or the failure. The
+// namespaces of a failed reconcile are left as they were.
+func (r *ClusterDatabasePolicyReconciler) updatePolicyStatus(ctx context.Context, policy *databasev1.ClusterDatabasePolicy, namespaces []string, reason string, err error) error {
+	condition := metav1.Condition{
+		Type:               "Ready",
+		Status:             metav1.ConditionTrue,
+		Reason:             "Applied",
+		Message:            fmt.Sprintf("Applied in %d namespaces", len(namespaces)),
+		ObservedGeneration: policy.Generation,
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reason
+		condition.Message = err.Error()
+	} else {
+		policy.Status.Namespaces = namespaces
+	}
+	policy.Status.ObservedGeneration = policy.Generation
+	meta.SetStatusCondition(&policy.Status.Conditions, condition)
+
+	if updateErr := r.Status().Update(ctx, policy); updateErr != nil {
+		return updateErr
+	}
+	// An invalid selector is terminal; everything else is retried
+	if reason == "InvalidSelector" {
+		return nil
+	}
+	return err
+}
+
+// clusterPolicySelectors parses the namespace and client selectors of a policy
+func clusterPolicySelectors(policy *databasev1.ClusterDatabasePolicy) (labels.Selector, *metav1.LabelSelector, error) {
+	namespaceSelector := labels.Everything()
+	if policy.Spec.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(policy.Spec.NamespaceSelector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid namespaceSelector: %w", err)
+		}
+		namespaceSelector = selector
+	}
+
+	// An empty pod selector in a NetworkPolicy peer selects every pod in the namespace
+	clientSelector := &metav1.LabelSelector{}
+	if policy.Spec.ClientSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(policy.Spec.ClientSelector); err != nil {
+			return nil, nil, fmt.Errorf("invalid clientSelector: %w", err)
+		}
+		clientSelector = policy.Spec.ClientSelector.DeepCopy()
+	}
+	return namespaceSelector, clientSelector, nil
+}
+
+// indexClusterPolicyOwner is the clusterPolicyOwnerIndex function
+func indexClusterPolicyOwner(obj client.Object) []string {
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil || owner.Kind != "ClusterDatabasePolicy" || owner.APIVersion != databasev1.GroupVersion.String() {
+		return nil
+	}
+	return []string{owner.Name}
+}
+
+// findPoliciesForNamespace maps a namespace to the policies that select it now or are still
+// applied in it, so that new namespaces are covered and relabeled ones are cleaned up
+func (r *ClusterDatabasePolicyReconciler) findPoliciesForNamespace(ctx context.Context, o client.Object) []reconcile.Request {
+	var list databasev1.ClusterDatabasePolicyList
+	if err := r.List(ctx, &list); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list ClusterDatabasePolicies")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, policy := range list.Items {
+		selector, _, err := clusterPolicySelectors(&policy)
+		applied := sets.New(policy.Status.Namespaces...).Has(o.GetName())
+		if applied || err == nil && selector.Matches(labels.Set(o.GetLabels())) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: policy.Name},
+			})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager
+func (r *ClusterDatabasePolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &networkingv1.NetworkPolicy{},
+		clusterPolicyOwnerIndex, indexClusterPolicyOwner); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&databasev1.ClusterDatabasePolicy{}).
+		// Maps to the cluster-scoped owner; the request has no namespace
+		Owns(&networkingv1.NetworkPolicy{}).
+		// New namespaces and label changes change the set of children
+		Watches(
+			&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.findPoliciesForNamespace),
+			builder.WithPredicates(predicate.LabelChangedPredicate{}),
+		).
+		Complete(r.Switches.Controller(ClusterDatabasePolicyController, r))
+}
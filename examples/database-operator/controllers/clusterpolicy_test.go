@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func newClusterPolicyFixture(t *testing.T, objects ...client.Object) (*ClusterDatabasePolicyReconciler, client.Client) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, networkingv1.AddToScheme(scheme))
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(&databasev1.ClusterDatabasePolicy{}).
+		WithIndex(&networkingv1.NetworkPolicy{}, clusterPolicyOwnerIndex, indexClusterPolicyOwner).
+		Build()
+	return &ClusterDatabasePolicyReconciler{Client: fakeClient, Scheme: scheme}, fakeClient
+}
+
+func newNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestClusterDatabasePolicyReconciler_FansOut(t *testing.T) {
+	restricted := map[string]string{"databases.my.domain/policy": "restricted"}
+	terminating := newNamespace("team-c", restricted)
+	terminating.Status.Phase = corev1.NamespaceTerminating
+
+	policy := &databasev1.ClusterDatabasePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "database-access", Generation: 1},
+		Spec: databasev1.ClusterDatabasePolicySpec{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: restricted},
+			ClientSelector:    &metav1.LabelSelector{MatchLabels: map[string]string{"database.my.domain/client": "true"}},
+		},
+	}
+	reconciler, fakeClient := newClusterPolicyFixture(t, policy,
+		newNamespace("team-a", restricted), newNamespace("team-b", restricted), terminating, newNamespace("sandbox", nil))
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "database-access"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	require.NoError(t, fakeClient.Get(ctx, key, policy))
+	assert.Equal(t, []string{"team-a", "team-b"}, policy.Status.Namespaces)
+	assert.True(t, meta.IsStatusConditionTrue(policy.Status.Conditions, "Ready"))
+
+	networkPolicy := &networkingv1.NetworkPolicy{}
+	childKey := types.NamespacedName{Name: "database-policy-database-access", Namespace: "team-a"}
+	require.NoError(t, fakeClient.Get(ctx, childKey, networkPolicy))
+	assert.True(t, metav1.IsControlledBy(networkPolicy, policy), "A cluster-scoped owner may own namespaced children")
+	assert.Equal(t, policy.Spec.ClientSelector, networkPolicy.Spec.Ingress[0].From[0].PodSelector)
+
+	err = fakeClient.Get(ctx, types.NamespacedName{Name: childKey.Name, Namespace: "sandbox"}, &networkingv1.NetworkPolicy{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// A namespace that stops matching is cleaned up through the owner index
+	teamB := &corev1.Namespace{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "team-b"}, teamB))
+	assert.Equal(t, []reconcile.Request{{NamespacedName: key}}, reconciler.findPoliciesForNamespace(ctx, teamB))
+	teamB.Labels = nil
+	require.NoError(t, fakeClient.Update(ctx, teamB))
+	assert.Equal(t, []reconcile.Request{{NamespacedName: key}}, reconciler.findPoliciesForNamespace(ctx, teamB),
+		"The policy is still applied in the relabeled namespace")
+
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	err = fakeClient.Get(ctx, types.NamespacedName{Name: childKey.Name, Namespace: "team-b"}, &networkingv1.NetworkPolicy{})
+	assert.True(t, apierrors.IsNotFound(err))
+	require.NoError(t, fakeClient.Get(ctx, key, policy))
+	assert.Equal(t, []string{"team-a"}, policy.Status.Namespaces)
+}
+
+func TestClusterDatabasePolicyReconciler_WatchedNamespaces(t *testing.T) {
+	policy := &databasev1.ClusterDatabasePolicy{ObjectMeta: metav1.ObjectMeta{Name: "database-access"}}
+	reconciler, fakeClient := newClusterPolicyFixture(t, policy, newNamespace("team-a", nil), newNamespace("team-b", nil))
+	reconciler.Namespaces = []string{"team-b"}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "database-access"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	require.NoError(t, fakeClient.Get(ctx, key, policy))
+	assert.Equal(t, []string{"team-b"}, policy.Status.Namespaces, "Unwatched namespaces are not in the cache")
+}
+
+func TestClusterDatabasePolicyReconciler_InvalidSelector(t *testing.T) {
+	policy := &databasev1.ClusterDatabasePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "database-access"},
+		Spec: databasev1.ClusterDatabasePolicySpec{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: "Near"}},
+			},
+		},
+	}
+	reconciler, fakeClient := newClusterPolicyFixture(t, policy, newNamespace("team-a", nil))
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "database-access"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err, "Retrying cannot fix an invalid selector")
+
+	require.NoError(t, fakeClient.Get(ctx, key, policy))
+	ready := meta.FindStatusCondition(policy.Status.Conditions, "Ready")
+	require.NotNil(t, ready)
+	assert.Equal(t, "InvalidSelector", ready.Reason)
+	assert.Empty(t, reconciler.findPoliciesForNamespace(ctx, newNamespace("team-a", nil)))
+}
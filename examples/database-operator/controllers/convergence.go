@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// conditionConverged is True while a ready Database's desired state has not changed between
+// two reconciles. Converged Databases are resynced at RequeuePolicy.ConvergedInterval.
+const conditionConverged = "Converged"
+
+// desiredStateHash hashes everything the children are rendered from: the spec after
+// substitution, operator defaults and image resolution, and the labels and annotations that
+// may be propagated. Unlike the generation, it changes when the defaults ConfigMap, a
+// substituted value or a resolved digest changes.
+func desiredStateHash(database *databasev1.Database) string {
+	return hashOf(database.Spec, database.Labels, database.Annotations)
+}
+
+// observeConvergence records the desired state hash and sets the Converged condition. It must
+// run before the status update, which replaces the in-memory spec with the stored one.
+func observeConvergence(database *databasev1.Database, ready bool) {
+	hash := desiredStateHash(database)
+	unchanged := database.Status.DesiredStateHash == hash
+	database.Status.DesiredStateHash = hash
+
+	switch {
+	case !ready:
+		database.SetCondition(conditionConverged, metav1.ConditionFalse, "NotReady", "Database is not ready")
+	case !unchanged:
+		database.SetCondition(conditionConverged, metav1.ConditionFalse, "DesiredStateChanged",
+			"Desired state changed since the last reconcile")
+	default:
+		database.SetCondition(conditionConverged, metav1.ConditionTrue, "SteadyState",
+			"Database is ready and its desired state is unchanged")
+	}
+}
+
+// isConverged reports whether the Database was converged at its last status update
+func isConverged(database *databasev1.Database) bool {
+	condition := database.GetCondition(conditionConverged)
+	return condition != nil && condition.Status == metav1.ConditionTrue
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestObserveConvergence(t *testing.T) {
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+
+	// The first reconcile has nothing to compare with
+	observeConvergence(database, true)
+	assert.False(t, isConverged(database))
+	assert.Equal(t, "DesiredStateChanged", database.GetCondition(conditionConverged).Reason)
+	hash := database.Status.DesiredStateHash
+	assert.NotEmpty(t, hash)
+
+	observeConvergence(database, true)
+	assert.True(t, isConverged(database))
+
+	// Not ready is never converged, even with an unchanged desired state
+	observeConvergence(database, false)
+	assert.False(t, isConverged(database))
+	assert.Equal(t, "NotReady", database.GetCondition(conditionConverged).Reason)
+
+	// A propagated label is part of the desired state
+	database.Labels = map[string]string{"team": "payments"}
+	observeConvergence(database, true)
+	assert.False(t, isConverged(database))
+	assert.NotEqual(t, hash, database.Status.DesiredStateHash)
+}
+
+func TestDatabaseReconciler_StretchesResyncWhenConverged(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-db",
+			Namespace:  "default",
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database, &appsv1.Deployment{}).
+		Build()
+
+	policy := RequeuePolicy{ReadyInterval: 5 * time.Minute, ConvergedInterval: time.Hour}
+	reconciler := &DatabaseReconciler{
+		Client:        fakeClient,
+		Scheme:        scheme,
+		RequeuePolicy: policy,
+	}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+
+	// Mark the rendered Deployment ready, as the deployment controller would
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	deployment.Status.ReadyReplicas = 1
+	require.NoError(t, fakeClient.Status().Update(ctx, deployment))
+
+	// Ready, but the desired state was only recorded by the previous reconcile
+	result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.True(t, database.IsReady())
+	assert.True(t, isConverged(database), "The desired state is unchanged since the first reconcile")
+	assert.Equal(t, time.Hour, result.RequeueAfter)
+
+	// A spec change leaves the steady state until it has been applied
+	database.Spec.Replicas = 2
+	require.NoError(t, fakeClient.Update(ctx, database))
+	result, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.False(t, isConverged(database))
+	assert.Equal(t, DefaultRequeuePolicy.NotReadyInterval, result.RequeueAfter)
+}
+
+func TestDatabaseReconciler_ConvergedWritesNoStatus(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-db",
+			Namespace:  "default",
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+
+	statusWrites := 0
+	countStatusWrites := interceptor.Funcs{
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			if _, ok := obj.(*databasev1.Database); ok {
+				statusWrites++
+			}
+			return c.SubResource(subResource).Update(ctx, obj, opts...)
+		},
+	}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database, &appsv1.Deployment{}).
+		WithInterceptorFuncs(countStatusWrites).
+		Build()
+	reconciler := &DatabaseReconciler{
+		Client:        fakeClient,
+		Scheme:        scheme,
+		RequeuePolicy: RequeuePolicy{ReadyInterval: 5 * time.Minute, ConvergedInterval: time.Hour},
+	}
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	deployment.Status.ReadyReplicas = 1
+	require.NoError(t, fakeClient.Status().Update(ctx, deployment))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	require.True(t, isConverged(database))
+
+	// The periodic resync of a converged Database finds nothing to write
+	statusWrites = 0
+	resourceVersion := database.ResourceVersion
+	result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, result.RequeueAfter)
+	assert.Zero(t, statusWrites)
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.Equal(t, resourceVersion, database.ResourceVersion)
+}
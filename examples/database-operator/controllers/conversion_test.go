@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/yaml"
+
+	databasev1 "your.domain/project/api/v1"
+	databasev2 "your.domain/project/api/v2"
+)
+
+// TestDatabaseConversion_Envtest writes and reads Databases in both versions through a real
+// API server, which converts them with the webhook the operator serves. Writes in v2 are
+// defaulted and validated by the v1 admission webhooks, after conversion.
+// It needs the envtest binaries; run it with KUBEBUILDER_ASSETS set, e.g. via setup-envtest.
+func TestDatabaseConversion_Envtest(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, databasev2.AddToScheme(scheme))
+
+	// Served as with config/crd/patches/webhook_in_databases.yaml; envtest points the
+	// conversion webhook of convertible types at the local webhook server
+	content, err := os.ReadFile("../config/crd/bases/my.domain_databases.yaml")
+	require.NoError(t, err)
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	require.NoError(t, yaml.Unmarshal(content, crd))
+	for i := range crd.Spec.Versions {
+		crd.Spec.Versions[i].Served = true
+	}
+
+	env := &envtest.Environment{
+		CRDs:   []*apiextensionsv1.CustomResourceDefinition{crd},
+		Scheme: scheme,
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			Paths: []string{"../config/webhook/manifests.yaml"},
+		},
+	}
+	cfg, err := env.Start()
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, env.Stop()) })
+
+	webhookOptions := env.WebhookInstallOptions
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:  scheme,
+		Metrics: metricsserver.Options{BindAddress: "0"},
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Host:    webhookOptions.LocalServingHost,
+			Port:    webhookOptions.LocalServingPort,
+			CertDir: webhookOptions.LocalServingCertDir,
+		}),
+	})
+	require.NoError(t, err)
+	require.NoError(t, (&DatabaseDefaulter{Defaults: newDefaultsSource(mgr.GetAPIReader())}).SetupWebhookWithManager(mgr))
+	require.NoError(t, (&DatabaseValidator{Reader: mgr.GetAPIReader()}).SetupWebhookWithManager(mgr))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := mgr.Start(ctx); err != nil {
+			t.Errorf("manager stopped: %v", err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	require.Eventually(t, func() bool {
+		return mgr.GetWebhookServer().StartedChecker()(nil) == nil
+	}, 10*time.Second, 100*time.Millisecond)
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	require.NoError(t, err)
+
+	// Written in v2, stored in v1
+	orders := &databasev2.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "default"},
+		Spec: databasev2.DatabaseSpec{
+			Instances: 2,
+			Image:     "postgres:15",
+			Storage:   databasev2.StorageSpec{Size: resource.MustParse("1500M"), StorageClassName: "fast"},
+			Bootstrap: &databasev2.BootstrapSpec{Database: "orders", Owner: "app"},
+		},
+	}
+	require.NoError(t, c.Create(ctx, orders))
+	stored := &databasev1.Database{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(orders), stored))
+	assert.Equal(t, int32(2), stored.Spec.Replicas)
+	assert.Equal(t, int32(1431), stored.Spec.Storage)
+	assert.Equal(t, "fast", stored.Spec.StorageClass)
+	assert.Equal(t, "orders", stored.Spec.DatabaseName)
+	assert.Equal(t, "app", stored.Spec.UserName)
+
+	current := &databasev2.Database{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(orders), current))
+	assert.Equal(t, "1500M", current.Spec.Storage.Size.String(), "The size is read back as written")
+	assert.Empty(t, current.Annotations)
+
+	// Written in v1 with the deprecated serviceType, updated in v2
+	legacy := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "default"},
+		Spec: databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024,
+			ServiceType: corev1.ServiceTypeNodePort},
+	}
+	require.NoError(t, c.Create(ctx, legacy))
+	upgraded := &databasev2.Database{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(legacy), upgraded))
+	assert.Equal(t, "1Gi", upgraded.Spec.Storage.Size.String())
+	assert.Equal(t, corev1.ServiceTypeNodePort, upgraded.Spec.Service.Type)
+	upgraded.Spec.Instances = 3
+	require.NoError(t, c.Update(ctx, upgraded))
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(legacy), legacy))
+	assert.Equal(t, int32(3), legacy.Spec.Replicas)
+	assert.Equal(t, corev1.ServiceTypeNodePort, legacy.Spec.ServiceType, "v1 clients still find the field they wrote")
+	assert.Nil(t, legacy.Spec.Service)
+
+	// Lists convert every item
+	var databases databasev2.DatabaseList
+	require.NoError(t, c.List(ctx, &databases, client.InNamespace("default")))
+	assert.Len(t, databases.Items, 2)
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/secretstore"
+)
+
+// passwordReferenceKey is the key of a password Secret pointing at a password kept in an
+// external secret manager, in place of the password key
+const passwordReferenceKey = "passwordRef"
+
+// passwordVolume mounts an external password into pods
+const (
+	passwordVolume    = "password"
+	passwordMountPath = "/var/run/secrets/database"
+	secretsStoreCSI   = "secrets-store.csi.k8s.io"
+)
+
+// ExternalPasswords keeps the generated passwords of Databases in an external secret manager.
+// The password Secret of a Database then holds only a reference to the password, so the
+// password never reaches etcd; passwords already stored in Secrets are moved to the manager.
+//
+// Pods read the password through the Secrets Store CSI driver: every Database namespace needs
+// a SecretProviderClass named like the password Secret that mounts the referenced password
+// as the file "password". The operator resolves the reference itself where it connects.
+type ExternalPasswords struct {
+	Manager secretstore.Manager
+}
+
+// enabled reports whether passwords are kept outside of Secrets
+func (p *ExternalPasswords) enabled() bool {
+	return p != nil && p.Manager != nil
+}
+
+// externalPasswordName is the name of the password of a Database in the secret manager
+func externalPasswordName(database *databasev1.Database) string {
+	return database.Namespace + "/" + passwordSecretName(database)
+}
+
+// store moves a password into the secret manager and leaves a reference in the Secret data
+func (p *ExternalPasswords) store(ctx context.Context, database *databasev1.Database, data map[string][]byte, password []byte) error {
+	name := externalPasswordName(database)
+	version, err := p.Manager.Put(ctx, name, password)
+	if err != nil {
+		return fmt.Errorf("failed to store password in secret manager: %w", err)
+	}
+	delete(data, "password")
+	data[passwordReferenceKey] = []byte(secretstore.Reference{Name: name, Version: version}.String())
+	return nil
+}
+
+// password returns the password held by a password Secret, resolving a reference through the
+// secret manager
+func (p *ExternalPasswords) password(ctx context.Context, secret *corev1.Secret) ([]byte, error) {
+	ref, ok := secret.Data[passwordReferenceKey]
+	if !ok {
+		return secret.Data["password"], nil
+	}
+	if !p.enabled() {
+		return nil, fmt.Errorf("password Secret %s references an external password but no secret manager is configured", secret.Name)
+	}
+	reference, err := secretstore.ParseReference(string(ref))
+	if err != nil {
+		return nil, err
+	}
+	return secretstore.Resolve(ctx, p.Manager, reference)
+}
+
+// delete removes the password of a deleted Database from the secret manager
+func (p *ExternalPasswords) delete(ctx context.Context, reader client.Reader, database *databasev1.Database) error {
+	if !p.enabled() {
+		return nil
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: database.Namespace, Name: passwordSecretName(database)}
+	if err := reader.Get(ctx, key, secret); client.IgnoreNotFound(err) != nil {
+		return err
+	} else if err == nil {
+		if _, ok := secret.Data[passwordReferenceKey]; !ok {
+			return nil
+		}
+	}
+	if err := p.Manager.Delete(ctx, externalPasswordName(database)); err != nil {
+		return fmt.Errorf("failed to delete password from secret manager: %w", err)
+	}
+	return nil
+}
+
+// mountPassword replaces the password Secret references of the containers in a pod by the
+// password file mounted from the secret manager. The postgres image reads the file named by
+// POSTGRES_PASSWORD_FILE; the client tools only read PGPASSWORD, so their command exports it
+// from the file first.
+func (p *ExternalPasswords) mountPassword(database *databasev1.Database, podSpec *corev1.PodSpec) {
+	if !p.enabled() {
+		return
+	}
+	file := passwordMountPath + "/password"
+	mounted := false
+	for _, container := range podContainers(podSpec) {
+		env := container.Env[:0]
+		found := false
+		for _, e := range container.Env {
+			if !refersToPassword(database, e) {
+				env = append(env, e)
+				continue
+			}
+			found = true
+			if e.Name == "POSTGRES_PASSWORD" {
+				env = append(env, corev1.EnvVar{Name: "POSTGRES_PASSWORD_FILE", Value: file})
+				continue
+			}
+			container.Command = append([]string{"sh", "-c", e.Name + `="$(cat ` + file + `)" exec "$@"`, "--"}, container.Command...)
+		}
+		if !found {
+			continue
+		}
+		container.Env = env
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: passwordVolume, MountPath: passwordMountPath, ReadOnly: true})
+		mounted = true
+	}
+	if !mounted {
+		return
+	}
+	readOnly := true
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: passwordVolume,
+		VolumeSource: corev1.VolumeSource{
+			CSI: &corev1.CSIVolumeSource{
+				Driver:           secretsStoreCSI,
+				ReadOnly:         &readOnly,
+				VolumeAttributes: map[string]string{"secretProviderClass": passwordSecretName(database)},
+			},
+		},
+	})
+}
+
+// podContainers returns the init containers and the containers of a pod, such as the dump of
+// a Backup that reads the password before its upload runs
+func podContainers(podSpec *corev1.PodSpec) []*corev1.Container {
+	containers := make([]*corev1.Container, 0, len(podSpec.InitContainers)+len(podSpec.Containers))
+	for i := range podSpec.InitContainers {
+		containers = append(containers, &podSpec.InitContainers[i])
+	}
+	for i := range podSpec.Containers {
+		containers = append(containers, &podSpec.Containers[i])
+	}
+	return containers
+}
+
+// refersToPassword reports whether an environment variable reads the password Secret
+func refersToPassword(database *databasev1.Database, e corev1.EnvVar) bool {
+	if e.ValueFrom == nil || e.ValueFrom.SecretKeyRef == nil {
+		return false
+	}
+	ref := e.ValueFrom.SecretKeyRef
+	return ref.Name == passwordSecretName(database) && ref.Key == "password"
+}
+
+// externalizePassword moves the password of a password Secret into the secret manager. A
+// Secret that references a password can only be reconciled while a manager is configured.
+func (r *DatabaseReconciler) externalizePassword(ctx context.Context, database *databasev1.Database, secret *corev1.Secret) error {
+	password, stored := secret.Data["password"]
+	if !r.Passwords.enabled() {
+		if _, ok := secret.Data[passwordReferenceKey]; ok && !stored {
+			return fmt.Errorf("password Secret %s references an external password but no secret manager is configured", secret.Name)
+		}
+		return nil
+	}
+	if !stored {
+		return nil
+	}
+	return r.Passwords.store(ctx, database, secret.Data, password)
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/secretstore"
+)
+
+func TestDatabaseReconciler_ExternalPasswords(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "orders",
+			Namespace:  "default",
+			Generation: 1,
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024, UserName: "app"},
+	}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database).
+		Build()
+	manager := secretstore.NewMemory()
+	reconciler := &DatabaseReconciler{
+		Client:    fakeClient,
+		Scheme:    scheme,
+		Recorder:  events.NewFakeRecorder(10),
+		Passwords: &ExternalPasswords{Manager: manager},
+	}
+	ctx := context.Background()
+	key := client.ObjectKeyFromObject(database)
+	secretKey := client.ObjectKey{Namespace: "default", Name: passwordSecretName(database)}
+
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	// The Secret only holds the reference
+	secret := &corev1.Secret{}
+	require.NoError(t, fakeClient.Get(ctx, secretKey, secret))
+	assert.NotContains(t, secret.Data, "password")
+	assert.Equal(t, "default/"+secretKey.Name+"#1", string(secret.Data[passwordReferenceKey]))
+	password, err := reconciler.Passwords.password(ctx, secret)
+	require.NoError(t, err)
+	assert.Len(t, password, 32)
+
+	// The pods mount it from the secret manager
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Namespace: "default", Name: deploymentName(database)}, deployment))
+	podSpec := deployment.Spec.Template.Spec
+	assert.Contains(t, podSpec.Containers[0].Env, corev1.EnvVar{Name: "POSTGRES_PASSWORD_FILE", Value: "/var/run/secrets/database/password"})
+	for _, env := range podSpec.Containers[0].Env {
+		assert.NotEqual(t, "POSTGRES_PASSWORD", env.Name)
+	}
+	volume := podSpec.Volumes[len(podSpec.Volumes)-1]
+	require.NotNil(t, volume.CSI)
+	assert.Equal(t, "secrets-store.csi.k8s.io", volume.CSI.Driver)
+	assert.Equal(t, secretKey.Name, volume.CSI.VolumeAttributes["secretProviderClass"])
+
+	// The stats collector resolves the reference to connect
+	collector := NewStatsCollector()
+	collector.Client = fakeClient
+	collector.Passwords = reconciler.Passwords
+	dsn, err := collector.connectionURL(ctx, database)
+	require.NoError(t, err)
+	assert.Contains(t, dsn, "app:"+string(password)+"@")
+
+	// Without a secret manager the reference cannot be used
+	reconciler.Passwords = nil
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	assert.ErrorContains(t, err, "no secret manager is configured")
+	reconciler.Passwords = &ExternalPasswords{Manager: manager}
+
+	// The password goes with the Database
+	require.NoError(t, fakeClient.Delete(ctx, database))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, 0, manager.Len())
+}
+
+func TestDatabaseReconciler_ExternalPasswordsInTerminatingNamespace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	now := metav1.Now()
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "orders",
+			Namespace:         "teardown",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "teardown"},
+		Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+	}
+	manager := secretstore.NewMemory()
+	ctx := context.Background()
+	_, err := manager.Put(ctx, externalPasswordName(database), []byte("s3cret"))
+	require.NoError(t, err)
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(namespace, database).Build()
+	reconciler := &DatabaseReconciler{
+		Client:    fakeClient,
+		Scheme:    scheme,
+		Recorder:  events.NewFakeRecorder(10),
+		Passwords: &ExternalPasswords{Manager: manager},
+	}
+
+	// The password Secret may already be gone; the password still goes with the Database
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(database)})
+	require.NoError(t, err)
+	assert.Equal(t, 0, manager.Len())
+}
+
+func TestDatabaseReconciler_ExternalizePassword(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	database := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "default"}}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: passwordSecretName(database), Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("s3cret"), "username": []byte("app")},
+	}
+	manager := secretstore.NewMemory()
+	reconciler := &DatabaseReconciler{
+		Client:    fake.NewClientBuilder().WithScheme(scheme).WithObjects(database, secret).Build(),
+		Scheme:    scheme,
+		Passwords: &ExternalPasswords{Manager: manager},
+	}
+	ctx := context.Background()
+
+	// A password stored before the secret manager was configured is moved to it
+	require.NoError(t, reconciler.reconcileSecret(ctx, database))
+	require.NoError(t, reconciler.Get(ctx, client.ObjectKeyFromObject(secret), secret))
+	assert.Equal(t, map[string][]byte{"username": []byte("app"), passwordReferenceKey: []byte("default/orders-password#1")}, secret.Data)
+	password, err := manager.Get(ctx, "default/orders-password", "1")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", string(password), "The Database keeps its password")
+
+	require.NoError(t, reconciler.reconcileSecret(ctx, database))
+	_, err = manager.Get(ctx, "default/orders-password", "2")
+	assert.Error(t, err, "A referenced password is not stored again")
+}
+
+func TestExternalPasswords_MountPassword(t *testing.T) {
+	database := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "default"}}
+	podSpec := corev1.PodSpec{Containers: []corev1.Container{{
+		Name:    "maintenance",
+		Command: []string{"vacuumdb", "--all"},
+		Env: []corev1.EnvVar{
+			{Name: "PGHOST", Value: "orders"},
+			{Name: "PGPASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: passwordSecretName(database)},
+				Key:                  "password",
+			}}},
+		},
+	}}}
+
+	var disabled *ExternalPasswords
+	unchanged := *podSpec.DeepCopy()
+	disabled.mountPassword(database, &podSpec)
+	assert.Equal(t, unchanged, podSpec)
+
+	(&ExternalPasswords{Manager: secretstore.NewMemory()}).mountPassword(database, &podSpec)
+	container := podSpec.Containers[0]
+	assert.Equal(t, []corev1.EnvVar{{Name: "PGHOST", Value: "orders"}}, container.Env)
+	assert.Equal(t, []string{"sh", "-c", `PGPASSWORD="$(cat /var/run/secrets/database/password)" exec "$@"`, "--", "vacuumdb", "--all"}, container.Command)
+	assert.Equal(t, []corev1.VolumeMount{{Name: "password", MountPath: "/var/run/secrets/database", ReadOnly: true}}, container.VolumeMounts)
+	require.Len(t, podSpec.Volumes, 1)
+}
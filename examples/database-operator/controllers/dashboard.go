@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/naming"
+)
+
+// DashboardPolicy configures the Grafana dashboards provisioned for Databases with monitoring
+// enabled. The dashboards are ConfigMaps in the Database namespace, owned by the Database, that
+// the Grafana dashboard sidecar picks up by label; the sidecar must search every namespace
+// the operator manages Databases in.
+type DashboardPolicy struct {
+	// Label is the key=value label the sidecar selects dashboard ConfigMaps by
+	Label string
+}
+
+// DefaultDashboardPolicy uses the label of the Grafana Helm chart's sidecar
+var DefaultDashboardPolicy = DashboardPolicy{Label: "grafana_dashboard=1"}
+
+// BindFlags registers flags for the policy, using the current values as defaults
+func (p *DashboardPolicy) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&p.Label, "dashboard-label", p.Label,
+		"The key=value label the Grafana sidecar selects dashboard ConfigMaps by.")
+}
+
+// label splits the policy label, falling back to the default when it is unset or malformed
+func (p DashboardPolicy) label() (string, string) {
+	if key, value, ok := strings.Cut(p.Label, "="); ok && key != "" {
+		return key, value
+	}
+	key, value, _ := strings.Cut(DefaultDashboardPolicy.Label, "=")
+	return key, value
+}
+
+// dashboardName is the name of the dashboard ConfigMap of a Database
+func dashboardName(database *databasev1.Database) string {
+	return naming.Subdomain(database.Name, "dashboard")
+}
+
+// monitoringEnabled reports whether a Database asks for a dashboard
+func monitoringEnabled(database *databasev1.Database) bool {
+	return database.Spec.Monitoring != nil && database.Spec.Monitoring.Enabled
+}
+
+// reconcileDashboard keeps the dashboard ConfigMap in sync with the Database. Disabling
+// monitoring leaves it to pruneChildren, and deleting the Database to the garbage collector.
+func (r *DatabaseReconciler) reconcileDashboard(ctx context.Context, database *databasev1.Database) error {
+	if !monitoringEnabled(database) {
+		return nil
+	}
+
+	dashboard, err := renderDashboard(database)
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dashboardName(database),
+			Namespace: database.Namespace,
+		},
+	}
+	_, err = r.createOrPatch(ctx, cm, func() error {
+		// The sidecar writes each key to a file in one directory, so keys are unique per Database
+		cm.Data = map[string]string{database.Namespace + "-" + database.Name + ".json": dashboard}
+		r.Propagation.apply(database, cm)
+		setDatabaseLabel(cm, database)
+		key, value := r.Dashboards.label()
+		cm.Labels[key] = value
+		return controllerutil.SetControllerReference(database, cm, r.Scheme)
+	})
+	return err
+}
+
+// dashboardPanel is a Grafana time series panel
+type dashboardPanel struct {
+	Title   string
+	Unit    string
+	Queries []dashboardQuery
+}
+
+// dashboardQuery is a PromQL query with its legend format
+type dashboardQuery struct {
+	Expr   string
+	Legend string
+}
+
+// renderDashboard renders the dashboard JSON of a Database from the metrics the operator
+// exports for it. The data source is a dashboard variable, so the dashboard works with any
+// Prometheus data source.
+func renderDashboard(database *databasev1.Database) (string, error) {
+	selector := fmt.Sprintf(`namespace=%q,name=%q`, database.Namespace, database.Name)
+	panels := []dashboardPanel{
+		{
+			Title:   "Connections by state",
+			Unit:    "short",
+			Queries: []dashboardQuery{{Expr: "sum by (state) (database_connections{" + selector + "})", Legend: "{{state}}"}},
+		},
+		{
+			Title: "Connection saturation",
+			Unit:  "percentunit",
+			Queries: []dashboardQuery{{
+				Expr:   "sum(database_connections{" + selector + "}) / max(database_max_connections{" + selector + "})",
+				Legend: "used",
+			}},
+		},
+		{
+			Title:   "Longest transaction",
+			Unit:    "s",
+			Queries: []dashboardQuery{{Expr: "database_longest_transaction_seconds{" + selector + "}", Legend: "oldest open transaction"}},
+		},
+		{
+			Title:   "Slowest statements (mean execution time)",
+			Unit:    "s",
+			Queries: []dashboardQuery{{Expr: "database_slow_query_mean_seconds{" + selector + "}", Legend: "{{queryid}}"}},
+		},
+		{
+			Title:   "Statistics collection errors",
+			Unit:    "short",
+			Queries: []dashboardQuery{{Expr: "increase(database_stats_collection_errors_total{" + selector + "}[$__rate_interval])", Legend: "errors"}},
+		},
+	}
+
+	datasource := map[string]interface{}{"type": "prometheus", "uid": "${datasource}"}
+	var rendered []interface{}
+	for i, panel := range panels {
+		var targets []interface{}
+		for j, query := range panel.Queries {
+			targets = append(targets, map[string]interface{}{
+				"datasource":   datasource,
+				"expr":         query.Expr,
+				"legendFormat": query.Legend,
+				"refId":        string(rune('A' + j)),
+			})
+		}
+		rendered = append(rendered, map[string]interface{}{
+			"id":         i + 1,
+			"type":       "timeseries",
+			"title":      panel.Title,
+			"datasource": datasource,
+			"gridPos":    map[string]int{"x": (i % 2) * 12, "y": (i / 2) * 8, "w": 12, "h": 8},
+			"fieldConfig": map[string]interface{}{
+				"defaults":  map[string]interface{}{"unit": panel.Unit},
+				"overrides": []interface{}{},
+			},
+			"targets": targets,
+		})
+	}
+
+	key := sha256.Sum256([]byte(database.Namespace + "/" + database.Name))
+	dashboard := map[string]interface{}{
+		// Stable across re-renders, so Grafana updates the dashboard instead of adding one
+		"uid":           "database-" + hex.EncodeToString(key[:8]),
+		"title":         fmt.Sprintf("Database %s/%s", database.Namespace, database.Name),
+		"tags":          []string{"database-operator"},
+		"editable":      false,
+		"schemaVersion": 39,
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"refresh":       "1m",
+		"templating": map[string]interface{}{
+			"list": []interface{}{map[string]interface{}{
+				"name":  "datasource",
+				"label": "Data source",
+				"type":  "datasource",
+				"query": "prometheus",
+			}},
+		},
+		"panels": rendered,
+	}
+	data, err := json.MarshalIndent(dashboard, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to render dashboard: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestDatabaseReconciler_ReconcileDashboard(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop", UID: "orders-uid"},
+		Spec: databasev1.DatabaseSpec{
+			Replicas:   1,
+			Image:      "postgres:15",
+			Storage:    1024,
+			Monitoring: &databasev1.MonitoringSpec{Enabled: true},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(database).Build()
+	reconciler := &DatabaseReconciler{
+		Client:     fakeClient,
+		Scheme:     scheme,
+		Dashboards: DashboardPolicy{Label: "team.example.com/dashboard=database"},
+	}
+	ctx := context.Background()
+	require.NoError(t, reconciler.reconcileDashboard(ctx, database))
+
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Namespace: "shop", Name: "orders-dashboard"}, cm))
+	assert.Equal(t, "database", cm.Labels["team.example.com/dashboard"])
+	assert.True(t, metav1.IsControlledBy(cm, database), "The dashboard is garbage collected with the Database")
+	require.Contains(t, cm.Data, "shop-orders.json")
+
+	var dashboard struct {
+		UID    string `json:"uid"`
+		Title  string `json:"title"`
+		Panels []struct {
+			Targets []struct {
+				Expr string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(cm.Data["shop-orders.json"]), &dashboard))
+	assert.Equal(t, "Database shop/orders", dashboard.Title)
+	assert.LessOrEqual(t, len(dashboard.UID), 40, "Grafana limits dashboard UIDs to 40 characters")
+	require.NotEmpty(t, dashboard.Panels)
+	assert.Equal(t, `sum by (state) (database_connections{namespace="shop",name="orders"})`, dashboard.Panels[0].Targets[0].Expr)
+
+	// Edits are reverted
+	cm.Data["shop-orders.json"] = "{}"
+	require.NoError(t, fakeClient.Update(ctx, cm))
+	require.NoError(t, reconciler.reconcileDashboard(ctx, database))
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(cm), cm))
+	assert.Contains(t, cm.Data["shop-orders.json"], dashboard.UID)
+
+	// Disabling monitoring leaves the ConfigMap to pruneChildren
+	desired := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "orders-dashboard", Namespace: "shop"}}
+	assert.Contains(t, desiredChildren(database), desired)
+	database.Spec.Monitoring.Enabled = false
+	assert.NotContains(t, desiredChildren(database), desired)
+}
+
+func TestDashboardPolicy_Label(t *testing.T) {
+	key, value := DashboardPolicy{}.label()
+	assert.Equal(t, "grafana_dashboard", key)
+	assert.Equal(t, "1", value)
+
+	key, value = DashboardPolicy{Label: "dashboards=postgres"}.label()
+	assert.Equal(t, "dashboards", key)
+	assert.Equal(t, "postgres", value)
+}
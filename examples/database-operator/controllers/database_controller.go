@@ -5,29 +5,39 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"strconv"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/finalizer"
+	"your.domain/project/plugins"
+	"your.domain/project/validation"
 )
 
 const databaseFinalizer = "database.my.domain/finalizer"
@@ -36,16 +46,78 @@ const databaseFinalizer = "database.my.domain/finalizer"
 type DatabaseReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// RequeuePolicy controls periodic resyncs; zero values fall back to DefaultRequeuePolicy
+	RequeuePolicy RequeuePolicy
+
+	// ChildConcurrency is how many independent child resources are reconciled in parallel.
+	// Zero or one reconciles them one after another.
+	ChildConcurrency int
+
+	// Defaults holds cluster-wide defaults for fields a Database leaves unset; nil disables them
+	Defaults *DefaultsSource
+
+	// Recorder reports failed reconciles and stalled rollouts as events; nil disables them
+	Recorder events.EventRecorder
+
+	// Propagation selects the Database labels and annotations copied to its children
+	Propagation PropagationPolicy
+
+	// HistoryLimit is how many reconcile outcomes are kept in status.history; zero disables it
+	HistoryLimit int
+
+	// DegradedAfter is how long a Database may serve with only part of its replicas ready
+	// before it is reported Degraded; zero disables the Degraded condition
+	DegradedAfter time.Duration
+
+	// HotLoops warns about Databases reconciled over and over; nil disables it
+	HotLoops *HotLoopDetector
+
+	// Dashboards configures the Grafana dashboards of Databases with monitoring enabled
+	Dashboards DashboardPolicy
+
+	// ResourceUsage adds the live usage reported by metrics-server to status.resources
+	ResourceUsage bool
+
+	// ExternalEvents requests reconciles from outside the cluster, e.g. HookReceiver.Events;
+	// nil disables them
+	ExternalEvents <-chan event.GenericEvent
+
+	// Switches can turn the controller off, or into break-glass mode, at runtime; nil keeps
+	// it on, and only the break-glass annotation applies
+	Switches *Switches
+
+	// ApplyPolicies are checked against every child before it is written; nil disables them
+	ApplyPolicies *ApplyPolicySource
+
+	// Plugins are site-specific hooks called before provisioning, once ready and before
+	// deletion; nil or empty calls none
+	Plugins *plugins.Registry
+
+	// Passwords keeps generated passwords in an external secret manager; nil keeps them in
+	// the password Secret
+	Passwords *ExternalPasswords
+
+	// APIReader reads a created child from the API server when the cache is slow to show it;
+	// nil only waits for the cache
+	APIReader client.Reader
+
+	// schemaSkew tracks the Databases written by a newer operator
+	schemaSkew schemaSkew
 }
 
 //+kubebuilder:rbac:groups=my.domain,resources=databases,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=my.domain,resources=databases/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=my.domain,resources=databases/finalizers,verbs=update
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
 //+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is the main reconciliation loop
 func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -56,98 +128,200 @@ func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	err := r.Get(ctx, req.NamespacedName, database)
 	if err != nil {
 		if errors.IsNotFound(err) {
+			r.HotLoops.forget(req.NamespacedName)
+			r.schemaSkew.forget(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{}, err
 	}
 
-	// Handle deletion
-	if !database.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(ctx, database)
+	// Not even deletion is handled for a Database a newer operator wrote
+	newer, err := r.checkSchemaRevision(ctx, database)
+	if err != nil || newer {
+		return ctrl.Result{}, err
 	}
 
-	// Add finalizer if not present
-	if !controllerutil.ContainsFinalizer(database, databaseFinalizer) {
-		controllerutil.AddFinalizer(database, databaseFinalizer)
-		if err := r.Update(ctx, database); err != nil {
+	// On-call engineers may be editing the children by hand; report, but change nothing
+	if reason := r.breakGlassReason(database); reason != "" {
+		return r.observeBreakGlass(ctx, database, reason)
+	}
+	if endBreakGlass(database) {
+		if err := r.Status().Update(ctx, database); err != nil {
 			return ctrl.Result{}, err
 		}
+	}
+
+	// A reconcile that leaves the status as it found it does not write it
+	observed := database.Status.DeepCopy()
+
+	// Add the finalizer, or finalize a deleted Database and release it.
+	// A new Database is requeued once its finalizer is stored, before
+	// anything is provisioned for it.
+	added := !controllerutil.ContainsFinalizer(database, databaseFinalizer)
+	if result, done, err := finalizer.ReconcileWithFinalizer(ctx, r.Client, database, databaseFinalizer, r.finalize); done {
+		return result, err
+	}
+	if added {
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	// Children cannot be created in a terminating namespace; the Database is
+	// deleted along with the namespace and then handled by finalize
+	terminating, err := namespaceTerminating(ctx, r.Client, database.Namespace)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if terminating {
+		logger.Info("Namespace is terminating, skipping reconciliation", "namespace", database.Namespace)
+		return ctrl.Result{}, nil
+	}
+
+	// A permanently failed Database is not retried until its spec changes
+	if stalled := database.GetCondition(conditionStalled); stalled != nil && stalled.Status == metav1.ConditionTrue {
+		if isStalled(database) {
+			logger.Info("Database is stalled, waiting for a spec change", "reason", stalled.Reason)
+			return ctrl.Result{}, nil
+		}
+		database.SetCondition(conditionStalled, metav1.ConditionFalse, "SpecChanged", "Spec changed since the failure, retrying")
+	}
+
 	// Reconcile the database
 	logger.Info("Reconciling Database", "name", database.Name, "replicas", database.Spec.Replicas)
+	ctx = startReconcileAttempt(ctx, database)
+	r.detectHotLoop(database)
+
+	// Move deprecated fields to their replacements, fill unset fields from the DatabaseClass,
+	// expand ${VAR} references, fill the fields still unset from the operator defaults and
+	// resolve images for registry mirrors and pinned digests. Only the in-memory copy is
+	// changed, and status writes return the stored spec, so no status may be written
+	// between here and the children.
+	r.migrateDeprecatedFields(database)
+	if err := r.applyDatabaseClass(ctx, database); err != nil {
+		return r.setErrorStatus(ctx, database, reasonClassUnavailable, err)
+	}
+	if err := r.substituteSpec(ctx, database); err != nil {
+		return r.setErrorStatus(ctx, database, "SubstitutionFailed", err)
+	}
+	if err := r.applyOperatorDefaults(ctx, database); err != nil {
+		return r.setErrorStatus(ctx, database, "DefaultsUnavailable", err)
+	}
 
-	// Update status phase
-	database.Status.Phase = "Reconciling"
-	if updateErr := r.Status().Update(ctx, database); updateErr != nil {
-		logger.Error(updateErr, "failed to update status")
+	// The webhook may be disabled or bypassed; an invalid resolved spec stops here with a
+	// clear reason instead of failing on its children
+	if errs := validation.ValidateDatabase(database); len(errs) > 0 {
+		return r.markStalled(ctx, database, reasonValidationFailed, errs.ToAggregate())
 	}
 
-	// Reconcile child resources
-	if err := r.reconcilePVC(ctx, database); err != nil {
-		return r.setErrorStatus(ctx, database, "PVCCreateFailed", err)
+	// Site-specific plugins may hold back the first provisioning
+	if result, done, err := r.runPreProvisionPlugins(ctx, database); done {
+		return result, err
 	}
 
-	if err := r.reconcileSecret(ctx, database); err != nil {
-		return r.setErrorStatus(ctx, database, "SecretCreateFailed", err)
+	// A broken policy must not let children through unchecked; it is reported before any write
+	if _, err := r.ApplyPolicies.Load(ctx); err != nil {
+		return r.setErrorStatus(ctx, database, reasonPoliciesInvalid, err)
 	}
 
-	if err := r.reconcileConfigMap(ctx, database); err != nil {
-		return r.setErrorStatus(ctx, database, "ConfigMapCreateFailed", err)
+	// Reconcile child resources
+	if reason, err := r.reconcileChildren(ctx, database); err != nil {
+		return r.setErrorStatus(ctx, database, reason, err)
 	}
+	endRecreate(database)
 
-	if err := r.reconcileDeployment(ctx, database); err != nil {
-		return r.setErrorStatus(ctx, database, "DeploymentCreateFailed", err)
+	// Features whose APIs the cluster does not serve are skipped, not failed
+	if err := r.reconcileOptionalFeatures(ctx, database); err != nil {
+		return r.setErrorStatus(ctx, database, "OptionalFeatureFailed", err)
 	}
 
-	if err := r.reconcileService(ctx, database); err != nil {
-		return r.setErrorStatus(ctx, database, "ServiceCreateFailed", err)
+	// Remove children left behind by disabled or renamed features
+	if err := r.pruneChildren(ctx, database); err != nil {
+		return r.setErrorStatus(ctx, database, "PruneFailed", err)
 	}
 
 	// Update status
-	if err := r.updateStatus(ctx, database); err != nil {
+	r.observeApplyPolicies(database)
+	wasReady := database.IsReady()
+	if err := r.updateStatus(ctx, database, observed); err != nil {
 		return ctrl.Result{}, err
 	}
-
-	// Requeue for status check
-	requeueAfter := time.Minute * 1
-	if !database.IsReady() {
-		requeueAfter = time.Second * 10
+	r.runPostReadyPlugins(ctx, database, wasReady)
+	if isStalled(database) {
+		return ctrl.Result{}, nil
 	}
 
+	// Readiness changes arrive through pod and EndpointSlice watches;
+	// the periodic requeue is only a safety net
+	requeueAfter := r.RequeuePolicy.RequeueAfter(database)
+	if wait := maintenanceRequeue(database, time.Now()); wait > 0 && (requeueAfter <= 0 || wait < requeueAfter) {
+		requeueAfter = wait
+	}
+	if wait := degradedRequeue(database, r.DegradedAfter, time.Now()); wait > 0 && (requeueAfter <= 0 || wait < requeueAfter) {
+		requeueAfter = wait
+	}
 	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
-// reconcileDelete handles deletion of Database resources
-func (r *DatabaseReconciler) reconcileDelete(ctx context.Context, database *databasev1.Database) (ctrl.Result, error) {
+// finalize cleans up after a deleted Database; ReconcileWithFinalizer removes the finalizer
+// once it returns an empty result, and calls it again until then
+func (r *DatabaseReconciler) finalize(ctx context.Context, database *databasev1.Database) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
+	logger.Info("Deleting Database", "name", database.Name)
+
+	// Cleanup is handled automatically by garbage collection
+	// due to owner references
 
-	if controllerutil.ContainsFinalizer(database, databaseFinalizer) {
-		logger.Info("Deleting Database", "name", database.Name)
+	// During namespace teardown everything in the namespace is deleted at once;
+	// release the Database right away so the teardown is not held up
+	terminating, err := namespaceTerminating(ctx, r.Client, database.Namespace)
+	if err != nil {
+		logger.Error(err, "failed to check whether namespace is terminating")
+	}
 
-		// Cleanup is handled automatically by garbage collection
-		// due to owner references
+	// Site-specific plugins may hold the Database until they cleaned up after it.
+	// They are called during teardown too, but cannot hold it then.
+	if result, done := r.runPreDeletePlugins(ctx, database); done && !terminating {
+		return result, nil
+	}
 
-		controllerutil.RemoveFinalizer(database, databaseFinalizer)
-		if err := r.Update(ctx, database); err != nil {
+	// The password Secret is garbage collected, the password it references is not.
+	// Removing it only needs the secret manager, so teardown removes it too, but a
+	// failure then is logged instead of holding the Database.
+	if err := r.Passwords.delete(ctx, r.Client, database); err != nil {
+		if !terminating {
 			return ctrl.Result{}, err
 		}
+		logger.Error(err, "failed to delete password during namespace teardown")
 	}
-
 	return ctrl.Result{}, nil
 }
 
 // reconcilePVC creates or updates the persistent volume claim
 func (r *DatabaseReconciler) reconcilePVC(ctx context.Context, database *databasev1.Database) error {
+	// The StatefulSet of a highly available Database claims a volume per pod
+	if database.Spec.HighAvailability {
+		return nil
+	}
+
 	pvc := &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      database.Name,
+			Name:      pvcName(database),
 			Namespace: database.Namespace,
 		},
 	}
 
-	_, err := controllerutil.CreateOrPatch(ctx, r.Client, pvc, func() error {
+	existing := &corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(pvc), existing); client.IgnoreNotFound(err) != nil {
+		return err
+	} else if err == nil {
+		if !existing.DeletionTimestamp.IsZero() {
+			return &recreateError{kind: "PersistentVolumeClaim", name: existing.Name}
+		}
+		if field := pvcImmutableChange(existing, database); field != "" {
+			return r.recreatePVC(ctx, database, existing, field)
+		}
+	}
+
+	_, err := r.createOrPatch(ctx, pvc, func() error {
 		pvc.Spec = corev1.PersistentVolumeClaimSpec{
 			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
 			Resources: corev1.VolumeResourceRequirements{
@@ -157,6 +331,7 @@ func (r *DatabaseReconciler) reconcilePVC(ctx context.Context, database *databas
 			},
 			StorageClassName: &database.Spec.StorageClass,
 		}
+		r.Propagation.apply(database, pvc)
 		return controllerutil.SetControllerReference(database, pvc, r.Scheme)
 	})
 
@@ -165,19 +340,14 @@ func (r *DatabaseReconciler) reconcilePVC(ctx context.Context, database *databas
 
 // reconcileSecret creates or updates the database password secret
 func (r *DatabaseReconciler) reconcileSecret(ctx context.Context, database *databasev1.Database) error {
-	secretName := database.Spec.PasswordSecretName
-	if secretName == "" {
-		secretName = database.Name + "-password"
-	}
-
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      secretName,
+			Name:      passwordSecretName(database),
 			Namespace: database.Namespace,
 		},
 	}
 
-	_, err := controllerutil.CreateOrPatch(ctx, r.Client, secret, func() error {
+	_, err := r.createOrPatch(ctx, secret, func() error {
 		if secret.Data == nil {
 			// Generate secure random password
 			password, err := generateRandomPassword(24)
@@ -189,6 +359,11 @@ func (r *DatabaseReconciler) reconcileSecret(ctx context.Context, database *data
 				"username": []byte(database.Spec.UserName),
 			}
 		}
+		if err := r.externalizePassword(ctx, database, secret); err != nil {
+			return err
+		}
+		r.Propagation.apply(database, secret)
+		setDatabaseLabel(secret, database)
 		return controllerutil.SetControllerReference(database, secret, r.Scheme)
 	})
 
@@ -218,117 +393,214 @@ func (r *DatabaseReconciler) reconcileConfigMap(ctx context.Context, database *d
 		},
 	}
 
-	_, err := controllerutil.CreateOrPatch(ctx, r.Client, cm, func() error {
+	_, err := r.createOrPatch(ctx, cm, func() error {
 		cm.Data = map[string]string{
 			"POSTGRES_DB":       database.Spec.DatabaseName,
 			"POSTGRES_USER":     database.Spec.UserName,
 			"POSTGRES_PASSWORD": fmt.Sprintf("file:///etc/secrets/%s-password/password", database.Name),
 		}
+		r.Propagation.apply(database, cm)
+		setDatabaseLabel(cm, database)
 		return controllerutil.SetControllerReference(database, cm, r.Scheme)
 	})
 
 	return err
 }
 
-// reconcileDeployment creates or updates the deployment
+// reconcileDeployment creates or updates the deployment. The rendered Deployment is hashed
+// first, and the patch is skipped when neither the hashes nor the Deployment generation
+// changed: server-side defaults make a full render differ from the stored Deployment, so
+// patching unconditionally would write on every pass.
 func (r *DatabaseReconciler) reconcileDeployment(ctx context.Context, database *databasev1.Database) error {
+	logger := log.FromContext(ctx)
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      database.Name,
+			Name:      deploymentName(database),
 			Namespace: database.Namespace,
 		},
 	}
 
-	_, err := controllerutil.CreateOrPatch(ctx, r.Client, deployment, func() error {
+	checksums, err := r.podTemplateChecksums(ctx, database)
+	if err != nil {
+		return fmt.Errorf("failed to compute pod template checksums: %w", err)
+	}
+
+	podLabels := r.Propagation.podLabels(database)
+	podSpec := renderPodSpec(database)
+	r.Passwords.mountPassword(database, &podSpec)
+	deploymentMeta := metav1.ObjectMeta{}
+	r.Propagation.apply(database, &deploymentMeta)
+	hashes := deploymentHashes{
+		Template:   hashOf(podLabels, checksums, podSpec),
+		Deployment: hashOf(database.Spec.Replicas, deploymentMeta.Labels, deploymentMeta.Annotations),
+	}
+
+	existing := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(deployment), existing); client.IgnoreNotFound(err) != nil {
+		return err
+	} else if err == nil {
+		if !existing.DeletionTimestamp.IsZero() {
+			return &recreateError{kind: "Deployment", name: existing.Name}
+		}
+		if field := deploymentImmutableChange(existing, database); field != "" {
+			return r.recreateDeployment(ctx, database, existing, field)
+		}
+		change := classifyDeploymentChange(existing, hashes, database)
+		if change == changeNone || change == changeStatusOnly {
+			// The steady state of every Database passes here; build the line only when it is logged
+			if debug := logger.V(1); debug.Enabled() {
+				debug.Info("Deployment is up to date", "change", change.String())
+			}
+			return nil
+		}
+		logger.Info("Updating Deployment", "change", change.String())
+	}
+
+	_, err = r.createOrPatch(ctx, deployment, func() error {
 		deployment.Spec.Replicas = &database.Spec.Replicas
+		r.Propagation.apply(database, deployment)
+		deployment.Annotations[templateHashAnnotation] = hashes.Template
+		deployment.Annotations[deploymentHashAnnotation] = hashes.Deployment
+		// A spec change made by this patch bumps the generation past the recorded one, so
+		// the next pass renders once more and only records the new generation
+		deployment.Annotations[appliedGenerationAnnotation] = strconv.FormatInt(deployment.Generation, 10)
 		deployment.Spec.Selector = &metav1.LabelSelector{
-			MatchLabels: map[string]string{"app": database.Name},
+			MatchLabels: selectorLabels(database),
 		}
-		deployment.Spec.Template.ObjectMeta.Labels = map[string]string{"app": database.Name}
-
-		// Set up container
-		container := corev1.Container{
-			Name:  "database",
-			Image: database.Spec.Image,
-			Env: []corev1.EnvVar{
-				{
-					Name: "POSTGRES_DB",
-					Value: database.Spec.DatabaseName,
-				},
-				{
-					Name: "POSTGRES_USER",
-					Value: database.Spec.UserName,
-				},
-				{
-					Name: "POSTGRES_PASSWORD",
-					ValueFrom: &corev1.EnvVarSource{
-						SecretKeyRef: &corev1.SecretKeySelector{
-							LocalObjectReference: corev1.LocalObjectReference{
-								Name: database.Spec.PasswordSecretName,
-							},
-							Key: "password",
+		deployment.Spec.Template.ObjectMeta.Labels = podLabels
+
+		// Roll the pods when mounted Secret/ConfigMap content changes
+		if deployment.Spec.Template.Annotations == nil {
+			deployment.Spec.Template.Annotations = map[string]string{}
+		}
+		delete(deployment.Spec.Template.Annotations, configChecksumAnnotation)
+		for key, value := range checksums {
+			deployment.Spec.Template.Annotations[key] = value
+		}
+
+		deployment.Spec.Template.Spec.InitContainers = podSpec.InitContainers
+		deployment.Spec.Template.Spec.Containers = podSpec.Containers
+		deployment.Spec.Template.Spec.ImagePullSecrets = podSpec.ImagePullSecrets
+		deployment.Spec.Template.Spec.PriorityClassName = podSpec.PriorityClassName
+		deployment.Spec.Template.Spec.TopologySpreadConstraints = podSpec.TopologySpreadConstraints
+		deployment.Spec.Template.Spec.Volumes = podSpec.Volumes
+
+		return controllerutil.SetControllerReference(database, deployment, r.Scheme)
+	})
+
+	return err
+}
+
+// renderPodSpec renders the pod spec fields the operator owns
+func renderPodSpec(database *databasev1.Database) corev1.PodSpec {
+	// Set up container
+	allowPrivilegeEscalation := false
+	container := corev1.Container{
+		Name:  databaseContainer,
+		Image: database.Spec.Image,
+		Env: []corev1.EnvVar{
+			{
+				Name: "POSTGRES_DB",
+				Value: database.Spec.DatabaseName,
+			},
+			{
+				Name: "POSTGRES_USER",
+				Value: database.Spec.UserName,
+			},
+			{
+				Name: "POSTGRES_PASSWORD",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: passwordSecretName(database),
 						},
+						Key: "password",
 					},
 				},
 			},
-			Ports: []corev1.ContainerPort{
-				{ContainerPort: 5432},
+		},
+		Ports: []corev1.ContainerPort{
+			{ContainerPort: 5432},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "data",
+				MountPath: "/var/lib/postgresql/data",
 			},
-			VolumeMounts: []corev1.VolumeMount{
-				{
-					Name:      "data",
-					MountPath: "/var/lib/postgresql/data",
+		},
+		// Required by the pod policy webhook. The postgres entrypoint still drops from
+		// root to the postgres user, which needs no privilege escalation.
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		},
+	}
+
+	if database.Spec.Resources != nil {
+		container.Resources = *database.Spec.Resources
+	}
+
+	// Server settings; without them the image's default command runs unchanged
+	if len(database.Spec.Parameters) > 0 {
+		container.Args = postgresArgs(database.Spec.Parameters)
+	}
+
+	// Add ConfigMap volume if specified
+	if database.Spec.ConfigMapName != "" {
+		container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+			ConfigMapRef: &corev1.ConfigMapEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: database.Spec.ConfigMapName,
 				},
 			},
-		}
+		})
+	}
 
-		// Add ConfigMap volume if specified
-		if database.Spec.ConfigMapName != "" {
-			container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
-				ConfigMapRef: &corev1.ConfigMapEnvSource{
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: database.Spec.ConfigMapName,
-					},
-				},
-			})
-		}
+	podSpec := corev1.PodSpec{
+		Containers:                []corev1.Container{container},
+		ImagePullSecrets:          database.Spec.ImagePullSecrets,
+		PriorityClassName:         database.Spec.PriorityClassName,
+		TopologySpreadConstraints: zoneSpreadConstraints(database),
+	}
 
-		deployment.Spec.Template.Spec.Containers = []corev1.Container{container}
+	// A standby is cloned from its primary before PostgreSQL first starts
+	if database.Spec.Standby != nil {
+		podSpec.InitContainers = []corev1.Container{standbyInitContainer(database, container.Resources)}
+	}
 
-		// Add PVC volume
-		deployment.Spec.Template.Spec.Volumes = []corev1.Volume{
-			{
-				Name: "data",
-				VolumeSource: corev1.VolumeSource{
-					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-						ClaimName: database.Name,
-					},
+	// Add PVC volume
+	podSpec.Volumes = []corev1.Volume{
+		{
+			Name: "data",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pvcName(database),
 				},
 			},
-		}
-
-		return controllerutil.SetControllerReference(database, deployment, r.Scheme)
-	})
+		},
+	}
 
-	return err
+	return podSpec
 }
 
 // reconcileService creates or updates the service
 func (r *DatabaseReconciler) reconcileService(ctx context.Context, database *databasev1.Database) error {
 	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      database.Name,
+			Name:      serviceName(database),
 			Namespace: database.Namespace,
 		},
 	}
 
-	_, err := controllerutil.CreateOrPatch(ctx, r.Client, service, func() error {
-		service.Spec.Type = database.Spec.ServiceType
+	_, err := r.createOrPatch(ctx, service, func() error {
+		service.Spec.Type = ""
+		if database.Spec.Service != nil {
+			service.Spec.Type = database.Spec.Service.Type
+		}
 		if service.Spec.Type == "" {
 			service.Spec.Type = corev1.ServiceTypeClusterIP
 		}
 
-		service.Spec.Selector = map[string]string{"app": database.Name}
+		service.Spec.Selector = serviceSelector(database)
 		service.Spec.Ports = []corev1.ServicePort{
 			{
 				Port:       5432,
@@ -337,92 +609,274 @@ func (r *DatabaseReconciler) reconcileService(ctx context.Context, database *dat
 			},
 		}
 
+		// The endpoint controller copies Service labels to the EndpointSlices, which maps
+		// them back to the Database even when the Service name was shortened
+		r.Propagation.apply(database, service)
+		setDatabaseLabel(service, database)
 		return controllerutil.SetControllerReference(database, service, r.Scheme)
 	})
 
 	return err
 }
 
-// updateStatus updates the database status
-func (r *DatabaseReconciler) updateStatus(ctx context.Context, database *databasev1.Database) error {
+// updateStatus updates the database status; observed is the status the reconcile started with
+func (r *DatabaseReconciler) updateStatus(ctx context.Context, database *databasev1.Database, observed *databasev1.DatabaseStatus) error {
 	// Get deployment status
 	deployment := &appsv1.Deployment{}
-	if err := r.Get(ctx, types.NamespacedName{Name: database.Name, Namespace: database.Namespace}, deployment); err != nil {
+	if err := r.Get(ctx, types.NamespacedName{Name: deploymentName(database), Namespace: database.Namespace}, deployment); err != nil {
 		if !errors.IsNotFound(err) {
 			return err
 		}
 	}
 
+	readyReplicas := deployment.Status.ReadyReplicas
+	if database.Status.Workload == databasev1.WorkloadStatefulSet {
+		statefulSet := &appsv1.StatefulSet{}
+		if err := r.Get(ctx, types.NamespacedName{Name: statefulSetName(database), Namespace: database.Namespace}, statefulSet); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		readyReplicas = statefulSet.Status.ReadyReplicas
+	}
+
 	// Update status
-	database.Status.ReadyReplicas = deployment.Status.ReadyReplicas
+	database.Status.ReadyReplicas = readyReplicas
 	database.Status.DeploymentName = deployment.Name
-	database.Status.ServiceName = database.Name
+	database.Status.ServiceName = serviceName(database)
 	database.Status.ObservedGeneration = database.Generation
 
+	// Summarize pods so the Database status is enough for first-line debugging
+	pods, err := r.listDatabasePods(ctx, database)
+	if err != nil {
+		return err
+	}
+	database.Status.Pods = podStatuses(pods)
+	if err := r.reportResources(ctx, database, pods); err != nil {
+		return err
+	}
+	if err := r.reportZones(ctx, database, pods); err != nil {
+		return err
+	}
+
 	// Update conditions
-	if deployment.Status.ReadyReplicas == database.Spec.Replicas {
+	ready := readyReplicas == database.Spec.Replicas
+	observeConvergence(database, ready)
+	observeDegraded(database, readyReplicas, r.DegradedAfter, time.Now())
+	if ready {
 		database.Status.Phase = "Ready"
 		database.SetCondition("Ready", metav1.ConditionTrue, "Ready", "Database is ready")
+		database.SetCondition(conditionRolloutStalled, metav1.ConditionFalse, "RolloutComplete", "Rollout is complete")
+		r.recordHistory(ctx, database, nil)
+		return r.writeStatus(ctx, database, observed)
+	}
+
+	// Not ready yet: tell a slow rollout apart from one that will never finish
+	stall := detectRolloutStall(deployment, pods)
+	if stall != nil {
+		r.warn(database, deployment, stall.Reason, "Rollout", stall.Message)
+	}
+	if stall != nil && terminalStallReasons[stall.Reason] {
+		setStalled(database, stall.Reason, stall.Message)
+		database.SetCondition(conditionRolloutStalled, metav1.ConditionTrue, stall.Reason, stall.Message)
+	} else if stall != nil {
+		database.Status.Phase = "Stalled"
+		database.SetCondition(conditionRolloutStalled, metav1.ConditionTrue, stall.Reason, stall.Message)
+		database.SetCondition("Ready", metav1.ConditionFalse, conditionRolloutStalled, stall.Message)
 	} else {
 		database.Status.Phase = "Progressing"
+		if database.IsDegraded() {
+			database.Status.Phase = "Degraded"
+		}
+		database.SetCondition(conditionRolloutStalled, metav1.ConditionFalse, "Progressing", "Rollout is progressing")
 		database.SetCondition("Ready", metav1.ConditionFalse, "Progressing",
-			fmt.Sprintf("Waiting for replicas: %d/%d", deployment.Status.ReadyReplicas, database.Spec.Replicas))
+			fmt.Sprintf("Waiting for replicas: %d/%d", readyReplicas, database.Spec.Replicas))
 	}
 
+	r.recordHistory(ctx, database, nil)
+	return r.writeStatus(ctx, database, observed)
+}
+
+// writeStatus stores the status unless it still equals observed, so the periodic resync of
+// a converged Database writes nothing
+func (r *DatabaseReconciler) writeStatus(ctx context.Context, database *databasev1.Database, observed *databasev1.DatabaseStatus) error {
+	if equality.Semantic.DeepEqual(&database.Status, observed) {
+		return nil
+	}
 	return r.Status().Update(ctx, database)
 }
 
 // setErrorStatus sets error status and returns error
 func (r *DatabaseReconciler) setErrorStatus(ctx context.Context, database *databasev1.Database, reason string, err error) (ctrl.Result, error) {
+	// The namespace started terminating mid-reconcile; the Database is about to be deleted
+	if isNamespaceTerminatingError(err) {
+		log.FromContext(ctx).Info("Namespace is terminating, skipping reconciliation", "namespace", database.Namespace)
+		return ctrl.Result{}, nil
+	}
+
+	// A child with a changed immutable field is deleted and created again, unless that loses data
+	if recreating, ok := asRecreateError(err); ok {
+		return r.waitForRecreate(ctx, database, recreating)
+	}
+	if blocked, ok := asRecreateBlockedError(err); ok {
+		return r.blockRecreate(ctx, database, blocked)
+	}
+
+	// A child broke an apply policy and was not written
+	if violation, ok := asPolicyViolation(err); ok {
+		return r.blockOnPolicy(ctx, database, violation)
+	}
+
+	// A workload migration step is waiting for pods to stop or start
+	if waiting, ok := asMigrationWait(err); ok {
+		return r.waitForMigration(ctx, database, waiting)
+	}
+
+	// Retrying cannot fix an invalid child; wait for the spec to change
+	if isTerminalError(err) {
+		return r.markStalled(ctx, database, reason, err)
+	}
+
+	r.warn(database, nil, reason, "Reconcile", err.Error())
 	database.Status.Phase = "Failed"
 	database.SetCondition("Ready", metav1.ConditionFalse, reason, err.Error())
+	database.SetCondition(conditionConverged, metav1.ConditionFalse, reason, err.Error())
+	r.recordHistory(ctx, database, err)
 	_ = r.Status().Update(ctx, database)
 	return ctrl.Result{}, err
 }
 
 // SetupWithManager sets up the controller with the Manager
 func (r *DatabaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&databasev1.Database{}).
+	bld := ctrl.NewControllerManagedBy(mgr)
+
+	// Only watch optional kinds the cluster serves; a watch on a missing API fails startup
+	available, err := apiAvailable(mgr.GetRESTMapper(), podDisruptionBudgetGVK)
+	if err != nil {
+		return err
+	}
+	if available {
+		bld = bld.Owns(&policyv1.PodDisruptionBudget{})
+	}
+	if err := mgr.Add(manager.RunnableFunc(r.checkSchemaRevisions)); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &databasev1.Database{},
+		classNameIndex, indexClassName); err != nil {
+		return err
+	}
+	// ConfigMap and variable events list only the Databases they concern, not their namespace
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &databasev1.Database{},
+		configMapIndex, indexConfigMaps); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &databasev1.Database{},
+		substitutionIndex, indexSubstitution); err != nil {
+		return err
+	}
+	// Children of Databases with names too long for a label value are mapped back by index
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &databasev1.Database{},
+		databaseLabelIndex, indexDatabaseLabel); err != nil {
+		return err
+	}
+	if r.ExternalEvents != nil {
+		bld = bld.WatchesRawSource(&source.Channel{Source: r.ExternalEvents}, &handler.EnqueueRequestForObject{})
+	}
+
+	return bld.
+		// Status writes change the resourceVersion but not the generation; reconciling on
+		// them would keep every Database busy and the resync interval would never matter
+		For(&databasev1.Database{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			predicate.LabelChangedPredicate{},
+			predicate.AnnotationChangedPredicate{},
+		))).
 		// Watch owned deployment
 		Owns(&appsv1.Deployment{}).
+		// Watch the owned StatefulSet of migrated Databases
+		Owns(&appsv1.StatefulSet{}).
 		// Watch owned service
 		Owns(&corev1.Service{}).
-		// Watch owned configmap (if specified)
+		// Watch owned maintenance Jobs so finished runs are recorded
+		Owns(&batchv1.Job{}).
+		// Watch owned password secret so content changes roll the pods
+		Owns(&corev1.Secret{}).
+		// Watch owned configmap (if specified) and variable ConfigMaps
 		Watches(
-			&source.Kind{Type: &corev1.ConfigMap{}},
+			&corev1.ConfigMap{},
 			handler.EnqueueRequestsFromMapFunc(r.findDatabasesForConfigMap),
 			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
 		).
+		// Watch variable Secrets so substituted values are re-resolved when they change
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.findDatabasesForVariables),
+			builder.WithPredicates(hasLabel(variablesLabel)),
+		).
+		// Watch database pods so readiness changes are seen immediately
+		Watches(
+			&corev1.Pod{},
+			handler.EnqueueRequestsFromMapFunc(r.findDatabaseForPod),
+			builder.WithPredicates(hasLabel(databaseNameLabel)),
+		).
+		// Watch the Service's EndpointSlices so connectivity changes are seen immediately
+		Watches(
+			&discoveryv1.EndpointSlice{},
+			handler.EnqueueRequestsFromMapFunc(r.findDatabaseForEndpointSlice),
+			builder.WithPredicates(hasLabel(discoveryv1.LabelServiceName)),
+		).
+		// Watch DatabaseClasses so preset changes reach the Databases using them
+		Watches(
+			&databasev1.DatabaseClass{},
+			handler.EnqueueRequestsFromMapFunc(r.findDatabasesForClass),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
 		// Configure controller options
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: 2,
 		}).
-		Complete(r)
+		Complete(r.Switches.Controller(DatabaseController, r))
 }
 
-// findDatabasesForConfigMap finds Databases that reference a ConfigMap
+// configMapIndex indexes Databases by the ConfigMaps they mount or own
+const configMapIndex = ".spec.configMapName"
+
+// findDatabasesForConfigMap finds Databases that reference a ConfigMap or take variables from it
 func (r *DatabaseReconciler) findDatabasesForConfigMap(ctx context.Context, o client.Object) []reconcile.Request {
 	configMap := o.(*corev1.ConfigMap)
 	logger := log.FromContext(ctx)
 
+	// Only the names are read, so the cached Databases are not copied
 	var list databasev1.DatabaseList
-	if err := r.List(ctx, &list, client.InNamespace(configMap.Namespace)); err != nil {
+	if err := r.List(ctx, &list, client.InNamespace(configMap.Namespace),
+		client.MatchingFields{configMapIndex: configMap.Name}, client.UnsafeDisableDeepCopy); err != nil {
 		logger.Error(err, "failed to list Databases")
 		return nil
 	}
 
-	var requests []reconcile.Request
-	for _, item := range list.Items {
-		if item.Spec.ConfigMapName == configMap.Name {
-			requests = append(requests, reconcile.Request{
-				NamespacedName: types.NamespacedName{
-					Name:      item.Name,
-					Namespace: item.Namespace,
-				},
-			})
-		}
+	requests := r.findDatabasesForVariables(ctx, configMap)
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      list.Items[i].Name,
+				Namespace: list.Items[i].Namespace,
+			},
+		})
 	}
 
 	return requests
 }
+
+// indexConfigMaps is the configMapIndex function: the ConfigMap a Database mounts and its dashboard
+func indexConfigMaps(obj client.Object) []string {
+	database, ok := obj.(*databasev1.Database)
+	if !ok {
+		return nil
+	}
+	var names []string
+	if database.Spec.ConfigMapName != "" {
+		names = append(names, database.Spec.ConfigMapName)
+	}
+	if monitoringEnabled(database) {
+		names = append(names, dashboardName(database))
+	}
+	return names
+}
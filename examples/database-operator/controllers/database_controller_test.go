@@ -3,14 +3,17 @@ package controllers
 import (
 	"context"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
@@ -21,6 +24,7 @@ func TestDatabaseReconciler_Reconcile(t *testing.T) {
 	scheme := runtime.NewScheme()
 	require.NoError(t, databasev1.AddToScheme(scheme))
 	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
 
 	tests := []struct {
 		name            string
@@ -67,11 +71,15 @@ func TestDatabaseReconciler_Reconcile(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Create fake client
-			fakeClient := fake.NewClientBuilder().
-				WithScheme(scheme).
-				WithObjects(tt.initialDatabase).
-				Build()
+			// Create fake client; a missing Database is not created
+			existing := tt.name != "database not found"
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			if existing {
+				builder = builder.
+					WithObjects(tt.initialDatabase).
+					WithStatusSubresource(tt.initialDatabase)
+			}
+			fakeClient := builder.Build()
 
 			// Create reconciler
 			reconciler := &DatabaseReconciler{
@@ -87,6 +95,9 @@ func TestDatabaseReconciler_Reconcile(t *testing.T) {
 				},
 			}
 
+			// The first reconcile only adds the finalizer
+			_, err := reconciler.Reconcile(context.Background(), req)
+			require.NoError(t, err)
 			result, err := reconciler.Reconcile(context.Background(), req)
 
 			// Verify results
@@ -94,11 +105,15 @@ func TestDatabaseReconciler_Reconcile(t *testing.T) {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
-				assert.NotEqual(t, ctrl.Result{}, result, "Should return a result")
+				if existing {
+					assert.NotEqual(t, ctrl.Result{}, result, "Should return a result")
+				} else {
+					assert.Equal(t, ctrl.Result{}, result, "A missing Database is not requeued")
+				}
 			}
 
 			// Verify status if database exists
-			if tt.name != "database not found" {
+			if existing {
 				database := &databasev1.Database{}
 				err = fakeClient.Get(context.Background(), req.NamespacedName, database)
 				require.NoError(t, err)
@@ -153,11 +168,9 @@ func TestDatabaseReconciler_ReconcileWithFinalizer(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, ctrl.Result{}, result)
 
-	// Verify finalizer was removed
-	updatedDB := &databasev1.Database{}
-	err = fakeClient.Get(context.Background(), req.NamespacedName, updatedDB)
-	require.NoError(t, err)
-	assert.Empty(t, updatedDB.Finalizers, "Finalizer should be removed after cleanup")
+	// Removing the last finalizer of a deleted object deletes it
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &databasev1.Database{})
+	assert.True(t, apierrors.IsNotFound(err), "Database should be gone once the finalizer is removed")
 }
 
 func TestGenerateRandomPassword(t *testing.T) {
@@ -207,3 +220,66 @@ func TestDatabase_IsReady(t *testing.T) {
 	db.SetCondition("Ready", "False", "Error", "Database failed")
 	assert.False(t, db.IsReady())
 }
+
+// newHotPathDatabase returns a Database shaped like a typical production one, for the
+// allocation benchmarks of the reconcile hot path
+func newHotPathDatabase() *databasev1.Database {
+	return &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "orders",
+			Namespace:   "shop",
+			UID:         "orders-uid",
+			Generation:  1,
+			Finalizers:  []string{databaseFinalizer},
+			Labels:      map[string]string{"app.kubernetes.io/part-of": "shop", "team": "payments"},
+			Annotations: map[string]string{"owner": "payments@example.com"},
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas:      3,
+			Image:         "postgres:15",
+			Storage:       10240,
+			StorageClass:  "fast-ssd",
+			DatabaseName:  "orders",
+			UserName:      "app",
+			ConfigMapName: "orders-config",
+			Resources: &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+			},
+			Parameters: map[string]string{"max_connections": "200", "shared_buffers": "256MB", "work_mem": "16MB"},
+		},
+	}
+}
+
+// BenchmarkDatabaseReconciler_Reconcile measures a steady-state reconcile: the children exist
+// and are up to date, and the history is full. The fake client's own copies dominate; compare
+// allocs/op between changes rather than with a real cache.
+// Run with: go test -run '^$' -bench DatabaseReconciler_Reconcile -benchmem ./controllers/
+func BenchmarkDatabaseReconciler_Reconcile(b *testing.B) {
+	scheme := runtime.NewScheme()
+	require.NoError(b, databasev1.AddToScheme(scheme))
+	require.NoError(b, clientgoscheme.AddToScheme(scheme))
+
+	database := newHotPathDatabase()
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database).
+		Build()
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme, HistoryLimit: maxStatusHistory}
+
+	ctx := context.Background()
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: "orders", Namespace: "shop"}}
+	for i := 0; i < maxStatusHistory; i++ {
+		_, err := reconciler.Reconcile(ctx, request)
+		require.NoError(b, err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := reconciler.Reconcile(ctx, request); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
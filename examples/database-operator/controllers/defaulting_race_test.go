@@ -0,0 +1,311 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// raceDatabases is how many Databases the race test creates back to back
+const raceDatabases = 20
+
+// newRaceDefaults is the defaults ConfigMap of the race test. It sets no priority class, which
+// the validating webhook would look up.
+func newRaceDefaults() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "database-operator-defaults", Namespace: "database-operator-system"},
+		Data: map[string]string{
+			defaultsImageRegistryKey: "registry.example.com/mirror",
+			defaultsStorageClassKey:  "fast-ssd",
+			defaultsResourcesKey:     "requests: {cpu: 500m, memory: 1Gi}\n",
+		},
+	}
+}
+
+// TestDefaultingRace_Fake runs the race against the fake client. The defaulting webhook is
+// called on every create and update of a Database, as the API server does, and every write of
+// the controller that changes a stored spec is recorded.
+func TestDefaultingRace_Fake(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	stored := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(newRaceDefaults()).
+		WithStatusSubresource(&databasev1.Database{}, &appsv1.Deployment{}).
+		Build()
+	defaulter := &DatabaseDefaulter{Defaults: newDefaultsSource(stored)}
+	admit := func(ctx context.Context, obj client.Object) error {
+		if database, ok := obj.(*databasev1.Database); ok {
+			return defaulter.Default(ctx, database)
+		}
+		return nil
+	}
+	admitted := interceptor.NewClient(stored, interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if err := admit(ctx, obj); err != nil {
+				return err
+			}
+			// Owner references match by UID, which the fake client does not assign
+			obj.SetUID(uuid.NewUUID())
+			return c.Create(ctx, obj, opts...)
+		},
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			if err := admit(ctx, obj); err != nil {
+				return err
+			}
+			return c.Update(ctx, obj, opts...)
+		},
+	})
+
+	var mu sync.Mutex
+	var specWrites []string
+	recordSpecWrite := func(ctx context.Context, obj client.Object, write func() error) error {
+		if _, ok := obj.(*databasev1.Database); !ok {
+			return write()
+		}
+		before := &databasev1.Database{}
+		if err := stored.Get(ctx, client.ObjectKeyFromObject(obj), before); err != nil {
+			return err
+		}
+		if err := write(); err != nil {
+			return err
+		}
+		after := &databasev1.Database{}
+		if err := stored.Get(ctx, client.ObjectKeyFromObject(obj), after); err != nil {
+			return err
+		}
+		if !equality.Semantic.DeepEqual(before.Spec, after.Spec) {
+			mu.Lock()
+			specWrites = append(specWrites, obj.GetName())
+			mu.Unlock()
+		}
+		return nil
+	}
+	controllerClient := interceptor.NewClient(admitted, interceptor.Funcs{
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			return recordSpecWrite(ctx, obj, func() error { return c.Update(ctx, obj, opts...) })
+		},
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			return recordSpecWrite(ctx, obj, func() error { return c.Patch(ctx, obj, patch, opts...) })
+		},
+	})
+	reconciler := &DatabaseReconciler{
+		Client:   controllerClient,
+		Scheme:   scheme,
+		Defaults: newDefaultsSource(stored),
+		Recorder: events.NewFakeRecorder(1000),
+	}
+
+	// The controller reconciles every Database over and over while they are created
+	controller := func(ctx context.Context) {
+		for ctx.Err() == nil {
+			databases := &databasev1.DatabaseList{}
+			if err := stored.List(ctx, databases); err != nil {
+				continue
+			}
+			for i := range databases.Items {
+				_, _ = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&databases.Items[i])})
+			}
+		}
+	}
+	testDefaultingRace(t, admitted, controller)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Empty(t, specWrites, "The controller never rewrites a persisted spec")
+}
+
+// TestDefaultingRace_Envtest runs the race against a real API server, with the defaulting and
+// validating webhooks and the Database controller served by one manager, as in the operator.
+// It needs the envtest binaries; run it with KUBEBUILDER_ASSETS set, e.g. via setup-envtest.
+func TestDefaultingRace_Envtest(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{"../config/crd/bases"},
+		ErrorIfCRDPathMissing: true,
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			Paths: []string{"../config/webhook/manifests.yaml"},
+		},
+	}
+	cfg, err := env.Start()
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, env.Stop()) })
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "database-operator-system"}}))
+	require.NoError(t, c.Create(ctx, newRaceDefaults()))
+
+	webhookOptions := env.WebhookInstallOptions
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:  scheme,
+		Metrics: metricsserver.Options{BindAddress: "0"},
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Host:    webhookOptions.LocalServingHost,
+			Port:    webhookOptions.LocalServingPort,
+			CertDir: webhookOptions.LocalServingCertDir,
+		}),
+	})
+	require.NoError(t, err)
+	defaults := newDefaultsSource(mgr.GetAPIReader())
+	require.NoError(t, (&DatabaseDefaulter{Defaults: defaults}).SetupWebhookWithManager(mgr))
+	require.NoError(t, (&DatabaseValidator{Reader: mgr.GetAPIReader()}).SetupWebhookWithManager(mgr))
+	require.NoError(t, (&DatabaseReconciler{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		Scheme:    scheme,
+		Defaults:  defaults,
+		Recorder:  events.NewFakeRecorder(1000),
+	}).SetupWithManager(mgr))
+
+	manager := func(ctx context.Context) {
+		if err := mgr.Start(ctx); err != nil {
+			t.Errorf("manager stopped: %v", err)
+		}
+	}
+	admitted := testDefaultingRace(t, c, manager)
+
+	// The API server bumps the generation on every spec change
+	for _, database := range admitted {
+		current := &databasev1.Database{}
+		require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(database), current))
+		assert.Equal(t, int64(1), current.Generation, "Database %s was only written with the spec it was created with", database.Name)
+	}
+}
+
+// testDefaultingRace creates Databases back to back while the controller runs and switches
+// the operator defaults half way through. The defaulting webhook persists the defaults of the
+// moment each Database is created; the controller must take those as the spec and only fill
+// unset fields in memory. If it wrote the in-memory result back, or applied defaults after it
+// wrote the finalizer, Databases created before the switch would take the new defaults and
+// their children would be changed behind the user's back.
+//
+// c is the client of the user, whose writes pass the webhook; run runs the controller until its
+// context is cancelled. The Databases are returned as the webhook admitted them.
+func testDefaultingRace(t *testing.T, c client.Client, run func(ctx context.Context)) []*databasev1.Database {
+	ctx, cancel := context.WithCancel(context.Background())
+	var running sync.WaitGroup
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		running.Wait()
+	})
+
+	admitted := make([]*databasev1.Database, 0, raceDatabases)
+	for i := 0; i < raceDatabases; i++ {
+		if i == raceDatabases/2 {
+			defaults := newRaceDefaults()
+			require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(defaults), defaults))
+			defaults.Data[defaultsStorageClassKey] = "standard-hdd"
+			defaults.Data[defaultsResourcesKey] = "requests: {cpu: 250m, memory: 512Mi}\n"
+			require.NoError(t, c.Update(ctx, defaults))
+		}
+		database := &databasev1.Database{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("race-%02d", i), Namespace: "default"},
+			Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+		}
+		require.NoError(t, c.Create(ctx, database))
+		admitted = append(admitted, database)
+	}
+
+	// The defaults of the moment of creation are persisted
+	for i, database := range admitted {
+		assert.Equal(t, "registry.example.com/mirror/postgres:15", database.Spec.Image)
+		if i < raceDatabases/2 {
+			assert.Equal(t, "fast-ssd", database.Spec.StorageClass, database.Name)
+		} else {
+			assert.Equal(t, "standard-hdd", database.Spec.StorageClass, database.Name)
+		}
+	}
+
+	// Every Database converges on the spec it was admitted with
+	converged := func(database *databasev1.Database) error {
+		current := &databasev1.Database{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(database), current); err != nil {
+			return err
+		}
+		if !equality.Semantic.DeepEqual(current.Spec, database.Spec) {
+			return fmt.Errorf("spec of %s changed from %+v to %+v", database.Name, database.Spec, current.Spec)
+		}
+		deployment := &appsv1.Deployment{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: database.Namespace, Name: deploymentName(database)}, deployment); err != nil {
+			return err
+		}
+		container := deployment.Spec.Template.Spec.Containers[0]
+		if container.Image != database.Spec.Image {
+			return fmt.Errorf("the Deployment of %s runs %s", database.Name, container.Image)
+		}
+		if !equality.Semantic.DeepEqual(container.Resources, *database.Spec.Resources) {
+			return fmt.Errorf("the Deployment of %s requests %v", database.Name, container.Resources.Requests)
+		}
+		pvc := &corev1.PersistentVolumeClaim{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: database.Namespace, Name: pvcName(database)}, pvc); err != nil {
+			return err
+		}
+		if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName != database.Spec.StorageClass {
+			return fmt.Errorf("the PVC of %s has storage class %v", database.Name, pvc.Spec.StorageClassName)
+		}
+		return nil
+	}
+	allConverged := func() error {
+		for _, database := range admitted {
+			if err := converged(database); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for deadline := time.Now().Add(30 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		err := allConverged()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			require.NoError(t, err, "Databases did not converge")
+		}
+	}
+
+	// Give the controller time to fight, then check that nothing moved
+	time.Sleep(time.Second)
+	for _, database := range admitted {
+		assert.NoError(t, converged(database))
+	}
+	return admitted
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// Keys read from the operator defaults ConfigMap
+const (
+	defaultsImageRegistryKey = "imageRegistry"
+	defaultsStorageClassKey  = "storageClass"
+	defaultsResourcesKey     = "resources"
+	defaultsPriorityClassKey = "priorityClassName"
+)
+
+// OperatorDefaults is cluster-wide policy applied to fields a Database leaves unset
+type OperatorDefaults struct {
+	// ImageRegistry is prepended to images that do not name a registry, e.g. a mirror
+	// in air-gapped clusters
+	ImageRegistry string
+
+	// StorageClass is used when spec.storageClass is empty
+	StorageClass string
+
+	// Resources are used when spec.resources is unset
+	Resources *corev1.ResourceRequirements
+
+	// PriorityClassName is used when spec.priorityClassName is empty
+	PriorityClassName string
+
+	// Images is applied to the rendered pods only; it is not persisted by Apply
+	Images ImagePolicy
+}
+
+// Apply fills unset fields of the Database from the defaults.
+// Fields the user set are never overwritten.
+func (d OperatorDefaults) Apply(database *databasev1.Database) {
+	// Images with ${VAR} references are left alone until the controller has expanded them
+	if d.ImageRegistry != "" && database.Spec.Image != "" && !hasRegistry(database.Spec.Image) &&
+		!strings.Contains(database.Spec.Image, "${") {
+		database.Spec.Image = strings.TrimSuffix(d.ImageRegistry, "/") + "/" + database.Spec.Image
+	}
+	if database.Spec.StorageClass == "" {
+		database.Spec.StorageClass = d.StorageClass
+	}
+	if database.Spec.Resources == nil && d.Resources != nil {
+		database.Spec.Resources = d.Resources.DeepCopy()
+	}
+	if database.Spec.PriorityClassName == "" {
+		database.Spec.PriorityClassName = d.PriorityClassName
+	}
+}
+
+// hasRegistry reports whether an image reference starts with a registry host,
+// using the same rule as the container runtime: the first path component names
+// a registry if it contains a dot or a port, or is localhost
+func hasRegistry(image string) bool {
+	first, _, found := strings.Cut(image, "/")
+	if !found {
+		return false
+	}
+	return strings.ContainsAny(first, ".:") || first == "localhost"
+}
+
+// DefaultsSource loads OperatorDefaults from a ConfigMap maintained by the cluster admin,
+// so per-cluster policy does not require rebuilding or redeploying the operator:
+//
+//	apiVersion: v1
+//	kind: ConfigMap
+//	metadata:
+//	  name: database-operator-defaults
+//	  namespace: database-operator-system
+//	data:
+//	  imageRegistry: registry.example.com/mirror
+//	  storageClass: fast-ssd
+//	  priorityClassName: database-critical
+//	  resources: |
+//	    requests: {cpu: 500m, memory: 1Gi}
+//	    limits: {memory: 2Gi}
+//	  registryMirrors: |
+//	    docker.io: registry.example.com/dockerhub
+//	  imageDigests: |
+//	    postgres:15: sha256:...
+//	  imagePullSecrets: |
+//	    - mirror-credentials
+//
+// The ConfigMap is read on every use, so edits apply to the next admission or reconcile.
+type DefaultsSource struct {
+	client.Reader
+
+	// Namespace and Name locate the ConfigMap; an empty Name disables operator defaults
+	Namespace string
+	Name      string
+}
+
+// BindFlags registers flags for the defaults ConfigMap, using the current values as defaults
+func (s *DefaultsSource) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.Namespace, "defaults-configmap-namespace", s.Namespace,
+		"Namespace of the ConfigMap holding cluster-wide Database defaults.")
+	fs.StringVar(&s.Name, "defaults-configmap-name", s.Name,
+		"Name of the ConfigMap holding cluster-wide Database defaults. Empty disables operator defaults.")
+}
+
+// Load reads the current defaults. A missing ConfigMap means no defaults.
+func (s *DefaultsSource) Load(ctx context.Context) (OperatorDefaults, error) {
+	var defaults OperatorDefaults
+	if s == nil || s.Name == "" {
+		return defaults, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := s.Get(ctx, types.NamespacedName{Namespace: s.Namespace, Name: s.Name}, configMap); err != nil {
+		if errors.IsNotFound(err) {
+			return defaults, nil
+		}
+		return defaults, fmt.Errorf("failed to get defaults ConfigMap: %w", err)
+	}
+
+	defaults.ImageRegistry = strings.TrimSpace(configMap.Data[defaultsImageRegistryKey])
+	defaults.StorageClass = strings.TrimSpace(configMap.Data[defaultsStorageClassKey])
+	defaults.PriorityClassName = strings.TrimSpace(configMap.Data[defaultsPriorityClassKey])
+	if raw := configMap.Data[defaultsResourcesKey]; raw != "" {
+		resources := &corev1.ResourceRequirements{}
+		if err := yaml.UnmarshalStrict([]byte(raw), resources); err != nil {
+			return defaults, fmt.Errorf("invalid %q in defaults ConfigMap %s/%s: %w",
+				defaultsResourcesKey, s.Namespace, s.Name, err)
+		}
+		defaults.Resources = resources
+	}
+
+	images, err := parseImagePolicy(configMap.Data)
+	if err != nil {
+		return defaults, fmt.Errorf("defaults ConfigMap %s/%s: %w", s.Namespace, s.Name, err)
+	}
+	defaults.Images = images
+
+	return defaults, nil
+}
+
+// applyOperatorDefaults fills unset fields of the in-memory Database. The defaulting webhook
+// persists the same defaults; this covers Databases created before it was installed or while
+// it was disabled. The image policy is applied last and only ever in memory.
+func (r *DatabaseReconciler) applyOperatorDefaults(ctx context.Context, database *databasev1.Database) error {
+	defaults, err := r.Defaults.Load(ctx)
+	if err != nil {
+		return err
+	}
+	defaults.Apply(database)
+	defaults.Images.apply(database)
+	return nil
+}
+
+//+kubebuilder:webhook:path=/mutate-my-domain-v1-database,mutating=true,failurePolicy=fail,timeoutSeconds=5,sideEffects=None,groups=my.domain,resources=databases,verbs=create;update,versions=v1,name=mdatabase.kb.io,admissionReviewVersions=v1
+
+// DatabaseDefaulter is the defaulting webhook for Databases
+type DatabaseDefaulter struct {
+	Defaults *DefaultsSource
+
+	// Switches can turn the webhook off at runtime; nil keeps it on
+	Switches *Switches
+}
+
+var _ admission.CustomDefaulter = &DatabaseDefaulter{}
+
+// Default applies the operator defaults to a Database being created or updated
+func (d *DatabaseDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	database, ok := obj.(*databasev1.Database)
+	if !ok {
+		return fmt.Errorf("expected a Database but got %T", obj)
+	}
+
+	defaults, err := d.Defaults.Load(ctx)
+	if err != nil {
+		return err
+	}
+	defaults.Apply(database)
+
+	// Every write through this webhook marks the Database as written by this schema, so an
+	// older operator still holding the lease during a rollout leaves it alone
+	stampSchemaRevision(database)
+	return nil
+}
+
+// SetupWebhookWithManager registers the defaulting webhook with the Manager
+func (d *DatabaseDefaulter) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&databasev1.Database{}).
+		WithDefaulter(d.Switches.Defaulter(DatabaseDefaultingWebhook, guardDefaulter(DatabaseDefaultingWebhook, d))).
+		Complete()
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/apitest"
+)
+
+func newDefaultsConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "database-operator-defaults",
+			Namespace: "database-operator-system",
+		},
+		Data: map[string]string{
+			defaultsImageRegistryKey: "registry.example.com/mirror/",
+			defaultsStorageClassKey:  "fast-ssd",
+			defaultsResourcesKey:     "requests: {cpu: 500m, memory: 1Gi}\nlimits: {memory: 2Gi}\n",
+			defaultsPriorityClassKey: "database-critical",
+		},
+	}
+}
+
+func newDefaultsSource(c client.Reader) *DefaultsSource {
+	return &DefaultsSource{Reader: c, Namespace: "database-operator-system", Name: "database-operator-defaults"}
+}
+
+func TestDefaultsSource_Load(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+
+	// A missing ConfigMap means no defaults
+	defaults, err := newDefaultsSource(fake.NewClientBuilder().WithScheme(scheme).Build()).Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, OperatorDefaults{}, defaults)
+
+	source := newDefaultsSource(fake.NewClientBuilder().WithScheme(scheme).WithObjects(newDefaultsConfigMap()).Build())
+	defaults, err = source.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "registry.example.com/mirror/", defaults.ImageRegistry)
+	assert.Equal(t, "fast-ssd", defaults.StorageClass)
+	assert.Equal(t, "database-critical", defaults.PriorityClassName)
+	require.NotNil(t, defaults.Resources)
+	assert.True(t, resource.MustParse("1Gi").Equal(defaults.Resources.Requests[corev1.ResourceMemory]))
+
+	invalid := newDefaultsConfigMap()
+	invalid.Data[defaultsResourcesKey] = "request: {cpu: 1}"
+	source = newDefaultsSource(fake.NewClientBuilder().WithScheme(scheme).WithObjects(invalid).Build())
+	_, err = source.Load(ctx)
+	assert.Error(t, err, "Unknown fields should be rejected rather than silently ignored")
+}
+
+func TestOperatorDefaults_Apply(t *testing.T) {
+	defaults := OperatorDefaults{
+		ImageRegistry:     "registry.example.com/mirror",
+		StorageClass:      "fast-ssd",
+		PriorityClassName: "database-critical",
+		Resources: &corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+		},
+	}
+
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "postgres:15", want: "registry.example.com/mirror/postgres:15"},
+		{image: "bitnami/postgresql:15", want: "registry.example.com/mirror/bitnami/postgresql:15"},
+		{image: "quay.io/postgres:15", want: "quay.io/postgres:15"},
+		{image: "localhost:5000/postgres:15", want: "localhost:5000/postgres:15"},
+		{image: "localhost/postgres:15", want: "localhost/postgres:15"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			database := &databasev1.Database{Spec: databasev1.DatabaseSpec{Image: tt.image}}
+			defaults.Apply(database)
+			assert.Equal(t, tt.want, database.Spec.Image)
+		})
+	}
+
+	// Fields the user set are kept
+	database := &databasev1.Database{Spec: databasev1.DatabaseSpec{
+		Image:             "postgres:15",
+		StorageClass:      "standard",
+		PriorityClassName: "batch",
+		Resources:         &corev1.ResourceRequirements{},
+	}}
+	defaults.Apply(database)
+	assert.Equal(t, "standard", database.Spec.StorageClass)
+	assert.Equal(t, "batch", database.Spec.PriorityClassName)
+	assert.Empty(t, database.Spec.Resources.Limits)
+}
+
+func TestDatabaseDefaulter_Default(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	defaulter := &DatabaseDefaulter{
+		Defaults: newDefaultsSource(fake.NewClientBuilder().WithScheme(scheme).WithObjects(newDefaultsConfigMap()).Build()),
+	}
+
+	database := &databasev1.Database{Spec: databasev1.DatabaseSpec{Image: "postgres:15"}}
+	require.NoError(t, defaulter.Default(context.Background(), database))
+	assert.Equal(t, "registry.example.com/mirror/postgres:15", database.Spec.Image)
+	assert.Equal(t, "fast-ssd", database.Spec.StorageClass)
+	assert.Equal(t, "database-critical", database.Spec.PriorityClassName)
+	assert.NotNil(t, database.Spec.Resources)
+	assert.Equal(t, strconv.Itoa(databasev1.SchemaRevision), database.Annotations[databasev1.SchemaRevisionAnnotation])
+
+	assert.Error(t, defaulter.Default(context.Background(), &corev1.Pod{}))
+}
+
+func TestDatabaseReconciler_AppliesOperatorDefaults(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-db",
+			Namespace:  "default",
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database, newDefaultsConfigMap()).
+		WithStatusSubresource(database).
+		Build()
+
+	reconciler := &DatabaseReconciler{
+		Client:   fakeClient,
+		Scheme:   scheme,
+		Defaults: newDefaultsSource(fakeClient),
+	}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	require.NoError(t, fakeClient.Get(ctx, key, pvc))
+	require.NotNil(t, pvc.Spec.StorageClassName)
+	assert.Equal(t, "fast-ssd", *pvc.Spec.StorageClassName)
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	container := deployment.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "registry.example.com/mirror/postgres:15", container.Image)
+	assert.True(t, resource.MustParse("2Gi").Equal(container.Resources.Limits[corev1.ResourceMemory]))
+	assert.Equal(t, "database-critical", deployment.Spec.Template.Spec.PriorityClassName)
+}
+
+// TestAPITypes round trips every registered type and checks that defaulting, with operator
+// defaults configured, is idempotent and yields Databases the webhook admits
+func TestAPITypes(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newDefaultsConfigMap()).Build()
+
+	apitest.CheckGroupVersion(t, scheme, databasev1.GroupVersion, map[string]apitest.Kind{
+		"Database": {
+			New: func() runtime.Object {
+				return &databasev1.Database{
+					ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "default"},
+					Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+				}
+			},
+			Defaulter: &DatabaseDefaulter{Defaults: newDefaultsSource(fakeClient)},
+			Validator: &DatabaseValidator{},
+		},
+		// Neither defaulted nor validated by a webhook
+		"ClusterDatabasePolicy": {},
+		"SkillsQuota":           {},
+		"DatabaseClass":         {},
+		"Application":           {},
+		"Backup":                {},
+		"Restore":               {},
+	})
+}
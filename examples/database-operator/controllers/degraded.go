@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/health"
+)
+
+// Reasons of the Degraded condition
+const (
+	reasonPartiallyReady   = "PartiallyReady"
+	reasonAllReplicasReady = "AllReplicasReady"
+	reasonNoReplicasReady  = "NoReplicasReady"
+)
+
+// observeDegraded sets the Degraded condition, which has negative polarity: it turns True once
+// the Database has served with only part of its replicas ready for longer than after. A short
+// dip, e.g. during a rolling update, is not Degraded; neither is a Database without any ready
+// replica, which is unavailable and reported by Ready alone.
+//
+// The time the Database has been partially ready is measured from the last transition of
+// Ready to False, so it survives operator restarts without an extra status field. It must run
+// before Ready is updated for the current reconcile.
+func observeDegraded(database *databasev1.Database, readyReplicas int32, after time.Duration, now time.Time) {
+	if after <= 0 {
+		return
+	}
+	desired := database.Spec.Replicas
+
+	switch {
+	case readyReplicas >= desired:
+		if database.GetCondition(health.ConditionDegraded) != nil {
+			database.SetCondition(health.ConditionDegraded, metav1.ConditionFalse, reasonAllReplicasReady,
+				"All replicas are ready")
+		}
+	case readyReplicas == 0:
+		database.SetCondition(health.ConditionDegraded, metav1.ConditionFalse, reasonNoReplicasReady,
+			"No replica is ready; the Database is unavailable rather than degraded")
+	default:
+		since := partiallyReadySince(database, now)
+		message := fmt.Sprintf("%d/%d replicas ready for %s", readyReplicas, desired, now.Sub(since).Round(time.Second))
+		if now.Sub(since) < after {
+			database.SetCondition(health.ConditionDegraded, metav1.ConditionFalse, reasonPartiallyReady,
+				fmt.Sprintf("%s, Degraded after %s", message, after))
+			return
+		}
+		database.SetCondition(health.ConditionDegraded, metav1.ConditionTrue, reasonPartiallyReady, message)
+	}
+}
+
+// partiallyReadySince returns when the Database stopped being fully ready
+func partiallyReadySince(database *databasev1.Database, now time.Time) time.Time {
+	ready := database.GetCondition("Ready")
+	if ready == nil || ready.Status == metav1.ConditionTrue || ready.LastTransitionTime.IsZero() {
+		return now
+	}
+	return ready.LastTransitionTime.Time
+}
+
+// degradedRequeue returns how long until a partially ready Database turns Degraded, so it is
+// reconciled then even when no pod event arrives; zero when no such deadline is pending
+func degradedRequeue(database *databasev1.Database, after time.Duration, now time.Time) time.Duration {
+	degraded := database.GetCondition(health.ConditionDegraded)
+	if after <= 0 || degraded == nil || degraded.Status == metav1.ConditionTrue || degraded.Reason != reasonPartiallyReady {
+		return 0
+	}
+	wait := partiallyReadySince(database, now).Add(after).Sub(now)
+	if wait <= 0 {
+		return time.Second
+	}
+	return wait
+}
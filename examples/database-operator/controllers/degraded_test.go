@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/health"
+)
+
+func TestObserveDegraded(t *testing.T) {
+	now := time.Now()
+	database := &databasev1.Database{Spec: databasev1.DatabaseSpec{Replicas: 3}}
+	database.Status.Conditions = []metav1.Condition{{
+		Type: "Ready", Status: metav1.ConditionFalse, Reason: "Progressing",
+		LastTransitionTime: metav1.NewTime(now.Add(-2 * time.Minute)),
+	}}
+
+	// A replica lost two minutes ago is tolerated for now
+	observeDegraded(database, 2, 5*time.Minute, now)
+	assert.False(t, database.IsDegraded())
+	assert.Equal(t, "2/3 replicas ready for 2m0s, Degraded after 5m0s", database.GetCondition(health.ConditionDegraded).Message)
+	assert.Equal(t, 3*time.Minute, degradedRequeue(database, 5*time.Minute, now))
+
+	observeDegraded(database, 2, 5*time.Minute, now.Add(4*time.Minute))
+	assert.True(t, database.IsDegraded())
+	assert.Equal(t, "2/3 replicas ready for 6m0s", database.GetCondition(health.ConditionDegraded).Message)
+	assert.Zero(t, degradedRequeue(database, 5*time.Minute, now), "Nothing is pending once Degraded")
+	assert.Equal(t, health.InProgress, databasev1.ComputeHealth(database).Status)
+
+	// Without any ready replica the Database is unavailable, which Ready reports
+	observeDegraded(database, 0, 5*time.Minute, now.Add(5*time.Minute))
+	assert.False(t, database.IsDegraded())
+	assert.Equal(t, reasonNoReplicasReady, database.GetCondition(health.ConditionDegraded).Reason)
+
+	observeDegraded(database, 3, 5*time.Minute, now.Add(6*time.Minute))
+	assert.False(t, database.IsDegraded())
+	assert.Equal(t, reasonAllReplicasReady, database.GetCondition(health.ConditionDegraded).Reason)
+
+	// Healthy Databases and disabled detection do not get the condition at all
+	healthy := &databasev1.Database{Spec: databasev1.DatabaseSpec{Replicas: 1}}
+	observeDegraded(healthy, 1, 5*time.Minute, now)
+	assert.Nil(t, healthy.GetCondition(health.ConditionDegraded))
+	observeDegraded(healthy, 0, 0, now)
+	assert.Nil(t, healthy.GetCondition(health.ConditionDegraded))
+}
+
+func TestSetCondition_KeepsTransitionTime(t *testing.T) {
+	database := &databasev1.Database{}
+	database.SetCondition("Ready", metav1.ConditionFalse, "Progressing", "Waiting for replicas: 0/1")
+	since := metav1.NewTime(time.Now().Add(-time.Hour))
+	database.Status.Conditions[0].LastTransitionTime = since
+
+	database.SetCondition("Ready", metav1.ConditionFalse, "Progressing", "Waiting for replicas: 1/3")
+	assert.Equal(t, since, database.GetCondition("Ready").LastTransitionTime, "The status did not change")
+
+	database.SetCondition("Ready", metav1.ConditionTrue, "Ready", "Database is ready")
+	assert.NotEqual(t, since, database.GetCondition("Ready").LastTransitionTime)
+}
+
+func TestDatabaseReconciler_ReportsDegraded(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default", Finalizers: []string{databaseFinalizer}},
+		Spec:       databasev1.DatabaseSpec{Replicas: 3, Image: "postgres:15", Storage: 1024},
+	}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database, &appsv1.Deployment{}).
+		Build()
+	reconciler := &DatabaseReconciler{
+		Client:        fakeClient,
+		Scheme:        scheme,
+		RequeuePolicy: RequeuePolicy{NotReadyInterval: time.Hour},
+		DegradedAfter: 5 * time.Minute,
+	}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	deployment.Status.ReadyReplicas = 2
+	require.NoError(t, fakeClient.Status().Update(ctx, deployment))
+
+	result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.False(t, database.IsDegraded())
+	assert.Equal(t, "Progressing", database.Status.Phase)
+	assert.InDelta(t, 5*time.Minute, result.RequeueAfter, float64(5*time.Second), "Reconciled again when the grace period ends")
+
+	// Ready has been False for longer than the grace period
+	require.NotNil(t, database.GetCondition("Ready"))
+	for i := range database.Status.Conditions {
+		if database.Status.Conditions[i].Type == "Ready" {
+			database.Status.Conditions[i].LastTransitionTime = metav1.NewTime(time.Now().Add(-10 * time.Minute))
+		}
+	}
+	require.NoError(t, fakeClient.Status().Update(ctx, database))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.True(t, database.IsDegraded())
+	assert.Equal(t, "Degraded", database.Status.Phase)
+	assert.Contains(t, database.GetCondition(health.ConditionDegraded).Message, "2/3 replicas ready for 10m")
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// reasonDeprecatedField is the Warning event reason for a Database that sets a deprecated field
+const reasonDeprecatedField = "DeprecatedField"
+
+// deprecatedFieldUsage counts Database writes that set a deprecated field, by field. A field
+// can be removed once its count stops growing.
+var deprecatedFieldUsage = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "database_deprecated_field_usage_total",
+	Help: "Number of Database creates and updates validated with a deprecated field set.",
+}, []string{"field"})
+
+func init() {
+	metrics.Registry.MustRegister(deprecatedFieldUsage)
+}
+
+// deprecatedField is a spec field kept for compatibility and the field replacing it. Each one
+// is marked in the API types with
+//
+//	// +database:deprecated:replacement=<replacement>
+//
+// and the test checks that the markers and this list agree.
+type deprecatedField struct {
+	// path and replacement are the JSON paths of the deprecated field and its replacement
+	path, replacement string
+
+	// isSet reports whether the Database sets the deprecated field
+	isSet func(*databasev1.Database) bool
+
+	// conflicts reports whether the replacement is also set, to a different value
+	conflicts func(*databasev1.Database) bool
+
+	// migrate copies the deprecated value to the replacement unless the replacement is set
+	migrate func(*databasev1.Database)
+}
+
+// deprecatedFields are the deprecated fields of a Database
+var deprecatedFields = []deprecatedField{
+	{
+		path:        "spec.serviceType",
+		replacement: "spec.service.type",
+		isSet:       func(database *databasev1.Database) bool { return database.Spec.ServiceType != "" },
+		conflicts: func(database *databasev1.Database) bool {
+			return database.Spec.Service != nil && database.Spec.Service.Type != "" &&
+				database.Spec.Service.Type != database.Spec.ServiceType
+		},
+		migrate: func(database *databasev1.Database) {
+			if database.Spec.Service == nil {
+				database.Spec.Service = &databasev1.ServiceSpec{}
+			}
+			if database.Spec.Service.Type == "" {
+				database.Spec.Service.Type = database.Spec.ServiceType
+			}
+		},
+	},
+}
+
+// deprecationWarning is the admission warning and event note for a deprecated field
+func (f deprecatedField) deprecationWarning() string {
+	return fmt.Sprintf("%s is deprecated; use %s", f.path, f.replacement)
+}
+
+// checkDeprecatedFields returns an admission warning for each deprecated field the Database
+// sets and counts its use. Setting a deprecated field and its replacement to different values
+// is invalid: the user cannot tell which one wins.
+func checkDeprecatedFields(database *databasev1.Database) (admission.Warnings, field.ErrorList) {
+	var warnings admission.Warnings
+	var errs field.ErrorList
+	for _, f := range deprecatedFields {
+		if !f.isSet(database) {
+			continue
+		}
+		deprecatedFieldUsage.WithLabelValues(f.path).Inc()
+		warnings = append(warnings, f.deprecationWarning())
+		if f.conflicts(database) {
+			errs = append(errs, field.Forbidden(fieldPath(f.path),
+				fmt.Sprintf("must not differ from %s; set only %s", f.replacement, f.replacement)))
+		}
+	}
+	return warnings, errs
+}
+
+// migrateDeprecatedFields copies deprecated fields of the in-memory Database to their
+// replacements, so the rest of the reconcile only reads the replacements. The stored spec is
+// left alone: rewriting it would fight the tools applying the user's manifests. A Warning
+// event is recorded once per generation that sets a deprecated field.
+func (r *DatabaseReconciler) migrateDeprecatedFields(database *databasev1.Database) {
+	for _, f := range deprecatedFields {
+		if !f.isSet(database) {
+			continue
+		}
+		if database.Status.ObservedGeneration != database.Generation {
+			r.warn(database, nil, reasonDeprecatedField, "Migrate", f.deprecationWarning())
+		}
+		f.migrate(database)
+	}
+}
+
+// fieldPath parses a dotted JSON path like spec.service.type
+func fieldPath(path string) *field.Path {
+	names := strings.Split(path, ".")
+	return field.NewPath(names[0], names[1:]...)
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+const deprecatedMarker = "+database:deprecated:replacement="
+
+// TestDeprecatedFieldMarkers checks that every field marked deprecated in the API types has
+// an entry in deprecatedFields, and the other way round
+func TestDeprecatedFieldMarkers(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "../api/v1/postgres_types.go", nil, parser.ParseComments)
+	require.NoError(t, err)
+
+	marked := map[string]string{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.TypeSpec)
+		if !ok || spec.Name.Name != "DatabaseSpec" {
+			return true
+		}
+		for _, f := range spec.Type.(*ast.StructType).Fields.List {
+			if f.Doc == nil || f.Tag == nil {
+				continue
+			}
+			for _, comment := range f.Doc.List {
+				text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+				if replacement, ok := strings.CutPrefix(text, deprecatedMarker); ok {
+					name, _, _ := strings.Cut(reflect.StructTag(strings.Trim(f.Tag.Value, "`")).Get("json"), ",")
+					marked["spec."+name] = replacement
+				}
+			}
+		}
+		return false
+	})
+
+	registered := map[string]string{}
+	for _, f := range deprecatedFields {
+		registered[f.path] = f.replacement
+	}
+	assert.NotEmpty(t, marked)
+	assert.Equal(t, marked, registered)
+}
+
+func TestDatabaseValidator_DeprecatedFields(t *testing.T) {
+	validator := &DatabaseValidator{}
+	ctx := context.Background()
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+	usage := func() float64 { return testutil.ToFloat64(deprecatedFieldUsage.WithLabelValues("spec.serviceType")) }
+
+	// The replacement alone is not counted
+	before := usage()
+	replaced := database.DeepCopy()
+	replaced.Spec.Service = &databasev1.ServiceSpec{Type: corev1.ServiceTypeNodePort}
+	warnings, err := validator.ValidateCreate(ctx, replaced)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, before, usage())
+
+	deprecated := database.DeepCopy()
+	deprecated.Spec.ServiceType = corev1.ServiceTypeNodePort
+	warnings, err = validator.ValidateCreate(ctx, deprecated)
+	require.NoError(t, err)
+	assert.Equal(t, admission.Warnings{"spec.serviceType is deprecated; use spec.service.type"}, warnings)
+	assert.Equal(t, before+1, usage())
+
+	// Both set to the same value is a migration in progress
+	both := deprecated.DeepCopy()
+	both.Spec.Service = &databasev1.ServiceSpec{Type: corev1.ServiceTypeNodePort}
+	warnings, err = validator.ValidateUpdate(ctx, deprecated, both)
+	require.NoError(t, err)
+	assert.Len(t, warnings, 1)
+	assert.Equal(t, before+2, usage())
+
+	both.Spec.Service.Type = corev1.ServiceTypeLoadBalancer
+	_, err = validator.ValidateUpdate(ctx, deprecated, both)
+	require.Error(t, err)
+	assert.True(t, apierrors.IsInvalid(err))
+	assert.Contains(t, err.Error(), "spec.serviceType: Forbidden: must not differ from spec.service.type")
+}
+
+func TestDatabaseReconciler_MigrateDeprecatedFields(t *testing.T) {
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &DatabaseReconciler{Recorder: recorder}
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default", Generation: 2},
+		Spec:       databasev1.DatabaseSpec{ServiceType: corev1.ServiceTypeNodePort},
+		Status:     databasev1.DatabaseStatus{ObservedGeneration: 1},
+	}
+
+	migrated := database.DeepCopy()
+	reconciler.migrateDeprecatedFields(migrated)
+	require.NotNil(t, migrated.Spec.Service)
+	assert.Equal(t, corev1.ServiceTypeNodePort, migrated.Spec.Service.Type)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning DeprecatedField spec.serviceType is deprecated; use spec.service.type")
+
+	// The event is recorded once per generation
+	database.Status.ObservedGeneration = 2
+	reconciler.migrateDeprecatedFields(database.DeepCopy())
+	assert.Empty(t, recorder.Events)
+
+	// A replacement that is set wins
+	database.Spec.Service = &databasev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer}
+	reconciler.migrateDeprecatedFields(database)
+	assert.Equal(t, corev1.ServiceTypeLoadBalancer, database.Spec.Service.Type)
+}
+
+func TestDatabaseReconciler_ReconcileServiceType(t *testing.T) {
+	reconciler, database := newChildTestReconciler(t, 1, interceptor.Funcs{})
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+
+	database.Spec.ServiceType = corev1.ServiceTypeNodePort
+	reconciler.migrateDeprecatedFields(database)
+	require.NoError(t, reconciler.reconcileService(ctx, database))
+	service := &corev1.Service{}
+	require.NoError(t, reconciler.Get(ctx, key, service))
+	assert.Equal(t, corev1.ServiceTypeNodePort, service.Spec.Type)
+
+	database.Spec.ServiceType = ""
+	database.Spec.Service = nil
+	require.NoError(t, reconciler.reconcileService(ctx, database))
+	require.NoError(t, reconciler.Get(ctx, key, service))
+	assert.Equal(t, corev1.ServiceTypeClusterIP, service.Spec.Type)
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+//+kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch;update
+
+// NewEventBroadcaster returns an events.k8s.io/v1 broadcaster for the manager's cluster and
+// adds it to the manager. Unlike mgr.GetEventRecorderFor, which writes core/v1 Events, the
+// events/v1 broadcaster groups repeats of an event into a series: a Database that fails the
+// same way on every retry updates one Event's series.count instead of creating new Events.
+// Repeats are only grouped when the note is identical, so notes must not embed durations,
+// timestamps or counters.
+func NewEventBroadcaster(mgr manager.Manager) (events.EventBroadcaster, error) {
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create events client: %w", err)
+	}
+	broadcaster := events.NewBroadcaster(&events.EventSinkImpl{Interface: clientset.EventsV1()})
+
+	// Not leader-elected: standby replicas record nothing
+	err = mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		if err := broadcaster.StartRecordingToSinkWithContext(ctx); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		broadcaster.Shutdown()
+		return nil
+	}))
+	return broadcaster, err
+}
+
+// warn records a Warning event about the Database. related is the child the event is
+// about, if any, and shows up as the Event's related object.
+func (r *DatabaseReconciler) warn(database *databasev1.Database, related runtime.Object, reason, action, note string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(database, related, corev1.EventTypeWarning, reason, action, "%s", note)
+}
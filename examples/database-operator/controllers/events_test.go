@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestDatabaseReconciler_WarnsOnFailure(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+	}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database).
+		Build()
+
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme, Recorder: recorder}
+
+	_, err := reconciler.setErrorStatus(context.Background(), database, "ServiceCreateFailed", fmt.Errorf("quota exceeded"))
+	require.Error(t, err)
+
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Warning ServiceCreateFailed quota exceeded", <-recorder.Events)
+
+	// Without a recorder events are skipped
+	reconciler.Recorder = nil
+	_, err = reconciler.setErrorStatus(context.Background(), database, "ServiceCreateFailed", fmt.Errorf("quota exceeded"))
+	require.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+
+// conditionFeatureUnavailable is True when a feature the Database asks for was skipped
+// because the cluster does not serve the API it needs
+const conditionFeatureUnavailable = "FeatureUnavailable"
+
+// podDisruptionBudgetGVK is served from Kubernetes 1.21; older clusters only have policy/v1beta1
+var podDisruptionBudgetGVK = policyv1.SchemeGroupVersion.WithKind("PodDisruptionBudget")
+
+// optionalFeature is a Database feature that depends on an API the cluster may not serve.
+// A missing API degrades the Database instead of failing the whole reconcile.
+type optionalFeature struct {
+	name string
+	gvk  schema.GroupVersionKind
+
+	// wanted reports whether the Database uses the feature
+	wanted func(database *databasev1.Database) bool
+
+	reconcile func(ctx context.Context, database *databasev1.Database) error
+}
+
+// optionalFeatures lists the features gated on API availability.
+// Register new features here (e.g. a ServiceMonitor or VolumeSnapshot schedule) and add
+// their list type to ownedKinds so that they are pruned when no longer wanted.
+func (r *DatabaseReconciler) optionalFeatures() []optionalFeature {
+	return []optionalFeature{
+		{
+			name:      "PodDisruptionBudget",
+			gvk:       podDisruptionBudgetGVK,
+			wanted:    func(database *databasev1.Database) bool { return database.Spec.Replicas > 1 },
+			reconcile: r.reconcilePodDisruptionBudget,
+		},
+	}
+}
+
+// apiAvailable reports whether the cluster serves gvk.
+// The manager's RESTMapper re-runs discovery on a miss, so APIs installed later are picked up.
+func apiAvailable(mapper meta.RESTMapper, gvk schema.GroupVersionKind) (bool, error) {
+	_, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// reconcileOptionalFeatures reconciles the wanted features whose APIs are served
+// and records the skipped ones in the FeatureUnavailable condition
+func (r *DatabaseReconciler) reconcileOptionalFeatures(ctx context.Context, database *databasev1.Database) error {
+	var unavailable []string
+
+	for _, feature := range r.optionalFeatures() {
+		if !feature.wanted(database) {
+			continue
+		}
+
+		available, err := apiAvailable(r.RESTMapper(), feature.gvk)
+		if err != nil {
+			return fmt.Errorf("failed to discover %s: %w", feature.gvk, err)
+		}
+		if !available {
+			unavailable = append(unavailable, fmt.Sprintf("%s (%s not served)", feature.name, feature.gvk.GroupVersion()))
+			continue
+		}
+
+		if err := feature.reconcile(ctx, database); err != nil {
+			return fmt.Errorf("failed to reconcile %s: %w", feature.name, err)
+		}
+	}
+
+	if len(unavailable) > 0 {
+		database.SetCondition(conditionFeatureUnavailable, metav1.ConditionTrue, "APINotServed",
+			"Skipped features: "+strings.Join(unavailable, ", "))
+	} else if database.GetCondition(conditionFeatureUnavailable) != nil {
+		database.SetCondition(conditionFeatureUnavailable, metav1.ConditionFalse, "AllFeaturesAvailable",
+			"All requested features are available")
+	}
+	return nil
+}
+
+// reconcilePodDisruptionBudget keeps voluntary disruptions (node drains) from taking down
+// more than one replica of a replicated Database at a time
+func (r *DatabaseReconciler) reconcilePodDisruptionBudget(ctx context.Context, database *databasev1.Database) error {
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podDisruptionBudgetName(database),
+			Namespace: database.Namespace,
+		},
+	}
+
+	_, err := r.createOrPatch(ctx, pdb, func() error {
+		maxUnavailable := intstr.FromInt32(1)
+		pdb.Spec.MaxUnavailable = &maxUnavailable
+		pdb.Spec.Selector = &metav1.LabelSelector{
+			MatchLabels: selectorLabels(database),
+		}
+		r.Propagation.apply(database, pdb)
+		return controllerutil.SetControllerReference(database, pdb, r.Scheme)
+	})
+
+	return err
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func newReplicatedDatabase() *databasev1.Database {
+	return &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-db",
+			Namespace: "default",
+			UID:       "test-uid",
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 3,
+			Image:    "postgres:15",
+			Storage:  1024,
+		},
+	}
+}
+
+func TestReconcileOptionalFeatures_APINotServed(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	require.NoError(t, policyv1.AddToScheme(scheme))
+
+	// The fake client's default RESTMapper is empty, like a cluster that serves no policy/v1
+	database := newReplicatedDatabase()
+	reconciler := &DatabaseReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(database).Build(),
+		Scheme: scheme,
+	}
+
+	ctx := context.Background()
+	require.NoError(t, reconciler.reconcileOptionalFeatures(ctx, database), "A missing API should not fail the reconcile")
+
+	condition := database.GetCondition(conditionFeatureUnavailable)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "APINotServed", condition.Reason)
+	assert.Contains(t, condition.Message, "PodDisruptionBudget")
+}
+
+func TestReconcileOptionalFeatures_CreatesPodDisruptionBudget(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	require.NoError(t, policyv1.AddToScheme(scheme))
+
+	database := newReplicatedDatabase()
+	database.SetCondition(conditionFeatureUnavailable, metav1.ConditionTrue, "APINotServed", "previously skipped")
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(podDisruptionBudgetGVK, meta.RESTScopeNamespace)
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRESTMapper(mapper).
+		WithObjects(database).
+		Build()
+	reconciler := &DatabaseReconciler{
+		Client: fakeClient,
+		Scheme: scheme,
+	}
+
+	ctx := context.Background()
+	require.NoError(t, reconciler.reconcileOptionalFeatures(ctx, database))
+
+	pdb := &policyv1.PodDisruptionBudget{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-db", Namespace: "default"}, pdb))
+	assert.Equal(t, int32(1), pdb.Spec.MaxUnavailable.IntVal)
+	assert.True(t, metav1.IsControlledBy(pdb, database))
+
+	condition := database.GetCondition(conditionFeatureUnavailable)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status, "The condition should clear once the API is served")
+
+	// Scaling down to a single replica removes the budget
+	database.Spec.Replicas = 1
+	require.NoError(t, reconciler.pruneChildren(ctx, database))
+	pdbs := &policyv1.PodDisruptionBudgetList{}
+	require.NoError(t, fakeClient.List(ctx, pdbs))
+	assert.Empty(t, pdbs.Items)
+}
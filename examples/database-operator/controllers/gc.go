@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// ownedKinds is the registry of child kinds the Database controller creates and may need to prune.
+// Register new child kinds here when adding optional features, so that disabling a feature
+// removes its objects. PersistentVolumeClaims are deliberately absent: they hold data and
+// must never be deleted as a side effect of a spec change.
+var ownedKinds = []func() client.ObjectList{
+	func() client.ObjectList { return &appsv1.DeploymentList{} },
+	func() client.ObjectList { return &appsv1.StatefulSetList{} },
+	func() client.ObjectList { return &corev1.ServiceList{} },
+	func() client.ObjectList { return &corev1.SecretList{} },
+	func() client.ObjectList { return &corev1.ConfigMapList{} },
+	func() client.ObjectList { return &policyv1.PodDisruptionBudgetList{} },
+	func() client.ObjectList { return &batchv1.JobList{} },
+}
+
+// childKey identifies a child object by kind and name within the owner's namespace
+type childKey struct {
+	kind schema.GroupKind
+	name string
+}
+
+// pruneOwned deletes objects of the registered kinds that are controlled by owner but are not
+// in desired. Delete options such as client.PropagationPolicy are passed through to every delete.
+// It returns the objects that were deleted; they are shared with the cache and must not be changed.
+func pruneOwned(ctx context.Context, c client.Client, scheme *runtime.Scheme, owner client.Object,
+	kinds []func() client.ObjectList, desired []client.Object, opts ...client.DeleteOption) ([]client.Object, error) {
+	keep := make(map[childKey]bool, len(desired))
+	for _, obj := range desired {
+		gvk, err := apiutil.GVKForObject(obj, scheme)
+		if runtime.IsNotRegisteredError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keep[childKey{kind: gvk.GroupKind(), name: obj.GetName()}] = true
+	}
+
+	var pruned []client.Object
+	for _, newList := range kinds {
+		list := newList()
+		// Every child of the namespace is listed on every reconcile but only its owner is read,
+		// so the cached objects are not copied
+		if err := c.List(ctx, list, client.InNamespace(owner.GetNamespace()), client.UnsafeDisableDeepCopy); err != nil {
+			if meta.IsNoMatchError(err) || runtime.IsNotRegisteredError(err) {
+				// Optional API not served by this cluster or not registered in the scheme,
+				// so nothing of this kind was created
+				continue
+			}
+			return pruned, fmt.Errorf("failed to list owned objects: %w", err)
+		}
+
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return pruned, err
+		}
+
+		for _, item := range items {
+			obj, ok := item.(client.Object)
+			if !ok || !metav1.IsControlledBy(obj, owner) {
+				continue
+			}
+
+			gvk, err := apiutil.GVKForObject(obj, scheme)
+			if err != nil {
+				return pruned, err
+			}
+			if keep[childKey{kind: gvk.GroupKind(), name: obj.GetName()}] {
+				continue
+			}
+
+			if err := c.Delete(ctx, obj, opts...); client.IgnoreNotFound(err) != nil {
+				return pruned, fmt.Errorf("failed to delete %s %s: %w", gvk.Kind, obj.GetName(), err)
+			}
+			log.FromContext(ctx).Info("Pruned child that is no longer desired", "kind", gvk.Kind, "name", obj.GetName())
+			pruned = append(pruned, obj)
+		}
+	}
+
+	return pruned, nil
+}
+
+// desiredChildren returns the children a Database should currently own
+func desiredChildren(database *databasev1.Database) []client.Object {
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: database.Namespace}
+	}
+
+	desired := []client.Object{
+		&corev1.Service{ObjectMeta: objectMeta(serviceName(database))},
+		&corev1.Secret{ObjectMeta: objectMeta(passwordSecretName(database))},
+	}
+	// During a migration both workloads exist
+	if database.Status.Workload != databasev1.WorkloadStatefulSet {
+		desired = append(desired, &appsv1.Deployment{ObjectMeta: objectMeta(deploymentName(database))})
+	}
+	if database.Spec.Workload == databasev1.WorkloadStatefulSet {
+		desired = append(desired, &appsv1.StatefulSet{ObjectMeta: objectMeta(statefulSetName(database))})
+	}
+	if database.Spec.HighAvailability {
+		desired = append(desired, &corev1.Service{ObjectMeta: objectMeta(headlessServiceName(database))})
+	}
+	// Copies of the operator's pull secrets; referenced Secrets the Database does not
+	// control are never pruned anyway
+	for _, secret := range database.Spec.ImagePullSecrets {
+		desired = append(desired, &corev1.Secret{ObjectMeta: objectMeta(secret.Name)})
+	}
+	if database.Spec.ConfigMapName != "" {
+		desired = append(desired, &corev1.ConfigMap{ObjectMeta: objectMeta(database.Spec.ConfigMapName)})
+	}
+	if monitoringEnabled(database) {
+		desired = append(desired, &corev1.ConfigMap{ObjectMeta: objectMeta(dashboardName(database))})
+	}
+	if database.Spec.Replicas > 1 {
+		desired = append(desired, &policyv1.PodDisruptionBudget{ObjectMeta: objectMeta(podDisruptionBudgetName(database))})
+	}
+	if maintenance := database.Spec.Maintenance; maintenance != nil {
+		for _, task := range maintenance.Tasks {
+			desired = append(desired, &batchv1.Job{ObjectMeta: objectMeta(maintenanceJobName(database, task.Type))})
+		}
+	}
+	return desired
+}
+
+// pruneChildren removes children that are no longer desired, e.g. a ConfigMap
+// left behind after spec.configMapName was cleared or renamed
+func (r *DatabaseReconciler) pruneChildren(ctx context.Context, database *databasev1.Database) error {
+	_, err := pruneOwned(ctx, r.Client, r.Scheme, database, ownedKinds, desiredChildren(database),
+		client.PropagationPolicy(metav1.DeletePropagationBackground))
+	return err
+}
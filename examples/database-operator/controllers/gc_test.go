@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestDatabaseReconciler_PruneChildren(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	// spec.configMapName has been cleared, so the owned ConfigMap is no longer desired
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-db",
+			Namespace: "default",
+			UID:       "test-uid",
+		},
+	}
+
+	owned := func(obj client.Object) client.Object {
+		require.NoError(t, controllerutil.SetControllerReference(database, obj, scheme))
+		return obj
+	}
+
+	staleConfigMap := owned(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "old-config", Namespace: "default"}})
+	deployment := owned(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"}})
+	foreignConfigMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "user-config", Namespace: "default"}}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database, staleConfigMap, deployment, foreignConfigMap).
+		Build()
+
+	reconciler := &DatabaseReconciler{
+		Client: fakeClient,
+		Scheme: scheme,
+	}
+
+	ctx := context.Background()
+	require.NoError(t, reconciler.pruneChildren(ctx, database))
+
+	err := fakeClient.Get(ctx, types.NamespacedName{Name: "old-config", Namespace: "default"}, &corev1.ConfigMap{})
+	assert.True(t, errors.IsNotFound(err), "Owned ConfigMap that is no longer desired should be pruned")
+
+	err = fakeClient.Get(ctx, types.NamespacedName{Name: "test-db", Namespace: "default"}, &appsv1.Deployment{})
+	assert.NoError(t, err, "Desired children should be kept")
+
+	err = fakeClient.Get(ctx, types.NamespacedName{Name: "user-config", Namespace: "default"}, &corev1.ConfigMap{})
+	assert.NoError(t, err, "Objects not controlled by the Database should be kept")
+}
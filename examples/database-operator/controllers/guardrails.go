@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// Bounds of the status lists. They match the MaxItems markers of DatabaseStatus, so the API
+// server rejects a status write over them; pruneStatus keeps the operator's writes within.
+const (
+	maxStatusConditions  = 32
+	maxStatusPods        = 200
+	maxStatusHistory     = 100
+	maxStatusZones       = 64
+	maxStatusSlowQueries = 50
+)
+
+// conditionMessageLimit bounds condition messages, which often hold wrapped API errors. The
+// schema allows 32KiB per message, so a few failing children could fill the object.
+const conditionMessageLimit = 4096
+
+// maxStatusBytes is the size status may take up. Objects are stored whole in etcd, which
+// rejects writes over 1.5MiB, and the spec and metadata need the rest.
+const maxStatusBytes = 256 << 10
+
+// maxDatabaseBytes is the size a Database may have without its status, including kubectl's
+// last-applied annotation, which repeats the spec. Children copy at most the Database's
+// propagated labels and annotations and its spec, so they stay within the limit too.
+const maxDatabaseBytes = 512 << 10
+
+// pruneStatus keeps the status within its bounds before it is written. Conditions that have
+// not changed for the longest are dropped first, never Ready; history is dropped oldest
+// first, before anything else, if the status is still too large.
+func pruneStatus(database *databasev1.Database) {
+	status := &database.Status
+
+	for i := range status.Conditions {
+		status.Conditions[i].Message = truncate(status.Conditions[i].Message, conditionMessageLimit)
+	}
+	if excess := len(status.Conditions) - maxStatusConditions; excess > 0 {
+		conditions := append(status.Conditions[:0:0], status.Conditions...)
+		sort.SliceStable(conditions, func(i, j int) bool {
+			if (conditions[i].Type == "Ready") != (conditions[j].Type == "Ready") {
+				return conditions[j].Type == "Ready"
+			}
+			return conditions[i].LastTransitionTime.Before(&conditions[j].LastTransitionTime)
+		})
+		dropped := map[string]bool{}
+		for _, condition := range conditions[:excess] {
+			dropped[condition.Type] = true
+		}
+		kept := status.Conditions[:0]
+		for _, condition := range status.Conditions {
+			if !dropped[condition.Type] {
+				kept = append(kept, condition)
+			}
+		}
+		status.Conditions = kept
+	}
+
+	if len(status.Pods) > maxStatusPods {
+		status.Pods = status.Pods[:maxStatusPods]
+	}
+	if len(status.History) > maxStatusHistory {
+		status.History = status.History[:maxStatusHistory]
+	}
+	if len(status.Zones) > maxStatusZones {
+		status.Zones = status.Zones[:maxStatusZones]
+	}
+	if status.Stats != nil && len(status.Stats.SlowQueries) > maxStatusSlowQueries {
+		status.Stats.SlowQueries = status.Stats.SlowQueries[:maxStatusSlowQueries]
+	}
+
+	for len(status.History) > 0 && statusSize(status) > maxStatusBytes {
+		status.History = status.History[:len(status.History)-1]
+	}
+	if status.Stats != nil && statusSize(status) > maxStatusBytes {
+		status.Stats.SlowQueries = nil
+	}
+}
+
+// statusSize is the encoded size of a status
+func statusSize(status *databasev1.DatabaseStatus) int {
+	buf := getEncodeBuffer()
+	defer buf.put()
+	data, err := buf.encodeJSON(status)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// validateSize rejects a Database too large to leave room for its status in etcd
+func validateSize(database *databasev1.Database) field.ErrorList {
+	// Only fields of the copy itself are cleared, so a shallow copy leaves the Database intact
+	stripped := *database
+	stripped.Status = databasev1.DatabaseStatus{}
+	stripped.ManagedFields = nil
+	buf := getEncodeBuffer()
+	defer buf.put()
+	data, err := buf.encodeJSON(&stripped)
+	if err != nil || len(data) <= maxDatabaseBytes {
+		return nil
+	}
+	return field.ErrorList{field.Forbidden(field.NewPath("spec"), fmt.Sprintf(
+		"the Database takes %d bytes without its status, more than the %d allowed to keep it and its status within the etcd object size limit",
+		len(data), maxDatabaseBytes))}
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestPruneStatus(t *testing.T) {
+	database := &databasev1.Database{}
+	start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	database.Status.Conditions = append(database.Status.Conditions, metav1.Condition{
+		Type: "Ready", Status: metav1.ConditionFalse, Message: strings.Repeat("x", 10000),
+		LastTransitionTime: metav1.NewTime(start),
+	})
+	for i := 0; i < maxStatusConditions+2; i++ {
+		database.Status.Conditions = append(database.Status.Conditions, metav1.Condition{
+			Type: fmt.Sprintf("Condition%d", i), LastTransitionTime: metav1.NewTime(start.Add(time.Duration(i+1) * time.Minute)),
+		})
+	}
+	for i := 0; i < maxStatusHistory+10; i++ {
+		database.Status.History = append(database.Status.History, databasev1.ReconcileRecord{Outcome: fmt.Sprint(i)})
+	}
+	database.Status.Stats = &databasev1.DatabaseStats{SlowQueries: make([]databasev1.SlowQuery, maxStatusSlowQueries+1)}
+
+	pruneStatus(database)
+	require.Len(t, database.Status.Conditions, maxStatusConditions)
+	assert.Equal(t, "Ready", database.Status.Conditions[0].Type, "Ready is kept even though it is the oldest")
+	assert.Len(t, database.Status.Conditions[0].Message, conditionMessageLimit)
+	assert.Equal(t, "Condition3", database.Status.Conditions[1].Type, "The conditions unchanged for the longest are dropped")
+	require.Len(t, database.Status.History, maxStatusHistory)
+	assert.Equal(t, "0", database.Status.History[0].Outcome, "The newest records are kept")
+	assert.Len(t, database.Status.Stats.SlowQueries, maxStatusSlowQueries)
+
+	// Records are dropped, oldest first, until the status fits
+	for i := range database.Status.History {
+		database.Status.History[i].Error = strings.Repeat("e", 4096)
+	}
+	pruneStatus(database)
+	assert.LessOrEqual(t, statusSize(&database.Status), maxStatusBytes)
+	assert.NotEmpty(t, database.Status.History)
+	assert.Equal(t, "0", database.Status.History[0].Outcome)
+}
+
+func TestDatabaseValidator_Size(t *testing.T) {
+	validator := &DatabaseValidator{}
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-db",
+			Namespace:   "default",
+			Annotations: map[string]string{lastAppliedAnnotation: strings.Repeat("x", maxDatabaseBytes/2)},
+		},
+		Spec: databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+	_, err := validator.ValidateCreate(context.Background(), database)
+	assert.NoError(t, err)
+
+	database.Annotations["team.example.com/notes"] = strings.Repeat("x", maxDatabaseBytes/2)
+	_, err = validator.ValidateCreate(context.Background(), database)
+	require.Error(t, err)
+	assert.True(t, apierrors.IsInvalid(err))
+	assert.Contains(t, err.Error(), "etcd object size limit")
+}
+
+// BenchmarkPruneStatus measures pruning a full status, which every status write does.
+// Run with: go test -run '^$' -bench PruneStatus -benchmem ./controllers/
+func BenchmarkPruneStatus(b *testing.B) {
+	database := newHotPathDatabase()
+	start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	for _, conditionType := range []string{"Ready", conditionConverged, conditionRolloutStalled, conditionStalled} {
+		database.SetCondition(conditionType, metav1.ConditionTrue, "Ready", "Database is ready")
+	}
+	for i := int32(0); i < database.Spec.Replicas; i++ {
+		database.Status.Pods = append(database.Status.Pods, databasev1.DatabasePodStatus{
+			Name: fmt.Sprintf("orders-5d1e0f7a-%d", i), Ready: true, Node: fmt.Sprintf("node-%d", i),
+		})
+	}
+	for i := 0; i < maxStatusHistory; i++ {
+		database.Status.History = append(database.Status.History, databasev1.ReconcileRecord{
+			Time: metav1.NewTime(start.Add(time.Duration(i) * time.Minute)), Outcome: "Ready", Reason: "Ready",
+			Duration: metav1.Duration{Duration: 40 * time.Millisecond}, Trigger: triggerEvent, Count: 3,
+		})
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		pruneStatus(database)
+	}
+}
+
+// BenchmarkValidateSize measures the size check of the validating webhook
+func BenchmarkValidateSize(b *testing.B) {
+	database := newHotPathDatabase()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		validateSize(database)
+	}
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/naming"
+)
+
+// Values of roleLabel on the pods of a highly available Database
+const (
+	primaryRole = "primary"
+	replicaRole = "replica"
+)
+
+// initdbMountPath is where the postgres image runs scripts after it initialized an empty data
+// directory
+const initdbMountPath = "/docker-entrypoint-initdb.d"
+
+// replicaBootstrapScript clones the primary into the empty data directory of a replica, and
+// gives the primary the hook that lets replicas connect. The pod index is the suffix of the
+// StatefulSet pod name; the first pod initializes its data directory itself.
+// --write-recovery-conf writes standby.signal and the primary_conninfo the replica streams from.
+const replicaBootstrapScript = `set -eu
+printf '%s\n' 'echo "host replication all all scram-sha-256" >> "$PGDATA/pg_hba.conf"' > ` + initdbMountPath + `/replication.sh
+if [ "${HOSTNAME##*-}" = 0 ] || [ -s "$PGDATA/PG_VERSION" ]; then
+  exit 0
+fi
+pg_basebackup --pgdata="$PGDATA" --wal-method=stream --write-recovery-conf --checkpoint=fast
+`
+
+// headlessServiceName is the name of the headless Service that gives the pods of a highly
+// available Database their DNS names
+func headlessServiceName(database *databasev1.Database) string {
+	return naming.Label(database.Name, "headless")
+}
+
+// primaryPodName is the name of the primary pod of a highly available Database
+func primaryPodName(database *databasev1.Database) string {
+	return statefulSetName(database) + "-0"
+}
+
+// primaryHost is the DNS name replicas reach the primary at
+func primaryHost(database *databasev1.Database) string {
+	return primaryPodName(database) + "." + headlessServiceName(database)
+}
+
+// primarySelector selects the primary pod of a highly available Database
+func primarySelector(database *databasev1.Database) map[string]string {
+	labels := statefulSetSelector(database)
+	labels[roleLabel] = primaryRole
+	return labels
+}
+
+// reconcileHighAvailability runs a highly available Database: a headless Service, a
+// StatefulSet claiming a volume per pod, and the roles of its pods. The first pod is the
+// primary and the others replicate from it; there is no automatic failover, a lost primary
+// is recreated on its volume by the StatefulSet controller.
+func (r *DatabaseReconciler) reconcileHighAvailability(ctx context.Context, database *databasev1.Database) error {
+	// Only possible with the webhook disabled: the data of the single volume is not moved
+	if database.Status.Workload == databasev1.WorkloadDeployment || migrationReason(database) != "" {
+		return errors.NewBadRequest("highAvailability can only be set when a Database is created")
+	}
+	existing := &appsv1.StatefulSet{}
+	if err := r.Get(ctx, client.ObjectKey{Name: statefulSetName(database), Namespace: database.Namespace}, existing); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if existing.Name != "" && len(existing.Spec.VolumeClaimTemplates) == 0 {
+		return errors.NewBadRequest("highAvailability can only be set when a Database is created")
+	}
+
+	if err := r.reconcileHeadlessService(ctx, database); err != nil {
+		return err
+	}
+	if err := r.applyStatefulSet(ctx, database, database.Spec.Replicas); err != nil {
+		return err
+	}
+	database.Status.Workload = databasev1.WorkloadStatefulSet
+	return r.labelRoles(ctx, database)
+}
+
+// reconcileHeadlessService creates or updates the headless Service of a highly available
+// Database. Not ready pods are published too: a replica resolves the primary before it is
+// ready itself.
+func (r *DatabaseReconciler) reconcileHeadlessService(ctx context.Context, database *databasev1.Database) error {
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+		Name:      headlessServiceName(database),
+		Namespace: database.Namespace,
+	}}
+	_, err := r.createOrPatch(ctx, service, func() error {
+		service.Spec.ClusterIP = corev1.ClusterIPNone
+		service.Spec.PublishNotReadyAddresses = true
+		service.Spec.Selector = statefulSetSelector(database)
+		service.Spec.Ports = []corev1.ServicePort{{
+			Name:       "postgres",
+			Port:       5432,
+			TargetPort: intstr.FromInt(5432),
+			Protocol:   corev1.ProtocolTCP,
+		}}
+		r.Propagation.apply(database, service)
+		setDatabaseLabel(service, database)
+		return controllerutil.SetControllerReference(database, service, r.Scheme)
+	})
+	return err
+}
+
+// highAvailabilityPodSpec turns the pod spec of a Database into the one of a highly available
+// Database: the data volume comes from the claim template, and an init container bootstraps
+// replicas from the primary
+func highAvailabilityPodSpec(database *databasev1.Database, podSpec corev1.PodSpec) corev1.PodSpec {
+	volumes := make([]corev1.Volume, 0, len(podSpec.Volumes))
+	for _, volume := range podSpec.Volumes {
+		if volume.Name != "data" {
+			volumes = append(volumes, volume)
+		}
+	}
+	podSpec.Volumes = append(volumes, corev1.Volume{
+		Name:         "initdb",
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	})
+
+	containers := make([]corev1.Container, len(podSpec.Containers))
+	copy(containers, podSpec.Containers)
+	for i := range containers {
+		if containers[i].Name == databaseContainer {
+			mounts := append([]corev1.VolumeMount{}, containers[i].VolumeMounts...)
+			containers[i].VolumeMounts = append(mounts, corev1.VolumeMount{Name: "initdb", MountPath: initdbMountPath})
+		}
+	}
+	podSpec.Containers = containers
+
+	allowPrivilegeEscalation := false
+	uid := postgresUID
+	bootstrap := corev1.Container{
+		Name:    "replica-bootstrap",
+		Image:   database.Spec.Image,
+		Command: []string{"sh", "-c", replicaBootstrapScript},
+		Env: []corev1.EnvVar{
+			{Name: "PGDATA", Value: pgdata},
+			// Replicas connect as the database user, a superuser of the postgres image
+			{Name: "PGHOST", Value: primaryHost(database)},
+			{Name: "PGUSER", Value: database.Spec.UserName},
+			{Name: "PGPASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: passwordSecretName(database)},
+				Key:                  "password",
+			}}},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "data", MountPath: pgdata},
+			{Name: "initdb", MountPath: initdbMountPath},
+		},
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+			RunAsUser:                &uid,
+		},
+	}
+	if len(containers) > 0 {
+		bootstrap.Resources = containers[0].Resources
+	}
+	podSpec.InitContainers = append([]corev1.Container{bootstrap}, podSpec.InitContainers...)
+	return podSpec
+}
+
+// dataClaimTemplates are the volume claim templates of a highly available Database, one
+// volume per pod. Claim templates cannot change, so later changes to spec.storage or
+// spec.storageClass do not reach existing volumes.
+func dataClaimTemplates(database *databasev1.Database) []corev1.PersistentVolumeClaim {
+	return []corev1.PersistentVolumeClaim{{
+		ObjectMeta: metav1.ObjectMeta{Name: "data"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(fmt.Sprintf("%dMi", database.Spec.Storage)),
+				},
+			},
+			StorageClassName: &database.Spec.StorageClass,
+		},
+	}}
+}
+
+// labelRoles labels the pods of a highly available Database with their role, which the
+// Service selects the primary by, and records the primary in the status. The pods are shared
+// with the cache, so each label is patched on a copy.
+func (r *DatabaseReconciler) labelRoles(ctx context.Context, database *databasev1.Database) error {
+	pods, err := r.listDatabasePods(ctx, database)
+	if err != nil {
+		return err
+	}
+	database.Status.Primary = ""
+	for i := range pods {
+		pod := &pods[i]
+		role := replicaRole
+		if pod.Name == primaryPodName(database) {
+			role = primaryRole
+			database.Status.Primary = pod.Name
+		}
+		if pod.Labels[roleLabel] == role {
+			continue
+		}
+		labeled := pod.DeepCopy()
+		labeled.Labels[roleLabel] = role
+		if err := r.Patch(ctx, labeled, client.MergeFrom(pod)); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to label pod %s as %s: %w", pod.Name, role, err)
+		}
+	}
+	return nil
+}
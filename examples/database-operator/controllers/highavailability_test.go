@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/readonly"
+)
+
+// highAvailabilityFixture is a highly available Database with three pods running. The pods
+// are read through a Guard, like the uncopied pod list of the operator.
+func highAvailabilityFixture(t *testing.T, objects ...client.Object) (*DatabaseReconciler, *readonly.Guard, *databasev1.Database) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "default", UID: "orders-uid"},
+		Spec: databasev1.DatabaseSpec{Replicas: 3, Image: "postgres:15", Storage: 1024, StorageClass: "fast",
+			UserName: "app", Workload: databasev1.WorkloadStatefulSet, HighAvailability: true},
+	}
+	objects = append(objects, database)
+	for _, name := range []string{"orders-0", "orders-1", "orders-2"} {
+		objects = append(objects, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: name, Namespace: "default", Labels: statefulSetSelector(database),
+		}})
+	}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(database).
+		Build()
+	guard := readonly.NewGuard(fakeClient)
+	return &DatabaseReconciler{Client: guard, Scheme: scheme}, guard, database
+}
+
+func TestDatabaseReconciler_HighAvailability(t *testing.T) {
+	reconciler, guard, database := highAvailabilityFixture(t)
+	ctx := context.Background()
+
+	require.NoError(t, reconciler.reconcilePVC(ctx, database))
+	require.NoError(t, reconciler.reconcileService(ctx, database))
+	require.NoError(t, reconciler.reconcileWorkload(ctx, database))
+	assert.True(t, errors.IsNotFound(guard.Get(ctx, client.ObjectKey{Name: pvcName(database), Namespace: "default"}, &corev1.PersistentVolumeClaim{})),
+		"Each pod claims its own volume")
+	assert.Equal(t, databasev1.WorkloadStatefulSet, database.Status.Workload)
+
+	statefulSet := &appsv1.StatefulSet{}
+	require.NoError(t, guard.Get(ctx, client.ObjectKey{Name: "orders", Namespace: "default"}, statefulSet))
+	assert.Equal(t, int32(3), *statefulSet.Spec.Replicas)
+	assert.Equal(t, "orders-headless", statefulSet.Spec.ServiceName)
+	require.Len(t, statefulSet.Spec.VolumeClaimTemplates, 1)
+	claim := statefulSet.Spec.VolumeClaimTemplates[0]
+	assert.Equal(t, "data", claim.Name)
+	assert.Equal(t, "1Gi", claim.Spec.Resources.Requests.Storage().String())
+	assert.Equal(t, "fast", *claim.Spec.StorageClassName)
+	podSpec := statefulSet.Spec.Template.Spec
+	for _, volume := range podSpec.Volumes {
+		assert.Nil(t, volume.PersistentVolumeClaim, "The data volume comes from the claim template")
+	}
+	require.Len(t, podSpec.InitContainers, 1)
+	bootstrap := podSpec.InitContainers[0]
+	assert.Equal(t, "replica-bootstrap", bootstrap.Name)
+	assert.Equal(t, "postgres:15", bootstrap.Image)
+	assert.Contains(t, bootstrap.Env, corev1.EnvVar{Name: "PGHOST", Value: "orders-0.orders-headless"})
+	assert.Contains(t, bootstrap.Env, corev1.EnvVar{Name: "PGUSER", Value: "app"})
+	assert.Contains(t, podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: "initdb", MountPath: initdbMountPath})
+
+	headless := &corev1.Service{}
+	require.NoError(t, guard.Get(ctx, client.ObjectKey{Name: "orders-headless", Namespace: "default"}, headless))
+	assert.Equal(t, corev1.ClusterIPNone, headless.Spec.ClusterIP)
+	assert.True(t, headless.Spec.PublishNotReadyAddresses)
+	assert.Equal(t, statefulSetSelector(database), headless.Spec.Selector)
+	assert.True(t, metav1.IsControlledBy(headless, database))
+
+	service := &corev1.Service{}
+	require.NoError(t, guard.Get(ctx, client.ObjectKey{Name: "orders", Namespace: "default"}, service))
+	assert.Equal(t, primaryRole, service.Spec.Selector[roleLabel], "Clients reach the primary")
+
+	// The first pod is the primary
+	assert.Equal(t, "orders-0", database.Status.Primary)
+	for name, role := range map[string]string{"orders-0": primaryRole, "orders-1": replicaRole, "orders-2": replicaRole} {
+		pod := &corev1.Pod{}
+		require.NoError(t, guard.Get(ctx, client.ObjectKey{Name: name, Namespace: "default"}, pod))
+		assert.Equal(t, role, pod.Labels[roleLabel], name)
+	}
+	guard.Check(t)
+
+	// A lost primary is reported until the StatefulSet recreates it
+	require.NoError(t, guard.Delete(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "orders-0", Namespace: "default"}}))
+	require.NoError(t, reconciler.reconcileWorkload(ctx, database))
+	assert.Empty(t, database.Status.Primary)
+}
+
+func TestDatabaseReconciler_HighAvailabilityAfterCreation(t *testing.T) {
+	// Only possible with the webhook disabled
+	existing := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "default"}}
+	reconciler, _, database := highAvailabilityFixture(t, existing)
+
+	err := reconciler.reconcileWorkload(context.Background(), database)
+	assert.True(t, errors.IsBadRequest(err), "unexpected error %v", err)
+
+	database.Status.Workload = databasev1.WorkloadDeployment
+	err = reconciler.reconcileWorkload(context.Background(), database)
+	assert.True(t, errors.IsBadRequest(err), "unexpected error %v", err)
+}
+
+func TestDesiredChildren_HighAvailability(t *testing.T) {
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "default"},
+		Spec:       databasev1.DatabaseSpec{Workload: databasev1.WorkloadStatefulSet, HighAvailability: true},
+		Status:     databasev1.DatabaseStatus{Workload: databasev1.WorkloadStatefulSet},
+	}
+	var names []string
+	for _, child := range desiredChildren(database) {
+		names = append(names, child.GetName())
+	}
+	assert.Contains(t, names, "orders-headless")
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// Triggers recorded in status.history
+const (
+	triggerCreated             = "Created"
+	triggerSpecChanged         = "SpecChanged"
+	triggerDesiredStateChanged = "DesiredStateChanged"
+	triggerEvent               = "Event"
+)
+
+// historyErrorLimit bounds the error stored per record; the Ready condition keeps the full
+// message of the latest failure
+const historyErrorLimit = 256
+
+type reconcileAttemptKey struct{}
+
+// reconcileAttempt is what the history needs from the start of a reconcile
+type reconcileAttempt struct {
+	start            time.Time
+	trigger          string
+	desiredStateHash string
+}
+
+// startReconcileAttempt records when the reconcile started and what changed since the last
+// one. It must run before the Database is modified.
+func startReconcileAttempt(ctx context.Context, database *databasev1.Database) context.Context {
+	trigger := triggerEvent
+	switch {
+	case database.Status.ObservedGeneration == 0:
+		trigger = triggerCreated
+	case database.Generation != database.Status.ObservedGeneration:
+		trigger = triggerSpecChanged
+	}
+	return context.WithValue(ctx, reconcileAttemptKey{}, &reconcileAttempt{
+		start:            time.Now(),
+		trigger:          trigger,
+		desiredStateHash: database.Status.DesiredStateHash,
+	})
+}
+
+// recordHistory adds the outcome of the current reconcile to status.history. It runs right
+// before the last status update of a reconcile, after the phase and conditions are set.
+//
+// Consecutive reconciles that end the same way share a record, so the periodic resyncs of a
+// healthy Database do not push a flap out of the history. A repeated success leaves the record
+// as it is: a resync that changes nothing must not write the status. Since every reconcile ends
+// here, the status is pruned to its bounds here as well.
+func (r *DatabaseReconciler) recordHistory(ctx context.Context, database *databasev1.Database, err error) {
+	defer pruneStatus(database)
+	if r.HistoryLimit <= 0 {
+		database.Status.History = nil
+		return
+	}
+	attempt, ok := ctx.Value(reconcileAttemptKey{}).(*reconcileAttempt)
+	if !ok {
+		return
+	}
+
+	record := databasev1.ReconcileRecord{
+		Time:     metav1.Now(),
+		Outcome:  database.Status.Phase,
+		Duration: metav1.Duration{Duration: time.Since(attempt.start).Round(time.Millisecond)},
+		Trigger:  attempt.trigger,
+		Count:    1,
+	}
+	if ready := database.GetCondition("Ready"); ready != nil {
+		record.Reason = ready.Reason
+	}
+	if err != nil {
+		record.Error = truncate(err.Error(), historyErrorLimit)
+	}
+	// Labels, annotations, defaults and substituted values change without a new generation
+	if record.Trigger == triggerEvent && attempt.desiredStateHash != "" &&
+		database.Status.DesiredStateHash != attempt.desiredStateHash {
+		record.Trigger = triggerDesiredStateChanged
+	}
+
+	history := database.Status.History
+	switch {
+	case len(history) > 0 && sameOutcome(history[0], record) && err == nil:
+	case len(history) > 0 && sameOutcome(history[0], record):
+		// Failed retries are backed off and write their status anyway, so they are counted
+		record.Count = history[0].Count + 1
+		history = append([]databasev1.ReconcileRecord{record}, history[1:]...)
+	default:
+		history = append([]databasev1.ReconcileRecord{record}, history...)
+	}
+	if len(history) > r.HistoryLimit {
+		history = history[:r.HistoryLimit]
+	}
+	database.Status.History = history
+}
+
+// sameOutcome reports whether two records describe the same kind of reconcile
+func sameOutcome(a, b databasev1.ReconcileRecord) bool {
+	return a.Outcome == b.Outcome && a.Reason == b.Reason && a.Error == b.Error && a.Trigger == b.Trigger
+}
+
+// truncate shortens s to at most limit bytes without splitting a character
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	const ellipsis = "..."
+	n := limit - len(ellipsis)
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + ellipsis
+}
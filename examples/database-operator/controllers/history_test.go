@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestRecordHistory(t *testing.T) {
+	reconciler := &DatabaseReconciler{HistoryLimit: 3}
+	database := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Generation: 1}}
+
+	record := func(phase string, err error) {
+		ctx := startReconcileAttempt(context.Background(), database)
+		database.Status.Phase = phase
+		database.Status.ObservedGeneration = database.Generation
+		reconciler.recordHistory(ctx, database, err)
+	}
+	outcomes := func() []string {
+		var result []string
+		for _, r := range database.Status.History {
+			result = append(result, r.Trigger+"/"+r.Outcome)
+		}
+		return result
+	}
+
+	record("Progressing", nil)
+	record("Ready", nil)
+	record("Ready", nil)
+	record("Ready", nil)
+	assert.Equal(t, []string{"Event/Ready", "Created/Progressing"}, outcomes())
+	assert.Equal(t, int32(1), database.Status.History[0].Count, "Repeated successes share a record and are not counted")
+
+	// Repeated failures are counted
+	record("Failed", errors.New("connection refused"))
+	record("Failed", errors.New("connection refused"))
+	assert.Equal(t, []string{"Event/Failed", "Event/Ready", "Created/Progressing"}, outcomes())
+	assert.Equal(t, int32(2), database.Status.History[0].Count)
+
+	// A flap stays visible and the oldest records are dropped
+	record("Ready", nil)
+	assert.Equal(t, []string{"Event/Ready", "Event/Failed", "Event/Ready"}, outcomes())
+	assert.Equal(t, "connection refused", database.Status.History[1].Error)
+	assert.Equal(t, int32(1), database.Status.History[0].Count)
+
+	database.Generation = 2
+	record("Ready", nil)
+	assert.Equal(t, triggerSpecChanged, database.Status.History[0].Trigger)
+
+	// Disabling the history clears it
+	reconciler.HistoryLimit = 0
+	record("Ready", nil)
+	assert.Empty(t, database.Status.History)
+}
+
+func TestRecordHistory_TruncatesErrors(t *testing.T) {
+	reconciler := &DatabaseReconciler{HistoryLimit: 1}
+	database := &databasev1.Database{}
+	ctx := startReconcileAttempt(context.Background(), database)
+
+	reconciler.recordHistory(ctx, database, errors.New(strings.Repeat("é", historyErrorLimit)))
+	stored := database.Status.History[0].Error
+	assert.LessOrEqual(t, len(stored), historyErrorLimit)
+	assert.True(t, strings.HasSuffix(stored, "é..."), "Characters are not split")
+}
+
+func TestDatabaseReconciler_RecordsHistory(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-db",
+			Namespace:  "default",
+			Generation: 1,
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database).
+		Build()
+
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme, HistoryLimit: 5}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+	for i := 0; i < 3; i++ {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	history := database.Status.History
+	require.Len(t, history, 2)
+	assert.Equal(t, "Progressing", history[0].Outcome)
+	assert.Equal(t, triggerEvent, history[0].Trigger)
+	assert.Equal(t, int32(1), history[0].Count)
+	assert.Equal(t, triggerCreated, history[1].Trigger)
+	assert.False(t, history[0].Time.IsZero())
+
+	// Another identical reconcile leaves the history as it is
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.Equal(t, history, database.Status.History)
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// hookSignatureHeader carries the hex HMAC-SHA256 of the request body, prefixed "sha256="
+const hookSignatureHeader = "X-Hub-Signature-256"
+
+// maxHookBody bounds the payload a hook may post
+const maxHookBody = 1 << 20
+
+// RegistryHook is the payload of /hooks/registry. Registries send their own formats; a relay
+// or the registry's webhook template maps them to this one.
+type RegistryHook struct {
+	// Repository that was pushed to, e.g. docker.io/library/postgres or postgres
+	Repository string `json:"repository"`
+
+	// Tag that was pushed; empty matches every tag of the repository
+	Tag string `json:"tag,omitempty"`
+}
+
+// HookReceiver serves webhooks from external systems and turns them into Database reconciles,
+// so a push to a registry is acted on immediately instead of at the next resync.
+//
+// Requests must be signed with the shared secret the way GitHub signs webhooks. The receiver
+// runs on every replica, since the Service may route a hook to any of them; on a standby
+// replica the requests wait in the buffer and are reconciled once it becomes the leader.
+type HookReceiver struct {
+	client.Reader
+
+	// Addr the hook server listens on; empty disables the receiver
+	Addr string
+
+	// SecretFile holds the shared secret. It is read for every request, so a rotated Secret
+	// takes effect without a restart.
+	SecretFile string
+
+	events chan event.GenericEvent
+}
+
+// NewHookReceiver returns a disabled receiver with a request buffer
+func NewHookReceiver() *HookReceiver {
+	return &HookReceiver{events: make(chan event.GenericEvent, 256)}
+}
+
+var _ manager.LeaderElectionRunnable = &HookReceiver{}
+
+// BindFlags registers flags for the receiver, using the current values as defaults
+func (h *HookReceiver) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&h.Addr, "hook-bind-address", h.Addr,
+		"The address the /hooks endpoints bind to, e.g. :9444. Empty disables them.")
+	fs.StringVar(&h.SecretFile, "hook-secret-file", h.SecretFile,
+		"File holding the shared secret hook requests are signed with. Required when --hook-bind-address is set.")
+}
+
+// Events is the channel the Database controller watches for hook-triggered reconciles
+func (h *HookReceiver) Events() <-chan event.GenericEvent {
+	return h.events
+}
+
+// Start serves hooks until the context is cancelled
+func (h *HookReceiver) Start(ctx context.Context) error {
+	if h.SecretFile == "" {
+		return errors.New("hook receiver requires a secret file")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hooks/registry", h.serveRegistry)
+	server := &http.Server{
+		Addr:              h.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	log.FromContext(ctx).Info("Serving hooks", "address", h.Addr)
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("hook server failed: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// NeedLeaderElection is false: hooks reach whichever replica the Service picks
+func (h *HookReceiver) NeedLeaderElection() bool {
+	return false
+}
+
+// serveRegistry enqueues every Database running the pushed image
+func (h *HookReceiver) serveRegistry(w http.ResponseWriter, req *http.Request) {
+	logger := log.FromContext(req.Context()).WithName("hooks")
+
+	body, status := h.verify(req)
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	var hook RegistryHook
+	if err := json.Unmarshal(body, &hook); err != nil || hook.Repository == "" {
+		http.Error(w, "expected {\"repository\": ..., \"tag\": ...}", http.StatusBadRequest)
+		return
+	}
+
+	var databases databasev1.DatabaseList
+	if err := h.List(req.Context(), &databases); err != nil {
+		logger.Error(err, "failed to list Databases")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// The sender retries a failed delivery, so a full buffer is reported instead of waited out
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+	matched := 0
+	for i := range databases.Items {
+		database := &databases.Items[i]
+		if !hook.matches(database.Spec.Image) {
+			continue
+		}
+		select {
+		case h.events <- event.GenericEvent{Object: &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{
+			Name: database.Name, Namespace: database.Namespace,
+		}}}:
+			matched++
+		case <-ctx.Done():
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	logger.Info("Registry hook received", "repository", hook.Repository, "tag", hook.Tag, "databases", matched)
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "%d databases enqueued\n", matched)
+}
+
+// verify reads the body of a POST request and checks its signature
+func (h *HookReceiver) verify(req *http.Request) ([]byte, int) {
+	if req.Method != http.MethodPost {
+		return nil, http.StatusMethodNotAllowed
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxHookBody))
+	if err != nil {
+		return nil, http.StatusBadRequest
+	}
+
+	secret, err := os.ReadFile(h.SecretFile)
+	if err != nil {
+		log.FromContext(req.Context()).Error(err, "failed to read hook secret")
+		return nil, http.StatusInternalServerError
+	}
+	mac := hmac.New(sha256.New, []byte(strings.TrimSpace(string(secret))))
+	mac.Write(body)
+	signature, err := hex.DecodeString(strings.TrimPrefix(req.Header.Get(hookSignatureHeader), "sha256="))
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, http.StatusUnauthorized
+	}
+	return body, http.StatusOK
+}
+
+// matches reports whether a push affects image. Images pinned to a digest never change.
+func (hook RegistryHook) matches(image string) bool {
+	if image == "" {
+		return false
+	}
+	repository, tag, digest := parseImage(image)
+	pushed, _, _ := parseImage(hook.Repository)
+	return digest == "" && repository == pushed && (hook.Tag == "" || hook.Tag == tag)
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func newHookFixture(t *testing.T, images map[string]string) *HookReceiver {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for name, image := range images {
+		builder = builder.WithObjects(&databasev1.Database{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       databasev1.DatabaseSpec{Image: image},
+		})
+	}
+
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("s3cret\n"), 0o600))
+
+	receiver := NewHookReceiver()
+	receiver.Reader = builder.Build()
+	receiver.SecretFile = secretFile
+	return receiver
+}
+
+func postHook(receiver *HookReceiver, body, secret string) *httptest.ResponseRecorder {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	req := httptest.NewRequest(http.MethodPost, "/hooks/registry", strings.NewReader(body))
+	req.Header.Set(hookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	recorder := httptest.NewRecorder()
+	receiver.serveRegistry(recorder, req)
+	return recorder
+}
+
+func TestHookReceiver_Registry(t *testing.T) {
+	receiver := newHookFixture(t, map[string]string{
+		"pg15":   "postgres:15",
+		"pg16":   "docker.io/library/postgres:16",
+		"pinned": "postgres:15@sha256:" + strings.Repeat("a", 64),
+		"mirror": "registry.example.com/postgres:15",
+	})
+
+	recorder := postHook(receiver, `{"repository": "postgres", "tag": "15"}`, "s3cret")
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	require.Len(t, receiver.Events(), 1, "Pinned and differently hosted images are unaffected")
+	assert.Equal(t, "pg15", (<-receiver.Events()).Object.GetName())
+
+	recorder = postHook(receiver, `{"repository": "docker.io/library/postgres"}`, "s3cret")
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	var names []string
+	for len(receiver.Events()) > 0 {
+		names = append(names, (<-receiver.Events()).Object.GetName())
+	}
+	assert.ElementsMatch(t, []string{"pg15", "pg16"}, names, "A push without a tag matches every tag")
+}
+
+func TestHookReceiver_RejectsUnsigned(t *testing.T) {
+	receiver := newHookFixture(t, map[string]string{"pg15": "postgres:15"})
+
+	recorder := postHook(receiver, `{"repository": "postgres"}`, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/hooks/registry", strings.NewReader(`{"repository": "postgres"}`))
+	recorder = httptest.NewRecorder()
+	receiver.serveRegistry(recorder, req)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	recorder = postHook(receiver, `{"tag": "15"}`, "s3cret")
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Empty(t, receiver.Events())
+}
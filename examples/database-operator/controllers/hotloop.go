@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// reasonReconcileHotLoop is the Warning event reason for hot loops and a flapping Ready condition
+const reasonReconcileHotLoop = "ReconcileHotLoop"
+
+// hotLoopDetections counts Databases found hot-looping or flapping, by kind
+var hotLoopDetections = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "database_reconcile_hot_loops_total",
+	Help: "Number of times a Database was found reconciled too often without a spec change (hot_loop) or with a flapping Ready condition (flapping).",
+}, []string{"kind"})
+
+func init() {
+	metrics.Registry.MustRegister(hotLoopDetections)
+}
+
+// HotLoopDetector flags Databases that are reconciled over and over. The usual cause is a
+// fight with another actor: a mutating webhook, an HPA or a GitOps tool keeps changing a child
+// back, every change triggers a reconcile, and the reconcile changes it again. Such fights
+// never show up as errors, only as API load and, often, a Ready condition that flaps.
+//
+// Only the current generation is counted: a burst of reconciles after spec changes is the
+// controller converging, not looping. The state is in memory and per replica, which is enough
+// since only the leader reconciles.
+type HotLoopDetector struct {
+	// Threshold is how many reconciles of one generation within Window make a hot loop;
+	// zero disables the check
+	Threshold int
+
+	// FlapThreshold is how many Ready condition transitions within Window make it flapping;
+	// zero disables the check
+	FlapThreshold int
+
+	// Window is the sliding window both thresholds apply to
+	Window time.Duration
+
+	mu        sync.Mutex
+	databases map[types.NamespacedName]*reconcileActivity
+}
+
+// reconcileActivity is the recent reconcile activity of one Database
+type reconcileActivity struct {
+	generation  int64
+	reconciles  []time.Time
+	ready       metav1.ConditionStatus
+	transitions []time.Time
+	hotLoop     bool
+	flapping    bool
+}
+
+// NewHotLoopDetector returns a detector with the default thresholds
+func NewHotLoopDetector() *HotLoopDetector {
+	return &HotLoopDetector{
+		Threshold:     30,
+		FlapThreshold: 6,
+		Window:        5 * time.Minute,
+	}
+}
+
+// BindFlags registers flags for the detector, using the current values as defaults
+func (d *HotLoopDetector) BindFlags(fs *flag.FlagSet) {
+	fs.IntVar(&d.Threshold, "hot-loop-threshold", d.Threshold,
+		"How many reconciles of an unchanged Database within --hot-loop-window emit a ReconcileHotLoop warning. Zero disables the check.")
+	fs.IntVar(&d.FlapThreshold, "hot-loop-flap-threshold", d.FlapThreshold,
+		"How many Ready condition transitions within --hot-loop-window emit a ReconcileHotLoop warning. Zero disables the check.")
+	fs.DurationVar(&d.Window, "hot-loop-window", d.Window,
+		"The sliding window the hot loop thresholds apply to.")
+}
+
+// observe records a reconcile of the Database at now and reports whether it started a hot
+// loop or a flapping Ready condition. Each is reported once, when its threshold is crossed,
+// and again only after the activity dropped below the threshold.
+func (d *HotLoopDetector) observe(database *databasev1.Database, now time.Time) (hotLoop, flapping bool) {
+	if d == nil {
+		return false, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.databases == nil {
+		d.databases = map[types.NamespacedName]*reconcileActivity{}
+	}
+	key := client.ObjectKeyFromObject(database)
+	activity := d.databases[key]
+	if activity == nil || activity.generation != database.Generation {
+		ready := metav1.ConditionStatus("")
+		if activity != nil {
+			ready = activity.ready
+		}
+		activity = &reconcileActivity{generation: database.Generation, ready: ready}
+		d.databases[key] = activity
+	}
+
+	activity.reconciles = d.recent(append(activity.reconciles, now), now, d.Threshold)
+	hotLoop = d.Threshold > 0 && len(activity.reconciles) > d.Threshold && !activity.hotLoop
+	activity.hotLoop = d.Threshold > 0 && len(activity.reconciles) > d.Threshold
+
+	// The status is the one the previous reconcile wrote
+	ready := metav1.ConditionUnknown
+	if condition := database.GetCondition("Ready"); condition != nil {
+		ready = condition.Status
+	}
+	if activity.ready != "" && activity.ready != ready {
+		activity.transitions = append(activity.transitions, now)
+	}
+	activity.ready = ready
+	activity.transitions = d.recent(activity.transitions, now, d.FlapThreshold)
+	flapping = d.FlapThreshold > 0 && len(activity.transitions) >= d.FlapThreshold && !activity.flapping
+	activity.flapping = d.FlapThreshold > 0 && len(activity.transitions) >= d.FlapThreshold
+
+	if hotLoop {
+		hotLoopDetections.WithLabelValues("hot_loop").Inc()
+	}
+	if flapping {
+		hotLoopDetections.WithLabelValues("flapping").Inc()
+	}
+	return hotLoop, flapping
+}
+
+// recent drops the times that fell out of the window and keeps at most threshold+1 of them,
+// which is all the thresholds need
+func (d *HotLoopDetector) recent(times []time.Time, now time.Time, threshold int) []time.Time {
+	start := 0
+	for start < len(times) && now.Sub(times[start]) > d.Window {
+		start++
+	}
+	if excess := len(times) - start - (threshold + 1); excess > 0 {
+		start += excess
+	}
+	return append(times[:0], times[start:]...)
+}
+
+// forget drops the activity of a deleted Database
+func (d *HotLoopDetector) forget(key types.NamespacedName) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.databases, key)
+}
+
+// detectHotLoop records the reconcile and warns about a hot loop or a flapping Ready condition
+func (r *DatabaseReconciler) detectHotLoop(database *databasev1.Database) {
+	hotLoop, flapping := r.HotLoops.observe(database, time.Now())
+	if hotLoop {
+		r.warn(database, nil, reasonReconcileHotLoop, "Reconcile", fmt.Sprintf(
+			"Database was reconciled more than %d times in %s without a spec change; another controller may be changing its children",
+			r.HotLoops.Threshold, r.HotLoops.Window))
+	}
+	if flapping {
+		r.warn(database, nil, reasonReconcileHotLoop, "Reconcile", fmt.Sprintf(
+			"Ready condition changed %d times in %s; another controller may be changing its children",
+			r.HotLoops.FlapThreshold, r.HotLoops.Window))
+	}
+}
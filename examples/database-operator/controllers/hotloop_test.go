@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestHotLoopDetector_HotLoop(t *testing.T) {
+	detector := &HotLoopDetector{Threshold: 3, Window: time.Minute}
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default", Generation: 1},
+	}
+	start := time.Now()
+	observe := func(offset time.Duration) bool {
+		hotLoop, _ := detector.observe(database, start.Add(offset))
+		return hotLoop
+	}
+
+	assert.False(t, observe(0))
+	assert.False(t, observe(10*time.Second))
+	assert.False(t, observe(20*time.Second))
+	assert.True(t, observe(30*time.Second), "Fourth reconcile within the window")
+	assert.False(t, observe(40*time.Second), "Reported once per hot loop")
+
+	// Reconciles spread over more than the window are not a hot loop
+	assert.False(t, observe(3*time.Minute))
+	assert.False(t, observe(4*time.Minute))
+	assert.False(t, observe(5*time.Minute))
+
+	// Reconciles after spec changes are converging, not looping
+	for i := 0; i < 5; i++ {
+		database.Generation++
+		assert.False(t, observe(5*time.Minute+time.Duration(i)*time.Second))
+	}
+}
+
+func TestHotLoopDetector_Flapping(t *testing.T) {
+	detector := &HotLoopDetector{FlapThreshold: 3, Window: time.Minute}
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default", Generation: 1},
+	}
+	start := time.Now()
+	before := testutil.ToFloat64(hotLoopDetections.WithLabelValues("flapping"))
+
+	var reported []bool
+	for i, status := range []metav1.ConditionStatus{"True", "False", "True", "True", "False", "True"} {
+		database.SetCondition("Ready", status, "Test", "")
+		_, flapping := detector.observe(database, start.Add(time.Duration(i)*time.Second))
+		reported = append(reported, flapping)
+	}
+	assert.Equal(t, []bool{false, false, false, false, true, false}, reported)
+	assert.Equal(t, before+1, testutil.ToFloat64(hotLoopDetections.WithLabelValues("flapping")))
+
+	detector.forget(types.NamespacedName{Name: "test-db", Namespace: "default"})
+	assert.Empty(t, detector.databases)
+}
+
+func TestDatabaseReconciler_DetectHotLoop(t *testing.T) {
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &DatabaseReconciler{
+		Recorder: recorder,
+		HotLoops: &HotLoopDetector{Threshold: 2, Window: time.Minute},
+	}
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default", Generation: 1},
+	}
+
+	for i := 0; i < 5; i++ {
+		reconciler.detectHotLoop(database)
+	}
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning ReconcileHotLoop Database was reconciled more than 2 times in 1m0s")
+
+	// A nil detector is disabled
+	reconciler.HotLoops = nil
+	reconciler.detectHotLoop(database)
+	assert.Empty(t, recorder.Events)
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/yaml"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// Keys read from the operator defaults ConfigMap that configure image resolution
+const (
+	defaultsRegistryMirrorsKey  = "registryMirrors"
+	defaultsImageDigestsKey     = "imageDigests"
+	defaultsImagePullSecretsKey = "imagePullSecrets"
+)
+
+var digestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// ImagePolicy rewrites the images the controller renders so that they can be pulled in
+// disconnected clusters. Unlike OperatorDefaults.Apply it never changes the stored spec:
+// the Database keeps naming the upstream image and only the rendered pods use the mirror.
+type ImagePolicy struct {
+	// Mirrors maps a registry or repository prefix to its mirror, e.g.
+	// docker.io: registry.example.com/dockerhub. The longest matching prefix wins.
+	Mirrors map[string]string
+
+	// Digests pins images to a digest, keyed by fully qualified reference, e.g.
+	// docker.io/library/postgres:15. Pin the digest of the multi-architecture image index,
+	// not of a single-platform manifest, or nodes of other architectures pull an image they
+	// cannot run.
+	Digests map[string]string
+
+	// PullSecrets are Secrets in the operator namespace that are copied into the namespace
+	// of every Database and referenced from its pods
+	PullSecrets []string
+}
+
+// parseImagePolicy reads the image policy keys of the operator defaults ConfigMap
+func parseImagePolicy(data map[string]string) (ImagePolicy, error) {
+	var policy ImagePolicy
+
+	if raw := data[defaultsRegistryMirrorsKey]; raw != "" {
+		mirrors := map[string]string{}
+		if err := yaml.UnmarshalStrict([]byte(raw), &mirrors); err != nil {
+			return policy, fmt.Errorf("invalid %q: %w", defaultsRegistryMirrorsKey, err)
+		}
+		policy.Mirrors = make(map[string]string, len(mirrors))
+		for prefix, mirror := range mirrors {
+			policy.Mirrors[strings.TrimSuffix(prefix, "/")] = strings.TrimSuffix(mirror, "/")
+		}
+	}
+
+	if raw := data[defaultsImageDigestsKey]; raw != "" {
+		digests := map[string]string{}
+		if err := yaml.UnmarshalStrict([]byte(raw), &digests); err != nil {
+			return policy, fmt.Errorf("invalid %q: %w", defaultsImageDigestsKey, err)
+		}
+		policy.Digests = make(map[string]string, len(digests))
+		for image, digest := range digests {
+			repository, tag, pinned := parseImage(image)
+			if pinned != "" {
+				return policy, fmt.Errorf("invalid %q: %s is already pinned to a digest", defaultsImageDigestsKey, image)
+			}
+			if !digestPattern.MatchString(digest) {
+				return policy, fmt.Errorf("invalid %q: %q is not a sha256 digest", defaultsImageDigestsKey, digest)
+			}
+			policy.Digests[repository+":"+tag] = digest
+		}
+	}
+
+	if raw := data[defaultsImagePullSecretsKey]; raw != "" {
+		if err := yaml.UnmarshalStrict([]byte(raw), &policy.PullSecrets); err != nil {
+			return policy, fmt.Errorf("invalid %q: %w", defaultsImagePullSecretsKey, err)
+		}
+	}
+
+	return policy, nil
+}
+
+// parseImage splits an image reference into its fully qualified repository, tag and digest,
+// with the container runtime's defaults: docker.io for references without a registry,
+// library/ for official Docker Hub images and the latest tag when there is no tag or digest
+func parseImage(image string) (repository, tag, digest string) {
+	repository, digest, _ = strings.Cut(image, "@")
+	if colon := strings.LastIndex(repository, ":"); colon > strings.LastIndex(repository, "/") {
+		repository, tag = repository[:colon], repository[colon+1:]
+	}
+	if !hasRegistry(repository) {
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+		repository = "docker.io/" + repository
+	}
+	if tag == "" && digest == "" {
+		tag = "latest"
+	}
+	return repository, tag, digest
+}
+
+// Resolve returns the reference to render for image. Images without a mirror or pinned
+// digest are returned unchanged, so that enabling the policy does not roll every Database.
+func (p ImagePolicy) Resolve(image string) string {
+	if image == "" {
+		return image
+	}
+	repository, tag, digest := parseImage(image)
+
+	changed := false
+	if pinned, ok := p.Digests[repository+":"+tag]; ok && digest == "" {
+		digest, changed = pinned, true
+	}
+
+	// The longest prefix ending at a path component boundary wins
+	best := ""
+	for prefix := range p.Mirrors {
+		if (repository == prefix || strings.HasPrefix(repository, prefix+"/")) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best != "" {
+		repository, changed = p.Mirrors[best]+strings.TrimPrefix(repository, best), true
+	}
+
+	if !changed {
+		return image
+	}
+	ref := repository
+	if tag != "" {
+		ref += ":" + tag
+	}
+	if digest != "" {
+		ref += "@" + digest
+	}
+	return ref
+}
+
+// apply rewrites the in-memory Database with the images and pull secrets its pods use
+func (p ImagePolicy) apply(database *databasev1.Database) {
+	database.Spec.Image = p.Resolve(database.Spec.Image)
+	for _, source := range p.PullSecrets {
+		database.Spec.ImagePullSecrets = append(database.Spec.ImagePullSecrets,
+			corev1.LocalObjectReference{Name: pullSecretName(database, source)})
+	}
+}
+
+// reconcileImagePullSecrets copies the operator's pull secrets into the Database namespace.
+// Pods can only reference Secrets in their own namespace.
+func (r *DatabaseReconciler) reconcileImagePullSecrets(ctx context.Context, database *databasev1.Database) error {
+	defaults, err := r.Defaults.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range defaults.Images.PullSecrets {
+		source := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: r.Defaults.Namespace, Name: name}, source); err != nil {
+			if errors.IsNotFound(err) {
+				return fmt.Errorf("image pull secret %s/%s not found", r.Defaults.Namespace, name)
+			}
+			return fmt.Errorf("failed to get image pull secret: %w", err)
+		}
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pullSecretName(database, name),
+				Namespace: database.Namespace,
+			},
+		}
+		if _, err := r.createOrPatch(ctx, secret, func() error {
+			secret.Type = source.Type
+			secret.Data = source.Data
+			r.Propagation.apply(database, secret)
+			setDatabaseLabel(secret, database)
+			return controllerutil.SetControllerReference(database, secret, r.Scheme)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+var testDigest = "sha256:" + strings.Repeat("ab", 32)
+
+func TestImagePolicy_Resolve(t *testing.T) {
+	policy, err := parseImagePolicy(map[string]string{
+		defaultsRegistryMirrorsKey: "docker.io: mirror.example.com/dockerhub\n" +
+			"docker.io/bitnami: mirror.example.com/bitnami/\n",
+		defaultsImageDigestsKey: "postgres:15: " + testDigest + "\n",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "postgres:15", want: "mirror.example.com/dockerhub/library/postgres:15@" + testDigest},
+		{image: "docker.io/library/postgres:15", want: "mirror.example.com/dockerhub/library/postgres:15@" + testDigest},
+		{image: "postgres:16", want: "mirror.example.com/dockerhub/library/postgres:16"},
+		{image: "bitnami/postgresql:15", want: "mirror.example.com/bitnami/postgresql:15"},
+		// Prefixes only match whole path components
+		{image: "bitnamilegacy/postgresql:15", want: "mirror.example.com/dockerhub/bitnamilegacy/postgresql:15"},
+		{image: "postgres@" + testDigest, want: "mirror.example.com/dockerhub/library/postgres@" + testDigest},
+		{image: "quay.io/postgres:15", want: "quay.io/postgres:15"},
+		{image: "localhost:5000/postgres:15", want: "localhost:5000/postgres:15"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.want, policy.Resolve(tt.image))
+		})
+	}
+
+	assert.Equal(t, "postgres:15", ImagePolicy{}.Resolve("postgres:15"), "Images without a policy must not be rewritten")
+}
+
+func TestParseImagePolicy_Invalid(t *testing.T) {
+	for name, data := range map[string]map[string]string{
+		"short digest": {defaultsImageDigestsKey: "postgres:15: sha256:abc"},
+		"pinned key":   {defaultsImageDigestsKey: "postgres@" + testDigest + ": " + testDigest},
+		"mirrors list": {defaultsRegistryMirrorsKey: "- docker.io"},
+		"secrets map":  {defaultsImagePullSecretsKey: "name: mirror-credentials"},
+		"digests list": {defaultsImageDigestsKey: "- postgres:15"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseImagePolicy(data)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDatabaseReconciler_ResolvesImages(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-db",
+			Namespace:  "default",
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas:         1,
+			Image:            "postgres:15",
+			Storage:          1024,
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "team-credentials"}},
+		},
+	}
+
+	defaults := newDefaultsConfigMap()
+	defaults.Data = map[string]string{
+		defaultsRegistryMirrorsKey:  "docker.io: mirror.example.com/dockerhub",
+		defaultsImageDigestsKey:     "postgres:15: " + testDigest,
+		defaultsImagePullSecretsKey: "[mirror-credentials]",
+	}
+	pullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "mirror-credentials", Namespace: "database-operator-system"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database, defaults).
+		WithStatusSubresource(database).
+		Build()
+
+	reconciler := &DatabaseReconciler{
+		Client:   fakeClient,
+		Scheme:   scheme,
+		Defaults: newDefaultsSource(fakeClient),
+	}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+
+	// A missing source Secret fails the reconcile instead of rendering unpullable pods
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "database-operator-system/mirror-credentials")
+
+	require.NoError(t, fakeClient.Create(ctx, pullSecret))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	podSpec := deployment.Spec.Template.Spec
+	assert.Equal(t, "mirror.example.com/dockerhub/library/postgres:15@"+testDigest, podSpec.Containers[0].Image)
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "team-credentials"}, {Name: "test-db-pull-mirror-credentials"}},
+		podSpec.ImagePullSecrets)
+
+	copied := &corev1.Secret{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "test-db-pull-mirror-credentials"}, copied))
+	assert.Equal(t, corev1.SecretTypeDockerConfigJson, copied.Type)
+	assert.Equal(t, pullSecret.Data, copied.Data)
+	assert.True(t, metav1.IsControlledBy(copied, database))
+
+	// The stored spec keeps the upstream image
+	stored := &databasev1.Database{}
+	require.NoError(t, fakeClient.Get(ctx, key, stored))
+	assert.Equal(t, "postgres:15", stored.Spec.Image)
+	assert.Len(t, stored.Spec.ImagePullSecrets, 1)
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/validation"
+)
+
+// TagLister lists the tags of an image repository, e.g. docker.io/library/postgres
+type TagLister interface {
+	ListTags(ctx context.Context, repository string) ([]string, error)
+}
+
+// ImageUpdater periodically looks for newer tags of the images of Databases with an
+// imageUpdatePolicy. In Notify mode it records the newest tag within the range in the
+// available-image annotation; in Apply mode it updates spec.image, and the rollout follows
+// as for any other spec change.
+//
+// An applied update also sets the automated-image annotation, which makes the validating
+// webhook check it against the policy. A bug here, or anyone else writing the annotation,
+// therefore cannot move a Database outside its range.
+type ImageUpdater struct {
+	client.Client
+	Recorder events.EventRecorder
+
+	// Tags lists the tags of a repository; RegistryTags by default
+	Tags TagLister
+
+	// Interval is how often registries are polled; zero disables the updater
+	Interval time.Duration
+
+	// Switches pause the updates in break-glass mode; nil only honours the break-glass
+	// annotation
+	Switches *Switches
+}
+
+var _ manager.LeaderElectionRunnable = &ImageUpdater{}
+
+// BindFlags registers flags for the updater, using the current values as defaults
+func (u *ImageUpdater) BindFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&u.Interval, "image-update-interval", u.Interval,
+		"How often registries are polled for new tags of Databases with an imageUpdatePolicy. Zero disables image updates.")
+}
+
+// Start polls registries until the context is cancelled
+func (u *ImageUpdater) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("image-updater")
+	ctx = log.IntoContext(ctx, logger)
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := u.check(ctx); err != nil {
+			logger.Error(err, "failed to check for image updates")
+		}
+	}, u.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes only the leader poll registries and update Databases
+func (u *ImageUpdater) NeedLeaderElection() bool {
+	return true
+}
+
+// check updates every Database with an update policy once. Each repository is listed once
+// per check, however many Databases use it.
+func (u *ImageUpdater) check(ctx context.Context) error {
+	var databases databasev1.DatabaseList
+	if err := u.List(ctx, &databases); err != nil {
+		return err
+	}
+
+	tags := map[string][]string{}
+	var errs []error
+	for i := range databases.Items {
+		database := &databases.Items[i]
+		if database.Spec.ImageUpdatePolicy == nil || !database.DeletionTimestamp.IsZero() || u.Switches.InBreakGlass(database) {
+			continue
+		}
+		if err := u.update(ctx, database, tags); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", client.ObjectKeyFromObject(database), err))
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// update moves one Database to the newest tag within its range, or annotates it
+func (u *ImageUpdater) update(ctx context.Context, database *databasev1.Database, tags map[string][]string) error {
+	policy := database.Spec.ImageUpdatePolicy
+	image := database.Spec.Image
+	repository, tag, digest := parseImage(image)
+	if digest != "" || strings.Contains(image, "${") || !strings.HasSuffix(image, ":"+tag) {
+		// Pinned, unresolved or implicitly latest: nothing to compare against
+		return nil
+	}
+	versions, err := validation.ParseVersionRange(policy.Range)
+	if err != nil {
+		// The reconciler stalls the Database with the validation error
+		return nil
+	}
+	current, err := validation.ParseTag(tag)
+	if err != nil {
+		log.FromContext(ctx).V(1).Info("Image tag has no version, skipping", "database", client.ObjectKeyFromObject(database), "tag", tag)
+		return nil
+	}
+
+	available, listed := tags[repository]
+	if !listed {
+		if available, err = u.Tags.ListTags(ctx, repository); err != nil {
+			return fmt.Errorf("failed to list tags of %s: %w", repository, err)
+		}
+		tags[repository] = available
+	}
+
+	patch := client.MergeFrom(database.DeepCopy())
+	newest := newestTag(available, current, versions)
+	annotations := database.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if newest == "" {
+		if _, ok := annotations[databasev1.AvailableImageAnnotation]; !ok {
+			return nil
+		}
+		// Updated by hand, or the tag was withdrawn
+		delete(annotations, databasev1.AvailableImageAnnotation)
+		database.SetAnnotations(annotations)
+		return client.IgnoreNotFound(u.Patch(ctx, database, patch))
+	}
+
+	newImage := strings.TrimSuffix(image, tag) + newest
+	if policy.Mode == databasev1.ImageUpdateApply {
+		database.Spec.Image = newImage
+		annotations[databasev1.AutomatedImageAnnotation] = newImage
+		delete(annotations, databasev1.AvailableImageAnnotation)
+	} else {
+		if annotations[databasev1.AvailableImageAnnotation] == newImage {
+			return nil
+		}
+		annotations[databasev1.AvailableImageAnnotation] = newImage
+	}
+	database.SetAnnotations(annotations)
+	if err := u.Patch(ctx, database, patch); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if policy.Mode == databasev1.ImageUpdateApply {
+		u.Recorder.Eventf(database, nil, corev1.EventTypeNormal, "ImageUpdated", "UpdateImage",
+			"Updated image from %s to %s within range %q", image, newImage, policy.Range)
+	} else {
+		u.Recorder.Eventf(database, nil, corev1.EventTypeNormal, "ImageUpdateAvailable", "UpdateImage",
+			"Image %s is available within range %q", newImage, policy.Range)
+	}
+	return nil
+}
+
+// newestTag returns the newest tag of the current variant that is within versions and newer
+// than current, or "" if there is none
+func newestTag(tags []string, current validation.Tag, versions validation.VersionRange) string {
+	var newest string
+	var newestTag validation.Tag
+	for _, tag := range tags {
+		parsed, err := validation.ParseTag(tag)
+		if err != nil || parsed.Variant != current.Variant || !versions.Contains(parsed) {
+			continue
+		}
+		if parsed.Compare(current) <= 0 {
+			continue
+		}
+		// Of equal versions (15.4 and 15.4.0) the more specific tag wins, so the choice does
+		// not depend on the registry's ordering
+		if newest == "" || parsed.Compare(newestTag) > 0 ||
+			(parsed.Compare(newestTag) == 0 && len(parsed.Version) > len(newestTag.Version)) {
+			newest, newestTag = tag, parsed
+		}
+	}
+	return newest
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// staticTags lists fixed tags and counts the calls per repository
+type staticTags struct {
+	tags  map[string][]string
+	calls map[string]int
+}
+
+func (s *staticTags) ListTags(ctx context.Context, repository string) ([]string, error) {
+	s.calls[repository]++
+	return s.tags[repository], nil
+}
+
+func newUpdatableDatabase(name, image string, mode databasev1.ImageUpdateMode) *databasev1.Database {
+	return &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: databasev1.DatabaseSpec{
+			Replicas:          1,
+			Image:             image,
+			Storage:           1024,
+			ImageUpdatePolicy: &databasev1.ImageUpdatePolicy{Range: ">=15 <16", Mode: mode},
+		},
+	}
+}
+
+func TestImageUpdater_Check(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+
+	pinned := newUpdatableDatabase("pinned", "postgres@sha256:0123456789abcdef0123456789abcdef", databasev1.ImageUpdateApply)
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			newUpdatableDatabase("apply", "postgres:15.2", databasev1.ImageUpdateApply),
+			newUpdatableDatabase("alpine", "postgres:15.2-alpine", databasev1.ImageUpdateApply),
+			newUpdatableDatabase("notify", "docker.io/library/postgres:15.2", databasev1.ImageUpdateNotify),
+			newUpdatableDatabase("latest", "postgres:15.10", databasev1.ImageUpdateNotify),
+			pinned,
+		).
+		Build()
+
+	tags := &staticTags{
+		tags: map[string][]string{
+			"docker.io/library/postgres": {"latest", "14.9", "15.2", "15.4", "15.4-alpine", "15.10", "15.10.0", "16.1"},
+		},
+		calls: map[string]int{},
+	}
+	recorder := events.NewFakeRecorder(10)
+	updater := &ImageUpdater{Client: fakeClient, Recorder: recorder, Tags: tags}
+
+	ctx := context.Background()
+	require.NoError(t, updater.check(ctx))
+	assert.Equal(t, 1, tags.calls["docker.io/library/postgres"], "Each repository is listed once per check")
+
+	get := func(name string) *databasev1.Database {
+		database := &databasev1.Database{}
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "default"}, database))
+		return database
+	}
+
+	apply := get("apply")
+	assert.Equal(t, "postgres:15.10.0", apply.Spec.Image, "The newest tag within the range wins")
+	assert.Equal(t, "postgres:15.10.0", apply.Annotations[databasev1.AutomatedImageAnnotation])
+
+	assert.Equal(t, "postgres:15.4-alpine", get("alpine").Spec.Image, "Only tags of the same variant are candidates")
+
+	notify := get("notify")
+	assert.Equal(t, "docker.io/library/postgres:15.2", notify.Spec.Image)
+	assert.Equal(t, "docker.io/library/postgres:15.10.0", notify.Annotations[databasev1.AvailableImageAnnotation])
+
+	assert.Empty(t, get("latest").Annotations, "15.10.0 is the same version as 15.10")
+	assert.Equal(t, pinned.Spec.Image, get("pinned").Spec.Image)
+	assert.Len(t, recorder.Events, 3)
+
+	// The annotation goes away once the Database is updated by hand
+	notify.Spec.Image = "postgres:15.10"
+	require.NoError(t, fakeClient.Update(ctx, notify))
+	require.NoError(t, updater.check(ctx))
+	assert.NotContains(t, get("notify").Annotations, databasev1.AvailableImageAnnotation)
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"flag"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/naming"
+)
+
+// selectorLabel selects the pods of a Database. Deployment selectors are immutable, so it is
+// kept as it was even though the standard labels below carry the same information.
+const selectorLabel = "app"
+
+// selectorLabels select the pods of a Database in the Deployment, Service and PodDisruptionBudget
+func selectorLabels(database *databasev1.Database) map[string]string {
+	return map[string]string{selectorLabel: databaseLabel(database)}
+}
+
+// databaseLabel is the Database name as a label value. Label values are at most 63 characters
+// and names up to 253, so longer names are shortened; watches.go maps them back through
+// databaseLabelIndex.
+func databaseLabel(database *databasev1.Database) string {
+	return naming.LabelValue(database.Name)
+}
+
+// standardLabels are the recommended app.kubernetes.io labels set on every child
+func standardLabels(database *databasev1.Database) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       "postgresql",
+		"app.kubernetes.io/instance":   databaseLabel(database),
+		"app.kubernetes.io/component":  "database",
+		"app.kubernetes.io/managed-by": "database-operator",
+	}
+}
+
+// PropagationPolicy selects labels and annotations of a Database that are copied to its
+// children, e.g. cost-allocation or team labels that policy engines and billing read from
+// every object. Propagated keys are kept in sync: a key matching the policy that is removed
+// from the Database is removed from the children too.
+//
+// Labels are also copied to the pod template, so pods carry them; annotations are not,
+// because changing the pod template restarts the pods.
+type PropagationPolicy struct {
+	// Labels and Annotations are the keys to copy. A key ending in "*" matches every key
+	// with that prefix, e.g. "team.example.com/*".
+	Labels      []string
+	Annotations []string
+}
+
+// BindFlags registers flags for the policy
+func (p *PropagationPolicy) BindFlags(fs *flag.FlagSet) {
+	fs.Func("propagate-labels",
+		`Comma-separated Database label keys copied to its children; a trailing "*" matches a prefix.`,
+		func(value string) error {
+			p.Labels = splitKeys(value)
+			return nil
+		})
+	fs.Func("propagate-annotations",
+		`Comma-separated Database annotation keys copied to its children; a trailing "*" matches a prefix.`,
+		func(value string) error {
+			p.Annotations = splitKeys(value)
+			return nil
+		})
+}
+
+func splitKeys(value string) []string {
+	var keys []string
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// apply sets the standard labels and the propagated labels and annotations on a child
+func (p PropagationPolicy) apply(database *databasev1.Database, obj metav1.Object) {
+	labels := propagate(p.Labels, database.Labels, obj.GetLabels())
+	for key, value := range standardLabels(database) {
+		labels[key] = value
+	}
+	obj.SetLabels(labels)
+	obj.SetAnnotations(propagate(p.Annotations, database.Annotations, obj.GetAnnotations()))
+}
+
+// podLabels returns the labels of the pod template. The template is rendered in full, so
+// labels removed from the Database disappear with the next rollout.
+func (p PropagationPolicy) podLabels(database *databasev1.Database) map[string]string {
+	labels := propagate(p.Labels, database.Labels, nil)
+	for key, value := range standardLabels(database) {
+		labels[key] = value
+	}
+	for key, value := range selectorLabels(database) {
+		labels[key] = value
+	}
+	labels[databaseNameLabel] = databaseLabel(database)
+	if database.Spec.Standby != nil {
+		labels[roleLabel] = role(database)
+	}
+	return labels
+}
+
+// propagate copies the keys of source that match patterns into a copy of target and
+// removes matching keys that source no longer has
+func propagate(patterns []string, source, target map[string]string) map[string]string {
+	result := make(map[string]string, len(target))
+	for key, value := range target {
+		if !matchesAny(patterns, key) {
+			result[key] = value
+		}
+	}
+	for key, value := range source {
+		if matchesAny(patterns, key) {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// matchesAny reports whether key is selected by one of the patterns. Keys the operator or
+// kubectl manage are never propagated, whatever the patterns say.
+func matchesAny(patterns []string, key string) bool {
+	if key == selectorLabel || key == databaseNameLabel || key == lastAppliedAnnotation ||
+		strings.HasPrefix(key, "app.kubernetes.io/") || strings.HasPrefix(key, databasev1.GroupVersion.Group+"/") ||
+		strings.HasPrefix(key, "database."+databasev1.GroupVersion.Group+"/") {
+		return false
+	}
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestPropagate(t *testing.T) {
+	patterns := []string{"team.example.com/*", "cost-center", "app.kubernetes.io/*"}
+
+	source := map[string]string{
+		"team.example.com/owner":    "payments",
+		"cost-center":               "42",
+		"unrelated":                 "x",
+		"app.kubernetes.io/part-of": "shop",
+	}
+	target := map[string]string{
+		"team.example.com/oncall": "stale",
+		"set-by-someone-else":     "kept",
+	}
+
+	assert.Equal(t, map[string]string{
+		"team.example.com/owner": "payments",
+		"cost-center":            "42",
+		"set-by-someone-else":    "kept",
+	}, propagate(patterns, source, target), "Matching keys follow the source, others are kept, reserved keys are never copied")
+}
+
+func TestDatabaseReconciler_PropagatesLabels(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-db",
+			Namespace:   "default",
+			Finalizers:  []string{databaseFinalizer},
+			Labels:      map[string]string{"team.example.com/owner": "payments", "unrelated": "x"},
+			Annotations: map[string]string{"cost-center": "42"},
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database).
+		Build()
+
+	reconciler := &DatabaseReconciler{
+		Client: fakeClient,
+		Scheme: scheme,
+		Propagation: PropagationPolicy{
+			Labels:      []string{"team.example.com/*"},
+			Annotations: []string{"cost-center"},
+		},
+	}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	service := &corev1.Service{}
+	require.NoError(t, fakeClient.Get(ctx, key, service))
+	assert.Equal(t, "payments", service.Labels["team.example.com/owner"])
+	assert.NotContains(t, service.Labels, "unrelated")
+	assert.Equal(t, "test-db", service.Labels["app.kubernetes.io/instance"])
+	assert.Equal(t, "42", service.Annotations["cost-center"])
+	assert.Equal(t, selectorLabels(database), service.Spec.Selector)
+
+	secret := &corev1.Secret{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-db-password", Namespace: "default"}, secret))
+	assert.Equal(t, "payments", secret.Labels["team.example.com/owner"])
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	assert.Equal(t, "payments", deployment.Labels["team.example.com/owner"])
+	assert.Equal(t, map[string]string{"app": "test-db"}, deployment.Spec.Selector.MatchLabels,
+		"The immutable selector is unchanged")
+	podLabels := deployment.Spec.Template.Labels
+	assert.Equal(t, "payments", podLabels["team.example.com/owner"])
+	assert.Equal(t, "test-db", podLabels["app"])
+	assert.Equal(t, "test-db", podLabels[databaseNameLabel])
+	assert.Equal(t, "database-operator", podLabels["app.kubernetes.io/managed-by"])
+	assert.NotContains(t, deployment.Spec.Template.Annotations, "cost-center",
+		"Annotations stay off the pod template so they do not restart pods")
+
+	// Removing a propagated label from the Database removes it from the children
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	delete(database.Labels, "team.example.com/owner")
+	require.NoError(t, fakeClient.Update(ctx, database))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	require.NoError(t, fakeClient.Get(ctx, key, service))
+	assert.NotContains(t, service.Labels, "team.example.com/owner")
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	assert.NotContains(t, deployment.Spec.Template.Labels, "team.example.com/owner")
+}
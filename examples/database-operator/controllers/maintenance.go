@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/naming"
+)
+
+// Maintenance task results recorded in status.maintenance
+const (
+	maintenanceRunning   = "Running"
+	maintenanceSucceeded = "Succeeded"
+	maintenanceFailed    = "Failed"
+)
+
+// maintenanceCommands are the client tools of the postgres image that run each task. Reindex
+// uses REINDEX CONCURRENTLY so writes continue; system catalogs are skipped.
+var maintenanceCommands = map[databasev1.MaintenanceTaskType][]string{
+	databasev1.MaintenanceVacuumAnalyze: {"vacuumdb", "--all", "--analyze"},
+	databasev1.MaintenanceReindex:       {"reindexdb", "--all", "--concurrently"},
+}
+
+// maintenanceJobName is the name of the Job running a maintenance task. Job names end up in
+// a pod label, so they are DNS labels.
+func maintenanceJobName(database *databasev1.Database, task databasev1.MaintenanceTaskType) string {
+	return naming.Label(database.Name, strings.ToLower(string(task)))
+}
+
+// reconcileMaintenance starts the maintenance tasks that are due while the window is open and
+// records the outcome of their Jobs. A finished Job is kept as the record of its run until
+// the task is due again, so the schedule survives operator restarts and status rewrites.
+func (r *DatabaseReconciler) reconcileMaintenance(ctx context.Context, database *databasev1.Database) error {
+	maintenance := database.Spec.Maintenance
+	if maintenance == nil {
+		// pruneChildren removes the Jobs
+		database.Status.Maintenance = nil
+		return nil
+	}
+
+	now := time.Now()
+	statuses := make([]databasev1.MaintenanceTaskStatus, 0, len(maintenance.Tasks))
+	for _, task := range maintenance.Tasks {
+		status := databasev1.MaintenanceTaskStatus{Type: task.Type}
+		for _, previous := range database.Status.Maintenance {
+			if previous.Type == task.Type {
+				status = previous
+			}
+		}
+		if err := r.reconcileMaintenanceTask(ctx, database, task, &status, now); err != nil {
+			return err
+		}
+		statuses = append(statuses, status)
+	}
+	database.Status.Maintenance = statuses
+	return nil
+}
+
+func (r *DatabaseReconciler) reconcileMaintenanceTask(ctx context.Context, database *databasev1.Database,
+	task databasev1.MaintenanceTask, status *databasev1.MaintenanceTaskStatus, now time.Time) error {
+	window := database.Spec.Maintenance.Window
+	job := &batchv1.Job{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: database.Namespace, Name: maintenanceJobName(database, task.Type)}, job)
+	if apierrors.IsNotFound(err) {
+		opened, open := openWindow(window, now)
+		if !open || !maintenanceDue(window, task, status, opened) || !database.IsReady() {
+			return nil
+		}
+		return r.startMaintenance(ctx, database, task, status, opened.Add(window.Duration.Duration).Sub(now))
+	}
+	if err != nil {
+		return err
+	}
+	if !job.DeletionTimestamp.IsZero() {
+		// The previous run is being removed; its deletion triggers the next reconcile
+		return nil
+	}
+
+	status.JobName = job.Name
+	if job.Status.StartTime != nil {
+		status.StartTime = job.Status.StartTime
+	} else if status.StartTime == nil {
+		status.StartTime = &job.CreationTimestamp
+	}
+	completed, failed, message := jobOutcome(job)
+	if completed == nil {
+		// Owns(&batchv1.Job{}) reconciles again when it finishes
+		status.Result = maintenanceRunning
+		return nil
+	}
+
+	result := maintenanceSucceeded
+	if failed {
+		result = maintenanceFailed
+		if status.Result != maintenanceFailed || !status.CompletionTime.Equal(completed) {
+			r.warn(database, job, "MaintenanceFailed", "Maintenance", fmt.Sprintf("%s failed: %s", task.Type, message))
+		}
+	}
+	status.Result, status.CompletionTime, status.Message = result, completed, message
+
+	// Due again: remove the record of the last run; the next reconcile starts a new one
+	if opened, open := openWindow(window, now); open && maintenanceDue(window, task, status, opened) {
+		err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		return client.IgnoreNotFound(err)
+	}
+	return nil
+}
+
+// startMaintenance creates the Job of a task. It is stopped when the window closes: VACUUM
+// and REINDEX CONCURRENTLY can be interrupted safely, and the next window carries on.
+func (r *DatabaseReconciler) startMaintenance(ctx context.Context, database *databasev1.Database,
+	task databasev1.MaintenanceTask, status *databasev1.MaintenanceTaskStatus, remaining time.Duration) error {
+	deadline := int64(remaining.Seconds())
+	if deadline < 1 {
+		return nil
+	}
+	backoffLimit := int32(0)
+	user, dbname := loginNames(database)
+	allowPrivilegeEscalation := false
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      maintenanceJobName(database, task.Type),
+			Namespace: database.Namespace,
+		},
+		Spec: batchv1.JobSpec{
+			// A failed run is reported and retried in the next window
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &deadline,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy:     corev1.RestartPolicyNever,
+					ImagePullSecrets:  database.Spec.ImagePullSecrets,
+					PriorityClassName: database.Spec.PriorityClassName,
+					Containers: []corev1.Container{{
+						Name:    "maintenance",
+						Image:   database.Spec.Image,
+						Command: maintenanceCommands[task.Type],
+						Env: []corev1.EnvVar{
+							{Name: "PGHOST", Value: serviceName(database)},
+							{Name: "PGUSER", Value: user},
+							{Name: "PGDATABASE", Value: dbname},
+							{
+								Name: "PGPASSWORD",
+								ValueFrom: &corev1.EnvVarSource{
+									SecretKeyRef: &corev1.SecretKeySelector{
+										LocalObjectReference: corev1.LocalObjectReference{Name: passwordSecretName(database)},
+										Key:                  "password",
+									},
+								},
+							},
+						},
+						// The work happens in the database; the client only waits for it
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("10m"),
+								corev1.ResourceMemory: resource.MustParse("32Mi"),
+							},
+						},
+						SecurityContext: &corev1.SecurityContext{
+							AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+						},
+					}},
+				},
+			},
+		},
+	}
+	r.Passwords.mountPassword(database, &job.Spec.Template.Spec)
+	r.Propagation.apply(database, job)
+	setDatabaseLabel(job, database)
+	if err := controllerutil.SetControllerReference(database, job, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.create(ctx, job); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to start %s: %w", task.Type, err)
+	}
+
+	now := metav1.Now()
+	*status = databasev1.MaintenanceTaskStatus{
+		Type:      task.Type,
+		JobName:   job.Name,
+		StartTime: &now,
+		Result:    maintenanceRunning,
+	}
+	return nil
+}
+
+// maintenanceDue reports whether a task should run in the window that opened at opened: it
+// did not run in this window yet, and its interval passed since the window of its last run
+func maintenanceDue(window databasev1.MaintenanceWindow, task databasev1.MaintenanceTask,
+	status *databasev1.MaintenanceTaskStatus, opened time.Time) bool {
+	if status.StartTime == nil {
+		return true
+	}
+	last := status.StartTime.Time
+	if !last.Before(opened) {
+		return false
+	}
+	if task.Interval == nil {
+		return true
+	}
+	// Measured between window openings, so a weekly interval matches a weekly window even
+	// though the last run started a little after its window opened
+	if lastOpened, ok := openWindow(window, last); ok {
+		last = lastOpened
+	}
+	return opened.Sub(last) >= task.Interval.Duration
+}
+
+// jobOutcome returns when a Job finished and whether it failed, or nil while it runs
+func jobOutcome(job *batchv1.Job) (*metav1.Time, bool, string) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return &condition.LastTransitionTime, false, ""
+		case batchv1.JobFailed:
+			return &condition.LastTransitionTime, true, condition.Reason + ": " + condition.Message
+		}
+	}
+	return nil, false, ""
+}
+
+// maintenanceRequeue returns how long until the maintenance window of a Database next opens,
+// or zero without maintenance. Jobs start when the window opens, not at the next resync.
+func maintenanceRequeue(database *databasev1.Database, now time.Time) time.Duration {
+	if database.Spec.Maintenance == nil {
+		return 0
+	}
+	next, ok := nextWindow(database.Spec.Maintenance.Window, now)
+	if !ok {
+		return 0
+	}
+	return next.Sub(now)
+}
+
+// openWindow returns when the occurrence of window that is open at now opened
+func openWindow(window databasev1.MaintenanceWindow, now time.Time) (time.Time, bool) {
+	now = now.UTC()
+	// Windows last at most a day, so one opened yesterday may still be open
+	for day := -1; day <= 0; day++ {
+		start, ok := windowStart(window, now, day)
+		if ok && !start.After(now) && now.Before(start.Add(window.Duration.Duration)) {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// nextWindow returns when window opens next after now
+func nextWindow(window databasev1.MaintenanceWindow, now time.Time) (time.Time, bool) {
+	now = now.UTC()
+	for day := 0; day <= 7; day++ {
+		if start, ok := windowStart(window, now, day); ok && start.After(now) {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// windowStart returns when window opens on the day offset days from now, if it opens that day
+func windowStart(window databasev1.MaintenanceWindow, now time.Time, offset int) (time.Time, bool) {
+	clock, err := time.Parse("15:04", window.Start)
+	if err != nil || window.Duration.Duration <= 0 {
+		return time.Time{}, false
+	}
+	start := time.Date(now.Year(), now.Month(), now.Day()+offset, clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+	if len(window.Days) == 0 {
+		return start, true
+	}
+	for _, day := range window.Days {
+		if string(day) == start.Weekday().String() {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	// Saturday 23:00 to Sunday 01:00
+	window := databasev1.MaintenanceWindow{
+		Days:     []databasev1.Weekday{"Saturday"},
+		Start:    "23:00",
+		Duration: metav1.Duration{Duration: 2 * time.Hour},
+	}
+	saturday := time.Date(2024, time.March, 2, 23, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		open bool
+		next time.Time
+	}{
+		{name: "before", now: saturday.Add(-time.Hour), next: saturday},
+		{name: "opening", now: saturday, open: true, next: saturday.AddDate(0, 0, 7)},
+		{name: "past midnight", now: saturday.Add(90 * time.Minute), open: true, next: saturday.AddDate(0, 0, 7)},
+		{name: "closed", now: saturday.Add(2 * time.Hour), next: saturday.AddDate(0, 0, 7)},
+		{name: "other timezone", now: saturday.Add(30 * time.Minute).In(time.FixedZone("UTC+9", 9*3600)), open: true, next: saturday.AddDate(0, 0, 7)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opened, open := openWindow(window, tt.now)
+			assert.Equal(t, tt.open, open)
+			if tt.open {
+				assert.Equal(t, saturday, opened)
+			}
+			next, ok := nextWindow(window, tt.now)
+			assert.True(t, ok)
+			assert.Equal(t, tt.next, next)
+		})
+	}
+}
+
+func TestMaintenanceDue(t *testing.T) {
+	window := databasev1.MaintenanceWindow{Start: "02:00", Duration: metav1.Duration{Duration: time.Hour}}
+	opened := time.Date(2024, time.March, 8, 2, 0, 0, 0, time.UTC)
+	startedAt := func(t time.Time) *databasev1.MaintenanceTaskStatus {
+		return &databasev1.MaintenanceTaskStatus{StartTime: &metav1.Time{Time: t}}
+	}
+	daily := databasev1.MaintenanceTask{Type: databasev1.MaintenanceVacuumAnalyze}
+	weekly := databasev1.MaintenanceTask{Type: databasev1.MaintenanceReindex, Interval: &metav1.Duration{Duration: 7 * 24 * time.Hour}}
+
+	assert.True(t, maintenanceDue(window, daily, &databasev1.MaintenanceTaskStatus{}, opened), "Never ran")
+	assert.False(t, maintenanceDue(window, daily, startedAt(opened.Add(time.Minute)), opened), "Already ran in this window")
+	assert.True(t, maintenanceDue(window, daily, startedAt(opened.Add(-24*time.Hour+time.Minute)), opened))
+	assert.False(t, maintenanceDue(window, weekly, startedAt(opened.Add(-24*time.Hour)), opened))
+	assert.True(t, maintenanceDue(window, weekly, startedAt(opened.Add(-7*24*time.Hour+time.Minute)), opened),
+		"The interval is measured from the window the last run started in")
+}
+
+func TestDatabaseReconciler_ReconcileMaintenance(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, batchv1.AddToScheme(scheme))
+
+	// A window that opened an hour ago
+	now := time.Now().UTC()
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default", UID: "test-uid"},
+		Spec: databasev1.DatabaseSpec{
+			Replicas:          1,
+			Image:             "postgres:15",
+			Storage:           1024,
+			PriorityClassName: "database-critical",
+			Maintenance: &databasev1.MaintenanceSpec{
+				Window: databasev1.MaintenanceWindow{
+					Start:    now.Add(-time.Hour).Format("15:04"),
+					Duration: metav1.Duration{Duration: 3 * time.Hour},
+				},
+				Tasks: []databasev1.MaintenanceTask{{Type: databasev1.MaintenanceVacuumAnalyze}},
+			},
+		},
+	}
+	database.SetCondition("Ready", metav1.ConditionTrue, "Ready", "Database is ready")
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme, Recorder: recorder}
+	ctx := context.Background()
+
+	require.NoError(t, reconciler.reconcileMaintenance(ctx, database))
+	require.Len(t, database.Status.Maintenance, 1)
+	status := database.Status.Maintenance[0]
+	assert.Equal(t, maintenanceRunning, status.Result)
+
+	job := &batchv1.Job{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Namespace: "default", Name: status.JobName}, job))
+	assert.Equal(t, []string{"vacuumdb", "--all", "--analyze"}, job.Spec.Template.Spec.Containers[0].Command)
+	require.NotNil(t, job.Spec.ActiveDeadlineSeconds)
+	assert.InDelta(t, 2*time.Hour.Seconds(), float64(*job.Spec.ActiveDeadlineSeconds), 60, "The Job stops when the window closes")
+	assert.Empty(t, checkPodPolicy(&job.Spec.Template.Spec, field.NewPath("spec")))
+	assert.Equal(t, "database-critical", job.Spec.Template.Spec.PriorityClassName)
+
+	// The Job fails; the outcome is recorded and the finished Job kept until the next window
+	job.Status.Conditions = []batchv1.JobCondition{{
+		Type:               batchv1.JobFailed,
+		Status:             corev1.ConditionTrue,
+		Reason:             "BackoffLimitExceeded",
+		Message:            "Job has reached the specified backoff limit",
+		LastTransitionTime: metav1.Now(),
+	}}
+	require.NoError(t, fakeClient.Status().Update(ctx, job))
+	require.NoError(t, reconciler.reconcileMaintenance(ctx, database))
+	status = database.Status.Maintenance[0]
+	assert.Equal(t, maintenanceFailed, status.Result)
+	assert.Contains(t, status.Message, "BackoffLimitExceeded")
+	assert.NotNil(t, status.CompletionTime)
+	assert.Len(t, recorder.Events, 1)
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(job), job))
+
+	// In the next window the record is removed, so the task runs again
+	database.Status.Maintenance[0].StartTime = &metav1.Time{Time: now.Add(-25 * time.Hour)}
+	job.Status.StartTime = database.Status.Maintenance[0].StartTime
+	require.NoError(t, fakeClient.Status().Update(ctx, job))
+	require.NoError(t, reconciler.reconcileMaintenance(ctx, database))
+	err := fakeClient.Get(ctx, client.ObjectKeyFromObject(job), job)
+	assert.True(t, apierrors.IsNotFound(err), "The finished Job of an earlier window is deleted")
+	assert.Len(t, recorder.Events, 1, "A recorded failure is not reported again")
+
+	// Removing maintenance clears the status; pruneChildren removes the Jobs
+	database.Spec.Maintenance = nil
+	require.NoError(t, reconciler.reconcileMaintenance(ctx, database))
+	assert.Empty(t, database.Status.Maintenance)
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/naming"
+)
+
+// Names of the children generated for a Database. Everything that creates, looks up or prunes
+// a child goes through these, so that long or punctuated Database names still produce valid
+// names. Names that were already valid are unchanged, so existing children are not renamed.
+
+// deploymentName is the name of the database Deployment
+func deploymentName(database *databasev1.Database) string {
+	return naming.Subdomain(database.Name)
+}
+
+// serviceName is the name of the database Service. Service names are DNS-1035 labels,
+// so a Database named e.g. orders.eu gets a shortened, hashed Service name.
+func serviceName(database *databasev1.Database) string {
+	return naming.Label(database.Name)
+}
+
+// pvcName is the name of the PersistentVolumeClaim holding the database data
+func pvcName(database *databasev1.Database) string {
+	return naming.Subdomain(database.Name)
+}
+
+// podDisruptionBudgetName is the name of the PodDisruptionBudget of a replicated Database
+func podDisruptionBudgetName(database *databasev1.Database) string {
+	return naming.Subdomain(database.Name)
+}
+
+// passwordSecretName returns the name of the Secret holding the database password
+func passwordSecretName(database *databasev1.Database) string {
+	if database.Spec.PasswordSecretName != "" {
+		return database.Spec.PasswordSecretName
+	}
+	return naming.Subdomain(database.Name, "password")
+}
+
+// pullSecretName is the name of the copy of an operator pull secret in the Database namespace.
+// Each Database owns its own copies, so they are garbage collected with it.
+func pullSecretName(database *databasev1.Database, source string) string {
+	return naming.Subdomain(database.Name, "pull", source)
+}
+
+// clusterPolicyChildName is the name of the NetworkPolicy a ClusterDatabasePolicy creates in
+// each selected namespace
+func clusterPolicyChildName(policy *databasev1.ClusterDatabasePolicy) string {
+	return naming.Subdomain("database-policy", policy.Name)
+}
+
+// backupJobName is the name of the Job taking a Backup. Job names end up in a pod label, so
+// they are DNS labels.
+func backupJobName(backup *databasev1.Backup) string {
+	return naming.Label(backup.Name, "backup")
+}
+
+// restoreJobName is the name of the Job running a Restore, a DNS label like backupJobName
+func restoreJobName(restore *databasev1.Restore) string {
+	return naming.Label(restore.Name, "restore")
+}
+
+// applicationDatabaseName is the name of the Database an Application creates
+func applicationDatabaseName(app *databasev1.Application) string {
+	return naming.Subdomain(app.Name, "db")
+}
+
+// applicationConnectionSecretName is the name of the Secret holding the connection variables
+// of an Application's web tier
+func applicationConnectionSecretName(app *databasev1.Application) string {
+	return naming.Subdomain(app.Name, "db", "connection")
+}
+
+// applicationWebName is the name of the web Deployment of an Application
+func applicationWebName(app *databasev1.Application) string {
+	return naming.Subdomain(app.Name, "web")
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestChildNames(t *testing.T) {
+	database := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "test-db"}}
+	assert.Equal(t, "test-db", deploymentName(database), "Existing children keep their names")
+	assert.Equal(t, "test-db", serviceName(database))
+	assert.Equal(t, "test-db-password", passwordSecretName(database))
+	assert.Equal(t, "test-db-pull-mirror", pullSecretName(database, "mirror"))
+
+	database.Name = strings.Repeat("a", 250)
+	for _, name := range []string{deploymentName(database), pvcName(database), passwordSecretName(database), pullSecretName(database, "mirror")} {
+		assert.Empty(t, validation.IsDNS1123Subdomain(name), name)
+	}
+	assert.True(t, strings.HasSuffix(passwordSecretName(database), "-password"))
+	assert.Empty(t, validation.IsDNS1035Label(serviceName(database)))
+
+	database.Spec.PasswordSecretName = "custom"
+	assert.Equal(t, "custom", passwordSecretName(database))
+}
+
+func TestDatabaseReconciler_PunctuatedName(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "orders.eu",
+			Namespace:  "default",
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database).
+		Build()
+
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "orders.eu", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	// Dots are not allowed in Service names
+	var services corev1.ServiceList
+	require.NoError(t, fakeClient.List(ctx, &services))
+	require.Len(t, services.Items, 1)
+	service := services.Items[0]
+	assert.Empty(t, validation.IsDNS1035Label(service.Name))
+	assert.Equal(t, "orders.eu", service.Labels[databaseNameLabel])
+
+	updated := &databasev1.Database{}
+	require.NoError(t, fakeClient.Get(ctx, key, updated))
+	assert.Equal(t, service.Name, updated.Status.ServiceName)
+
+	require.NoError(t, fakeClient.Get(ctx, key, &appsv1.Deployment{}), "Deployment names may contain dots")
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "orders.eu-password", Namespace: "default"}, &corev1.Secret{}))
+}
+
+func TestDatabaseReconciler_LongName(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	name := strings.Repeat("orders.", 14) + "eu"
+	require.Len(t, name, 100)
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       name,
+			Namespace:  "default",
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database).
+		WithIndex(&databasev1.Database{}, databaseLabelIndex, indexDatabaseLabel).
+		Build()
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme}
+	ctx := context.Background()
+	key := types.NamespacedName{Name: name, Namespace: "default"}
+
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	// Every label of every child is a valid label value
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: deploymentName(database), Namespace: "default"}, deployment))
+	var services corev1.ServiceList
+	require.NoError(t, fakeClient.List(ctx, &services))
+	require.Len(t, services.Items, 1)
+	secret := &corev1.Secret{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: passwordSecretName(database), Namespace: "default"}, secret))
+	labelSets := []map[string]string{
+		deployment.Labels, deployment.Spec.Selector.MatchLabels, deployment.Spec.Template.Labels,
+		services.Items[0].Labels, services.Items[0].Spec.Selector, secret.Labels,
+	}
+	for _, labels := range labelSets {
+		for key, value := range labels {
+			assert.Empty(t, validation.IsValidLabelValue(value), key)
+		}
+	}
+
+	// Pods and generated objects are mapped back to the Database
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "orders-0",
+		Namespace: "default",
+		Labels:    deployment.Spec.Template.Labels,
+	}}
+	assert.Contains(t, reconciler.findDatabaseForPod(ctx, pod), ctrl.Request{NamespacedName: key})
+	found := &databasev1.Database{}
+	require.NoError(t, getDatabaseForLabel(ctx, fakeClient, "default", secret.Labels[databaseNameLabel], found))
+	assert.Equal(t, name, found.Name)
+	assert.Equal(t, client.ObjectKeyFromObject(found), key)
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+// namespaceTerminating reports whether the namespace is being deleted.
+// The API server rejects creation of new objects in a terminating namespace,
+// so reconciling children there only produces an error loop.
+func namespaceTerminating(ctx context.Context, c client.Reader, name string) (bool, error) {
+	namespace := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, namespace); err != nil {
+		if errors.IsNotFound(err) {
+			// Let the child creates report the missing namespace
+			return false, nil
+		}
+		return false, err
+	}
+
+	return !namespace.DeletionTimestamp.IsZero() || namespace.Status.Phase == corev1.NamespaceTerminating, nil
+}
+
+// isNamespaceTerminatingError reports whether a create was rejected because the namespace is terminating.
+// This covers the race where the namespace starts terminating after namespaceTerminating was checked.
+func isNamespaceTerminatingError(err error) bool {
+	return errors.HasStatusCause(err, corev1.NamespaceTerminatingCause)
+}
+
+// listSelectedNamespaces lists the active namespaces matching the selector, sorted. A
+// non-empty watched restricts them to the namespaces the operator caches.
+func listSelectedNamespaces(ctx context.Context, c client.Reader, selector labels.Selector, watched []string) ([]string, error) {
+	var list corev1.NamespaceList
+	if err := c.List(ctx, &list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	watchedSet := sets.New(watched...)
+	var namespaces []string
+	for _, namespace := range list.Items {
+		if namespace.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+		if watchedSet.Len() > 0 && !watchedSet.Has(namespace.Name) {
+			continue
+		}
+		namespaces = append(namespaces, namespace.Name)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
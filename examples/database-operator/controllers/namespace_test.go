@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestDatabaseReconciler_TerminatingNamespace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "teardown"},
+		Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+	}
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-db",
+			Namespace:  "teardown",
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(namespace, database).
+		Build()
+
+	reconciler := &DatabaseReconciler{
+		Client: fakeClient,
+		Scheme: scheme,
+	}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "teardown"}
+
+	result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, ctrl.Result{}, result)
+
+	err = fakeClient.Get(ctx, key, &appsv1.Deployment{})
+	assert.True(t, errors.IsNotFound(err), "No children should be created in a terminating namespace")
+
+	// The namespace controller deletes the Database
+	require.NoError(t, fakeClient.Delete(ctx, database))
+
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	err = fakeClient.Get(ctx, key, &databasev1.Database{})
+	assert.True(t, errors.IsNotFound(err), "Finalizer should be removed so the Database goes away")
+}
+
+func TestIsNamespaceTerminatingError(t *testing.T) {
+	terminating := &errors.StatusError{ErrStatus: metav1.Status{
+		Reason: metav1.StatusReasonForbidden,
+		Details: &metav1.StatusDetails{
+			Causes: []metav1.StatusCause{{Type: corev1.NamespaceTerminatingCause}},
+		},
+	}}
+
+	assert.True(t, isNamespaceTerminatingError(terminating))
+	assert.False(t, isNamespaceTerminatingError(errors.NewForbidden(corev1.Resource("pods"), "test-pod", fmt.Errorf("denied"))))
+}
@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/pagination"
+)
+
+// orphanedObjects reports generated objects found without a valid owner on the last sweep
+var orphanedObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "database_orphaned_objects",
+	Help: "Number of operator-generated objects whose Database owner reference is missing or stale, by kind.",
+}, []string{"kind"})
+
+func init() {
+	metrics.Registry.MustRegister(orphanedObjects)
+}
+
+// setDatabaseLabel marks a generated object with the Database it belongs to. The label survives
+// when owner references do not, e.g. after a restore that recreated the Database with a new UID.
+func setDatabaseLabel(obj metav1.Object, database *databasev1.Database) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[databaseNameLabel] = databaseLabel(database)
+	obj.SetLabels(labels)
+}
+
+// orphanKinds are the generated kinds the sweeper checks
+var orphanKinds = []func() client.ObjectList{
+	func() client.ObjectList { return &corev1.SecretList{} },
+	func() client.ObjectList { return &corev1.ConfigMapList{} },
+}
+
+// OrphanSweeper periodically finds Secrets and ConfigMaps generated for a Database that are no
+// longer controlled by it. Owner references are matched by UID, so they break when a Database is
+// restored from a backup under a new UID, and the garbage collector may then delete children
+// that are still in use, or never delete children whose Database is gone.
+//
+// An orphan whose Database exists is re-adopted: the stale owner reference is replaced, so the
+// generated password is kept. An orphan whose Database does not exist is deleted. In dry-run
+// mode, the default, orphans are only logged and counted, so the result of a sweep can be
+// reviewed before it is allowed to change anything.
+type OrphanSweeper struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder events.EventRecorder
+
+	// Reader lists the generated objects page by page, so a sweep holds one page of them at a
+	// time; set it to the API reader, the cache has no pages. Nil lists them through Client.
+	Reader client.Reader
+
+	// PageSize is the number of generated objects listed at a time
+	PageSize int64
+
+	// Interval is how often generated objects are checked; zero disables the sweeper
+	Interval time.Duration
+
+	// MinAge skips objects younger than this, so that a Database not yet in the cache does
+	// not make its new children look orphaned
+	MinAge time.Duration
+
+	// DryRun only reports orphans
+	DryRun bool
+
+	// Switches make the sweep a dry run in break-glass mode; nil keeps DryRun
+	Switches *Switches
+}
+
+// DefaultOrphanSweeper holds the default sweeper settings
+var DefaultOrphanSweeper = OrphanSweeper{
+	PageSize: pagination.DefaultPageSize,
+	Interval: time.Hour,
+	MinAge:   10 * time.Minute,
+	DryRun:   true,
+}
+
+var _ manager.LeaderElectionRunnable = &OrphanSweeper{}
+
+// BindFlags registers flags for the sweeper, using the current values as defaults
+func (s *OrphanSweeper) BindFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&s.Interval, "orphan-sweep-interval", s.Interval,
+		"How often generated Secrets and ConfigMaps are checked for a missing Database owner. Zero disables the sweep.")
+	fs.DurationVar(&s.MinAge, "orphan-sweep-min-age", s.MinAge,
+		"Generated objects younger than this are never treated as orphaned.")
+	fs.BoolVar(&s.DryRun, "orphan-sweep-dry-run", s.DryRun,
+		"Only report orphaned objects instead of re-adopting or deleting them.")
+	fs.Int64Var(&s.PageSize, "orphan-sweep-page-size", s.PageSize,
+		"How many generated objects of a kind the orphan sweep lists at a time.")
+}
+
+// Start runs the sweeper until the context is cancelled
+func (s *OrphanSweeper) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("orphan-sweeper")
+	ctx = log.IntoContext(ctx, logger)
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.sweep(ctx); err != nil {
+			logger.Error(err, "failed to sweep orphaned objects")
+		}
+	}, s.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes only the leader adopt and delete objects
+func (s *OrphanSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+// sweep checks every labeled object of the orphan kinds once
+func (s *OrphanSweeper) sweep(ctx context.Context) error {
+	now := time.Now()
+	var errs []error
+
+	reader := s.Reader
+	if reader == nil {
+		reader = s.Client
+	}
+	for _, newList := range orphanKinds {
+		list := newList()
+		gvk, err := apiutil.GVKForObject(list, s.Scheme)
+		if err != nil {
+			return err
+		}
+		kind := gvk.Kind[:len(gvk.Kind)-len("List")]
+
+		orphans := 0
+		err = pagination.ForEach(ctx, reader, list, func(obj client.Object) error {
+			if now.Sub(obj.GetCreationTimestamp().Time) < s.MinAge {
+				return nil
+			}
+			orphaned, err := s.check(ctx, kind, obj)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if orphaned {
+				orphans++
+			}
+			return nil
+		}, client.HasLabels{databaseNameLabel}, client.Limit(s.PageSize))
+		if err != nil {
+			// A partial count would look like orphans were fixed
+			errs = append(errs, fmt.Errorf("failed to list generated objects: %w", err))
+			continue
+		}
+		orphanedObjects.WithLabelValues(kind).Set(float64(orphans))
+	}
+
+	return kerrors.NewAggregate(errs)
+}
+
+// check re-adopts or deletes obj if its Database no longer controls it and reports whether it was orphaned
+func (s *OrphanSweeper) check(ctx context.Context, kind string, obj client.Object) (bool, error) {
+	logger := log.FromContext(ctx).WithValues("kind", kind, "object", client.ObjectKeyFromObject(obj))
+
+	// Objects controlled by something other than a Database were adopted on purpose
+	if owner := metav1.GetControllerOf(obj); owner != nil && owner.Kind != "Database" {
+		return false, nil
+	}
+
+	database := &databasev1.Database{}
+	err := getDatabaseForLabel(ctx, s.Client, obj.GetNamespace(), obj.GetLabels()[databaseNameLabel], database)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+
+	dryRun := s.DryRun || s.Switches.InBreakGlass(nil) || (err == nil && s.Switches.InBreakGlass(database))
+	if errors.IsNotFound(err) {
+		if dryRun {
+			logger.Info("Found orphaned object whose Database does not exist; would delete it (dry run)")
+			return true, nil
+		}
+		// The UID precondition keeps a recreated object with the same name from being deleted
+		uid := obj.GetUID()
+		if err := s.Delete(ctx, obj, client.Preconditions{UID: &uid}); client.IgnoreNotFound(err) != nil {
+			return true, fmt.Errorf("failed to delete orphaned %s %s: %w", kind, obj.GetName(), err)
+		}
+		logger.Info("Deleted orphaned object whose Database does not exist")
+		return true, nil
+	}
+
+	if metav1.IsControlledBy(obj, database) || !database.DeletionTimestamp.IsZero() {
+		return false, nil
+	}
+
+	if dryRun {
+		logger.Info("Found object with a stale or missing Database owner reference; would re-adopt it (dry run)",
+			"database", database.Name)
+		return true, nil
+	}
+
+	// Merge patches replace the whole ownerReferences list, so foreign owners are kept as they are
+	patch := client.MergeFromWithOptions(obj.DeepCopyObject().(client.Object), client.MergeFromWithOptimisticLock{})
+	var refs []metav1.OwnerReference
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind != "Database" || ref.APIVersion != databasev1.GroupVersion.String() {
+			refs = append(refs, ref)
+		}
+	}
+	obj.SetOwnerReferences(refs)
+	if err := controllerutil.SetControllerReference(database, obj, s.Scheme); err != nil {
+		return true, err
+	}
+	if err := s.Patch(ctx, obj, patch); err != nil {
+		return true, client.IgnoreNotFound(err)
+	}
+
+	logger.Info("Re-adopted object with a stale or missing Database owner reference", "database", database.Name)
+	s.Recorder.Eventf(database, obj, corev1.EventTypeNormal, "OrphanAdopted", "AdoptOrphan",
+		"Re-adopted %s %s after its owner reference was lost", kind, obj.GetName())
+	return true, nil
+}
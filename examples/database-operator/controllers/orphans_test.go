@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestOrphanSweeper_Sweep(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	old := metav1.NewTime(time.Now().Add(-time.Hour))
+	restored := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "restored-db", Namespace: "default", UID: "new-uid"},
+	}
+	staleOwner := metav1.OwnerReference{
+		APIVersion: databasev1.GroupVersion.String(),
+		Kind:       "Database",
+		Name:       "restored-db",
+		UID:        "old-uid",
+		Controller: func() *bool { controller := true; return &controller }(),
+	}
+
+	objectMeta := func(name, database string, created metav1.Time) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			UID:               types.UID(name),
+			CreationTimestamp: created,
+			Labels:            map[string]string{databaseNameLabel: database},
+		}
+	}
+
+	// Password Secret of a Database restored under a new UID
+	password := &corev1.Secret{ObjectMeta: objectMeta("restored-db-password", "restored-db", old)}
+	password.OwnerReferences = []metav1.OwnerReference{staleOwner}
+	// ConfigMap of a Database that is gone
+	gone := &corev1.ConfigMap{ObjectMeta: objectMeta("gone-config", "gone-db", old)}
+	// Too young to judge; its Database may not be cached yet
+	young := &corev1.Secret{ObjectMeta: objectMeta("new-db-password", "new-db", metav1.Now())}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(restored, password, gone, young).
+		Build()
+
+	recorder := events.NewFakeRecorder(10)
+	sweeper := &OrphanSweeper{
+		Client:   fakeClient,
+		Scheme:   scheme,
+		Recorder: recorder,
+		MinAge:   10 * time.Minute,
+		DryRun:   true,
+	}
+
+	ctx := context.Background()
+	require.NoError(t, sweeper.sweep(ctx))
+
+	// A dry run reports without changing anything
+	assert.Equal(t, float64(1), testutil.ToFloat64(orphanedObjects.WithLabelValues("Secret")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(orphanedObjects.WithLabelValues("ConfigMap")))
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "gone-config", Namespace: "default"}, &corev1.ConfigMap{}))
+	secret := &corev1.Secret{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "restored-db-password", Namespace: "default"}, secret))
+	assert.False(t, metav1.IsControlledBy(secret, restored))
+	assert.Empty(t, recorder.Events)
+
+	sweeper.DryRun = false
+	require.NoError(t, sweeper.sweep(ctx))
+
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "restored-db-password", Namespace: "default"}, secret))
+	assert.True(t, metav1.IsControlledBy(secret, restored), "The Secret is re-adopted by the restored Database")
+	assert.Len(t, secret.OwnerReferences, 1, "The stale owner reference is replaced")
+
+	err := fakeClient.Get(ctx, types.NamespacedName{Name: "gone-config", Namespace: "default"}, &corev1.ConfigMap{})
+	assert.True(t, errors.IsNotFound(err), "The ConfigMap of a missing Database is deleted")
+
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "new-db-password", Namespace: "default"}, &corev1.Secret{}))
+
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "OrphanAdopted")
+
+	// Once repaired, the next sweep finds nothing
+	require.NoError(t, sweeper.sweep(ctx))
+	assert.Equal(t, float64(0), testutil.ToFloat64(orphanedObjects.WithLabelValues("Secret")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(orphanedObjects.WithLabelValues("ConfigMap")))
+}
+
+func TestOrphanSweeper_Pages(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	gone := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name: "gone-config", Namespace: "default",
+		CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+		Labels:            map[string]string{databaseNameLabel: "gone-db"},
+	}}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gone).Build()
+
+	// The generated objects are listed through Reader, a page at a time
+	var limits []int64
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gone).
+		WithInterceptorFuncs(interceptor.Funcs{
+			List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				limits = append(limits, (&client.ListOptions{}).ApplyOptions(opts).Limit)
+				return c.List(ctx, list, opts...)
+			},
+		}).
+		Build()
+	sweeper := &OrphanSweeper{
+		Client:   fakeClient,
+		Reader:   reader,
+		Scheme:   scheme,
+		Recorder: events.NewFakeRecorder(10),
+		PageSize: 50,
+		MinAge:   10 * time.Minute,
+		DryRun:   true,
+	}
+	require.NoError(t, sweeper.sweep(context.Background()))
+	assert.Equal(t, []int64{50, 50}, limits, "One page of Secrets and one of ConfigMaps")
+	assert.Equal(t, float64(1), testutil.ToFloat64(orphanedObjects.WithLabelValues("ConfigMap")))
+}
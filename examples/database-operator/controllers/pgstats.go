@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// maxQueryText bounds the statement text kept in the status
+const maxQueryText = 256
+
+const (
+	// connectionsQuery counts client connections by state; the collector's own is left out
+	connectionsQuery = `SELECT COALESCE(state, 'unknown'), count(*)
+FROM pg_stat_activity
+WHERE backend_type = 'client backend' AND pid <> pg_backend_pid()
+GROUP BY 1`
+
+	maxConnectionsQuery = `SELECT current_setting('max_connections')::int`
+
+	longestTransactionQuery = `SELECT COALESCE(EXTRACT(EPOCH FROM max(clock_timestamp() - xact_start)), 0)::bigint
+FROM pg_stat_activity
+WHERE backend_type = 'client backend' AND pid <> pg_backend_pid()`
+
+	statementsInstalledQuery = `SELECT count(*) > 0 FROM pg_extension WHERE extname = 'pg_stat_statements'`
+
+	// slowQueriesQuery needs PostgreSQL 13 or later, which renamed mean_time to mean_exec_time
+	slowQueriesQuery = `SELECT queryid::text, left(query, $1), calls, round(mean_exec_time)::bigint
+FROM pg_stat_statements
+WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database()) AND queryid IS NOT NULL AND calls > 0
+ORDER BY mean_exec_time DESC
+LIMIT $2`
+)
+
+// SQLStats queries the statistics views with database/sql. The driver is linked into the
+// binary with a blank import, e.g. _ "github.com/lib/pq" in main.
+type SQLStats struct {
+	// Driver is the database/sql driver name; "postgres" when empty
+	Driver string
+}
+
+// QueryStats connects, takes the snapshot and disconnects. Slow queries are left out when
+// pg_stat_statements is not installed in the database.
+func (s *SQLStats) QueryStats(ctx context.Context, dsn string, topQueries int) (*databasev1.DatabaseStats, error) {
+	driver := s.Driver
+	if driver == "" {
+		driver = "postgres"
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	stats := &databasev1.DatabaseStats{Connections: map[string]int32{}}
+	rows, err := db.QueryContext(ctx, connectionsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var state string
+		var count int32
+		if err := rows.Scan(&state, &count); err != nil {
+			return nil, err
+		}
+		stats.Connections[state] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := db.QueryRowContext(ctx, maxConnectionsQuery).Scan(&stats.MaxConnections); err != nil {
+		return nil, err
+	}
+	if err := db.QueryRowContext(ctx, longestTransactionQuery).Scan(&stats.LongestTransactionSeconds); err != nil {
+		return nil, err
+	}
+
+	var installed bool
+	if err := db.QueryRowContext(ctx, statementsInstalledQuery).Scan(&installed); err != nil {
+		return nil, err
+	}
+	if !installed || topQueries <= 0 {
+		return stats, nil
+	}
+	if stats.SlowQueries, err = slowQueries(ctx, db, topQueries); err != nil {
+		// Installed but not in shared_preload_libraries: the connection statistics still help
+		log.FromContext(ctx).V(1).Info("pg_stat_statements is not usable", "error", err.Error())
+	}
+	return stats, nil
+}
+
+func slowQueries(ctx context.Context, db *sql.DB, limit int) ([]databasev1.SlowQuery, error) {
+	rows, err := db.QueryContext(ctx, slowQueriesQuery, maxQueryText, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var queries []databasev1.SlowQuery
+	for rows.Next() {
+		var query databasev1.SlowQuery
+		if err := rows.Scan(&query.QueryID, &query.Query, &query.Calls, &query.MeanMilliseconds); err != nil {
+			return nil, err
+		}
+		queries = append(queries, query)
+	}
+	return queries, rows.Err()
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/plugins"
+)
+
+// conditionProvisionAllowed is True once the pre-provision plugins allowed the children of a
+// Database to be created; they are not called again after that
+const conditionProvisionAllowed = "ProvisionAllowed"
+
+// Reasons of the ProvisionAllowed condition and of plugin events
+const (
+	reasonPluginsAllowed     = "PluginsAllowed"
+	reasonAlreadyProvisioned = "AlreadyProvisioned"
+	reasonPluginDenied       = "PluginDenied"
+	reasonPluginFailed       = "PluginFailed"
+)
+
+// pluginRetryInterval is how soon a plugin that denied or failed is called again
+const pluginRetryInterval = 30 * time.Second
+
+// runPreProvisionPlugins calls the pre-provision plugins before the children of a Database are
+// first created. It returns done when the reconcile must stop because a plugin denied it or
+// failed; the Database then waits with ProvisionAllowed False and is retried.
+func (r *DatabaseReconciler) runPreProvisionPlugins(ctx context.Context, database *databasev1.Database) (ctrl.Result, bool, error) {
+	if r.Plugins.Len() == 0 || meta.IsStatusConditionTrue(database.Status.Conditions, conditionProvisionAllowed) {
+		return ctrl.Result{}, false, nil
+	}
+	// Databases provisioned before the plugins were configured are not held back
+	if database.Status.ObservedGeneration != 0 {
+		database.SetCondition(conditionProvisionAllowed, metav1.ConditionTrue, reasonAlreadyProvisioned, "Database was provisioned before the plugins ran")
+		return ctrl.Result{}, false, nil
+	}
+
+	response, err := r.Plugins.Run(ctx, plugins.PreProvision, database)
+	if err != nil {
+		return r.holdForPlugin(ctx, database, reasonPluginFailed, err.Error())
+	}
+	if !response.Allowed {
+		return r.holdForPlugin(ctx, database, reasonPluginDenied, response.Message)
+	}
+	database.SetCondition(conditionProvisionAllowed, metav1.ConditionTrue, reasonPluginsAllowed, "Pre-provision plugins allowed the Database")
+	return ctrl.Result{}, false, nil
+}
+
+// holdForPlugin records why provisioning waits and retries later
+func (r *DatabaseReconciler) holdForPlugin(ctx context.Context, database *databasev1.Database, reason, message string) (ctrl.Result, bool, error) {
+	r.warn(database, nil, reason, "PreProvision", message)
+	database.Status.Phase = "Pending"
+	database.SetCondition(conditionProvisionAllowed, metav1.ConditionFalse, reason, message)
+	database.SetCondition("Ready", metav1.ConditionFalse, reason, message)
+	if err := r.Status().Update(ctx, database); err != nil {
+		return ctrl.Result{}, true, err
+	}
+	return ctrl.Result{RequeueAfter: pluginRetryInterval}, true, nil
+}
+
+// runPostReadyPlugins calls the post-ready plugins when a Database became ready in this
+// reconcile. They only get notified: a failure is reported as an event and not retried.
+func (r *DatabaseReconciler) runPostReadyPlugins(ctx context.Context, database *databasev1.Database, wasReady bool) {
+	if r.Plugins.Len() == 0 || wasReady || !database.IsReady() {
+		return
+	}
+	if _, err := r.Plugins.Run(ctx, plugins.PostReady, database); err != nil {
+		log.FromContext(ctx).Error(err, "post-ready plugin failed")
+		r.warn(database, nil, reasonPluginFailed, "PostReady", err.Error())
+	}
+}
+
+// runPreDeletePlugins calls the pre-delete plugins before the finalizer of a Database is
+// removed. It returns done while a plugin denies the deletion or fails; the finalizer stays
+// and the plugins are called again later, unless the namespace is terminating.
+func (r *DatabaseReconciler) runPreDeletePlugins(ctx context.Context, database *databasev1.Database) (ctrl.Result, bool) {
+	response, err := r.Plugins.Run(ctx, plugins.PreDelete, database)
+	switch {
+	case err != nil:
+		r.warn(database, nil, reasonPluginFailed, "PreDelete", err.Error())
+	case !response.Allowed:
+		r.warn(database, nil, reasonPluginDenied, "PreDelete", response.Message)
+	default:
+		return ctrl.Result{}, false
+	}
+	return ctrl.Result{RequeueAfter: pluginRetryInterval}, true
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/plugins"
+)
+
+// pluginStub answers every point with the response set for it, recording the calls
+type pluginStub struct {
+	responses map[plugins.Point]plugins.Response
+	calls     []plugins.Point
+}
+
+func (p *pluginStub) Points(context.Context) ([]plugins.Point, error) {
+	return []plugins.Point{plugins.PreProvision, plugins.PostReady, plugins.PreDelete}, nil
+}
+
+func (p *pluginStub) Call(_ context.Context, req plugins.Request) (plugins.Response, error) {
+	p.calls = append(p.calls, req.Point)
+	return p.responses[req.Point], nil
+}
+
+func TestDatabaseReconciler_Plugins(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "orders",
+			Namespace:  "default",
+			Generation: 1,
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database).
+		Build()
+	stub := &pluginStub{responses: map[plugins.Point]plugins.Response{
+		plugins.PreProvision: {Allowed: false, Message: "no budget code"},
+		plugins.PreDelete:    {Allowed: false, Message: "backup not exported"},
+	}}
+	registry := &plugins.Registry{}
+	registry.Add("site", stub)
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme, Recorder: recorder, Plugins: registry}
+	ctx := context.Background()
+	key := client.ObjectKeyFromObject(database)
+	deploymentKey := client.ObjectKey{Namespace: "default", Name: deploymentName(database)}
+
+	// A denial holds the provisioning back
+	result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, pluginRetryInterval, result.RequeueAfter)
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	condition := database.GetCondition(conditionProvisionAllowed)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, reasonPluginDenied, condition.Reason)
+	assert.Equal(t, "plugin site: no budget code", condition.Message)
+	assert.Contains(t, <-recorder.Events, reasonPluginDenied)
+	err = fakeClient.Get(ctx, deploymentKey, &appsv1.Deployment{})
+	assert.True(t, apierrors.IsNotFound(err), "Nothing was created")
+
+	// Once allowed, the Database is provisioned and the plugin is not asked again
+	stub.responses[plugins.PreProvision] = plugins.Response{Allowed: true}
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, deploymentKey, &appsv1.Deployment{}))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.True(t, database.GetCondition(conditionProvisionAllowed).Status == metav1.ConditionTrue)
+	assert.Equal(t, []plugins.Point{plugins.PreProvision, plugins.PreProvision}, stub.calls)
+
+	// A denied deletion keeps the finalizer
+	require.NoError(t, fakeClient.Delete(ctx, database))
+	result, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, pluginRetryInterval, result.RequeueAfter)
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.Contains(t, database.Finalizers, databaseFinalizer)
+
+	stub.responses[plugins.PreDelete] = plugins.Response{Allowed: true}
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	err = fakeClient.Get(ctx, key, database)
+	assert.True(t, apierrors.IsNotFound(err), "The finalizer was removed")
+}
+
+func TestDatabaseReconciler_PreDeletePluginsInTerminatingNamespace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	now := metav1.Now()
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "orders",
+			Namespace:         "teardown",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "teardown"},
+		Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(namespace, database).Build()
+	stub := &pluginStub{responses: map[plugins.Point]plugins.Response{
+		plugins.PreDelete: {Allowed: false, Message: "backup not exported"},
+	}}
+	registry := &plugins.Registry{}
+	registry.Add("site", stub)
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme, Recorder: recorder, Plugins: registry}
+	ctx := context.Background()
+	key := client.ObjectKeyFromObject(database)
+
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, []plugins.Point{plugins.PreDelete}, stub.calls, "The plugin is called during teardown")
+	assert.Contains(t, <-recorder.Events, reasonPluginDenied)
+	err = fakeClient.Get(ctx, key, database)
+	assert.True(t, apierrors.IsNotFound(err), "A denial does not hold up the namespace")
+}
+
+func TestDatabaseReconciler_PostReadyPlugins(t *testing.T) {
+	stub := &pluginStub{}
+	registry := &plugins.Registry{}
+	registry.Add("site", stub)
+	reconciler := &DatabaseReconciler{Plugins: registry}
+	ctx := context.Background()
+
+	database := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "default"}}
+	reconciler.runPostReadyPlugins(ctx, database, false)
+	assert.Empty(t, stub.calls, "Not ready yet")
+
+	database.SetCondition("Ready", metav1.ConditionTrue, "Ready", "Database is ready")
+	reconciler.runPostReadyPlugins(ctx, database, false)
+	assert.Equal(t, []plugins.Point{plugins.PostReady}, stub.calls)
+
+	reconciler.runPostReadyPlugins(ctx, database, true)
+	assert.Len(t, stub.calls, 1, "Only the transition to ready is reported")
+
+	// Databases provisioned before the plugins were configured are not held back
+	database.Status.ObservedGeneration = 2
+	_, done, err := reconciler.runPreProvisionPlugins(ctx, database)
+	require.NoError(t, err)
+	assert.False(t, done)
+	assert.Equal(t, reasonAlreadyProvisioned, database.GetCondition(conditionProvisionAllowed).Reason)
+	assert.Len(t, stub.calls, 1)
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// podPolicyPath serves the pod policy webhook. The ValidatingWebhookConfiguration is kept by
+// hand in config/webhook/pod_policy.yaml rather than generated from a marker, because
+// controller-gen cannot emit the objectSelector that limits it to database pods.
+const podPolicyPath = "/validate-v1-pod-database"
+
+// PodPolicyValidator rejects database pods that break the operator's pod policy.
+//
+// The operator renders conforming pod templates, but a Deployment can be edited after the
+// fact (kubectl set image, a patch from another tool, a mutating webhook) and the ReplicaSet
+// controller creates pods from whatever the template says by then. Validating the pods
+// themselves is the last point where a :latest image or a missing resource request can be
+// stopped. Rejected pods show up as FailedCreate events on the ReplicaSet and stall the rollout.
+//
+// The webhook only sees pods carrying the database.my.domain/name label, so other workloads
+// in the cluster are never sent to the operator.
+type PodPolicyValidator struct {
+	// Switches can turn the webhook off at runtime; nil keeps it on
+	Switches *Switches
+}
+
+var _ admission.CustomValidator = &PodPolicyValidator{}
+
+// ValidateCreate checks a new database pod
+func (v *PodPolicyValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.validate(obj)
+}
+
+// ValidateUpdate checks a database pod update; only images are mutable on a running pod
+func (v *PodPolicyValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return v.validate(newObj)
+}
+
+// ValidateDelete always allows deletion
+func (v *PodPolicyValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *PodPolicyValidator) validate(obj runtime.Object) (admission.Warnings, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil, fmt.Errorf("expected a Pod but got %T", obj)
+	}
+
+	errs := checkPodPolicy(&pod.Spec, field.NewPath("spec"))
+	if len(errs) == 0 {
+		return nil, nil
+	}
+
+	// Pods created by a ReplicaSet only have generateName at admission time
+	name := pod.Name
+	if name == "" {
+		name = pod.GenerateName
+	}
+	return nil, apierrors.NewInvalid(corev1.SchemeGroupVersion.WithKind("Pod").GroupKind(), name, errs)
+}
+
+// checkPodPolicy returns the policy violations of a pod spec:
+//   - images must be pinned to a tag other than latest or to a digest
+//   - containers must request CPU and memory
+//   - containers must not run privileged or allow privilege escalation, and the pod must not
+//     share the host's network, PID or IPC namespaces
+func checkPodPolicy(spec *corev1.PodSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if spec.HostNetwork {
+		errs = append(errs, field.Forbidden(path.Child("hostNetwork"), "database pods must not use the host network"))
+	}
+	if spec.HostPID {
+		errs = append(errs, field.Forbidden(path.Child("hostPID"), "database pods must not share the host PID namespace"))
+	}
+	if spec.HostIPC {
+		errs = append(errs, field.Forbidden(path.Child("hostIPC"), "database pods must not share the host IPC namespace"))
+	}
+
+	for i := range spec.InitContainers {
+		errs = append(errs, checkContainerPolicy(&spec.InitContainers[i], path.Child("initContainers").Index(i))...)
+	}
+	for i := range spec.Containers {
+		errs = append(errs, checkContainerPolicy(&spec.Containers[i], path.Child("containers").Index(i))...)
+	}
+	return errs
+}
+
+func checkContainerPolicy(container *corev1.Container, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if _, tag, digest := parseImage(container.Image); digest == "" && tag == "latest" {
+		errs = append(errs, field.Invalid(path.Child("image"), container.Image,
+			"image must be pinned to a tag other than latest or to a digest"))
+	}
+
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if _, ok := container.Resources.Requests[name]; !ok {
+			errs = append(errs, field.Required(path.Child("resources", "requests", string(name)),
+				"database containers must request CPU and memory"))
+		}
+	}
+
+	securityContext := container.SecurityContext
+	if securityContext == nil || securityContext.AllowPrivilegeEscalation == nil || *securityContext.AllowPrivilegeEscalation {
+		errs = append(errs, field.Required(path.Child("securityContext", "allowPrivilegeEscalation"),
+			"must be set to false"))
+	}
+	if securityContext != nil && securityContext.Privileged != nil && *securityContext.Privileged {
+		errs = append(errs, field.Forbidden(path.Child("securityContext", "privileged"),
+			"database containers must not run privileged"))
+	}
+	return errs
+}
+
+// SetupWebhookWithManager serves the pod policy webhook on the Manager's webhook server
+func (v *PodPolicyValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(podPolicyPath, admission.WithCustomValidator(mgr.GetScheme(), &corev1.Pod{},
+		v.Switches.Validator(PodPolicyWebhook, guardValidator(PodPolicyWebhook, v))))
+	return nil
+}
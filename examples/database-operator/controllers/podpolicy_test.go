@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func newPolicyPod() *corev1.Pod {
+	allowPrivilegeEscalation := false
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "test-db-5d8f7-",
+			Namespace:    "default",
+			Labels:       map[string]string{databaseNameLabel: "test-db"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "database",
+				Image: "postgres:15",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("500m"),
+						corev1.ResourceMemory: resource.MustParse("1Gi"),
+					},
+				},
+				SecurityContext: &corev1.SecurityContext{AllowPrivilegeEscalation: &allowPrivilegeEscalation},
+			}},
+		},
+	}
+}
+
+func TestPodPolicyValidator(t *testing.T) {
+	privileged := true
+
+	tests := []struct {
+		name   string
+		mutate func(pod *corev1.Pod)
+		fields []string
+	}{
+		{
+			name:   "conforming",
+			mutate: func(pod *corev1.Pod) {},
+		},
+		{
+			name:   "latest tag",
+			mutate: func(pod *corev1.Pod) { pod.Spec.Containers[0].Image = "postgres:latest" },
+			fields: []string{"spec.containers[0].image"},
+		},
+		{
+			name:   "untagged",
+			mutate: func(pod *corev1.Pod) { pod.Spec.Containers[0].Image = "registry.example.com:5000/postgres" },
+			fields: []string{"spec.containers[0].image"},
+		},
+		{
+			name: "latest pinned to a digest",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.Containers[0].Image = "postgres:latest@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+			},
+		},
+		{
+			name:   "no memory request",
+			mutate: func(pod *corev1.Pod) { delete(pod.Spec.Containers[0].Resources.Requests, corev1.ResourceMemory) },
+			fields: []string{"spec.containers[0].resources.requests.memory"},
+		},
+		{
+			name:   "no security context",
+			mutate: func(pod *corev1.Pod) { pod.Spec.Containers[0].SecurityContext = nil },
+			fields: []string{"spec.containers[0].securityContext.allowPrivilegeEscalation"},
+		},
+		{
+			name:   "privileged",
+			mutate: func(pod *corev1.Pod) { pod.Spec.Containers[0].SecurityContext.Privileged = &privileged },
+			fields: []string{"spec.containers[0].securityContext.privileged"},
+		},
+		{
+			name: "injected init container and host network",
+			mutate: func(pod *corev1.Pod) {
+				pod.Spec.HostNetwork = true
+				pod.Spec.InitContainers = []corev1.Container{{Name: "init", Image: "busybox"}}
+			},
+			fields: []string{
+				"spec.hostNetwork",
+				"spec.initContainers[0].image",
+				"spec.initContainers[0].resources.requests.cpu",
+				"spec.initContainers[0].resources.requests.memory",
+				"spec.initContainers[0].securityContext.allowPrivilegeEscalation",
+			},
+		},
+	}
+
+	validator := &PodPolicyValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newPolicyPod()
+			tt.mutate(pod)
+
+			_, err := validator.ValidateCreate(context.Background(), pod)
+			if len(tt.fields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.True(t, apierrors.IsInvalid(err))
+
+			var fields []string
+			for _, cause := range err.(*apierrors.StatusError).ErrStatus.Details.Causes {
+				fields = append(fields, cause.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
+
+func TestPodPolicyValidator_UpdateAndDelete(t *testing.T) {
+	validator := &PodPolicyValidator{}
+	ctx := context.Background()
+
+	updated := newPolicyPod()
+	updated.Spec.Containers[0].Image = "postgres"
+	_, err := validator.ValidateUpdate(ctx, newPolicyPod(), updated)
+	assert.Error(t, err, "Switching a running pod to an unpinned image is rejected")
+
+	_, err = validator.ValidateDelete(ctx, updated)
+	assert.NoError(t, err)
+
+	_, err = validator.ValidateCreate(ctx, &corev1.Service{})
+	assert.Error(t, err)
+}
+
+func TestDatabaseReconciler_RendersPolicyConformingPods(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-db",
+			Namespace:  "default",
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database, newDefaultsConfigMap()).
+		WithStatusSubresource(database).
+		Build()
+
+	reconciler := &DatabaseReconciler{
+		Client:   fakeClient,
+		Scheme:   scheme,
+		Defaults: newDefaultsSource(fakeClient),
+	}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	assert.Contains(t, deployment.Spec.Template.Labels, databaseNameLabel,
+		"The webhook objectSelector matches rendered pods")
+	assert.Empty(t, checkPodPolicy(&deployment.Spec.Template.Spec, field.NewPath("spec")))
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+const (
+	// databaseNameLabel is set on database pods so pod events can be mapped back to their Database,
+	// on generated Secrets and ConfigMaps so the orphan sweeper can find them, and on the Service
+	databaseNameLabel = "database.my.domain/name"
+
+	// databaseRoleLabel carries the role of a database pod in the topology
+	databaseRoleLabel = "database.my.domain/role"
+)
+
+// listDatabasePods lists the pods running the given Database. They are not copied out of the
+// cache: the pods returned must not be changed, only patched through a copy.
+func (r *DatabaseReconciler) listDatabasePods(ctx context.Context, database *databasev1.Database) ([]corev1.Pod, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods,
+		client.InNamespace(database.Namespace),
+		client.MatchingLabels(selectorLabels(database)),
+		client.UnsafeDisableDeepCopy,
+	); err != nil {
+		return nil, fmt.Errorf("failed to list database pods: %w", err)
+	}
+	return pods.Items, nil
+}
+
+// podStatuses summarizes pods for the Database status, sorted by name for stable output
+func podStatuses(pods []corev1.Pod) []databasev1.DatabasePodStatus {
+	statuses := make([]databasev1.DatabasePodStatus, 0, len(pods))
+	for i := range pods {
+		pod := &pods[i]
+		var restarts int32
+		for _, status := range pod.Status.ContainerStatuses {
+			restarts += status.RestartCount
+		}
+
+		statuses = append(statuses, databasev1.DatabasePodStatus{
+			Name:     pod.Name,
+			Role:     pod.Labels[databaseRoleLabel],
+			Ready:    isPodReady(pod),
+			Restarts: restarts,
+			Node:     pod.Spec.NodeName,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// isPodReady returns true if the pod's Ready condition is True
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodStatuses(t *testing.T) {
+	pods := []corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "test-db-b",
+				Labels: map[string]string{databaseRoleLabel: "replica"},
+			},
+			Spec: corev1.PodSpec{NodeName: "node-2"},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "database", RestartCount: 3},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "test-db-a",
+				Labels: map[string]string{databaseRoleLabel: "primary"},
+			},
+			Spec: corev1.PodSpec{NodeName: "node-1"},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{
+					{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+				},
+			},
+		},
+	}
+
+	statuses := podStatuses(pods)
+
+	assert.Len(t, statuses, 2)
+	assert.Equal(t, "test-db-a", statuses[0].Name, "Pods should be sorted by name")
+	assert.Equal(t, "primary", statuses[0].Role)
+	assert.True(t, statuses[0].Ready)
+	assert.Equal(t, "node-1", statuses[0].Node)
+
+	assert.Equal(t, "test-db-b", statuses[1].Name)
+	assert.False(t, statuses[1].Ready)
+	assert.Equal(t, int32(3), statuses[1].Restarts)
+}
@@ -0,0 +1,351 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// Resources limited by a SkillsQuota, named as in the messages of exceeded quotas
+const (
+	quotaDatabases = "databases"
+	quotaStorage   = "storage"
+	quotaReplicas  = "replicas"
+)
+
+// quotaResources are the limited resources in the order they are reported
+var quotaResources = []string{quotaDatabases, quotaStorage, quotaReplicas}
+
+// conditionQuotaExceeded is the SkillsQuota condition listing the namespaces over a limit
+const conditionQuotaExceeded = "Exceeded"
+
+// SkillsQuotaReconciler records how much of a SkillsQuota every selected namespace uses.
+//
+// The quota is a policy object: it changes nothing itself, and is enforced by the validating
+// webhook of Databases, which reads it on every create and scale-up, like the API server reads
+// ResourceQuotas. The controller only makes the usage visible, including namespaces over a
+// limit that was lowered below their usage, or that were filled while the webhook was
+// switched off or unreachable; those Databases keep running, but cannot grow.
+type SkillsQuotaReconciler struct {
+	client.Client
+
+	// Namespaces limits the usage to the namespaces the operator watches; the cache holds no
+	// Databases elsewhere. Empty means all namespaces.
+	Namespaces []string
+
+	// Switches can turn the controller off at runtime; nil keeps it on
+	Switches *Switches
+}
+
+//+kubebuilder:rbac:groups=my.domain,resources=skillsquotas,verbs=get;list;watch
+//+kubebuilder:rbac:groups=my.domain,resources=skillsquotas/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+// Reconcile records the usage of every selected namespace and whether any exceeds the quota
+func (r *SkillsQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	quota := &databasev1.SkillsQuota{}
+	if err := r.Get(ctx, req.NamespacedName, quota); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !quota.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	selector, err := quotaSelector(quota)
+	if err != nil {
+		// Retrying cannot fix an invalid selector; wait for the spec to change
+		return ctrl.Result{}, r.updateQuotaStatus(ctx, quota, nil, "InvalidSelector", err)
+	}
+
+	namespaces, err := listSelectedNamespaces(ctx, r.Client, selector, r.Namespaces)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var databases databasev1.DatabaseList
+	if err := r.List(ctx, &databases); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list Databases: %w", err)
+	}
+	byNamespace := map[string][]databasev1.Database{}
+	for _, database := range databases.Items {
+		byNamespace[database.Namespace] = append(byNamespace[database.Namespace], database)
+	}
+
+	usage := make([]databasev1.NamespaceUsage, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		usage = append(usage, namespaceUsage(namespace, byNamespace[namespace]))
+	}
+	return ctrl.Result{}, r.updateQuotaStatus(ctx, quota, usage, "", nil)
+}
+
+// updateQuotaStatus records the usage and the namespaces exceeding the quota, or the failure.
+// The usage of a failed reconcile is left as it was.
+func (r *SkillsQuotaReconciler) updateQuotaStatus(ctx context.Context, quota *databasev1.SkillsQuota, usage []databasev1.NamespaceUsage, reason string, err error) error {
+	ready := metav1.Condition{
+		Type:               "Ready",
+		Status:             metav1.ConditionTrue,
+		Reason:             "UsageRecorded",
+		Message:            fmt.Sprintf("Usage recorded for %d namespaces", len(usage)),
+		ObservedGeneration: quota.Generation,
+	}
+	if err != nil {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = reason
+		ready.Message = err.Error()
+	} else {
+		quota.Status.Usage = usage
+
+		exceeded := metav1.Condition{
+			Type:               conditionQuotaExceeded,
+			Status:             metav1.ConditionFalse,
+			Reason:             "WithinLimits",
+			Message:            "Every namespace is within the limits",
+			ObservedGeneration: quota.Generation,
+		}
+		var over []string
+		for _, namespace := range usage {
+			if excess := exceededLimits(quota, usageAmounts(namespace)); excess != "" {
+				over = append(over, fmt.Sprintf("%s: %s", namespace.Namespace, excess))
+			}
+		}
+		if len(over) > 0 {
+			exceeded.Status = metav1.ConditionTrue
+			exceeded.Reason = "LimitsExceeded"
+			exceeded.Message = strings.Join(over, "; ")
+		}
+		meta.SetStatusCondition(&quota.Status.Conditions, exceeded)
+	}
+	quota.Status.ObservedGeneration = quota.Generation
+	meta.SetStatusCondition(&quota.Status.Conditions, ready)
+
+	if updateErr := r.Status().Update(ctx, quota); updateErr != nil {
+		return updateErr
+	}
+	if reason == "InvalidSelector" {
+		return nil
+	}
+	return err
+}
+
+// findQuotasForNamespace maps a namespace to the quotas that select it now or still report its
+// usage, so that relabeled namespaces are added to or removed from the usage
+func (r *SkillsQuotaReconciler) findQuotasForNamespace(ctx context.Context, o client.Object) []reconcile.Request {
+	return r.quotasFor(ctx, o.GetName(), o.GetLabels())
+}
+
+// findQuotasForDatabase maps a Database to the quotas that select its namespace
+func (r *SkillsQuotaReconciler) findQuotasForDatabase(ctx context.Context, o client.Object) []reconcile.Request {
+	namespace := &corev1.Namespace{}
+	if err := r.Get(ctx, client.ObjectKey{Name: o.GetNamespace()}, namespace); err != nil {
+		log.FromContext(ctx).Error(err, "failed to get namespace", "namespace", o.GetNamespace())
+		return nil
+	}
+	return r.quotasFor(ctx, namespace.Name, namespace.Labels)
+}
+
+func (r *SkillsQuotaReconciler) quotasFor(ctx context.Context, namespace string, namespaceLabels map[string]string) []reconcile.Request {
+	var list databasev1.SkillsQuotaList
+	if err := r.List(ctx, &list); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list SkillsQuotas")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, quota := range list.Items {
+		selector, err := quotaSelector(&quota)
+		if quotaReports(&quota, namespace) || err == nil && selector.Matches(labels.Set(namespaceLabels)) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: quota.Name},
+			})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager
+func (r *SkillsQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&databasev1.SkillsQuota{}).
+		// Creates, deletes and spec changes change the usage; status updates do not
+		Watches(
+			&databasev1.Database{},
+			handler.EnqueueRequestsFromMapFunc(r.findQuotasForDatabase),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Watches(
+			&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.findQuotasForNamespace),
+			builder.WithPredicates(predicate.LabelChangedPredicate{}),
+		).
+		Complete(r.Switches.Controller(SkillsQuotaController, r))
+}
+
+// validateQuotas rejects a new Database, or an update growing one, that would take its
+// namespace over a limit of a SkillsQuota selecting it. Only the resources the request adds
+// are checked, so a namespace over a lowered limit can still change and shrink its Databases.
+// Two concurrent requests can both be admitted; the quota controller reports the excess.
+func (v *DatabaseValidator) validateQuotas(ctx context.Context, old, database *databasev1.Database) error {
+	if v.Reader == nil {
+		return nil
+	}
+	requested := databaseAmounts(database)
+	if old != nil {
+		for resource, amount := range databaseAmounts(old) {
+			requested[resource] -= amount
+		}
+	}
+	if requested[quotaDatabases] <= 0 && requested[quotaStorage] <= 0 && requested[quotaReplicas] <= 0 {
+		return nil
+	}
+
+	var quotas databasev1.SkillsQuotaList
+	if err := v.Reader.List(ctx, &quotas); err != nil {
+		return fmt.Errorf("failed to list SkillsQuotas: %w", err)
+	}
+	if len(quotas.Items) == 0 {
+		return nil
+	}
+	namespace := &corev1.Namespace{}
+	if err := v.Reader.Get(ctx, client.ObjectKey{Name: database.Namespace}, namespace); err != nil {
+		return fmt.Errorf("failed to get namespace %s: %w", database.Namespace, err)
+	}
+	var databases databasev1.DatabaseList
+	if err := v.Reader.List(ctx, &databases, client.InNamespace(database.Namespace)); err != nil {
+		return fmt.Errorf("failed to list Databases: %w", err)
+	}
+	// The stored Database is what old describes; its usage is the baseline of the request
+	var others []databasev1.Database
+	for _, other := range databases.Items {
+		if other.Name != database.Name {
+			others = append(others, other)
+		}
+	}
+	if old != nil {
+		others = append(others, *old)
+	}
+	used := usageAmounts(namespaceUsage(database.Namespace, others))
+
+	for i := range quotas.Items {
+		quota := &quotas.Items[i]
+		selector, err := quotaSelector(quota)
+		if err != nil || !selector.Matches(labels.Set(namespace.Labels)) {
+			continue
+		}
+		limits := quotaLimits(quota)
+		var requestedParts, usedParts, limitedParts []string
+		for _, resource := range quotaResources {
+			limit, limited := limits[resource]
+			if !limited || requested[resource] <= 0 || used[resource]+requested[resource] <= limit {
+				continue
+			}
+			requestedParts = append(requestedParts, formatQuotaAmount(resource, requested[resource]))
+			usedParts = append(usedParts, formatQuotaAmount(resource, used[resource]))
+			limitedParts = append(limitedParts, formatQuotaAmount(resource, limit))
+		}
+		if len(limitedParts) > 0 {
+			return apierrors.NewForbidden(databasev1.GroupVersion.WithResource("databases").GroupResource(), database.Name,
+				fmt.Errorf("exceeded SkillsQuota %s, requested: %s, used: %s, limited: %s", quota.Name,
+					strings.Join(requestedParts, ","), strings.Join(usedParts, ","), strings.Join(limitedParts, ",")))
+		}
+	}
+	return nil
+}
+
+// quotaSelector parses the namespace selector of a quota
+func quotaSelector(quota *databasev1.SkillsQuota) (labels.Selector, error) {
+	if quota.Spec.NamespaceSelector == nil {
+		return labels.Everything(), nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(quota.Spec.NamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespaceSelector: %w", err)
+	}
+	return selector, nil
+}
+
+// quotaReports reports whether the status of a quota holds the usage of a namespace
+func quotaReports(quota *databasev1.SkillsQuota, namespace string) bool {
+	for _, usage := range quota.Status.Usage {
+		if usage.Namespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceUsage sums the usage of the Databases of a namespace. Databases being deleted
+// count until they are gone, as they still hold their storage.
+func namespaceUsage(namespace string, databases []databasev1.Database) databasev1.NamespaceUsage {
+	usage := databasev1.NamespaceUsage{Namespace: namespace}
+	for i := range databases {
+		usage.Databases++
+		usage.Storage += int64(databases[i].Spec.Storage)
+		usage.Replicas += databases[i].Spec.Replicas
+	}
+	return usage
+}
+
+// usageAmounts returns usage by resource
+func usageAmounts(usage databasev1.NamespaceUsage) map[string]int64 {
+	return map[string]int64{
+		quotaDatabases: int64(usage.Databases),
+		quotaStorage:   usage.Storage,
+		quotaReplicas:  int64(usage.Replicas),
+	}
+}
+
+// databaseAmounts returns what one Database uses by resource
+func databaseAmounts(database *databasev1.Database) map[string]int64 {
+	return usageAmounts(namespaceUsage(database.Namespace, []databasev1.Database{*database}))
+}
+
+// quotaLimits returns the limits a quota sets by resource; unlimited resources are absent
+func quotaLimits(quota *databasev1.SkillsQuota) map[string]int64 {
+	limits := map[string]int64{}
+	if quota.Spec.MaxDatabases != nil {
+		limits[quotaDatabases] = int64(*quota.Spec.MaxDatabases)
+	}
+	if quota.Spec.MaxStorage != nil {
+		limits[quotaStorage] = *quota.Spec.MaxStorage
+	}
+	if quota.Spec.MaxReplicas != nil {
+		limits[quotaReplicas] = int64(*quota.Spec.MaxReplicas)
+	}
+	return limits
+}
+
+// exceededLimits describes the limits of a quota that used exceeds, or is empty
+func exceededLimits(quota *databasev1.SkillsQuota, used map[string]int64) string {
+	limits := quotaLimits(quota)
+	var exceeded []string
+	for _, resource := range quotaResources {
+		if limit, limited := limits[resource]; limited && used[resource] > limit {
+			exceeded = append(exceeded, fmt.Sprintf("%s, limited: %s",
+				formatQuotaAmount(resource, used[resource]), formatQuotaAmount(resource, limit)))
+		}
+	}
+	return strings.Join(exceeded, ", ")
+}
+
+// formatQuotaAmount formats an amount of a resource as resource=amount, with storage in Mi
+func formatQuotaAmount(resource string, amount int64) string {
+	if resource == quotaStorage {
+		return fmt.Sprintf("%s=%dMi", resource, amount)
+	}
+	return fmt.Sprintf("%s=%d", resource, amount)
+}
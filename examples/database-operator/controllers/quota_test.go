@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+var teamTier = map[string]string{"databases.my.domain/tier": "team"}
+
+func newQuotaFixture(t *testing.T, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(&databasev1.SkillsQuota{}).
+		Build()
+}
+
+func newTeamQuota() *databasev1.SkillsQuota {
+	return &databasev1.SkillsQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-limits", Generation: 1},
+		Spec: databasev1.SkillsQuotaSpec{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: teamTier},
+			MaxDatabases:      ptr.To[int32](2),
+			MaxStorage:        ptr.To[int64](4096),
+		},
+	}
+}
+
+func newQuotaDatabase(namespace, name string, replicas, storage int32) *databasev1.Database {
+	return &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       databasev1.DatabaseSpec{Replicas: replicas, Image: "postgres:15", Storage: storage},
+	}
+}
+
+func TestSkillsQuotaReconciler_RecordsUsage(t *testing.T) {
+	fakeClient := newQuotaFixture(t, newTeamQuota(),
+		newNamespace("team-a", teamTier), newNamespace("team-b", teamTier), newNamespace("sandbox", nil),
+		// team-a was filled before the quota was lowered
+		newQuotaDatabase("team-a", "orders", 3, 2048),
+		newQuotaDatabase("team-a", "payments", 1, 2048),
+		newQuotaDatabase("team-a", "reports", 1, 1024),
+		newQuotaDatabase("team-b", "catalog", 2, 1024),
+		newQuotaDatabase("sandbox", "scratch", 1, 8192),
+	)
+	reconciler := &SkillsQuotaReconciler{Client: fakeClient}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "team-limits"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	quota := &databasev1.SkillsQuota{}
+	require.NoError(t, fakeClient.Get(ctx, key, quota))
+	assert.Equal(t, []databasev1.NamespaceUsage{
+		{Namespace: "team-a", Databases: 3, Storage: 5120, Replicas: 5},
+		{Namespace: "team-b", Databases: 1, Storage: 1024, Replicas: 2},
+	}, quota.Status.Usage, "Unselected namespaces are not reported")
+	assert.Equal(t, int64(1), quota.Status.ObservedGeneration)
+	assert.True(t, meta.IsStatusConditionTrue(quota.Status.Conditions, "Ready"))
+
+	exceeded := meta.FindStatusCondition(quota.Status.Conditions, conditionQuotaExceeded)
+	require.NotNil(t, exceeded)
+	assert.Equal(t, metav1.ConditionTrue, exceeded.Status)
+	assert.Equal(t, "team-a: databases=3, limited: databases=2, storage=5120Mi, limited: storage=4096Mi", exceeded.Message)
+
+	// Raising the limits clears the condition
+	quota.Spec.MaxDatabases = nil
+	quota.Spec.MaxStorage = ptr.To[int64](8192)
+	require.NoError(t, fakeClient.Update(ctx, quota))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, quota))
+	assert.True(t, meta.IsStatusConditionFalse(quota.Status.Conditions, conditionQuotaExceeded))
+}
+
+func TestSkillsQuotaReconciler_InvalidSelector(t *testing.T) {
+	quota := newTeamQuota()
+	quota.Spec.NamespaceSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+		Key: "databases.my.domain/tier", Operator: "Within",
+	}}}
+	fakeClient := newQuotaFixture(t, quota)
+	reconciler := &SkillsQuotaReconciler{Client: fakeClient}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "team-limits"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err, "An invalid selector is terminal")
+
+	require.NoError(t, fakeClient.Get(ctx, key, quota))
+	ready := meta.FindStatusCondition(quota.Status.Conditions, "Ready")
+	require.NotNil(t, ready)
+	assert.Equal(t, "InvalidSelector", ready.Reason)
+}
+
+func TestSkillsQuotaReconciler_MapsDatabasesAndNamespaces(t *testing.T) {
+	quota := newTeamQuota()
+	quota.Status.Usage = []databasev1.NamespaceUsage{{Namespace: "team-c"}}
+	fakeClient := newQuotaFixture(t, quota, newNamespace("team-a", teamTier), newNamespace("sandbox", nil))
+	reconciler := &SkillsQuotaReconciler{Client: fakeClient}
+
+	ctx := context.Background()
+	want := []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "team-limits"}}}
+	assert.Equal(t, want, reconciler.findQuotasForDatabase(ctx, newQuotaDatabase("team-a", "orders", 1, 1024)))
+	assert.Empty(t, reconciler.findQuotasForDatabase(ctx, newQuotaDatabase("sandbox", "scratch", 1, 1024)))
+
+	// A namespace that lost its label is still reported, so its usage must be removed
+	assert.Equal(t, want, reconciler.findQuotasForNamespace(ctx, newNamespace("team-c", nil)))
+}
+
+func TestDatabaseValidator_Quota(t *testing.T) {
+	orders := newQuotaDatabase("team-a", "orders", 1, 2048)
+	fakeClient := newQuotaFixture(t, newTeamQuota(),
+		newNamespace("team-a", teamTier), newNamespace("sandbox", nil),
+		orders, newQuotaDatabase("team-a", "payments", 1, 1024))
+	validator := &DatabaseValidator{Reader: fakeClient}
+	ctx := context.Background()
+
+	_, err := validator.ValidateCreate(ctx, newQuotaDatabase("team-a", "reports", 1, 512))
+	require.Error(t, err)
+	assert.True(t, apierrors.IsForbidden(err))
+	assert.Contains(t, err.Error(), "exceeded SkillsQuota team-limits, requested: databases=1, used: databases=2, limited: databases=2")
+
+	_, err = validator.ValidateCreate(ctx, newQuotaDatabase("sandbox", "reports", 1, 8192))
+	assert.NoError(t, err, "Namespaces the quota does not select are not limited")
+
+	grown := orders.DeepCopy()
+	grown.Spec.Storage = 4096
+	_, err = validator.ValidateUpdate(ctx, orders, grown)
+	require.Error(t, err)
+	assert.True(t, apierrors.IsForbidden(err))
+	assert.Contains(t, err.Error(), "requested: storage=2048Mi, used: storage=3072Mi, limited: storage=4096Mi")
+
+	grown.Spec.Storage = 3072
+	_, err = validator.ValidateUpdate(ctx, orders, grown)
+	assert.NoError(t, err, "Growing up to the limit is allowed")
+
+	// Invalid Databases are rejected as invalid before the quota is looked at
+	invalid := newQuotaDatabase("team-a", "reports", 1, 512)
+	invalid.Spec.Image = "postgres:15 "
+	_, err = validator.ValidateCreate(ctx, invalid)
+	assert.True(t, apierrors.IsInvalid(err))
+}
+
+func TestDatabaseValidator_QuotaLoweredAfterTheFact(t *testing.T) {
+	quota := newTeamQuota()
+	quota.Spec.MaxDatabases = ptr.To[int32](1)
+	quota.Spec.MaxReplicas = ptr.To[int32](2)
+	orders := newQuotaDatabase("team-a", "orders", 2, 2048)
+	fakeClient := newQuotaFixture(t, quota, newNamespace("team-a", teamTier),
+		orders, newQuotaDatabase("team-a", "payments", 1, 1024))
+	validator := &DatabaseValidator{Reader: fakeClient}
+	ctx := context.Background()
+
+	// The namespace holds one Database too many, but changes that do not grow are allowed
+	shrunk := orders.DeepCopy()
+	shrunk.Spec.Replicas = 1
+	_, err := validator.ValidateUpdate(ctx, orders, shrunk)
+	assert.NoError(t, err)
+
+	relabeled := orders.DeepCopy()
+	relabeled.Labels = map[string]string{"team": "checkout"}
+	_, err = validator.ValidateUpdate(ctx, orders, relabeled)
+	assert.NoError(t, err)
+
+	grown := orders.DeepCopy()
+	grown.Spec.Replicas = 3
+	_, err = validator.ValidateUpdate(ctx, orders, grown)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requested: replicas=1, used: replicas=3, limited: replicas=2")
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/readonly"
+)
+
+// TestDatabaseReconciler_ReadOnlyCache reconciles and maps events through every read that
+// skips the cache's copy, and fails if any of them changed an object it was handed
+func TestDatabaseReconciler_ReadOnlyCache(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	database := newHotPathDatabase()
+	database.Spec.ClassName = "standard"
+	database.Spec.Image = "registry.local/${CLUSTER_ENV}/postgres:15"
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders-7d9f-x2x4z", Namespace: "shop", Labels: selectorLabels(database)},
+		Spec: corev1.PodSpec{NodeName: "node-1", Containers: []corev1.Container{{
+			Name:      "postgres",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}},
+		}}},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			Conditions:        []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "postgres", RestartCount: 2}},
+		},
+	}
+	// A child that is no longer desired, pruned from the uncopied list of Services
+	stale := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+		Name: "orders-stale", Namespace: "shop",
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: databasev1.GroupVersion.String(), Kind: "Database", Name: "orders", UID: database.UID,
+			Controller: ptr.To(true),
+		}},
+	}}
+	variables := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name: "shop-vars", Namespace: "shop", Labels: map[string]string{variablesLabel: "true"},
+	}, Data: map[string]string{"CLUSTER_ENV": "prod"}}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database, newStandardClass(), pod, stale, variables).
+		WithStatusSubresource(database).
+		WithIndex(&databasev1.Database{}, classNameIndex, indexClassName).
+		WithIndex(&databasev1.Database{}, configMapIndex, indexConfigMaps).
+		WithIndex(&databasev1.Database{}, substitutionIndex, indexSubstitution).
+		Build()
+	guard := readonly.NewGuard(fakeClient)
+	reconciler := &DatabaseReconciler{Client: guard, Scheme: scheme, Defaults: newDefaultsSource(guard)}
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "orders", Namespace: "shop"}})
+		require.NoError(t, err)
+	}
+	assert.True(t, apierrors.IsNotFound(fakeClient.Get(ctx, client.ObjectKeyFromObject(stale), &corev1.Service{})),
+		"The stale child was pruned from the uncopied list")
+
+	// The objects of watch events are the cache's
+	guard.Track(variables)
+	assert.NotEmpty(t, reconciler.findDatabasesForConfigMap(ctx, variables))
+	class := newStandardClass()
+	guard.Track(class)
+	assert.NotEmpty(t, reconciler.findDatabasesForClass(ctx, class))
+
+	guard.Check(t)
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// createdCacheTimeout bounds how long a reconcile waits for a child it created to show up in
+// the cache, and createdCachePoll is how often it looks
+const (
+	createdCacheTimeout = 2 * time.Second
+	createdCachePoll    = 20 * time.Millisecond
+)
+
+// awaitCreated waits until obj, just created, can be read from cache. The cache lags the API
+// server by the latency of the watch, so a read right after a create, by the same reconcile or
+// by the next one the status update queues, can miss the object: the reconciler then creates
+// it again and fails with AlreadyExists, or acts on a NotFound as if the child were deleted.
+//
+// If the cache has not caught up within timeout, the object is read from apiReader instead,
+// which confirms it exists without waiting any longer; nil skips the check.
+func awaitCreated(ctx context.Context, cache, apiReader client.Reader, obj client.Object, timeout time.Duration) error {
+	key := client.ObjectKeyFromObject(obj)
+	read := obj.DeepCopyObject().(client.Object)
+
+	err := wait.PollUntilContextTimeout(ctx, createdCachePoll, timeout, true, func(ctx context.Context) (bool, error) {
+		if err := cache.Get(ctx, key, read); err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	})
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil || !wait.Interrupted(err) {
+		return fmt.Errorf("failed to read created %s from cache: %w", key, err)
+	}
+
+	logger := log.FromContext(ctx).WithValues("object", key, "timeout", timeout)
+	if apiReader == nil {
+		logger.Info("Created object has not shown up in the cache")
+		return nil
+	}
+	if err := apiReader.Get(ctx, key, read); err != nil {
+		return fmt.Errorf("failed to read created %s: %w", key, err)
+	}
+	logger.V(1).Info("Created object has not shown up in the cache; read it from the API server")
+	return nil
+}
+
+// createOrPatch is controllerutil.CreateOrPatch, waiting for the cache when it created obj.
+// The mutated object is checked against the apply policies; nothing is written if it breaks one.
+func (r *DatabaseReconciler) createOrPatch(ctx context.Context, obj client.Object, f controllerutil.MutateFn) (controllerutil.OperationResult, error) {
+	result, err := controllerutil.CreateOrPatch(ctx, r.Client, obj, func() error {
+		if err := f(); err != nil {
+			return err
+		}
+		return r.checkApplyPolicies(ctx, obj)
+	})
+	if err != nil || result != controllerutil.OperationResultCreated {
+		return result, err
+	}
+	return result, awaitCreated(ctx, r.Client, r.APIReader, obj, createdCacheTimeout)
+}
+
+// create creates obj, unless it breaks an apply policy, and waits for the cache
+func (r *DatabaseReconciler) create(ctx context.Context, obj client.Object) error {
+	if err := r.checkApplyPolicies(ctx, obj); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, obj); err != nil {
+		return err
+	}
+	return awaitCreated(ctx, r.Client, r.APIReader, obj, createdCacheTimeout)
+}
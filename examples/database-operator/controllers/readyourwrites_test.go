@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestAwaitCreated(t *testing.T) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "orders-credentials", Namespace: "shop"}}
+	empty := func() client.Client { return fake.NewClientBuilder().Build() }
+	stored := func() client.Client { return fake.NewClientBuilder().WithObjects(secret.DeepCopy()).Build() }
+	unused := fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(context.Context, client.WithWatch, client.ObjectKey, client.Object, ...client.GetOption) error {
+			t.Error("The API server is only read when the cache has not caught up")
+			return nil
+		},
+	}).Build()
+
+	// The cache shows the object after a few reads
+	reads := 0
+	lagging := fake.NewClientBuilder().WithObjects(secret.DeepCopy()).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if reads++; reads < 3 {
+				return apierrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}).Build()
+
+	tests := []struct {
+		name      string
+		cache     client.Reader
+		apiReader client.Reader
+		wantErr   bool
+	}{
+		{name: "cached", cache: stored(), apiReader: unused},
+		{name: "cache catches up", cache: lagging, apiReader: unused},
+		{name: "read from the API server", cache: empty(), apiReader: stored()},
+		{name: "no API reader", cache: empty()},
+		{name: "deleted", cache: empty(), apiReader: empty(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := awaitCreated(context.Background(), tt.cache, tt.apiReader, secret, 200*time.Millisecond)
+			if tt.wantErr {
+				assert.True(t, apierrors.IsNotFound(err), "got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAwaitCreated_StopsWithContext(t *testing.T) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "orders-credentials", Namespace: "shop"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := awaitCreated(ctx, fake.NewClientBuilder().Build(), fake.NewClientBuilder().Build(), secret, time.Minute)
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
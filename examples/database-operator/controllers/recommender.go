@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// databaseContainer is the name of the container running PostgreSQL
+const databaseContainer = "database"
+
+const (
+	// recommendationMargin is added to the observed usage, so a recommendation is not
+	// saturated by the load it was derived from
+	recommendationMargin = 0.15
+
+	// recommendationTolerance is how far the requests may be from the recommendation before
+	// it is applied, so the pods are not restarted for small changes
+	recommendationTolerance = 0.1
+
+	// minRecommendedCPU and minRecommendedMemory keep idle Databases schedulable and startable
+	minRecommendedCPU    = 10
+	minRecommendedMemory = 64 << 20
+)
+
+// usageSample is the usage of the database container of one pod at one time
+type usageSample struct {
+	at time.Time
+
+	// cpu in millicores and memory in bytes
+	cpu    int64
+	memory int64
+}
+
+// Recommender periodically samples the usage of the database container of every Database
+// with a verticalScaling policy from metrics-server, and recommends requests from the usage
+// history in status.recommendation: CPU covers the 90th percentile of the samples and memory
+// the peak, since running out of memory restarts the server. Limits keep the ratio of limits
+// to requests of spec.resources.
+//
+// In Apply mode the recommendation is written to spec.resources while the maintenance window
+// is open, so the rollout restarts the pods when disruption is expected anyway.
+//
+// The history is kept in memory. After a restart or a change of leader it starts over, and
+// nothing is recommended until it spans MinHistory again.
+type Recommender struct {
+	client.Client
+	Recorder events.EventRecorder
+
+	// Interval between samples; zero disables the recommender
+	Interval time.Duration
+
+	// History is how long samples are kept
+	History time.Duration
+
+	// MinHistory is how long a Database must have been sampled before it gets a recommendation
+	MinHistory time.Duration
+
+	// Switches keep recommendations from being applied in break-glass mode; nil only honours
+	// the break-glass annotation
+	Switches *Switches
+
+	// samples are the usage history of each Database, oldest first
+	samples map[types.NamespacedName][]usageSample
+}
+
+var _ manager.LeaderElectionRunnable = &Recommender{}
+
+// NewRecommender returns a recommender keeping a week of history
+func NewRecommender() *Recommender {
+	return &Recommender{History: 7 * 24 * time.Hour, MinHistory: 24 * time.Hour}
+}
+
+// BindFlags registers flags for the recommender, using the current values as defaults
+func (r *Recommender) BindFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&r.Interval, "recommender-interval", r.Interval,
+		"How often the usage of Databases with a verticalScaling policy is sampled. Zero disables resource recommendations.")
+	fs.DurationVar(&r.History, "recommender-history", r.History,
+		"How long usage samples are kept for resource recommendations.")
+	fs.DurationVar(&r.MinHistory, "recommender-min-history", r.MinHistory,
+		"How long a Database must have been sampled before resources are recommended for it.")
+}
+
+// Start samples usage until the context is cancelled
+func (r *Recommender) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("recommender")
+	ctx = log.IntoContext(ctx, logger)
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := r.recommendAll(ctx, time.Now()); err != nil {
+			logger.Error(err, "failed to recommend database resources")
+		}
+	}, r.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes only the leader keep a history and update Databases
+func (r *Recommender) NeedLeaderElection() bool {
+	return true
+}
+
+// recommendAll samples every Database with a policy once and forgets the history of
+// Databases that are gone or no longer have one
+func (r *Recommender) recommendAll(ctx context.Context, now time.Time) error {
+	var databases databasev1.DatabaseList
+	if err := r.List(ctx, &databases); err != nil {
+		return err
+	}
+	if r.samples == nil {
+		r.samples = map[types.NamespacedName][]usageSample{}
+	}
+
+	sampled := map[types.NamespacedName]bool{}
+	var errs []error
+	for i := range databases.Items {
+		database := &databases.Items[i]
+		if database.Spec.VerticalScaling == nil || !database.DeletionTimestamp.IsZero() {
+			continue
+		}
+		key := client.ObjectKeyFromObject(database)
+		sampled[key] = true
+		if err := r.recommend(ctx, database, now); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+
+	for key := range r.samples {
+		if !sampled[key] {
+			delete(r.samples, key)
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// recommend adds the current usage of a Database to its history, records the recommendation
+// and applies it if the policy allows
+func (r *Recommender) recommend(ctx context.Context, database *databasev1.Database, now time.Time) error {
+	// metrics-server copies the pod labels to the PodMetrics
+	items, err := listPodMetrics(ctx, r.Client, database.Namespace, client.MatchingLabels(selectorLabels(database)))
+	if err != nil {
+		return err
+	}
+
+	key := client.ObjectKeyFromObject(database)
+	samples := r.samples[key]
+	for i := range items {
+		containers, err := containerUsage(&items[i])
+		if err != nil {
+			return err
+		}
+		if usage, ok := containers[databaseContainer]; ok {
+			samples = append(samples, usageSample{at: now, cpu: usage.Cpu().MilliValue(), memory: usage.Memory().Value()})
+		}
+	}
+	cutoff := now.Add(-r.History)
+	samples = samples[sort.Search(len(samples), func(i int) bool { return !samples[i].at.Before(cutoff) }):]
+	r.samples[key] = samples
+	if len(samples) == 0 || now.Sub(samples[0].at) < r.MinHistory {
+		return nil
+	}
+
+	recommendation := recommendResources(database, samples)
+	if previous := database.Status.Recommendation; previous != nil {
+		recommendation.AppliedAt = previous.AppliedAt
+	}
+	patch := client.MergeFrom(database.DeepCopy())
+	database.Status.Recommendation = recommendation
+	if err := r.Status().Patch(ctx, database, patch); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if database.Spec.VerticalScaling.Mode != databasev1.VerticalScalingApply || database.Spec.Maintenance == nil ||
+		r.Switches.InBreakGlass(database) {
+		return nil
+	}
+	if _, open := openWindow(database.Spec.Maintenance.Window, now); !open {
+		return nil
+	}
+	return r.apply(ctx, database, recommendation, now)
+}
+
+// apply writes the recommendation to spec.resources when the requests are outside the
+// tolerance. Other resources in spec.resources are kept.
+func (r *Recommender) apply(ctx context.Context, database *databasev1.Database,
+	recommendation *databasev1.ResourceRecommendation, now time.Time) error {
+	current := corev1.ResourceRequirements{}
+	if database.Spec.Resources != nil {
+		current = *database.Spec.Resources.DeepCopy()
+	}
+	if !outsideTolerance(current.Requests, recommendation.Requests) {
+		return nil
+	}
+
+	patch := client.MergeFrom(database.DeepCopy())
+	resources := current.DeepCopy()
+	if resources.Requests == nil {
+		resources.Requests = corev1.ResourceList{}
+	}
+	for name, quantity := range recommendation.Requests {
+		resources.Requests[name] = quantity
+	}
+	if len(recommendation.Limits) > 0 && resources.Limits == nil {
+		resources.Limits = corev1.ResourceList{}
+	}
+	for name, quantity := range recommendation.Limits {
+		resources.Limits[name] = quantity
+	}
+	database.Spec.Resources = resources
+	if err := r.Patch(ctx, database, patch); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if r.Recorder != nil {
+		r.Recorder.Eventf(database, nil, corev1.EventTypeNormal, "ResourcesUpdated", "VerticalScaling",
+			"Updated requests from %s to %s", formatResources(current.Requests), formatResources(resources.Requests))
+	}
+
+	statusPatch := client.MergeFrom(database.DeepCopy())
+	database.Status.Recommendation.AppliedAt = &metav1.Time{Time: now}
+	return client.IgnoreNotFound(r.Status().Patch(ctx, database, statusPatch))
+}
+
+// recommendResources derives requests from the usage history, bounded by the policy, and
+// scales the limits of spec.resources with them
+func recommendResources(database *databasev1.Database, samples []usageSample) *databasev1.ResourceRecommendation {
+	cpus := make([]int64, 0, len(samples))
+	var memory int64
+	for _, sample := range samples {
+		cpus = append(cpus, sample.cpu)
+		memory = max(memory, sample.memory)
+	}
+	sort.Slice(cpus, func(i, j int) bool { return cpus[i] < cpus[j] })
+	cpu := cpus[int(math.Ceil(0.9*float64(len(cpus))))-1]
+
+	cpu = max(int64(math.Ceil(float64(cpu)*(1+recommendationMargin))), minRecommendedCPU)
+	memory = max(int64(math.Ceil(float64(memory)*(1+recommendationMargin))), minRecommendedMemory)
+	requests := corev1.ResourceList{
+		corev1.ResourceCPU:    newResourceQuantity(corev1.ResourceCPU, cpu),
+		corev1.ResourceMemory: newResourceQuantity(corev1.ResourceMemory, memory),
+	}
+
+	policy := database.Spec.VerticalScaling
+	for name, request := range requests {
+		if bound, ok := policy.MinAllowed[name]; ok && request.Cmp(bound) < 0 {
+			requests[name] = bound.DeepCopy()
+		}
+		if bound, ok := policy.MaxAllowed[name]; ok && request.Cmp(bound) > 0 {
+			requests[name] = bound.DeepCopy()
+		}
+	}
+
+	recommendation := &databasev1.ResourceRecommendation{
+		Requests: requests,
+		Since:    metav1.Time{Time: samples[0].at},
+		Samples:  int32(len(samples)),
+	}
+	if current := database.Spec.Resources; current != nil {
+		for name, request := range requests {
+			limit, hasLimit := current.Limits[name]
+			currentRequest, hasRequest := current.Requests[name]
+			if !hasLimit || !hasRequest || currentRequest.IsZero() {
+				continue
+			}
+			ratio := float64(resourceValue(name, limit)) / float64(resourceValue(name, currentRequest))
+			if recommendation.Limits == nil {
+				recommendation.Limits = corev1.ResourceList{}
+			}
+			recommendation.Limits[name] = newResourceQuantity(name, int64(math.Ceil(float64(resourceValue(name, request))*ratio)))
+		}
+	}
+	return recommendation
+}
+
+// outsideTolerance reports whether a recommended request differs from the current one by
+// more than the tolerance, or is not set at all
+func outsideTolerance(current, recommended corev1.ResourceList) bool {
+	for name, quantity := range recommended {
+		request, ok := current[name]
+		if !ok {
+			return true
+		}
+		diff := math.Abs(float64(resourceValue(name, quantity) - resourceValue(name, request)))
+		if diff > recommendationTolerance*float64(resourceValue(name, request)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceValue returns CPU in millicores and memory in bytes
+func resourceValue(name corev1.ResourceName, quantity resource.Quantity) int64 {
+	if name == corev1.ResourceCPU {
+		return quantity.MilliValue()
+	}
+	return quantity.Value()
+}
+
+// newResourceQuantity is the inverse of resourceValue. Memory is rounded up to whole MiB, so
+// recommendations read like the resources people write.
+func newResourceQuantity(name corev1.ResourceName, value int64) resource.Quantity {
+	if name == corev1.ResourceCPU {
+		return *resource.NewMilliQuantity(value, resource.DecimalSI)
+	}
+	const mebibyte = 1 << 20
+	return *resource.NewQuantity((value+mebibyte-1)/mebibyte*mebibyte, resource.BinarySI)
+}
+
+// formatResources renders the CPU and memory of a resource list for events
+func formatResources(resources corev1.ResourceList) string {
+	if len(resources) == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("cpu=%s memory=%s", resources.Cpu(), resources.Memory())
+}
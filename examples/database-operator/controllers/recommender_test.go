@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestRecommendResources(t *testing.T) {
+	database := &databasev1.Database{Spec: databasev1.DatabaseSpec{
+		Resources: &corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1"),
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			},
+			Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+		},
+		VerticalScaling: &databasev1.VerticalScalingPolicy{},
+	}}
+	start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	var samples []usageSample
+	for i := int64(1); i <= 10; i++ {
+		// A single CPU spike is above the 90th percentile
+		cpu := i * 100
+		if i == 10 {
+			cpu = 4000
+		}
+		samples = append(samples, usageSample{at: start.Add(time.Duration(i) * time.Hour), cpu: cpu, memory: 400 << 20})
+	}
+
+	recommendation := recommendResources(database, samples)
+	assert.Equal(t, "1035m", recommendation.Requests.Cpu().String(), "90th percentile plus the margin")
+	assert.Equal(t, "460Mi", recommendation.Requests.Memory().String(), "Peak plus the margin")
+	assert.Equal(t, "920Mi", recommendation.Limits.Memory().String(), "The limit keeps its ratio to the request")
+	assert.NotContains(t, recommendation.Limits, corev1.ResourceCPU)
+	assert.Equal(t, int32(10), recommendation.Samples)
+	assert.Equal(t, samples[0].at, recommendation.Since.Time)
+
+	database.Spec.VerticalScaling.MaxAllowed = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}
+	database.Spec.VerticalScaling.MinAllowed = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}
+	recommendation = recommendResources(database, samples)
+	assert.Equal(t, "500m", recommendation.Requests.Cpu().String())
+	assert.Equal(t, "1Gi", recommendation.Requests.Memory().String())
+}
+
+func TestOutsideTolerance(t *testing.T) {
+	requests := func(cpu, memory string) corev1.ResourceList {
+		return corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}
+	}
+	assert.False(t, outsideTolerance(requests("1", "1Gi"), requests("1050m", "1000Mi")))
+	assert.True(t, outsideTolerance(requests("1", "1Gi"), requests("1200m", "1Gi")))
+	assert.True(t, outsideTolerance(requests("1", "1Gi"), requests("1", "512Mi")))
+	assert.True(t, outsideTolerance(nil, requests("1", "1Gi")), "Unset requests are always updated")
+}
+
+func TestRecommender_Recommend(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(podMetricsListGVK, &unstructured.UnstructuredList{})
+	scheme.AddKnownTypeWithName(podMetricsListGVK.GroupVersion().WithKind("PodMetrics"), &unstructured.Unstructured{})
+
+	// The maintenance window opens at 02:00 for an hour
+	start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+			Resources: &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("2"),
+					corev1.ResourceMemory: resource.MustParse("4Gi"),
+				},
+			},
+			Maintenance: &databasev1.MaintenanceSpec{
+				Window: databasev1.MaintenanceWindow{Start: "02:00", Duration: metav1.Duration{Duration: time.Hour}},
+				Tasks:  []databasev1.MaintenanceTask{{Type: databasev1.MaintenanceVacuumAnalyze}},
+			},
+			VerticalScaling: &databasev1.VerticalScalingPolicy{Mode: databasev1.VerticalScalingApply},
+		},
+	}
+	metrics := &unstructured.Unstructured{Object: map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{"name": databaseContainer, "usage": map[string]interface{}{"cpu": "200m", "memory": "900Mi"}},
+			map[string]interface{}{"name": "exporter", "usage": map[string]interface{}{"cpu": "3", "memory": "8Gi"}},
+		},
+	}}
+	metrics.SetGroupVersionKind(podMetricsListGVK.GroupVersion().WithKind("PodMetrics"))
+	metrics.SetName("test-db-abc")
+	metrics.SetNamespace("default")
+	metrics.SetLabels(selectorLabels(database))
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database, metrics).
+		WithStatusSubresource(database).
+		Build()
+	recorder := events.NewFakeRecorder(10)
+	recommender := NewRecommender()
+	recommender.Client = fakeClient
+	recommender.Recorder = recorder
+	recommender.MinHistory = 2 * time.Hour
+	ctx := context.Background()
+	key := client.ObjectKeyFromObject(database)
+
+	// Not enough history yet
+	require.NoError(t, recommender.recommendAll(ctx, start))
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.Nil(t, database.Status.Recommendation)
+
+	// Recommended outside the window, applied once it opens
+	require.NoError(t, recommender.recommendAll(ctx, start.Add(90*time.Minute)))
+	require.NoError(t, recommender.recommendAll(ctx, start.Add(2*time.Hour)))
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	require.NotNil(t, database.Status.Recommendation)
+	assert.Equal(t, int32(3), database.Status.Recommendation.Samples, "Only the database container is sampled")
+	assert.Equal(t, "230m", database.Status.Recommendation.Requests.Cpu().String())
+	assert.Equal(t, "1035Mi", database.Status.Recommendation.Requests.Memory().String())
+	assert.Equal(t, "230m", database.Spec.Resources.Requests.Cpu().String())
+	require.NotNil(t, database.Status.Recommendation.AppliedAt)
+	assert.Equal(t, start.Add(2*time.Hour), database.Status.Recommendation.AppliedAt.Time.UTC())
+	assert.Len(t, recorder.Events, 1)
+
+	// Within the tolerance nothing changes
+	require.NoError(t, recommender.recommendAll(ctx, start.Add(150*time.Minute)))
+	assert.Len(t, recorder.Events, 1)
+
+	// Removing the policy forgets the history
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	database.Spec.VerticalScaling = nil
+	require.NoError(t, fakeClient.Update(ctx, database))
+	require.NoError(t, recommender.recommendAll(ctx, start.Add(3*time.Hour)))
+	assert.Empty(t, recommender.samples)
+}
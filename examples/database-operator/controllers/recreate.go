@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// conditionRecreating is True while a child is deleted to be created again with a field the
+// API server does not let the operator patch, and False with reasonRecreateBlocked when
+// recreating it would lose data
+const conditionRecreating = "Recreating"
+
+// Reasons of the Recreating condition
+const (
+	reasonRecreatingChild = "RecreatingChild"
+	reasonRecreated       = "Recreated"
+	reasonRecreateBlocked = "RecreateBlocked"
+)
+
+const (
+	// recreatePollInterval is how often a Database is reconciled while a deleted child is
+	// still terminating; PVCs are not watched, so their removal queues nothing
+	recreatePollInterval = 5 * time.Second
+
+	// recreateBlockedInterval is how often a blocked recreate is checked again: the user
+	// resolves it by deleting the child, which changes no spec
+	recreateBlockedInterval = time.Minute
+)
+
+// recreateError is returned by a child step whose child was deleted, or is still being
+// deleted, so it can be created again
+type recreateError struct {
+	kind  string
+	name  string
+	field string
+}
+
+func (e *recreateError) Error() string {
+	if e.field == "" {
+		return fmt.Sprintf("%s %s is being deleted; it is created again once it is gone", e.kind, e.name)
+	}
+	return fmt.Sprintf("recreating %s %s: %s cannot be changed in place", e.kind, e.name, e.field)
+}
+
+// recreateBlockedError is returned by a child step whose child needs to be recreated, but
+// may not be deleted by the operator
+type recreateBlockedError struct {
+	kind  string
+	name  string
+	field string
+	why   string
+}
+
+func (e *recreateBlockedError) Error() string {
+	return fmt.Sprintf("%s %s must be recreated to change %s, but %s", e.kind, e.name, e.field, e.why)
+}
+
+// asRecreateError returns the recreate in progress, if that is all that failed. A step
+// that really failed alongside it is reported as a failure.
+func asRecreateError(err error) (*recreateError, bool) {
+	if aggregate, ok := err.(kerrors.Aggregate); ok {
+		var first *recreateError
+		for _, e := range aggregate.Errors() {
+			recreating, ok := asRecreateError(e)
+			if !ok {
+				return nil, false
+			}
+			if first == nil {
+				first = recreating
+			}
+		}
+		return first, first != nil
+	}
+	recreating, ok := err.(*recreateError)
+	return recreating, ok
+}
+
+// asRecreateBlockedError returns a blocked recreate, also among the aggregated errors of
+// parallel steps
+func asRecreateBlockedError(err error) (*recreateBlockedError, bool) {
+	if aggregate, ok := err.(kerrors.Aggregate); ok {
+		for _, e := range aggregate.Errors() {
+			if blocked, ok := asRecreateBlockedError(e); ok {
+				return blocked, true
+			}
+		}
+		return nil, false
+	}
+	blocked, ok := err.(*recreateBlockedError)
+	return blocked, ok
+}
+
+// deploymentImmutableChange returns the field of an existing Deployment that differs from
+// the desired one and cannot be patched, empty if it can be patched
+func deploymentImmutableChange(existing *appsv1.Deployment, database *databasev1.Database) string {
+	desired := &metav1.LabelSelector{MatchLabels: selectorLabels(database)}
+	if existing.Spec.Selector != nil && !equality.Semantic.DeepEqual(existing.Spec.Selector, desired) {
+		return "spec.selector"
+	}
+	return ""
+}
+
+// pvcImmutableChange returns the field of an existing PVC that differs from the desired one
+// and cannot be patched, empty if it can be patched
+func pvcImmutableChange(existing *corev1.PersistentVolumeClaim, database *databasev1.Database) string {
+	if existing.Spec.StorageClassName != nil && *existing.Spec.StorageClassName != database.Spec.StorageClass {
+		return "spec.storageClassName"
+	}
+	return ""
+}
+
+// pvcHoldsData reports whether a PVC may hold data: once bound, deleting it deletes or
+// releases the volume with the database files on it
+func pvcHoldsData(pvc *corev1.PersistentVolumeClaim) bool {
+	return pvc.Spec.VolumeName != "" || pvc.Status.Phase != corev1.ClaimPending
+}
+
+// recreateDeployment deletes a Deployment whose selector changed. Deletion is foreground, so
+// the old pods are gone before the new Deployment starts pods on the same volume.
+func (r *DatabaseReconciler) recreateDeployment(ctx context.Context, database *databasev1.Database, existing *appsv1.Deployment, field string) error {
+	return r.recreateChild(ctx, database, existing, "Deployment", field, metav1.DeletePropagationForeground)
+}
+
+// recreatePVC deletes a PVC whose storage class changed, but only while it holds no data:
+// a PVC that never bound, e.g. because the storage class was misspelled, is safe to delete.
+// A bound PVC is left alone; the user copies the data and deletes it, or reverts the spec.
+func (r *DatabaseReconciler) recreatePVC(ctx context.Context, database *databasev1.Database, existing *corev1.PersistentVolumeClaim, field string) error {
+	if pvcHoldsData(existing) {
+		return &recreateBlockedError{kind: "PersistentVolumeClaim", name: existing.Name, field: field,
+			why: "it is bound and deleting it would lose the data; copy the data and delete the PVC, or revert the spec"}
+	}
+	return r.recreateChild(ctx, database, existing, "PersistentVolumeClaim", field, metav1.DeletePropagationBackground)
+}
+
+// recreateChild deletes a child so the next reconcile creates it again. Only children the
+// Database controls are deleted, and the UID precondition keeps a child created again in the
+// meantime from being deleted instead.
+func (r *DatabaseReconciler) recreateChild(ctx context.Context, database *databasev1.Database, child client.Object, kind, field string, propagation metav1.DeletionPropagation) error {
+	if !metav1.IsControlledBy(child, database) {
+		return &recreateBlockedError{kind: kind, name: child.GetName(), field: field,
+			why: "it is not controlled by the Database"}
+	}
+	err := r.Delete(ctx, child, client.Preconditions{UID: ptr.To(child.GetUID())}, client.PropagationPolicy(propagation))
+	if client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete %s %s to recreate it: %w", kind, child.GetName(), err)
+	}
+	if r.Recorder != nil {
+		r.Recorder.Eventf(database, child, corev1.EventTypeNormal, reasonRecreatingChild, "Recreate",
+			"Deleted %s %s to change %s, which cannot be changed in place", kind, child.GetName(), field)
+	}
+	return &recreateError{kind: kind, name: child.GetName(), field: field}
+}
+
+// waitForRecreate records a recreate in progress and checks again shortly. It is not a
+// failure: no Warning event, no error backoff.
+func (r *DatabaseReconciler) waitForRecreate(ctx context.Context, database *databasev1.Database, recreating *recreateError) (ctrl.Result, error) {
+	database.Status.Phase = "Reconciling"
+	database.SetCondition(conditionRecreating, metav1.ConditionTrue, reasonRecreatingChild, recreating.Error())
+	database.SetCondition("Ready", metav1.ConditionFalse, reasonRecreatingChild, recreating.Error())
+	r.recordHistory(ctx, database, nil)
+	if err := r.Status().Update(ctx, database); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: recreatePollInterval}, nil
+}
+
+// blockRecreate records a recreate the operator refused. Retrying on every error backoff
+// cannot help; the Database is checked again at recreateBlockedInterval.
+func (r *DatabaseReconciler) blockRecreate(ctx context.Context, database *databasev1.Database, blocked *recreateBlockedError) (ctrl.Result, error) {
+	if current := database.GetCondition(conditionRecreating); current == nil || current.Reason != reasonRecreateBlocked {
+		r.warn(database, nil, reasonRecreateBlocked, "Recreate", blocked.Error())
+	}
+	database.Status.Phase = "Failed"
+	database.SetCondition(conditionRecreating, metav1.ConditionFalse, reasonRecreateBlocked, blocked.Error())
+	database.SetCondition("Ready", metav1.ConditionFalse, reasonRecreateBlocked, blocked.Error())
+	database.SetCondition(conditionConverged, metav1.ConditionFalse, reasonRecreateBlocked, blocked.Error())
+	r.recordHistory(ctx, database, blocked)
+	if err := r.Status().Update(ctx, database); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: recreateBlockedInterval}, nil
+}
+
+// endRecreate records that the children were reconciled after a recreate was started or blocked
+func endRecreate(database *databasev1.Database) {
+	current := database.GetCondition(conditionRecreating)
+	if current == nil {
+		return
+	}
+	if current.Status == metav1.ConditionTrue {
+		database.SetCondition(conditionRecreating, metav1.ConditionFalse, reasonRecreated,
+			"The recreated children were created again")
+		return
+	}
+	if current.Reason == reasonRecreateBlocked {
+		database.SetCondition(conditionRecreating, metav1.ConditionFalse, reasonRecreated,
+			"No child needs to be recreated any more")
+	}
+}
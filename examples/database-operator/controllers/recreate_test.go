@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func recreateScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	return scheme
+}
+
+func TestDatabaseReconciler_RecreatesDeploymentOnSelectorChange(t *testing.T) {
+	scheme := recreateScheme(t)
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default", UID: "test-uid"},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db-password", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	}
+	// Created by an operator version that selected on other labels
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: deploymentName(database), Namespace: "default", UID: "old-uid"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app.kubernetes.io/instance": "test-db"}},
+		},
+	}
+	require.NoError(t, ctrl.SetControllerReference(database, deployment, scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(database, secret, deployment).Build()
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme, Recorder: recorder}
+	ctx := context.Background()
+	key := client.ObjectKeyFromObject(deployment)
+
+	assert.Equal(t, "spec.selector", deploymentImmutableChange(deployment, database))
+	err := reconciler.reconcileDeployment(ctx, database)
+	recreating, ok := asRecreateError(err)
+	require.True(t, ok, "unexpected error %v", err)
+	assert.Equal(t, "recreating Deployment test-db: spec.selector cannot be changed in place", recreating.Error())
+	assert.True(t, errors.IsNotFound(fakeClient.Get(ctx, key, &appsv1.Deployment{})))
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Normal "+reasonRecreatingChild)
+
+	// The next pass creates it with the new selector
+	require.NoError(t, reconciler.reconcileDeployment(ctx, database))
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	assert.Equal(t, selectorLabels(database), deployment.Spec.Selector.MatchLabels)
+	assert.Empty(t, deploymentImmutableChange(deployment, database))
+}
+
+func TestDatabaseReconciler_RecreatePVCProtectsData(t *testing.T) {
+	scheme := recreateScheme(t)
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default", UID: "test-uid"},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024, StorageClass: "fast-ssd"},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: pvcName(database), Namespace: "default"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: ptr.To("fast-sdd"),
+			VolumeName:       "pv-1",
+		},
+		Status: corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	require.NoError(t, ctrl.SetControllerReference(database, pvc, scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(database, pvc).Build()
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme}
+	ctx := context.Background()
+	key := client.ObjectKeyFromObject(pvc)
+
+	// A bound PVC is never deleted
+	err := reconciler.reconcilePVC(ctx, database)
+	blocked, ok := asRecreateBlockedError(err)
+	require.True(t, ok, "unexpected error %v", err)
+	assert.Contains(t, blocked.Error(), "would lose the data")
+	require.NoError(t, fakeClient.Get(ctx, key, pvc))
+	assert.Equal(t, "fast-sdd", *pvc.Spec.StorageClassName)
+
+	// Neither is one the Database does not control
+	pvc.OwnerReferences = nil
+	pvc.Spec.VolumeName = ""
+	require.NoError(t, fakeClient.Update(ctx, pvc))
+	pvc.Status.Phase = corev1.ClaimPending
+	require.NoError(t, fakeClient.Status().Update(ctx, pvc))
+	blocked, ok = asRecreateBlockedError(reconciler.reconcilePVC(ctx, database))
+	require.True(t, ok)
+	assert.Contains(t, blocked.Error(), "not controlled by the Database")
+
+	// One that never bound, e.g. with a misspelled storage class, is recreated
+	require.NoError(t, ctrl.SetControllerReference(database, pvc, scheme))
+	require.NoError(t, fakeClient.Update(ctx, pvc))
+	_, ok = asRecreateError(reconciler.reconcilePVC(ctx, database))
+	require.True(t, ok)
+	require.NoError(t, reconciler.reconcilePVC(ctx, database))
+	require.NoError(t, fakeClient.Get(ctx, key, pvc))
+	assert.Equal(t, "fast-ssd", *pvc.Spec.StorageClassName)
+}
+
+func TestDatabaseReconciler_RecreateStatus(t *testing.T) {
+	scheme := recreateScheme(t)
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(database).WithStatusSubresource(database).Build()
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme, Recorder: recorder}
+	ctx := context.Background()
+
+	// Recreating is not a failure, also when steps ran in parallel
+	recreating := kerrors.NewAggregate([]error{
+		&recreateError{kind: "Deployment", name: "test-db", field: "spec.selector"},
+		&recreateError{kind: "PersistentVolumeClaim", name: "test-db-data"},
+	})
+	result, err := reconciler.setErrorStatus(ctx, database, "DeploymentCreateFailed", recreating)
+	require.NoError(t, err)
+	assert.Equal(t, ctrl.Result{RequeueAfter: recreatePollInterval}, result)
+	assert.Equal(t, "Reconciling", database.Status.Phase)
+	assert.Equal(t, metav1.ConditionTrue, database.GetCondition(conditionRecreating).Status)
+	assert.Empty(t, recorder.Events)
+
+	// A real failure next to it is reported as one
+	_, ok := asRecreateError(kerrors.NewAggregate([]error{recreating, errors.NewServiceUnavailable("down")}))
+	assert.False(t, ok)
+
+	endRecreate(database)
+	assert.Equal(t, reasonRecreated, database.GetCondition(conditionRecreating).Reason)
+
+	// A blocked recreate warns once and is checked again without error backoff
+	blocked := &recreateBlockedError{kind: "PersistentVolumeClaim", name: "test-db-data", field: "spec.storageClassName", why: "it is bound"}
+	for i := 0; i < 2; i++ {
+		result, err = reconciler.setErrorStatus(ctx, database, "PVCCreateFailed", kerrors.NewAggregate([]error{blocked}))
+		require.NoError(t, err)
+		assert.Equal(t, ctrl.Result{RequeueAfter: recreateBlockedInterval}, result)
+	}
+	assert.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Failed", database.Status.Phase)
+	assert.Equal(t, reasonRecreateBlocked, database.GetCondition("Ready").Reason)
+	assert.Nil(t, database.GetCondition(conditionStalled), "Deleting the PVC resolves it without a spec change")
+}
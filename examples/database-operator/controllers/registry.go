@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// dockerHubRegistry serves the docker.io repositories
+const dockerHubRegistry = "registry-1.docker.io"
+
+// maxTagPages bounds the pages of a tag list, so a misbehaving registry cannot keep the
+// updater paging forever
+const maxTagPages = 100
+
+var (
+	// challengeParams extracts the key="value" pairs of a WWW-Authenticate challenge
+	challengeParams = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+	// nextLink extracts the URL of a Link header with rel="next"
+	nextLink = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="next"`)
+)
+
+// RegistryTags lists tags with the registry HTTP API (the distribution spec). Public
+// repositories need no configuration: the anonymous bearer token registries such as Docker
+// Hub require is fetched when the registry asks for it. Private repositories need a TagLister
+// that authenticates.
+type RegistryTags struct {
+	// Client sends the requests; http.DefaultClient when nil
+	Client *http.Client
+}
+
+// ListTags returns every tag of repository, following pagination
+func (r *RegistryTags) ListTags(ctx context.Context, repository string) ([]string, error) {
+	registry, path, _ := strings.Cut(repository, "/")
+	if registry == "docker.io" {
+		registry = dockerHubRegistry
+	}
+	next := (&url.URL{Scheme: "https", Host: registry, Path: "/v2/" + path + "/tags/list"}).String()
+
+	var tags []string
+	var token string
+	for page := 0; next != "" && page < maxTagPages; page++ {
+		resp, err := r.get(ctx, next, token)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && token == "" {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if token, err = r.token(ctx, challenge); err != nil {
+				return nil, err
+			}
+			page--
+			continue
+		}
+
+		var list struct {
+			Tags []string `json:"tags"`
+		}
+		err = decodeRegistryResponse(resp, &list)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, list.Tags...)
+
+		next = ""
+		if match := nextLink.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+			link, err := resp.Request.URL.Parse(match[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid next link %q: %w", match[1], err)
+			}
+			next = link.String()
+		}
+	}
+	return tags, nil
+}
+
+// token fetches an anonymous bearer token for the scope a Bearer challenge asks for
+func (r *RegistryTags) token(ctx context.Context, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("registry requires unsupported authentication %q", challenge)
+	}
+	params := map[string]string{}
+	for _, match := range challengeParams.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Scheme == "" {
+		return "", fmt.Errorf("registry challenge has an invalid realm %q", params["realm"])
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	resp, err := r.get(ctx, realm.String(), "")
+	if err != nil {
+		return "", err
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := decodeRegistryResponse(resp, &body); err != nil {
+		return "", fmt.Errorf("failed to get registry token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+func (r *RegistryTags) get(ctx context.Context, url, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// decodeRegistryResponse decodes a successful JSON response and closes its body
+func decodeRegistryResponse(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", resp.Request.URL.Redacted(), resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
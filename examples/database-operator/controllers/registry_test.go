@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryTags_ListTags(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/token":
+			assert.Equal(t, "repository:library/postgres:pull", req.URL.Query().Get("scope"))
+			fmt.Fprint(w, `{"token": "anonymous"}`)
+		case req.Header.Get("Authorization") != "Bearer anonymous":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm="%s/token",service="registry",scope="repository:library/postgres:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case req.URL.Query().Get("last") == "":
+			w.Header().Set("Link", `</v2/library/postgres/tags/list?last=15.2>; rel="next"`)
+			fmt.Fprint(w, `{"tags": ["15.1", "15.2"]}`)
+		default:
+			fmt.Fprint(w, `{"tags": ["15.4"]}`)
+		}
+	}))
+	defer server.Close()
+
+	registry := &RegistryTags{Client: server.Client()}
+	tags, err := registry.ListTags(context.Background(), strings.TrimPrefix(server.URL, "https://")+"/library/postgres")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"15.1", "15.2", "15.4"}, tags)
+}
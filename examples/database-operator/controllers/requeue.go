@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"flag"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/validation"
+)
+
+// RequeuePolicy holds the operator-wide resync intervals.
+// Individual Databases can override the intervals via spec.requeuePolicy.
+type RequeuePolicy struct {
+	// ReadyInterval is the resync interval for ready Databases
+	ReadyInterval time.Duration
+
+	// NotReadyInterval is the resync interval for Databases that are not ready yet
+	NotReadyInterval time.Duration
+
+	// ConvergedInterval is the resync interval for Databases that are ready and whose desired
+	// state did not change since the last reconcile. Watches still deliver every change, so a
+	// long interval cuts API load for large fleets; a negative value disables these resyncs.
+	ConvergedInterval time.Duration
+
+	// JitterFactor spreads resyncs over [interval, interval*(1+JitterFactor)]
+	// so that many Databases created together do not resync in lockstep
+	JitterFactor float64
+}
+
+// DefaultRequeuePolicy is used for any interval left unset.
+// Readiness changes are picked up from pod and EndpointSlice events,
+// so Databases that are not ready do not need aggressive polling.
+var DefaultRequeuePolicy = RequeuePolicy{
+	ReadyInterval:     5 * time.Minute,
+	NotReadyInterval:  time.Minute,
+	ConvergedInterval: 30 * time.Minute,
+	JitterFactor:      0.1,
+}
+
+// BindFlags registers flags for the requeue policy, using the current values as defaults
+func (p *RequeuePolicy) BindFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&p.ReadyInterval, "requeue-ready-interval", p.ReadyInterval,
+		"How often a ready Database is resynced when no events arrive.")
+	fs.DurationVar(&p.NotReadyInterval, "requeue-not-ready-interval", p.NotReadyInterval,
+		"How often a Database that is not ready is resynced when no events arrive.")
+	fs.DurationVar(&p.ConvergedInterval, "requeue-converged-interval", p.ConvergedInterval,
+		"How often a converged Database (ready, desired state unchanged) is resynced when no events arrive. Negative disables these resyncs.")
+	fs.Float64Var(&p.JitterFactor, "requeue-jitter", p.JitterFactor,
+		"Maximum fraction of the interval added as random jitter to each resync.")
+}
+
+// RequeueAfter returns the jittered resync interval for a Database.
+// The resource's spec.requeuePolicy takes precedence over the operator-wide policy.
+func (p RequeuePolicy) RequeueAfter(database *databasev1.Database) time.Duration {
+	interval := p.interval(database)
+	if p.JitterFactor <= 0 {
+		return interval
+	}
+	return wait.Jitter(interval, p.JitterFactor)
+}
+
+// interval picks the un-jittered interval for the Database's current readiness and convergence
+func (p RequeuePolicy) interval(database *databasev1.Database) time.Duration {
+	ready := database.IsReady()
+
+	// Overrides below the minimum only reach here when the webhook was bypassed
+	if override := database.Spec.RequeuePolicy; override != nil {
+		if ready && override.ReadyInterval != nil {
+			return max(override.ReadyInterval.Duration, validation.MinRequeueInterval)
+		}
+		if !ready && override.NotReadyInterval != nil {
+			return max(override.NotReadyInterval.Duration, validation.MinRequeueInterval)
+		}
+	}
+
+	if ready && isConverged(database) {
+		switch {
+		case p.ConvergedInterval > 0:
+			return p.ConvergedInterval
+		case p.ConvergedInterval < 0:
+			// Zero makes RequeueAfter skip the resync
+			return 0
+		}
+		return DefaultRequeuePolicy.ConvergedInterval
+	}
+	if ready {
+		if p.ReadyInterval > 0 {
+			return p.ReadyInterval
+		}
+		return DefaultRequeuePolicy.ReadyInterval
+	}
+	if p.NotReadyInterval > 0 {
+		return p.NotReadyInterval
+	}
+	return DefaultRequeuePolicy.NotReadyInterval
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/validation"
+)
+
+func TestRequeuePolicy_RequeueAfter(t *testing.T) {
+	ready := &databasev1.Database{}
+	ready.SetCondition("Ready", metav1.ConditionTrue, "Ready", "Database is ready")
+
+	notReady := &databasev1.Database{}
+
+	converged := ready.DeepCopy()
+	converged.SetCondition(conditionConverged, metav1.ConditionTrue, "SteadyState", "Database is ready and its desired state is unchanged")
+
+	convergedOverridden := converged.DeepCopy()
+	convergedOverridden.Spec.RequeuePolicy = &databasev1.RequeuePolicy{
+		ReadyInterval: &metav1.Duration{Duration: time.Minute},
+	}
+
+	overridden := &databasev1.Database{
+		Spec: databasev1.DatabaseSpec{
+			RequeuePolicy: &databasev1.RequeuePolicy{
+				NotReadyInterval: &metav1.Duration{Duration: 30 * time.Second},
+			},
+		},
+	}
+
+	tooShort := &databasev1.Database{
+		Spec: databasev1.DatabaseSpec{
+			RequeuePolicy: &databasev1.RequeuePolicy{
+				NotReadyInterval: &metav1.Duration{Duration: time.Nanosecond},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		policy   RequeuePolicy
+		database *databasev1.Database
+		expected time.Duration
+	}{
+		{
+			name:     "zero policy falls back to defaults when ready",
+			database: ready,
+			expected: DefaultRequeuePolicy.ReadyInterval,
+		},
+		{
+			name:     "zero policy falls back to defaults when not ready",
+			database: notReady,
+			expected: DefaultRequeuePolicy.NotReadyInterval,
+		},
+		{
+			name:     "operator-wide policy",
+			policy:   RequeuePolicy{ReadyInterval: 10 * time.Minute},
+			database: ready,
+			expected: 10 * time.Minute,
+		},
+		{
+			name:     "spec override wins over operator-wide policy",
+			policy:   RequeuePolicy{NotReadyInterval: 5 * time.Second},
+			database: overridden,
+			expected: 30 * time.Second,
+		},
+		{
+			name:     "zero policy falls back to defaults when converged",
+			database: converged,
+			expected: DefaultRequeuePolicy.ConvergedInterval,
+		},
+		{
+			name:     "converged interval stretches the ready interval",
+			policy:   RequeuePolicy{ReadyInterval: 10 * time.Minute, ConvergedInterval: 2 * time.Hour},
+			database: converged,
+			expected: 2 * time.Hour,
+		},
+		{
+			name:     "negative converged interval disables resyncs",
+			policy:   RequeuePolicy{ConvergedInterval: -1},
+			database: converged,
+			expected: 0,
+		},
+		{
+			name:     "spec ready interval wins over the converged interval",
+			policy:   RequeuePolicy{ConvergedInterval: 2 * time.Hour},
+			database: convergedOverridden,
+			expected: time.Minute,
+		},
+		{
+			name:     "spec override is clamped to the minimum",
+			database: tooShort,
+			expected: validation.MinRequeueInterval,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.policy.RequeueAfter(tt.database))
+		})
+	}
+}
+
+func TestRequeuePolicy_Jitter(t *testing.T) {
+	policy := RequeuePolicy{ReadyInterval: time.Minute, JitterFactor: 0.5}
+	database := &databasev1.Database{}
+	database.SetCondition("Ready", metav1.ConditionTrue, "Ready", "Database is ready")
+
+	for i := 0; i < 100; i++ {
+		interval := policy.RequeueAfter(database)
+		assert.GreaterOrEqual(t, interval, time.Minute)
+		assert.LessOrEqual(t, interval, 90*time.Second)
+	}
+}
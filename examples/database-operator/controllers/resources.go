@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+//+kubebuilder:rbac:groups=metrics.k8s.io,resources=pods,verbs=get;list
+
+// podMetricsListGVK is the metrics-server API. It is read as unstructured objects, so the
+// operator does not depend on the metrics client.
+var podMetricsListGVK = schema.GroupVersionKind{Group: "metrics.k8s.io", Version: "v1beta1", Kind: "PodMetricsList"}
+
+// jobNameLabel is set by the Job controller on the pods of a Job
+const jobNameLabel = "job-name"
+
+// reportResources records in status.resources what the Database costs: the requests of its
+// running pods and maintenance Jobs, the capacity of its volume and, with ResourceUsage, the
+// live usage from metrics-server. pods are the Database's pods, already listed for the status.
+func (r *DatabaseReconciler) reportResources(ctx context.Context, database *databasev1.Database, pods []corev1.Pod) error {
+	maintenancePods, err := r.listMaintenancePods(ctx, database)
+	if err != nil {
+		return err
+	}
+
+	summary := &databasev1.ResourceSummary{Requests: corev1.ResourceList{}}
+	counted := map[string]bool{}
+	// A new slice: appending to pods could write into the caller's backing array
+	all := make([]corev1.Pod, len(pods)+len(maintenancePods))
+	copy(all, pods)
+	copy(all[len(pods):], maintenancePods)
+	for _, pod := range all {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		summary.Pods++
+		counted[pod.Name] = true
+		addResources(summary.Requests, podRequests(&pod))
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	err = r.Get(ctx, client.ObjectKey{Namespace: database.Namespace, Name: pvcName(database)}, pvc)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err == nil {
+		// The provisioned capacity may exceed the request; before binding only the request is known
+		storage, ok := pvc.Status.Capacity[corev1.ResourceStorage]
+		if !ok {
+			storage, ok = pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		}
+		if ok {
+			summary.Storage = &storage
+		}
+	}
+
+	if r.ResourceUsage {
+		usage, err := r.podUsage(ctx, database.Namespace, counted)
+		if err != nil {
+			// Usage is informational; a missing or overloaded metrics-server must not fail the reconcile
+			log.FromContext(ctx).V(1).Info("Pod metrics unavailable", "error", err.Error())
+		} else {
+			summary.Usage = usage
+		}
+	}
+
+	database.Status.Resources = summary
+	return nil
+}
+
+// listMaintenancePods lists the pods of the Database's maintenance Jobs
+func (r *DatabaseReconciler) listMaintenancePods(ctx context.Context, database *databasev1.Database) ([]corev1.Pod, error) {
+	if database.Spec.Maintenance == nil || len(database.Spec.Maintenance.Tasks) == 0 {
+		return nil, nil
+	}
+	var jobs []string
+	for _, task := range database.Spec.Maintenance.Tasks {
+		jobs = append(jobs, maintenanceJobName(database, task.Type))
+	}
+	requirement, err := labels.NewRequirement(jobNameLabel, selection.In, jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods,
+		client.InNamespace(database.Namespace),
+		client.MatchingLabelsSelector{Selector: labels.NewSelector().Add(*requirement)},
+	); err != nil {
+		return nil, fmt.Errorf("failed to list maintenance pods: %w", err)
+	}
+	return pods.Items, nil
+}
+
+// podUsage sums the metrics-server usage of the named pods
+func (r *DatabaseReconciler) podUsage(ctx context.Context, namespace string, pods map[string]bool) (corev1.ResourceList, error) {
+	items, err := listPodMetrics(ctx, r.Client, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := corev1.ResourceList{}
+	for i := range items {
+		if !pods[items[i].GetName()] {
+			continue
+		}
+		containers, err := containerUsage(&items[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, container := range containers {
+			addResources(usage, container)
+		}
+	}
+	return usage, nil
+}
+
+// listPodMetrics lists the metrics-server usage of pods in a namespace
+func listPodMetrics(ctx context.Context, c client.Reader, namespace string, opts ...client.ListOption) ([]unstructured.Unstructured, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(podMetricsListGVK)
+	if err := c.List(ctx, list, append(opts, client.InNamespace(namespace))...); err != nil {
+		if meta.IsNoMatchError(err) || errors.IsNotFound(err) {
+			return nil, fmt.Errorf("metrics-server is not installed: %w", err)
+		}
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// containerUsage returns the usage of each container in a PodMetrics object, by name
+func containerUsage(metrics *unstructured.Unstructured) (map[string]corev1.ResourceList, error) {
+	containers, _, _ := unstructured.NestedSlice(metrics.Object, "containers")
+	usage := make(map[string]corev1.ResourceList, len(containers))
+	for _, container := range containers {
+		fields, ok := container.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(fields, "name")
+		values, _, _ := unstructured.NestedStringMap(fields, "usage")
+		resources := corev1.ResourceList{}
+		for resourceName, value := range values {
+			quantity, err := resource.ParseQuantity(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s usage %q of pod %s: %w", resourceName, value, metrics.GetName(), err)
+			}
+			resources[corev1.ResourceName(resourceName)] = quantity
+		}
+		usage[name] = resources
+	}
+	return usage, nil
+}
+
+// podRequests returns the CPU and memory a pod holds on its node: the larger of the sum of its
+// containers and its largest init container, as the scheduler computes it, plus the overhead
+func podRequests(pod *corev1.Pod) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		addResources(requests, container.Resources.Requests)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			if request, ok := container.Resources.Requests[name]; ok && request.Cmp(requests[name]) > 0 {
+				requests[name] = request.DeepCopy()
+			}
+		}
+	}
+	addResources(requests, pod.Spec.Overhead)
+
+	for name := range requests {
+		if name != corev1.ResourceCPU && name != corev1.ResourceMemory {
+			delete(requests, name)
+		}
+	}
+	return requests
+}
+
+// addResources adds the quantities of add to total
+func addResources(total, add corev1.ResourceList) {
+	for name, quantity := range add {
+		sum := total[name]
+		sum.Add(quantity)
+		total[name] = sum
+	}
+}
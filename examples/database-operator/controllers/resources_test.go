@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestPodRequests(t *testing.T) {
+	requests := func(cpu, memory string) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}}
+	}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		InitContainers: []corev1.Container{
+			{Name: "bootstrap", Resources: requests("2", "64Mi")},
+		},
+		Containers: []corev1.Container{
+			{Name: "postgres", Resources: requests("500m", "1Gi")},
+			{Name: "exporter", Resources: requests("100m", "64Mi")},
+		},
+		Overhead: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("16Mi")},
+	}}
+
+	got := podRequests(pod)
+	assert.Equal(t, "2", got.Cpu().String(), "The largest init container exceeds the containers")
+	assert.Equal(t, "1104Mi", got.Memory().String(), "Containers are summed, plus the overhead")
+}
+
+func TestDatabaseReconciler_ReportResources(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(podMetricsListGVK, &unstructured.UnstructuredList{})
+	scheme.AddKnownTypeWithName(podMetricsListGVK.GroupVersion().WithKind("PodMetrics"), &unstructured.Unstructured{})
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default", UID: "test-uid"},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+			Maintenance: &databasev1.MaintenanceSpec{
+				Tasks: []databasev1.MaintenanceTask{{Type: databasev1.MaintenanceVacuumAnalyze}},
+			},
+		},
+	}
+	pod := func(name string, phase corev1.PodPhase, labels map[string]string, cpu string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "main",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse(cpu),
+				}},
+			}}},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+	jobLabels := map[string]string{jobNameLabel: maintenanceJobName(database, databasev1.MaintenanceVacuumAnalyze)}
+	databasePods := []corev1.Pod{
+		*pod("test-db-0", corev1.PodRunning, nil, "500m"),
+		*pod("test-db-1", corev1.PodFailed, nil, "500m"),
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: pvcName(database), Namespace: "default"},
+		Spec: corev1.PersistentVolumeClaimSpec{Resources: corev1.VolumeResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+		}},
+	}
+	metrics := func(name, cpu string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "main", "usage": map[string]interface{}{"cpu": cpu, "memory": "100Mi"}},
+			},
+		}}
+		u.SetGroupVersionKind(podMetricsListGVK.GroupVersion().WithKind("PodMetrics"))
+		u.SetName(name)
+		u.SetNamespace("default")
+		return u
+	}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		pvc,
+		pod("test-db-vacuumanalyze-x", corev1.PodRunning, jobLabels, "10m"),
+		pod("other-vacuumanalyze-x", corev1.PodRunning, map[string]string{jobNameLabel: "other-vacuumanalyze"}, "10m"),
+		metrics("test-db-0", "250m"),
+		metrics("test-db-vacuumanalyze-x", "5m"),
+		metrics("other-vacuumanalyze-x", "1"),
+	).Build()
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme}
+	ctx := context.Background()
+
+	require.NoError(t, reconciler.reportResources(ctx, database, databasePods))
+	summary := database.Status.Resources
+	require.NotNil(t, summary)
+	assert.Equal(t, int32(2), summary.Pods, "Finished pods are not counted")
+	assert.Equal(t, "510m", summary.Requests.Cpu().String())
+	require.NotNil(t, summary.Storage)
+	assert.Equal(t, "1Gi", summary.Storage.String(), "The request stands in until the volume is bound")
+	assert.Nil(t, summary.Usage, "Usage is opt-in")
+
+	// The bound capacity wins over the request
+	pvc.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("2Gi")}
+	require.NoError(t, fakeClient.Status().Update(ctx, pvc))
+	reconciler.ResourceUsage = true
+	require.NoError(t, reconciler.reportResources(ctx, database, databasePods))
+	summary = database.Status.Resources
+	assert.Equal(t, "2Gi", summary.Storage.String())
+	assert.Equal(t, "255m", summary.Usage.Cpu().String())
+	assert.Equal(t, "200Mi", summary.Usage.Memory().String())
+
+	// The maintenance pods are not written into spare capacity of the caller's slice
+	spare := append(make([]corev1.Pod, 0, len(databasePods)+1), databasePods...)
+	require.NoError(t, reconciler.reportResources(ctx, database, spare))
+	assert.Empty(t, spare[:cap(spare)][len(databasePods)].Name)
+}
+
+func TestDatabaseReconciler_ReportResourcesWithoutMetricsServer(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+	reconciler := &DatabaseReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+			List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				if _, ok := list.(*unstructured.UnstructuredList); ok {
+					return &meta.NoKindMatchError{GroupKind: podMetricsListGVK.GroupKind()}
+				}
+				return c.List(ctx, list, opts...)
+			},
+		}).Build(),
+		Scheme:        scheme,
+		ResourceUsage: true,
+	}
+
+	require.NoError(t, reconciler.reportResources(context.Background(), database, nil), "Usage is best effort")
+	require.NotNil(t, database.Status.Resources)
+	assert.Nil(t, database.Status.Resources.Usage)
+	assert.Nil(t, database.Status.Resources.Storage)
+}
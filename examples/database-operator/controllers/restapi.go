@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/health"
+	"your.domain/project/sealing"
+)
+
+// restPrefix is the path all REST endpoints are served under
+const restPrefix = "/api/v1/"
+
+// Actions accepted by POST /api/v1/namespaces/<namespace>/databases/<name>/<action>
+const (
+	// restActionPause sets the break-glass annotation: the operator keeps reporting the state
+	// of the Database but changes nothing until it is resumed
+	restActionPause = "pause"
+
+	// restActionResume removes the break-glass annotation
+	restActionResume = "resume"
+)
+
+// DatabaseSummary is how the REST API presents a Database
+type DatabaseSummary struct {
+	Namespace     string             `json:"namespace"`
+	Name          string             `json:"name"`
+	Phase         string             `json:"phase,omitempty"`
+	Health        health.Result      `json:"health"`
+	Replicas      int32              `json:"replicas"`
+	ReadyReplicas int32              `json:"readyReplicas"`
+	Paused        bool               `json:"paused"`
+	Conditions    []metav1.Condition `json:"conditions,omitempty"`
+
+	// SlowQueries are opened when the server has the Sealer they were sealed with
+	SlowQueries []databasev1.SlowQuery `json:"slowQueries,omitempty"`
+}
+
+// RESTServer serves a small REST API over the Databases for dashboards and scripts that cannot
+// speak the Kubernetes API:
+//
+//	GET  /api/v1/databases[?namespace=<namespace>]
+//	GET  /api/v1/namespaces/<namespace>/databases/<name>
+//	POST /api/v1/namespaces/<namespace>/databases/<name>/pause
+//	POST /api/v1/namespaces/<namespace>/databases/<name>/resume
+//
+// Every request must carry the bearer token in TokenFile. The API is deliberately narrow: it
+// reads Databases and only translates the actions into annotations the controller already
+// honours, so it grants nothing the operator does not already do. Anyone holding the token can
+// pause any Database, so the token should be treated like the operator's own credentials.
+type RESTServer struct {
+	client.Client
+
+	// Recorder reports the actions taken through the API as events; nil disables them
+	Recorder events.EventRecorder
+
+	// Addr the API listens on; empty disables it
+	Addr string
+
+	// TokenFile holds the bearer token. It is read for every request, so a rotated Secret
+	// takes effect without a restart.
+	TokenFile string
+
+	// Sealer opens the sealed slow queries of a Database; without it they are left out
+	Sealer sealing.Sealer
+}
+
+var _ manager.LeaderElectionRunnable = &RESTServer{}
+
+// BindFlags registers flags for the API, using the current values as defaults
+func (s *RESTServer) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.Addr, "rest-bind-address", s.Addr,
+		"The address the REST API for non-Kubernetes clients binds to, e.g. :9445. Empty disables it.")
+	fs.StringVar(&s.TokenFile, "rest-token-file", s.TokenFile,
+		"File holding the bearer token of REST API requests. Required when --rest-bind-address is set.")
+}
+
+// Start serves the API until the context is cancelled
+func (s *RESTServer) Start(ctx context.Context) error {
+	if s.TokenFile == "" {
+		return errors.New("REST API requires a token file")
+	}
+
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	log.FromContext(ctx).Info("Serving REST API", "address", s.Addr)
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("REST API server failed: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// NeedLeaderElection is false: every replica can read the cache and write annotations
+func (s *RESTServer) NeedLeaderElection() bool {
+	return false
+}
+
+// ServeHTTP authenticates the request and routes it
+func (s *RESTServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if status := s.authenticate(req); status != http.StatusOK {
+		writeRESTError(w, status, http.StatusText(status))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, restPrefix), "/"), "/")
+	switch {
+	case !strings.HasPrefix(req.URL.Path, restPrefix):
+		writeRESTError(w, http.StatusNotFound, "not found")
+	case len(parts) == 1 && parts[0] == "databases":
+		s.list(w, req)
+	case len(parts) == 4 && parts[0] == "namespaces" && parts[2] == "databases":
+		s.get(w, req, types.NamespacedName{Namespace: parts[1], Name: parts[3]})
+	case len(parts) == 5 && parts[0] == "namespaces" && parts[2] == "databases":
+		s.act(w, req, types.NamespacedName{Namespace: parts[1], Name: parts[3]}, parts[4])
+	default:
+		writeRESTError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// authenticate checks the bearer token of the request
+func (s *RESTServer) authenticate(req *http.Request) int {
+	token, err := os.ReadFile(s.TokenFile)
+	if err != nil {
+		log.FromContext(req.Context()).Error(err, "failed to read REST API token")
+		return http.StatusInternalServerError
+	}
+	expected := strings.TrimSpace(string(token))
+	given, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if expected == "" || !ok || subtle.ConstantTimeCompare([]byte(given), []byte(expected)) != 1 {
+		return http.StatusUnauthorized
+	}
+	return http.StatusOK
+}
+
+// list returns the summaries of all Databases, or of those in one namespace
+func (s *RESTServer) list(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeRESTError(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+	var databases databasev1.DatabaseList
+	if err := s.List(req.Context(), &databases, client.InNamespace(req.URL.Query().Get("namespace"))); err != nil {
+		log.FromContext(req.Context()).Error(err, "failed to list Databases")
+		writeRESTError(w, http.StatusInternalServerError, "failed to list Databases")
+		return
+	}
+	items := make([]DatabaseSummary, 0, len(databases.Items))
+	for i := range databases.Items {
+		summary := summarizeDatabase(&databases.Items[i])
+		summary.Conditions = nil
+		items = append(items, summary)
+	}
+	writeREST(w, http.StatusOK, struct {
+		Items []DatabaseSummary `json:"items"`
+	}{items})
+}
+
+// get returns the summary of one Database with its conditions
+func (s *RESTServer) get(w http.ResponseWriter, req *http.Request, key types.NamespacedName) {
+	if req.Method != http.MethodGet {
+		writeRESTError(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+	database := &databasev1.Database{}
+	if !s.read(w, req, key, database) {
+		return
+	}
+	summary := summarizeDatabase(database)
+	queries, err := s.openSlowQueries(req.Context(), database)
+	if err != nil {
+		log.FromContext(req.Context()).Error(err, "failed to open slow queries", "database", key)
+		writeRESTError(w, http.StatusInternalServerError, "failed to open the slow queries")
+		return
+	}
+	summary.SlowQueries = queries
+	writeREST(w, http.StatusOK, summary)
+}
+
+// openSlowQueries returns the slow queries of a Database with their text opened. Queries
+// recorded before sealing was switched on are returned as they are; sealed ones are left out
+// when the server cannot open them.
+func (s *RESTServer) openSlowQueries(ctx context.Context, database *databasev1.Database) ([]databasev1.SlowQuery, error) {
+	if database.Status.Stats == nil {
+		return nil, nil
+	}
+	binding := client.ObjectKeyFromObject(database).String()
+	var queries []databasev1.SlowQuery
+	for _, query := range database.Status.Stats.SlowQueries {
+		if sealing.IsSealed(query.Query) {
+			if s.Sealer == nil {
+				continue
+			}
+			var err error
+			if query.Query, err = s.Sealer.Open(ctx, query.Query, binding); err != nil {
+				return nil, err
+			}
+		}
+		queries = append(queries, query)
+	}
+	return queries, nil
+}
+
+// act applies an action to a Database by patching its annotations
+func (s *RESTServer) act(w http.ResponseWriter, req *http.Request, key types.NamespacedName, action string) {
+	if req.Method != http.MethodPost {
+		writeRESTError(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+	if action != restActionPause && action != restActionResume {
+		writeRESTError(w, http.StatusNotFound, fmt.Sprintf("unknown action %q, expected %s or %s", action, restActionPause, restActionResume))
+		return
+	}
+	database := &databasev1.Database{}
+	if !s.read(w, req, key, database) {
+		return
+	}
+
+	patch := client.MergeFrom(database.DeepCopy())
+	annotations := database.GetAnnotations()
+	if action == restActionPause {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[databasev1.BreakGlassAnnotation] = "true"
+	} else {
+		delete(annotations, databasev1.BreakGlassAnnotation)
+	}
+	database.SetAnnotations(annotations)
+	if err := s.Patch(req.Context(), database, patch); err != nil {
+		log.FromContext(req.Context()).Error(err, "failed to patch Database", "database", key, "action", action)
+		writeRESTError(w, http.StatusInternalServerError, "failed to update the Database")
+		return
+	}
+
+	log.FromContext(req.Context()).Info("REST API action", "database", key, "action", action)
+	if s.Recorder != nil {
+		s.Recorder.Eventf(database, nil, corev1.EventTypeNormal, "RESTAPIAction", action,
+			"Database %s through the REST API", map[string]string{restActionPause: "paused", restActionResume: "resumed"}[action])
+	}
+	writeREST(w, http.StatusOK, summarizeDatabase(database))
+}
+
+// read gets a Database, writing the error response when it fails
+func (s *RESTServer) read(w http.ResponseWriter, req *http.Request, key types.NamespacedName, database *databasev1.Database) bool {
+	err := s.Get(req.Context(), key, database)
+	switch {
+	case apierrors.IsNotFound(err):
+		writeRESTError(w, http.StatusNotFound, fmt.Sprintf("Database %s not found", key))
+		return false
+	case err != nil:
+		log.FromContext(req.Context()).Error(err, "failed to get Database", "database", key)
+		writeRESTError(w, http.StatusInternalServerError, "failed to get the Database")
+		return false
+	}
+	return true
+}
+
+// summarizeDatabase returns the REST view of a Database
+func summarizeDatabase(database *databasev1.Database) DatabaseSummary {
+	return DatabaseSummary{
+		Namespace:     database.Namespace,
+		Name:          database.Name,
+		Phase:         database.Status.Phase,
+		Health:        databasev1.ComputeHealth(database),
+		Replicas:      database.Spec.Replicas,
+		ReadyReplicas: database.Status.ReadyReplicas,
+		Paused:        database.GetAnnotations()[databasev1.BreakGlassAnnotation] == "true",
+		Conditions:    database.Status.Conditions,
+	}
+}
+
+// writeREST writes a JSON response
+func writeREST(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeRESTError writes a JSON error response
+func writeRESTError(w http.ResponseWriter, status int, message string) {
+	writeREST(w, status, struct {
+		Error string `json:"error"`
+	}{message})
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/health"
+)
+
+func newRESTServer(t *testing.T) (*RESTServer, *events.FakeRecorder) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+
+	ready := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop", Generation: 1},
+		Spec:       databasev1.DatabaseSpec{Replicas: 2},
+		Status: databasev1.DatabaseStatus{
+			Phase: "Ready", ReadyReplicas: 2, ObservedGeneration: 1,
+			Conditions: []metav1.Condition{{Type: "Ready", Status: metav1.ConditionTrue, Reason: "Ready", ObservedGeneration: 1}},
+		},
+	}
+	other := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "metrics", Namespace: "monitoring"}}
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("s3cret\n"), 0o600))
+	recorder := events.NewFakeRecorder(10)
+	return &RESTServer{
+		Client:    fake.NewClientBuilder().WithScheme(scheme).WithObjects(ready, other).Build(),
+		Recorder:  recorder,
+		TokenFile: tokenFile,
+	}, recorder
+}
+
+func serveREST(s *RESTServer, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func TestRESTServer_Authentication(t *testing.T) {
+	server, _ := newRESTServer(t)
+
+	assert.Equal(t, http.StatusUnauthorized, serveREST(server, http.MethodGet, "/api/v1/databases", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serveREST(server, http.MethodGet, "/api/v1/databases", "wrong").Code)
+	assert.Equal(t, http.StatusOK, serveREST(server, http.MethodGet, "/api/v1/databases", "s3cret").Code)
+
+	// An empty token file locks the API instead of opening it
+	require.NoError(t, os.WriteFile(server.TokenFile, nil, 0o600))
+	assert.Equal(t, http.StatusUnauthorized, serveREST(server, http.MethodGet, "/api/v1/databases", "").Code)
+}
+
+func TestRESTServer_Read(t *testing.T) {
+	server, _ := newRESTServer(t)
+
+	w := serveREST(server, http.MethodGet, "/api/v1/databases?namespace=shop", "s3cret")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Items []DatabaseSummary `json:"items"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "orders", list.Items[0].Name)
+	assert.Equal(t, health.Current, list.Items[0].Health.Status)
+	assert.Empty(t, list.Items[0].Conditions, "Lists leave the conditions out")
+
+	w = serveREST(server, http.MethodGet, "/api/v1/namespaces/shop/databases/orders", "s3cret")
+	require.Equal(t, http.StatusOK, w.Code)
+	var summary DatabaseSummary
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&summary))
+	assert.Equal(t, int32(2), summary.ReadyReplicas)
+	assert.Len(t, summary.Conditions, 1)
+
+	assert.Equal(t, http.StatusNotFound, serveREST(server, http.MethodGet, "/api/v1/namespaces/shop/databases/missing", "s3cret").Code)
+	assert.Equal(t, http.StatusNotFound, serveREST(server, http.MethodGet, "/api/v1/secrets", "s3cret").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serveREST(server, http.MethodDelete, "/api/v1/namespaces/shop/databases/orders", "s3cret").Code)
+}
+
+func TestRESTServer_Actions(t *testing.T) {
+	server, recorder := newRESTServer(t)
+	key := types.NamespacedName{Namespace: "shop", Name: "orders"}
+
+	w := serveREST(server, http.MethodPost, "/api/v1/namespaces/shop/databases/orders/pause", "s3cret")
+	require.Equal(t, http.StatusOK, w.Code)
+	database := &databasev1.Database{}
+	require.NoError(t, server.Get(context.Background(), key, database))
+	assert.Equal(t, "true", database.Annotations[databasev1.BreakGlassAnnotation])
+	assert.Contains(t, <-recorder.Events, "paused through the REST API")
+
+	w = serveREST(server, http.MethodPost, "/api/v1/namespaces/shop/databases/orders/resume", "s3cret")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, server.Get(context.Background(), key, database))
+	assert.NotContains(t, database.Annotations, databasev1.BreakGlassAnnotation)
+
+	assert.Equal(t, http.StatusNotFound, serveREST(server, http.MethodPost, "/api/v1/namespaces/shop/databases/orders/drop", "s3cret").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serveREST(server, http.MethodGet, "/api/v1/namespaces/shop/databases/orders/pause", "s3cret").Code)
+}
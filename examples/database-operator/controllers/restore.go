@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/dependency"
+)
+
+// conditionRestoreComplete is the Restore condition recording the outcome of its Job
+const conditionRestoreComplete = "Complete"
+
+// restoreMountPath is where the dump of a Restore is downloaded to and loaded from
+const restoreMountPath = "/restore"
+
+// RestoreReconciler restores dumps into Databases. Each Restore runs one Job: an init container
+// downloads the dump with the aws CLI into an emptyDir, and a container of the database image
+// loads it with pg_restore. The dump replaces the objects it contains; pg_restore runs in a
+// single transaction, so a failed Restore leaves the Database as it was.
+//
+// The Job only starts once the Database is ready and, for the dump of a Backup, once the Backup
+// completed; the gates requeue the Restore when they do. A failed Backup fails the Restore.
+type RestoreReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// DownloaderImage is the default downloader image; empty uses DefaultUploaderImage
+	DownloaderImage string
+
+	// Passwords mounts the password of Databases that keep it in a secret manager
+	Passwords *ExternalPasswords
+
+	// Switches can turn the controller off at runtime; nil keeps it on
+	Switches *Switches
+}
+
+// restoreSource is the resolved dump of a Restore and the bucket settings to download it
+type restoreSource struct {
+	location              string
+	endpoint              string
+	region                string
+	credentialsSecretName string
+}
+
+//+kubebuilder:rbac:groups=my.domain,resources=restores,verbs=get;list;watch
+//+kubebuilder:rbac:groups=my.domain,resources=restores/status,verbs=get;update;patch
+
+// Reconcile starts the Job of a Restore once its Database is ready and its dump is available,
+// and records its outcome
+func (r *RestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	restore := &databasev1.Restore{}
+	if err := r.Get(ctx, req.NamespacedName, restore); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !restore.DeletionTimestamp.IsZero() || restore.IsFinished() {
+		// The Job is garbage collected with the Restore; the Database keeps what was restored
+		return ctrl.Result{}, nil
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: restore.Namespace, Name: restoreJobName(restore)}, job)
+	if client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	if apierrors.IsNotFound(err) {
+		ready, err := r.databaseGate().WaitFor(ctx, restore, &restore.Status.Conditions, restore.Spec.DatabaseName, "Ready")
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !ready {
+			waiting := meta.FindStatusCondition(restore.Status.Conditions, r.databaseGate().ConditionType())
+			r.setRestoreStatus(restore, databasev1.RestorePending, metav1.ConditionFalse, "WaitingForDatabase", waiting.Message)
+			return ctrl.Result{}, r.Status().Update(ctx, restore)
+		}
+		source, done, err := r.resolveSource(ctx, restore)
+		if err != nil || done {
+			return ctrl.Result{}, err
+		}
+		if job, err = r.startRestore(ctx, restore, source); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to start restore: %w", err)
+		}
+		restore.Status.Location = source.location
+	}
+
+	restore.Status.JobName = job.Name
+	if job.Status.StartTime != nil {
+		restore.Status.StartTime = job.Status.StartTime
+	} else if restore.Status.StartTime == nil {
+		now := metav1.Now()
+		restore.Status.StartTime = &now
+	}
+	completed, failed, message := jobOutcome(job)
+	switch {
+	case completed == nil:
+		// Owns(&batchv1.Job{}) reconciles again when it finishes
+		r.setRestoreStatus(restore, databasev1.RestoreRestoring, metav1.ConditionFalse, "Restoring",
+			fmt.Sprintf("Job %s is restoring Database %s", job.Name, restore.Spec.DatabaseName))
+	case failed:
+		restore.Status.CompletionTime = completed
+		r.setRestoreStatus(restore, databasev1.RestoreFailed, metav1.ConditionFalse, "Failed", message)
+	default:
+		restore.Status.CompletionTime = completed
+		r.setRestoreStatus(restore, databasev1.RestoreCompleted, metav1.ConditionTrue, "Restored",
+			fmt.Sprintf("Database %s was restored from %s", restore.Spec.DatabaseName, restore.Status.Location))
+	}
+	return ctrl.Result{}, r.Status().Update(ctx, restore)
+}
+
+// resolveSource returns the dump of a Restore. For the dump of a Backup it waits until the
+// Backup completed; done is true when the status of the Restore was written instead.
+func (r *RestoreReconciler) resolveSource(ctx context.Context, restore *databasev1.Restore) (restoreSource, bool, error) {
+	spec := restore.Spec.Source
+	if spec.BackupName == "" {
+		return restoreSource{
+			location:              spec.Location,
+			endpoint:              spec.Endpoint,
+			region:                spec.Region,
+			credentialsSecretName: spec.CredentialsSecretName,
+		}, false, nil
+	}
+
+	completed, err := r.backupGate().WaitFor(ctx, restore, &restore.Status.Conditions, spec.BackupName, conditionBackupComplete)
+	if err != nil {
+		return restoreSource{}, false, err
+	}
+	backup := &databasev1.Backup{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: restore.Namespace, Name: spec.BackupName}, backup); client.IgnoreNotFound(err) != nil {
+		return restoreSource{}, false, err
+	}
+	if !completed {
+		if backup.Status.Phase == databasev1.BackupFailed {
+			// A Backup runs once, so its dump never arrives
+			r.setRestoreStatus(restore, databasev1.RestoreFailed, metav1.ConditionFalse, "BackupFailed",
+				fmt.Sprintf("Backup %s failed", backup.Name))
+		} else {
+			waiting := meta.FindStatusCondition(restore.Status.Conditions, r.backupGate().ConditionType())
+			r.setRestoreStatus(restore, databasev1.RestorePending, metav1.ConditionFalse, "WaitingForBackup", waiting.Message)
+		}
+		return restoreSource{}, true, r.Status().Update(ctx, restore)
+	}
+	destination := backup.Spec.Destination
+	return restoreSource{
+		location:              backup.Status.Location,
+		endpoint:              destination.Endpoint,
+		region:                destination.Region,
+		credentialsSecretName: destination.CredentialsSecretName,
+	}, false, nil
+}
+
+// databaseGate gates the Job of a Restore on the Ready condition of its Database
+func (r *RestoreReconciler) databaseGate() *dependency.Gate {
+	return &dependency.Gate{
+		Kind:      "Database",
+		Object:    &databasev1.Database{},
+		Owner:     &databasev1.Restore{},
+		OwnerList: &databasev1.RestoreList{},
+		References: func(o client.Object) []string {
+			return []string{o.(*databasev1.Restore).Spec.DatabaseName}
+		},
+		Reader: r.Client,
+	}
+}
+
+// backupGate gates the Job of a Restore on the Complete condition of its Backup
+func (r *RestoreReconciler) backupGate() *dependency.Gate {
+	return &dependency.Gate{
+		Kind:      "Backup",
+		Object:    &databasev1.Backup{},
+		Owner:     &databasev1.Restore{},
+		OwnerList: &databasev1.RestoreList{},
+		References: func(o client.Object) []string {
+			if name := o.(*databasev1.Restore).Spec.Source.BackupName; name != "" {
+				return []string{name}
+			}
+			return nil
+		},
+		Reader: r.Client,
+	}
+}
+
+// setRestoreStatus sets the phase and the Complete condition of a Restore
+func (r *RestoreReconciler) setRestoreStatus(restore *databasev1.Restore, phase string, status metav1.ConditionStatus, reason, message string) {
+	restore.Status.Phase = phase
+	restore.Status.ObservedGeneration = restore.Generation
+	meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{
+		Type:               conditionRestoreComplete,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: restore.Generation,
+	})
+}
+
+// startRestore creates the Job of a Restore
+func (r *RestoreReconciler) startRestore(ctx context.Context, restore *databasev1.Restore, source restoreSource) (*batchv1.Job, error) {
+	database := &databasev1.Database{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: restore.Namespace, Name: restore.Spec.DatabaseName}, database); err != nil {
+		return nil, err
+	}
+	user, dbname := loginNames(database)
+	dump := restoreMountPath + "/" + dbname + ".dump"
+	downloaderImage := restore.Spec.DownloaderImage
+	if downloaderImage == "" {
+		downloaderImage = r.DownloaderImage
+	}
+
+	job := dumpJob(restoreJobName(restore), restore.Namespace, database, restoreMountPath,
+		s3Container("download", downloaderImage, s3Copy(source.location, dump, source.endpoint),
+			source.region, source.credentialsSecretName),
+		corev1.Container{
+			Name:  "restore",
+			Image: database.Spec.Image,
+			// Objects in the dump replace those in the Database, and an error rolls all of it back
+			Command: []string{"pg_restore", "--clean", "--if-exists", "--no-owner", "--single-transaction",
+				"--dbname=" + dbname, dump},
+			Env: databaseEnv(database, user, dbname),
+		})
+	r.Passwords.mountPassword(database, &job.Spec.Template.Spec)
+	if err := controllerutil.SetControllerReference(restore, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// SetupWithManager sets up the controller with the Manager
+func (r *RestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&databasev1.Restore{}).
+		Owns(&batchv1.Job{})
+	// Restores waiting for their Database or Backup start when it is ready or completed
+	if err := r.databaseGate().SetupWithManager(mgr, b); err != nil {
+		return err
+	}
+	if err := r.backupGate().SetupWithManager(mgr, b); err != nil {
+		return err
+	}
+	return b.Complete(r.Switches.Controller(RestoreController, r))
+}
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func newRestoreFixture(t *testing.T, objects ...client.Object) (*RestoreReconciler, client.Client) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, batchv1.AddToScheme(scheme))
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(&databasev1.Restore{}, &databasev1.Backup{}, &databasev1.Database{}, &batchv1.Job{}).
+		Build()
+	return &RestoreReconciler{Client: fakeClient, Scheme: scheme}, fakeClient
+}
+
+func newNightlyRestore() *databasev1.Restore {
+	return &databasev1.Restore{
+		ObjectMeta: metav1.ObjectMeta{Name: "from-nightly", Namespace: "default"},
+		Spec: databasev1.RestoreSpec{
+			DatabaseName: "orders",
+			Source:       databasev1.RestoreSource{BackupName: "nightly"},
+		},
+	}
+}
+
+// setBackupPhase stands in for the BackupReconciler
+func setBackupPhase(t *testing.T, c client.Client, phase string) {
+	ctx := context.Background()
+	backup := &databasev1.Backup{}
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "nightly", Namespace: "default"}, backup))
+	backup.Status.Phase = phase
+	backup.Status.Location = "s3://backups/postgres/default/orders/nightly.dump"
+	status := metav1.ConditionFalse
+	if phase == databasev1.BackupCompleted {
+		status = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&backup.Status.Conditions, metav1.Condition{
+		Type: conditionBackupComplete, Status: status, Reason: phase,
+	})
+	require.NoError(t, c.Status().Update(ctx, backup))
+}
+
+func TestRestoreReconciler_RestoresBackup(t *testing.T) {
+	reconciler, fakeClient := newRestoreFixture(t, newNightlyRestore(), newNightlyBackup(), newBackupDatabase())
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "from-nightly", Namespace: "default"}
+	jobKey := types.NamespacedName{Name: "from-nightly-restore", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	// Nothing runs before the Database is ready
+	restore := &databasev1.Restore{}
+	require.NoError(t, fakeClient.Get(ctx, key, restore))
+	assert.Equal(t, databasev1.RestorePending, restore.Status.Phase)
+	assert.Equal(t, "WaitingForDatabase", meta.FindStatusCondition(restore.Status.Conditions, conditionRestoreComplete).Reason)
+
+	// Nor before the Backup completed
+	setDatabaseReady(t, fakeClient, types.NamespacedName{Name: "orders", Namespace: "default"}, metav1.ConditionTrue)
+	setBackupPhase(t, fakeClient, databasev1.BackupRunning)
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, restore))
+	assert.Equal(t, databasev1.RestorePending, restore.Status.Phase)
+	assert.Equal(t, "WaitingForBackup", meta.FindStatusCondition(restore.Status.Conditions, conditionRestoreComplete).Reason)
+	assert.True(t, apierrors.IsNotFound(fakeClient.Get(ctx, jobKey, &batchv1.Job{})))
+
+	setBackupPhase(t, fakeClient, databasev1.BackupCompleted)
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	job := &batchv1.Job{}
+	require.NoError(t, fakeClient.Get(ctx, jobKey, job))
+	assert.True(t, metav1.IsControlledBy(job, restore))
+	podSpec := job.Spec.Template.Spec
+	require.Len(t, podSpec.InitContainers, 1)
+	download := podSpec.InitContainers[0]
+	assert.Equal(t, DefaultUploaderImage, download.Image)
+	assert.Equal(t, []string{"aws", "s3", "cp", "s3://backups/postgres/default/orders/nightly.dump", "/restore/app.dump",
+		"--endpoint-url", "http://minio.storage.svc:9000"}, download.Command, "The bucket settings are the Backup's")
+	assert.Equal(t, []corev1.EnvVar{{Name: "AWS_DEFAULT_REGION", Value: "eu-west-1"}}, download.Env)
+	assert.Equal(t, "minio-credentials", download.EnvFrom[0].SecretRef.Name)
+	require.Len(t, podSpec.Containers, 1)
+	load := podSpec.Containers[0]
+	assert.Equal(t, "postgres:15", load.Image)
+	assert.Equal(t, []string{"pg_restore", "--clean", "--if-exists", "--no-owner", "--single-transaction",
+		"--dbname=app", "/restore/app.dump"}, load.Command)
+	assert.Contains(t, load.Env, corev1.EnvVar{Name: "PGHOST", Value: "orders"})
+
+	require.NoError(t, fakeClient.Get(ctx, key, restore))
+	assert.Equal(t, databasev1.RestoreRestoring, restore.Status.Phase)
+	assert.Equal(t, "from-nightly-restore", restore.Status.JobName)
+	assert.Equal(t, "s3://backups/postgres/default/orders/nightly.dump", restore.Status.Location)
+	assert.NotNil(t, restore.Status.StartTime)
+
+	finishJob(t, fakeClient, jobKey, batchv1.JobComplete, "")
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, restore))
+	assert.Equal(t, databasev1.RestoreCompleted, restore.Status.Phase)
+	assert.NotNil(t, restore.Status.CompletionTime)
+	condition := meta.FindStatusCondition(restore.Status.Conditions, conditionRestoreComplete)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "Restored", condition.Reason)
+
+	// A finished Restore is left alone, even once its Job is gone
+	require.NoError(t, fakeClient.Delete(ctx, job))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.True(t, apierrors.IsNotFound(fakeClient.Get(ctx, jobKey, &batchv1.Job{})), "A Restore runs once")
+}
+
+func TestRestoreReconciler_BackupFailed(t *testing.T) {
+	reconciler, fakeClient := newRestoreFixture(t, newNightlyRestore(), newNightlyBackup(), newBackupDatabase())
+	setDatabaseReady(t, fakeClient, types.NamespacedName{Name: "orders", Namespace: "default"}, metav1.ConditionTrue)
+	setBackupPhase(t, fakeClient, databasev1.BackupFailed)
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "from-nightly", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	restore := &databasev1.Restore{}
+	require.NoError(t, fakeClient.Get(ctx, key, restore))
+	assert.Equal(t, databasev1.RestoreFailed, restore.Status.Phase)
+	assert.Equal(t, "BackupFailed", meta.FindStatusCondition(restore.Status.Conditions, conditionRestoreComplete).Reason)
+	assert.True(t, apierrors.IsNotFound(fakeClient.Get(ctx, types.NamespacedName{Name: "from-nightly-restore", Namespace: "default"}, &batchv1.Job{})))
+}
+
+func TestRestoreReconciler_Location(t *testing.T) {
+	restore := newNightlyRestore()
+	restore.Spec.Source = databasev1.RestoreSource{
+		Location:              "s3://exports/orders.dump",
+		Region:                "us-east-1",
+		CredentialsSecretName: "aws-credentials",
+	}
+	restore.Spec.DownloaderImage = "minio/mc-aws:1.0"
+	reconciler, fakeClient := newRestoreFixture(t, restore, newBackupDatabase())
+	reconciler.DownloaderImage = "registry.example.com/aws-cli:2"
+	setDatabaseReady(t, fakeClient, types.NamespacedName{Name: "orders", Namespace: "default"}, metav1.ConditionTrue)
+
+	ctx := context.Background()
+	key := client.ObjectKeyFromObject(restore)
+	jobKey := types.NamespacedName{Name: "from-nightly-restore", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	job := &batchv1.Job{}
+	require.NoError(t, fakeClient.Get(ctx, jobKey, job))
+	download := job.Spec.Template.Spec.InitContainers[0]
+	assert.Equal(t, "minio/mc-aws:1.0", download.Image, "The Restore's image wins")
+	assert.Equal(t, []string{"aws", "s3", "cp", "s3://exports/orders.dump", "/restore/app.dump"}, download.Command)
+	assert.Equal(t, "aws-credentials", download.EnvFrom[0].SecretRef.Name)
+
+	finishJob(t, fakeClient, jobKey, batchv1.JobFailed, "Job has reached the specified backoff limit")
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, restore))
+	assert.Equal(t, databasev1.RestoreFailed, restore.Status.Phase)
+	assert.Equal(t, "s3://exports/orders.dump", restore.Status.Location)
+	condition := meta.FindStatusCondition(restore.Status.Conditions, conditionRestoreComplete)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "Failed: Job has reached the specified backoff limit", condition.Message)
+}
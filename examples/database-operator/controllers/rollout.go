@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// conditionRolloutStalled is set when the database Deployment can no longer make progress
+	conditionRolloutStalled = "RolloutStalled"
+
+	// reasonProgressDeadlineExceeded mirrors the reason the Deployment controller reports
+	reasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+)
+
+// stalledWaitingReasons are container waiting reasons that will not resolve without intervention
+var stalledWaitingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"CreateContainerConfigError": true,
+	"InvalidImageName":           true,
+}
+
+// rolloutStall describes why a Database rollout is not making progress
+type rolloutStall struct {
+	Reason  string
+	Message string
+}
+
+// detectRolloutStall checks the Deployment and its pods for a rollout that is stuck.
+// It returns nil if the rollout is still progressing normally.
+func detectRolloutStall(deployment *appsv1.Deployment, pods []corev1.Pod) *rolloutStall {
+	podStall := findStalledPod(pods)
+
+	if deploymentProgressDeadlineExceeded(deployment) {
+		message := fmt.Sprintf("Deployment %s exceeded its progress deadline", deployment.Name)
+		if podStall != nil {
+			message = fmt.Sprintf("%s; %s", message, podStall.Message)
+		}
+		return &rolloutStall{Reason: reasonProgressDeadlineExceeded, Message: message}
+	}
+
+	return podStall
+}
+
+// deploymentProgressDeadlineExceeded returns true if the Deployment controller gave up on the rollout
+func deploymentProgressDeadlineExceeded(deployment *appsv1.Deployment) bool {
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing &&
+			condition.Status == corev1.ConditionFalse &&
+			condition.Reason == reasonProgressDeadlineExceeded {
+			return true
+		}
+	}
+	return false
+}
+
+// findStalledPod returns the first pod with a container stuck in a non-recoverable waiting state
+func findStalledPod(pods []corev1.Pod) *rolloutStall {
+	for _, pod := range pods {
+		statuses := append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+		statuses = append(statuses, pod.Status.ContainerStatuses...)
+
+		for _, status := range statuses {
+			if status.State.Waiting == nil || !stalledWaitingReasons[status.State.Waiting.Reason] {
+				continue
+			}
+			return &rolloutStall{
+				Reason: status.State.Waiting.Reason,
+				Message: fmt.Sprintf("pod %s container %s is in %s: %s",
+					pod.Name, status.Name, status.State.Waiting.Reason, lastTerminationMessage(status)),
+			}
+		}
+	}
+	return nil
+}
+
+// lastTerminationMessage returns the most useful explanation for why a container is not running
+func lastTerminationMessage(status corev1.ContainerStatus) string {
+	if terminated := status.LastTerminationState.Terminated; terminated != nil {
+		if terminated.Message != "" {
+			return terminated.Message
+		}
+		return fmt.Sprintf("exited with code %d (%s)", terminated.ExitCode, terminated.Reason)
+	}
+	if status.State.Waiting.Message != "" {
+		return status.State.Waiting.Message
+	}
+	return "no termination message"
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDetectRolloutStall(t *testing.T) {
+	crashingPod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-db-abc12",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "test-db"},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name: "database",
+					State: corev1.ContainerState{
+						Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+					},
+					LastTerminationState: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							ExitCode: 1,
+							Message:  "initdb: directory is not empty",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	deadlineExceeded := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-db",
+			Namespace: "default",
+		},
+		Status: appsv1.DeploymentStatus{
+			Conditions: []appsv1.DeploymentCondition{
+				{
+					Type:   appsv1.DeploymentProgressing,
+					Status: corev1.ConditionFalse,
+					Reason: reasonProgressDeadlineExceeded,
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		deployment    *appsv1.Deployment
+		pods          []corev1.Pod
+		expectStall   bool
+		expectReason  string
+		expectMessage string
+	}{
+		{
+			name:        "progressing rollout",
+			deployment:  &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-db"}},
+			expectStall: false,
+		},
+		{
+			name:          "crash looping pod",
+			deployment:    &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-db"}},
+			pods:          []corev1.Pod{crashingPod},
+			expectStall:   true,
+			expectReason:  "CrashLoopBackOff",
+			expectMessage: "initdb: directory is not empty",
+		},
+		{
+			name:          "progress deadline exceeded",
+			deployment:    deadlineExceeded,
+			pods:          []corev1.Pod{crashingPod},
+			expectStall:   true,
+			expectReason:  reasonProgressDeadlineExceeded,
+			expectMessage: "test-db-abc12",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stall := detectRolloutStall(tt.deployment, tt.pods)
+
+			if !tt.expectStall {
+				assert.Nil(t, stall)
+				return
+			}
+			require.NotNil(t, stall)
+			assert.Equal(t, tt.expectReason, stall.Reason)
+			assert.Contains(t, stall.Message, tt.expectMessage)
+		})
+	}
+}
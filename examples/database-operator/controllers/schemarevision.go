@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// reasonNewerSchemaRevision is the Warning event reason for Databases left alone because a
+// newer operator wrote them
+const reasonNewerSchemaRevision = "NewerSchemaRevision"
+
+// mixedVersionDatabases reports the Databases this operator refuses to manage
+var mixedVersionDatabases = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "database_operator_mixed_version",
+	Help: "Number of Databases written by an operator with a newer schema revision, which this operator does not manage until it is upgraded.",
+})
+
+func init() {
+	metrics.Registry.MustRegister(mixedVersionDatabases)
+}
+
+// schemaRevision returns the revision recorded on a Database; zero if it was never stamped
+func schemaRevision(database *databasev1.Database) (int, error) {
+	value, ok := database.Annotations[databasev1.SchemaRevisionAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	revision, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation %q: %w", databasev1.SchemaRevisionAnnotation, value, err)
+	}
+	return revision, nil
+}
+
+// stampSchemaRevision records this operator's revision on a Database unless a newer one is
+// recorded already, and reports whether it changed anything
+func stampSchemaRevision(database *databasev1.Database) bool {
+	if revision, err := schemaRevision(database); err == nil && revision >= databasev1.SchemaRevision {
+		return false
+	}
+	if database.Annotations == nil {
+		database.Annotations = map[string]string{}
+	}
+	database.Annotations[databasev1.SchemaRevisionAnnotation] = strconv.Itoa(databasev1.SchemaRevision)
+	return true
+}
+
+// schemaSkew tracks the Databases written by a newer operator, for the mixed version metric.
+// The zero value is ready to use.
+type schemaSkew struct {
+	mu    sync.Mutex
+	newer map[types.NamespacedName]int
+}
+
+// observe records the revision of a Database
+func (s *schemaSkew) observe(key types.NamespacedName, revision int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.newer == nil {
+		s.newer = map[types.NamespacedName]int{}
+	}
+	if revision > databasev1.SchemaRevision {
+		s.newer[key] = revision
+	} else {
+		delete(s.newer, key)
+	}
+	mixedVersionDatabases.Set(float64(len(s.newer)))
+}
+
+// forget drops a deleted Database
+func (s *schemaSkew) forget(key types.NamespacedName) {
+	s.observe(key, 0)
+}
+
+// checkSchemaRevision stamps a Database with this operator's revision, and reports whether it
+// must be left alone because a newer operator wrote it. An older operator does not know the
+// fields a newer one added, so any Update it makes, including status and finalizer updates,
+// would drop them. This happens during a staged rollout, while the newer webhook already admits
+// Databases and the older replica still holds the lease, and after a rollback.
+func (r *DatabaseReconciler) checkSchemaRevision(ctx context.Context, database *databasev1.Database) (bool, error) {
+	key := client.ObjectKeyFromObject(database)
+	revision, err := schemaRevision(database)
+	if err != nil {
+		// Overwritten below; a newer operator never writes an invalid value
+		log.FromContext(ctx).Info("Replacing schema revision", "error", err.Error())
+	}
+	r.schemaSkew.observe(key, revision)
+
+	if revision > databasev1.SchemaRevision {
+		r.warn(database, nil, reasonNewerSchemaRevision, "Reconcile", fmt.Sprintf(
+			"Database was written by an operator with schema revision %d, this operator has %d; not managing it until the operator is upgraded",
+			revision, databasev1.SchemaRevision))
+		return true, nil
+	}
+
+	// Nearly every reconcile finds the current revision; copying the Database for a patch that
+	// is not sent would be garbage
+	if err == nil && revision == databasev1.SchemaRevision {
+		return false, nil
+	}
+
+	// A merge patch of the annotation only, so it cannot drop unknown fields either
+	patch := client.MergeFrom(database.DeepCopy())
+	if !stampSchemaRevision(database) {
+		return false, nil
+	}
+	return false, r.Patch(ctx, database, patch)
+}
+
+// checkSchemaRevisions runs once the operator is elected, before the first reconciles finish,
+// and reports every Database a newer operator wrote. The mixed version metric is accurate from
+// startup on instead of filling up as the initial reconciles go through.
+func (r *DatabaseReconciler) checkSchemaRevisions(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("schema-revision")
+
+	var databases databasev1.DatabaseList
+	if err := r.List(ctx, &databases); err != nil {
+		return fmt.Errorf("failed to list Databases: %w", err)
+	}
+
+	var newer []string
+	for i := range databases.Items {
+		database := &databases.Items[i]
+		revision, _ := schemaRevision(database)
+		r.schemaSkew.observe(client.ObjectKeyFromObject(database), revision)
+		if revision > databasev1.SchemaRevision {
+			newer = append(newer, client.ObjectKeyFromObject(database).String())
+		}
+	}
+	if len(newer) > 0 {
+		logger.Info("Databases were written by a newer operator and will not be managed until this operator is upgraded",
+			"revision", databasev1.SchemaRevision, "databases", newer)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestDatabaseReconciler_SchemaRevision(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	newer := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "newer",
+			Namespace:   "default",
+			Annotations: map[string]string{databasev1.SchemaRevisionAnnotation: strconv.Itoa(databasev1.SchemaRevision + 1)},
+		},
+		Spec: databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+	older := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "older",
+			Namespace:   "default",
+			Annotations: map[string]string{databasev1.SchemaRevisionAnnotation: "0"},
+		},
+		Spec: databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(newer, older).
+		WithStatusSubresource(newer, older).
+		Build()
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme, Recorder: recorder}
+	ctx := context.Background()
+
+	// The startup check reports the newer Database before it is reconciled
+	require.NoError(t, reconciler.checkSchemaRevisions(ctx))
+	assert.Equal(t, 1.0, testutil.ToFloat64(mixedVersionDatabases))
+
+	// A Database a newer operator wrote is left untouched
+	key := client.ObjectKeyFromObject(newer)
+	result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, ctrl.Result{}, result)
+	require.NoError(t, fakeClient.Get(ctx, key, newer))
+	assert.Empty(t, newer.Finalizers, "No finalizer is added")
+	assert.Empty(t, newer.Status.Phase, "No status is written")
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, reasonNewerSchemaRevision)
+
+	// An older revision is raised to this operator's before the Database is managed
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(older)})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(older), older))
+	assert.Equal(t, strconv.Itoa(databasev1.SchemaRevision), older.Annotations[databasev1.SchemaRevisionAnnotation])
+	assert.Contains(t, older.Finalizers, databaseFinalizer)
+
+	// The metric drops once the newer Database is gone
+	require.NoError(t, fakeClient.Delete(ctx, newer))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, testutil.ToFloat64(mixedVersionDatabases))
+}
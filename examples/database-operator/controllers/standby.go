@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+const (
+	// conditionStandby is True while a standby Database replicates from its primary and
+	// False once it was promoted; Databases without spec.standby do not have it
+	conditionStandby = "Standby"
+
+	// roleLabel tells the pods of a standby Database and the replicas of a highly available
+	// one apart from writable ones, e.g. for a Service or NetworkPolicy that only selects
+	// primaries
+	roleLabel = "database.my.domain/role"
+
+	// pgdata is the data directory of the postgres image, on the data volume
+	pgdata = "/var/lib/postgresql/data"
+
+	// postgresUID is the postgres user of the postgres image; the data directory must be
+	// owned by it
+	postgresUID = int64(999)
+)
+
+// primaryConnectionKeys are the keys a primary connection Secret must have
+var primaryConnectionKeys = []string{"host", "port", "username", "password"}
+
+// standbyBootstrapScript clones the primary into an empty data directory.
+// --write-recovery-conf writes standby.signal and the primary_conninfo streaming replication connects with.
+const standbyBootstrapScript = `set -eu
+if [ -s "$PGDATA/PG_VERSION" ]; then
+  exit 0
+fi
+pg_basebackup --pgdata="$PGDATA" --wal-method=stream --write-recovery-conf --checkpoint=fast
+`
+
+// walArchiveBootstrapScript clones the primary once, then replays archived WAL with the
+// restore_command from the Secret instead of connecting to the primary. Single quotes are
+// doubled for the configuration file.
+const walArchiveBootstrapScript = `set -eu
+if [ -s "$PGDATA/PG_VERSION" ]; then
+  exit 0
+fi
+pg_basebackup --pgdata="$PGDATA" --wal-method=stream --checkpoint=fast
+touch "$PGDATA/standby.signal"
+printf "restore_command = '%s'\n" "$(printf '%s' "$RESTORE_COMMAND" | sed "s/'/''/g")" >> "$PGDATA/postgresql.auto.conf"
+`
+
+// standbyPromoteScript promotes a standby on its next start: without standby.signal,
+// PostgreSQL finishes recovery and accepts writes
+const standbyPromoteScript = `rm -f "$PGDATA/standby.signal" "$PGDATA/recovery.signal"
+`
+
+// isPromoted reports whether a standby Database was promoted, or asked to be
+func isPromoted(database *databasev1.Database) bool {
+	return database.Spec.Standby != nil && (database.Spec.Standby.Promote || database.Status.PromotedAt != nil)
+}
+
+// isStandby reports whether a Database replicates from a primary
+func isStandby(database *databasev1.Database) bool {
+	return database.Spec.Standby != nil && !isPromoted(database)
+}
+
+// reconcileStandby checks the primary connection Secret of a standby Database and records
+// the Standby condition. A promoted Database no longer needs the primary.
+func (r *DatabaseReconciler) reconcileStandby(ctx context.Context, database *databasev1.Database) error {
+	standby := database.Spec.Standby
+	if standby == nil {
+		return nil
+	}
+
+	if isPromoted(database) {
+		if database.Status.PromotedAt == nil {
+			now := metav1.Now()
+			database.Status.PromotedAt = &now
+			if r.Recorder != nil {
+				r.Recorder.Eventf(database, nil, corev1.EventTypeNormal, "Promoted", "Promote", "Standby promoted to a primary")
+			}
+		}
+		database.SetCondition(conditionStandby, metav1.ConditionFalse, "Promoted",
+			fmt.Sprintf("Promoted at %s", database.Status.PromotedAt.UTC().Format(time.RFC3339)))
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: database.Namespace, Name: standby.PrimaryConnectionSecret}
+	if err := r.Get(ctx, key, secret); err != nil {
+		return fmt.Errorf("failed to get primary connection Secret %s: %w", standby.PrimaryConnectionSecret, err)
+	}
+	keys := primaryConnectionKeys
+	if standby.Source == databasev1.StandbyWALArchive {
+		keys = append(keys[:len(keys):len(keys)], "restoreCommand")
+	}
+	for _, k := range keys {
+		if len(secret.Data[k]) == 0 {
+			return fmt.Errorf("primary connection Secret %s has no %q key", standby.PrimaryConnectionSecret, k)
+		}
+	}
+
+	source := standby.Source
+	if source == "" {
+		source = databasev1.StandbyStreaming
+	}
+	database.SetCondition(conditionStandby, metav1.ConditionTrue, "Replicating",
+		fmt.Sprintf("Replicating from %s (%s)", secret.Data["host"], source))
+	return nil
+}
+
+// standbyInitContainer renders the init container that bootstraps a standby from its primary,
+// or promotes it once asked to. It runs as the postgres user with the database container's
+// resources, so it passes the pod policy.
+func standbyInitContainer(database *databasev1.Database, resources corev1.ResourceRequirements) corev1.Container {
+	standby := database.Spec.Standby
+	allowPrivilegeEscalation := false
+	uid := postgresUID
+	container := corev1.Container{
+		Image:     database.Spec.Image,
+		Env:       []corev1.EnvVar{{Name: "PGDATA", Value: pgdata}},
+		Resources: resources,
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "data", MountPath: pgdata},
+		},
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+			RunAsUser:                &uid,
+		},
+	}
+
+	if isPromoted(database) {
+		container.Name = "standby-promote"
+		container.Command = []string{"sh", "-c", standbyPromoteScript}
+		return container
+	}
+
+	container.Name = "standby-bootstrap"
+	script := standbyBootstrapScript
+	if standby.Source == databasev1.StandbyWALArchive {
+		script = walArchiveBootstrapScript
+	}
+	container.Command = []string{"sh", "-c", script}
+
+	// libpq reads the connection from the environment
+	env := []struct{ name, key string }{
+		{"PGHOST", "host"},
+		{"PGPORT", "port"},
+		{"PGUSER", "username"},
+		{"PGPASSWORD", "password"},
+	}
+	if standby.Source == databasev1.StandbyWALArchive {
+		env = append(env, struct{ name, key string }{"RESTORE_COMMAND", "restoreCommand"})
+	}
+	for _, e := range env {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name: e.name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: standby.PrimaryConnectionSecret},
+					Key:                  e.key,
+				},
+			},
+		})
+	}
+	return container
+}
+
+// role is the roleLabel value of a standby Database's pods
+func role(database *databasev1.Database) string {
+	if isStandby(database) {
+		return "standby"
+	}
+	return "primary"
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func newStandbyDatabase(source databasev1.StandbySource) *databasev1.Database {
+	return &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "replica", Namespace: "default"},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+			Resources: &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("500m"),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+			},
+			Standby: &databasev1.StandbySpec{PrimaryConnectionSecret: "primary", Source: source},
+		},
+	}
+}
+
+func TestDatabaseReconciler_ReconcileStandby(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "primary", Namespace: "default"},
+		Data: map[string][]byte{
+			"host":     []byte("db.eu-west.example.com"),
+			"port":     []byte("5432"),
+			"username": []byte("replicator"),
+			"password": []byte("secret"),
+		},
+	}
+	reconciler := &DatabaseReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+		Scheme: scheme,
+	}
+	ctx := context.Background()
+
+	database := newStandbyDatabase(databasev1.StandbyStreaming)
+	require.NoError(t, reconciler.reconcileStandby(ctx, database))
+	condition := meta.FindStatusCondition(database.Status.Conditions, conditionStandby)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "Replicating from db.eu-west.example.com (Streaming)", condition.Message)
+
+	// WAL archive replay needs a restore command the Secret does not have
+	assert.ErrorContains(t, reconciler.reconcileStandby(ctx, newStandbyDatabase(databasev1.StandbyWALArchive)), "restoreCommand")
+
+	database.Spec.Standby.Promote = true
+	require.NoError(t, reconciler.reconcileStandby(ctx, database))
+	require.NotNil(t, database.Status.PromotedAt)
+	assert.True(t, meta.IsStatusConditionFalse(database.Status.Conditions, conditionStandby))
+
+	// Promotion does not depend on the primary, which is usually gone by then
+	require.NoError(t, reconciler.Delete(ctx, secret))
+	promotedAt := database.Status.PromotedAt
+	require.NoError(t, reconciler.reconcileStandby(ctx, database))
+	assert.Equal(t, promotedAt, database.Status.PromotedAt)
+}
+
+func TestRenderPodSpec_Standby(t *testing.T) {
+	database := newStandbyDatabase(databasev1.StandbyWALArchive)
+
+	podSpec := renderPodSpec(database)
+	require.Len(t, podSpec.InitContainers, 1)
+	bootstrap := podSpec.InitContainers[0]
+	assert.Equal(t, "standby-bootstrap", bootstrap.Name)
+	assert.Equal(t, database.Spec.Image, bootstrap.Image)
+	assert.Contains(t, bootstrap.Command[2], "restore_command")
+
+	var secretKeys []string
+	for _, env := range bootstrap.Env {
+		if env.ValueFrom != nil {
+			assert.Equal(t, "primary", env.ValueFrom.SecretKeyRef.Name)
+			secretKeys = append(secretKeys, env.ValueFrom.SecretKeyRef.Key)
+		}
+	}
+	assert.Equal(t, []string{"host", "port", "username", "password", "restoreCommand"}, secretKeys)
+	assert.Empty(t, checkPodPolicy(&podSpec, field.NewPath("spec")), "The init container must pass the pod policy")
+	assert.Equal(t, "standby", PropagationPolicy{}.podLabels(database)[roleLabel])
+
+	database.Spec.Standby.Promote = true
+	podSpec = renderPodSpec(database)
+	require.Len(t, podSpec.InitContainers, 1)
+	assert.Equal(t, "standby-promote", podSpec.InitContainers[0].Name)
+	assert.Equal(t, "primary", PropagationPolicy{}.podLabels(database)[roleLabel])
+
+	database.Spec.Standby = nil
+	assert.Empty(t, renderPodSpec(database).InitContainers)
+	assert.NotContains(t, PropagationPolicy{}.podLabels(database), roleLabel)
+}
@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/sealing"
+)
+
+// statsQueryTimeout bounds the queries against one Database, so an overloaded server does
+// not hold up the others
+const statsQueryTimeout = 10 * time.Second
+
+var (
+	databaseConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "database_connections",
+		Help: "Client connections of a Database by state, from pg_stat_activity.",
+	}, []string{"namespace", "name", "state"})
+
+	databaseMaxConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "database_max_connections",
+		Help: "The max_connections setting of a Database.",
+	}, []string{"namespace", "name"})
+
+	databaseLongestTransaction = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "database_longest_transaction_seconds",
+		Help: "Age of the oldest open transaction of a Database.",
+	}, []string{"namespace", "name"})
+
+	databaseSlowQueryMean = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "database_slow_query_mean_seconds",
+		Help: "Mean execution time of the slowest statements of a Database, from pg_stat_statements.",
+	}, []string{"namespace", "name", "queryid"})
+
+	databaseSlowQueryCalls = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "database_slow_query_calls",
+		Help: "Calls of the slowest statements of a Database since pg_stat_statements was last reset.",
+	}, []string{"namespace", "name", "queryid"})
+
+	databaseStatsErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "database_stats_collection_errors_total",
+		Help: "Number of failed attempts to collect statistics from a Database.",
+	}, []string{"namespace", "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(databaseConnections, databaseMaxConnections, databaseLongestTransaction,
+		databaseSlowQueryMean, databaseSlowQueryCalls, databaseStatsErrors)
+}
+
+// StatsQuerier takes a statistics snapshot of the server behind a connection URL, with at
+// most topQueries slow queries
+type StatsQuerier interface {
+	QueryStats(ctx context.Context, dsn string, topQueries int) (*databasev1.DatabaseStats, error)
+}
+
+// StatsCollector periodically queries pg_stat_activity and pg_stat_statements of every ready
+// Database and exports connection counts, the longest open transaction and the slowest
+// statements as metrics. With StatusSummary it also records them in status.stats, so app
+// teams see them on the Database they own without access to the monitoring stack.
+//
+// Only slow statements are labelled, by queryid, to bound the metric cardinality; the
+// statement text is only kept in the status.
+type StatsCollector struct {
+	client.Client
+
+	// Querier takes the snapshots; SQLStats by default
+	Querier StatsQuerier
+
+	// Interval between collections; zero disables the collector
+	Interval time.Duration
+
+	// TopQueries is how many slow queries are kept per Database
+	TopQueries int
+
+	// StatusSummary records the snapshot in status.stats
+	StatusSummary bool
+
+	// Sealer encrypts the statement text recorded in status.stats, which may name tables and
+	// columns the owners of the Database want kept from its readers; nil records it as is
+	Sealer sealing.Sealer
+
+	// Passwords resolves passwords kept in an external secret manager
+	Passwords *ExternalPasswords
+
+	// exported are the Databases metrics were exported for in the last collection
+	exported map[types.NamespacedName]bool
+}
+
+var _ manager.LeaderElectionRunnable = &StatsCollector{}
+
+// NewStatsCollector returns a collector with the default number of slow queries
+func NewStatsCollector() *StatsCollector {
+	return &StatsCollector{TopQueries: 5}
+}
+
+// BindFlags registers flags for the collector, using the current values as defaults
+func (c *StatsCollector) BindFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&c.Interval, "stats-interval", c.Interval,
+		"How often connection and query statistics are collected from ready Databases. Zero disables the collector.")
+	fs.IntVar(&c.TopQueries, "stats-top-queries", c.TopQueries,
+		"How many of the slowest statements of each Database are exported.")
+	fs.BoolVar(&c.StatusSummary, "stats-status-summary", c.StatusSummary,
+		"Record the collected statistics in the status of each Database.")
+}
+
+// Start collects statistics until the context is cancelled
+func (c *StatsCollector) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("stats-collector")
+	ctx = log.IntoContext(ctx, logger)
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := c.collect(ctx); err != nil {
+			logger.Error(err, "failed to collect database statistics")
+		}
+	}, c.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes only the leader query the Databases, so the servers see one
+// collector and the metrics come from one replica
+func (c *StatsCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// collect takes a snapshot of every ready Database and forgets the metrics of Databases
+// that are gone or no longer ready
+func (c *StatsCollector) collect(ctx context.Context) error {
+	var databases databasev1.DatabaseList
+	if err := c.List(ctx, &databases); err != nil {
+		return err
+	}
+
+	exported := map[types.NamespacedName]bool{}
+	var errs []error
+	for i := range databases.Items {
+		database := &databases.Items[i]
+		if database.Status.ReadyReplicas == 0 || !database.DeletionTimestamp.IsZero() {
+			continue
+		}
+		key := client.ObjectKeyFromObject(database)
+		if err := c.collectOne(ctx, database); err != nil {
+			databaseStatsErrors.WithLabelValues(key.Namespace, key.Name).Inc()
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		exported[key] = true
+	}
+
+	for key := range c.exported {
+		if !exported[key] {
+			deleteStatsMetrics(key)
+		}
+	}
+	c.exported = exported
+	return kerrors.NewAggregate(errs)
+}
+
+// collectOne takes a snapshot of one Database, exports it and records it in the status
+func (c *StatsCollector) collectOne(ctx context.Context, database *databasev1.Database) error {
+	dsn, err := c.connectionURL(ctx, database)
+	if err != nil {
+		return err
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, statsQueryTimeout)
+	defer cancel()
+	stats, err := c.Querier.QueryStats(queryCtx, dsn, c.TopQueries)
+	if err != nil {
+		return err
+	}
+	stats.CollectedAt = metav1.Now()
+
+	key := client.ObjectKeyFromObject(database)
+	deleteStatsMetrics(key)
+	for state, count := range stats.Connections {
+		databaseConnections.WithLabelValues(key.Namespace, key.Name, state).Set(float64(count))
+	}
+	databaseMaxConnections.WithLabelValues(key.Namespace, key.Name).Set(float64(stats.MaxConnections))
+	databaseLongestTransaction.WithLabelValues(key.Namespace, key.Name).Set(float64(stats.LongestTransactionSeconds))
+	for _, query := range stats.SlowQueries {
+		databaseSlowQueryMean.WithLabelValues(key.Namespace, key.Name, query.QueryID).Set(float64(query.MeanMilliseconds) / 1000)
+		databaseSlowQueryCalls.WithLabelValues(key.Namespace, key.Name, query.QueryID).Set(float64(query.Calls))
+	}
+
+	if !c.StatusSummary {
+		return nil
+	}
+	if len(stats.SlowQueries) > maxStatusSlowQueries {
+		stats.SlowQueries = stats.SlowQueries[:maxStatusSlowQueries]
+	}
+	if c.Sealer != nil {
+		// Every collection seals again, so the text moves to a new key within an interval
+		for i := range stats.SlowQueries {
+			if stats.SlowQueries[i].Query, err = c.Sealer.Seal(ctx, stats.SlowQueries[i].Query, key.String()); err != nil {
+				return fmt.Errorf("failed to seal slow query: %w", err)
+			}
+		}
+	}
+	patch := client.MergeFrom(database.DeepCopy())
+	database.Status.Stats = stats
+	return client.IgnoreNotFound(c.Status().Patch(ctx, database, patch))
+}
+
+// connectionURL connects as the Database user through its Service. The pods serve plain
+// connections, so TLS is disabled.
+func (c *StatsCollector) connectionURL(ctx context.Context, database *databasev1.Database) (string, error) {
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: database.Namespace, Name: passwordSecretName(database)}
+	if err := c.Get(ctx, key, secret); err != nil {
+		return "", fmt.Errorf("failed to get password Secret: %w", err)
+	}
+	password, err := c.Passwords.password(ctx, secret)
+	if err != nil {
+		return "", err
+	}
+
+	user, dbname := loginNames(database)
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, string(password)),
+		Host:     net.JoinHostPort(serviceName(database)+"."+database.Namespace+".svc", "5432"),
+		Path:     "/" + dbname,
+		RawQuery: "sslmode=disable&connect_timeout=" + strconv.Itoa(int(statsQueryTimeout.Seconds())),
+	}
+	return dsn.String(), nil
+}
+
+// loginNames returns the user and database the postgres image creates for a Database. The
+// user defaults to postgres and the database to the user.
+func loginNames(database *databasev1.Database) (user, dbname string) {
+	user = database.Spec.UserName
+	if user == "" {
+		user = "postgres"
+	}
+	dbname = database.Spec.DatabaseName
+	if dbname == "" {
+		dbname = user
+	}
+	return user, dbname
+}
+
+// deleteStatsMetrics removes the metrics of a Database, including connection states and
+// slow queries that are not in the next snapshot
+func deleteStatsMetrics(key types.NamespacedName) {
+	labels := prometheus.Labels{"namespace": key.Namespace, "name": key.Name}
+	for _, vec := range []*prometheus.GaugeVec{databaseConnections, databaseMaxConnections, databaseLongestTransaction,
+		databaseSlowQueryMean, databaseSlowQueryCalls} {
+		vec.DeletePartialMatch(labels)
+	}
+}
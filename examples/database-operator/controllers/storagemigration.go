@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions/status,verbs=update
+//+kubebuilder:rbac:groups=my.domain,resources=clusterdatabasepolicies,verbs=update
+
+// migrationPageSize bounds the objects listed per request, so a large migration does not
+// load every object at once
+const migrationPageSize = 500
+
+var (
+	storageMigrationPending = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "database_storage_migration_pending_objects",
+		Help: "Objects of a CRD not yet rewritten in the storage version by the running migration; zero when none is running.",
+	}, []string{"crd"})
+	storageMigrationComplete = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "database_storage_migration_complete",
+		Help: "1 if every object of a CRD is stored in its storage version, i.e. status.storedVersions lists only that version.",
+	}, []string{"crd"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(storageMigrationPending, storageMigrationComplete)
+}
+
+// StorageVersionMigrator rewrites custom resources stored in an old API version. After a new
+// version becomes the storage version, objects written before stay in etcd in the old one
+// until they happen to be updated, and the CRD keeps listing the old version in
+// status.storedVersions. The old version cannot be removed from the CRD while it is listed,
+// and removing it from the list by hand while objects still use it makes them unreadable.
+//
+// The migrator updates every object unchanged, which the API server stores in the current
+// storage version, and then drops the other versions from status.storedVersions. Objects are
+// read and written unstructured, so fields unknown to this operator are kept.
+type StorageVersionMigrator struct {
+	// Client writes the objects and the CRD status. Reader lists them bypassing the cache, so
+	// the migration neither starts informers nor misses objects the cache filters out.
+	Client   client.Client
+	Reader   client.Reader
+	Recorder events.EventRecorder
+
+	// Interval is how often the CRDs are checked; zero disables the migrator
+	Interval time.Duration
+
+	// CRDs are the names of the CustomResourceDefinitions to migrate
+	CRDs []string
+}
+
+var _ manager.LeaderElectionRunnable = &StorageVersionMigrator{}
+
+// NewStorageVersionMigrator returns a migrator of the operator's CRDs that checks them hourly
+func NewStorageVersionMigrator() *StorageVersionMigrator {
+	group := databasev1.GroupVersion.Group
+	return &StorageVersionMigrator{
+		Interval: time.Hour,
+		CRDs:     []string{"databases." + group, "clusterdatabasepolicies." + group},
+	}
+}
+
+// BindFlags registers flags for the migrator, using the current values as defaults
+func (m *StorageVersionMigrator) BindFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&m.Interval, "storage-migration-interval", m.Interval,
+		"How often the operator's CRDs are checked for objects stored in an old API version. Zero disables the migration.")
+}
+
+// Start migrates at startup, when a new operator version has just changed the storage
+// version, and then periodically until the context is cancelled
+func (m *StorageVersionMigrator) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("storage-migration")
+	ctx = log.IntoContext(ctx, logger)
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := m.migrateAll(ctx); err != nil {
+			logger.Error(err, "failed to migrate stored versions")
+		}
+	}, m.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes only the leader write objects
+func (m *StorageVersionMigrator) NeedLeaderElection() bool {
+	return true
+}
+
+// migrateAll migrates every CRD once
+func (m *StorageVersionMigrator) migrateAll(ctx context.Context) error {
+	var errs []error
+	for _, name := range m.CRDs {
+		if err := m.migrate(ctx, name); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// migrate rewrites the objects of a CRD stored in versions other than the storage version
+func (m *StorageVersionMigrator) migrate(ctx context.Context, name string) error {
+	logger := log.FromContext(ctx).WithValues("crd", name)
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := m.Reader.Get(ctx, client.ObjectKey{Name: name}, crd); err != nil {
+		return err
+	}
+	storageVersion := ""
+	for _, version := range crd.Spec.Versions {
+		if version.Storage {
+			storageVersion = version.Name
+		}
+	}
+	if storageVersion == "" {
+		return fmt.Errorf("no version is marked as the storage version")
+	}
+	if len(crd.Status.StoredVersions) == 1 && crd.Status.StoredVersions[0] == storageVersion {
+		storageMigrationComplete.WithLabelValues(name).Set(1)
+		return nil
+	}
+	storageMigrationComplete.WithLabelValues(name).Set(0)
+
+	started := time.Now()
+	logger.Info("Migrating stored versions", "storedVersions", crd.Status.StoredVersions, "storageVersion", storageVersion)
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.GroupVersionKind{Group: crd.Spec.Group, Version: storageVersion, Kind: crd.Spec.Names.ListKind})
+
+	// The remaining count of a list is an estimate, so the gauge is only approximate
+	migrated := 0
+	for {
+		if err := m.Reader.List(ctx, list, client.Limit(migrationPageSize), client.Continue(list.GetContinue())); err != nil {
+			return fmt.Errorf("failed to list objects: %w", err)
+		}
+		pending := len(list.Items)
+		if remaining := list.GetRemainingItemCount(); remaining != nil {
+			pending += int(*remaining)
+		}
+		for i := range list.Items {
+			storageMigrationPending.WithLabelValues(name).Set(float64(pending - i))
+			if err := m.rewrite(ctx, &list.Items[i]); err != nil {
+				storageMigrationPending.WithLabelValues(name).Set(0)
+				return err
+			}
+			migrated++
+		}
+		if list.GetContinue() == "" {
+			break
+		}
+	}
+	storageMigrationPending.WithLabelValues(name).Set(0)
+
+	// Every object is now stored in the storage version. Objects created during the migration
+	// were created in it too, since the storage version was already in effect.
+	previous := crd.Status.StoredVersions
+	crd.Status.StoredVersions = []string{storageVersion}
+	if err := m.Client.Status().Update(ctx, crd); err != nil {
+		return fmt.Errorf("failed to update stored versions: %w", err)
+	}
+	storageMigrationComplete.WithLabelValues(name).Set(1)
+	logger.Info("Migrated stored versions", "objects", migrated, "duration", time.Since(started).Round(time.Millisecond))
+	if m.Recorder != nil {
+		m.Recorder.Eventf(crd, nil, corev1.EventTypeNormal, "StorageVersionMigrated", "Migrate",
+			"Rewrote %d objects in %s; stored versions were %v", migrated, storageVersion, previous)
+	}
+	return nil
+}
+
+// rewrite updates an object unchanged. A conflict means it was written since it was listed,
+// which stored it in the storage version as well, and a deleted object needs no migration.
+func (m *StorageVersionMigrator) rewrite(ctx context.Context, obj *unstructured.Unstructured) error {
+	err := m.Client.Update(ctx, obj)
+	if err == nil || errors.IsConflict(err) || errors.IsNotFound(err) {
+		return nil
+	}
+	return fmt.Errorf("failed to rewrite %s: %w", client.ObjectKeyFromObject(obj), err)
+}
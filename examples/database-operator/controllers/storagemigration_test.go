@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestStorageVersionMigrator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, apiextensionsv1.AddToScheme(scheme))
+
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "databases.my.domain"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "my.domain",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: "Database", ListKind: "DatabaseList"},
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1", Served: true},
+				{Name: "v1", Served: true, Storage: true},
+			},
+		},
+		Status: apiextensionsv1.CustomResourceDefinitionStatus{StoredVersions: []string{"v1alpha1", "v1"}},
+	}
+	newDatabase := func(name string) *databasev1.Database {
+		return &databasev1.Database{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+		}
+	}
+
+	var rewritten []string
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(crd, newDatabase("orders"), newDatabase("users"), newDatabase("busy")).
+		WithStatusSubresource(crd).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				if obj.GetName() == "busy" {
+					return errors.NewConflict(databasev1.GroupVersion.WithResource("databases").GroupResource(), "busy", nil)
+				}
+				rewritten = append(rewritten, obj.GetName())
+				return c.Update(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	recorder := events.NewFakeRecorder(10)
+	migrator := NewStorageVersionMigrator()
+	migrator.Client = fakeClient
+	migrator.Reader = fakeClient
+	migrator.Recorder = recorder
+	migrator.CRDs = []string{crd.Name}
+	ctx := context.Background()
+
+	// Objects written concurrently are already in the storage version
+	require.NoError(t, migrator.migrateAll(ctx))
+	assert.ElementsMatch(t, []string{"orders", "users"}, rewritten)
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(crd), crd))
+	assert.Equal(t, []string{"v1"}, crd.Status.StoredVersions)
+	assert.Equal(t, 1.0, testutil.ToFloat64(storageMigrationComplete.WithLabelValues(crd.Name)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(storageMigrationPending.WithLabelValues(crd.Name)))
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "StorageVersionMigrated")
+
+	// Nothing is rewritten once the CRD lists only the storage version
+	rewritten = nil
+	require.NoError(t, migrator.migrateAll(ctx))
+	assert.Empty(t, rewritten)
+	assert.Empty(t, recorder.Events)
+
+	// A missing CRD is reported
+	migrator.CRDs = []string{"missing.my.domain"}
+	assert.Error(t, migrator.migrateAll(ctx))
+}
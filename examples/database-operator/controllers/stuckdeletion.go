@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// stuckDeletions reports how many Databases have been terminating for longer than the threshold
+var stuckDeletions = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "database_stuck_deletions",
+	Help: "Number of Databases whose deletion has been pending for longer than the stuck-deletion threshold.",
+})
+
+func init() {
+	metrics.Registry.MustRegister(stuckDeletions)
+}
+
+// StuckDeletionDetector periodically reports Databases that have been terminating for too long.
+// A Database usually gets stuck because a finalizer owned by another controller is never removed,
+// which in turn blocks deletion of its namespace.
+type StuckDeletionDetector struct {
+	client.Client
+	Recorder events.EventRecorder
+
+	// Threshold is how long a Database may be terminating before it is reported; zero disables the detector
+	Threshold time.Duration
+
+	// Interval is how often terminating Databases are checked
+	Interval time.Duration
+
+	// RemovableFinalizers are foreign finalizers the detector removes from stuck Databases.
+	// Only list finalizers whose owning controller has been uninstalled or is known to be safe to skip.
+	// The operator's own finalizer is never removed here.
+	RemovableFinalizers []string
+
+	// Switches stop the finalizer removal in break-glass mode; nil only honours the
+	// break-glass annotation
+	Switches *Switches
+}
+
+// DefaultStuckDeletionDetector holds the default detector settings
+var DefaultStuckDeletionDetector = StuckDeletionDetector{
+	Threshold: 30 * time.Minute,
+	Interval:  5 * time.Minute,
+}
+
+var _ manager.LeaderElectionRunnable = &StuckDeletionDetector{}
+
+// BindFlags registers flags for the detector, using the current values as defaults
+func (d *StuckDeletionDetector) BindFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&d.Threshold, "stuck-deletion-threshold", d.Threshold,
+		"How long a Database may be terminating before a warning is emitted. Zero disables the check.")
+	fs.DurationVar(&d.Interval, "stuck-deletion-interval", d.Interval,
+		"How often terminating Databases are checked.")
+	fs.Func("stuck-deletion-removable-finalizers",
+		"Comma-separated foreign finalizers that may be removed from Databases stuck in deletion.",
+		func(value string) error {
+			d.RemovableFinalizers = nil
+			for _, finalizer := range strings.Split(value, ",") {
+				if finalizer = strings.TrimSpace(finalizer); finalizer != "" {
+					d.RemovableFinalizers = append(d.RemovableFinalizers, finalizer)
+				}
+			}
+			return nil
+		})
+}
+
+// Start runs the detector until the context is cancelled
+func (d *StuckDeletionDetector) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("stuck-deletion-detector")
+
+	interval := d.Interval
+	if interval <= 0 {
+		interval = DefaultStuckDeletionDetector.Interval
+	}
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := d.check(ctx); err != nil {
+			logger.Error(err, "failed to check for stuck deletions")
+		}
+	}, interval)
+	return nil
+}
+
+// NeedLeaderElection makes only the leader emit events and remove finalizers
+func (d *StuckDeletionDetector) NeedLeaderElection() bool {
+	return true
+}
+
+// check reports every Database terminating for longer than the threshold
+func (d *StuckDeletionDetector) check(ctx context.Context) error {
+	var databases databasev1.DatabaseList
+	if err := d.List(ctx, &databases); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	stuck := 0
+	var errs []error
+
+	for i := range databases.Items {
+		database := &databases.Items[i]
+		if database.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		age := now.Sub(database.DeletionTimestamp.Time)
+		if age < d.Threshold {
+			continue
+		}
+
+		stuck++
+		// The note omits the elapsed time so that repeated checks aggregate into one event series
+		d.Recorder.Eventf(database, nil, corev1.EventTypeWarning, "DeletionStuck", "CheckDeletion",
+			"Database has been terminating for more than %s, waiting on finalizers: %s",
+			d.Threshold, strings.Join(database.Finalizers, ", "))
+
+		if d.Switches.InBreakGlass(database) {
+			continue
+		}
+		if err := d.removeForeignFinalizers(ctx, database); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	stuckDeletions.Set(float64(stuck))
+	return kerrors.NewAggregate(errs)
+}
+
+// removeForeignFinalizers removes the configured foreign finalizers from a stuck Database
+func (d *StuckDeletionDetector) removeForeignFinalizers(ctx context.Context, database *databasev1.Database) error {
+	var removed []string
+	patch := client.MergeFromWithOptions(database.DeepCopy(), client.MergeFromWithOptimisticLock{})
+
+	for _, finalizer := range d.RemovableFinalizers {
+		if finalizer == databaseFinalizer {
+			continue
+		}
+		if controllerutil.RemoveFinalizer(database, finalizer) {
+			removed = append(removed, finalizer)
+		}
+	}
+
+	if len(removed) == 0 {
+		return nil
+	}
+
+	if err := d.Patch(ctx, database, patch); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	log.FromContext(ctx).Info("Removed foreign finalizers from stuck Database",
+		"database", client.ObjectKeyFromObject(database), "finalizers", removed)
+	d.Recorder.Eventf(database, nil, corev1.EventTypeWarning, "FinalizerRemoved", "RemoveFinalizer",
+		"Removed finalizers %s to unblock deletion", strings.Join(removed, ", "))
+	return nil
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestStuckDeletionDetector_Check(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+
+	stuckSince := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	recentSince := metav1.NewTime(time.Now().Add(-time.Minute))
+
+	stuck := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "stuck-db",
+			Namespace:         "default",
+			DeletionTimestamp: &stuckSince,
+			Finalizers:        []string{databaseFinalizer, "backup.example.com/protect"},
+		},
+	}
+	recent := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "recent-db",
+			Namespace:         "default",
+			DeletionTimestamp: &recentSince,
+			Finalizers:        []string{"backup.example.com/protect"},
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(stuck, recent).
+		Build()
+
+	recorder := events.NewFakeRecorder(10)
+	detector := &StuckDeletionDetector{
+		Client:              fakeClient,
+		Recorder:            recorder,
+		Threshold:           30 * time.Minute,
+		RemovableFinalizers: []string{"backup.example.com/protect", databaseFinalizer},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, detector.check(ctx))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(stuckDeletions))
+
+	updated := &databasev1.Database{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "stuck-db", Namespace: "default"}, updated))
+	assert.Equal(t, []string{databaseFinalizer}, updated.Finalizers,
+		"Foreign finalizer should be removed, the operator's own finalizer kept")
+
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "recent-db", Namespace: "default"}, updated))
+	assert.Equal(t, []string{"backup.example.com/protect"}, updated.Finalizers,
+		"Databases below the threshold should not be touched")
+
+	require.Len(t, recorder.Events, 2)
+	assert.Contains(t, <-recorder.Events, "DeletionStuck")
+	assert.Contains(t, <-recorder.Events, "FinalizerRemoved")
+}
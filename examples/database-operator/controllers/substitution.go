@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// variablesLabel marks ConfigMaps and Secrets whose keys can be referenced from Database specs
+// in the same namespace as ${KEY}, e.g. image: registry.local/${CLUSTER_ENV}/postgres:15.
+// Resolved values are written into child objects such as the Deployment, so do not keep
+// credentials in these Secrets.
+const variablesLabel = "database.my.domain/variables"
+
+// substitutionIndex indexes Databases by whether their spec references variables
+const substitutionIndex = ".spec.substitution"
+
+// variablePattern matches ${NAME}; $${NAME} is an escaped literal
+var variablePattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// substitutableFields are the only spec fields in which variables are expanded.
+// Names of child objects are deliberately excluded so that a variable change cannot
+// orphan existing children.
+func substitutableFields(database *databasev1.Database) []*string {
+	return []*string{
+		&database.Spec.Image,
+		&database.Spec.DatabaseName,
+		&database.Spec.UserName,
+		&database.Spec.StorageClass,
+	}
+}
+
+// usesSubstitution reports whether any substitutable field references a variable
+func usesSubstitution(database *databasev1.Database) bool {
+	for _, field := range substitutableFields(database) {
+		if strings.Contains(*field, "${") {
+			return true
+		}
+	}
+	return false
+}
+
+// substitute expands ${NAME} references in s. Every referenced variable must be defined;
+// a half-expanded image reference would only fail later in a less obvious way.
+func substitute(s string, vars map[string]string) (string, error) {
+	var missing []string
+	result := variablePattern.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		name := variablePattern.FindStringSubmatch(match)[1]
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		return s, fmt.Errorf("undefined variables: %s", strings.Join(missing, ", "))
+	}
+	return result, nil
+}
+
+// substitutionVariables collects the variables visible to a Database: the built-in
+// downward-API style variables plus the keys of labeled ConfigMaps and Secrets in its namespace.
+// The same key in two sources is an error rather than an order-dependent override.
+func (r *DatabaseReconciler) substitutionVariables(ctx context.Context, database *databasev1.Database) (map[string]string, error) {
+	vars := map[string]string{
+		"DATABASE_NAME":      database.Name,
+		"DATABASE_NAMESPACE": database.Namespace,
+	}
+	sources := map[string]string{}
+	for name := range vars {
+		sources[name] = "built-in"
+	}
+
+	add := func(source string, data map[string]string) error {
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if previous, ok := sources[key]; ok {
+				return fmt.Errorf("variable %s is defined by both %s and %s", key, previous, source)
+			}
+			vars[key] = data[key]
+			sources[key] = source
+		}
+		return nil
+	}
+
+	opts := []client.ListOption{
+		client.InNamespace(database.Namespace),
+		client.MatchingLabels{variablesLabel: "true"},
+	}
+
+	var configMaps corev1.ConfigMapList
+	if err := r.List(ctx, &configMaps, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list variable ConfigMaps: %w", err)
+	}
+	for _, configMap := range configMaps.Items {
+		if err := add("ConfigMap "+configMap.Name, configMap.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	var secrets corev1.SecretList
+	if err := r.List(ctx, &secrets, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list variable Secrets: %w", err)
+	}
+	for _, secret := range secrets.Items {
+		data := make(map[string]string, len(secret.Data))
+		for key, value := range secret.Data {
+			data[key] = string(value)
+		}
+		if err := add("Secret "+secret.Name, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return vars, nil
+}
+
+// substituteSpec expands variables in the in-memory Database. The stored spec keeps the
+// references, so a variable change takes effect on the next reconcile.
+func (r *DatabaseReconciler) substituteSpec(ctx context.Context, database *databasev1.Database) error {
+	if !usesSubstitution(database) {
+		return nil
+	}
+
+	vars, err := r.substitutionVariables(ctx, database)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range substitutableFields(database) {
+		value, err := substitute(*field, vars)
+		if err != nil {
+			return err
+		}
+		*field = value
+	}
+	return nil
+}
+
+// findDatabasesForVariables maps a labeled ConfigMap or Secret to the Databases in its
+// namespace that use substitution, so that changing a variable re-reconciles them
+func (r *DatabaseReconciler) findDatabasesForVariables(ctx context.Context, o client.Object) []reconcile.Request {
+	if o.GetLabels()[variablesLabel] != "true" {
+		return nil
+	}
+
+	// Only the names are read, so the cached Databases are not copied
+	var list databasev1.DatabaseList
+	if err := r.List(ctx, &list, client.InNamespace(o.GetNamespace()),
+		client.MatchingFields{substitutionIndex: "true"}, client.UnsafeDisableDeepCopy); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list Databases")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      list.Items[i].Name,
+				Namespace: list.Items[i].Namespace,
+			},
+		})
+	}
+	return requests
+}
+
+// indexSubstitution is the substitutionIndex function
+func indexSubstitution(obj client.Object) []string {
+	database, ok := obj.(*databasev1.Database)
+	if !ok || !usesSubstitution(database) {
+		return nil
+	}
+	return []string{"true"}
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestSubstitute(t *testing.T) {
+	vars := map[string]string{"CLUSTER_ENV": "prod", "TAG": "15"}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "no variables", input: "postgres:15", want: "postgres:15"},
+		{name: "single", input: "registry.local/${CLUSTER_ENV}/postgres:15", want: "registry.local/prod/postgres:15"},
+		{name: "multiple", input: "${CLUSTER_ENV}/postgres:${TAG}", want: "prod/postgres:15"},
+		{name: "escaped", input: "$${CLUSTER_ENV}", want: "${CLUSTER_ENV}"},
+		{name: "undefined", input: "${MISSING}/postgres", wantErr: true},
+		{name: "not a reference", input: "$CLUSTER_ENV", want: "$CLUSTER_ENV"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := substitute(tt.input, vars)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDatabaseReconciler_SubstitutesVariables(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-db",
+			Namespace:  "default",
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas:     1,
+			Image:        "registry.local/${CLUSTER_ENV}/postgres:15",
+			Storage:      1024,
+			DatabaseName: "${DATABASE_NAME}",
+			StorageClass: "${STORAGE_TIER}",
+		},
+	}
+	variables := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster-vars",
+			Namespace: "default",
+			Labels:    map[string]string{variablesLabel: "true"},
+		},
+		Data: map[string]string{"CLUSTER_ENV": "prod"},
+	}
+	secretVariables := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "storage-vars",
+			Namespace: "default",
+			Labels:    map[string]string{variablesLabel: "true"},
+		},
+		Data: map[string][]byte{"STORAGE_TIER": []byte("fast-ssd")},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database, variables, secretVariables).
+		WithStatusSubresource(database).
+		WithIndex(&databasev1.Database{}, configMapIndex, indexConfigMaps).
+		WithIndex(&databasev1.Database{}, substitutionIndex, indexSubstitution).
+		Build()
+
+	reconciler := &DatabaseReconciler{
+		Client: fakeClient,
+		Scheme: scheme,
+	}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	container := deployment.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "registry.local/prod/postgres:15", container.Image)
+	assert.Contains(t, container.Env, corev1.EnvVar{Name: "POSTGRES_DB", Value: "test-db"})
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	require.NoError(t, fakeClient.Get(ctx, key, pvc))
+	assert.Equal(t, "fast-ssd", *pvc.Spec.StorageClassName)
+
+	// The stored spec keeps the reference so later variable changes apply
+	stored := &databasev1.Database{}
+	require.NoError(t, fakeClient.Get(ctx, key, stored))
+	assert.Equal(t, "registry.local/${CLUSTER_ENV}/postgres:15", stored.Spec.Image)
+
+	// Changing a variable re-reconciles the Databases that use it
+	requests := reconciler.findDatabasesForConfigMap(ctx, variables)
+	assert.Contains(t, requests, ctrl.Request{NamespacedName: key})
+}
+
+func TestDatabaseReconciler_SubstitutionConflict(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec:       databasev1.DatabaseSpec{Image: "${REGISTRY}/postgres:15"},
+	}
+	labels := map[string]string{variablesLabel: "true"}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "default", Labels: labels},
+				Data:       map[string]string{"REGISTRY": "registry.a"},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "default", Labels: labels},
+				Data:       map[string]string{"REGISTRY": "registry.b"},
+			},
+		).
+		Build()
+
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme}
+	err := reconciler.substituteSpec(context.Background(), database)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "REGISTRY")
+	assert.Equal(t, "${REGISTRY}/postgres:15", database.Spec.Image, "A failed substitution must not half-expand the spec")
+}
@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// Names of the controllers and webhooks that can be switched off
+const (
+	DatabaseController              = "database"
+	ClusterDatabasePolicyController = "clusterdatabasepolicy"
+	SkillsQuotaController           = "skillsquota"
+	ApplicationController           = "application"
+	BackupController                = "backup"
+	RestoreController               = "restore"
+
+	DatabaseDefaultingWebhook = "database-defaulting"
+	DatabaseValidationWebhook = "database-validation"
+	PodPolicyWebhook          = "pod-policy"
+)
+
+// Keys read from the switches ConfigMap
+const (
+	switchesControllersKey = "disabledControllers"
+	switchesWebhooksKey    = "disabledWebhooks"
+	switchesBreakGlassKey  = "breakGlass"
+)
+
+// Kinds of switches, the type label of the metric
+const (
+	controllerSwitch = "controller"
+	webhookSwitch    = "webhook"
+)
+
+// switchNames are the known switches by kind
+var switchNames = map[string][]string{
+	controllerSwitch: {DatabaseController, ClusterDatabasePolicyController, SkillsQuotaController, ApplicationController, BackupController, RestoreController},
+	webhookSwitch:    {DatabaseDefaultingWebhook, DatabaseValidationWebhook, PodPolicyWebhook},
+}
+
+var (
+	switchEnabled = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "database_operator_enabled",
+		Help: "1 if a controller or webhook of this operator replica is enabled, 0 if it is switched off by flag or by the switches ConfigMap.",
+	}, []string{"type", "name"})
+	breakGlassActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "database_operator_break_glass",
+		Help: "1 if operator-wide break-glass is on: the operator reports the state of Databases but changes nothing.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(switchEnabled, breakGlassActive)
+}
+
+// Switches turn individual controllers and webhooks off and back on without redeploying the
+// operator, e.g. to stop a controller that fights a manual fix during an incident, or a
+// webhook that rejects writes it should not. Flags switch them off for the life of the
+// process; the ConfigMap, maintained by the cluster admin, at runtime:
+//
+//	apiVersion: v1
+//	kind: ConfigMap
+//	metadata:
+//	  name: database-operator-switches
+//	  namespace: database-operator-system
+//	data:
+//	  disabledControllers: database
+//	  disabledWebhooks: pod-policy, database-validation
+//	  breakGlass: "false"
+//
+// A disabled controller drops its requests without touching the objects and looks at them
+// again every Interval, so they are reconciled soon after it is switched back on. A disabled
+// defaulting webhook leaves objects unchanged and a disabled validating webhook admits them
+// with a warning; the reconciler still rejects invalid Databases in their status. The
+// ConfigMap is read every Interval, and a missing ConfigMap switches nothing off.
+//
+// Break-glass goes further, for on-call engineers who need to edit children by hand during an
+// incident without the operator reverting them: every webhook is skipped, the controllers only
+// report what they observe, and the periodic tasks that change objects pause. It applies to
+// the whole operator, or to one Database and its children with the BreakGlassAnnotation.
+type Switches struct {
+	client.Reader
+
+	// Namespace and Name locate the ConfigMap; an empty Name only applies the flags
+	Namespace string
+	Name      string
+
+	// Interval is how often the ConfigMap is read and disabled controllers retry requests
+	Interval time.Duration
+
+	// DisabledControllers and DisabledWebhooks are switched off regardless of the ConfigMap
+	DisabledControllers []string
+	DisabledWebhooks    []string
+
+	// BreakGlass turns break-glass on regardless of the ConfigMap
+	BreakGlass bool
+
+	mu         sync.RWMutex
+	disabled   map[string]sets.Set[string]
+	breakGlass bool
+}
+
+var _ manager.LeaderElectionRunnable = &Switches{}
+
+// BindFlags registers flags for the switches, using the current values as defaults
+func (s *Switches) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.Namespace, "switches-configmap-namespace", s.Namespace,
+		"Namespace of the ConfigMap that switches controllers and webhooks off at runtime.")
+	fs.StringVar(&s.Name, "switches-configmap-name", s.Name,
+		"Name of the ConfigMap that switches controllers and webhooks off at runtime. Empty only applies --disable-controllers and --disable-webhooks.")
+	fs.DurationVar(&s.Interval, "switches-interval", s.Interval,
+		"How often the switches ConfigMap is read, and how often disabled controllers retry their requests.")
+	fs.Func("disable-controllers", fmt.Sprintf("Comma-separated controllers to switch off: %s.",
+		strings.Join(switchNames[controllerSwitch], ", ")), func(value string) error {
+		s.DisabledControllers = splitList(value)
+		return nil
+	})
+	fs.Func("disable-webhooks", fmt.Sprintf("Comma-separated webhooks to switch off: %s.",
+		strings.Join(switchNames[webhookSwitch], ", ")), func(value string) error {
+		s.DisabledWebhooks = splitList(value)
+		return nil
+	})
+	fs.BoolVar(&s.BreakGlass, "break-glass", s.BreakGlass,
+		"Break-glass mode: skip every webhook and only report the state of Databases, without changing them or their children.")
+}
+
+// Validate rejects a non-positive interval, and unknown names in the flags, which would
+// otherwise switch nothing off
+func (s *Switches) Validate() error {
+	if s.Interval <= 0 {
+		return fmt.Errorf("the switches interval must be positive, got %s", s.Interval)
+	}
+	for kind, names := range map[string][]string{controllerSwitch: s.DisabledControllers, webhookSwitch: s.DisabledWebhooks} {
+		if unknown := sets.List(sets.New(names...).Delete(switchNames[kind]...)); len(unknown) > 0 {
+			return fmt.Errorf("unknown %ss %v; known are %v", kind, unknown, switchNames[kind])
+		}
+	}
+	return nil
+}
+
+// Start reads the ConfigMap every Interval until the context is cancelled
+func (s *Switches) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("switches")
+	ctx = log.IntoContext(ctx, logger)
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.Refresh(ctx); err != nil {
+			logger.Error(err, "failed to read switches; keeping the current ones")
+		}
+	}, s.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes every replica read the switches, since every replica serves webhooks
+func (s *Switches) NeedLeaderElection() bool {
+	return false
+}
+
+// Refresh reads the ConfigMap and updates the switches and their metric
+func (s *Switches) Refresh(ctx context.Context) error {
+	disabled := map[string]sets.Set[string]{
+		controllerSwitch: sets.New(s.DisabledControllers...),
+		webhookSwitch:    sets.New(s.DisabledWebhooks...),
+	}
+
+	breakGlass := s.BreakGlass
+
+	if s.Name != "" {
+		configMap := &corev1.ConfigMap{}
+		err := s.Get(ctx, types.NamespacedName{Namespace: s.Namespace, Name: s.Name}, configMap)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get switches ConfigMap: %w", err)
+		}
+		if value := strings.TrimSpace(configMap.Data[switchesBreakGlassKey]); value != "" {
+			on, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %q in switches ConfigMap %s/%s: %w", switchesBreakGlassKey, s.Namespace, s.Name, err)
+			}
+			breakGlass = breakGlass || on
+		}
+		for kind, key := range map[string]string{controllerSwitch: switchesControllersKey, webhookSwitch: switchesWebhooksKey} {
+			names := splitList(configMap.Data[key])
+			disabled[kind].Insert(names...)
+			if unknown := sets.List(sets.New(names...).Delete(switchNames[kind]...)); len(unknown) > 0 {
+				log.FromContext(ctx).Info("Ignoring unknown names in the switches ConfigMap", "key", key,
+					"unknown", unknown, "known", switchNames[kind])
+			}
+		}
+	}
+
+	s.mu.Lock()
+	previous, previousBreakGlass := s.disabled, s.breakGlass
+	s.disabled, s.breakGlass = disabled, breakGlass
+	s.mu.Unlock()
+
+	logger := log.FromContext(ctx)
+	breakGlassActive.Set(boolValue(breakGlass))
+	if previous != nil && previousBreakGlass != breakGlass {
+		logger.Info("Switched break-glass", "enabled", breakGlass)
+	}
+	for kind, names := range switchNames {
+		for _, name := range names {
+			off := disabled[kind].Has(name)
+			switchEnabled.WithLabelValues(kind, name).Set(boolValue(!off))
+			if previous != nil && previous[kind].Has(name) != off {
+				logger.Info("Switched "+kind, "name", name, "enabled", !off)
+			}
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether a controller or webhook is switched on. Before the first Refresh
+// only the flags apply.
+func (s *Switches) Enabled(kind, name string) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.disabled == nil {
+		names := s.DisabledControllers
+		if kind == webhookSwitch {
+			names = s.DisabledWebhooks
+		}
+		return !sets.New(names...).Has(name)
+	}
+	return !s.disabled[kind].Has(name)
+}
+
+// InBreakGlass reports whether break-glass is on for the whole operator, or for obj through
+// its annotation; obj may be nil
+func (s *Switches) InBreakGlass(obj metav1.Object) bool {
+	if obj != nil && obj.GetAnnotations()[databasev1.BreakGlassAnnotation] == "true" {
+		return true
+	}
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.BreakGlass || s.breakGlass
+}
+
+// retryAfter is how long a controller waits to look at an object left alone again
+func (s *Switches) retryAfter() time.Duration {
+	if s == nil {
+		return 0
+	}
+	return s.Interval
+}
+
+// Controller gates a reconciler; nil Switches return it unchanged. Break-glass is left to the
+// reconciler, which still reports what it observes.
+func (s *Switches) Controller(name string, r reconcile.Reconciler) reconcile.Reconciler {
+	if s == nil {
+		return r
+	}
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		if !s.Enabled(controllerSwitch, name) {
+			log.FromContext(ctx).V(1).Info("Controller is switched off; retrying later", "retryAfter", s.Interval)
+			return reconcile.Result{RequeueAfter: s.Interval}, nil
+		}
+		return r.Reconcile(ctx, req)
+	})
+}
+
+// Defaulter gates a defaulting webhook. With nil Switches only the break-glass annotation
+// applies.
+func (s *Switches) Defaulter(name string, d admission.CustomDefaulter) admission.CustomDefaulter {
+	return &switchedDefaulter{switches: s, name: name, defaulter: d}
+}
+
+// Validator gates a validating webhook. With nil Switches only the break-glass annotation
+// applies.
+func (s *Switches) Validator(name string, v admission.CustomValidator) admission.CustomValidator {
+	return &switchedValidator{switches: s, name: name, validator: v}
+}
+
+// skipWebhook returns why a webhook must not look at obj, empty if it may
+func (s *Switches) skipWebhook(name string, obj runtime.Object) string {
+	if !s.Enabled(webhookSwitch, name) {
+		return "switched off"
+	}
+	// Objects without metadata only follow the operator-wide mode
+	accessor, _ := meta.Accessor(obj)
+	if s.InBreakGlass(accessor) {
+		return "skipped in break-glass mode"
+	}
+	return ""
+}
+
+type switchedDefaulter struct {
+	switches  *Switches
+	name      string
+	defaulter admission.CustomDefaulter
+}
+
+func (d *switchedDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	if d.switches.skipWebhook(d.name, obj) != "" {
+		return nil
+	}
+	return d.defaulter.Default(ctx, obj)
+}
+
+type switchedValidator struct {
+	switches  *Switches
+	name      string
+	validator admission.CustomValidator
+}
+
+func (v *switchedValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	if reason := v.switches.skipWebhook(v.name, obj); reason != "" {
+		return v.skipped(reason), nil
+	}
+	return v.validator.ValidateCreate(ctx, obj)
+}
+
+func (v *switchedValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	if reason := v.switches.skipWebhook(v.name, newObj); reason != "" {
+		return v.skipped(reason), nil
+	}
+	return v.validator.ValidateUpdate(ctx, oldObj, newObj)
+}
+
+func (v *switchedValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	if reason := v.switches.skipWebhook(v.name, obj); reason != "" {
+		return v.skipped(reason), nil
+	}
+	return v.validator.ValidateDelete(ctx, obj)
+}
+
+// skipped tells the client the object was not validated
+func (v *switchedValidator) skipped(reason string) admission.Warnings {
+	return admission.Warnings{fmt.Sprintf("the %s webhook is %s; the object was not validated", v.name, reason)}
+}
+
+// splitList splits a comma-separated list, dropping blanks; the result is sorted
+func splitList(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestSwitches_Flags(t *testing.T) {
+	switches := &Switches{Interval: time.Second}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	switches.BindFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--disable-controllers", "clusterdatabasepolicy", "--disable-webhooks", " pod-policy ,"}))
+	require.NoError(t, switches.Validate())
+
+	// The flags apply before the ConfigMap is first read
+	assert.False(t, switches.Enabled(controllerSwitch, ClusterDatabasePolicyController))
+	assert.True(t, switches.Enabled(controllerSwitch, DatabaseController))
+	assert.False(t, switches.Enabled(webhookSwitch, PodPolicyWebhook))
+
+	require.NoError(t, fs.Parse([]string{"--disable-controllers", "databases"}))
+	assert.Error(t, switches.Validate(), "A misspelled name must not silently switch nothing off")
+
+	assert.True(t, (*Switches)(nil).Enabled(controllerSwitch, DatabaseController), "nil Switches keep everything on")
+}
+
+func TestSwitches_Refresh(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "switches", Namespace: "system"},
+		Data:       map[string]string{switchesControllersKey: "database", switchesWebhooksKey: "database-validation, unknown"},
+	}
+	c := fake.NewClientBuilder().WithObjects(configMap).Build()
+	switches := &Switches{Reader: c, Namespace: "system", Name: "switches", Interval: time.Second,
+		DisabledWebhooks: []string{PodPolicyWebhook}}
+
+	require.NoError(t, switches.Refresh(context.Background()))
+	assert.False(t, switches.Enabled(controllerSwitch, DatabaseController))
+	assert.True(t, switches.Enabled(controllerSwitch, ClusterDatabasePolicyController))
+	assert.False(t, switches.Enabled(webhookSwitch, DatabaseValidationWebhook))
+	assert.False(t, switches.Enabled(webhookSwitch, PodPolicyWebhook), "The flags still apply")
+	assert.Equal(t, 0.0, testutil.ToFloat64(switchEnabled.WithLabelValues(controllerSwitch, DatabaseController)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(switchEnabled.WithLabelValues(webhookSwitch, DatabaseDefaultingWebhook)))
+
+	// Deleting the ConfigMap switches the controller back on
+	require.NoError(t, c.Delete(context.Background(), configMap))
+	require.NoError(t, switches.Refresh(context.Background()))
+	assert.True(t, switches.Enabled(controllerSwitch, DatabaseController))
+	assert.False(t, switches.Enabled(webhookSwitch, PodPolicyWebhook))
+	assert.Equal(t, 1.0, testutil.ToFloat64(switchEnabled.WithLabelValues(controllerSwitch, DatabaseController)))
+}
+
+func TestSwitches_Gates(t *testing.T) {
+	switches := &Switches{Interval: 30 * time.Second,
+		DisabledControllers: []string{DatabaseController},
+		DisabledWebhooks:    []string{DatabaseValidationWebhook, DatabaseDefaultingWebhook}}
+
+	// A disabled controller retries its requests later without reconciling them
+	reconciled := 0
+	r := switches.Controller(DatabaseController, reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+		reconciled++
+		return reconcile.Result{}, nil
+	}))
+	result, err := r.Reconcile(context.Background(), reconcile.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, reconcile.Result{RequeueAfter: 30 * time.Second}, result)
+	assert.Zero(t, reconciled)
+
+	// A disabled validating webhook admits invalid objects with a warning
+	invalid := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024, ConfigMapName: "Settings"},
+	}
+	_, err = (&DatabaseValidator{}).ValidateCreate(context.Background(), invalid)
+	require.Error(t, err)
+	warnings, err := switches.Validator(DatabaseValidationWebhook, &DatabaseValidator{}).ValidateCreate(context.Background(), invalid)
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 1)
+
+	// Enabled webhooks behave as before
+	_, err = switches.Validator(PodPolicyWebhook, &DatabaseValidator{}).ValidateCreate(context.Background(), invalid)
+	assert.Error(t, err)
+
+	// A disabled defaulting webhook leaves the object unchanged
+	defaulter := &DatabaseDefaulter{}
+	database := &databasev1.Database{}
+	require.NoError(t, switches.Defaulter(DatabaseDefaultingWebhook, defaulter).Default(context.Background(), database))
+	assert.Empty(t, database.Annotations, "Not even the schema revision is stamped")
+}
+
+func TestSwitches_BreakGlass(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "switches", Namespace: "system"},
+		Data:       map[string]string{switchesBreakGlassKey: "true"},
+	}
+	c := fake.NewClientBuilder().WithObjects(configMap).Build()
+	switches := &Switches{Reader: c, Namespace: "system", Name: "switches", Interval: time.Second}
+	ctx := context.Background()
+
+	annotated := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{databasev1.BreakGlassAnnotation: "true"},
+	}}
+	assert.False(t, switches.InBreakGlass(nil))
+	assert.True(t, switches.InBreakGlass(annotated), "The annotation applies before the ConfigMap is read")
+	assert.True(t, (*Switches)(nil).InBreakGlass(annotated), "The annotation applies without switches")
+
+	require.NoError(t, switches.Refresh(ctx))
+	assert.True(t, switches.InBreakGlass(nil))
+	assert.Equal(t, 1.0, testutil.ToFloat64(breakGlassActive))
+
+	// Every webhook is skipped, however the object is annotated
+	invalid := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024, ConfigMapName: "Settings"},
+	}
+	warnings, err := switches.Validator(DatabaseValidationWebhook, &DatabaseValidator{}).ValidateCreate(ctx, invalid)
+	assert.NoError(t, err)
+	assert.Equal(t, admission.Warnings{"the database-validation webhook is skipped in break-glass mode; the object was not validated"}, warnings)
+
+	configMap.Data[switchesBreakGlassKey] = "maybe"
+	require.NoError(t, c.Update(ctx, configMap))
+	assert.Error(t, switches.Refresh(ctx))
+	assert.True(t, switches.InBreakGlass(nil), "An invalid value keeps the current mode")
+
+	configMap.Data[switchesBreakGlassKey] = "false"
+	require.NoError(t, c.Update(ctx, configMap))
+	require.NoError(t, switches.Refresh(ctx))
+	assert.False(t, switches.InBreakGlass(nil))
+	assert.Equal(t, 0.0, testutil.ToFloat64(breakGlassActive))
+
+	// Without operator-wide break-glass only annotated objects skip the webhooks
+	_, err = switches.Validator(DatabaseValidationWebhook, &DatabaseValidator{}).ValidateCreate(ctx, invalid)
+	assert.Error(t, err)
+	invalid.Annotations = annotated.Annotations
+	_, err = switches.Validator(DatabaseValidationWebhook, &DatabaseValidator{}).ValidateCreate(ctx, invalid)
+	assert.NoError(t, err)
+	database := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{databasev1.BreakGlassAnnotation: "true"}}}
+	require.NoError(t, switches.Defaulter(DatabaseDefaultingWebhook, &DatabaseDefaulter{}).Default(ctx, database))
+	assert.NotContains(t, database.Annotations, databasev1.SchemaRevisionAnnotation)
+}
@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+// telemetryTimeout bounds one delivery of a batch
+const telemetryTimeout = 30 * time.Second
+
+// TelemetryReport is one anonymous usage report: how many resources of each kind the operator
+// manages and how many Databases use each feature. It carries no names, namespaces, images or
+// other values of the objects counted.
+type TelemetryReport struct {
+	// InstallationID tells reports of one cluster apart from others; it is a hash of the UID
+	// of the kube-system namespace, which cannot be traced back to the cluster
+	InstallationID string `json:"installationID"`
+
+	// Time is when the counts were taken
+	Time time.Time `json:"time"`
+
+	// Resources counts the managed resources by kind
+	Resources map[string]int `json:"resources"`
+
+	// Features counts the Databases using each feature
+	Features map[string]int `json:"features"`
+
+	// Controllers are the controllers that are switched on
+	Controllers []string `json:"controllers,omitempty"`
+}
+
+// TelemetrySink delivers batches of reports
+type TelemetrySink interface {
+	Send(ctx context.Context, reports []TelemetryReport) error
+}
+
+// NoopTelemetrySink drops every report; it is the sink of a reporter without one
+type NoopTelemetrySink struct{}
+
+// Send does nothing
+func (NoopTelemetrySink) Send(context.Context, []TelemetryReport) error {
+	return nil
+}
+
+// HTTPTelemetrySink posts each batch as {"reports": [...]} to an endpoint
+type HTTPTelemetrySink struct {
+	// Endpoint is the URL the batches are posted to
+	Endpoint string
+
+	// Client sends the requests; http.DefaultClient when nil
+	Client *http.Client
+}
+
+// Send posts the batch and fails unless the endpoint accepts it with a 2xx status
+func (s *HTTPTelemetrySink) Send(ctx context.Context, reports []TelemetryReport) error {
+	body, err := json.Marshal(struct {
+		Reports []TelemetryReport `json:"reports"`
+	}{reports})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, telemetryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("POST %s: %s", resp.Request.URL.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+// TelemetryReporter periodically counts the resources the operator manages and the features
+// they use and reports them to the maintainers of the operator. It is off unless switched on
+// with --enable-telemetry, and sends nothing but the counts of TelemetryReport.
+//
+// Reports are taken every Interval, moved by up to Jitter of it so that the operators of many
+// clusters do not report at the same moment, and sent in batches of BatchSize. A batch that
+// fails to send is kept and sent with the next one; beyond MaxPending reports the oldest are
+// dropped, so an unreachable endpoint never grows the memory of the operator.
+type TelemetryReporter struct {
+	client.Client
+
+	// Switches reports which controllers are on; nil reports all of them
+	Switches *Switches
+
+	// Sink delivers the batches; NoopTelemetrySink when nil
+	Sink TelemetrySink
+
+	// Enabled switches the reporter on
+	Enabled bool
+
+	// Endpoint is where batches are posted to when enabled
+	Endpoint string
+
+	// Interval between reports
+	Interval time.Duration
+
+	// Jitter is the fraction of Interval reports are delayed by at most
+	Jitter float64
+
+	// BatchSize is how many reports are sent together
+	BatchSize int
+
+	// MaxPending is how many reports are kept while the endpoint fails
+	MaxPending int
+
+	// pending are the reports not sent yet, oldest first
+	pending []TelemetryReport
+
+	// installationID is computed once from the kube-system namespace
+	installationID string
+}
+
+var _ manager.LeaderElectionRunnable = &TelemetryReporter{}
+
+// NewTelemetryReporter returns a disabled reporter with the default schedule: a report every
+// six hours, sent once a day
+func NewTelemetryReporter() *TelemetryReporter {
+	return &TelemetryReporter{
+		Interval:   6 * time.Hour,
+		Jitter:     0.25,
+		BatchSize:  4,
+		MaxPending: 28,
+	}
+}
+
+// BindFlags registers flags for the reporter, using the current values as defaults
+func (r *TelemetryReporter) BindFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&r.Enabled, "enable-telemetry", r.Enabled,
+		"Report anonymous counts of managed resources and used features to --telemetry-endpoint. Off by default.")
+	fs.StringVar(&r.Endpoint, "telemetry-endpoint", r.Endpoint,
+		"The URL telemetry batches are posted to; required with --enable-telemetry.")
+	fs.DurationVar(&r.Interval, "telemetry-interval", r.Interval,
+		"How often resource and feature counts are taken.")
+	fs.IntVar(&r.BatchSize, "telemetry-batch-size", r.BatchSize,
+		"How many telemetry reports are sent together.")
+}
+
+// Validate checks the flags of an enabled reporter
+func (r *TelemetryReporter) Validate() error {
+	if !r.Enabled {
+		return nil
+	}
+	if r.Endpoint == "" {
+		return fmt.Errorf("--telemetry-endpoint is required with --enable-telemetry")
+	}
+	if r.Interval <= 0 || r.BatchSize <= 0 {
+		return fmt.Errorf("--telemetry-interval and --telemetry-batch-size must be positive")
+	}
+	return nil
+}
+
+// Start reports until the context is cancelled
+func (r *TelemetryReporter) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("telemetry")
+	ctx = log.IntoContext(ctx, logger)
+
+	wait.JitterUntilWithContext(ctx, func(ctx context.Context) {
+		if err := r.report(ctx, time.Now()); err != nil {
+			logger.V(1).Info("failed to send telemetry", "error", err.Error(), "pending", len(r.pending))
+		}
+	}, r.Interval, r.Jitter, true)
+	return nil
+}
+
+// NeedLeaderElection makes only the leader report, so a cluster is counted once
+func (r *TelemetryReporter) NeedLeaderElection() bool {
+	return true
+}
+
+// report takes a report and sends the pending ones once a batch is full
+func (r *TelemetryReporter) report(ctx context.Context, now time.Time) error {
+	report, err := r.collect(ctx, now)
+	if err != nil {
+		return err
+	}
+	r.pending = append(r.pending, report)
+	if r.MaxPending > 0 && len(r.pending) > r.MaxPending {
+		r.pending = r.pending[len(r.pending)-r.MaxPending:]
+	}
+	if len(r.pending) < r.BatchSize {
+		return nil
+	}
+
+	sink := r.Sink
+	if sink == nil {
+		sink = NoopTelemetrySink{}
+	}
+	if err := sink.Send(ctx, r.pending); err != nil {
+		return err
+	}
+	r.pending = nil
+	return nil
+}
+
+// collect counts the managed resources and the features of the Databases
+func (r *TelemetryReporter) collect(ctx context.Context, now time.Time) (TelemetryReport, error) {
+	id, err := r.installation(ctx)
+	if err != nil {
+		return TelemetryReport{}, err
+	}
+	report := TelemetryReport{
+		InstallationID: id,
+		Time:           now.UTC(),
+		Resources:      map[string]int{},
+		Features:       map[string]int{},
+	}
+
+	var databases databasev1.DatabaseList
+	if err := r.List(ctx, &databases); err != nil {
+		return TelemetryReport{}, err
+	}
+	report.Resources["Database"] = len(databases.Items)
+	for i := range databases.Items {
+		for _, feature := range databaseFeatures(&databases.Items[i]) {
+			report.Features[feature]++
+		}
+	}
+
+	for kind, list := range map[string]client.ObjectList{
+		"Application":           &databasev1.ApplicationList{},
+		"Backup":                &databasev1.BackupList{},
+		"Restore":               &databasev1.RestoreList{},
+		"DatabaseClass":         &databasev1.DatabaseClassList{},
+		"ClusterDatabasePolicy": &databasev1.ClusterDatabasePolicyList{},
+		"SkillsQuota":           &databasev1.SkillsQuotaList{},
+	} {
+		if err := r.List(ctx, list); err != nil {
+			return TelemetryReport{}, err
+		}
+		report.Resources[kind] = meta.LenList(list)
+	}
+
+	for _, name := range switchNames[controllerSwitch] {
+		if r.Switches.Enabled(controllerSwitch, name) {
+			report.Controllers = append(report.Controllers, name)
+		}
+	}
+	sort.Strings(report.Controllers)
+	return report, nil
+}
+
+// installation returns the anonymous ID of the cluster
+func (r *TelemetryReporter) installation(ctx context.Context) (string, error) {
+	if r.installationID != "" {
+		return r.installationID, nil
+	}
+	var namespace corev1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: metav1.NamespaceSystem}, &namespace); err != nil {
+		return "", fmt.Errorf("failed to get the kube-system namespace: %w", err)
+	}
+	sum := sha256.Sum256([]byte("database-operator/" + string(namespace.UID)))
+	r.installationID = hex.EncodeToString(sum[:16])
+	return r.installationID, nil
+}
+
+// databaseFeatures names the optional features a Database uses
+func databaseFeatures(database *databasev1.Database) []string {
+	spec := database.Spec
+	var features []string
+	add := func(name string, used bool) {
+		if used {
+			features = append(features, name)
+		}
+	}
+	add("replicas", spec.Replicas > 1)
+	add("class", spec.ClassName != "")
+	add("statefulSet", spec.Workload == databasev1.WorkloadStatefulSet)
+	add("standby", spec.Standby != nil)
+	add("maintenance", spec.Maintenance != nil)
+	add("monitoring", spec.Monitoring != nil && spec.Monitoring.Enabled)
+	add("zoneSpread", spec.ZoneSpread != nil)
+	add("verticalScaling", spec.VerticalScaling != nil)
+	add("imageUpdate", spec.ImageUpdatePolicy != nil)
+	add("requeuePolicy", spec.RequeuePolicy != nil)
+	return features
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// recordingSink records the batches it is sent, failing while err is set
+type recordingSink struct {
+	batches [][]TelemetryReport
+	err     error
+}
+
+func (s *recordingSink) Send(_ context.Context, reports []TelemetryReport) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.batches = append(s.batches, append([]TelemetryReport(nil), reports...))
+	return nil
+}
+
+func newTelemetryReporter(t *testing.T, sink TelemetrySink) *TelemetryReporter {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	replicated := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop"},
+		Spec: databasev1.DatabaseSpec{
+			Replicas:   3,
+			ClassName:  "standard",
+			Monitoring: &databasev1.MonitoringSpec{Enabled: true},
+		},
+	}
+	single := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "sessions", Namespace: "shop"},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Monitoring: &databasev1.MonitoringSpec{}},
+	}
+	class := &databasev1.DatabaseClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}}
+	kubeSystem := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: metav1.NamespaceSystem, UID: "5a1f0c2e-cluster"}}
+
+	reporter := NewTelemetryReporter()
+	reporter.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(replicated, single, class, kubeSystem).Build()
+	reporter.Switches = &Switches{DisabledControllers: []string{ApplicationController}}
+	reporter.Sink = sink
+	reporter.BatchSize = 2
+	reporter.MaxPending = 3
+	return reporter
+}
+
+func TestTelemetryReporter_Collect(t *testing.T) {
+	reporter := newTelemetryReporter(t, nil)
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	report, err := reporter.collect(context.Background(), now)
+	require.NoError(t, err)
+	assert.Len(t, report.InstallationID, 32)
+	assert.NotContains(t, report.InstallationID, "5a1f0c2e", "The namespace UID is hashed")
+	assert.Equal(t, now, report.Time)
+	assert.Equal(t, map[string]int{
+		"Database": 2, "Application": 0, "Backup": 0, "Restore": 0, "DatabaseClass": 1, "ClusterDatabasePolicy": 0, "SkillsQuota": 0,
+	}, report.Resources)
+	assert.Equal(t, map[string]int{"replicas": 1, "class": 1, "monitoring": 1}, report.Features,
+		"Only enabled monitoring counts")
+	assert.Equal(t, []string{BackupController, ClusterDatabasePolicyController, DatabaseController, RestoreController, SkillsQuotaController}, report.Controllers)
+
+	// Nothing names the counted objects
+	content, err := json.Marshal(report)
+	require.NoError(t, err)
+	for _, name := range []string{"orders", "sessions", "shop", "standard"} {
+		assert.NotContains(t, string(content), name)
+	}
+}
+
+func TestTelemetryReporter_Batches(t *testing.T) {
+	sink := &recordingSink{}
+	reporter := newTelemetryReporter(t, sink)
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, reporter.report(ctx, now))
+	assert.Empty(t, sink.batches, "Reports wait for a full batch")
+	require.NoError(t, reporter.report(ctx, now.Add(time.Hour)))
+	require.Len(t, sink.batches, 1)
+	assert.Len(t, sink.batches[0], 2)
+
+	// A failing endpoint keeps the reports, dropping the oldest beyond MaxPending
+	sink.err = errors.New("connection refused")
+	require.NoError(t, reporter.report(ctx, now.Add(2*time.Hour)))
+	for i := 3; i < 6; i++ {
+		assert.Error(t, reporter.report(ctx, now.Add(time.Duration(i)*time.Hour)))
+	}
+	assert.Len(t, reporter.pending, 3)
+	assert.Equal(t, now.Add(3*time.Hour).UTC(), reporter.pending[0].Time)
+
+	sink.err = nil
+	require.NoError(t, reporter.report(ctx, now.Add(6*time.Hour)))
+	require.Len(t, sink.batches, 2)
+	assert.Len(t, sink.batches[1], 3)
+	assert.Empty(t, reporter.pending)
+}
+
+func TestTelemetryReporter_Defaults(t *testing.T) {
+	reporter := NewTelemetryReporter()
+	assert.False(t, reporter.Enabled, "Telemetry is opt-in")
+	assert.NoError(t, reporter.Validate())
+
+	reporter.Enabled = true
+	assert.Error(t, reporter.Validate(), "An endpoint is required")
+	reporter.Endpoint = "https://telemetry.example.com/v1/reports"
+	assert.NoError(t, reporter.Validate())
+
+	// Without a sink, full batches are dropped
+	reporter = newTelemetryReporter(t, nil)
+	require.NoError(t, reporter.report(context.Background(), time.Now()))
+	require.NoError(t, reporter.report(context.Background(), time.Now()))
+	assert.Empty(t, reporter.pending)
+}
+
+func TestHTTPTelemetrySink(t *testing.T) {
+	var received struct {
+		Reports []TelemetryReport `json:"reports"`
+	}
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := &HTTPTelemetrySink{Endpoint: server.URL, Client: server.Client()}
+	reports := []TelemetryReport{{InstallationID: "abc", Resources: map[string]int{"Database": 2}}}
+	require.NoError(t, sink.Send(context.Background(), reports))
+	require.Len(t, received.Reports, 1)
+	assert.Equal(t, 2, received.Reports[0].Resources["Database"])
+
+	status = http.StatusServiceUnavailable
+	assert.ErrorContains(t, sink.Send(context.Background(), reports), "503")
+}
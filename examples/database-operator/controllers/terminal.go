@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// conditionStalled is True when the Database failed in a way retrying cannot fix.
+// Its ObservedGeneration records the spec generation that failed; the Database is
+// not reconciled again until the spec changes.
+const conditionStalled = "Stalled"
+
+// terminalStallReasons are rollout stall reasons that only a spec change can fix.
+// CrashLoopBackOff or ImagePullBackOff may resolve on their own (a registry outage,
+// a missing Secret created later), a malformed image reference never does.
+var terminalStallReasons = map[string]bool{
+	"InvalidImageName": true,
+}
+
+// isTerminalError reports whether the API server rejected a child in a way only a spec
+// change can fix, e.g. changing an immutable PVC field. Aggregated errors from parallel
+// child reconciliation are terminal if any of them is.
+func isTerminalError(err error) bool {
+	if aggregate, ok := err.(kerrors.Aggregate); ok {
+		for _, e := range aggregate.Errors() {
+			if isTerminalError(e) {
+				return true
+			}
+		}
+		return false
+	}
+	return errors.IsInvalid(err) || errors.IsBadRequest(err)
+}
+
+// isStalled reports whether the Database failed permanently at its current generation
+func isStalled(database *databasev1.Database) bool {
+	condition := database.GetCondition(conditionStalled)
+	return condition != nil &&
+		condition.Status == metav1.ConditionTrue &&
+		condition.ObservedGeneration == database.Generation
+}
+
+// setStalled records a permanent failure for the current generation
+func setStalled(database *databasev1.Database, reason, message string) {
+	database.Status.Phase = "Failed"
+	database.SetCondition("Ready", metav1.ConditionFalse, reason, message)
+	database.SetCondition(conditionStalled, metav1.ConditionTrue, reason, message)
+
+	// SetCondition does not track generations; the Stalled condition must
+	for i := range database.Status.Conditions {
+		if database.Status.Conditions[i].Type == conditionStalled {
+			database.Status.Conditions[i].ObservedGeneration = database.Generation
+		}
+	}
+}
+
+// markStalled stores a permanent failure and stops requeueing.
+// The next reconcile happens when the spec changes.
+func (r *DatabaseReconciler) markStalled(ctx context.Context, database *databasev1.Database, reason string, err error) (ctrl.Result, error) {
+	setStalled(database, reason, err.Error())
+	r.warn(database, nil, reason, "Reconcile", err.Error())
+	r.recordHistory(ctx, database, err)
+	if updateErr := r.Status().Update(ctx, database); updateErr != nil {
+		return ctrl.Result{}, updateErr
+	}
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestDatabaseReconciler_StallsOnTerminalError(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-db",
+			Namespace:  "default",
+			Generation: 3,
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+		},
+	}
+
+	// The API server rejects the PVC, e.g. because an immutable field changed
+	pvcCreates := 0
+	rejectPVC := interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*corev1.PersistentVolumeClaim); ok {
+				pvcCreates++
+				return errors.NewInvalid(schema.GroupKind{Kind: "PersistentVolumeClaim"}, obj.GetName(),
+					field.ErrorList{field.Forbidden(field.NewPath("spec"), "spec is immutable after creation")})
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database).
+		WithInterceptorFuncs(rejectPVC).
+		Build()
+
+	reconciler := &DatabaseReconciler{
+		Client: fakeClient,
+		Scheme: scheme,
+	}
+
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-db", Namespace: "default"}}
+
+	result, err := reconciler.Reconcile(ctx, req)
+	require.NoError(t, err, "Terminal errors should not be returned, so they are not retried")
+	assert.Equal(t, ctrl.Result{}, result)
+
+	updated := &databasev1.Database{}
+	require.NoError(t, fakeClient.Get(ctx, req.NamespacedName, updated))
+	stalled := updated.GetCondition(conditionStalled)
+	require.NotNil(t, stalled)
+	assert.Equal(t, metav1.ConditionTrue, stalled.Status)
+	assert.Equal(t, "PVCCreateFailed", stalled.Reason)
+	assert.Equal(t, int64(3), stalled.ObservedGeneration)
+	assert.Equal(t, "Failed", updated.Status.Phase)
+
+	// Further events for the same generation are ignored
+	result, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, ctrl.Result{}, result)
+	assert.Equal(t, 1, pvcCreates, "A stalled Database should not be retried until its spec changes")
+}
+
+func TestIsStalled(t *testing.T) {
+	database := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Generation: 2}}
+	assert.False(t, isStalled(database))
+
+	setStalled(database, "InvalidImageName", "bad image")
+	assert.True(t, isStalled(database))
+
+	database.Generation = 3
+	assert.False(t, isStalled(database), "A spec change should lift the stall")
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// lastAppliedAnnotation is written by kubectl apply and holds a full copy of the object
+const lastAppliedAnnotation = corev1.LastAppliedConfigAnnotation
+
+// CacheOptions returns cache options that drop fields the operator never reads.
+// Transforms run before objects are stored, so the savings apply to every informer.
+//
+// Objects the operator writes with Update (the Database itself) only lose managedFields:
+// the API server keeps managedFields when an update omits them, but an Update without the
+// last-applied annotation would delete it and break the user's next kubectl apply.
+func CacheOptions() cache.Options {
+	return cache.Options{
+		DefaultTransform: stripManagedFields,
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.Pod{}:       {Transform: trimPod},
+			&corev1.ConfigMap{}: {Transform: stripMetadata},
+			&corev1.Secret{}:    {Transform: stripMetadata},
+			&corev1.Service{}:   {Transform: stripMetadata},
+		},
+	}
+}
+
+var (
+	_ toolscache.TransformFunc = stripManagedFields
+	_ toolscache.TransformFunc = stripMetadata
+	_ toolscache.TransformFunc = trimPod
+)
+
+// stripManagedFields drops server-side apply bookkeeping, often the largest part of small objects
+func stripManagedFields(obj interface{}) (interface{}, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+	return obj, nil
+}
+
+// stripMetadata drops managedFields and the last-applied annotation.
+// Only use it for types the operator reads or patches, never for types it Updates.
+func stripMetadata(obj interface{}) (interface{}, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return obj, nil
+	}
+
+	accessor.SetManagedFields(nil)
+	if annotations := accessor.GetAnnotations(); annotations[lastAppliedAnnotation] != "" {
+		delete(annotations, lastAppliedAnnotation)
+		accessor.SetAnnotations(annotations)
+	}
+	return obj, nil
+}
+
+// trimPod keeps only the pod fields read by podStatuses, isPodReady, findStalledPod and podRequests.
+// Pods are read-only for this operator, so a trimmed copy is never written back.
+func trimPod(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return stripMetadata(obj)
+	}
+
+	if _, err := stripMetadata(pod); err != nil {
+		return nil, err
+	}
+
+	pod.Spec = corev1.PodSpec{
+		NodeName:       pod.Spec.NodeName,
+		Overhead:       pod.Spec.Overhead,
+		InitContainers: trimContainers(pod.Spec.InitContainers),
+		Containers:     trimContainers(pod.Spec.Containers),
+	}
+	pod.Status = corev1.PodStatus{
+		Phase:                 pod.Status.Phase,
+		Conditions:            pod.Status.Conditions,
+		InitContainerStatuses: trimContainerStatuses(pod.Status.InitContainerStatuses),
+		ContainerStatuses:     trimContainerStatuses(pod.Status.ContainerStatuses),
+	}
+	return pod, nil
+}
+
+// trimContainers keeps the names and requests of containers
+func trimContainers(containers []corev1.Container) []corev1.Container {
+	if containers == nil {
+		return nil
+	}
+
+	trimmed := make([]corev1.Container, len(containers))
+	for i, container := range containers {
+		trimmed[i] = corev1.Container{
+			Name:      container.Name,
+			Resources: corev1.ResourceRequirements{Requests: container.Resources.Requests},
+		}
+	}
+	return trimmed
+}
+
+// trimContainerStatuses keeps readiness, restarts and the waiting/termination details used for stall detection
+func trimContainerStatuses(statuses []corev1.ContainerStatus) []corev1.ContainerStatus {
+	if statuses == nil {
+		return nil
+	}
+
+	trimmed := make([]corev1.ContainerStatus, len(statuses))
+	for i, status := range statuses {
+		trimmed[i] = corev1.ContainerStatus{
+			Name:                 status.Name,
+			Ready:                status.Ready,
+			RestartCount:         status.RestartCount,
+			State:                status.State,
+			LastTerminationState: status.LastTerminationState,
+		}
+	}
+	return trimmed
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+func TestTrimPod(t *testing.T) {
+	pod := syntheticPod(0)
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{
+		Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+	}
+	pod.Status.ContainerStatuses[0].LastTerminationState = corev1.ContainerState{
+		Terminated: &corev1.ContainerStateTerminated{Message: "FATAL: invalid configuration"},
+	}
+
+	before := podStatuses([]corev1.Pod{*pod.DeepCopy()})
+	beforeStall := findStalledPod([]corev1.Pod{*pod.DeepCopy()})
+	beforeRequests := podRequests(pod.DeepCopy())
+
+	obj, err := trimPod(pod)
+	require.NoError(t, err)
+	trimmed := obj.(*corev1.Pod)
+
+	assert.Empty(t, trimmed.ManagedFields)
+	assert.NotContains(t, trimmed.Annotations, lastAppliedAnnotation)
+	assert.Empty(t, trimmed.Spec.Containers[0].Env)
+	assert.Empty(t, trimmed.Spec.Volumes)
+	assert.Empty(t, trimmed.Status.ContainerStatuses[0].ImageID)
+
+	assert.Equal(t, before, podStatuses([]corev1.Pod{*trimmed}), "Pod status reporting should be unaffected")
+	assert.Equal(t, beforeStall, findStalledPod([]corev1.Pod{*trimmed}), "Stall detection should be unaffected")
+	assert.Equal(t, beforeRequests, podRequests(trimmed), "Resource reporting should be unaffected")
+}
+
+func TestStripMetadata_KeepsOtherAnnotations(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				lastAppliedAnnotation: "{}",
+				"team":                "payments",
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		},
+	}
+
+	_, err := stripMetadata(secret)
+	require.NoError(t, err)
+
+	assert.Empty(t, secret.ManagedFields)
+	assert.Equal(t, map[string]string{"team": "payments"}, secret.Annotations)
+}
+
+// BenchmarkCacheTransforms compares the heap held by a cache of 5k pods with and without trimPod.
+// Run with: go test -run '^$' -bench CacheTransforms ./controllers/
+func BenchmarkCacheTransforms(b *testing.B) {
+	const objects = 5000
+
+	for _, bc := range []struct {
+		name      string
+		transform toolscache.TransformFunc
+	}{
+		{name: "without", transform: nil},
+		{name: "with", transform: trimPod},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				before := heapInUse()
+
+				store := toolscache.NewStore(toolscache.MetaNamespaceKeyFunc)
+				for n := 0; n < objects; n++ {
+					var obj interface{} = syntheticPod(n)
+					if bc.transform != nil {
+						obj, _ = bc.transform(obj)
+					}
+					require.NoError(b, store.Add(obj))
+				}
+
+				b.ReportMetric(float64(heapInUse()-before)/(1<<20), "MiB/5k-pods")
+				runtime.KeepAlive(store)
+			}
+		})
+	}
+}
+
+// heapInUse returns live heap bytes after a full collection
+func heapInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+// syntheticPod returns a pod shaped like a typical database pod, including the bookkeeping
+// that real clusters attach: managedFields, kubectl's last-applied copy and full container statuses
+func syntheticPod(n int) *corev1.Pod {
+	name := fmt.Sprintf("db-%d", n)
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{"app": name, databaseNameLabel: name},
+			Annotations: map[string]string{
+				lastAppliedAnnotation: strings.Repeat("x", 2048),
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kube-controller-manager", Operation: metav1.ManagedFieldsOperationUpdate},
+				{Manager: "kubelet", Operation: metav1.ManagedFieldsOperationUpdate, Subresource: "status"},
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Containers: []corev1.Container{{
+				Name:  "database",
+				Image: "postgres:15",
+				Env: []corev1.EnvVar{
+					{Name: "POSTGRES_DB", Value: "appdb"},
+					{Name: "POSTGRES_USER", Value: "appuser"},
+					{Name: "PGDATA", Value: "/var/lib/postgresql/data/pgdata"},
+				},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+				},
+				VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/var/lib/postgresql/data"}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name: "data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: name},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+			},
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:         "database",
+				Ready:        true,
+				RestartCount: 1,
+				Image:        "docker.io/library/postgres:15",
+				ImageID:      "docker.io/library/postgres@sha256:" + strings.Repeat("a", 64),
+				ContainerID:  "containerd://" + strings.Repeat("b", 64),
+			}},
+		},
+	}
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/validation"
+)
+
+// reasonValidationFailed is the Stalled reason of a Database that fails validation
+const reasonValidationFailed = "ValidationFailed"
+
+//+kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=get
+//+kubebuilder:rbac:groups=my.domain,resources=skillsquotas,verbs=list
+//+kubebuilder:rbac:groups=my.domain,resources=databaseclasses,verbs=get
+
+//+kubebuilder:webhook:path=/validate-my-domain-v1-database,mutating=false,failurePolicy=ignore,timeoutSeconds=5,sideEffects=None,groups=my.domain,resources=databases,verbs=create;update,versions=v1,name=vdatabase.kb.io,admissionReviewVersions=v1
+
+// DatabaseValidator is the validating webhook for Databases. The reconciler applies the same
+// rules, so it only moves the failure from the Database status to the client.
+type DatabaseValidator struct {
+	// Reader looks up referenced cluster objects and SkillsQuotas; nil skips the lookups.
+	// Only the webhook checks them, since a class deleted later, or a quota lowered later,
+	// must not break Databases that exist.
+	Reader client.Reader
+
+	// Switches can turn the webhook off at runtime; nil keeps it on
+	Switches *Switches
+}
+
+var _ admission.CustomValidator = &DatabaseValidator{}
+
+// ValidateCreate checks a new Database, warns about deprecated fields it sets and rejects it
+// when its namespace has no room left in a SkillsQuota
+func (v *DatabaseValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	database, errs, err := v.validate(obj)
+	if err != nil {
+		return nil, err
+	}
+	classErrs, err := v.validatePriorityClass(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+	databaseClassErrs, err := v.validateClass(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+	errs = append(errs, databaseClassErrs...)
+	warnings, deprecationErrs := checkDeprecatedFields(database)
+	errs = append(errs, deprecationErrs...)
+	if err := invalidDatabase(database, append(errs, classErrs...)); err != nil {
+		return warnings, err
+	}
+	return warnings, v.validateQuotas(ctx, nil, database)
+}
+
+// ValidateUpdate checks an updated Database, keeps automated image updates within policy
+// and the standby lifecycle one-way, and growth within SkillsQuotas. The priority class and
+// the DatabaseClass are only looked up when they change.
+func (v *DatabaseValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	database, errs, err := v.validate(newObj)
+	if err != nil {
+		return nil, err
+	}
+	old, ok := oldObj.(*databasev1.Database)
+	if !ok {
+		return nil, fmt.Errorf("expected a Database but got %T", oldObj)
+	}
+	errs = append(errs, validation.ValidateImageUpdate(old, database)...)
+	errs = append(errs, validation.ValidateStandbyUpdate(old, database)...)
+	errs = append(errs, validation.ValidateWorkloadUpdate(old, database)...)
+	errs = append(errs, validation.ValidateHighAvailabilityUpdate(old, database)...)
+	if database.Spec.PriorityClassName != old.Spec.PriorityClassName {
+		classErrs, err := v.validatePriorityClass(ctx, database)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, classErrs...)
+	}
+	if database.Spec.ClassName != old.Spec.ClassName || database.Spec.Image != old.Spec.Image {
+		classErrs, err := v.validateClass(ctx, database)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, classErrs...)
+	}
+	warnings, deprecationErrs := checkDeprecatedFields(database)
+	if err := invalidDatabase(database, append(errs, deprecationErrs...)); err != nil {
+		return warnings, err
+	}
+	return warnings, v.validateQuotas(ctx, old, database)
+}
+
+// ValidateDelete allows every deletion
+func (v *DatabaseValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate returns the rules a Database violates on its own, without lookups
+func (v *DatabaseValidator) validate(obj runtime.Object) (*databasev1.Database, field.ErrorList, error) {
+	database, ok := obj.(*databasev1.Database)
+	if !ok {
+		return nil, nil, fmt.Errorf("expected a Database but got %T", obj)
+	}
+	errs := validation.ValidateDatabase(database)
+	return database, append(errs, validateSize(database)...), nil
+}
+
+// invalidDatabase turns every violated rule into one Invalid error. Its status lists each
+// rule as a cause with the path of its field, e.g. spec.image, which kubectl prints one per
+// line. It is nil when no rule is violated.
+func invalidDatabase(database *databasev1.Database, errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(databasev1.GroupVersion.WithKind("Database").GroupKind(), database.Name, errs)
+}
+
+// validatePriorityClass rejects a priorityClassName that names no PriorityClass. Without it
+// the Database is accepted, but the API server rejects every pod created for it.
+func (v *DatabaseValidator) validatePriorityClass(ctx context.Context, database *databasev1.Database) (field.ErrorList, error) {
+	name := database.Spec.PriorityClassName
+	if v.Reader == nil || name == "" {
+		return nil, nil
+	}
+	err := v.Reader.Get(ctx, client.ObjectKey{Name: name}, &schedulingv1.PriorityClass{})
+	if apierrors.IsNotFound(err) {
+		return field.ErrorList{field.NotFound(field.NewPath("spec", "priorityClassName"), name)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PriorityClass %s: %w", name, err)
+	}
+	return nil, nil
+}
+
+// SetupWebhookWithManager registers the validating webhook with the Manager
+func (v *DatabaseValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&databasev1.Database{}).
+		WithValidator(v.Switches.Validator(DatabaseValidationWebhook, guardValidator(DatabaseValidationWebhook, v))).
+		Complete()
+}
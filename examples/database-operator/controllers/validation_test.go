@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestDatabaseValidator(t *testing.T) {
+	validator := &DatabaseValidator{}
+	ctx := context.Background()
+
+	valid := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+	}
+	_, err := validator.ValidateCreate(ctx, valid)
+	assert.NoError(t, err)
+
+	invalid := valid.DeepCopy()
+	invalid.Spec.ConfigMapName = "Settings"
+	_, err = validator.ValidateUpdate(ctx, valid, invalid)
+	require.Error(t, err)
+	assert.True(t, apierrors.IsInvalid(err))
+
+	// Automated image updates are only admitted within the update policy
+	automated := valid.DeepCopy()
+	automated.Spec.Image = "postgres:16"
+	automated.Annotations = map[string]string{databasev1.AutomatedImageAnnotation: "postgres:16"}
+	automated.Spec.ImageUpdatePolicy = &databasev1.ImageUpdatePolicy{Range: ">=15 <16", Mode: databasev1.ImageUpdateApply}
+	_, err = validator.ValidateUpdate(ctx, valid, automated)
+	require.Error(t, err)
+	assert.True(t, apierrors.IsInvalid(err))
+
+	_, err = validator.ValidateDelete(ctx, invalid)
+	assert.NoError(t, err, "Invalid Databases can always be deleted")
+}
+
+func TestDatabaseValidator_FieldCauses(t *testing.T) {
+	validator := &DatabaseValidator{}
+	ctx := context.Background()
+	old := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+		Status:     databasev1.DatabaseStatus{Workload: databasev1.WorkloadStatefulSet},
+	}
+	database := old.DeepCopy()
+	database.Spec.Image = "postgres:15 "
+	database.Spec.UserName = "app\x00"
+
+	// The rules of the object and of the update are reported together, one cause per field
+	_, err := validator.ValidateUpdate(ctx, old, database)
+	var status apierrors.APIStatus
+	require.ErrorAs(t, err, &status)
+	details := status.Status().Details
+	require.NotNil(t, details)
+	assert.Equal(t, "Database", details.Kind)
+	assert.Equal(t, "test-db", details.Name)
+	var causes []string
+	for _, cause := range details.Causes {
+		causes = append(causes, cause.Field+" "+string(cause.Type))
+	}
+	assert.Equal(t, []string{
+		"spec.image FieldValueInvalid",
+		"spec.userName FieldValueInvalid",
+		"spec.workload FieldValueForbidden",
+	}, causes)
+}
+
+func TestDatabaseValidator_PriorityClass(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, schedulingv1.AddToScheme(scheme))
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	critical := &schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "database-critical"}, Value: 1000000}
+	validator := &DatabaseValidator{Reader: fake.NewClientBuilder().WithScheme(scheme).WithObjects(critical).Build()}
+	ctx := context.Background()
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec: databasev1.DatabaseSpec{
+			Replicas:          1,
+			Image:             "postgres:15",
+			Storage:           1024,
+			PriorityClassName: "database-critical",
+		},
+	}
+	_, err := validator.ValidateCreate(ctx, database)
+	assert.NoError(t, err)
+
+	missing := database.DeepCopy()
+	missing.Spec.PriorityClassName = "database-citical"
+	_, err = validator.ValidateCreate(ctx, missing)
+	require.Error(t, err)
+	assert.True(t, apierrors.IsInvalid(err))
+	assert.Contains(t, err.Error(), "spec.priorityClassName")
+	_, err = validator.ValidateUpdate(ctx, database, missing)
+	assert.True(t, apierrors.IsInvalid(err))
+
+	// A class deleted after the fact does not block unrelated updates
+	resized := missing.DeepCopy()
+	resized.Spec.Storage = 2048
+	_, err = validator.ValidateUpdate(ctx, missing, resized)
+	assert.NoError(t, err)
+}
+
+func TestDatabaseReconciler_StallsInvalidDatabase(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	// Created while the webhook was disabled
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-db",
+			Namespace:  "default",
+			Generation: 1,
+			Finalizers: []string{databaseFinalizer},
+		},
+		Spec: databasev1.DatabaseSpec{
+			Replicas: 1,
+			Image:    "postgres:15",
+			Storage:  1024,
+			UserName: "admin\tuser",
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database).
+		WithStatusSubresource(database).
+		Build()
+
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-db", Namespace: "default"}
+	result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err, "Retrying cannot fix an invalid spec")
+	assert.Equal(t, ctrl.Result{}, result)
+
+	require.NoError(t, fakeClient.Get(ctx, key, database))
+	assert.True(t, isStalled(database))
+	stalled := database.GetCondition(conditionStalled)
+	assert.Equal(t, reasonValidationFailed, stalled.Reason)
+	assert.Contains(t, stalled.Message, "spec.userName")
+
+	err = fakeClient.Get(ctx, key, &appsv1.Deployment{})
+	assert.True(t, apierrors.IsNotFound(err), "No child is rendered from an invalid spec")
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/health"
+)
+
+// warmSpareDiagnosticsPath serves the cached Databases on the metrics server
+const warmSpareDiagnosticsPath = "/debug/databases"
+
+var (
+	desiredReplicasDesc = prometheus.NewDesc("database_desired_replicas",
+		"Replicas requested in the Database spec, as seen by the cache of this operator replica.",
+		[]string{"namespace", "database"}, nil)
+	pendingGenerationDesc = prometheus.NewDesc("database_generation_pending",
+		"1 if the Database spec has changed since it was last reconciled, as seen by the cache of this operator replica.",
+		[]string{"namespace", "database"}, nil)
+	leaderDesc = prometheus.NewDesc("database_operator_leader",
+		"1 if this operator replica holds the leader lease and runs the controllers.",
+		nil, nil)
+	cacheSyncedDesc = prometheus.NewDesc("database_operator_cache_synced",
+		"1 once the informers of every watched kind have synced on this operator replica.",
+		nil, nil)
+)
+
+// WatchedObjects returns the kinds the controllers read through the cache, in the form they
+// read them: a typed object and a metadata-only object of the same kind are separate informers.
+// Optional kinds are only included when the cluster serves them, like in SetupWithManager.
+func WatchedObjects(mapper meta.RESTMapper) ([]client.Object, error) {
+	objects := []client.Object{
+		&databasev1.Database{},
+		&databasev1.ClusterDatabasePolicy{},
+		&databasev1.SkillsQuota{},
+		&databasev1.DatabaseClass{},
+		&databasev1.Application{},
+		&databasev1.Backup{},
+		&databasev1.Restore{},
+		&appsv1.Deployment{},
+		&appsv1.StatefulSet{},
+		&corev1.Service{},
+		&batchv1.Job{},
+		&corev1.Secret{},
+		&corev1.ConfigMap{},
+		&corev1.Pod{},
+		&corev1.PersistentVolumeClaim{},
+		&corev1.Namespace{},
+		&discoveryv1.EndpointSlice{},
+		&networkingv1.NetworkPolicy{},
+	}
+	nodes := &metav1.PartialObjectMetadata{}
+	nodes.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Node"))
+	objects = append(objects, nodes)
+
+	available, err := apiAvailable(mapper, podDisruptionBudgetGVK)
+	if err != nil {
+		return nil, err
+	}
+	if available {
+		objects = append(objects, &policyv1.PodDisruptionBudget{})
+	}
+	return objects, nil
+}
+
+// WarmSpare keeps the informers of every watched kind running on replicas that are not the
+// leader. Controllers only start their informers once elected, so without it a new leader
+// lists every Pod, Secret and ConfigMap in the cluster before it reconciles anything, which
+// takes minutes on large clusters. With it, failover costs the lease timeout only.
+//
+// While waiting, a replica serves what it can without writing: the cached Databases on
+// /debug/databases of the metrics server, and their desired state as metrics. Both are
+// answered by every replica, so they stay available during failover.
+type WarmSpare struct {
+	// Enabled starts the informers on every replica instead of on the leader only
+	Enabled bool
+
+	// Cache whose informers are started; Reader serves the diagnostics from it
+	Cache  cache.Informers
+	Reader client.Reader
+
+	// Objects are the kinds to keep warm, usually WatchedObjects
+	Objects []client.Object
+
+	// Elected is closed when this replica becomes the leader
+	Elected <-chan struct{}
+
+	synced atomic.Bool
+}
+
+var (
+	_ manager.LeaderElectionRunnable = &WarmSpare{}
+	_ prometheus.Collector           = &WarmSpare{}
+	_ http.Handler                   = &WarmSpare{}
+	_ healthz.Checker                = (&WarmSpare{}).Synced
+)
+
+// BindFlags registers flags for the warm spare, using the current values as defaults
+func (w *WarmSpare) BindFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&w.Enabled, "warm-spare", w.Enabled,
+		"Keep the informer caches of replicas that are not the leader in sync, so failover does not wait for a full resync.")
+}
+
+// Start starts an informer for every object and waits for them to sync
+func (w *WarmSpare) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("warm-spare")
+	started := time.Now()
+
+	// GetInformer blocks until the informer has synced
+	for _, obj := range w.Objects {
+		if _, err := w.Cache.GetInformer(ctx, obj); err != nil {
+			return fmt.Errorf("failed to start informer for %T: %w", obj, err)
+		}
+	}
+	w.synced.Store(true)
+	logger.Info("Caches warm", "kinds", len(w.Objects), "duration", time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+// NeedLeaderElection is false: the caches are kept warm for the time a replica is not the leader
+func (w *WarmSpare) NeedLeaderElection() bool {
+	return false
+}
+
+// Synced is a readiness check failing until every informer has synced
+func (w *WarmSpare) Synced(_ *http.Request) error {
+	if !w.synced.Load() {
+		return errors.New("informer caches are not synced yet")
+	}
+	return nil
+}
+
+// isLeader reports whether this replica was elected
+func (w *WarmSpare) isLeader() bool {
+	select {
+	case <-w.Elected:
+		return true
+	default:
+		return false
+	}
+}
+
+// DatabaseDiagnostics is the cached state of one Database on /debug/databases
+type DatabaseDiagnostics struct {
+	Namespace          string `json:"namespace"`
+	Name               string `json:"name"`
+	Generation         int64  `json:"generation"`
+	ObservedGeneration int64  `json:"observedGeneration"`
+	Phase              string `json:"phase,omitempty"`
+	Replicas           int32  `json:"replicas"`
+	ReadyReplicas      int32  `json:"readyReplicas"`
+	Deleting           bool   `json:"deleting,omitempty"`
+
+	// Health is the kstatus health, as kubectl plugins and deployment pipelines compute it
+	Health health.Result `json:"health"`
+}
+
+// Diagnostics is the body of /debug/databases
+type Diagnostics struct {
+	Leader    bool                  `json:"leader"`
+	Synced    bool                  `json:"synced"`
+	Databases []DatabaseDiagnostics `json:"databases"`
+}
+
+// ServeHTTP answers /debug/databases from the cache, on GET only
+func (w *WarmSpare) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !w.synced.Load() {
+		http.Error(rw, "informer caches are not synced yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	var databases databasev1.DatabaseList
+	if err := w.Reader.List(req.Context(), &databases); err != nil {
+		log.FromContext(req.Context()).Error(err, "failed to list Databases")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	diagnostics := Diagnostics{Leader: w.isLeader(), Synced: true, Databases: []DatabaseDiagnostics{}}
+	for _, database := range databases.Items {
+		diagnostics.Databases = append(diagnostics.Databases, DatabaseDiagnostics{
+			Namespace:          database.Namespace,
+			Name:               database.Name,
+			Generation:         database.Generation,
+			ObservedGeneration: database.Status.ObservedGeneration,
+			Phase:              database.Status.Phase,
+			Replicas:           database.Spec.Replicas,
+			ReadyReplicas:      database.Status.ReadyReplicas,
+			Deleting:           !database.DeletionTimestamp.IsZero(),
+			Health:             databasev1.ComputeHealth(&database),
+		})
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(diagnostics)
+}
+
+// DiagnosticsHandlers returns the endpoints to add to the metrics server
+func (w *WarmSpare) DiagnosticsHandlers() map[string]http.Handler {
+	return map[string]http.Handler{warmSpareDiagnosticsPath: w}
+}
+
+// Describe implements prometheus.Collector
+func (w *WarmSpare) Describe(ch chan<- *prometheus.Desc) {
+	ch <- desiredReplicasDesc
+	ch <- pendingGenerationDesc
+	ch <- leaderDesc
+	ch <- cacheSyncedDesc
+}
+
+// Collect implements prometheus.Collector. The Database metrics are read from the cache on
+// every scrape, so they are left out until it has synced rather than reported as empty.
+func (w *WarmSpare) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(leaderDesc, prometheus.GaugeValue, boolValue(w.isLeader()))
+	ch <- prometheus.MustNewConstMetric(cacheSyncedDesc, prometheus.GaugeValue, boolValue(w.synced.Load()))
+	if !w.synced.Load() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var databases databasev1.DatabaseList
+	if err := w.Reader.List(ctx, &databases); err != nil {
+		ch <- prometheus.NewInvalidMetric(desiredReplicasDesc, err)
+		return
+	}
+	for _, database := range databases.Items {
+		ch <- prometheus.MustNewConstMetric(desiredReplicasDesc, prometheus.GaugeValue,
+			float64(database.Spec.Replicas), database.Namespace, database.Name)
+		ch <- prometheus.MustNewConstMetric(pendingGenerationDesc, prometheus.GaugeValue,
+			boolValue(database.Status.ObservedGeneration != database.Generation), database.Namespace, database.Name)
+	}
+}
+
+// boolValue is the gauge value of a flag
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
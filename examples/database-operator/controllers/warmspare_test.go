@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/health"
+)
+
+func TestWarmSpare(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, databasev1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop", Generation: 3},
+		Spec:       databasev1.DatabaseSpec{Replicas: 2, Image: "postgres:15", Storage: 1024},
+		Status:     databasev1.DatabaseStatus{ObservedGeneration: 2, Phase: "Running", ReadyReplicas: 2},
+	}
+	informers := &informertest.FakeInformers{Scheme: scheme}
+	elected := make(chan struct{})
+	warmSpare := &WarmSpare{
+		Cache:   informers,
+		Reader:  fake.NewClientBuilder().WithScheme(scheme).WithObjects(database).Build(),
+		Objects: []client.Object{&databasev1.Database{}, &appsv1.Deployment{}, &corev1.Pod{}},
+		Elected: elected,
+	}
+
+	// Nothing is served before the caches have synced
+	assert.Error(t, warmSpare.Synced(nil))
+	rec := httptest.NewRecorder()
+	warmSpare.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, warmSpareDiagnosticsPath, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, 2, testutil.CollectAndCount(warmSpare), "Only the replica metrics are reported")
+
+	require.NoError(t, warmSpare.Start(context.Background()))
+	assert.NoError(t, warmSpare.Synced(nil))
+	assert.Len(t, informers.InformersByGVK, 3, "An informer is started for every object")
+
+	rec = httptest.NewRecorder()
+	warmSpare.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, warmSpareDiagnosticsPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var diagnostics Diagnostics
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &diagnostics))
+	assert.False(t, diagnostics.Leader)
+	assert.Equal(t, []DatabaseDiagnostics{{
+		Namespace: "shop", Name: "orders", Generation: 3, ObservedGeneration: 2,
+		Phase: "Running", Replicas: 2, ReadyReplicas: 2,
+		Health: health.Result{Status: health.InProgress, Message: "Generation is 3, but latest observed generation is 2"},
+	}}, diagnostics.Databases)
+
+	rec = httptest.NewRecorder()
+	warmSpare.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, warmSpareDiagnosticsPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code, "The endpoint is read-only")
+
+	close(elected)
+	assert.NoError(t, testutil.CollectAndCompare(warmSpare, strings.NewReader(`
+# HELP database_desired_replicas Replicas requested in the Database spec, as seen by the cache of this operator replica.
+# TYPE database_desired_replicas gauge
+database_desired_replicas{database="orders",namespace="shop"} 2
+# HELP database_generation_pending 1 if the Database spec has changed since it was last reconciled, as seen by the cache of this operator replica.
+# TYPE database_generation_pending gauge
+database_generation_pending{database="orders",namespace="shop"} 1
+# HELP database_operator_leader 1 if this operator replica holds the leader lease and runs the controllers.
+# TYPE database_operator_leader gauge
+database_operator_leader 1
+`), "database_desired_replicas", "database_generation_pending", "database_operator_leader"))
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/naming"
+)
+
+// Database readiness is driven by pod and endpoint events rather than polling.
+// Pods are owned by ReplicaSets and EndpointSlices by the endpoint controller,
+// so neither can be watched with Owns(); both are mapped back via labels instead.
+
+// hasLabel returns a predicate that only admits objects carrying the given label
+func hasLabel(key string) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		_, ok := obj.GetLabels()[key]
+		return ok
+	})
+}
+
+// findDatabaseForPod maps a database pod to the Database that runs it
+func (r *DatabaseReconciler) findDatabaseForPod(ctx context.Context, o client.Object) []reconcile.Request {
+	return r.requestForLabel(ctx, o, databaseNameLabel)
+}
+
+// findDatabaseForEndpointSlice maps an EndpointSlice to the Database whose Service it backs,
+// using the Database label copied from the Service. Services created before the label was set
+// share the Database's name.
+func (r *DatabaseReconciler) findDatabaseForEndpointSlice(ctx context.Context, o client.Object) []reconcile.Request {
+	if _, ok := o.GetLabels()[databaseNameLabel]; ok {
+		return r.requestForLabel(ctx, o, databaseNameLabel)
+	}
+	return r.requestForLabel(ctx, o, discoveryv1.LabelServiceName)
+}
+
+// requestForLabel enqueues the Database named by the given label in the object's namespace
+func (r *DatabaseReconciler) requestForLabel(ctx context.Context, o client.Object, key string) []reconcile.Request {
+	name, ok := o.GetLabels()[key]
+	if !ok || name == "" {
+		return nil
+	}
+
+	// A shortened name only matches through the index; the name itself is enqueued anyway, as
+	// a Database may be named like a shortened name
+	requests := []reconcile.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Name:      name,
+				Namespace: o.GetNamespace(),
+			},
+		},
+	}
+	if !naming.MayBeShortened(name) {
+		return requests
+	}
+	var list databasev1.DatabaseList
+	if err := r.List(ctx, &list, client.InNamespace(o.GetNamespace()),
+		client.MatchingFields{databaseLabelIndex: name}, client.UnsafeDisableDeepCopy); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list Databases", "label", name)
+		return requests
+	}
+	for i := range list.Items {
+		if list.Items[i].Name != name {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+	return requests
+}
+
+// databaseLabelIndex indexes Databases by databaseLabel, the value of their labels on children
+const databaseLabelIndex = ".metadata.labelValue"
+
+// indexDatabaseLabel is the databaseLabelIndex function
+func indexDatabaseLabel(obj client.Object) []string {
+	database, ok := obj.(*databasev1.Database)
+	if !ok {
+		return nil
+	}
+	return []string{databaseLabel(database)}
+}
+
+// getDatabaseForLabel gets the Database whose children carry value as their databaseNameLabel
+func getDatabaseForLabel(ctx context.Context, reader client.Reader, namespace, value string, database *databasev1.Database) error {
+	err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: value}, database)
+	if !errors.IsNotFound(err) || !naming.MayBeShortened(value) {
+		return err
+	}
+	var list databasev1.DatabaseList
+	if listErr := reader.List(ctx, &list, client.InNamespace(namespace), client.MatchingFields{databaseLabelIndex: value}); listErr != nil {
+		return listErr
+	}
+	if len(list.Items) == 0 {
+		return err
+	}
+	list.Items[0].DeepCopyInto(database)
+	return nil
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestDatabaseReconciler_FindDatabaseForPod(t *testing.T) {
+	reconciler := &DatabaseReconciler{}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-db-abc12",
+			Namespace: "default",
+			Labels:    map[string]string{databaseNameLabel: "test-db"},
+		},
+	}
+	requests := reconciler.findDatabaseForPod(context.Background(), pod)
+	assert.Len(t, requests, 1)
+	assert.Equal(t, types.NamespacedName{Name: "test-db", Namespace: "default"}, requests[0].NamespacedName)
+
+	// Pods not managed by a Database are ignored
+	unrelated := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}}
+	assert.Empty(t, reconciler.findDatabaseForPod(context.Background(), unrelated))
+}
+
+func TestDatabaseReconciler_FindDatabaseForEndpointSlice(t *testing.T) {
+	reconciler := &DatabaseReconciler{}
+
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-db-x7k2p",
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "test-db"},
+		},
+	}
+	requests := reconciler.findDatabaseForEndpointSlice(context.Background(), slice)
+	assert.Len(t, requests, 1)
+	assert.Equal(t, types.NamespacedName{Name: "test-db", Namespace: "default"}, requests[0].NamespacedName)
+
+	// A shortened Service name is mapped through the Database label copied from the Service
+	slice.Labels = map[string]string{
+		discoveryv1.LabelServiceName: "orders-eu-5d1e0f7a",
+		databaseNameLabel:            "orders.eu",
+	}
+	requests = reconciler.findDatabaseForEndpointSlice(context.Background(), slice)
+	assert.Len(t, requests, 1)
+	assert.Equal(t, types.NamespacedName{Name: "orders.eu", Namespace: "default"}, requests[0].NamespacedName)
+}
+
+func TestHasLabel(t *testing.T) {
+	pred := hasLabel(databaseNameLabel)
+
+	labeled := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{databaseNameLabel: "test-db"}}}
+	assert.True(t, pred.Generic(event.GenericEvent{Object: labeled}))
+
+	unlabeled := &corev1.Pod{}
+	assert.False(t, pred.Generic(event.GenericEvent{Object: unlabeled}))
+}
+
+// cachedDatabases serves Database lists the way the manager's cache does, which the fake
+// client does not: a field selector is answered from an index, and only the Databases returned
+// are copied, unless UnsafeDisableDeepCopy is set
+type cachedDatabases struct {
+	client.Client
+	indexer toolscache.Indexer
+}
+
+func newCachedDatabases(b *testing.B, c client.Client, indexes map[string]client.IndexerFunc, databases []*databasev1.Database) *cachedDatabases {
+	indexers := toolscache.Indexers{toolscache.NamespaceIndex: toolscache.MetaNamespaceIndexFunc}
+	for field, extract := range indexes {
+		extract := extract
+		indexers["field:"+field] = func(obj interface{}) ([]string, error) {
+			var keys []string
+			for _, value := range extract(obj.(client.Object)) {
+				keys = append(keys, obj.(client.Object).GetNamespace()+"/"+value)
+			}
+			return keys, nil
+		}
+	}
+	indexer := toolscache.NewIndexer(toolscache.MetaNamespaceKeyFunc, indexers)
+	for _, database := range databases {
+		require.NoError(b, indexer.Add(database))
+	}
+	return &cachedDatabases{Client: c, indexer: indexer}
+}
+
+func (c *cachedDatabases) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	databases, ok := list.(*databasev1.DatabaseList)
+	if !ok {
+		return c.Client.List(ctx, list, opts...)
+	}
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	var objs []interface{}
+	var err error
+	if listOpts.FieldSelector != nil {
+		requirement := listOpts.FieldSelector.Requirements()[0]
+		objs, err = c.indexer.ByIndex("field:"+requirement.Field, listOpts.Namespace+"/"+requirement.Value)
+	} else {
+		objs, err = c.indexer.ByIndex(toolscache.NamespaceIndex, listOpts.Namespace)
+	}
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		database := obj.(*databasev1.Database)
+		if listOpts.UnsafeDisableDeepCopy == nil || !*listOpts.UnsafeDisableDeepCopy {
+			database = database.DeepCopy()
+		}
+		databases.Items = append(databases.Items, *database)
+	}
+	return nil
+}
+
+// BenchmarkFindDatabasesForConfigMap maps ConfigMap events in a cached namespace of 1000
+// Databases, of which one mounts the ConfigMap and ten take variables.
+// Run with: go test -run '^$' -bench FindDatabasesForConfigMap -benchmem ./controllers/
+func BenchmarkFindDatabasesForConfigMap(b *testing.B) {
+	scheme := runtime.NewScheme()
+	require.NoError(b, databasev1.AddToScheme(scheme))
+	require.NoError(b, corev1.AddToScheme(scheme))
+
+	var databases []*databasev1.Database
+	for i := 0; i < 1000; i++ {
+		database := &databasev1.Database{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("db-%d", i), Namespace: "default"},
+			Spec: databasev1.DatabaseSpec{
+				Replicas: 1, Image: "postgres:15", Storage: 1024, ConfigMapName: fmt.Sprintf("db-%d-config", i),
+			},
+		}
+		if i%100 == 0 {
+			database.Spec.Image = "${REGISTRY}/postgres:15"
+		}
+		databases = append(databases, database)
+	}
+	cache := newCachedDatabases(b, fake.NewClientBuilder().WithScheme(scheme).Build(), map[string]client.IndexerFunc{
+		configMapIndex:    indexConfigMaps,
+		substitutionIndex: indexSubstitution,
+	}, databases)
+	reconciler := &DatabaseReconciler{Client: cache, Scheme: scheme}
+
+	ctx := context.Background()
+	for _, bc := range []struct {
+		name      string
+		configMap *corev1.ConfigMap
+		want      int
+	}{
+		{
+			name:      "mounted",
+			configMap: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "db-7-config", Namespace: "default"}},
+			want:      1,
+		},
+		{
+			name: "variables",
+			configMap: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster-vars", Namespace: "default", Labels: map[string]string{variablesLabel: "true"},
+			}},
+			want: 10,
+		},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			require.Len(b, reconciler.findDatabasesForConfigMap(ctx, bc.configMap), bc.want)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				reconciler.findDatabasesForConfigMap(ctx, bc.configMap)
+			}
+		})
+	}
+}
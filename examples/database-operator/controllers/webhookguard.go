@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// webhookPolicy bounds how long a webhook handler may run. Budget is shorter than the
+// timeoutSeconds of the webhook configuration, so the handler still answers before the API
+// server gives up on it; its API calls are cancelled after Budget. FailOpen must match the
+// failurePolicy of the configuration: a handler out of budget admits the object with a
+// warning when the webhook fails open, and rejects it otherwise.
+type webhookPolicy struct {
+	Budget   time.Duration
+	FailOpen bool
+}
+
+// webhookPolicies are the policies of the webhooks, next to the timeoutSeconds and
+// failurePolicy of their configurations
+var webhookPolicies = map[string]webhookPolicy{
+	// timeoutSeconds=5, failurePolicy=Fail: a Database stored without the schema revision
+	// stamp would be taken for one an older operator wrote
+	DatabaseDefaultingWebhook: {Budget: 4 * time.Second},
+	// timeoutSeconds=5, failurePolicy=Ignore: the reconciler validates again and stalls an
+	// invalid Database with a clear reason
+	DatabaseValidationWebhook: {Budget: 4 * time.Second, FailOpen: true},
+	// timeoutSeconds=3, failurePolicy=Fail: the policy only holds if it cannot be bypassed
+	PodPolicyWebhook: {Budget: 2 * time.Second},
+}
+
+// Results of a webhook call in webhookDuration
+const (
+	webhookAllowed = "allowed"
+	webhookDenied  = "denied"
+	webhookError   = "error"
+	webhookTimeout = "timeout"
+)
+
+var webhookDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "database_operator_webhook_handler_duration_seconds",
+	Help:    "Time the webhook handlers take, by webhook and result: allowed, denied, error or timeout.",
+	Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 4},
+}, []string{"webhook", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(webhookDuration)
+}
+
+// guardDefaulter applies the policy of a defaulting webhook to its handler
+func guardDefaulter(name string, d admission.CustomDefaulter) admission.CustomDefaulter {
+	return &guardedDefaulter{name: name, policy: webhookPolicies[name], defaulter: d}
+}
+
+// guardValidator applies the policy of a validating webhook to its handler
+func guardValidator(name string, v admission.CustomValidator) admission.CustomValidator {
+	return &guardedValidator{name: name, policy: webhookPolicies[name], validator: v}
+}
+
+// run calls a handler within the budget of a policy and records how long it took. It returns
+// the result of the call and, when the handler ran out of budget, the error to answer with.
+func (p webhookPolicy) run(ctx context.Context, name string, handler func(ctx context.Context) error) (string, error) {
+	if p.Budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Budget)
+		defer cancel()
+	}
+	start := time.Now()
+	err := handler(ctx)
+
+	result := webhookAllowed
+	switch {
+	case err == nil:
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result = webhookTimeout
+		err = fmt.Errorf("the %s webhook did not finish within %s: %w", name, p.Budget, err)
+	case apierrors.IsInvalid(err) || apierrors.IsForbidden(err):
+		result = webhookDenied
+	default:
+		result = webhookError
+	}
+	webhookDuration.WithLabelValues(name, result).Observe(time.Since(start).Seconds())
+	return result, err
+}
+
+type guardedDefaulter struct {
+	name      string
+	policy    webhookPolicy
+	defaulter admission.CustomDefaulter
+}
+
+func (d *guardedDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	// A mutation half applied when the budget ran out is discarded with the error
+	_, err := d.policy.run(ctx, d.name, func(ctx context.Context) error {
+		return d.defaulter.Default(ctx, obj)
+	})
+	return err
+}
+
+type guardedValidator struct {
+	name      string
+	policy    webhookPolicy
+	validator admission.CustomValidator
+}
+
+func (v *guardedValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.validate(ctx, func(ctx context.Context) (admission.Warnings, error) {
+		return v.validator.ValidateCreate(ctx, obj)
+	})
+}
+
+func (v *guardedValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return v.validate(ctx, func(ctx context.Context) (admission.Warnings, error) {
+		return v.validator.ValidateUpdate(ctx, oldObj, newObj)
+	})
+}
+
+func (v *guardedValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.validate(ctx, func(ctx context.Context) (admission.Warnings, error) {
+		return v.validator.ValidateDelete(ctx, obj)
+	})
+}
+
+func (v *guardedValidator) validate(ctx context.Context, validate func(ctx context.Context) (admission.Warnings, error)) (admission.Warnings, error) {
+	var warnings admission.Warnings
+	result, err := v.policy.run(ctx, v.name, func(ctx context.Context) error {
+		var err error
+		warnings, err = validate(ctx)
+		return err
+	})
+	if result == webhookTimeout && v.policy.FailOpen {
+		return append(warnings, fmt.Sprintf("%s; the object was admitted without validation", err)), nil
+	}
+	return warnings, err
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// slowValidator waits for its context like a validator stuck on an API call
+type slowValidator struct{}
+
+func (slowValidator) ValidateCreate(ctx context.Context, _ runtime.Object) (admission.Warnings, error) {
+	<-ctx.Done()
+	return admission.Warnings{"looked up nothing"}, ctx.Err()
+}
+
+func (slowValidator) ValidateUpdate(ctx context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return slowValidator{}.ValidateCreate(ctx, newObj)
+}
+
+func (slowValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func TestGuardedValidator_Budget(t *testing.T) {
+	ctx := context.Background()
+	database := &databasev1.Database{}
+
+	// Failing open admits the object before the API server times out
+	failOpen := &guardedValidator{name: DatabaseValidationWebhook, policy: webhookPolicy{Budget: 10 * time.Millisecond, FailOpen: true}, validator: slowValidator{}}
+	start := time.Now()
+	warnings, err := failOpen.ValidateCreate(ctx, database)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	require.Len(t, warnings, 2)
+	assert.Equal(t, "the database-validation webhook did not finish within 10ms: context deadline exceeded; the object was admitted without validation", warnings[1])
+
+	// Failing closed rejects it
+	failClosed := &guardedValidator{name: PodPolicyWebhook, policy: webhookPolicy{Budget: 10 * time.Millisecond}, validator: slowValidator{}}
+	_, err = failClosed.ValidateUpdate(ctx, database, database)
+	assert.ErrorContains(t, err, "did not finish within 10ms")
+
+	// Handlers within budget are untouched
+	database.Spec.Image = "postgres:15 "
+	_, err = guardValidator(DatabaseValidationWebhook, &DatabaseValidator{}).ValidateCreate(ctx, database)
+	assert.True(t, apierrors.IsInvalid(err))
+}
+
+func TestWebhookPolicy_Results(t *testing.T) {
+	ctx := context.Background()
+	policy := webhookPolicy{Budget: time.Second}
+	invalid := apierrors.NewInvalid(schema.GroupKind{Kind: "Database"}, "orders", field.ErrorList{field.Required(field.NewPath("spec"), "")})
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "allowed", want: webhookAllowed},
+		{name: "denied", err: invalid, want: webhookDenied},
+		{name: "lookup failed", err: errors.New("connection refused"), want: webhookError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := policy.run(ctx, DatabaseValidationWebhook, func(context.Context) error { return tt.err })
+			assert.Equal(t, tt.want, result)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+
+	// Every webhook has a budget below its timeoutSeconds
+	for _, name := range []string{DatabaseDefaultingWebhook, DatabaseValidationWebhook, PodPolicyWebhook} {
+		assert.Positive(t, webhookPolicies[name].Budget, name)
+	}
+}
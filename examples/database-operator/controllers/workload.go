@@ -0,0 +1,402 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// workloadLabel tells StatefulSet pods apart from the Deployment pods of the same Database, so
+// the Service can be cut over from one to the other during a migration
+const workloadLabel = "database.my.domain/workload"
+
+// statefulSetWorkload is the workloadLabel value of StatefulSet pods
+const statefulSetWorkload = "statefulset"
+
+// conditionWorkloadMigration is True while a Database moves from a Deployment to a StatefulSet
+// or rolls back, and False once it migrated or rolled back
+const conditionWorkloadMigration = "WorkloadMigration"
+
+// Reasons of the WorkloadMigration condition
+const (
+	reasonMigrating   = "Migrating"
+	reasonMigrated    = "Migrated"
+	reasonRollingBack = "RollingBack"
+	reasonRolledBack  = "RolledBack"
+)
+
+// Migration steps, in order. Each has a condition that is True once the step is done.
+const (
+	stepStatefulSetCreated   = "MigrationStatefulSetCreated"
+	stepDeploymentScaledDown = "MigrationDeploymentScaledDown"
+	stepVolumeMoved          = "MigrationVolumeMoved"
+	stepServiceCutOver       = "MigrationServiceCutOver"
+	stepDeploymentDeleted    = "MigrationDeploymentDeleted"
+)
+
+var migrationSteps = []string{
+	stepStatefulSetCreated, stepDeploymentScaledDown, stepVolumeMoved, stepServiceCutOver, stepDeploymentDeleted,
+}
+
+const (
+	// migrationTimeout is how long the StatefulSet pods may take to become ready on the moved
+	// volume before the migration is rolled back
+	migrationTimeout = 10 * time.Minute
+
+	// migrationPollInterval is how often a Database is reconciled while a migration step waits
+	// for pods to stop or start
+	migrationPollInterval = 5 * time.Second
+)
+
+// migrationWaitError is returned by the workload step while a migration step waits for pods
+type migrationWaitError struct {
+	message string
+}
+
+func (e *migrationWaitError) Error() string {
+	return e.message
+}
+
+// asMigrationWait returns the migration step being waited for, also when the workload step
+// ran in parallel
+func asMigrationWait(err error) (*migrationWaitError, bool) {
+	if aggregate, ok := err.(kerrors.Aggregate); ok && len(aggregate.Errors()) == 1 {
+		return asMigrationWait(aggregate.Errors()[0])
+	}
+	waiting, ok := err.(*migrationWaitError)
+	return waiting, ok
+}
+
+// statefulSetName is the name of the database StatefulSet
+func statefulSetName(database *databasev1.Database) string {
+	return deploymentName(database)
+}
+
+// statefulSetSelector selects the StatefulSet pods of a Database, but not its Deployment pods
+func statefulSetSelector(database *databasev1.Database) map[string]string {
+	labels := selectorLabels(database)
+	labels[workloadLabel] = statefulSetWorkload
+	return labels
+}
+
+// serviceSelector selects the pods the Service sends traffic to: the primary of a highly
+// available Database, the StatefulSet pods once a migration cut the Service over, every
+// database pod otherwise
+func serviceSelector(database *databasev1.Database) map[string]string {
+	if database.Spec.HighAvailability {
+		return primarySelector(database)
+	}
+	if database.Status.Workload == databasev1.WorkloadStatefulSet || stepDone(database, stepServiceCutOver) {
+		return statefulSetSelector(database)
+	}
+	return selectorLabels(database)
+}
+
+// stepDone reports whether a migration step is done
+func stepDone(database *databasev1.Database, step string) bool {
+	condition := database.GetCondition(step)
+	return condition != nil && condition.Status == metav1.ConditionTrue
+}
+
+// migrationReason returns the reason of the WorkloadMigration condition, empty without one
+func migrationReason(database *databasev1.Database) string {
+	if condition := database.GetCondition(conditionWorkloadMigration); condition != nil {
+		return condition.Reason
+	}
+	return ""
+}
+
+// rolledBack reports whether a migration of the current generation was rolled back; it is
+// retried when the spec changes
+func rolledBack(database *databasev1.Database) bool {
+	condition := database.GetCondition(conditionWorkloadMigration)
+	return condition != nil && condition.Reason == reasonRolledBack && condition.ObservedGeneration == database.Generation
+}
+
+// reconcileWorkload reconciles the workload running the database pods, migrating a
+// Deployment-based Database to a StatefulSet when spec.workload asks for one
+func (r *DatabaseReconciler) reconcileWorkload(ctx context.Context, database *databasev1.Database) error {
+	if database.Spec.HighAvailability {
+		return r.reconcileHighAvailability(ctx, database)
+	}
+	wantStatefulSet := database.Spec.Workload == databasev1.WorkloadStatefulSet
+	switch {
+	case database.Status.Workload == databasev1.WorkloadStatefulSet:
+		if !wantStatefulSet {
+			// Only possible with the webhook disabled; the Deployment is long gone
+			return errors.NewBadRequest("a Database migrated to a StatefulSet cannot go back to a Deployment")
+		}
+		return r.applyStatefulSet(ctx, database, database.Spec.Replicas)
+	case migrationReason(database) == reasonRollingBack:
+		return r.rollbackMigration(ctx, database)
+	case migrationReason(database) == reasonMigrating && !wantStatefulSet:
+		return r.startRollback(ctx, database, fmt.Errorf("spec.workload changed back to Deployment"))
+	case wantStatefulSet && !rolledBack(database):
+		return r.migrateToStatefulSet(ctx, database)
+	}
+	database.Status.Workload = databasev1.WorkloadDeployment
+	return r.reconcileDeployment(ctx, database)
+}
+
+// migrateToStatefulSet moves a running Database from its Deployment to a StatefulSet, one step
+// per pass. The StatefulSet mounts the PVC the Deployment used, so the data stays where it is,
+// but the volume is ReadWriteOnce: the Deployment pods stop before the StatefulSet pods start,
+// and the database is down in between. Failures the API server will not accept, and
+// StatefulSet pods that do not become ready within migrationTimeout, roll the migration back.
+func (r *DatabaseReconciler) migrateToStatefulSet(ctx context.Context, database *databasev1.Database) error {
+	deployment := &appsv1.Deployment{}
+	key := client.ObjectKey{Name: deploymentName(database), Namespace: database.Namespace}
+	if err := r.Get(ctx, key, deployment); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	hasDeployment := deployment.Name != ""
+
+	// A new Database has nothing to migrate
+	if !hasDeployment && migrationReason(database) == "" {
+		database.Status.Workload = databasev1.WorkloadStatefulSet
+		return r.applyStatefulSet(ctx, database, database.Spec.Replicas)
+	}
+	if migrationReason(database) != reasonMigrating {
+		for _, step := range migrationSteps {
+			meta.RemoveStatusCondition(&database.Status.Conditions, step)
+		}
+		database.SetCondition(conditionWorkloadMigration, metav1.ConditionTrue, reasonMigrating,
+			"Migrating from a Deployment to a StatefulSet")
+	}
+
+	if !stepDone(database, stepStatefulSetCreated) {
+		if err := r.applyStatefulSet(ctx, database, 0); err != nil {
+			return r.migrationFailed(ctx, database, stepStatefulSetCreated, err)
+		}
+		database.SetCondition(stepStatefulSetCreated, metav1.ConditionTrue, "Done",
+			fmt.Sprintf("StatefulSet %s created without pods", statefulSetName(database)))
+	}
+
+	// The Deployment pods must release the volume before the StatefulSet pods mount it
+	if !stepDone(database, stepDeploymentScaledDown) {
+		if hasDeployment {
+			if err := r.scaleToZero(ctx, deployment); err != nil {
+				return r.migrationFailed(ctx, database, stepDeploymentScaledDown, err)
+			}
+			if deployment.Status.ObservedGeneration < deployment.Generation || deployment.Status.Replicas > 0 {
+				return &migrationWaitError{message: fmt.Sprintf("Migrating to a StatefulSet: waiting for the %d pods of Deployment %s to stop",
+					deployment.Status.Replicas, deployment.Name)}
+			}
+		}
+		database.SetCondition(stepDeploymentScaledDown, metav1.ConditionTrue, "Done",
+			fmt.Sprintf("Deployment %s has no pods left", deploymentName(database)))
+	}
+
+	if !stepDone(database, stepVolumeMoved) {
+		if err := r.applyStatefulSet(ctx, database, database.Spec.Replicas); err != nil {
+			return r.migrationFailed(ctx, database, stepVolumeMoved, err)
+		}
+		statefulSet := &appsv1.StatefulSet{}
+		if err := r.Get(ctx, client.ObjectKey{Name: statefulSetName(database), Namespace: database.Namespace}, statefulSet); err != nil {
+			return r.migrationFailed(ctx, database, stepVolumeMoved, err)
+		}
+		if statefulSet.Status.ReadyReplicas < database.Spec.Replicas {
+			scaledDown := database.GetCondition(stepDeploymentScaledDown).LastTransitionTime
+			if time.Since(scaledDown.Time) > migrationTimeout {
+				return r.startRollback(ctx, database, fmt.Errorf("StatefulSet %s did not become ready within %s", statefulSet.Name, migrationTimeout))
+			}
+			return &migrationWaitError{message: fmt.Sprintf("Migrating to a StatefulSet: waiting for replicas of StatefulSet %s: %d/%d",
+				statefulSet.Name, statefulSet.Status.ReadyReplicas, database.Spec.Replicas)}
+		}
+		database.SetCondition(stepVolumeMoved, metav1.ConditionTrue, "Done",
+			fmt.Sprintf("The StatefulSet pods are ready on PersistentVolumeClaim %s", pvcName(database)))
+	}
+
+	if !stepDone(database, stepServiceCutOver) {
+		database.SetCondition(stepServiceCutOver, metav1.ConditionTrue, "Done",
+			fmt.Sprintf("Service %s selects the StatefulSet pods", serviceName(database)))
+		if err := r.reconcileService(ctx, database); err != nil {
+			return r.migrationFailed(ctx, database, stepServiceCutOver, err)
+		}
+	}
+
+	if hasDeployment {
+		if err := r.deleteWorkload(ctx, database, deployment); err != nil {
+			return r.migrationFailed(ctx, database, stepDeploymentDeleted, err)
+		}
+	}
+	database.SetCondition(stepDeploymentDeleted, metav1.ConditionTrue, "Done",
+		fmt.Sprintf("Deployment %s deleted", deploymentName(database)))
+	database.SetCondition(conditionWorkloadMigration, metav1.ConditionFalse, reasonMigrated,
+		"Migrated from a Deployment to a StatefulSet")
+	database.Status.Workload = databasev1.WorkloadStatefulSet
+	if r.Recorder != nil {
+		r.Recorder.Eventf(database, nil, corev1.EventTypeNormal, reasonMigrated, "MigrateWorkload",
+			"Migrated from a Deployment to a StatefulSet")
+	}
+	return nil
+}
+
+// migrationFailed records a failed migration step. Errors the API server will keep returning
+// roll the migration back; anything else is retried.
+func (r *DatabaseReconciler) migrationFailed(ctx context.Context, database *databasev1.Database, step string, err error) error {
+	if isTerminalError(err) {
+		return r.startRollback(ctx, database, err)
+	}
+	database.SetCondition(step, metav1.ConditionFalse, "Failed", err.Error())
+	return err
+}
+
+// startRollback starts rolling a migration back
+func (r *DatabaseReconciler) startRollback(ctx context.Context, database *databasev1.Database, cause error) error {
+	message := fmt.Sprintf("Migration to a StatefulSet failed: %s", cause)
+	r.warn(database, nil, reasonRollingBack, "MigrateWorkload", message)
+	database.SetCondition(conditionWorkloadMigration, metav1.ConditionTrue, reasonRollingBack, message)
+	return r.rollbackMigration(ctx, database)
+}
+
+// rollbackMigration returns a Database to its Deployment: the Service selects every database
+// pod again, the StatefulSet pods stop and release the volume, and the Deployment is scaled
+// back up. It is not retried until the spec changes.
+func (r *DatabaseReconciler) rollbackMigration(ctx context.Context, database *databasev1.Database) error {
+	meta.RemoveStatusCondition(&database.Status.Conditions, stepServiceCutOver)
+	if err := r.reconcileService(ctx, database); err != nil {
+		return err
+	}
+
+	statefulSet := &appsv1.StatefulSet{}
+	key := client.ObjectKey{Name: statefulSetName(database), Namespace: database.Namespace}
+	if err := r.Get(ctx, key, statefulSet); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if statefulSet.Name != "" {
+		if err := r.scaleToZero(ctx, statefulSet); err != nil {
+			return err
+		}
+		if statefulSet.Status.ObservedGeneration < statefulSet.Generation || statefulSet.Status.Replicas > 0 {
+			return &migrationWaitError{message: fmt.Sprintf("Rolling back to the Deployment: waiting for the %d pods of StatefulSet %s to stop",
+				statefulSet.Status.Replicas, statefulSet.Name)}
+		}
+		if err := r.deleteWorkload(ctx, database, statefulSet); err != nil {
+			return err
+		}
+	}
+
+	// Scaling the Deployment down was a hand edit as far as reconcileDeployment is concerned,
+	// so it is reverted
+	if err := r.reconcileDeployment(ctx, database); err != nil {
+		return err
+	}
+	for _, step := range migrationSteps {
+		meta.RemoveStatusCondition(&database.Status.Conditions, step)
+	}
+	cause := database.GetCondition(conditionWorkloadMigration).Message
+	database.SetCondition(conditionWorkloadMigration, metav1.ConditionFalse, reasonRolledBack,
+		cause+"; rolled back to the Deployment, change the spec to retry")
+	for i := range database.Status.Conditions {
+		if database.Status.Conditions[i].Type == conditionWorkloadMigration {
+			database.Status.Conditions[i].ObservedGeneration = database.Generation
+		}
+	}
+	database.Status.Workload = databasev1.WorkloadDeployment
+	return nil
+}
+
+// scaleToZero sets the replicas of a Deployment or StatefulSet to zero
+func (r *DatabaseReconciler) scaleToZero(ctx context.Context, workload client.Object) error {
+	patch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+	switch workload := workload.(type) {
+	case *appsv1.Deployment:
+		if ptr.Deref(workload.Spec.Replicas, 1) == 0 {
+			return nil
+		}
+		workload.Spec.Replicas = ptr.To[int32](0)
+	case *appsv1.StatefulSet:
+		if ptr.Deref(workload.Spec.Replicas, 1) == 0 {
+			return nil
+		}
+		workload.Spec.Replicas = ptr.To[int32](0)
+	}
+	return r.Patch(ctx, workload, patch)
+}
+
+// deleteWorkload deletes a workload the Database controls
+func (r *DatabaseReconciler) deleteWorkload(ctx context.Context, database *databasev1.Database, workload client.Object) error {
+	if !metav1.IsControlledBy(workload, database) {
+		return errors.NewBadRequest(fmt.Sprintf("%s is not controlled by the Database", workload.GetName()))
+	}
+	err := r.Delete(ctx, workload, client.Preconditions{UID: ptr.To(workload.GetUID())})
+	return client.IgnoreNotFound(err)
+}
+
+// applyStatefulSet creates or updates the database StatefulSet with the given replicas. It
+// renders the same pods as the Deployment and mounts the same PVC instead of claim templates,
+// which is what lets a migration keep the data. A highly available Database claims a volume
+// per pod instead.
+func (r *DatabaseReconciler) applyStatefulSet(ctx context.Context, database *databasev1.Database, replicas int32) error {
+	checksums, err := r.podTemplateChecksums(ctx, database)
+	if err != nil {
+		return fmt.Errorf("failed to compute pod template checksums: %w", err)
+	}
+	podLabels := r.Propagation.podLabels(database)
+	podLabels[workloadLabel] = statefulSetWorkload
+	podSpec := renderPodSpec(database)
+	serviceName := serviceName(database)
+	if database.Spec.HighAvailability {
+		podSpec = highAvailabilityPodSpec(database, podSpec)
+		serviceName = headlessServiceName(database)
+	}
+	r.Passwords.mountPassword(database, &podSpec)
+
+	statefulSet := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{
+		Name:      statefulSetName(database),
+		Namespace: database.Namespace,
+	}}
+	_, err = r.createOrPatch(ctx, statefulSet, func() error {
+		statefulSet.Spec.Replicas = &replicas
+		r.Propagation.apply(database, statefulSet)
+		statefulSet.Spec.ServiceName = serviceName
+		// Claim templates are immutable; they are only set on creation
+		if database.Spec.HighAvailability && statefulSet.CreationTimestamp.IsZero() {
+			statefulSet.Spec.VolumeClaimTemplates = dataClaimTemplates(database)
+		}
+		statefulSet.Spec.Selector = &metav1.LabelSelector{MatchLabels: statefulSetSelector(database)}
+		statefulSet.Spec.Template.ObjectMeta.Labels = podLabels
+
+		if statefulSet.Spec.Template.Annotations == nil {
+			statefulSet.Spec.Template.Annotations = map[string]string{}
+		}
+		for key, value := range checksums {
+			statefulSet.Spec.Template.Annotations[key] = value
+		}
+
+		statefulSet.Spec.Template.Spec.InitContainers = podSpec.InitContainers
+		statefulSet.Spec.Template.Spec.Containers = podSpec.Containers
+		statefulSet.Spec.Template.Spec.ImagePullSecrets = podSpec.ImagePullSecrets
+		statefulSet.Spec.Template.Spec.PriorityClassName = podSpec.PriorityClassName
+		statefulSet.Spec.Template.Spec.TopologySpreadConstraints = podSpec.TopologySpreadConstraints
+		statefulSet.Spec.Template.Spec.Volumes = podSpec.Volumes
+
+		return controllerutil.SetControllerReference(database, statefulSet, r.Scheme)
+	})
+	return err
+}
+
+// waitForMigration records a migration step waiting for pods and checks again shortly
+func (r *DatabaseReconciler) waitForMigration(ctx context.Context, database *databasev1.Database, waiting *migrationWaitError) (ctrl.Result, error) {
+	database.Status.Phase = "Migrating"
+	database.SetCondition("Ready", metav1.ConditionFalse, migrationReason(database), waiting.Error())
+	r.recordHistory(ctx, database, nil)
+	if err := r.Status().Update(ctx, database); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: migrationPollInterval}, nil
+}
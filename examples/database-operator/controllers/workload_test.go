@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// migrationFixture is a Deployment-based Database asked to move to a StatefulSet
+func migrationFixture(t *testing.T) (*DatabaseReconciler, client.Client, *databasev1.Database) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "default", UID: "orders-uid", Generation: 2},
+		Spec: databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024,
+			Workload: databasev1.WorkloadStatefulSet},
+		Status: databasev1.DatabaseStatus{Workload: databasev1.WorkloadDeployment},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: passwordSecretName(database), Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: deploymentName(database), Namespace: "default", UID: "deployment-uid"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To[int32](1),
+			Selector: &metav1.LabelSelector{MatchLabels: selectorLabels(database)},
+		},
+		Status: appsv1.DeploymentStatus{Replicas: 1, ReadyReplicas: 1},
+	}
+	require.NoError(t, ctrl.SetControllerReference(database, deployment, scheme))
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(database, secret, deployment).
+		WithStatusSubresource(database).
+		Build()
+	return &DatabaseReconciler{Client: fakeClient, Scheme: scheme}, fakeClient, database
+}
+
+// setWorkloadReplicas sets the observed pods of a workload, like its controller would
+func setWorkloadReplicas(t *testing.T, c client.Client, workload client.Object, replicas int32) {
+	ctx := context.Background()
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(workload), workload))
+	switch workload := workload.(type) {
+	case *appsv1.Deployment:
+		workload.Status = appsv1.DeploymentStatus{ObservedGeneration: workload.Generation, Replicas: replicas, ReadyReplicas: replicas}
+	case *appsv1.StatefulSet:
+		workload.Status = appsv1.StatefulSetStatus{ObservedGeneration: workload.Generation, Replicas: replicas, ReadyReplicas: replicas}
+	}
+	require.NoError(t, c.Status().Update(ctx, workload))
+}
+
+func TestDatabaseReconciler_MigrateToStatefulSet(t *testing.T) {
+	reconciler, fakeClient, database := migrationFixture(t)
+	ctx := context.Background()
+	deploymentKey := client.ObjectKey{Name: deploymentName(database), Namespace: "default"}
+	statefulSetKey := client.ObjectKey{Name: statefulSetName(database), Namespace: "default"}
+
+	// The StatefulSet is created without pods, and the Deployment pods are stopped
+	err := reconciler.reconcileWorkload(ctx, database)
+	_, ok := asMigrationWait(err)
+	require.True(t, ok, "unexpected error %v", err)
+	assert.Equal(t, reasonMigrating, migrationReason(database))
+	assert.True(t, stepDone(database, stepStatefulSetCreated))
+	assert.False(t, stepDone(database, stepDeploymentScaledDown))
+	statefulSet := &appsv1.StatefulSet{}
+	require.NoError(t, fakeClient.Get(ctx, statefulSetKey, statefulSet))
+	assert.Equal(t, int32(0), *statefulSet.Spec.Replicas)
+	assert.Equal(t, pvcName(database), statefulSet.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName, "The StatefulSet mounts the same volume")
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, deploymentKey, deployment))
+	assert.Equal(t, int32(0), *deployment.Spec.Replicas)
+
+	// Waiting is not a failure
+	result, err := reconciler.setErrorStatus(ctx, database, "DeploymentCreateFailed", err)
+	require.NoError(t, err)
+	assert.Equal(t, ctrl.Result{RequeueAfter: migrationPollInterval}, result)
+	assert.Equal(t, "Migrating", database.Status.Phase)
+
+	// Once the volume is released the StatefulSet starts its pods on it
+	setWorkloadReplicas(t, fakeClient, deployment, 0)
+	_, ok = asMigrationWait(reconciler.reconcileWorkload(ctx, database))
+	require.True(t, ok)
+	assert.True(t, stepDone(database, stepDeploymentScaledDown))
+	require.NoError(t, fakeClient.Get(ctx, statefulSetKey, statefulSet))
+	assert.Equal(t, int32(1), *statefulSet.Spec.Replicas)
+
+	// Once they are ready the Service is cut over and the Deployment deleted
+	setWorkloadReplicas(t, fakeClient, statefulSet, 1)
+	require.NoError(t, reconciler.reconcileWorkload(ctx, database))
+	for _, step := range migrationSteps {
+		assert.True(t, stepDone(database, step), step)
+	}
+	assert.Equal(t, reasonMigrated, migrationReason(database))
+	assert.Equal(t, databasev1.WorkloadStatefulSet, database.Status.Workload)
+	assert.True(t, errors.IsNotFound(fakeClient.Get(ctx, deploymentKey, deployment)))
+	service := &corev1.Service{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: serviceName(database), Namespace: "default"}, service))
+	assert.Equal(t, statefulSetWorkload, service.Spec.Selector[workloadLabel])
+
+	// From now on the StatefulSet is reconciled like the Deployment was
+	database.Spec.Replicas = 2
+	require.NoError(t, reconciler.reconcileWorkload(ctx, database))
+	require.NoError(t, fakeClient.Get(ctx, statefulSetKey, statefulSet))
+	assert.Equal(t, int32(2), *statefulSet.Spec.Replicas)
+	assert.Equal(t, []client.Object{&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: statefulSetName(database), Namespace: "default"}}},
+		desiredChildren(database)[2:3])
+}
+
+func TestDatabaseReconciler_MigrationRollsBack(t *testing.T) {
+	reconciler, fakeClient, database := migrationFixture(t)
+	ctx := context.Background()
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: deploymentName(database), Namespace: "default"}}
+	statefulSetKey := client.ObjectKey{Name: statefulSetName(database), Namespace: "default"}
+
+	_, ok := asMigrationWait(reconciler.reconcileWorkload(ctx, database))
+	require.True(t, ok)
+	setWorkloadReplicas(t, fakeClient, deployment, 0)
+	_, ok = asMigrationWait(reconciler.reconcileWorkload(ctx, database))
+	require.True(t, ok)
+	statefulSet := &appsv1.StatefulSet{}
+	require.NoError(t, fakeClient.Get(ctx, statefulSetKey, statefulSet))
+	// Started, but never ready
+	statefulSet.Status = appsv1.StatefulSetStatus{ObservedGeneration: statefulSet.Generation, Replicas: 1}
+	require.NoError(t, fakeClient.Status().Update(ctx, statefulSet))
+
+	// The pods do not become ready in time: the StatefulSet pods are stopped first
+	for i := range database.Status.Conditions {
+		if database.Status.Conditions[i].Type == stepDeploymentScaledDown {
+			database.Status.Conditions[i].LastTransitionTime = metav1.NewTime(time.Now().Add(-migrationTimeout - time.Minute))
+		}
+	}
+	_, ok = asMigrationWait(reconciler.reconcileWorkload(ctx, database))
+	require.True(t, ok)
+	assert.Equal(t, reasonRollingBack, migrationReason(database))
+	assert.Contains(t, database.GetCondition(conditionWorkloadMigration).Message, "did not become ready within 10m0s")
+	require.NoError(t, fakeClient.Get(ctx, statefulSetKey, statefulSet))
+	assert.Equal(t, int32(0), *statefulSet.Spec.Replicas)
+
+	// Then the StatefulSet is deleted and the Deployment scaled back up
+	setWorkloadReplicas(t, fakeClient, statefulSet, 0)
+	require.NoError(t, reconciler.reconcileWorkload(ctx, database))
+	assert.True(t, errors.IsNotFound(fakeClient.Get(ctx, statefulSetKey, statefulSet)))
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(deployment), deployment))
+	assert.Equal(t, int32(1), *deployment.Spec.Replicas)
+	condition := database.GetCondition(conditionWorkloadMigration)
+	assert.Equal(t, reasonRolledBack, condition.Reason)
+	assert.Equal(t, database.Generation, condition.ObservedGeneration)
+	assert.Nil(t, database.GetCondition(stepDeploymentScaledDown))
+	assert.Equal(t, databasev1.WorkloadDeployment, database.Status.Workload)
+
+	// It is not retried until the spec changes
+	require.NoError(t, reconciler.reconcileWorkload(ctx, database))
+	assert.True(t, errors.IsNotFound(fakeClient.Get(ctx, statefulSetKey, statefulSet)))
+	database.Generation++
+	_, ok = asMigrationWait(reconciler.reconcileWorkload(ctx, database))
+	assert.True(t, ok)
+	assert.Equal(t, reasonMigrating, migrationReason(database))
+}
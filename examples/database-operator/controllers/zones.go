@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// conditionZoneSkewed is True while the database pods are spread across zones more unevenly
+// than spec.zoneSpread.maxSkew allows, e.g. after a zone outage or with ScheduleAnyway
+const conditionZoneSkewed = "ZoneSkewed"
+
+//+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
+
+// topologyKey is the node label the zones of a Database are read from
+func topologyKey(spread *databasev1.ZoneSpreadSpec) string {
+	if spread.TopologyKey != "" {
+		return spread.TopologyKey
+	}
+	return corev1.LabelTopologyZone
+}
+
+// maxSkew is the allowed skew, defaulted for Databases the API server has not defaulted
+func maxSkew(spread *databasev1.ZoneSpreadSpec) int32 {
+	if spread.MaxSkew < 1 {
+		return 1
+	}
+	return spread.MaxSkew
+}
+
+// zoneSpreadConstraints renders the topology spread constraint of a Database's pods
+func zoneSpreadConstraints(database *databasev1.Database) []corev1.TopologySpreadConstraint {
+	spread := database.Spec.ZoneSpread
+	if spread == nil {
+		return nil
+	}
+	whenUnsatisfiable := spread.WhenUnsatisfiable
+	if whenUnsatisfiable == "" {
+		whenUnsatisfiable = corev1.ScheduleAnyway
+	}
+	return []corev1.TopologySpreadConstraint{{
+		MaxSkew:           maxSkew(spread),
+		TopologyKey:       topologyKey(spread),
+		WhenUnsatisfiable: whenUnsatisfiable,
+		LabelSelector:     &metav1.LabelSelector{MatchLabels: selectorLabels(database)},
+	}}
+}
+
+// reportZones records how the Database's pods are distributed across zones and sets the
+// ZoneSkewed condition. Every zone with a labelled node counts, as in the scheduler, so a
+// zone without replicas shows up with zero. pods are the Database's pods, already listed for
+// the status.
+func (r *DatabaseReconciler) reportZones(ctx context.Context, database *databasev1.Database, pods []corev1.Pod) error {
+	spread := database.Spec.ZoneSpread
+	if spread == nil {
+		database.Status.Zones = nil
+		meta.RemoveStatusCondition(&database.Status.Conditions, conditionZoneSkewed)
+		return nil
+	}
+	key := topologyKey(spread)
+
+	// Only the labels are needed, so nodes are cached as metadata
+	nodes := &metav1.PartialObjectMetadataList{}
+	nodes.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("NodeList"))
+	if err := r.List(ctx, nodes); err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+	nodeZones := map[string]string{}
+	replicas := map[string]int32{}
+	for _, node := range nodes.Items {
+		if zone, ok := node.Labels[key]; ok {
+			nodeZones[node.Name] = zone
+			replicas[zone] += 0
+		}
+	}
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" || !pod.DeletionTimestamp.IsZero() ||
+			pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if zone, ok := nodeZones[pod.Spec.NodeName]; ok {
+			replicas[zone]++
+		}
+	}
+
+	zones := make([]databasev1.ZoneReplicas, 0, len(replicas))
+	for zone, count := range replicas {
+		zones = append(zones, databasev1.ZoneReplicas{Zone: zone, Replicas: count})
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		return zones[i].Zone < zones[j].Zone
+	})
+	database.Status.Zones = zones
+
+	if len(zones) == 0 {
+		database.SetCondition(conditionZoneSkewed, metav1.ConditionUnknown, "NoZones",
+			fmt.Sprintf("No node has the %s label", key))
+		return nil
+	}
+	lowest, highest := zones[0].Replicas, zones[0].Replicas
+	placement := make([]string, 0, len(zones))
+	for _, zone := range zones {
+		lowest, highest = min(lowest, zone.Replicas), max(highest, zone.Replicas)
+		placement = append(placement, fmt.Sprintf("%s=%d", zone.Zone, zone.Replicas))
+	}
+	skew := highest - lowest
+	message := fmt.Sprintf("Replicas per zone: %s (skew %d, maxSkew %d)", strings.Join(placement, ", "), skew, maxSkew(spread))
+
+	if skew <= maxSkew(spread) {
+		database.SetCondition(conditionZoneSkewed, metav1.ConditionFalse, "WithinMaxSkew", message)
+		return nil
+	}
+	if !meta.IsStatusConditionTrue(database.Status.Conditions, conditionZoneSkewed) {
+		r.warn(database, nil, "ZoneSkewed", "Placement", message)
+	}
+	database.SetCondition(conditionZoneSkewed, metav1.ConditionTrue, "MaxSkewExceeded", message)
+	return nil
+}
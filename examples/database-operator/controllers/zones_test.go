@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestZoneSpreadConstraints(t *testing.T) {
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec:       databasev1.DatabaseSpec{Replicas: 3, Image: "postgres:15", Storage: 1024},
+	}
+	assert.Empty(t, renderPodSpec(database).TopologySpreadConstraints)
+
+	database.Spec.ZoneSpread = &databasev1.ZoneSpreadSpec{}
+	constraints := renderPodSpec(database).TopologySpreadConstraints
+	require.Len(t, constraints, 1)
+	assert.Equal(t, int32(1), constraints[0].MaxSkew)
+	assert.Equal(t, corev1.LabelTopologyZone, constraints[0].TopologyKey)
+	assert.Equal(t, corev1.ScheduleAnyway, constraints[0].WhenUnsatisfiable)
+	assert.Equal(t, selectorLabels(database), constraints[0].LabelSelector.MatchLabels)
+}
+
+func TestDatabaseReconciler_ReportZones(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	node := func(name, zone string) *corev1.Node {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if zone != "" {
+			node.Labels = map[string]string{corev1.LabelTopologyZone: zone}
+		}
+		return node
+	}
+	pod := func(name, node string) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	}
+	database := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec: databasev1.DatabaseSpec{
+			Replicas:   3,
+			Image:      "postgres:15",
+			Storage:    1024,
+			ZoneSpread: &databasev1.ZoneSpreadSpec{MaxSkew: 1},
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		node("node-a1", "zone-a"),
+		node("node-a2", "zone-a"),
+		node("node-b1", "zone-b"),
+		node("node-c1", "zone-c"),
+		node("node-unlabelled", ""),
+	).Build()
+	recorder := events.NewFakeRecorder(10)
+	reconciler := &DatabaseReconciler{Client: fakeClient, Scheme: scheme, Recorder: recorder}
+	ctx := context.Background()
+
+	// One pod per zone, plus one not scheduled yet
+	pods := []corev1.Pod{pod("test-db-a", "node-a1"), pod("test-db-b", "node-b1"), pod("test-db-c", "node-c1"), pod("test-db-d", "")}
+	require.NoError(t, reconciler.reportZones(ctx, database, pods))
+	assert.Equal(t, []databasev1.ZoneReplicas{
+		{Zone: "zone-a", Replicas: 1},
+		{Zone: "zone-b", Replicas: 1},
+		{Zone: "zone-c", Replicas: 1},
+	}, database.Status.Zones)
+	assert.True(t, meta.IsStatusConditionFalse(database.Status.Conditions, conditionZoneSkewed))
+
+	// zone-c is lost and its pod is rescheduled into zone-a
+	pods = []corev1.Pod{pod("test-db-a", "node-a1"), pod("test-db-b", "node-b1"), pod("test-db-c", "node-a2")}
+	require.NoError(t, fakeClient.Delete(ctx, node("node-c1", "zone-c")))
+	require.NoError(t, fakeClient.Create(ctx, node("node-c2", "zone-c")))
+	require.NoError(t, reconciler.reportZones(ctx, database, pods))
+	assert.Contains(t, database.Status.Zones, databasev1.ZoneReplicas{Zone: "zone-c", Replicas: 0}, "Empty zones count")
+	condition := meta.FindStatusCondition(database.Status.Conditions, conditionZoneSkewed)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "Replicas per zone: zone-a=2, zone-b=1, zone-c=0 (skew 2, maxSkew 1)", condition.Message)
+	assert.Len(t, recorder.Events, 1)
+
+	require.NoError(t, reconciler.reportZones(ctx, database, pods))
+	assert.Len(t, recorder.Events, 1, "A skew is reported once")
+
+	// Removing the spread clears the report
+	database.Spec.ZoneSpread = nil
+	require.NoError(t, reconciler.reportZones(ctx, database, pods))
+	assert.Empty(t, database.Status.Zones)
+	assert.Nil(t, meta.FindStatusCondition(database.Status.Conditions, conditionZoneSkewed))
+}
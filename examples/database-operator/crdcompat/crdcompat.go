@@ -0,0 +1,357 @@
+// Package crdcompat finds changes to a CRD that break users of an earlier release. Upgrading
+// the CRD in place must keep every stored object valid and every client working, so relative
+// to the released CRD a new one must not:
+//
+//   - remove a served version, a field or a subresource, or change the scope or names
+//   - change the type of a field
+//   - tighten validation: new required fields, higher minimums, lower maximums, new or
+//     changed patterns and formats, fewer enum values, new CEL rules, stricter list types
+//
+// Adding optional fields, versions and enum values and loosening validation are compatible.
+// The released CRDs are kept in testdata/released and compared with config/crd/bases by the
+// package test; copy the generated CRDs there when cutting a release.
+package crdcompat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// LoadDir reads the CRDs in the YAML files of a directory, by name
+func LoadDir(dir string) (map[string]*apiextensionsv1.CustomResourceDefinition, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	crds := map[string]*apiextensionsv1.CustomResourceDefinition{}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := yaml.UnmarshalStrict(content, crd); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		crds[crd.Name] = crd
+	}
+	return crds, nil
+}
+
+// CompareAll compares released CRDs with the current ones by name. CRDs added since the
+// release are compatible; removed ones are not.
+func CompareAll(released, current map[string]*apiextensionsv1.CustomResourceDefinition) []string {
+	var breaks []string
+	for name, crd := range released {
+		if next, ok := current[name]; ok {
+			breaks = append(breaks, Compare(crd, next)...)
+		} else {
+			breaks = append(breaks, fmt.Sprintf("%s: removed", name))
+		}
+	}
+	sort.Strings(breaks)
+	return breaks
+}
+
+// Compare returns the incompatible changes from a released CRD to the current one, each
+// prefixed with the CRD, the version and the path of the field
+func Compare(released, current *apiextensionsv1.CustomResourceDefinition) []string {
+	c := &comparison{}
+	name := released.Name
+	if released.Spec.Scope != current.Spec.Scope {
+		c.report(name, "scope changed from %s to %s", released.Spec.Scope, current.Spec.Scope)
+	}
+	if released.Spec.Names.Kind != current.Spec.Names.Kind {
+		c.report(name, "kind changed from %s to %s", released.Spec.Names.Kind, current.Spec.Names.Kind)
+	}
+	for _, shortName := range released.Spec.Names.ShortNames {
+		if !contains(current.Spec.Names.ShortNames, shortName) {
+			c.report(name, "short name %s removed", shortName)
+		}
+	}
+
+	for _, version := range released.Spec.Versions {
+		if !version.Served {
+			continue
+		}
+		prefix := name + " " + version.Name
+		next := findVersion(current, version.Name)
+		switch {
+		case next == nil:
+			c.report(prefix, "version removed")
+			continue
+		case !next.Served:
+			c.report(prefix, "version no longer served")
+			continue
+		}
+		if version.Subresources != nil && version.Subresources.Status != nil &&
+			(next.Subresources == nil || next.Subresources.Status == nil) {
+			c.report(prefix, "status subresource removed")
+		}
+		if version.Subresources != nil && version.Subresources.Scale != nil &&
+			(next.Subresources == nil || next.Subresources.Scale == nil) {
+			c.report(prefix, "scale subresource removed")
+		}
+		if version.Schema != nil && version.Schema.OpenAPIV3Schema != nil {
+			var schema *apiextensionsv1.JSONSchemaProps
+			if next.Schema != nil {
+				schema = next.Schema.OpenAPIV3Schema
+			}
+			if schema == nil {
+				c.report(prefix, "schema removed")
+				continue
+			}
+			c.compareSchema(prefix+": ", "", version.Schema.OpenAPIV3Schema, schema)
+		}
+	}
+	return c.breaks
+}
+
+type comparison struct {
+	breaks []string
+}
+
+func (c *comparison) report(where, format string, args ...interface{}) {
+	c.breaks = append(c.breaks, where+": "+fmt.Sprintf(format, args...))
+}
+
+// compareSchema compares the schema of one field and its children; path is empty for the root
+func (c *comparison) compareSchema(prefix, path string, released, current *apiextensionsv1.JSONSchemaProps) {
+	where := prefix + path
+	if path == "" {
+		where = strings.TrimSuffix(prefix, ": ")
+	}
+	report := func(format string, args ...interface{}) { c.report(where, format, args...) }
+
+	// Types
+	if released.Type != "" && current.Type != "" && released.Type != current.Type {
+		report("type changed from %s to %s", released.Type, current.Type)
+	}
+	if released.XIntOrString && !current.XIntOrString {
+		report("no longer accepts both integers and strings")
+	}
+	if released.Nullable && !current.Nullable {
+		report("no longer nullable")
+	}
+	if isTrue(released.XPreserveUnknownFields) && !isTrue(current.XPreserveUnknownFields) {
+		report("unknown fields are no longer preserved")
+	}
+	if released.XEmbeddedResource != current.XEmbeddedResource {
+		report("x-kubernetes-embedded-resource changed to %t", current.XEmbeddedResource)
+	}
+
+	// Value validation
+	if current.Format != "" && current.Format != released.Format {
+		report("format changed from %q to %q", released.Format, current.Format)
+	}
+	if current.Pattern != "" && current.Pattern != released.Pattern {
+		report("pattern changed from %q to %q", released.Pattern, current.Pattern)
+	}
+	if tighterMinimum(released, current) {
+		report("minimum raised from %s to %s", bound(released.Minimum, released.ExclusiveMinimum), bound(current.Minimum, current.ExclusiveMinimum))
+	}
+	if tighterMaximum(released, current) {
+		report("maximum lowered from %s to %s", bound(released.Maximum, released.ExclusiveMaximum), bound(current.Maximum, current.ExclusiveMaximum))
+	}
+	for _, limit := range []struct {
+		name              string
+		released, current *int64
+		isMin             bool
+	}{
+		{"minLength", released.MinLength, current.MinLength, true},
+		{"maxLength", released.MaxLength, current.MaxLength, false},
+		{"minItems", released.MinItems, current.MinItems, true},
+		{"maxItems", released.MaxItems, current.MaxItems, false},
+		{"minProperties", released.MinProperties, current.MinProperties, true},
+		{"maxProperties", released.MaxProperties, current.MaxProperties, false},
+	} {
+		if tighterLimit(limit.released, limit.current, limit.isMin) {
+			report("%s changed from %s to %d", limit.name, limitString(limit.released), *limit.current)
+		}
+	}
+	if len(current.Enum) > 0 {
+		if len(released.Enum) == 0 {
+			report("enum added")
+		} else {
+			values := map[string]bool{}
+			for _, value := range current.Enum {
+				values[string(value.Raw)] = true
+			}
+			for _, value := range released.Enum {
+				if !values[string(value.Raw)] {
+					report("enum value %s removed", value.Raw)
+				}
+			}
+		}
+	}
+	for _, rule := range current.XValidations {
+		if !hasRule(released.XValidations, rule.Rule) {
+			report("validation rule %q added", rule.Rule)
+		}
+	}
+
+	// Structure
+	if released.XListType != nil || current.XListType != nil {
+		from, to := stringValue(released.XListType, "atomic"), stringValue(current.XListType, "atomic")
+		if from != to && to != "atomic" {
+			report("list type changed from %s to %s", from, to)
+		}
+	}
+	if strings.Join(released.XListMapKeys, ",") != strings.Join(current.XListMapKeys, ",") && len(current.XListMapKeys) > 0 {
+		report("list map keys changed from %v to %v", released.XListMapKeys, current.XListMapKeys)
+	}
+	if released.XMapType != nil || current.XMapType != nil {
+		from, to := stringValue(released.XMapType, "granular"), stringValue(current.XMapType, "granular")
+		if from != to {
+			report("map type changed from %s to %s", from, to)
+		}
+	}
+	for _, required := range current.Required {
+		if !contains(released.Required, required) {
+			c.report(prefix+join(path, required), "field is now required")
+		}
+	}
+
+	// Children
+	for _, name := range sortedKeys(released.Properties) {
+		child := released.Properties[name]
+		next, ok := current.Properties[name]
+		if !ok {
+			if !isTrue(current.XPreserveUnknownFields) {
+				c.report(prefix+join(path, name), "field removed")
+			}
+			continue
+		}
+		c.compareSchema(prefix, join(path, name), &child, &next)
+	}
+	if released.Items != nil && released.Items.Schema != nil {
+		if current.Items == nil || current.Items.Schema == nil {
+			report("items schema removed")
+		} else {
+			c.compareSchema(prefix, path+"[*]", released.Items.Schema, current.Items.Schema)
+		}
+	}
+	if released.AdditionalProperties != nil && (released.AdditionalProperties.Allows || released.AdditionalProperties.Schema != nil) {
+		switch {
+		case current.AdditionalProperties == nil || (!current.AdditionalProperties.Allows && current.AdditionalProperties.Schema == nil):
+			report("no longer accepts arbitrary keys")
+		case released.AdditionalProperties.Schema != nil && current.AdditionalProperties.Schema != nil:
+			c.compareSchema(prefix, path+"[*]", released.AdditionalProperties.Schema, current.AdditionalProperties.Schema)
+		}
+	}
+}
+
+func tighterMinimum(released, current *apiextensionsv1.JSONSchemaProps) bool {
+	switch {
+	case current.Minimum == nil:
+		return false
+	case released.Minimum == nil || *current.Minimum > *released.Minimum:
+		return true
+	default:
+		return *current.Minimum == *released.Minimum && current.ExclusiveMinimum && !released.ExclusiveMinimum
+	}
+}
+
+func tighterMaximum(released, current *apiextensionsv1.JSONSchemaProps) bool {
+	switch {
+	case current.Maximum == nil:
+		return false
+	case released.Maximum == nil || *current.Maximum < *released.Maximum:
+		return true
+	default:
+		return *current.Maximum == *released.Maximum && current.ExclusiveMaximum && !released.ExclusiveMaximum
+	}
+}
+
+// tighterLimit reports whether a length, item or property count limit excludes values the
+// released one allowed
+func tighterLimit(released, current *int64, isMin bool) bool {
+	switch {
+	case current == nil:
+		return false
+	case released == nil:
+		return !isMin || *current > 0
+	case isMin:
+		return *current > *released
+	default:
+		return *current < *released
+	}
+}
+
+func bound(value *float64, exclusive bool) string {
+	switch {
+	case value == nil:
+		return "none"
+	case exclusive:
+		return fmt.Sprintf("%g (exclusive)", *value)
+	default:
+		return fmt.Sprintf("%g", *value)
+	}
+}
+
+func limitString(value *int64) string {
+	if value == nil {
+		return "none"
+	}
+	return fmt.Sprint(*value)
+}
+
+func findVersion(crd *apiextensionsv1.CustomResourceDefinition, name string) *apiextensionsv1.CustomResourceDefinitionVersion {
+	for i := range crd.Spec.Versions {
+		if crd.Spec.Versions[i].Name == name {
+			return &crd.Spec.Versions[i]
+		}
+	}
+	return nil
+}
+
+func hasRule(rules apiextensionsv1.ValidationRules, rule string) bool {
+	for _, r := range rules {
+		if r.Rule == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func sortedKeys(properties map[string]apiextensionsv1.JSONSchemaProps) []string {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func isTrue(value *bool) bool {
+	return value != nil && *value
+}
+
+func stringValue(value *string, fallback string) string {
+	if value == nil || *value == "" {
+		return fallback
+	}
+	return *value
+}
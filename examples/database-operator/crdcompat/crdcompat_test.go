@@ -0,0 +1,183 @@
+package crdcompat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// TestReleasedCRDs fails when the generated CRDs would break users of the last release
+func TestReleasedCRDs(t *testing.T) {
+	released, err := LoadDir("testdata/released")
+	require.NoError(t, err)
+	require.NotEmpty(t, released)
+	current, err := LoadDir("../config/crd/bases")
+	require.NoError(t, err)
+
+	for _, change := range CompareAll(released, current) {
+		t.Errorf("incompatible with the released CRD: %s", change)
+	}
+}
+
+func widgetCRD() *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.com"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "example.com",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: "Widget", Plural: "widgets", ShortNames: []string{"wd"}},
+			Scope: apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{
+				Name:         "v1",
+				Served:       true,
+				Storage:      true,
+				Subresources: &apiextensionsv1.CustomResourceSubresources{Status: &apiextensionsv1.CustomResourceSubresourceStatus{}},
+				Schema: &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+					Type: "object",
+					Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"spec": {
+							Type:     "object",
+							Required: []string{"size"},
+							Properties: map[string]apiextensionsv1.JSONSchemaProps{
+								"size":   {Type: "integer", Minimum: ptr.To(1.0), Maximum: ptr.To(10.0)},
+								"color":  {Type: "string", Enum: []apiextensionsv1.JSON{{Raw: []byte(`"red"`)}, {Raw: []byte(`"blue"`)}}},
+								"name":   {Type: "string", MaxLength: ptr.To[int64](63)},
+								"labels": {Type: "object", AdditionalProperties: &apiextensionsv1.JSONSchemaPropsOrBool{Allows: true, Schema: &apiextensionsv1.JSONSchemaProps{Type: "string"}}},
+								"ports": {Type: "array", Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &apiextensionsv1.JSONSchemaProps{
+									Type:       "object",
+									Properties: map[string]apiextensionsv1.JSONSchemaProps{"port": {Type: "integer"}},
+								}}},
+							},
+						},
+					},
+				}},
+			}},
+		},
+	}
+}
+
+func spec(crd *apiextensionsv1.CustomResourceDefinition) *apiextensionsv1.JSONSchemaProps {
+	s := crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"]
+	return &s
+}
+
+func setSpec(crd *apiextensionsv1.CustomResourceDefinition, s *apiextensionsv1.JSONSchemaProps) {
+	crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"] = *s
+}
+
+// setField changes one field of the spec
+func setField(crd *apiextensionsv1.CustomResourceDefinition, name string, change func(*apiextensionsv1.JSONSchemaProps)) {
+	s := spec(crd)
+	field := s.Properties[name]
+	change(&field)
+	s.Properties[name] = field
+	setSpec(crd, s)
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*apiextensionsv1.CustomResourceDefinition)
+		want   []string
+	}{
+		{
+			name:   "unchanged",
+			change: func(*apiextensionsv1.CustomResourceDefinition) {},
+		},
+		{
+			name: "compatible changes",
+			change: func(crd *apiextensionsv1.CustomResourceDefinition) {
+				setField(crd, "size", func(f *apiextensionsv1.JSONSchemaProps) { f.Maximum = ptr.To(100.0) })
+				setField(crd, "color", func(f *apiextensionsv1.JSONSchemaProps) {
+					f.Enum = append(f.Enum, apiextensionsv1.JSON{Raw: []byte(`"green"`)})
+				})
+				setField(crd, "name", func(f *apiextensionsv1.JSONSchemaProps) { f.MaxLength = nil })
+				s := spec(crd)
+				s.Properties["shape"] = apiextensionsv1.JSONSchemaProps{Type: "string", MinLength: ptr.To[int64](1)}
+				setSpec(crd, s)
+				crd.Spec.Versions = append(crd.Spec.Versions, apiextensionsv1.CustomResourceDefinitionVersion{Name: "v2", Served: true})
+			},
+		},
+		{
+			name: "removed and retyped fields",
+			change: func(crd *apiextensionsv1.CustomResourceDefinition) {
+				s := spec(crd)
+				delete(s.Properties, "name")
+				setSpec(crd, s)
+				setField(crd, "size", func(f *apiextensionsv1.JSONSchemaProps) { f.Type = "string" })
+				setField(crd, "ports", func(f *apiextensionsv1.JSONSchemaProps) {
+					f.Items.Schema.Properties["port"] = apiextensionsv1.JSONSchemaProps{Type: "string"}
+				})
+			},
+			want: []string{
+				"widgets.example.com v1: spec.name: field removed",
+				"widgets.example.com v1: spec.ports[*].port: type changed from integer to string",
+				"widgets.example.com v1: spec.size: type changed from integer to string",
+			},
+		},
+		{
+			name: "tightened validation",
+			change: func(crd *apiextensionsv1.CustomResourceDefinition) {
+				s := spec(crd)
+				s.Required = append(s.Required, "color")
+				setSpec(crd, s)
+				setField(crd, "size", func(f *apiextensionsv1.JSONSchemaProps) { f.Minimum, f.Maximum = ptr.To(2.0), ptr.To(9.0) })
+				setField(crd, "color", func(f *apiextensionsv1.JSONSchemaProps) { f.Enum = f.Enum[:1] })
+				setField(crd, "name", func(f *apiextensionsv1.JSONSchemaProps) {
+					f.MaxLength = ptr.To[int64](32)
+					f.Pattern = "^[a-z]+$"
+					f.XValidations = apiextensionsv1.ValidationRules{{Rule: "self != 'default'"}}
+				})
+				setField(crd, "labels", func(f *apiextensionsv1.JSONSchemaProps) { f.AdditionalProperties.Schema.MaxLength = ptr.To[int64](63) })
+				setField(crd, "ports", func(f *apiextensionsv1.JSONSchemaProps) {
+					f.XListType = ptr.To("map")
+					f.XListMapKeys = []string{"port"}
+				})
+			},
+			want: []string{
+				`widgets.example.com v1: spec.color: enum value "blue" removed`,
+				"widgets.example.com v1: spec.color: field is now required",
+				"widgets.example.com v1: spec.labels[*]: maxLength changed from none to 63",
+				`widgets.example.com v1: spec.name: pattern changed from "" to "^[a-z]+$"`,
+				"widgets.example.com v1: spec.name: maxLength changed from 63 to 32",
+				`widgets.example.com v1: spec.name: validation rule "self != 'default'" added`,
+				"widgets.example.com v1: spec.ports: list type changed from atomic to map",
+				"widgets.example.com v1: spec.ports: list map keys changed from [] to [port]",
+				"widgets.example.com v1: spec.size: minimum raised from 1 to 2",
+				"widgets.example.com v1: spec.size: maximum lowered from 10 to 9",
+			},
+		},
+		{
+			name: "removed version and subresource",
+			change: func(crd *apiextensionsv1.CustomResourceDefinition) {
+				crd.Spec.Scope = apiextensionsv1.ClusterScoped
+				crd.Spec.Names.ShortNames = nil
+				crd.Spec.Versions[0].Subresources = nil
+				crd.Spec.Versions = append(crd.Spec.Versions, crd.Spec.Versions[0])
+				crd.Spec.Versions[0].Served = false
+				crd.Spec.Versions[1].Name = "v2"
+			},
+			want: []string{
+				"widgets.example.com: scope changed from Namespaced to Cluster",
+				"widgets.example.com: short name wd removed",
+				"widgets.example.com v1: version no longer served",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := widgetCRD()
+			tt.change(current)
+			assert.ElementsMatch(t, tt.want, Compare(widgetCRD(), current))
+		})
+	}
+}
+
+func TestCompareAll(t *testing.T) {
+	released := map[string]*apiextensionsv1.CustomResourceDefinition{"widgets.example.com": widgetCRD()}
+	assert.Equal(t, []string{"widgets.example.com: removed"}, CompareAll(released, nil))
+	assert.Empty(t, CompareAll(released, released))
+}
@@ -0,0 +1,55 @@
+package crdcompat
+
+import (
+	"fmt"
+
+	"golang.org/x/tools/go/packages"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/controller-tools/pkg/crd"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// Generate builds the CRDs of the API types in the packages matching paths from their
+// markers, the way controller-gen does, by name. Tests use it to check what the markers
+// produce without depending on config/crd/bases being regenerated.
+func Generate(paths ...string) (map[string]*apiextensionsv1.CustomResourceDefinition, error) {
+	roots, err := loader.LoadRoots(paths...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages: %w", err)
+	}
+
+	registry := &markers.Registry{}
+	if err := (crd.Generator{}).RegisterMarkers(registry); err != nil {
+		return nil, err
+	}
+	parser := &crd.Parser{
+		Collector: &markers.Collector{Registry: registry},
+		Checker:   &loader.TypeChecker{NodeFilters: []loader.NodeFilter{(crd.Generator{}).CheckFilter()}},
+	}
+	crd.AddKnownTypes(parser)
+	for _, root := range roots {
+		parser.NeedPackage(root)
+	}
+	metav1Pkg := crd.FindMetav1(roots)
+	if metav1Pkg == nil {
+		return nil, fmt.Errorf("no API types found in %v", paths)
+	}
+
+	crds := map[string]*apiextensionsv1.CustomResourceDefinition{}
+	for _, groupKind := range crd.FindKubeKinds(parser, metav1Pkg) {
+		parser.NeedCRDFor(groupKind, nil)
+		generated := parser.CustomResourceDefinitions[groupKind]
+		crds[generated.Name] = &generated
+	}
+
+	// Type errors are expected: like controller-gen, only the API types are type checked
+	for _, root := range roots {
+		for _, err := range root.Errors {
+			if err.Kind != packages.TypeError {
+				return nil, fmt.Errorf("failed to parse markers in %s: %v", root.PkgPath, root.Errors)
+			}
+		}
+	}
+	return crds, nil
+}
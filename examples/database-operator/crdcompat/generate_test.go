@@ -0,0 +1,57 @@
+package crdcompat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func columnNames(crd *apiextensionsv1.CustomResourceDefinition) []string {
+	var names []string
+	for _, column := range crd.Spec.Versions[0].AdditionalPrinterColumns {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+func TestGenerate(t *testing.T) {
+	generated, err := Generate("../api/...")
+	require.NoError(t, err)
+	written, err := LoadDir("../config/crd/bases")
+	require.NoError(t, err)
+
+	// kubectl get output and resource names come from the markers
+	database := generated["databases.my.domain"]
+	require.NotNil(t, database)
+	assert.Equal(t, []string{"all", "databases"}, database.Spec.Names.Categories)
+	assert.Equal(t, []string{"db"}, database.Spec.Names.ShortNames)
+	assert.Equal(t, []string{"PHASE", "READY", "REASON", "PRIMARY", "AGE"}, columnNames(database))
+	assert.Equal(t, `.status.conditions[?(@.type=="Ready")].reason`, database.Spec.Versions[0].AdditionalPrinterColumns[2].JSONPath)
+	assert.Equal(t, int32(1), database.Spec.Versions[0].AdditionalPrinterColumns[3].Priority, "PRIMARY is only shown with -o wide")
+
+	// v1 is stored, and v2 is only served once the conversion webhook is
+	require.Len(t, database.Spec.Versions, 2)
+	assert.Equal(t, "v1", database.Spec.Versions[0].Name)
+	assert.True(t, database.Spec.Versions[0].Storage)
+	assert.Equal(t, "v2", database.Spec.Versions[1].Name)
+	assert.False(t, database.Spec.Versions[1].Served)
+	assert.Equal(t, database.Spec.Versions[0].AdditionalPrinterColumns, database.Spec.Versions[1].AdditionalPrinterColumns)
+
+	policy := generated["clusterdatabasepolicies.my.domain"]
+	require.NotNil(t, policy)
+	assert.Equal(t, []string{"databases"}, policy.Spec.Names.Categories)
+	assert.Equal(t, []string{"cdbp"}, policy.Spec.Names.ShortNames)
+	assert.Equal(t, []string{"NAMESPACES", "REASON", "AGE"}, columnNames(policy))
+
+	// The CRDs in config/crd/bases are regenerated from the same markers
+	require.Len(t, written, len(generated))
+	for name, crd := range generated {
+		require.Contains(t, written, name)
+		assert.Equal(t, crd.Spec.Names, written[name].Spec.Names, name)
+		for i, version := range crd.Spec.Versions {
+			assert.Equal(t, version.AdditionalPrinterColumns, written[name].Spec.Versions[i].AdditionalPrinterColumns, name)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Package dependency gates a resource on the conditions of other resources it references,
+// such as an Application on its Database.
+//
+// A Gate covers one kind of dependency for one kind of dependent. WaitFor reads the referenced
+// object, checks one of its conditions for its current generation, and records the outcome in
+// a WaitingFor<Kind> condition on the dependent. It never returns a requeue: SetupWithManager
+// indexes the dependents by the names they reference and watches the dependency kind, so every
+// change of a dependency, including its status, requeues the dependents waiting for it.
+//
+//	gate := &dependency.Gate{
+//		Kind:       "Database",
+//		Object:     &databasev1.Database{},
+//		Owner:      &databasev1.Application{},
+//		OwnerList:  &databasev1.ApplicationList{},
+//		References: func(o client.Object) []string { return []string{o.GetName() + "-db"} },
+//	}
+//	// in SetupWithManager, with b the builder of the Application controller
+//	err := gate.SetupWithManager(mgr, b)
+//	// in Reconcile
+//	ready, err := gate.WaitFor(ctx, app, &app.Status.Conditions, app.Name+"-db", "Ready")
+//
+// Conditions are read from status.conditions through the unstructured form, so any kind using
+// metav1.Condition works without an interface. A condition only counts for the generation it
+// was observed at: its own observedGeneration, or else status.observedGeneration, must match
+// metadata.generation when set, so a dependency that was just edited is waited for again.
+package dependency
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Reasons of the WaitingFor<Kind> condition besides the reasons passed through from the
+// condition of the dependency
+const (
+	// ReasonNotFound means the dependency does not exist
+	ReasonNotFound = "NotFound"
+
+	// ReasonPending means the dependency has not reported the condition for its current generation
+	ReasonPending = "Pending"
+
+	// ReasonSatisfied means the condition of the dependency is True
+	ReasonSatisfied = "Satisfied"
+)
+
+// Gate gates dependents of one kind on dependencies of another kind in their namespace
+type Gate struct {
+	// Kind names the dependency in the WaitingFor<Kind> condition and in messages, e.g. Database
+	Kind string
+
+	// Object is an empty object of the dependency type, read by WaitFor and watched
+	Object client.Object
+
+	// Owner and OwnerList are empty objects of the dependent type, indexed and listed to map a
+	// dependency back to the dependents referencing it
+	Owner     client.Object
+	OwnerList client.ObjectList
+
+	// References returns the names of the dependencies a dependent references
+	References func(owner client.Object) []string
+
+	// Reader reads the dependencies; SetupWithManager defaults it to the manager's client
+	Reader client.Reader
+}
+
+// ConditionType is the type of the condition WaitFor sets, e.g. WaitingForDatabase
+func (g *Gate) ConditionType() string {
+	return "WaitingFor" + g.Kind
+}
+
+// WaitFor reports whether the named dependency, in the namespace of the owner, has the
+// condition True for its current generation. The WaitingFor<Kind> condition in conditions is
+// set to True while waiting, with the reason and message of the dependency's condition when it
+// has one, and to False once the condition holds; the caller writes the status.
+func (g *Gate) WaitFor(ctx context.Context, owner client.Object, conditions *[]metav1.Condition, name, conditionType string) (bool, error) {
+	waiting := metav1.Condition{
+		Type:               g.ConditionType(),
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: owner.GetGeneration(),
+	}
+
+	object := g.Object.DeepCopyObject().(client.Object)
+	err := g.Reader.Get(ctx, types.NamespacedName{Name: name, Namespace: owner.GetNamespace()}, object)
+	if apierrors.IsNotFound(err) {
+		waiting.Reason = ReasonNotFound
+		waiting.Message = fmt.Sprintf("%s %s not found", g.Kind, name)
+		meta.SetStatusCondition(conditions, waiting)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get %s %s: %w", g.Kind, name, err)
+	}
+
+	condition, err := currentCondition(object, conditionType)
+	if err != nil {
+		return false, fmt.Errorf("failed to read the conditions of %s %s: %w", g.Kind, name, err)
+	}
+	switch {
+	case condition == nil:
+		waiting.Reason = ReasonPending
+		waiting.Message = fmt.Sprintf("%s %s has not reported %s yet", g.Kind, name, conditionType)
+	case condition.Status != metav1.ConditionTrue:
+		waiting.Reason = condition.Reason
+		waiting.Message = fmt.Sprintf("%s %s is not %s: %s", g.Kind, name, conditionType, condition.Message)
+	default:
+		waiting.Status = metav1.ConditionFalse
+		waiting.Reason = ReasonSatisfied
+		waiting.Message = fmt.Sprintf("%s %s is %s", g.Kind, name, conditionType)
+	}
+	meta.SetStatusCondition(conditions, waiting)
+	return waiting.Status == metav1.ConditionFalse, nil
+}
+
+// currentCondition returns the condition of the given type of the object, or nil when the
+// object has none or observed an older generation
+func currentCondition(object client.Object, conditionType string) (*metav1.Condition, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+	status, _, err := unstructured.NestedMap(content, "status")
+	if err != nil || status == nil {
+		return nil, err
+	}
+	var parsed struct {
+		ObservedGeneration int64              `json:"observedGeneration"`
+		Conditions         []metav1.Condition `json:"conditions"`
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(status, &parsed); err != nil {
+		return nil, err
+	}
+
+	condition := meta.FindStatusCondition(parsed.Conditions, conditionType)
+	if condition == nil {
+		return nil, nil
+	}
+	observed := condition.ObservedGeneration
+	if observed == 0 {
+		observed = parsed.ObservedGeneration
+	}
+	if observed != 0 && observed != object.GetGeneration() {
+		return nil, nil
+	}
+	return condition, nil
+}
+
+// Index returns the field index of the dependents by the dependencies they reference, to
+// register on fake clients in tests
+func (g *Gate) Index() (string, client.IndexerFunc) {
+	return ".dependencies." + strings.ToLower(g.Kind), g.References
+}
+
+// findOwners maps a dependency to the dependents in its namespace that reference it
+func (g *Gate) findOwners(ctx context.Context, o client.Object) []reconcile.Request {
+	list := g.OwnerList.DeepCopyObject().(client.ObjectList)
+	field, _ := g.Index()
+	// Only the names are read, so the cached dependents are not copied
+	err := g.Reader.List(ctx, list, client.InNamespace(o.GetNamespace()), client.MatchingFields{field: o.GetName()},
+		client.UnsafeDisableDeepCopy)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to list dependents", "kind", g.Kind, "name", o.GetName())
+		return nil
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to list dependents", "kind", g.Kind, "name", o.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(items))
+	for _, item := range items {
+		owner := item.(client.Object)
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: owner.GetName(), Namespace: owner.GetNamespace()},
+		})
+	}
+	return requests
+}
+
+// SetupWithManager indexes the dependents by the dependencies they reference and adds a watch
+// of the dependency kind to the dependent's controller, requeueing the dependents on every
+// change of a dependency they reference
+func (g *Gate) SetupWithManager(mgr ctrl.Manager, b *builder.Builder) error {
+	if g.Reader == nil {
+		g.Reader = mgr.GetClient()
+	}
+	field, extract := g.Index()
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), g.Owner, field, extract); err != nil {
+		return err
+	}
+	b.Watches(g.Object, handler.EnqueueRequestsFromMapFunc(g.findOwners))
+	return nil
+}
@@ -0,0 +1,144 @@
+package dependency
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// The tests gate Pods on the Available condition of Deployments, which carry the dependency
+// in an annotation: any kinds with metav1-style conditions work the same way
+const dependsOnAnnotation = "example.com/depends-on"
+
+func newGate(t *testing.T, objects ...client.Object) *Gate {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	gate := &Gate{
+		Kind:      "Deployment",
+		Object:    &appsv1.Deployment{},
+		Owner:     &corev1.Pod{},
+		OwnerList: &corev1.PodList{},
+		References: func(o client.Object) []string {
+			if name := o.GetAnnotations()[dependsOnAnnotation]; name != "" {
+				return []string{name}
+			}
+			return nil
+		},
+	}
+	field, extract := gate.Index()
+	gate.Reader = fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithIndex(&corev1.Pod{}, field, extract).
+		Build()
+	return gate
+}
+
+func newDependency(generation, observed int64, status metav1.ConditionStatus) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "backend", Namespace: "default", Generation: generation},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: observed,
+			Conditions: []appsv1.DeploymentCondition{{
+				Type:    appsv1.DeploymentAvailable,
+				Status:  corev1.ConditionStatus(status),
+				Reason:  "MinimumReplicasUnavailable",
+				Message: "Deployment does not have minimum availability.",
+			}},
+		},
+	}
+}
+
+func newDependent(name, dependsOn string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: name, Namespace: "default", Generation: 3,
+		Annotations: map[string]string{dependsOnAnnotation: dependsOn},
+	}}
+}
+
+func TestGate_WaitFor(t *testing.T) {
+	tests := []struct {
+		name       string
+		dependency *appsv1.Deployment
+		ready      bool
+		reason     string
+		message    string
+	}{
+		{
+			name:    "missing",
+			reason:  ReasonNotFound,
+			message: "Deployment backend not found",
+		},
+		{
+			name:       "condition false",
+			dependency: newDependency(2, 2, metav1.ConditionFalse),
+			reason:     "MinimumReplicasUnavailable",
+			message:    "Deployment backend is not Available: Deployment does not have minimum availability.",
+		},
+		{
+			name:       "older generation",
+			dependency: newDependency(2, 1, metav1.ConditionTrue),
+			reason:     ReasonPending,
+			message:    "Deployment backend has not reported Available yet",
+		},
+		{
+			name:       "condition true",
+			dependency: newDependency(2, 2, metav1.ConditionTrue),
+			ready:      true,
+			reason:     ReasonSatisfied,
+			message:    "Deployment backend is Available",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objects []client.Object
+			if tt.dependency != nil {
+				objects = append(objects, tt.dependency)
+			}
+			gate := newGate(t, objects...)
+			owner := newDependent("frontend", "backend")
+
+			var conditions []metav1.Condition
+			ready, err := gate.WaitFor(context.Background(), owner, &conditions, "backend", string(appsv1.DeploymentAvailable))
+			require.NoError(t, err)
+			assert.Equal(t, tt.ready, ready)
+
+			waiting := meta.FindStatusCondition(conditions, "WaitingForDeployment")
+			require.NotNil(t, waiting)
+			assert.Equal(t, !tt.ready, waiting.Status == metav1.ConditionTrue)
+			assert.Equal(t, tt.reason, waiting.Reason)
+			assert.Equal(t, tt.message, waiting.Message)
+			assert.Equal(t, int64(3), waiting.ObservedGeneration)
+		})
+	}
+}
+
+func TestGate_FindOwners(t *testing.T) {
+	other := newDependent("other", "backend")
+	other.Namespace = "staging"
+	gate := newGate(t,
+		newDependent("frontend", "backend"),
+		newDependent("worker", "backend"),
+		newDependent("admin", "auth"),
+		other,
+	)
+
+	requests := gate.findOwners(context.Background(), newDependency(1, 1, metav1.ConditionTrue))
+	assert.ElementsMatch(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "frontend", Namespace: "default"}},
+		{NamespacedName: types.NamespacedName{Name: "worker", Namespace: "default"}},
+	}, requests, "Only dependents referencing the dependency in its namespace are requeued")
+}
@@ -0,0 +1,153 @@
+// Command bundle generates the OLM bundle for the database operator:
+//
+//	bundle/
+//	├── manifests/
+//	│   ├── database-operator.clusterserviceversion.yaml
+//	│   └── my.domain_databases.yaml
+//	└── metadata/
+//	    └── annotations.yaml
+//
+// Run it from examples/database-operator after regenerating the CRDs:
+//
+//	go run ./hack/bundle --image registry.example.com/database-operator:v0.3.0
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+	"sigs.k8s.io/controller-tools/pkg/rbac"
+	"sigs.k8s.io/yaml"
+
+	"your.domain/project/olm"
+)
+
+func main() {
+	var (
+		image         string
+		databaseImage string
+		crdDir        string
+		outputDir     string
+	)
+	flag.StringVar(&image, "image", "", "Operator image referenced by the bundle.")
+	flag.StringVar(&databaseImage, "database-image", "docker.io/library/postgres:15",
+		"Default database image, listed as a related image for disconnected installs.")
+	flag.StringVar(&crdDir, "crd-dir", "config/crd/bases", "Directory with the generated CRD manifests.")
+	flag.StringVar(&outputDir, "output-dir", "bundle", "Directory the bundle is written to.")
+	flag.Parse()
+
+	if err := run(image, databaseImage, crdDir, outputDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(image, databaseImage, crdDir, outputDir string) error {
+	rules, err := rbacRules("./controllers/...")
+	if err != nil {
+		return err
+	}
+
+	csv, err := olm.NewClusterServiceVersion(olm.Options{
+		Image:         image,
+		RelatedImages: []olm.RelatedImage{{Name: "postgres", Image: databaseImage}},
+		Rules:         rules,
+	})
+	if err != nil {
+		return err
+	}
+
+	manifests := filepath.Join(outputDir, "manifests")
+	metadata := filepath.Join(outputDir, "metadata")
+	for _, dir := range []string{manifests, metadata} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	if err := writeYAML(filepath.Join(manifests, olm.PackageName+".clusterserviceversion.yaml"), csv); err != nil {
+		return err
+	}
+	if err := writeYAML(filepath.Join(metadata, "annotations.yaml"),
+		map[string]interface{}{"annotations": olm.BundleAnnotations()}); err != nil {
+		return err
+	}
+
+	// OLM installs the CRDs from the bundle, so they are shipped as generated
+	crds, err := filepath.Glob(filepath.Join(crdDir, "*.yaml"))
+	if err != nil {
+		return err
+	}
+	if len(crds) == 0 {
+		return fmt.Errorf("no CRDs found in %s", crdDir)
+	}
+	for _, crd := range crds {
+		content, err := os.ReadFile(crd)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(manifests, filepath.Base(crd)), content, 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rbacRules collects the cluster-wide rules from the +kubebuilder:rbac markers,
+// the same markers controller-gen turns into config/rbac/role.yaml
+func rbacRules(packages ...string) ([]rbacv1.PolicyRule, error) {
+	roots, err := loader.LoadRoots(packages...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages: %w", err)
+	}
+
+	registry := &markers.Registry{}
+	if err := (rbac.Generator{}).RegisterMarkers(registry); err != nil {
+		return nil, err
+	}
+
+	roles, err := rbac.GenerateRoles(&genall.GenerationContext{
+		Collector: &markers.Collector{Registry: registry},
+		Roots:     roots,
+		Checker:   &loader.TypeChecker{},
+	}, olm.PackageName)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []rbacv1.PolicyRule
+	for _, role := range roles {
+		switch role := role.(type) {
+		case rbacv1.ClusterRole:
+			rules = append(rules, role.Rules...)
+		case rbacv1.Role:
+			// Namespaced permissions would go to the CSV's permissions; the operator has none
+			return nil, fmt.Errorf("namespaced RBAC marker for namespace %s is not supported", role.Namespace)
+		}
+	}
+
+	for _, root := range roots {
+		if len(root.Errors) > 0 {
+			return nil, fmt.Errorf("failed to parse markers in %s: %v", root.PkgPath, root.Errors)
+		}
+	}
+	return rules, nil
+}
+
+func writeYAML(path string, obj interface{}) error {
+	content, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	// Drop the empty creationTimestamp metav1.ObjectMeta always marshals
+	content = []byte(strings.ReplaceAll(string(content), "  creationTimestamp: null\n", ""))
+	return os.WriteFile(path, content, 0o644)
+}
@@ -0,0 +1,137 @@
+// Package health computes the kstatus health of the operator's resources: Current,
+// InProgress, Failed or Terminating, from the deletion timestamp, the observed generation and
+// the Reconciling, Stalled, Degraded and Ready conditions. The API package exports
+// ComputeHealth for each type on top of it, so kubectl plugins, tests and the operator's own
+// diagnostics agree on what a healthy Database is.
+//
+// Conditions have a polarity. Ready is normal-true: True is the healthy state. Reconciling,
+// Stalled, Degraded and the WaitingFor<Kind> conditions of dependency gates are abnormal-true,
+// or negative polarity: True reports a problem, and they are best left False, or absent,
+// when there is none. IsAbnormal applies the right polarity to any condition, so code and
+// dashboards do not have to remember which way each type points.
+package health
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Status is the health of a resource, with the values kstatus uses
+type Status string
+
+const (
+	// InProgress means the resource is not yet in its desired state, and is expected to get there
+	InProgress Status = "InProgress"
+
+	// Failed means the resource will not get to its desired state without a change
+	Failed Status = "Failed"
+
+	// Current means the resource is in its desired state
+	Current Status = "Current"
+
+	// Terminating means the resource is being deleted
+	Terminating Status = "Terminating"
+)
+
+// Condition types with a meaning in kstatus
+const (
+	ConditionReady       = "Ready"
+	ConditionReconciling = "Reconciling"
+	ConditionStalled     = "Stalled"
+
+	// ConditionDegraded is True while a resource works with reduced capacity or redundancy,
+	// e.g. with only part of its replicas ready for a prolonged period
+	ConditionDegraded = "Degraded"
+)
+
+// negativePolarity are the condition types whose True status is abnormal
+var negativePolarity = map[string]bool{
+	ConditionReconciling: true,
+	ConditionStalled:     true,
+	ConditionDegraded:    true,
+}
+
+// IsNegativePolarity reports whether True is the abnormal status of conditions of the type
+func IsNegativePolarity(conditionType string) bool {
+	return negativePolarity[conditionType] || strings.HasPrefix(conditionType, "WaitingFor")
+}
+
+// IsAbnormal reports whether a condition reports a problem: True for negative polarity types,
+// anything but True, including Unknown, for the others
+func IsAbnormal(condition metav1.Condition) bool {
+	if IsNegativePolarity(condition.Type) {
+		return condition.Status == metav1.ConditionTrue
+	}
+	return condition.Status != metav1.ConditionTrue
+}
+
+// IsStalled reports whether the conditions hold a True Stalled condition
+func IsStalled(conditions []metav1.Condition) bool {
+	return meta.IsStatusConditionTrue(conditions, ConditionStalled)
+}
+
+// IsDegraded reports whether the conditions hold a True Degraded condition
+func IsDegraded(conditions []metav1.Condition) bool {
+	return meta.IsStatusConditionTrue(conditions, ConditionDegraded)
+}
+
+// Result is the health of a resource and why
+type Result struct {
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Input is what Compute needs of a resource
+type Input struct {
+	Object metav1.Object
+
+	// ObservedGeneration is the generation the status describes; nil for types that do not
+	// record it
+	ObservedGeneration *int64
+
+	Conditions []metav1.Condition
+}
+
+// Compute returns the health of a resource
+func Compute(in Input) Result {
+	if in.Object.GetDeletionTimestamp() != nil {
+		return Result{Status: Terminating, Message: "Resource scheduled for deletion"}
+	}
+
+	if in.ObservedGeneration != nil && *in.ObservedGeneration < in.Object.GetGeneration() {
+		return Result{Status: InProgress, Message: fmt.Sprintf("Generation is %d, but latest observed generation is %d",
+			in.Object.GetGeneration(), *in.ObservedGeneration)}
+	}
+
+	if condition := meta.FindStatusCondition(in.Conditions, ConditionReconciling); condition != nil &&
+		condition.Status == metav1.ConditionTrue {
+		return Result{Status: InProgress, Message: describe(condition)}
+	}
+	if condition := meta.FindStatusCondition(in.Conditions, ConditionStalled); condition != nil &&
+		condition.Status == metav1.ConditionTrue {
+		return Result{Status: Failed, Message: describe(condition)}
+	}
+	// A degraded resource is usually not Ready either; its message says more than Ready's
+	if condition := meta.FindStatusCondition(in.Conditions, ConditionDegraded); condition != nil &&
+		condition.Status == metav1.ConditionTrue {
+		return Result{Status: InProgress, Message: describe(condition)}
+	}
+	if condition := meta.FindStatusCondition(in.Conditions, ConditionReady); condition != nil {
+		if condition.Status != metav1.ConditionTrue {
+			return Result{Status: InProgress, Message: describe(condition)}
+		}
+		return Result{Status: Current, Message: describe(condition)}
+	}
+	return Result{Status: Current, Message: "Resource is current"}
+}
+
+// describe returns the message of a condition, or its reason if it has none
+func describe(condition *metav1.Condition) string {
+	if condition.Message != "" {
+		return condition.Message
+	}
+	return fmt.Sprintf("%s: %s", condition.Type, condition.Reason)
+}
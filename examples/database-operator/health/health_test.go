@@ -0,0 +1,129 @@
+package health
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCompute(t *testing.T) {
+	now := metav1.Now()
+	ready := metav1.Condition{Type: ConditionReady, Status: metav1.ConditionTrue, Reason: "Ready", Message: "Database is ready"}
+	notReady := metav1.Condition{Type: ConditionReady, Status: metav1.ConditionFalse, Reason: "Progressing", Message: "Waiting for replicas: 1/3"}
+	stalled := metav1.Condition{Type: ConditionStalled, Status: metav1.ConditionTrue, Reason: "InvalidSpec"}
+	reconciling := metav1.Condition{Type: ConditionReconciling, Status: metav1.ConditionTrue, Reason: "Scaling", Message: "Scaling up"}
+	degraded := metav1.Condition{Type: ConditionDegraded, Status: metav1.ConditionTrue, Reason: "PartiallyReady", Message: "1/3 replicas ready for 10m"}
+	generation := func(observed int64) *int64 { return &observed }
+
+	tests := []struct {
+		name       string
+		meta       metav1.ObjectMeta
+		observed   *int64
+		conditions []metav1.Condition
+		want       Result
+	}{
+		{
+			name:       "ready",
+			meta:       metav1.ObjectMeta{Generation: 2},
+			observed:   generation(2),
+			conditions: []metav1.Condition{ready},
+			want:       Result{Status: Current, Message: "Database is ready"},
+		},
+		{
+			name:       "deleted",
+			meta:       metav1.ObjectMeta{Generation: 2, DeletionTimestamp: &now},
+			observed:   generation(1),
+			conditions: []metav1.Condition{stalled},
+			want:       Result{Status: Terminating, Message: "Resource scheduled for deletion"},
+		},
+		{
+			name:       "spec not observed yet",
+			meta:       metav1.ObjectMeta{Generation: 3},
+			observed:   generation(2),
+			conditions: []metav1.Condition{ready},
+			want:       Result{Status: InProgress, Message: "Generation is 3, but latest observed generation is 2"},
+		},
+		{
+			name:       "type without observed generation",
+			meta:       metav1.ObjectMeta{Generation: 3},
+			conditions: []metav1.Condition{ready},
+			want:       Result{Status: Current, Message: "Database is ready"},
+		},
+		{
+			name:       "reconciling",
+			meta:       metav1.ObjectMeta{Generation: 1},
+			observed:   generation(1),
+			conditions: []metav1.Condition{ready, reconciling},
+			want:       Result{Status: InProgress, Message: "Scaling up"},
+		},
+		{
+			name:       "stalled",
+			meta:       metav1.ObjectMeta{Generation: 1},
+			observed:   generation(1),
+			conditions: []metav1.Condition{notReady, stalled},
+			want:       Result{Status: Failed, Message: "Stalled: InvalidSpec"},
+		},
+		{
+			name:       "degraded",
+			meta:       metav1.ObjectMeta{Generation: 1},
+			observed:   generation(1),
+			conditions: []metav1.Condition{notReady, degraded},
+			want:       Result{Status: InProgress, Message: "1/3 replicas ready for 10m"},
+		},
+		{
+			name:       "stalled and degraded",
+			meta:       metav1.ObjectMeta{Generation: 1},
+			observed:   generation(1),
+			conditions: []metav1.Condition{notReady, degraded, stalled},
+			want:       Result{Status: Failed, Message: "Stalled: InvalidSpec"},
+		},
+		{
+			name:       "not ready",
+			meta:       metav1.ObjectMeta{Generation: 1},
+			observed:   generation(1),
+			conditions: []metav1.Condition{notReady},
+			want:       Result{Status: InProgress, Message: "Waiting for replicas: 1/3"},
+		},
+		{
+			name:     "no conditions",
+			meta:     metav1.ObjectMeta{Generation: 1},
+			observed: generation(1),
+			want:     Result{Status: Current, Message: "Resource is current"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := tt.meta
+			got := Compute(Input{Object: &meta, ObservedGeneration: tt.observed, Conditions: tt.conditions})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPolarity(t *testing.T) {
+	tests := []struct {
+		condition metav1.Condition
+		abnormal  bool
+	}{
+		{metav1.Condition{Type: ConditionReady, Status: metav1.ConditionTrue}, false},
+		{metav1.Condition{Type: ConditionReady, Status: metav1.ConditionFalse}, true},
+		{metav1.Condition{Type: ConditionReady, Status: metav1.ConditionUnknown}, true},
+		{metav1.Condition{Type: ConditionDegraded, Status: metav1.ConditionTrue}, true},
+		{metav1.Condition{Type: ConditionDegraded, Status: metav1.ConditionFalse}, false},
+		{metav1.Condition{Type: ConditionStalled, Status: metav1.ConditionUnknown}, false},
+		{metav1.Condition{Type: "WaitingForDatabase", Status: metav1.ConditionTrue}, true},
+		{metav1.Condition{Type: "Converged", Status: metav1.ConditionFalse}, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.abnormal, IsAbnormal(tt.condition), "%s=%s", tt.condition.Type, tt.condition.Status)
+	}
+
+	conditions := []metav1.Condition{
+		{Type: ConditionDegraded, Status: metav1.ConditionTrue},
+		{Type: ConditionStalled, Status: metav1.ConditionFalse},
+	}
+	assert.True(t, IsDegraded(conditions))
+	assert.False(t, IsStalled(conditions))
+	assert.False(t, IsDegraded(nil), "An absent negative polarity condition is normal")
+}
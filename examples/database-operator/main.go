@@ -0,0 +1,491 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, AWS, GCP, etc.)
+	// to ensure that exec-entrypoint and run can make use of them.
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	// PostgreSQL driver for the statistics collector
+	_ "github.com/lib/pq"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	databasev1 "your.domain/project/api/v1"
+	databasev2 "your.domain/project/api/v2"
+	"your.domain/project/apiclient"
+	"your.domain/project/controllers"
+	"your.domain/project/plugins"
+	"your.domain/project/sealing"
+	//+kubebuilder:scaffold:imports
+)
+
+var (
+	scheme   = runtime.NewScheme()
+	setupLog = ctrl.Log.WithName("setup")
+)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+
+	utilruntime.Must(databasev1.AddToScheme(scheme))
+	utilruntime.Must(databasev2.AddToScheme(scheme))
+	//+kubebuilder:scaffold:scheme
+}
+
+func main() {
+	var metricsAddr string
+	var enableLeaderElection bool
+	var probeAddr string
+
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
+		"Enable leader election for controller manager. "+
+			"Enabling this will ensure there is only one active controller manager.")
+
+	// Operator-wide resync intervals; individual Databases may override them in spec.requeuePolicy
+	requeuePolicy := controllers.DefaultRequeuePolicy
+	requeuePolicy.BindFlags(flag.CommandLine)
+
+	// API server client tuning; see restOptions for why the defaults differ from client-go's
+	clientOptions := defaultRESTOptions
+	clientOptions.BindFlags(flag.CommandLine)
+
+	var watchNamespaces string
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma-separated namespaces whose Databases are reconciled. Empty watches all namespaces.")
+
+	var enableWebhooks bool
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the Database defaulting, validating and conversion webhooks and the database pod policy webhook. Requires webhook certificates.")
+
+	// Cluster-wide defaults (image registry, storage class, resources) maintained by the cluster admin
+	defaultsSource := controllers.DefaultsSource{
+		Namespace: "database-operator-system",
+		Name:      "database-operator-defaults",
+	}
+	defaultsSource.BindFlags(flag.CommandLine)
+
+	// CEL policies every child must satisfy before it is written, maintained by the cluster admin
+	applyPolicySource := &controllers.ApplyPolicySource{Namespace: "database-operator-system"}
+	applyPolicySource.BindFlags(flag.CommandLine)
+
+	// Database labels and annotations copied to every child, e.g. cost-allocation labels
+	var propagationPolicy controllers.PropagationPolicy
+	propagationPolicy.BindFlags(flag.CommandLine)
+
+	// Grafana dashboards of Databases with monitoring enabled, picked up by the dashboard sidecar
+	dashboardPolicy := controllers.DefaultDashboardPolicy
+	dashboardPolicy.BindFlags(flag.CommandLine)
+
+	var resourceUsage bool
+	flag.BoolVar(&resourceUsage, "report-resource-usage", false,
+		"Add the CPU and memory usage reported by metrics-server to each Database's status.resources.")
+
+	var childConcurrency int
+	flag.IntVar(&childConcurrency, "child-reconcile-concurrency", 1,
+		"How many independent child resources of a Database are reconciled in parallel.")
+
+	var historyLimit int
+	flag.IntVar(&historyLimit, "status-history-limit", 0,
+		"How many recent reconcile outcomes each Database keeps in status.history, at most 100. Zero disables the history.")
+
+	var degradedAfter time.Duration
+	flag.DurationVar(&degradedAfter, "degraded-after", 5*time.Minute,
+		"How long a Database may serve with only part of its replicas ready before its Degraded condition turns True. Zero disables the condition.")
+
+	var backupUploaderImage string
+	flag.StringVar(&backupUploaderImage, "backup-uploader-image", controllers.DefaultUploaderImage,
+		"Image uploading the dumps of Backups to their bucket, and downloading those of Restores; it must provide the aws CLI. Backups and Restores may set their own.")
+
+	// Warns about (and optionally unblocks) Databases stuck in Terminating
+	stuckDeletionDetector := controllers.DefaultStuckDeletionDetector
+	stuckDeletionDetector.BindFlags(flag.CommandLine)
+	orphanSweeper := controllers.DefaultOrphanSweeper
+	orphanSweeper.BindFlags(flag.CommandLine)
+
+	// Warns about Databases reconciled over and over, e.g. while fighting another controller
+	hotLoopDetector := controllers.NewHotLoopDetector()
+	hotLoopDetector.BindFlags(flag.CommandLine)
+
+	// Moves Databases with an imageUpdatePolicy to newer tags, or annotates them
+	var imageUpdater controllers.ImageUpdater
+	imageUpdater.BindFlags(flag.CommandLine)
+
+	// Lets a registry push trigger reconciles of the Databases running the pushed image
+	hookReceiver := controllers.NewHookReceiver()
+	hookReceiver.BindFlags(flag.CommandLine)
+
+	// Lists Databases and pauses or resumes them for clients that cannot use the Kubernetes API
+	var restServer controllers.RESTServer
+	restServer.BindFlags(flag.CommandLine)
+
+	// Keys sealing the sensitive values the operator records in the status of Databases
+	sealingKeys := &sealing.SecretKeys{Namespace: "database-operator-system"}
+	sealingKeys.BindFlags(flag.CommandLine)
+
+	// Exports connection and slow query statistics of ready Databases
+	statsCollector := controllers.NewStatsCollector()
+	statsCollector.BindFlags(flag.CommandLine)
+
+	// Recommends, and optionally applies, database resources from their usage history
+	recommender := controllers.NewRecommender()
+	recommender.BindFlags(flag.CommandLine)
+
+	// Rewrites Databases stored in an old API version after the storage version changes
+	storageMigrator := controllers.NewStorageVersionMigrator()
+	storageMigrator.BindFlags(flag.CommandLine)
+
+	// Keeps the caches of standby replicas warm, so a new leader does not start with a full resync
+	var warmSpare controllers.WarmSpare
+	warmSpare.BindFlags(flag.CommandLine)
+
+	// Controllers and webhooks switched off by flag or, at runtime, by a ConfigMap
+	switches := controllers.Switches{
+		Namespace: "database-operator-system",
+		Name:      "database-operator-switches",
+		Interval:  10 * time.Second,
+	}
+	switches.BindFlags(flag.CommandLine)
+
+	// Site-specific plugins called before provisioning, once ready and before deletion
+	pluginRegistry := &plugins.Registry{Timeout: plugins.DefaultTimeout}
+	pluginRegistry.BindFlags(flag.CommandLine)
+
+	// Opt-in anonymous counts of managed resources and used features for the maintainers
+	telemetryReporter := controllers.NewTelemetryReporter()
+	telemetryReporter.BindFlags(flag.CommandLine)
+
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	if err := switches.Validate(); err != nil {
+		setupLog.Error(err, "invalid switches")
+		os.Exit(1)
+	}
+	if err := telemetryReporter.Validate(); err != nil {
+		setupLog.Error(err, "invalid telemetry options")
+		os.Exit(1)
+	}
+
+	restConfig := ctrl.GetConfigOrDie()
+	if err := clientOptions.Apply(restConfig); err != nil {
+		setupLog.Error(err, "invalid API client options")
+		os.Exit(1)
+	}
+
+	var namespaces []string
+	cacheOptions := controllers.CacheOptions()
+	if watchNamespaces != "" {
+		cacheOptions.DefaultNamespaces = map[string]cache.Config{
+			// The defaults, apply policies and switches ConfigMaps are read through the cache as well
+			defaultsSource.Namespace:    {},
+			applyPolicySource.Namespace: {},
+			sealingKeys.Namespace:       {},
+			switches.Namespace:          {},
+		}
+		for _, namespace := range strings.Split(watchNamespaces, ",") {
+			namespaces = append(namespaces, strings.TrimSpace(namespace))
+			cacheOptions.DefaultNamespaces[strings.TrimSpace(namespace)] = cache.Config{}
+		}
+	}
+
+	metricsOptions := metricsserver.Options{BindAddress: metricsAddr}
+	if warmSpare.Enabled {
+		metricsOptions.ExtraHandlers = warmSpare.DiagnosticsHandlers()
+	}
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+		Scheme:                 scheme,
+		Cache:                  cacheOptions,
+		Metrics:                metricsOptions,
+		NewClient:              apiclient.NewClientFunc(clientOptions.ClientOptions()),
+		HealthProbeBindAddress: probeAddr,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "database.my.domain",
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+
+	defaultsSource.Reader = mgr.GetClient()
+	applyPolicySource.Reader = mgr.GetClient()
+	switches.Reader = mgr.GetClient()
+	if err := mgr.Add(&switches); err != nil {
+		setupLog.Error(err, "unable to set up switches")
+		os.Exit(1)
+	}
+
+	var externalEvents <-chan event.GenericEvent
+	if hookReceiver.Addr != "" {
+		hookReceiver.Reader = mgr.GetClient()
+		if err := mgr.Add(hookReceiver); err != nil {
+			setupLog.Error(err, "unable to set up hook receiver")
+			os.Exit(1)
+		}
+		externalEvents = hookReceiver.Events()
+	}
+
+	var sealer sealing.Sealer
+	if sealingKeys.Name != "" {
+		sealingKeys.Reader = mgr.GetClient()
+		sealer = &sealing.AESSealer{Keys: sealingKeys}
+	}
+
+	eventBroadcaster, err := controllers.NewEventBroadcaster(mgr)
+	if err != nil {
+		setupLog.Error(err, "unable to set up event broadcaster")
+		os.Exit(1)
+	}
+
+	if restServer.Addr != "" {
+		restServer.Client = mgr.GetClient()
+		restServer.Recorder = eventBroadcaster.NewRecorder(mgr.GetScheme(), "database-rest-api")
+		restServer.Sealer = sealer
+		if err := mgr.Add(&restServer); err != nil {
+			setupLog.Error(err, "unable to set up REST API")
+			os.Exit(1)
+		}
+	}
+
+	if pluginRegistry.Len() > 0 {
+		if err := mgr.Add(pluginRegistry); err != nil {
+			setupLog.Error(err, "unable to set up plugins")
+			os.Exit(1)
+		}
+	}
+
+	if err = (&controllers.DatabaseReconciler{
+		Client:           mgr.GetClient(),
+		APIReader:        mgr.GetAPIReader(),
+		Scheme:           mgr.GetScheme(),
+		RequeuePolicy:    requeuePolicy,
+		ChildConcurrency: childConcurrency,
+		Defaults:         &defaultsSource,
+		ApplyPolicies:    applyPolicySource,
+		Propagation:      propagationPolicy,
+		Dashboards:       dashboardPolicy,
+		ResourceUsage:    resourceUsage,
+		HistoryLimit:     historyLimit,
+		DegradedAfter:    degradedAfter,
+		HotLoops:         hotLoopDetector,
+		ExternalEvents:   externalEvents,
+		Switches:         &switches,
+		Plugins:          pluginRegistry,
+		Recorder:         eventBroadcaster.NewRecorder(mgr.GetScheme(), "database-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Database")
+		os.Exit(1)
+	}
+	if err = (&controllers.ClusterDatabasePolicyReconciler{
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		Namespaces: namespaces,
+		Switches:   &switches,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterDatabasePolicy")
+		os.Exit(1)
+	}
+	if err = (&controllers.SkillsQuotaReconciler{
+		Client:     mgr.GetClient(),
+		Namespaces: namespaces,
+		Switches:   &switches,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SkillsQuota")
+		os.Exit(1)
+	}
+	if err = (&controllers.ApplicationReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Switches: &switches,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Application")
+		os.Exit(1)
+	}
+	if err = (&controllers.BackupReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		UploaderImage: backupUploaderImage,
+		Switches:      &switches,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Backup")
+		os.Exit(1)
+	}
+	if err = (&controllers.RestoreReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		DownloaderImage: backupUploaderImage,
+		Switches:        &switches,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Restore")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		// The first webhook for Database also serves /convert: databasev2 converts through v1,
+		// the hub, once the CRD serves it
+		if err = (&controllers.DatabaseDefaulter{Defaults: &defaultsSource, Switches: &switches}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Database")
+			os.Exit(1)
+		}
+		// Uncached: PriorityClasses are only read on admission, so no informer is worth keeping,
+		// and SkillsQuotas must see the Databases created a moment ago
+		if err = (&controllers.DatabaseValidator{Reader: mgr.GetAPIReader(), Switches: &switches}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Database")
+			os.Exit(1)
+		}
+		if err = (&controllers.PodPolicyValidator{Switches: &switches}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Pod")
+			os.Exit(1)
+		}
+	}
+	//+kubebuilder:scaffold:builder
+
+	if stuckDeletionDetector.Threshold > 0 {
+		stuckDeletionDetector.Client = mgr.GetClient()
+		stuckDeletionDetector.Switches = &switches
+		stuckDeletionDetector.Recorder = eventBroadcaster.NewRecorder(mgr.GetScheme(), "database-stuck-deletion")
+		if err := mgr.Add(&stuckDeletionDetector); err != nil {
+			setupLog.Error(err, "unable to set up stuck deletion detector")
+			os.Exit(1)
+		}
+	}
+
+	if orphanSweeper.Interval > 0 {
+		orphanSweeper.Client = mgr.GetClient()
+		orphanSweeper.Reader = mgr.GetAPIReader()
+		orphanSweeper.Switches = &switches
+		orphanSweeper.Scheme = mgr.GetScheme()
+		orphanSweeper.Recorder = eventBroadcaster.NewRecorder(mgr.GetScheme(), "database-orphan-sweeper")
+		if err := mgr.Add(&orphanSweeper); err != nil {
+			setupLog.Error(err, "unable to set up orphan sweeper")
+			os.Exit(1)
+		}
+	}
+
+	if imageUpdater.Interval > 0 {
+		imageUpdater.Client = mgr.GetClient()
+		imageUpdater.Switches = &switches
+		imageUpdater.Tags = &controllers.RegistryTags{}
+		imageUpdater.Recorder = eventBroadcaster.NewRecorder(mgr.GetScheme(), "database-image-updater")
+		if err := mgr.Add(&imageUpdater); err != nil {
+			setupLog.Error(err, "unable to set up image updater")
+			os.Exit(1)
+		}
+	}
+
+	if statsCollector.Interval > 0 {
+		statsCollector.Client = mgr.GetClient()
+		statsCollector.Querier = &controllers.SQLStats{}
+		statsCollector.Sealer = sealer
+		if err := mgr.Add(statsCollector); err != nil {
+			setupLog.Error(err, "unable to set up stats collector")
+			os.Exit(1)
+		}
+	}
+
+	if telemetryReporter.Enabled {
+		telemetryReporter.Client = mgr.GetClient()
+		telemetryReporter.Switches = &switches
+		telemetryReporter.Sink = &controllers.HTTPTelemetrySink{Endpoint: telemetryReporter.Endpoint}
+		if err := mgr.Add(telemetryReporter); err != nil {
+			setupLog.Error(err, "unable to set up telemetry")
+			os.Exit(1)
+		}
+	}
+
+	if recommender.Interval > 0 {
+		recommender.Client = mgr.GetClient()
+		recommender.Switches = &switches
+		recommender.Recorder = eventBroadcaster.NewRecorder(mgr.GetScheme(), "database-recommender")
+		if err := mgr.Add(recommender); err != nil {
+			setupLog.Error(err, "unable to set up recommender")
+			os.Exit(1)
+		}
+	}
+
+	if storageMigrator.Interval > 0 {
+		storageMigrator.Client = mgr.GetClient()
+		storageMigrator.Reader = mgr.GetAPIReader()
+		storageMigrator.Recorder = eventBroadcaster.NewRecorder(mgr.GetScheme(), "database-storage-migration")
+		if err := mgr.Add(storageMigrator); err != nil {
+			setupLog.Error(err, "unable to set up storage version migration")
+			os.Exit(1)
+		}
+	}
+
+	if warmSpare.Enabled {
+		objects, err := controllers.WatchedObjects(mgr.GetRESTMapper())
+		if err != nil {
+			setupLog.Error(err, "unable to discover watched kinds")
+			os.Exit(1)
+		}
+		warmSpare.Cache = mgr.GetCache()
+		warmSpare.Reader = mgr.GetCache()
+		warmSpare.Objects = objects
+		warmSpare.Elected = mgr.Elected()
+		if err := mgr.Add(&warmSpare); err != nil {
+			setupLog.Error(err, "unable to set up warm spare")
+			os.Exit(1)
+		}
+		metrics.Registry.MustRegister(&warmSpare)
+		// A replica that cannot take over quickly is not ready
+		if err := mgr.AddReadyzCheck("warm-cache", warmSpare.Synced); err != nil {
+			setupLog.Error(err, "unable to set up warm cache check")
+			os.Exit(1)
+		}
+	}
+
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+
+	setupLog.Info("starting manager")
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running manager")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,127 @@
+// Package naming builds names for objects the operator generates from a Database name.
+//
+// Database names are DNS-1123 subdomains of up to 253 characters and may contain dots, but
+// children have stricter rules: a Service name must be a DNS-1035 label of at most 63
+// characters, and appending a suffix such as "-password" can push any name over its limit.
+// Concatenating names by hand therefore breaks for long or punctuated Database names.
+//
+// Names that are already valid are returned unchanged, so existing children keep their names.
+// Otherwise the name is sanitized and the parent part truncated, and a hash of the full,
+// unmodified input is inserted before the suffix:
+//
+//	Label("orders.eu-west")                                    -> "orders-eu-west-d5b628cb"
+//	Subdomain(strings.Repeat("a", 250), "pull", "registry") -> "aaa…aaa-e6b15115-pull-registry" (253 characters)
+//
+// The hash keeps the result stable across reconciles and keeps inputs that sanitize to the
+// same string (orders.eu and orders-eu) from colliding.
+package naming
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// hashLength is the number of hex characters of the input hash used in shortened names
+const hashLength = 8
+
+// Subdomain joins parts with dashes into a DNS-1123 subdomain, valid for most object names
+// (Secrets, ConfigMaps, Deployments, PersistentVolumeClaims)
+func Subdomain(parts ...string) string {
+	return build(validation.DNS1123SubdomainMaxLength, isSubdomain, parts)
+}
+
+// Label joins parts with dashes into a DNS-1035 label, valid for Service names and anything
+// else that must be at most 63 characters and start with a letter
+func Label(parts ...string) string {
+	return build(validation.DNS1035LabelMaxLength, isLabel, parts)
+}
+
+// LabelValue returns name if it is a valid label value, and Label(name) otherwise. Label values
+// may contain dots, so only names over 63 characters change.
+func LabelValue(name string) string {
+	if len(validation.IsValidLabelValue(name)) == 0 {
+		return name
+	}
+	return Label(name)
+}
+
+// MayBeShortened reports whether name may have been shortened, i.e. ends in the hash that
+// shortened names carry. Names that were not shortened may end like that too.
+func MayBeShortened(name string) bool {
+	i := strings.LastIndexByte(name, '-')
+	if i < 0 || len(name)-i-1 != hashLength {
+		return false
+	}
+	_, err := hex.DecodeString(name[i+1:])
+	return err == nil && strings.ToLower(name[i+1:]) == name[i+1:]
+}
+
+func isSubdomain(name string) bool {
+	return len(validation.IsDNS1123Subdomain(name)) == 0
+}
+
+func isLabel(name string) bool {
+	return len(validation.IsDNS1035Label(name)) == 0
+}
+
+// build joins parts, the first being the parent name and the rest the suffix, into a name
+// of at most maxLength characters that satisfies valid
+func build(maxLength int, valid func(string) bool, parts []string) string {
+	full := strings.Join(parts, "-")
+	if valid(full) {
+		return full
+	}
+
+	sum := sha256.Sum256([]byte(full))
+	hash := hex.EncodeToString(sum[:])[:hashLength]
+
+	// The suffix says what the child is, so it is kept unless it would take more than
+	// half of the name
+	var suffix string
+	if len(parts) > 1 {
+		suffix = sanitize(strings.Join(parts[1:], "-"))
+		if len(suffix) > maxLength/2 {
+			suffix = strings.TrimRight(suffix[:maxLength/2], "-")
+		}
+	}
+
+	budget := maxLength - len(hash) - 1
+	if suffix != "" {
+		budget -= len(suffix) + 1
+	}
+
+	// Labels must start with a letter; the hash may start with a digit, so never drop the prefix entirely
+	prefix := strings.TrimLeft(sanitize(parts[0]), "0123456789-")
+	if prefix == "" {
+		prefix = "x"
+	}
+	if len(prefix) > budget {
+		prefix = strings.TrimRight(prefix[:budget], "-")
+	}
+
+	name := prefix + "-" + hash
+	if suffix != "" {
+		name += "-" + suffix
+	}
+	return name
+}
+
+// sanitize lowercases s and replaces everything but letters, digits and dashes with dashes.
+// Dots are replaced too: they are valid in subdomains but not next to dashes or at the ends
+// of a segment, and not at all in labels.
+func sanitize(s string) string {
+	mapped := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '-'
+		}
+	}, s)
+	return strings.Trim(mapped, "-")
+}
@@ -0,0 +1,64 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubdomain(t *testing.T) {
+	assert.Equal(t, "orders-password", Subdomain("orders", "password"), "Valid names are unchanged")
+	assert.Equal(t, "orders.eu-password", Subdomain("orders.eu", "password"), "Dots are valid in subdomains")
+
+	long := strings.Repeat("a", 250)
+	name := Subdomain(long, "pull", "registry")
+	assert.Len(t, name, 253)
+	assert.True(t, strings.HasSuffix(name, "-pull-registry"), "The suffix survives truncation")
+	assert.True(t, isSubdomain(name))
+	assert.Equal(t, name, Subdomain(long, "pull", "registry"), "Names are stable")
+	assert.NotEqual(t, name, Subdomain(long+"b", "pull", "registry"))
+
+	name = Subdomain("Orders_EU", "password")
+	assert.True(t, isSubdomain(name), name)
+	assert.True(t, strings.HasPrefix(name, "orders-eu-"))
+}
+
+func TestLabel(t *testing.T) {
+	assert.Equal(t, "orders", Label("orders"))
+
+	tests := []string{
+		"orders.eu-west",
+		"1orders",
+		"123",
+		strings.Repeat("x", 100),
+		strings.Repeat("a.", 120) + "b",
+	}
+	for _, input := range tests {
+		name := Label(input)
+		assert.True(t, isLabel(name), "%q -> %q", input, name)
+		assert.Equal(t, name, Label(input))
+	}
+
+	// Inputs that sanitize to the same string still get different names
+	assert.NotEqual(t, Label("orders.eu"), Label("orders-eu"))
+	assert.Equal(t, "orders-eu", Label("orders-eu"))
+
+	name := Label(strings.Repeat("d", 100), strings.Repeat("s", 100))
+	assert.True(t, isLabel(name), name)
+	assert.Len(t, name, 63)
+}
+
+func TestLabelValue(t *testing.T) {
+	assert.Equal(t, "orders.eu", LabelValue("orders.eu"), "Dots are valid in label values")
+	assert.False(t, MayBeShortened("orders.eu"))
+
+	long := strings.Repeat("a.", 50) + "b"
+	value := LabelValue(long)
+	assert.Equal(t, Label(long), value)
+	assert.LessOrEqual(t, len(value), 63)
+	assert.True(t, MayBeShortened(value))
+	assert.True(t, MayBeShortened(Label("orders.eu")))
+	assert.False(t, MayBeShortened("orders-eu"))
+	assert.False(t, MayBeShortened("orders-DEADBEEF"))
+}
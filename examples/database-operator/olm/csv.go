@@ -0,0 +1,322 @@
+// Package olm builds the Operator Lifecycle Manager bundle for the database operator.
+//
+// The ClusterServiceVersion is assembled from Go: operator metadata and the release
+// history live in this package, RBAC comes from the +kubebuilder:rbac markers in
+// controllers/ and the CRD from the controller-gen output in config/crd/bases.
+// Regenerate the bundle after any of them change:
+//
+//	go run ./hack/bundle --image registry.example.com/database-operator:v0.3.0
+//
+// Only the subset of the operators.coreos.com/v1alpha1 ClusterServiceVersion schema
+// the operator needs is modelled here.
+package olm
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+// PackageName is the OLM package the bundles are published under
+const PackageName = "database-operator"
+
+// serviceAccountName is the service account OLM creates for the operator deployment
+const serviceAccountName = "database-operator"
+
+// ClusterServiceVersion describes one version of the operator to OLM
+type ClusterServiceVersion struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec ClusterServiceVersionSpec `json:"spec"`
+}
+
+// ClusterServiceVersionSpec is the subset of the CSV spec used by this operator
+type ClusterServiceVersionSpec struct {
+	DisplayName    string    `json:"displayName"`
+	Description    string    `json:"description"`
+	Version        string    `json:"version"`
+	Replaces       string    `json:"replaces,omitempty"`
+	Skips          []string  `json:"skips,omitempty"`
+	MinKubeVersion string    `json:"minKubeVersion,omitempty"`
+	Maturity       string    `json:"maturity,omitempty"`
+	Keywords       []string  `json:"keywords,omitempty"`
+	Provider       AppLink   `json:"provider"`
+	Links          []AppLink `json:"links,omitempty"`
+
+	InstallModes              []InstallMode             `json:"installModes"`
+	InstallStrategy           InstallStrategy           `json:"install"`
+	CustomResourceDefinitions CustomResourceDefinitions `json:"customresourcedefinitions"`
+	RelatedImages             []RelatedImage            `json:"relatedImages,omitempty"`
+}
+
+// AppLink is a named URL
+type AppLink struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// InstallMode declares whether the operator supports a tenancy mode
+type InstallMode struct {
+	Type      string `json:"type"`
+	Supported bool   `json:"supported"`
+}
+
+// InstallStrategy tells OLM how to run the operator
+type InstallStrategy struct {
+	StrategyName string                    `json:"strategy"`
+	Spec         StrategyDetailsDeployment `json:"spec"`
+}
+
+// StrategyDetailsDeployment holds the operator deployments and their permissions
+type StrategyDetailsDeployment struct {
+	DeploymentSpecs    []StrategyDeploymentSpec        `json:"deployments"`
+	Permissions        []StrategyDeploymentPermissions `json:"permissions,omitempty"`
+	ClusterPermissions []StrategyDeploymentPermissions `json:"clusterPermissions,omitempty"`
+}
+
+// StrategyDeploymentSpec is a named Deployment spec
+type StrategyDeploymentSpec struct {
+	Name string                `json:"name"`
+	Spec appsv1.DeploymentSpec `json:"spec"`
+}
+
+// StrategyDeploymentPermissions are the RBAC rules granted to a service account
+type StrategyDeploymentPermissions struct {
+	ServiceAccountName string              `json:"serviceAccountName"`
+	Rules              []rbacv1.PolicyRule `json:"rules"`
+}
+
+// CustomResourceDefinitions lists the CRDs the operator owns
+type CustomResourceDefinitions struct {
+	Owned []CRDDescription `json:"owned,omitempty"`
+}
+
+// CRDDescription describes an owned CRD in the OperatorHub UI
+type CRDDescription struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Kind        string `json:"kind"`
+	DisplayName string `json:"displayName,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// RelatedImage is an image the operator deploys, listed so that disconnected
+// installs can mirror it
+type RelatedImage struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// Options are the per-build inputs of the CSV
+type Options struct {
+	// Image is the operator image
+	Image string
+
+	// RelatedImages are images the operator deploys, e.g. the default database image
+	RelatedImages []RelatedImage
+
+	// Rules are the cluster-wide RBAC rules, generated from the +kubebuilder:rbac markers
+	Rules []rbacv1.PolicyRule
+}
+
+// CSVName returns the CSV name OLM uses to refer to a version
+func CSVName(version string) string {
+	return fmt.Sprintf("%s.v%s", PackageName, version)
+}
+
+// NewClusterServiceVersion builds the CSV for the head release
+func NewClusterServiceVersion(opts Options) (*ClusterServiceVersion, error) {
+	if opts.Image == "" {
+		return nil, fmt.Errorf("operator image is required")
+	}
+	if err := ValidateUpgradeGraph(Releases); err != nil {
+		return nil, fmt.Errorf("invalid release history: %w", err)
+	}
+	head := Releases[len(Releases)-1]
+
+	csv := &ClusterServiceVersion{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "operators.coreos.com/v1alpha1",
+			Kind:       "ClusterServiceVersion",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: CSVName(head.Version),
+			Annotations: map[string]string{
+				"containerImage": opts.Image,
+				"capabilities":   "Basic Install",
+				"categories":     "Database",
+			},
+		},
+		Spec: ClusterServiceVersionSpec{
+			DisplayName:    "Database Operator",
+			Description:    "Manages PostgreSQL databases: storage, credentials, configuration and rolling updates.",
+			Version:        head.Version,
+			MinKubeVersion: "1.25.0",
+			Maturity:       "alpha",
+			Keywords:       []string{"database", "postgres"},
+			Provider:       AppLink{Name: "Example"},
+			InstallModes: []InstallMode{
+				{Type: "OwnNamespace", Supported: false},
+				{Type: "SingleNamespace", Supported: false},
+				{Type: "MultiNamespace", Supported: false},
+				// The manager caches and reconciles Databases in every namespace
+				{Type: "AllNamespaces", Supported: true},
+			},
+			InstallStrategy: InstallStrategy{
+				StrategyName: "deployment",
+				Spec: StrategyDetailsDeployment{
+					DeploymentSpecs: []StrategyDeploymentSpec{
+						{Name: "database-operator-controller-manager", Spec: operatorDeployment(opts.Image)},
+					},
+					// Leader election runs in the install namespace; kubebuilder keeps these rules
+					// in config/rbac/leader_election_role.yaml rather than in markers
+					Permissions: []StrategyDeploymentPermissions{
+						{ServiceAccountName: serviceAccountName, Rules: leaderElectionRules},
+					},
+					ClusterPermissions: []StrategyDeploymentPermissions{
+						{ServiceAccountName: serviceAccountName, Rules: opts.Rules},
+					},
+				},
+			},
+			CustomResourceDefinitions: CustomResourceDefinitions{
+				Owned: []CRDDescription{
+					{
+						Name:        "databases." + databasev1.GroupVersion.Group,
+						Version:     databasev1.GroupVersion.Version,
+						Kind:        "Database",
+						DisplayName: "Database",
+						Description: "A PostgreSQL database with its storage, credentials and service.",
+					},
+					{
+						Name:        "clusterdatabasepolicies." + databasev1.GroupVersion.Group,
+						Version:     databasev1.GroupVersion.Version,
+						Kind:        "ClusterDatabasePolicy",
+						DisplayName: "Cluster Database Policy",
+						Description: "Network access rules for database pods, applied in every selected namespace.",
+					},
+					{
+						Name:        "skillsquotas." + databasev1.GroupVersion.Group,
+						Version:     databasev1.GroupVersion.Version,
+						Kind:        "SkillsQuota",
+						DisplayName: "Skills Quota",
+						Description: "Limits on the Databases, storage and replicas of every selected namespace.",
+					},
+					{
+						Name:        "databaseclasses." + databasev1.GroupVersion.Group,
+						Version:     databasev1.GroupVersion.Version,
+						Kind:        "DatabaseClass",
+						DisplayName: "Database Class",
+						Description: "Admin-curated presets of image, resources and parameters that Databases reference by name.",
+					},
+					{
+						Name:        "applications." + databasev1.GroupVersion.Group,
+						Version:     databasev1.GroupVersion.Version,
+						Kind:        "Application",
+						DisplayName: "Application",
+						Description: "A Database and the web tier using it, started once the Database is ready.",
+					},
+					{
+						Name:        "backups." + databasev1.GroupVersion.Group,
+						Version:     databasev1.GroupVersion.Version,
+						Kind:        "Backup",
+						DisplayName: "Backup",
+						Description: "A one-off pg_dump of a Database, uploaded to an S3-compatible bucket.",
+					},
+					{
+						Name:        "restores." + databasev1.GroupVersion.Group,
+						Version:     databasev1.GroupVersion.Version,
+						Kind:        "Restore",
+						DisplayName: "Restore",
+						Description: "Loads the dump of a Backup, or one in a bucket, into an existing Database.",
+					},
+				},
+			},
+		},
+	}
+
+	if head.Replaces != "" {
+		csv.Spec.Replaces = CSVName(head.Replaces)
+	}
+	for _, skipped := range head.Skips {
+		csv.Spec.Skips = append(csv.Spec.Skips, CSVName(skipped))
+	}
+
+	csv.Spec.RelatedImages = append([]RelatedImage{{Name: "operator", Image: opts.Image}}, opts.RelatedImages...)
+	return csv, nil
+}
+
+// leaderElectionRules allow the manager to hold its leader election Lease
+var leaderElectionRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{"coordination.k8s.io"},
+		Resources: []string{"leases"},
+		Verbs:     []string{"get", "list", "watch", "create", "update", "patch", "delete"},
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"events"},
+		Verbs:     []string{"create", "patch"},
+	},
+}
+
+// operatorDeployment is the controller manager Deployment OLM installs
+func operatorDeployment(image string) appsv1.DeploymentSpec {
+	labels := map[string]string{"control-plane": "controller-manager"}
+	replicas := int32(1)
+	runAsNonRoot := true
+	allowPrivilegeEscalation := false
+
+	probe := func(path string) *corev1.Probe {
+		return &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{Path: path, Port: intstr.FromInt32(8081)},
+			},
+		}
+	}
+
+	return appsv1.DeploymentSpec{
+		Replicas: &replicas,
+		Selector: &metav1.LabelSelector{MatchLabels: labels},
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: labels},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: serviceAccountName,
+				SecurityContext:    &corev1.PodSecurityContext{RunAsNonRoot: &runAsNonRoot},
+				Containers: []corev1.Container{
+					{
+						Name:    "manager",
+						Image:   image,
+						Command: []string{"/manager"},
+						Args:    []string{"--leader-elect", "--health-probe-bind-address=:8081"},
+						SecurityContext: &corev1.SecurityContext{
+							AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+							Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+						},
+						LivenessProbe:  probe("/healthz"),
+						ReadinessProbe: probe("/readyz"),
+					},
+				},
+			},
+		},
+	}
+}
+
+// BundleAnnotations returns the content of metadata/annotations.yaml for the head release
+func BundleAnnotations() map[string]string {
+	head := Releases[len(Releases)-1]
+	return map[string]string{
+		"operators.operatorframework.io.bundle.mediatype.v1":       "registry+v1",
+		"operators.operatorframework.io.bundle.manifests.v1":       "manifests/",
+		"operators.operatorframework.io.bundle.metadata.v1":        "metadata/",
+		"operators.operatorframework.io.bundle.package.v1":         PackageName,
+		"operators.operatorframework.io.bundle.channels.v1":        joinChannels(head.Channels),
+		"operators.operatorframework.io.bundle.channel.default.v1": DefaultChannel,
+	}
+}
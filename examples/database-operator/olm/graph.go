@@ -0,0 +1,161 @@
+package olm
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// DefaultChannel is the channel OLM subscribes to when none is given
+const DefaultChannel = "stable"
+
+// Release is a published bundle and its place in the upgrade graph
+type Release struct {
+	// Version is the semantic version of the bundle, without a leading "v"
+	Version string
+
+	// Replaces is the version this release upgrades from
+	Replaces string
+
+	// Skips are versions that may upgrade directly to this release, e.g. a broken patch
+	Skips []string
+
+	// Channels the bundle is published to
+	Channels []string
+}
+
+// Releases is the full release history, oldest first. Append a release here before
+// building a new bundle; the last entry is the bundle being built. Never edit
+// published entries: OLM catalogs already contain them. Once a bundle is published, copy
+// its CRDs to crdcompat/testdata/released so later changes are checked against them.
+var Releases = []Release{
+	{Version: "0.1.0", Channels: []string{"alpha", "stable"}},
+	{Version: "0.2.0", Replaces: "0.1.0", Channels: []string{"alpha", "stable"}},
+	{Version: "0.2.1", Replaces: "0.2.0", Channels: []string{"alpha", "stable"}},
+	// 0.2.1 shipped a broken migration; 0.2.0 installs upgrade straight to 0.3.0
+	{Version: "0.3.0", Replaces: "0.2.1", Skips: []string{"0.2.0"}, Channels: []string{"alpha", "stable"}},
+}
+
+// ValidateUpgradeGraph checks that every release in every channel can upgrade to the
+// channel head, that releases are ordered and that Replaces and Skips only point at
+// earlier releases in the same channel
+func ValidateUpgradeGraph(releases []Release) error {
+	if len(releases) == 0 {
+		return fmt.Errorf("no releases")
+	}
+
+	index := make(map[string]int, len(releases))
+	var previous *version.Version
+	for i, release := range releases {
+		v, err := version.ParseSemantic(release.Version)
+		if err != nil {
+			return fmt.Errorf("release %q: %w", release.Version, err)
+		}
+		if previous != nil && !previous.LessThan(v) {
+			return fmt.Errorf("release %s is not newer than %s", release.Version, previous)
+		}
+		if len(release.Channels) == 0 {
+			return fmt.Errorf("release %s is not published to any channel", release.Version)
+		}
+		index[release.Version] = i
+		previous = v
+	}
+
+	if !hasChannel(releases[len(releases)-1], DefaultChannel) {
+		return fmt.Errorf("head release %s is not in the default channel %q", releases[len(releases)-1].Version, DefaultChannel)
+	}
+
+	for _, channel := range channelNames(releases) {
+		// upgrades[v] lists the releases v can upgrade to directly
+		upgrades := map[string][]string{}
+		replacedBy := map[string]string{}
+		var members []string
+
+		for _, release := range releases {
+			if !hasChannel(release, channel) {
+				continue
+			}
+			members = append(members, release.Version)
+
+			from := release.Skips
+			if release.Replaces != "" {
+				if other, ok := replacedBy[release.Replaces]; ok {
+					return fmt.Errorf("channel %s: %s is replaced by both %s and %s", channel, release.Replaces, other, release.Version)
+				}
+				replacedBy[release.Replaces] = release.Version
+				from = append([]string{release.Replaces}, from...)
+			}
+
+			for _, source := range from {
+				i, ok := index[source]
+				if !ok {
+					return fmt.Errorf("release %s upgrades from unknown release %s", release.Version, source)
+				}
+				if i >= index[release.Version] {
+					return fmt.Errorf("release %s upgrades from newer release %s", release.Version, source)
+				}
+				if source == release.Replaces && !hasChannel(releases[i], channel) {
+					return fmt.Errorf("channel %s: %s replaces %s, which is not in the channel", channel, release.Version, source)
+				}
+				upgrades[source] = append(upgrades[source], release.Version)
+			}
+		}
+
+		head := members[len(members)-1]
+		for _, member := range members {
+			if !reachable(upgrades, member, head) {
+				return fmt.Errorf("channel %s: no upgrade path from %s to %s", channel, member, head)
+			}
+		}
+	}
+
+	return nil
+}
+
+// reachable reports whether to can be reached from from along upgrade edges
+func reachable(upgrades map[string][]string, from, to string) bool {
+	seen := map[string]bool{}
+	queue := []string{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == to {
+			return true
+		}
+		if seen[current] {
+			continue
+		}
+		seen[current] = true
+		queue = append(queue, upgrades[current]...)
+	}
+	return false
+}
+
+// channelNames returns the channels used by any release, in first-use order
+func channelNames(releases []Release) []string {
+	seen := map[string]bool{}
+	var channels []string
+	for _, release := range releases {
+		for _, channel := range release.Channels {
+			if !seen[channel] {
+				seen[channel] = true
+				channels = append(channels, channel)
+			}
+		}
+	}
+	return channels
+}
+
+func hasChannel(release Release, channel string) bool {
+	for _, c := range release.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+func joinChannels(channels []string) string {
+	return strings.Join(channels, ",")
+}
@@ -0,0 +1,119 @@
+package olm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleasesUpgradeGraph(t *testing.T) {
+	require.NoError(t, ValidateUpgradeGraph(Releases))
+}
+
+func TestValidateUpgradeGraph(t *testing.T) {
+	stable := []string{"stable"}
+
+	tests := []struct {
+		name     string
+		releases []Release
+		wantErr  string
+	}{
+		{
+			name: "linear",
+			releases: []Release{
+				{Version: "1.0.0", Channels: stable},
+				{Version: "1.1.0", Replaces: "1.0.0", Channels: stable},
+			},
+		},
+		{
+			name: "skip",
+			releases: []Release{
+				{Version: "1.0.0", Channels: []string{"stable", "fast"}},
+				{Version: "1.0.1", Replaces: "1.0.0", Channels: stable},
+				{Version: "1.1.0", Replaces: "1.0.0", Skips: []string{"1.0.1"}, Channels: []string{"fast"}},
+				{Version: "1.2.0", Replaces: "1.0.1", Channels: stable},
+			},
+		},
+		{
+			name: "dead end",
+			releases: []Release{
+				{Version: "1.0.0", Channels: stable},
+				{Version: "1.1.0", Channels: stable},
+			},
+			wantErr: "no upgrade path from 1.0.0",
+		},
+		{
+			name: "out of order",
+			releases: []Release{
+				{Version: "1.1.0", Channels: stable},
+				{Version: "1.0.0", Replaces: "1.1.0", Channels: stable},
+			},
+			wantErr: "not newer",
+		},
+		{
+			name: "unknown replaces",
+			releases: []Release{
+				{Version: "1.0.0", Channels: stable},
+				{Version: "1.1.0", Replaces: "0.9.0", Channels: stable},
+			},
+			wantErr: "unknown release 0.9.0",
+		},
+		{
+			name: "fork",
+			releases: []Release{
+				{Version: "1.0.0", Channels: stable},
+				{Version: "1.1.0", Replaces: "1.0.0", Channels: stable},
+				{Version: "1.2.0", Replaces: "1.0.0", Channels: stable},
+			},
+			wantErr: "replaced by both",
+		},
+		{
+			name: "replaces outside channel",
+			releases: []Release{
+				{Version: "1.0.0", Channels: []string{"alpha"}},
+				{Version: "1.1.0", Replaces: "1.0.0", Channels: stable},
+			},
+			wantErr: "not in the channel",
+		},
+		{
+			name: "head not in default channel",
+			releases: []Release{
+				{Version: "1.0.0", Channels: []string{"alpha"}},
+			},
+			wantErr: "default channel",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpgradeGraph(tt.releases)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestNewClusterServiceVersion(t *testing.T) {
+	_, err := NewClusterServiceVersion(Options{})
+	assert.Error(t, err, "The operator image is required")
+
+	csv, err := NewClusterServiceVersion(Options{
+		Image:         "registry.example.com/database-operator:v0.3.0",
+		RelatedImages: []RelatedImage{{Name: "postgres", Image: "docker.io/library/postgres:15"}},
+	})
+	require.NoError(t, err)
+
+	head := Releases[len(Releases)-1]
+	assert.Equal(t, CSVName(head.Version), csv.Name)
+	assert.Equal(t, CSVName(head.Replaces), csv.Spec.Replaces)
+	assert.Equal(t, "databases.my.domain", csv.Spec.CustomResourceDefinitions.Owned[0].Name)
+	assert.Equal(t, "registry.example.com/database-operator:v0.3.0",
+		csv.Spec.InstallStrategy.Spec.DeploymentSpecs[0].Spec.Template.Spec.Containers[0].Image)
+	assert.Len(t, csv.Spec.RelatedImages, 2)
+	assert.Equal(t, DefaultChannel, BundleAnnotations()["operators.operatorframework.io.bundle.channel.default.v1"])
+}
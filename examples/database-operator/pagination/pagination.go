@@ -0,0 +1,101 @@
+// Package pagination lists large collections page by page and hands the items to a callback,
+// so memory stays bounded by the page size instead of growing with the fleet. The orphan sweep
+// pages through the generated Secrets and ConfigMaps of every Database this way.
+//
+// Page through the API reader: the informer cache has no pages, it rejects a continue token
+// and cuts a list at the limit without one. Kinds the operator caches are in memory already
+// and are listed with an index and client.UnsafeDisableDeepCopy instead. A continue token
+// that expired mid-list is continued with the inconsistent token the API server returns, so a
+// callback may see objects changed since the first page, or an item twice.
+package pagination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// DefaultPageSize is the page size of lists without a client.Limit option
+const DefaultPageSize = 500
+
+// ErrStop stops ForEach without an error when the function returns it, e.g. once the
+// function found what it was looking for
+var ErrStop = errors.New("stop listing")
+
+// ForEach lists into list page by page and calls fn for every item. The page size is the
+// client.Limit option, DefaultPageSize without one. T is the pointer type of the items, e.g.
+// *corev1.Secret for a *corev1.SecretList. fn must not keep the item: the next page overwrites
+// it. An error of fn stops the list and is returned, except ErrStop, which stops it cleanly.
+func ForEach[T client.Object](ctx context.Context, r client.Reader, list client.ObjectList, fn func(T) error, opts ...client.ListOption) error {
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+	if listOpts.Limit <= 0 {
+		listOpts.Limit = DefaultPageSize
+	}
+	restarted := false
+	for {
+		if err := r.List(ctx, list, listOpts); err != nil {
+			token, expired := expiredContinue(err)
+			if !expired || listOpts.Continue == "" || (token == "" && restarted) {
+				return err
+			}
+			// Continue inconsistently, or start over once when the server gave no token
+			restarted = restarted || token == ""
+			listOpts.Continue = token
+			continue
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			obj, ok := item.(T)
+			if !ok {
+				return fmt.Errorf("list item %T is not a %T", item, *new(T))
+			}
+			if err := fn(obj); err != nil {
+				if errors.Is(err, ErrStop) {
+					return nil
+				}
+				return err
+			}
+		}
+		listOpts.Continue = list.GetContinue()
+		if listOpts.Continue == "" {
+			return nil
+		}
+	}
+}
+
+// Requests pages through list and returns a request for every item match accepts, for map
+// functions. On an error it returns the requests of the pages listed before it.
+func Requests[T client.Object](ctx context.Context, r client.Reader, list client.ObjectList, match func(T) bool, opts ...client.ListOption) ([]reconcile.Request, error) {
+	var requests []reconcile.Request
+	err := ForEach(ctx, r, list, func(item T) error {
+		if match(item) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: item.GetNamespace(), Name: item.GetName()},
+			})
+		}
+		return nil
+	}, opts...)
+	return requests, err
+}
+
+// expiredContinue reports whether err is a list whose continue token expired, and the token
+// the server sent to continue it inconsistently; empty when it sent none
+func expiredContinue(err error) (string, bool) {
+	if !apierrors.IsResourceExpired(err) {
+		return "", false
+	}
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return "", true
+	}
+	return status.Status().ListMeta.Continue, true
+}
@@ -0,0 +1,163 @@
+package pagination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// pagedReader pages the lists of the fake client, which ignores limits, like the API server:
+// the continue token is the offset of the next page
+type pagedReader struct {
+	client.Reader
+	limits []int64
+
+	// expire fails the list continued with this token; with continueAt it answers with an
+	// inconsistent token continuing there
+	expire     string
+	continueAt string
+}
+
+func (r *pagedReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+	r.limits = append(r.limits, listOpts.Limit)
+	if listOpts.Continue != "" && listOpts.Continue == r.expire {
+		r.expire = ""
+		err := apierrors.NewResourceExpired("The provided continue parameter is too old")
+		err.ErrStatus.ListMeta.Continue = r.continueAt
+		return err
+	}
+	if err := r.Reader.List(ctx, list, client.InNamespace(listOpts.Namespace)); err != nil {
+		return err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	offset := 0
+	if listOpts.Continue != "" {
+		if offset, err = strconv.Atoi(listOpts.Continue); err != nil {
+			return apierrors.NewBadRequest("invalid continue token")
+		}
+	}
+	end, next := len(items), ""
+	if offset+int(listOpts.Limit) < end {
+		end = offset + int(listOpts.Limit)
+		next = strconv.Itoa(end)
+	}
+	list.SetContinue(next)
+	return meta.SetList(list, items[offset:end])
+}
+
+func newPagedReader(t *testing.T, count int) *pagedReader {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for i := 0; i < count; i++ {
+		builder = builder.WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("orders-%02d", i), Namespace: "shop"},
+		})
+	}
+	return &pagedReader{Reader: builder.Build()}
+}
+
+func names(t *testing.T, r client.Reader, opts ...client.ListOption) []string {
+	var seen []string
+	require.NoError(t, ForEach(context.Background(), r, &corev1.ConfigMapList{}, func(configMap *corev1.ConfigMap) error {
+		seen = append(seen, configMap.Name)
+		return nil
+	}, opts...))
+	return seen
+}
+
+func TestForEach(t *testing.T) {
+	reader := newPagedReader(t, 7)
+	seen := names(t, reader, client.InNamespace("shop"), client.Limit(3))
+	assert.Len(t, seen, 7)
+	assert.Equal(t, "orders-00", seen[0])
+	assert.Equal(t, "orders-06", seen[6])
+	assert.Equal(t, []int64{3, 3, 3}, reader.limits, "Three pages of at most three")
+
+	reader = newPagedReader(t, 2)
+	assert.Len(t, names(t, reader), 2)
+	assert.Equal(t, []int64{DefaultPageSize}, reader.limits)
+}
+
+func TestForEach_Stop(t *testing.T) {
+	reader := newPagedReader(t, 7)
+	calls := 0
+	err := ForEach(context.Background(), reader, &corev1.ConfigMapList{}, func(*corev1.ConfigMap) error {
+		calls++
+		if calls == 4 {
+			return ErrStop
+		}
+		return nil
+	}, client.Limit(3))
+	require.NoError(t, err)
+	assert.Equal(t, 4, calls)
+	assert.Len(t, reader.limits, 2, "The third page is not listed")
+
+	failed := errors.New("failed")
+	err = ForEach(context.Background(), reader, &corev1.ConfigMapList{}, func(*corev1.ConfigMap) error {
+		return failed
+	})
+	assert.ErrorIs(t, err, failed)
+}
+
+func TestForEach_Expired(t *testing.T) {
+	t.Run("inconsistent continue", func(t *testing.T) {
+		reader := newPagedReader(t, 7)
+		reader.expire, reader.continueAt = "3", "4"
+		seen := names(t, reader, client.Limit(3))
+		assert.Len(t, seen, 6, "orders-03 changed and was compacted away")
+		assert.NotContains(t, seen, "orders-03")
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		reader := newPagedReader(t, 7)
+		reader.expire = "3"
+		seen := names(t, reader, client.Limit(3))
+		assert.Len(t, seen, 10, "The first page is seen twice")
+	})
+
+	t.Run("restart once", func(t *testing.T) {
+		reader := newPagedReader(t, 7)
+		reader.expire = "3"
+		calls := 0
+		err := ForEach(context.Background(), reader, &corev1.ConfigMapList{}, func(*corev1.ConfigMap) error {
+			// Expires again once the first page was seen after the restart
+			if calls++; calls == 6 {
+				reader.expire = "3"
+			}
+			return nil
+		}, client.Limit(3))
+		assert.True(t, apierrors.IsResourceExpired(err), err)
+	})
+}
+
+func TestRequests(t *testing.T) {
+	reader := newPagedReader(t, 7)
+	requests, err := Requests(context.Background(), reader, &corev1.ConfigMapList{}, func(configMap *corev1.ConfigMap) bool {
+		return configMap.Name == "orders-02" || configMap.Name == "orders-05"
+	}, client.InNamespace("shop"), client.Limit(2))
+	require.NoError(t, err)
+	assert.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: "shop", Name: "orders-02"}},
+		{NamespacedName: types.NamespacedName{Namespace: "shop", Name: "orders-05"}},
+	}, requests)
+	assert.Len(t, reader.limits, 4)
+}
@@ -0,0 +1,337 @@
+// Package pgtest is an in-memory stand-in for the PostgreSQL servers of Databases, so the code
+// that connects to them can be tested without Docker or a real server. A Server registers a
+// database/sql driver that accepts the connection URLs the operator builds, authenticates
+// them against the roles of the server and answers the queries the operator runs from a
+// Stats snapshot:
+//
+//	server := pgtest.NewServer()
+//	server.AddRole("orders.stats.svc:5432", "app", "s3cret", "app")
+//	server.SetStats(pgtest.Stats{Connections: map[string]int32{"active": 3}, MaxConnections: 100})
+//	querier := &controllers.SQLStats{Driver: server.DriverName()}
+//
+// Failures use the messages of PostgreSQL, so error handling sees what it would in a cluster:
+// unknown hosts fail like a DNS lookup, wrong passwords fail authentication and
+// pg_stat_statements can be installed without being loaded. Server.SetPassword changes a
+// password the way ALTER ROLE does, to test password rotation.
+//
+// Only the statements the operator runs are understood; anything else fails with an error
+// naming the statement, so new SQL in the operator needs a matching case here.
+package pgtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// servers counts the registered drivers, which database/sql cannot unregister
+var servers atomic.Int64
+
+// Stats is what the statistics views of the server return
+type Stats struct {
+	// Connections counts client connections by state, as in pg_stat_activity
+	Connections map[string]int32
+
+	// MaxConnections is the max_connections setting
+	MaxConnections int32
+
+	// LongestTransactionSeconds is the age of the oldest open transaction
+	LongestTransactionSeconds int64
+
+	// StatementsInstalled lists pg_stat_statements in pg_extension; StatementsLoaded also
+	// puts it in shared_preload_libraries, without which its view cannot be read
+	StatementsInstalled bool
+	StatementsLoaded    bool
+
+	// Statements are the rows of pg_stat_statements in the current database
+	Statements []Statement
+}
+
+// Statement is a row of pg_stat_statements
+type Statement struct {
+	QueryID          string
+	Query            string
+	Calls            int64
+	MeanMilliseconds int64
+}
+
+// role is a login role of a host and the databases it may connect to
+type role struct {
+	password  string
+	databases map[string]bool
+}
+
+// Server is an in-memory PostgreSQL server. It is safe for concurrent use.
+type Server struct {
+	driverName string
+
+	mu      sync.Mutex
+	hosts   map[string]map[string]*role
+	stats   Stats
+	down    error
+	open    int
+	queries []string
+}
+
+// NewServer returns a server without roles and registers its driver
+func NewServer() *Server {
+	s := &Server{
+		driverName: fmt.Sprintf("pgtest-%d", servers.Add(1)),
+		hosts:      map[string]map[string]*role{},
+	}
+	sql.Register(s.driverName, &pgDriver{server: s})
+	return s
+}
+
+// DriverName is the database/sql driver name connecting to the server
+func (s *Server) DriverName() string {
+	return s.driverName
+}
+
+// AddRole lets user log in with password at host, which is host:port as in connection URLs,
+// to the given databases
+func (s *Server) AddRole(host, user, password string, databases ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hosts[host] == nil {
+		s.hosts[host] = map[string]*role{}
+	}
+	r := &role{password: password, databases: map[string]bool{}}
+	for _, database := range databases {
+		r.databases[database] = true
+	}
+	s.hosts[host][user] = r
+}
+
+// SetPassword changes the password of a role, like ALTER ROLE ... PASSWORD. Open
+// connections stay open; new ones need the new password.
+func (s *Server) SetPassword(host, user, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.hosts[host][user]
+	if !ok {
+		return fmt.Errorf("role %q does not exist", user)
+	}
+	r.password = password
+	return nil
+}
+
+// SetStats sets what the statistics views return
+func (s *Server) SetStats(stats Stats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = stats
+}
+
+// SetDown makes new connections fail with err, e.g. while the pod restarts; nil brings the
+// server back
+func (s *Server) SetDown(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = err
+}
+
+// OpenConnections is the number of connections that are not closed
+func (s *Server) OpenConnections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.open
+}
+
+// Queries returns the statements run so far, in order
+func (s *Server) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+// connect authenticates a connection URL
+func (s *Server) connect(dsn string) (*conn, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return nil, fmt.Errorf("invalid connection URL %q", dsn)
+	}
+	user := u.User.Username()
+	password, _ := u.User.Password()
+	database := strings.TrimPrefix(u.Path, "/")
+	if database == "" {
+		database = user
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down != nil {
+		return nil, s.down
+	}
+	roles, ok := s.hosts[u.Host]
+	if !ok {
+		return nil, fmt.Errorf("dial tcp: lookup %s: no such host", u.Hostname())
+	}
+	r, ok := roles[user]
+	if !ok || r.password != password {
+		return nil, fmt.Errorf("password authentication failed for user %q", user)
+	}
+	if !r.databases[database] {
+		return nil, fmt.Errorf("database %q does not exist", database)
+	}
+	s.open++
+	return &conn{server: s}, nil
+}
+
+// query answers one statement
+func (s *Server) query(statement string, args []driver.NamedValue) (driver.Rows, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = append(s.queries, statement)
+	stats := s.stats
+
+	switch {
+	case strings.Contains(statement, "FROM pg_stat_activity") && strings.Contains(statement, "GROUP BY"):
+		states := make([]string, 0, len(stats.Connections))
+		for state := range stats.Connections {
+			states = append(states, state)
+		}
+		sort.Strings(states)
+		result := &rows{columns: []string{"state", "count"}}
+		for _, state := range states {
+			result.values = append(result.values, []driver.Value{state, int64(stats.Connections[state])})
+		}
+		return result, nil
+
+	case strings.Contains(statement, "current_setting('max_connections')"):
+		return single("current_setting", int64(stats.MaxConnections)), nil
+
+	case strings.Contains(statement, "FROM pg_stat_activity") && strings.Contains(statement, "xact_start"):
+		return single("coalesce", stats.LongestTransactionSeconds), nil
+
+	case strings.Contains(statement, "FROM pg_extension") && strings.Contains(statement, "pg_stat_statements"):
+		return single("?column?", stats.StatementsInstalled), nil
+
+	case strings.Contains(statement, "FROM pg_stat_statements"):
+		if !stats.StatementsInstalled {
+			return nil, errors.New(`relation "pg_stat_statements" does not exist`)
+		}
+		if !stats.StatementsLoaded {
+			return nil, errors.New(`pg_stat_statements must be loaded via "shared_preload_libraries"`)
+		}
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 arguments, got %d", len(args))
+		}
+		textLength, limit := args[0].Value.(int64), args[1].Value.(int64)
+		statements := append([]Statement(nil), stats.Statements...)
+		sort.SliceStable(statements, func(i, j int) bool {
+			return statements[i].MeanMilliseconds > statements[j].MeanMilliseconds
+		})
+		result := &rows{columns: []string{"queryid", "left", "calls", "round"}}
+		for _, statement := range statements {
+			if int64(len(result.values)) == limit {
+				break
+			}
+			if statement.Calls == 0 {
+				continue
+			}
+			text := statement.Query
+			if int64(len(text)) > textLength {
+				text = text[:textLength]
+			}
+			result.values = append(result.values, []driver.Value{statement.QueryID, text, statement.Calls, statement.MeanMilliseconds})
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("pgtest: unsupported statement: %s", statement)
+}
+
+func (s *Server) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.open--
+}
+
+type pgDriver struct {
+	server *Server
+}
+
+func (d *pgDriver) Open(dsn string) (driver.Conn, error) {
+	return d.server.connect(dsn)
+}
+
+// conn is one connection; it does not support transactions
+type conn struct {
+	server *Server
+	closed bool
+}
+
+var _ driver.QueryerContext = &conn{}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.server.query(query, args)
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	if !c.closed {
+		c.closed = true
+		c.server.close()
+	}
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("pgtest: transactions are not supported")
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("pgtest: unsupported statement: %s", s.query)
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return s.conn.server.query(s.query, named)
+}
+
+// rows is a materialized result
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func single(column string, value driver.Value) *rows {
+	return &rows{columns: []string{column}, values: [][]driver.Value{{value}}}
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
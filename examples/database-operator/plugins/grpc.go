@@ -0,0 +1,201 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// hookService is the gRPC service of plugin.proto
+const hookService = "plugins.v1.Hook"
+
+// hookServiceDesc serves a Hook as the service of plugin.proto
+var hookServiceDesc = grpc.ServiceDesc{
+	ServiceName: hookService,
+	HandlerType: (*Hook)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Points", Handler: handlePoints},
+		{MethodName: "Call", Handler: handleCall},
+	},
+	Metadata: "plugin.proto",
+}
+
+// pointsRequest and pointsResponse are the messages of Hook.Points; Request and Response are
+// those of Hook.Call
+type pointsRequest struct{}
+
+type pointsResponse struct {
+	Points []Point
+}
+
+func handlePoints(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+	if err := dec(&pointsRequest{}); err != nil {
+		return nil, err
+	}
+	points, err := srv.(Hook).Points(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pointsResponse{Points: points}, nil
+}
+
+func handleCall(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &Request{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	response, err := srv.(Hook).Call(ctx, *req)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// codec encodes the messages of plugin.proto in the protobuf wire format. They are four flat
+// messages, so they are encoded by hand instead of with generated code; plugins in other
+// languages use code generated from plugin.proto.
+type codec struct{}
+
+// Name is the content subtype of the calls, the one generated code uses
+func (codec) Name() string {
+	return "proto"
+}
+
+// Marshal encodes a message, leaving out fields with their zero value like proto3 does
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	var b []byte
+	switch m := v.(type) {
+	case *pointsRequest:
+	case *pointsResponse:
+		for _, point := range m.Points {
+			b = protowire.AppendTag(b, 1, protowire.BytesType)
+			b = protowire.AppendString(b, string(point))
+		}
+	case *Request:
+		b = appendBytes(b, 1, []byte(m.Point))
+		b = appendBytes(b, 2, m.Object)
+	case *Response:
+		if m.Allowed {
+			b = protowire.AppendTag(b, 1, protowire.VarintType)
+			b = protowire.AppendVarint(b, 1)
+		}
+		b = appendBytes(b, 2, []byte(m.Message))
+	default:
+		return nil, fmt.Errorf("plugins: cannot encode %T", v)
+	}
+	return b, nil
+}
+
+// Unmarshal decodes a message, skipping fields it does not know
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var value []byte
+		var varint uint64
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch m := v.(type) {
+		case *pointsRequest:
+		case *pointsResponse:
+			if num == 1 && typ == protowire.BytesType {
+				m.Points = append(m.Points, Point(value))
+			}
+		case *Request:
+			switch {
+			case num == 1 && typ == protowire.BytesType:
+				m.Point = Point(value)
+			case num == 2 && typ == protowire.BytesType:
+				// The buffer belongs to gRPC once Unmarshal returns
+				m.Object = append(json.RawMessage(nil), value...)
+			}
+		case *Response:
+			switch {
+			case num == 1 && typ == protowire.VarintType:
+				m.Allowed = varint != 0
+			case num == 2 && typ == protowire.BytesType:
+				m.Message = string(value)
+			}
+		default:
+			return fmt.Errorf("plugins: cannot decode %T", v)
+		}
+	}
+	return nil
+}
+
+// appendBytes appends a bytes or string field unless it is empty
+func appendBytes(b []byte, num protowire.Number, value []byte) []byte {
+	if len(value) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, value)
+}
+
+// connListener hands the one connection of the operator to the gRPC server. The server is
+// stopped once that connection closes, so a plugin does not outlive the operator.
+type connListener struct {
+	conns chan net.Conn
+	addr  net.Addr
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newConnListener(conn net.Conn, server *grpc.Server) *connListener {
+	l := &connListener{conns: make(chan net.Conn, 1), addr: conn.LocalAddr(), done: make(chan struct{})}
+	// Stop closes the listener and waits for the connection, so it cannot run in Close
+	l.conns <- &closeNotifier{Conn: conn, closed: func() { go server.Stop() }}
+	return l
+}
+
+// Accept returns the connection once, then blocks until the listener is closed
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
+
+// closeNotifier calls closed the first time the connection is closed
+type closeNotifier struct {
+	net.Conn
+	closed func()
+	once   sync.Once
+}
+
+func (c *closeNotifier) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.closed)
+	return err
+}
@@ -0,0 +1,207 @@
+// Package plugins lets site-specific logic run at defined points of a Database's life without
+// forking the controller: before its children are first created, when it becomes ready, and
+// before its finalizer is removed.
+//
+// A plugin is a separate executable. The operator starts it, reads a handshake line from its
+// stdout and calls it over the connection the handshake names, the way hashicorp/go-plugin
+// does: the handshake has the same "<core>|<app>|<network>|<address>|<protocol>" format and
+// the plugin must see the magic cookie in its environment, so running it by hand fails
+// clearly instead of hanging. Plugins are written against Hook and served with Serve:
+//
+//	type quota struct{}
+//
+//	func (quota) Points(context.Context) ([]plugins.Point, error) {
+//		return []plugins.Point{plugins.PreProvision}, nil
+//	}
+//
+//	func (quota) Call(ctx context.Context, req plugins.Request) (plugins.Response, error) {
+//		// req.Object is the Database as JSON
+//		return plugins.Response{Allowed: true}, nil
+//	}
+//
+//	func main() {
+//		if err := plugins.Serve(quota{}); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//
+// The calls use gRPC, the protocol go-plugin uses for plugins in any language, with the
+// service of plugin.proto: a plugin in another language generates its server from it, prints
+// the handshake line with "grpc" as protocol and answers on the address it named. Each call
+// carries the plugin timeout as its deadline.
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Point is where in the life of a resource a hook is called
+type Point string
+
+const (
+	// PreProvision is called before the children of a resource are first created. A denial
+	// holds the provisioning back until a later call allows it.
+	PreProvision Point = "pre-provision"
+
+	// PostReady is called when the resource becomes ready, again after each time it was not.
+	// The response is ignored.
+	PostReady Point = "post-ready"
+
+	// PreDelete is called before the finalizer of a deleted resource is removed. A denial
+	// keeps the finalizer, and so the resource, until a later call allows it. In a namespace
+	// being deleted it is called once and cannot deny: everything in the namespace goes, and
+	// holding the resource would only hold up the namespace.
+	PreDelete Point = "pre-delete"
+)
+
+// DefaultTimeout bounds a call of one hook
+const DefaultTimeout = 30 * time.Second
+
+// Request is what a hook is called with
+type Request struct {
+	// Point the hook is called at
+	Point Point `json:"point"`
+
+	// Object is the resource as JSON, including its status
+	Object json.RawMessage `json:"object"`
+}
+
+// Response is what a hook answers
+type Response struct {
+	// Allowed lets the operator go on; it is ignored at PostReady
+	Allowed bool `json:"allowed"`
+
+	// Message explains a denial; it is shown in the conditions and events of the resource
+	Message string `json:"message,omitempty"`
+}
+
+// Hook is site-specific logic called at the points it registers for
+type Hook interface {
+	// Points returns the points the hook is called at
+	Points(ctx context.Context) ([]Point, error)
+
+	// Call runs the hook. An error, unlike a denial, means the hook could not decide; the
+	// operator retries it the same way.
+	Call(ctx context.Context, req Request) (Response, error)
+}
+
+// Registry calls the registered hooks in the order they were added
+type Registry struct {
+	// Timeout bounds a call of one hook; DefaultTimeout when zero
+	Timeout time.Duration
+
+	hooks []namedHook
+}
+
+type namedHook struct {
+	name string
+	hook Hook
+}
+
+// Add registers a hook under a name used in messages
+func (r *Registry) Add(name string, hook Hook) {
+	r.hooks = append(r.hooks, namedHook{name: name, hook: hook})
+}
+
+// BindFlags registers flags for the registry, using the current values as defaults. Each
+// --plugin adds a plugin executable, named after its file, in the order given.
+func (r *Registry) BindFlags(fs *flag.FlagSet) {
+	fs.Func("plugin", "Path of a plugin executable called at the points it registers for; may be repeated.", func(path string) error {
+		r.Add(filepath.Base(path), &Process{Path: path})
+		return nil
+	})
+	fs.DurationVar(&r.Timeout, "plugin-timeout", r.Timeout, "How long a plugin may take to answer one call.")
+}
+
+// Len returns how many hooks are registered
+func (r *Registry) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.hooks)
+}
+
+// Run calls every hook registered for the point with the object, stopping at the first denial
+// or error. A nil or empty registry allows everything.
+func (r *Registry) Run(ctx context.Context, point Point, object client.Object) (Response, error) {
+	if r.Len() == 0 {
+		return Response{Allowed: true}, nil
+	}
+	content, err := json.Marshal(object)
+	if err != nil {
+		return Response{}, err
+	}
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	for _, named := range r.hooks {
+		points, err := named.hook.Points(ctx)
+		if err != nil {
+			return Response{}, fmt.Errorf("plugin %s: %w", named.name, err)
+		}
+		if !hasPoint(points, point) {
+			continue
+		}
+		response, err := call(ctx, timeout, named.hook, Request{Point: point, Object: content})
+		if err != nil {
+			return Response{}, fmt.Errorf("plugin %s: %w", named.name, err)
+		}
+		log.FromContext(ctx).V(1).Info("Called plugin", "plugin", named.name, "point", point, "allowed", response.Allowed)
+		if !response.Allowed && point != PostReady {
+			response.Message = fmt.Sprintf("plugin %s: %s", named.name, response.Message)
+			return response, nil
+		}
+	}
+	return Response{Allowed: true}, nil
+}
+
+// Stop stops the plugin processes started by the hooks
+func (r *Registry) Stop() {
+	if r == nil {
+		return
+	}
+	for _, named := range r.hooks {
+		if process, ok := named.hook.(*Process); ok {
+			process.Stop()
+		}
+	}
+}
+
+// Start stops the plugin processes once the context is cancelled, so the registry can be added
+// to a manager
+func (r *Registry) Start(ctx context.Context) error {
+	<-ctx.Done()
+	r.Stop()
+	return nil
+}
+
+// NeedLeaderElection is false: the processes must be stopped on every replica that started them
+func (r *Registry) NeedLeaderElection() bool {
+	return false
+}
+
+// call runs one hook within the timeout
+func call(ctx context.Context, timeout time.Duration, hook Hook, req Request) (Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return hook.Call(ctx, req)
+}
+
+func hasPoint(points []Point, point Point) bool {
+	for _, p := range points {
+		if p == point {
+			return true
+		}
+	}
+	return false
+}
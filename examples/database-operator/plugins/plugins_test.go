@@ -0,0 +1,160 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The test binary is its own plugin: started with the magic cookie, it serves testHook
+func TestMain(m *testing.M) {
+	if os.Getenv(MagicCookieKey) == MagicCookieValue {
+		if err := Serve(testHook{}); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// testHook denies objects labelled deny=true and exits on objects labelled crash=true
+type testHook struct{}
+
+func (testHook) Points(context.Context) ([]Point, error) {
+	return []Point{PreProvision, PreDelete}, nil
+}
+
+func (testHook) Call(_ context.Context, req Request) (Response, error) {
+	var object metav1.PartialObjectMetadata
+	if err := json.Unmarshal(req.Object, &object); err != nil {
+		return Response{}, err
+	}
+	switch {
+	case object.Labels["crash"] == "true":
+		os.Exit(3)
+	case object.Labels["deny"] == "true":
+		return Response{Allowed: false, Message: string(req.Point) + " denied for " + object.Name}, nil
+	}
+	return Response{Allowed: true}, nil
+}
+
+// funcHook is an in-process hook
+type funcHook struct {
+	points []Point
+	call   func(Request) (Response, error)
+}
+
+func (h funcHook) Points(context.Context) ([]Point, error) { return h.points, nil }
+
+func (h funcHook) Call(_ context.Context, req Request) (Response, error) { return h.call(req) }
+
+func configMap(labels map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop", Labels: labels}}
+}
+
+func TestProcess(t *testing.T) {
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	registry := &Registry{}
+	registry.Add("test", &Process{Path: executable})
+	defer registry.Stop()
+	ctx := context.Background()
+
+	response, err := registry.Run(ctx, PreProvision, configMap(nil))
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+
+	response, err = registry.Run(ctx, PreDelete, configMap(map[string]string{"deny": "true"}))
+	require.NoError(t, err)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, "plugin test: pre-delete denied for orders", response.Message)
+
+	// The plugin did not register for post-ready
+	response, err = registry.Run(ctx, PostReady, configMap(map[string]string{"deny": "true"}))
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+
+	// A crashed plugin fails the call and is started again for the next one
+	_, err = registry.Run(ctx, PreProvision, configMap(map[string]string{"crash": "true"}))
+	assert.Error(t, err)
+	response, err = registry.Run(ctx, PreProvision, configMap(nil))
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+}
+
+func TestServe_RequiresMagicCookie(t *testing.T) {
+	t.Setenv(MagicCookieKey, "")
+	assert.ErrorContains(t, Serve(testHook{}), "started by the operator")
+}
+
+func TestRegistry_Run(t *testing.T) {
+	var calls []string
+	record := func(name string, response Response, err error) Hook {
+		return funcHook{points: []Point{PreProvision, PostReady}, call: func(req Request) (Response, error) {
+			calls = append(calls, name+":"+string(req.Point))
+			return response, err
+		}}
+	}
+	ctx := context.Background()
+
+	var empty *Registry
+	response, err := empty.Run(ctx, PreProvision, configMap(nil))
+	require.NoError(t, err)
+	assert.True(t, response.Allowed, "No plugins allow everything")
+
+	registry := &Registry{}
+	registry.Add("quota", record("quota", Response{Allowed: false, Message: "over quota"}, nil))
+	registry.Add("cmdb", record("cmdb", Response{Allowed: true}, nil))
+
+	response, err = registry.Run(ctx, PreProvision, configMap(nil))
+	require.NoError(t, err)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, "plugin quota: over quota", response.Message)
+	assert.Equal(t, []string{"quota:pre-provision"}, calls, "A denial stops the later plugins")
+
+	// Denials are ignored after the fact
+	calls = nil
+	response, err = registry.Run(ctx, PostReady, configMap(nil))
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+	assert.Equal(t, []string{"quota:post-ready", "cmdb:post-ready"}, calls)
+
+	failing := &Registry{}
+	failing.Add("cmdb", record("cmdb", Response{}, errors.New("connection refused")))
+	_, err = failing.Run(ctx, PreProvision, configMap(nil))
+	assert.ErrorContains(t, err, "plugin cmdb: connection refused")
+}
+
+func TestCodec(t *testing.T) {
+	messages := []interface{}{
+		&pointsResponse{Points: []Point{PreProvision, PreDelete}},
+		&Request{Point: PreDelete, Object: json.RawMessage(`{"metadata":{"name":"orders"}}`)},
+		&Response{Allowed: true},
+		&Response{Message: "over quota"},
+	}
+	for _, message := range messages {
+		data, err := codec{}.Marshal(message)
+		require.NoError(t, err)
+		decoded := reflect.New(reflect.TypeOf(message).Elem()).Interface()
+		require.NoError(t, codec{}.Unmarshal(data, decoded))
+		assert.Equal(t, message, decoded)
+	}
+
+	// Fields added to plugin.proto later are skipped by older operators
+	data, err := codec{}.Marshal(&Response{Message: "denied"})
+	require.NoError(t, err)
+	data = protowire.AppendTag(data, 3, protowire.VarintType)
+	data = protowire.AppendVarint(data, 7)
+	response := &Response{}
+	require.NoError(t, codec{}.Unmarshal(data, response))
+	assert.Equal(t, &Response{Message: "denied"}, response)
+}
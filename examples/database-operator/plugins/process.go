@@ -0,0 +1,258 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// The handshake: the operator sets the magic cookie in the environment of the plugin, which
+// answers with "<CoreProtocolVersion>|<ProtocolVersion>|<network>|<address>|grpc" on stdout
+const (
+	// CoreProtocolVersion is go-plugin's version of the handshake line
+	CoreProtocolVersion = 1
+
+	// ProtocolVersion is the version of Request and Response; it changes when they change
+	// incompatibly
+	ProtocolVersion = 1
+
+	// MagicCookieKey and MagicCookieValue tell a plugin it was started by the operator
+	MagicCookieKey   = "DATABASE_OPERATOR_PLUGIN"
+	MagicCookieValue = "0f3c9b1e5d7a4e2c8b6a1d9f3e5c7b2a"
+
+	// protocolGRPC is go-plugin's name for plugins serving gRPC
+	protocolGRPC = "grpc"
+)
+
+// handshakeTimeout bounds how long a starting plugin may take to print its handshake
+const handshakeTimeout = 10 * time.Second
+
+// Process is a Hook run by a plugin executable. The executable is started on the first call and
+// started again after it exits, so a crashing plugin fails the calls made while it is down but
+// not the ones after.
+type Process struct {
+	// Path of the executable
+	Path string
+
+	// Args it is started with
+	Args []string
+
+	// Stderr receives what the plugin writes to stderr; os.Stderr when nil
+	Stderr io.Writer
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	client *grpc.ClientConn
+	exited chan struct{}
+	points []Point
+}
+
+var _ Hook = &Process{}
+
+// Points returns the points the plugin registered for when it started
+func (p *Process) Points(ctx context.Context) ([]Point, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.start(ctx); err != nil {
+		return nil, err
+	}
+	return p.points, nil
+}
+
+// Call calls the plugin, starting it if it is not running
+func (p *Process) Call(ctx context.Context, req Request) (Response, error) {
+	p.mu.Lock()
+	if err := p.start(ctx); err != nil {
+		p.mu.Unlock()
+		return Response{}, err
+	}
+	client := p.client
+	p.mu.Unlock()
+
+	var response Response
+	if err := client.Invoke(ctx, "/"+hookService+"/Call", &req, &response); err != nil {
+		st := status.Convert(err)
+		switch st.Code() {
+		case codes.Unavailable:
+			// The plugin exited or closed the connection
+			p.Stop()
+		case codes.Unknown:
+			// The error the hook returned
+			return Response{}, errors.New(st.Message())
+		}
+		return Response{}, err
+	}
+	return response, nil
+}
+
+// Stop kills the plugin; the next call starts it again
+func (p *Process) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+}
+
+// start runs the executable and connects to it unless it is running
+func (p *Process) start(ctx context.Context) error {
+	if p.client != nil {
+		select {
+		case <-p.exited:
+			p.stop()
+		default:
+			return nil
+		}
+	}
+
+	cmd := exec.Command(p.Path, p.Args...)
+	cmd.Env = append(os.Environ(), MagicCookieKey+"="+MagicCookieValue)
+	cmd.Stderr = p.Stderr
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", p.Path, err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+
+	network, address, err := readHandshake(ctx, stdout, exited)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("%s: %w", p.Path, err)
+	}
+	// Anything printed after the handshake is discarded; plugins log to stderr
+	go func() { _, _ = io.Copy(io.Discard, stdout) }()
+
+	client, err := grpc.Dial("passthrough:///"+address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		}),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(codec{})))
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("failed to connect to %s: %w", p.Path, err)
+	}
+	pointsCtx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
+	var points pointsResponse
+	if err := client.Invoke(pointsCtx, "/"+hookService+"/Points", &pointsRequest{}, &points); err != nil {
+		_ = client.Close()
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("%s: %w", p.Path, err)
+	}
+
+	p.cmd, p.client, p.exited, p.points = cmd, client, exited, points.Points
+	return nil
+}
+
+// stop kills the running executable, if any
+func (p *Process) stop() {
+	if p.client == nil {
+		return
+	}
+	_ = p.client.Close()
+	_ = p.cmd.Process.Kill()
+	<-p.exited
+	p.cmd, p.client, p.exited, p.points = nil, nil, nil, nil
+}
+
+// readHandshake reads and checks the handshake line of a starting plugin
+func readHandshake(ctx context.Context, stdout io.Reader, exited <-chan struct{}) (string, string, error) {
+	lines := make(chan string, 1)
+	errs := make(chan error, 1)
+	go func() {
+		line, err := bufio.NewReader(stdout).ReadString('\n')
+		if err != nil {
+			errs <- fmt.Errorf("no handshake: %w", err)
+			return
+		}
+		lines <- strings.TrimSpace(line)
+	}()
+
+	timer := time.NewTimer(handshakeTimeout)
+	defer timer.Stop()
+	var line string
+	select {
+	case line = <-lines:
+	case err := <-errs:
+		return "", "", err
+	case <-exited:
+		return "", "", errors.New("exited before the handshake")
+	case <-timer.C:
+		return "", "", errors.New("timed out waiting for the handshake")
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
+
+	parts := strings.Split(line, "|")
+	if len(parts) < 5 {
+		return "", "", fmt.Errorf("invalid handshake %q", line)
+	}
+	if parts[0] != strconv.Itoa(CoreProtocolVersion) {
+		return "", "", fmt.Errorf("unsupported core protocol version %s, expected %d", parts[0], CoreProtocolVersion)
+	}
+	if parts[1] != strconv.Itoa(ProtocolVersion) {
+		return "", "", fmt.Errorf("unsupported protocol version %s, expected %d", parts[1], ProtocolVersion)
+	}
+	if parts[4] != protocolGRPC {
+		return "", "", fmt.Errorf("unsupported protocol %q, expected %s", parts[4], protocolGRPC)
+	}
+	return parts[2], parts[3], nil
+}
+
+// Serve serves a hook to the operator that started the executable and returns once the
+// operator disconnects, so a plugin does not outlive it. It fails when the executable was not
+// started by the operator.
+func Serve(hook Hook) error {
+	if os.Getenv(MagicCookieKey) != MagicCookieValue {
+		return errors.New("this is a database-operator plugin; it is started by the operator, not by hand")
+	}
+
+	dir, err := os.MkdirTemp("", "database-operator-plugin")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	listener, err := net.Listen("unix", filepath.Join(dir, "plugin.sock"))
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	server := grpc.NewServer(grpc.ForceServerCodec(codec{}))
+	server.RegisterService(&hookServiceDesc, hook)
+	fmt.Printf("%d|%d|%s|%s|%s\n", CoreProtocolVersion, ProtocolVersion,
+		listener.Addr().Network(), listener.Addr().String(), protocolGRPC)
+	conn, err := listener.Accept()
+	if err != nil {
+		return err
+	}
+	// The operator is connected and the socket is not needed any more; remove it now, since
+	// a plugin stopped by the operator is killed before its deferred calls run
+	listener.Close()
+	os.RemoveAll(dir)
+	return server.Serve(newConnListener(conn, server))
+}
@@ -0,0 +1,171 @@
+// Package policy evaluates policies written by cluster admins against the objects an operator
+// is about to write. Admission webhooks see an object once it reaches the API server; these
+// policies run in the reconcile, before the write, so a child that breaks one is never sent
+// and the owner can report why.
+//
+// A policy is a CEL expression over the object as `object`, in its unstructured form. It must
+// evaluate to true for a compliant object:
+//
+//	kinds: [Deployment, StatefulSet]
+//	expression: object.spec.template.spec.containers.all(c, !c.image.endsWith(':latest'))
+//	message: database images must be pinned
+//
+// Fields missing from the object fail the evaluation; guard them with has(), as in
+// `!has(object.spec.type) || object.spec.type != 'LoadBalancer'`.
+//
+// Only CEL is supported. Rego would bring in OPA and its dependencies, which are far larger
+// than the policies they would evaluate here; CEL is what Kubernetes itself uses for
+// ValidatingAdmissionPolicy, so admins can reuse the expressions they write for it.
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"sigs.k8s.io/yaml"
+)
+
+// costLimit bounds the evaluation of one policy, so a policy iterating over large lists
+// cannot stall the reconcile. It is the per-expression limit of ValidatingAdmissionPolicy.
+const costLimit = 1000000
+
+// Policy is one rule objects of some kinds must satisfy
+type Policy struct {
+	// Name identifies the policy in violations
+	Name string `json:"-"`
+
+	// Kinds the policy applies to, e.g. Deployment; empty applies it to every kind
+	Kinds []string `json:"kinds,omitempty"`
+
+	// Expression is a CEL expression over `object` that is true for compliant objects
+	Expression string `json:"expression"`
+
+	// Message explains a violation; the expression is shown when it is empty
+	Message string `json:"message,omitempty"`
+}
+
+// Violation is a policy an object breaks
+type Violation struct {
+	Policy  string
+	Kind    string
+	Name    string
+	Message string
+}
+
+// String describes the violation for conditions and events
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %s violates policy %s: %s", v.Kind, v.Name, v.Policy, v.Message)
+}
+
+// Parse reads policies from the data of a ConfigMap: every key names a policy and holds it
+// as YAML. The policies are returned sorted by name.
+func Parse(data map[string]string) ([]Policy, error) {
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	policies := make([]Policy, 0, len(names))
+	for _, name := range names {
+		var p Policy
+		if err := yaml.UnmarshalStrict([]byte(data[name]), &p); err != nil {
+			return nil, fmt.Errorf("policy %s: %w", name, err)
+		}
+		if strings.TrimSpace(p.Expression) == "" {
+			return nil, fmt.Errorf("policy %s: expression is required", name)
+		}
+		p.Name = name
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// Set is a set of compiled policies
+type Set struct {
+	policies []compiled
+}
+
+type compiled struct {
+	Policy
+	program cel.Program
+}
+
+// Compile type-checks the policies. Every expression must evaluate to a bool.
+func Compile(policies []Policy) (*Set, error) {
+	env, err := cel.NewEnv(cel.Variable("object", cel.DynType))
+	if err != nil {
+		return nil, err
+	}
+	set := &Set{}
+	for _, p := range policies {
+		ast, issues := env.Compile(p.Expression)
+		if issues.Err() != nil {
+			return nil, fmt.Errorf("policy %s: %w", p.Name, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return nil, fmt.Errorf("policy %s: expression must evaluate to a bool, not %s", p.Name, ast.OutputType())
+		}
+		program, err := env.Program(ast, cel.CostLimit(costLimit))
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", p.Name, err)
+		}
+		set.policies = append(set.policies, compiled{Policy: p, program: program})
+	}
+	return set, nil
+}
+
+// Len returns how many policies the set holds
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.policies)
+}
+
+// Evaluate returns the policies the object of the kind breaks, in policy order. A policy that
+// fails to evaluate, for example on a missing field, counts as broken, so a mistake in a policy
+// blocks writes instead of letting them through unchecked.
+func (s *Set) Evaluate(kind, name string, object map[string]interface{}) []Violation {
+	var violations []Violation
+	for _, p := range s.policiesFor(kind) {
+		message := p.Message
+		if message == "" {
+			message = "expected " + p.Expression
+		}
+		out, _, err := p.program.Eval(map[string]interface{}{"object": object})
+		switch {
+		case err != nil:
+			message = "evaluation failed: " + err.Error()
+		case out.Value() == true:
+			continue
+		case out.Value() != false:
+			message = fmt.Sprintf("evaluated to %v, not a bool", out.Value())
+		}
+		violations = append(violations, Violation{Policy: p.Name, Kind: kind, Name: name, Message: message})
+	}
+	return violations
+}
+
+// policiesFor returns the policies applying to a kind
+func (s *Set) policiesFor(kind string) []compiled {
+	if s == nil {
+		return nil
+	}
+	var matching []compiled
+	for _, p := range s.policies {
+		if len(p.Kinds) == 0 {
+			matching = append(matching, p)
+			continue
+		}
+		for _, k := range p.Kinds {
+			if k == kind {
+				matching = append(matching, p)
+				break
+			}
+		}
+	}
+	return matching
+}
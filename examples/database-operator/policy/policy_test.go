@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func deployment(image string) map[string]interface{} {
+	return map[string]interface{}{
+		"kind": "Deployment",
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{"name": "postgres", "image": image}},
+				},
+			},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	policies, err := Parse(map[string]string{
+		"pinned-images": "kinds: [Deployment]\nexpression: object.spec.template.spec.containers.all(c, !c.image.endsWith(':latest'))\nmessage: images must be pinned\n",
+		"has-labels":    "expression: has(object.metadata.labels)\n",
+	})
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+	assert.Equal(t, "has-labels", policies[0].Name, "Policies are sorted by name")
+	assert.Empty(t, policies[0].Kinds)
+	assert.Equal(t, []string{"Deployment"}, policies[1].Kinds)
+
+	_, err = Parse(map[string]string{"empty": "kinds: [Service]\n"})
+	assert.ErrorContains(t, err, "policy empty: expression is required")
+	_, err = Parse(map[string]string{"typo": "expresion: true\n"})
+	assert.ErrorContains(t, err, "policy typo")
+}
+
+func TestCompile(t *testing.T) {
+	_, err := Compile([]Policy{{Name: "broken", Expression: "object.spec.replicas <"}})
+	assert.ErrorContains(t, err, "policy broken")
+
+	_, err = Compile([]Policy{{Name: "string", Expression: "'yes'"}})
+	assert.ErrorContains(t, err, "must evaluate to a bool")
+
+	set, err := Compile(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, set.Len())
+}
+
+func TestSet_Evaluate(t *testing.T) {
+	set, err := Compile([]Policy{
+		{
+			Name:       "pinned-images",
+			Kinds:      []string{"Deployment", "StatefulSet"},
+			Expression: "object.spec.template.spec.containers.all(c, !c.image.endsWith(':latest'))",
+			Message:    "images must be pinned",
+		},
+		{
+			Name:       "no-load-balancers",
+			Kinds:      []string{"Service"},
+			Expression: "!has(object.spec.type) || object.spec.type != 'LoadBalancer'",
+		},
+		{Name: "replicas", Kinds: []string{"Deployment"}, Expression: "object.spec.replicas <= 5"},
+	})
+	require.NoError(t, err)
+
+	violations := set.Evaluate("Deployment", "shop/orders", deployment("postgres:latest"))
+	require.Len(t, violations, 2)
+	assert.Equal(t, Violation{Policy: "pinned-images", Kind: "Deployment", Name: "shop/orders", Message: "images must be pinned"}, violations[0])
+	assert.Equal(t, "Deployment shop/orders violates policy pinned-images: images must be pinned", violations[0].String())
+	assert.Equal(t, "replicas", violations[1].Policy)
+	assert.Contains(t, violations[1].Message, "evaluation failed", "A missing field counts as a violation")
+
+	object := deployment("postgres:15")
+	object["spec"].(map[string]interface{})["replicas"] = int64(3)
+	assert.Empty(t, set.Evaluate("Deployment", "shop/orders", object))
+
+	service := map[string]interface{}{"spec": map[string]interface{}{"type": "LoadBalancer"}}
+	violations = set.Evaluate("Service", "shop/orders", service)
+	require.Len(t, violations, 1)
+	assert.Equal(t, "expected !has(object.spec.type) || object.spec.type != 'LoadBalancer'", violations[0].Message)
+	assert.Empty(t, set.Evaluate("ConfigMap", "shop/orders", service), "Policies only apply to their kinds")
+
+	var none *Set
+	assert.Empty(t, none.Evaluate("Deployment", "shop/orders", deployment("postgres:latest")))
+}
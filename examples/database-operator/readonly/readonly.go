@@ -0,0 +1,140 @@
+// Package readonly guards the objects the operator reads from the shared informer cache
+// without copying them: lists with client.UnsafeDisableDeepCopy in map functions, pod
+// summaries and child pruning, and the objects of watch events. Changing one corrupts the
+// cache for every controller, and the fake client copies on every read even with
+// UnsafeDisableDeepCopy, so such a change passes every unit test. Tests reconcile through a
+// Guard and Check it at the end; a change shows up as a diff of the object.
+//
+// Code that needs to change a shared object changes a DeepCopy of it instead.
+package readonly
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Guard is a client for tests that records every object it returns shared with the cache and
+// reports those changed since. Objects are shared when listed with client.UnsafeDisableDeepCopy,
+// when their type is one of the Shared types, or when passed to Track.
+type Guard struct {
+	client.Client
+
+	// Shared are the types the cache of the code under test serves without copying, e.g.
+	// &corev1.Pod{} for cache.ByObject{Object: &corev1.Pod{}, UnsafeDisableDeepCopy: ptr.To(true)}.
+	// Every Get and List of them is tracked.
+	Shared []client.Object
+
+	mu    sync.Mutex
+	reads []read
+}
+
+// read is an object returned shared with the cache and its content at the time
+type read struct {
+	obj      runtime.Object
+	snapshot map[string]interface{}
+}
+
+// NewGuard guards the reads through c; shared are the types cached without copying
+func NewGuard(c client.Client, shared ...client.Object) *Guard {
+	return &Guard{Client: c, Shared: shared}
+}
+
+// Get reads the object and tracks it when its type is shared
+func (g *Guard) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if err := g.Client.Get(ctx, key, obj, opts...); err != nil {
+		return err
+	}
+	if g.isShared(obj) {
+		g.Track(obj)
+	}
+	return nil
+}
+
+// List lists the objects and tracks the items that the cache would have shared
+func (g *Guard) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := g.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+	uncopied := listOpts.UnsafeDisableDeepCopy != nil && *listOpts.UnsafeDisableDeepCopy
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if obj, ok := item.(client.Object); ok && (uncopied || g.isShared(obj)) {
+			g.Track(obj)
+		}
+	}
+	return nil
+}
+
+// Track records objects handed to the code under test shared with the cache, such as the
+// object of a watch event passed to a map function or a predicate
+func (g *Guard) Track(objs ...client.Object) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, obj := range objs {
+		snapshot, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			panic(fmt.Sprintf("readonly: cannot snapshot %T: %v", obj, err))
+		}
+		g.reads = append(g.reads, read{obj: obj, snapshot: snapshot})
+	}
+}
+
+// Mutations describes every tracked object that changed since it was read, sorted
+func (g *Guard) Mutations() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var mutations []string
+	for _, r := range g.reads {
+		current, err := runtime.DefaultUnstructuredConverter.ToUnstructured(r.obj)
+		if err != nil {
+			mutations = append(mutations, fmt.Sprintf("%s: %v", describe(r.obj), err))
+			continue
+		}
+		if d := cmp.Diff(r.snapshot, current); d != "" {
+			mutations = append(mutations, fmt.Sprintf("%s changed after it was read from the cache (-read +now):\n%s", describe(r.obj), d))
+		}
+	}
+	sort.Strings(mutations)
+	return mutations
+}
+
+// Check fails the test for every tracked object that changed since it was read
+func (g *Guard) Check(t testing.TB) {
+	t.Helper()
+	if mutations := g.Mutations(); len(mutations) > 0 {
+		t.Errorf("objects shared with the cache were changed; change a DeepCopy instead:\n%s",
+			strings.Join(mutations, "\n"))
+	}
+}
+
+// isShared returns true if obj is of one of the Shared types
+func (g *Guard) isShared(obj client.Object) bool {
+	for _, shared := range g.Shared {
+		if reflect.TypeOf(shared) == reflect.TypeOf(obj) {
+			return true
+		}
+	}
+	return false
+}
+
+// describe names an object for a mutation report
+func describe(obj runtime.Object) string {
+	if o, ok := obj.(client.Object); ok {
+		return fmt.Sprintf("%T %s", obj, client.ObjectKeyFromObject(o))
+	}
+	return fmt.Sprintf("%T", obj)
+}
@@ -0,0 +1,74 @@
+package readonly
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newGuardFixture(t *testing.T, shared ...client.Object) *Guard {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "orders-0", Namespace: "shop", Labels: map[string]string{"app": "orders"}}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "orders-config", Namespace: "shop"}},
+		).
+		Build()
+	return NewGuard(fakeClient, shared...)
+}
+
+func TestGuard_UncopiedList(t *testing.T) {
+	guard := newGuardFixture(t)
+	ctx := context.Background()
+
+	var copied corev1.PodList
+	require.NoError(t, guard.List(ctx, &copied, client.InNamespace("shop")))
+	copied.Items[0].Labels["seen"] = "true"
+	assert.Empty(t, guard.Mutations(), "A copied list is the caller's to change")
+
+	var shared corev1.PodList
+	require.NoError(t, guard.List(ctx, &shared, client.InNamespace("shop"), client.UnsafeDisableDeepCopy))
+	_ = shared.Items[0].Labels["app"]
+	assert.Empty(t, guard.Mutations(), "Reading is fine")
+
+	shared.Items[0].Labels["seen"] = "true"
+	mutations := guard.Mutations()
+	require.Len(t, mutations, 1)
+	assert.True(t, strings.HasPrefix(mutations[0], "*v1.Pod shop/orders-0 changed after it was read from the cache"), mutations[0])
+	assert.Contains(t, mutations[0], `"seen"`)
+}
+
+func TestGuard_SharedTypes(t *testing.T) {
+	guard := newGuardFixture(t, &corev1.Pod{})
+	ctx := context.Background()
+
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, guard.Get(ctx, client.ObjectKey{Namespace: "shop", Name: "orders-config"}, configMap))
+	configMap.Data = map[string]string{"changed": "true"}
+	assert.Empty(t, guard.Mutations(), "ConfigMaps are copied by the cache")
+
+	pod := &corev1.Pod{}
+	require.NoError(t, guard.Get(ctx, client.ObjectKey{Namespace: "shop", Name: "orders-0"}, pod))
+	pod.Spec.NodeName = "node-1"
+	assert.Len(t, guard.Mutations(), 1, "Pods are cached without copying")
+}
+
+func TestGuard_Track(t *testing.T) {
+	guard := newGuardFixture(t)
+	event := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "orders-config", Namespace: "shop"}}
+	guard.Track(event)
+	assert.Empty(t, guard.Mutations())
+
+	event.Annotations = map[string]string{"handled": "true"}
+	assert.Len(t, guard.Mutations(), 1)
+}
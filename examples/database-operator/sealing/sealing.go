@@ -0,0 +1,235 @@
+// Package sealing encrypts values an operator has to persist in the status or annotations of
+// a resource, where everyone allowed to read the resource would otherwise see them.
+//
+// A sealed value is a string, so it fits any string field:
+//
+//	sealed:v1:<provider>:<ciphertext, base64>:<key ID>
+//
+// Open returns values without the prefix unchanged, so fields written before sealing was
+// switched on, or by a build without it, keep reading as they are. Every value is bound to a
+// binding, usually the namespace/name of its object: a sealed value copied to another object
+// does not open.
+//
+// Keys rotate without a migration: values are sealed with the current key and opened with
+// the key named in them, so after a new key is introduced each value moves to it the next
+// time it is written. Current reports whether a value already uses the current key.
+package sealing
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix starts every sealed value
+const prefix = "sealed:v1:"
+
+// Providers named in sealed values
+const (
+	providerAES = "aes"
+	providerKMS = "kms"
+)
+
+// ErrUnknownKey is returned for a value sealed with a key the provider no longer has
+var ErrUnknownKey = errors.New("sealed with an unknown key")
+
+// Sealer seals and opens values
+type Sealer interface {
+	// Seal encrypts a value bound to the binding
+	Seal(ctx context.Context, plaintext, binding string) (string, error)
+
+	// Open decrypts a sealed value bound to the binding; other values are returned unchanged
+	Open(ctx context.Context, value, binding string) (string, error)
+
+	// Current reports whether a sealed value uses the key new values are sealed with
+	Current(ctx context.Context, value string) (bool, error)
+}
+
+// IsSealed reports whether a value was sealed
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// format builds a sealed value
+func format(provider, keyID string, ciphertext []byte) string {
+	return prefix + provider + ":" + base64.StdEncoding.EncodeToString(ciphertext) + ":" + keyID
+}
+
+// parse splits a sealed value; the key ID comes last because KMS key IDs may contain colons
+func parse(value string) (provider, keyID string, ciphertext []byte, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(value, prefix), ":", 3)
+	if len(parts) != 3 {
+		return "", "", nil, errors.New("malformed sealed value")
+	}
+	ciphertext, err = base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", "", nil, fmt.Errorf("malformed sealed value: %w", err)
+	}
+	return parts[0], parts[2], ciphertext, nil
+}
+
+// Keyring holds AES-256 keys by ID
+type Keyring struct {
+	// Current is the ID of the key new values are sealed with
+	Current string
+
+	// Keys are the 32-byte keys by ID, including the ones older values were sealed with
+	Keys map[string][]byte
+}
+
+// KeyProvider returns the current keyring
+type KeyProvider interface {
+	Keyring(ctx context.Context) (Keyring, error)
+}
+
+// AESSealer seals values with AES-256-GCM keys of a KeyProvider
+type AESSealer struct {
+	Keys KeyProvider
+}
+
+var _ Sealer = &AESSealer{}
+
+// Seal encrypts the value with the current key
+func (s *AESSealer) Seal(ctx context.Context, plaintext, binding string) (string, error) {
+	keyring, err := s.Keys.Keyring(ctx)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(keyring.Keys[keyring.Current])
+	if err != nil {
+		return "", fmt.Errorf("key %s: %w", keyring.Current, err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return format(providerAES, keyring.Current, aead.Seal(nonce, nonce, []byte(plaintext), []byte(binding))), nil
+}
+
+// Open decrypts a value with the key it names
+func (s *AESSealer) Open(ctx context.Context, value, binding string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	provider, keyID, ciphertext, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	if provider != providerAES {
+		return "", fmt.Errorf("value was sealed by %s, not %s", provider, providerAES)
+	}
+	keyring, err := s.Keys.Keyring(ctx)
+	if err != nil {
+		return "", err
+	}
+	key, ok := keyring.Keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("key %s: %w", keyID, ErrUnknownKey)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", fmt.Errorf("key %s: %w", keyID, err)
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return "", errors.New("malformed sealed value")
+	}
+	plaintext, err := aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], []byte(binding))
+	if err != nil {
+		return "", fmt.Errorf("failed to open value sealed with key %s: %w", keyID, err)
+	}
+	return string(plaintext), nil
+}
+
+// Current reports whether the value was sealed with the current key
+func (s *AESSealer) Current(ctx context.Context, value string) (bool, error) {
+	if !IsSealed(value) {
+		return false, nil
+	}
+	_, keyID, _, err := parse(value)
+	if err != nil {
+		return false, err
+	}
+	keyring, err := s.Keys.Keyring(ctx)
+	if err != nil {
+		return false, err
+	}
+	return keyID == keyring.Current, nil
+}
+
+// newGCM returns AES-256-GCM for a key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("AES-256 keys are 32 bytes, not %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// KMS is a key management service that encrypts and decrypts small values with keys that
+// never leave it, e.g. a cloud KMS or Vault's transit engine
+type KMS interface {
+	// Encrypt encrypts the plaintext with the current key, returning its ID
+	Encrypt(ctx context.Context, plaintext, aad []byte) (keyID string, ciphertext []byte, err error)
+
+	// Decrypt decrypts a ciphertext with the key it was encrypted with
+	Decrypt(ctx context.Context, keyID string, ciphertext, aad []byte) ([]byte, error)
+
+	// CurrentKeyID returns the ID of the key Encrypt uses
+	CurrentKeyID(ctx context.Context) (string, error)
+}
+
+// KMSSealer seals every value through a KMS; rotation is up to the KMS
+type KMSSealer struct {
+	KMS KMS
+}
+
+var _ Sealer = &KMSSealer{}
+
+// Seal encrypts the value with the current key of the KMS
+func (s *KMSSealer) Seal(ctx context.Context, plaintext, binding string) (string, error) {
+	keyID, ciphertext, err := s.KMS.Encrypt(ctx, []byte(plaintext), []byte(binding))
+	if err != nil {
+		return "", fmt.Errorf("KMS encrypt: %w", err)
+	}
+	return format(providerKMS, keyID, ciphertext), nil
+}
+
+// Open decrypts a value through the KMS
+func (s *KMSSealer) Open(ctx context.Context, value, binding string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	provider, keyID, ciphertext, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	if provider != providerKMS {
+		return "", fmt.Errorf("value was sealed by %s, not %s", provider, providerKMS)
+	}
+	plaintext, err := s.KMS.Decrypt(ctx, keyID, ciphertext, []byte(binding))
+	if err != nil {
+		return "", fmt.Errorf("KMS decrypt with key %s: %w", keyID, err)
+	}
+	return string(plaintext), nil
+}
+
+// Current reports whether the value was sealed with the current key of the KMS
+func (s *KMSSealer) Current(ctx context.Context, value string) (bool, error) {
+	if !IsSealed(value) {
+		return false, nil
+	}
+	_, keyID, _, err := parse(value)
+	if err != nil {
+		return false, err
+	}
+	current, err := s.KMS.CurrentKeyID(ctx)
+	return keyID == current, err
+}
@@ -0,0 +1,175 @@
+package sealing
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// staticKeys is a fixed keyring
+type staticKeys Keyring
+
+func (k *staticKeys) Keyring(ctx context.Context) (Keyring, error) {
+	return Keyring(*k), nil
+}
+
+func newKey(t *testing.T) []byte {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func TestAESSealer(t *testing.T) {
+	ctx := context.Background()
+	keys := &staticKeys{Current: "2024-03", Keys: map[string][]byte{"2024-03": newKey(t)}}
+	sealer := &AESSealer{Keys: keys}
+
+	sealed, err := sealer.Seal(ctx, "SELECT * FROM orders", "shop/orders")
+	require.NoError(t, err)
+	assert.True(t, IsSealed(sealed))
+	assert.True(t, strings.HasPrefix(sealed, "sealed:v1:aes:"))
+	assert.NotContains(t, sealed, "orders")
+	opened, err := sealer.Open(ctx, sealed, "shop/orders")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM orders", opened)
+
+	_, err = sealer.Open(ctx, sealed, "shop/payments")
+	assert.Error(t, err, "A value copied to another object does not open")
+
+	opened, err = sealer.Open(ctx, "SELECT 1", "shop/orders")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT 1", opened, "Values written before sealing read as they are")
+
+	// After a rotation old values still open and are no longer current
+	keys.Keys["2024-09"] = newKey(t)
+	keys.Current = "2024-09"
+	current, err := sealer.Current(ctx, sealed)
+	require.NoError(t, err)
+	assert.False(t, current)
+	opened, err = sealer.Open(ctx, sealed, "shop/orders")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM orders", opened)
+	resealed, err := sealer.Seal(ctx, opened, "shop/orders")
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(resealed, ":2024-09"))
+	current, err = sealer.Current(ctx, resealed)
+	require.NoError(t, err)
+	assert.True(t, current)
+
+	// Once the old key is removed its values no longer open
+	delete(keys.Keys, "2024-03")
+	_, err = sealer.Open(ctx, sealed, "shop/orders")
+	assert.True(t, errors.Is(err, ErrUnknownKey))
+
+	_, err = sealer.Open(ctx, "sealed:v1:aes:not base64", "shop/orders")
+	assert.ErrorContains(t, err, "malformed sealed value")
+}
+
+// fakeKMS encrypts with AES keys it holds by ID, as a cloud KMS would behind its API
+type fakeKMS struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (k *fakeKMS) Encrypt(ctx context.Context, plaintext, aad []byte) (string, []byte, error) {
+	aead, err := newGCM(k.keys[k.current])
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return k.current, aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func (k *fakeKMS) Decrypt(ctx context.Context, keyID string, ciphertext, aad []byte) ([]byte, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("key %s: %w", keyID, ErrUnknownKey)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], aad)
+}
+
+func (k *fakeKMS) CurrentKeyID(ctx context.Context) (string, error) {
+	return k.current, nil
+}
+
+func TestKMSSealer(t *testing.T) {
+	ctx := context.Background()
+	kms := &fakeKMS{
+		current: "projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1",
+		keys:    map[string][]byte{"projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1": newKey(t)},
+	}
+	sealer := &KMSSealer{KMS: kms}
+
+	sealed, err := sealer.Seal(ctx, "SELECT * FROM orders", "shop/orders")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, "sealed:v1:kms:"))
+	opened, err := sealer.Open(ctx, sealed, "shop/orders")
+	require.NoError(t, err, "Key IDs may contain colons and slashes")
+	assert.Equal(t, "SELECT * FROM orders", opened)
+	current, err := sealer.Current(ctx, sealed)
+	require.NoError(t, err)
+	assert.True(t, current)
+
+	_, err = sealer.Open(ctx, sealed, "shop/payments")
+	assert.Error(t, err)
+
+	// Values of one provider are not opened by the other
+	_, err = (&AESSealer{Keys: &staticKeys{Current: "a", Keys: map[string][]byte{"a": newKey(t)}}}).Open(ctx, sealed, "shop/orders")
+	assert.ErrorContains(t, err, "sealed by kms")
+}
+
+func TestSecretKeys_Keyring(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "database-operator-sealing-keys", Namespace: "database-operator-system"},
+		Data:       map[string][]byte{"2024-03": newKey(t), "2024-09": newKey(t)},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+	keys := &SecretKeys{Reader: fakeClient, Namespace: secret.Namespace, Name: secret.Name}
+	ctx := context.Background()
+
+	keyring, err := keys.Keyring(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-09", keyring.Current, "The last ID is current by default")
+	assert.Len(t, keyring.Keys, 2)
+
+	secret.Data["current"] = []byte("2024-03")
+	require.NoError(t, fakeClient.Update(ctx, secret))
+	keyring, err = keys.Keyring(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03", keyring.Current)
+	assert.Len(t, keyring.Keys, 2)
+
+	secret.Data["current"] = []byte("2025-01")
+	require.NoError(t, fakeClient.Update(ctx, secret))
+	_, err = keys.Keyring(ctx)
+	assert.ErrorContains(t, err, "current key 2025-01 does not exist")
+
+	secret.Data = map[string][]byte{"short": []byte("too short")}
+	require.NoError(t, fakeClient.Update(ctx, secret))
+	_, err = keys.Keyring(ctx)
+	assert.ErrorContains(t, err, "key short is 9 bytes, not 32")
+
+	keys.Name = "missing"
+	_, err = keys.Keyring(ctx)
+	assert.ErrorContains(t, err, "failed to get sealing keys Secret")
+}
@@ -0,0 +1,74 @@
+package sealing
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// currentKey names the entry of the keys Secret holding the ID of the current key
+const currentKey = "current"
+
+// SecretKeys reads the keyring from a Secret. Every entry is a 32-byte key under its ID,
+// except "current", which names the key new values are sealed with; without it the last ID
+// in sort order is current, so dated IDs rotate by adding a key:
+//
+//	kubectl create secret generic database-operator-sealing-keys \
+//	  --from-file=2024-03=<(head -c 32 /dev/urandom) \
+//	  --from-file=2024-09=<(head -c 32 /dev/urandom)
+//
+// A key may be removed once no value names it any longer.
+type SecretKeys struct {
+	client.Reader
+
+	// Namespace and Name locate the Secret
+	Namespace string
+	Name      string
+}
+
+var _ KeyProvider = &SecretKeys{}
+
+// BindFlags registers flags for the keys Secret, using the current values as defaults
+func (s *SecretKeys) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.Namespace, "sealing-keys-secret-namespace", s.Namespace,
+		"Namespace of the Secret holding the keys sensitive status fields are encrypted with.")
+	fs.StringVar(&s.Name, "sealing-keys-secret-name", s.Name,
+		"Name of the Secret holding the keys sensitive status fields are encrypted with. Empty stores them unencrypted.")
+}
+
+// Keyring reads the keys from the Secret
+func (s *SecretKeys) Keyring(ctx context.Context) (Keyring, error) {
+	secret := &corev1.Secret{}
+	if err := s.Get(ctx, types.NamespacedName{Namespace: s.Namespace, Name: s.Name}, secret); err != nil {
+		return Keyring{}, fmt.Errorf("failed to get sealing keys Secret: %w", err)
+	}
+
+	keyring := Keyring{Current: string(secret.Data[currentKey]), Keys: map[string][]byte{}}
+	var ids []string
+	for id, key := range secret.Data {
+		if id == currentKey {
+			continue
+		}
+		if len(key) != 32 {
+			return Keyring{}, fmt.Errorf("sealing keys Secret %s/%s: key %s is %d bytes, not 32", s.Namespace, s.Name, id, len(key))
+		}
+		keyring.Keys[id] = key
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return Keyring{}, fmt.Errorf("sealing keys Secret %s/%s holds no keys", s.Namespace, s.Name)
+	}
+	if keyring.Current == "" {
+		sort.Strings(ids)
+		keyring.Current = ids[len(ids)-1]
+	}
+	if _, ok := keyring.Keys[keyring.Current]; !ok {
+		return Keyring{}, fmt.Errorf("sealing keys Secret %s/%s: current key %s does not exist", s.Namespace, s.Name, keyring.Current)
+	}
+	return keyring, nil
+}
@@ -0,0 +1,110 @@
+// Package secretstore keeps values an operator generates in an external secret manager, such
+// as a cloud secret manager or Vault, instead of in Kubernetes Secrets. The Secret then holds
+// only a Reference to the value, so the value itself never reaches etcd:
+//
+//	passwordRef: shop/orders-password#3
+//
+// Values are versioned. Put adds a version and references name it, so a reference keeps
+// pointing at the value it was written for while a newer version is rolled out.
+package secretstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned for a name or version the manager does not hold
+var ErrNotFound = errors.New("secret not found")
+
+// Manager is an external secret manager
+type Manager interface {
+	// Put stores a new version of the value under the name and returns the version
+	Put(ctx context.Context, name string, value []byte) (version string, err error)
+
+	// Get returns a version of the value stored under the name
+	Get(ctx context.Context, name, version string) ([]byte, error)
+
+	// Delete removes every version stored under the name; a missing name is not an error
+	Delete(ctx context.Context, name string) error
+}
+
+// Reference points at one version of a value in a Manager
+type Reference struct {
+	Name    string
+	Version string
+}
+
+// String formats the reference as name#version
+func (r Reference) String() string {
+	return r.Name + "#" + r.Version
+}
+
+// ParseReference parses a reference formatted by Reference.String
+func ParseReference(s string) (Reference, error) {
+	i := strings.LastIndex(s, "#")
+	if i <= 0 || i == len(s)-1 {
+		return Reference{}, fmt.Errorf("invalid secret reference %q, expected name#version", s)
+	}
+	return Reference{Name: s[:i], Version: s[i+1:]}, nil
+}
+
+// Resolve returns the value a reference points at
+func Resolve(ctx context.Context, m Manager, ref Reference) ([]byte, error) {
+	value, err := m.Get(ctx, ref.Name, ref.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve secret %s: %w", ref, err)
+	}
+	return value, nil
+}
+
+// Memory is a Manager keeping values in memory. It stands in for a real secret manager in
+// tests and demos; its values are lost when the process exits.
+type Memory struct {
+	mu       sync.Mutex
+	versions map[string][][]byte
+}
+
+var _ Manager = &Memory{}
+
+// NewMemory returns an empty in-memory manager
+func NewMemory() *Memory {
+	return &Memory{versions: map[string][][]byte{}}
+}
+
+// Put stores a new version; versions count up from 1
+func (m *Memory) Put(ctx context.Context, name string, value []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.versions[name] = append(m.versions[name], append([]byte(nil), value...))
+	return strconv.Itoa(len(m.versions[name])), nil
+}
+
+// Get returns a stored version
+func (m *Memory) Get(ctx context.Context, name, version string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := strconv.Atoi(version)
+	if err != nil || n < 1 || n > len(m.versions[name]) {
+		return nil, fmt.Errorf("%s version %s: %w", name, version, ErrNotFound)
+	}
+	return append([]byte(nil), m.versions[name][n-1]...), nil
+}
+
+// Delete removes every version of a name
+func (m *Memory) Delete(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.versions, name)
+	return nil
+}
+
+// Len returns how many names the manager holds
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.versions)
+}
@@ -0,0 +1,54 @@
+package secretstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReference(t *testing.T) {
+	ref, err := ParseReference("shop/orders-password#3")
+	require.NoError(t, err)
+	assert.Equal(t, Reference{Name: "shop/orders-password", Version: "3"}, ref)
+	assert.Equal(t, "shop/orders-password#3", ref.String())
+
+	ref, err = ParseReference("projects/p/secrets/a#b#7")
+	require.NoError(t, err)
+	assert.Equal(t, "7", ref.Version, "Only the last # separates the version")
+
+	for _, invalid := range []string{"", "orders", "#3", "orders#"} {
+		_, err := ParseReference(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	first, err := m.Put(ctx, "shop/orders-password", []byte("old"))
+	require.NoError(t, err)
+	second, err := m.Put(ctx, "shop/orders-password", []byte("new"))
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	value, err := Resolve(ctx, m, Reference{Name: "shop/orders-password", Version: first})
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(value), "References keep pointing at their version")
+	value, err = m.Get(ctx, "shop/orders-password", second)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(value))
+
+	_, err = Resolve(ctx, m, Reference{Name: "shop/orders-password", Version: "9"})
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.ErrorContains(t, err, "failed to resolve secret shop/orders-password#9")
+
+	require.NoError(t, m.Delete(ctx, "shop/orders-password"))
+	require.NoError(t, m.Delete(ctx, "shop/orders-password"), "Deleting twice is fine")
+	_, err = m.Get(ctx, "shop/orders-password", first)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, 0, m.Len())
+}
@@ -0,0 +1,180 @@
+// Package secretstoretest checks that a secretstore.Manager keeps the contract the operator
+// relies on, so an implementation for another secret manager gets the same checks as Memory:
+//
+//   - a value read back is the value written, and neither the caller's slice nor the returned
+//     one aliases what the manager stores
+//   - every Put adds a version and earlier versions stay readable, so a Put retried after an
+//     error the caller could not interpret leaves the references already written valid;
+//     reading a version again returns the same value
+//   - a missing name or version is ErrNotFound, wrapped or not, so callers can tell it from
+//     an outage
+//   - Delete removes every version of one name only, and deleting a missing name, or the same
+//     name twice, succeeds: finalizers retry it until it does
+//   - the manager is safe for concurrent use, which reconciles of different Databases need;
+//     run the suite with -race for this check to mean anything
+//
+// Call TestManager from the implementation's tests with a constructor returning an empty
+// manager; every subtest gets its own:
+//
+//	func TestVault(t *testing.T) {
+//		secretstoretest.TestManager(t, func(t *testing.T) secretstore.Manager {
+//			return newTestVault(t)
+//		})
+//	}
+package secretstoretest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"your.domain/project/secretstore"
+)
+
+// Workers is the number of goroutines the concurrency check runs
+const Workers = 8
+
+// TestManager runs the contract checks against managers returned by newManager, in a
+// subtest per rule
+func TestManager(t *testing.T, newManager func(t *testing.T) secretstore.Manager) {
+	t.Helper()
+	checks := []struct {
+		name  string
+		check func(t *testing.T, ctx context.Context, m secretstore.Manager)
+	}{
+		{"PutGet", checkPutGet},
+		{"Versions", checkVersions},
+		{"NotFound", checkNotFound},
+		{"Delete", checkDelete},
+		{"Concurrent", checkConcurrent},
+	}
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			c.check(t, context.Background(), newManager(t))
+		})
+	}
+}
+
+func checkPutGet(t *testing.T, ctx context.Context, m secretstore.Manager) {
+	value := []byte("s3cret")
+	version := put(t, ctx, m, "contract/put-get", value)
+	value[0] = 'x'
+
+	got := get(t, ctx, m, "contract/put-get", version)
+	if string(got) != "s3cret" {
+		t.Fatalf("Get returned %q, want %q; did Put keep the caller's slice?", got, "s3cret")
+	}
+	got[0] = 'x'
+	if got := get(t, ctx, m, "contract/put-get", version); string(got) != "s3cret" {
+		t.Errorf("Get returned %q after the previous result was changed, want %q", got, "s3cret")
+	}
+}
+
+func checkVersions(t *testing.T, ctx context.Context, m secretstore.Manager) {
+	first := put(t, ctx, m, "contract/versions", []byte("v1"))
+	retried := put(t, ctx, m, "contract/versions", []byte("v1"))
+	second := put(t, ctx, m, "contract/versions", []byte("v2"))
+	if first == retried || first == second || retried == second {
+		t.Fatalf("Put returned versions %q, %q and %q, want three different ones", first, retried, second)
+	}
+
+	for version, want := range map[string]string{first: "v1", retried: "v1", second: "v2"} {
+		for i := 0; i < 2; i++ {
+			if got := get(t, ctx, m, "contract/versions", version); string(got) != want {
+				t.Errorf("version %s is %q, want %q", version, got, want)
+			}
+		}
+	}
+}
+
+func checkNotFound(t *testing.T, ctx context.Context, m secretstore.Manager) {
+	if _, err := m.Get(ctx, "contract/missing", "1"); !errors.Is(err, secretstore.ErrNotFound) {
+		t.Errorf("Get of a missing name returned %v, want ErrNotFound", err)
+	}
+
+	version := put(t, ctx, m, "contract/not-found", []byte("value"))
+	for _, missing := range []string{version + "0", "", "not-a-version"} {
+		if _, err := m.Get(ctx, "contract/not-found", missing); !errors.Is(err, secretstore.ErrNotFound) {
+			t.Errorf("Get of missing version %q returned %v, want ErrNotFound", missing, err)
+		}
+	}
+}
+
+func checkDelete(t *testing.T, ctx context.Context, m secretstore.Manager) {
+	if err := m.Delete(ctx, "contract/never-stored"); err != nil {
+		t.Fatalf("Delete of a missing name returned %v, want nil", err)
+	}
+
+	first := put(t, ctx, m, "contract/delete", []byte("v1"))
+	second := put(t, ctx, m, "contract/delete", []byte("v2"))
+	kept := put(t, ctx, m, "contract/kept", []byte("kept"))
+	for i := 0; i < 2; i++ {
+		if err := m.Delete(ctx, "contract/delete"); err != nil {
+			t.Fatalf("Delete #%d returned %v, want nil", i+1, err)
+		}
+	}
+
+	for _, version := range []string{first, second} {
+		if _, err := m.Get(ctx, "contract/delete", version); !errors.Is(err, secretstore.ErrNotFound) {
+			t.Errorf("Get of deleted version %s returned %v, want ErrNotFound", version, err)
+		}
+	}
+	if got := get(t, ctx, m, "contract/kept", kept); string(got) != "kept" {
+		t.Errorf("Delete of another name changed contract/kept to %q", got)
+	}
+}
+
+func checkConcurrent(t *testing.T, ctx context.Context, m secretstore.Manager) {
+	var wg sync.WaitGroup
+	for w := 0; w < Workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// Every worker writes its own name and a name all of them share
+			own := fmt.Sprintf("contract/concurrent-%d", w)
+			for i := 0; i < 20; i++ {
+				value := []byte(fmt.Sprintf("%d-%d", w, i))
+				for _, name := range []string{own, "contract/concurrent-shared"} {
+					version, err := m.Put(ctx, name, value)
+					if err != nil {
+						t.Errorf("Put %s: %v", name, err)
+						return
+					}
+					got, err := m.Get(ctx, name, version)
+					if err != nil || !bytes.Equal(got, value) {
+						t.Errorf("Get %s version %s returned %q, %v, want %q", name, version, got, err, value)
+						return
+					}
+				}
+				if i%5 == 4 {
+					if err := m.Delete(ctx, own); err != nil {
+						t.Errorf("Delete %s: %v", own, err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func put(t *testing.T, ctx context.Context, m secretstore.Manager, name string, value []byte) string {
+	t.Helper()
+	version, err := m.Put(ctx, name, value)
+	if err != nil {
+		t.Fatalf("Put %s: %v", name, err)
+	}
+	return version
+}
+
+func get(t *testing.T, ctx context.Context, m secretstore.Manager, name, version string) []byte {
+	t.Helper()
+	value, err := m.Get(ctx, name, version)
+	if err != nil {
+		t.Fatalf("Get %s version %s: %v", name, version, err)
+	}
+	return value
+}
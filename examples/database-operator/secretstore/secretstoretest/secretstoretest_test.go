@@ -0,0 +1,13 @@
+package secretstoretest
+
+import (
+	"testing"
+
+	"your.domain/project/secretstore"
+)
+
+func TestMemory(t *testing.T) {
+	TestManager(t, func(*testing.T) secretstore.Manager {
+		return secretstore.NewMemory()
+	})
+}
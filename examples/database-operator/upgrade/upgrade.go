@@ -0,0 +1,235 @@
+// Package upgrade checks that the current controller takes over what an earlier release left
+// in a cluster. The objects of a release, custom resources and their children alike, are kept
+// as YAML fixtures in testdata/released; the package test restores them into a fake client and,
+// when the envtest binaries are available, into a real API server, runs the current
+// DatabaseReconciler until it converges and fails when a child was deleted or recreated on the
+// way, which would mean downtime or lost data for every upgrading user.
+//
+// Refresh the fixtures when cutting a release: read the Database and its children back from a
+// cluster running it, and drop the server-set fields other than the creation timestamp and
+// UIDs. Restore remaps the UIDs in owner references, as restoring a backup does.
+package upgrade
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// LoadDir reads the objects in the YAML files of a directory, in file and document order, as
+// the typed objects of the scheme
+func LoadDir(dir string, scheme *runtime.Scheme) ([]client.Object, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	var objects []client.Object
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+		for {
+			document, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", file, err)
+			}
+			if len(bytes.TrimSpace(document)) == 0 {
+				continue
+			}
+			decoded, _, err := decoder.Decode(document, nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+			}
+			object, ok := decoded.(client.Object)
+			if !ok {
+				return nil, fmt.Errorf("%s: %T is not an object", file, decoded)
+			}
+			objects = append(objects, object)
+		}
+	}
+	return objects, nil
+}
+
+// Restore creates the objects the way restoring a backup does: owners before the objects they
+// own, with the UIDs in owner references replaced by the ones the API server assigned, and
+// the status written through the status subresource, which ignores it on create. It returns
+// the objects as stored, in the order they were created.
+func Restore(ctx context.Context, c client.Client, objects []client.Object) ([]client.Object, error) {
+	pending := make([]client.Object, 0, len(objects))
+	for _, object := range objects {
+		pending = append(pending, object.DeepCopyObject().(client.Object))
+	}
+	released := map[types.UID]bool{}
+	for _, object := range pending {
+		released[object.GetUID()] = true
+	}
+
+	uids := map[types.UID]types.UID{}
+	var restored []client.Object
+	for len(pending) > 0 {
+		var next []client.Object
+		for _, object := range pending {
+			if !ownersRestored(object, released, uids) {
+				next = append(next, object)
+				continue
+			}
+			uid := object.GetUID()
+			stored, err := restore(ctx, c, object, uids)
+			if err != nil {
+				return restored, err
+			}
+			uids[uid] = stored.GetUID()
+			restored = append(restored, stored)
+		}
+		if len(next) == len(pending) {
+			return restored, fmt.Errorf("owner references of %s %s form a cycle", kindOf(c, next[0]), client.ObjectKeyFromObject(next[0]))
+		}
+		pending = next
+	}
+	return restored, nil
+}
+
+// ownersRestored reports whether every owner of the object in the fixtures was created
+func ownersRestored(object client.Object, released map[types.UID]bool, uids map[types.UID]types.UID) bool {
+	for _, reference := range object.GetOwnerReferences() {
+		if _, ok := uids[reference.UID]; released[reference.UID] && !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// restore creates one object and writes back its status
+func restore(ctx context.Context, c client.Client, object client.Object, uids map[types.UID]types.UID) (client.Object, error) {
+	references := object.GetOwnerReferences()
+	for i := range references {
+		if uid, ok := uids[references[i].UID]; ok {
+			references[i].UID = uid
+		}
+	}
+	object.SetOwnerReferences(references)
+	object.SetResourceVersion("")
+	if object.GetUID() == "" {
+		// The API server assigns a new UID regardless; fake clients keep the one they are
+		// given and assign none, so without it a recreated object would look unchanged
+		object.SetUID(uuid.NewUUID())
+	}
+
+	withStatus := object.DeepCopyObject().(client.Object)
+	if err := c.Create(ctx, object); err != nil {
+		return nil, fmt.Errorf("failed to restore %s %s: %w", kindOf(c, object), client.ObjectKeyFromObject(object), err)
+	}
+	hasStatus, err := hasStatus(withStatus)
+	if err != nil || !hasStatus {
+		return object, err
+	}
+	withStatus.SetResourceVersion(object.GetResourceVersion())
+	withStatus.SetUID(object.GetUID())
+	if err := c.Status().Update(ctx, withStatus); err != nil {
+		return nil, fmt.Errorf("failed to restore the status of %s %s: %w", kindOf(c, object), client.ObjectKeyFromObject(object), err)
+	}
+	return withStatus, nil
+}
+
+// hasStatus reports whether the object has a status with any field set
+func hasStatus(object client.Object) (bool, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return false, err
+	}
+	status, _, err := unstructured.NestedFieldNoCopy(content, "status")
+	return !isEmpty(status), err
+}
+
+// isEmpty reports whether a decoded JSON value holds nothing but empty objects and lists
+func isEmpty(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		for _, field := range value {
+			if !isEmpty(field) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		return len(value) == 0
+	default:
+		return false
+	}
+}
+
+// Snapshot records the UIDs of restored objects to find the ones deleted or recreated later
+type Snapshot struct {
+	entries []snapshotEntry
+}
+
+type snapshotEntry struct {
+	object client.Object
+	kind   string
+	uid    types.UID
+}
+
+// Take records the UIDs the objects have now
+func Take(ctx context.Context, c client.Client, objects []client.Object) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+	for _, object := range objects {
+		current := object.DeepCopyObject().(client.Object)
+		if err := c.Get(ctx, client.ObjectKeyFromObject(object), current); err != nil {
+			return nil, fmt.Errorf("failed to get %s %s: %w", kindOf(c, object), client.ObjectKeyFromObject(object), err)
+		}
+		snapshot.entries = append(snapshot.entries, snapshotEntry{object: current, kind: kindOf(c, object), uid: current.GetUID()})
+	}
+	return snapshot, nil
+}
+
+// Replaced returns the objects of the snapshot that were deleted or recreated since, as
+// "<Kind> <namespace>/<name>: deleted|recreated"
+func (s *Snapshot) Replaced(ctx context.Context, c client.Client) ([]string, error) {
+	var replaced []string
+	for _, entry := range s.entries {
+		key := client.ObjectKeyFromObject(entry.object)
+		current := entry.object.DeepCopyObject().(client.Object)
+		err := c.Get(ctx, key, current)
+		switch {
+		case apierrors.IsNotFound(err):
+			replaced = append(replaced, fmt.Sprintf("%s %s: deleted", entry.kind, key))
+		case err != nil:
+			return nil, fmt.Errorf("failed to get %s %s: %w", entry.kind, key, err)
+		case current.GetUID() != entry.uid:
+			replaced = append(replaced, fmt.Sprintf("%s %s: recreated", entry.kind, key))
+		}
+	}
+	return replaced, nil
+}
+
+// kindOf names the kind of a typed object for messages
+func kindOf(c client.Client, object client.Object) string {
+	gvk, err := apiutil.GVKForObject(object, c.Scheme())
+	if err != nil {
+		return fmt.Sprintf("%T", object)
+	}
+	return gvk.Kind
+}
@@ -0,0 +1,180 @@
+package upgrade
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	databasev1 "your.domain/project/api/v1"
+	"your.domain/project/controllers"
+)
+
+// maxReconciles bounds the reconciles a released Database may take to converge
+const maxReconciles = 10
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, databasev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	return scheme
+}
+
+func TestUpgrade_Fake(t *testing.T) {
+	scheme := newScheme(t)
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&databasev1.Database{}, &appsv1.Deployment{}).
+		Build()
+	testUpgrade(t, c)
+}
+
+// TestUpgrade_Envtest restores the released objects into a real API server, which validates
+// them against the current CRDs and rejects changes to immutable fields. It needs the envtest
+// binaries; run it with KUBEBUILDER_ASSETS set, e.g. via setup-envtest.
+func TestUpgrade_Envtest(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{"../config/crd/bases"},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := env.Start()
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, env.Stop()) })
+
+	c, err := client.New(cfg, client.Options{Scheme: newScheme(t)})
+	require.NoError(t, err)
+	testUpgrade(t, c)
+}
+
+// testUpgrade restores the released objects, runs the current reconciler on every released
+// Database until it converges and checks that no child was replaced on the way
+func testUpgrade(t *testing.T, c client.Client) {
+	ctx := context.Background()
+	objects, err := LoadDir("testdata/released", c.Scheme())
+	require.NoError(t, err)
+	require.NotEmpty(t, objects)
+	restored, err := Restore(ctx, c, objects)
+	require.NoError(t, err)
+	before, err := Take(ctx, c, restored)
+	require.NoError(t, err)
+
+	reconciler := &controllers.DatabaseReconciler{Client: c, Scheme: c.Scheme()}
+	var databases int
+	for _, object := range restored {
+		if database, ok := object.(*databasev1.Database); ok {
+			databases++
+			converge(t, c, reconciler, database)
+		}
+	}
+	require.NotZero(t, databases, "The fixtures hold no Database")
+
+	replaced, err := before.Replaced(ctx, c)
+	require.NoError(t, err)
+	assert.Empty(t, replaced, "The current controller replaced children of a released Database")
+
+	// A new password would lock out every client of the Database
+	for _, object := range objects {
+		if released, ok := object.(*corev1.Secret); ok {
+			secret := &corev1.Secret{}
+			require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(released), secret))
+			for key, value := range released.Data {
+				assert.Equal(t, value, secret.Data[key], "Secret %s changed key %s", released.Name, key)
+			}
+		}
+	}
+}
+
+// converge reconciles the Database until it is Ready for its generation and a reconcile leaves
+// its children untouched. The Deployment is marked rolled out after every reconcile, standing
+// in for the deployment controller, which neither the fake client nor envtest runs.
+func converge(t *testing.T, c client.Client, reconciler *controllers.DatabaseReconciler, database *databasev1.Database) {
+	ctx := context.Background()
+	key := client.ObjectKeyFromObject(database)
+	var versions map[string]string
+	for i := 0; i < maxReconciles; i++ {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		require.NoError(t, err, "reconcile %d of Database %s", i+1, key)
+		markRolledOut(t, c, key)
+
+		current := &databasev1.Database{}
+		require.NoError(t, c.Get(ctx, key, current))
+		next := childVersions(t, c, current)
+		ready := current.GetCondition("Ready")
+		if ready != nil && ready.Status == metav1.ConditionTrue && current.Status.ObservedGeneration == current.Generation &&
+			versions != nil && assert.ObjectsAreEqual(versions, next) {
+			return
+		}
+		versions = next
+	}
+	t.Fatalf("Database %s did not converge in %d reconciles", key, maxReconciles)
+}
+
+// markRolledOut reports every replica of the Deployment ready, once it exists
+func markRolledOut(t *testing.T, c client.Client, key client.ObjectKey) {
+	ctx := context.Background()
+	deployment := &appsv1.Deployment{}
+	err := c.Get(ctx, key, deployment)
+	if apierrors.IsNotFound(err) {
+		return
+	}
+	require.NoError(t, err)
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := appsv1.DeploymentStatus{
+		ObservedGeneration: deployment.Generation,
+		Replicas:           replicas,
+		UpdatedReplicas:    replicas,
+		ReadyReplicas:      replicas,
+		AvailableReplicas:  replicas,
+	}
+	if assert.ObjectsAreEqual(status, deployment.Status) {
+		return
+	}
+	deployment.Status = status
+	require.NoError(t, c.Status().Update(ctx, deployment))
+}
+
+// childVersions returns the resource versions of the objects the Database controls, by kind
+// and name
+func childVersions(t *testing.T, c client.Client, database *databasev1.Database) map[string]string {
+	ctx := context.Background()
+	versions := map[string]string{}
+	lists := map[string]client.ObjectList{
+		"Deployment": &appsv1.DeploymentList{},
+		"Service":    &corev1.ServiceList{},
+		"PVC":        &corev1.PersistentVolumeClaimList{},
+		"Secret":     &corev1.SecretList{},
+		"ConfigMap":  &corev1.ConfigMapList{},
+	}
+	for kind, list := range lists {
+		require.NoError(t, c.List(ctx, list, client.InNamespace(database.Namespace)))
+		items, err := meta.ExtractList(list)
+		require.NoError(t, err)
+		for _, item := range items {
+			object := item.(client.Object)
+			if owner := metav1.GetControllerOf(object); owner != nil && owner.UID == database.UID {
+				versions[kind+" "+object.GetName()] = object.GetResourceVersion()
+			}
+		}
+	}
+	return versions
+}
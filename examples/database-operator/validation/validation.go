@@ -0,0 +1,374 @@
+// Package validation holds the Database rules the CRD schema cannot express.
+//
+// The same rules run in two places. The validating webhook rejects invalid Databases up
+// front; the reconciler runs them again before rendering any child, because webhooks can be
+// disabled or bypassed with failurePolicy=Ignore. Without the second check an invalid
+// Database fails later and less clearly, e.g. as a Deployment the API server rejects or a
+// pod that crash-loops on a bad user name.
+//
+// Fields that may hold ${VAR} references are only checked once the references are resolved:
+// the webhook skips them, and the reconciler validates the resolved spec.
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+var (
+	// imagePattern is the reference grammar of the distribution project: an optional
+	// registry host with port, lowercase path components, an optional tag and digest
+	imagePattern = regexp.MustCompile(`^` +
+		`(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?/)?` +
+		`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+		`(?::[A-Za-z0-9_][A-Za-z0-9_.-]{0,127})?` +
+		`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?$`)
+
+	// parameterPattern matches PostgreSQL setting names, including the dotted names of
+	// extensions such as pg_stat_statements.track
+	parameterPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*(\.[a-z_][a-z0-9_]*)?$`)
+
+	// identifierPattern rejects control characters in database and user names. The image
+	// quotes the names, so any other character is allowed.
+	identifierPattern = regexp.MustCompile(`^[^\x00-\x1f\x7f]+$`)
+)
+
+// maxIdentifierBytes is the PostgreSQL identifier limit; longer names are silently truncated,
+// so the database would not have the name clients connect to
+const maxIdentifierBytes = 63
+
+// MinRequeueInterval is the shortest resync interval a Database may ask for in
+// spec.requeuePolicy; shorter intervals would let one Database keep the controller busy
+const MinRequeueInterval = 5 * time.Second
+
+// ValidateDatabase returns every rule the Database violates
+func ValidateDatabase(database *databasev1.Database) field.ErrorList {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+
+	if image := database.Spec.Image; !unresolved(image) && image != "" && !imagePattern.MatchString(image) {
+		errs = append(errs, field.Invalid(spec.Child("image"), image, "must be a valid image reference"))
+	}
+	// With a class, the image may come from it; the reconciler checks the merged spec
+	if database.Spec.Image == "" && database.Spec.ClassName == "" {
+		errs = append(errs, field.Required(spec.Child("image"), "must be set unless spec.className names a DatabaseClass that sets it"))
+	}
+	errs = append(errs, validateName(spec.Child("className"), database.Spec.ClassName)...)
+	errs = append(errs, validateParameters(spec.Child("parameters"), database.Spec.Parameters)...)
+
+	identifiers := []struct {
+		name  string
+		value string
+	}{
+		{"databaseName", database.Spec.DatabaseName},
+		{"userName", database.Spec.UserName},
+	}
+	for _, id := range identifiers {
+		if id.value == "" || unresolved(id.value) {
+			continue
+		}
+		if len(id.value) > maxIdentifierBytes {
+			errs = append(errs, field.TooLong(spec.Child(id.name), id.value, maxIdentifierBytes))
+		} else if !identifierPattern.MatchString(id.value) {
+			errs = append(errs, field.Invalid(spec.Child(id.name), id.value, "must not contain control characters"))
+		}
+	}
+
+	errs = append(errs, validateName(spec.Child("passwordSecretName"), database.Spec.PasswordSecretName)...)
+	errs = append(errs, validateName(spec.Child("configMapName"), database.Spec.ConfigMapName)...)
+	if !unresolved(database.Spec.StorageClass) {
+		errs = append(errs, validateName(spec.Child("storageClass"), database.Spec.StorageClass)...)
+	}
+	errs = append(errs, validateName(spec.Child("priorityClassName"), database.Spec.PriorityClassName)...)
+	for i, secret := range database.Spec.ImagePullSecrets {
+		path := spec.Child("imagePullSecrets").Index(i).Child("name")
+		if secret.Name == "" {
+			errs = append(errs, field.Required(path, ""))
+			continue
+		}
+		errs = append(errs, validateName(path, secret.Name)...)
+	}
+
+	if resources := database.Spec.Resources; resources != nil {
+		errs = append(errs, validateResources(spec.Child("resources"), resources)...)
+	}
+
+	if policy := database.Spec.RequeuePolicy; policy != nil {
+		path := spec.Child("requeuePolicy")
+		minimum := fmt.Sprintf("must be at least %s", MinRequeueInterval)
+		if policy.ReadyInterval != nil && policy.ReadyInterval.Duration < MinRequeueInterval {
+			errs = append(errs, field.Invalid(path.Child("readyInterval"), policy.ReadyInterval.Duration.String(), minimum))
+		}
+		if policy.NotReadyInterval != nil && policy.NotReadyInterval.Duration < MinRequeueInterval {
+			errs = append(errs, field.Invalid(path.Child("notReadyInterval"), policy.NotReadyInterval.Duration.String(), minimum))
+		}
+	}
+
+	if policy := database.Spec.ImageUpdatePolicy; policy != nil {
+		if _, err := ParseVersionRange(policy.Range); err != nil {
+			errs = append(errs, field.Invalid(spec.Child("imageUpdatePolicy", "range"), policy.Range, err.Error()))
+		}
+	}
+
+	if standby := database.Spec.Standby; standby != nil {
+		path := spec.Child("standby", "primaryConnectionSecret")
+		if standby.PrimaryConnectionSecret == "" {
+			errs = append(errs, field.Required(path, ""))
+		} else {
+			errs = append(errs, validateName(path, standby.PrimaryConnectionSecret)...)
+		}
+	}
+
+	// The replicas of a highly available Database replicate from its own primary
+	if database.Spec.HighAvailability {
+		if database.Spec.Standby != nil {
+			errs = append(errs, field.Forbidden(spec.Child("highAvailability"), "a standby cannot be highly available"))
+		}
+		if database.Spec.Workload != databasev1.WorkloadStatefulSet {
+			errs = append(errs, field.Invalid(spec.Child("workload"), database.Spec.Workload,
+				"a highly available Database runs a StatefulSet"))
+		}
+	}
+
+	if spread := database.Spec.ZoneSpread; spread != nil && spread.TopologyKey != "" {
+		for _, msg := range validation.IsQualifiedName(spread.TopologyKey) {
+			errs = append(errs, field.Invalid(spec.Child("zoneSpread", "topologyKey"), spread.TopologyKey, msg))
+		}
+	}
+
+	if maintenance := database.Spec.Maintenance; maintenance != nil {
+		errs = append(errs, validateMaintenance(spec.Child("maintenance"), maintenance)...)
+	}
+
+	if policy := database.Spec.VerticalScaling; policy != nil {
+		errs = append(errs, validateVerticalScaling(spec.Child("verticalScaling"), policy, database.Spec.Maintenance)...)
+	}
+
+	return errs
+}
+
+// ValidateImageUpdate limits automated image updates to the Database's update policy. An
+// update is automated when it sets spec.image to the AutomatedImageAnnotation; an image
+// changed by hand is not restricted.
+func ValidateImageUpdate(old, database *databasev1.Database) field.ErrorList {
+	image := database.Spec.Image
+	if image == old.Spec.Image || database.Annotations[databasev1.AutomatedImageAnnotation] != image {
+		return nil
+	}
+	path := field.NewPath("spec", "image")
+
+	policy := database.Spec.ImageUpdatePolicy
+	if policy == nil || policy.Mode != databasev1.ImageUpdateApply {
+		return field.ErrorList{field.Forbidden(path, "automated image updates are not enabled")}
+	}
+	versions, err := ParseVersionRange(policy.Range)
+	if err != nil {
+		// Reported by ValidateDatabase
+		return nil
+	}
+
+	oldName, oldTag := splitImage(old.Spec.Image)
+	name, tag := splitImage(image)
+	if name != oldName {
+		return field.ErrorList{field.Forbidden(path, "automated image updates must keep the repository")}
+	}
+	current, err := ParseTag(oldTag)
+	if err != nil {
+		return field.ErrorList{field.Forbidden(path, "automated image updates require a versioned tag")}
+	}
+	next, err := ParseTag(tag)
+	switch {
+	case err != nil || next.Variant != current.Variant:
+		return field.ErrorList{field.Forbidden(path, fmt.Sprintf("automated image updates must keep a %q tag variant", current.Variant))}
+	case !versions.Contains(next):
+		return field.ErrorList{field.Forbidden(path, fmt.Sprintf("tag %q is outside the update range %q", tag, policy.Range))}
+	case next.Compare(current) <= 0:
+		return field.ErrorList{field.Forbidden(path, "automated image updates must not downgrade")}
+	}
+	return nil
+}
+
+// ValidateStandbyUpdate keeps the standby lifecycle one-way. A running Database has its own
+// data, so it cannot become a standby; a promoted standby has diverged from its primary, so
+// it cannot go back to replicating from it.
+func ValidateStandbyUpdate(old, database *databasev1.Database) field.ErrorList {
+	path := field.NewPath("spec", "standby")
+	switch {
+	case old.Spec.Standby == nil && database.Spec.Standby != nil:
+		return field.ErrorList{field.Forbidden(path, "a Database can only be a standby from its creation")}
+	case old.Spec.Standby == nil:
+		return nil
+	case database.Spec.Standby == nil:
+		if old.Spec.Standby.Promote {
+			// Dropping the standby section of a promoted Database leaves a plain primary
+			return nil
+		}
+		return field.ErrorList{field.Forbidden(path, "a standby must be promoted before it stops being one")}
+	case old.Spec.Standby.Promote && !database.Spec.Standby.Promote:
+		return field.ErrorList{field.Forbidden(path.Child("promote"), "a promoted standby cannot be demoted")}
+	}
+	return nil
+}
+
+// ValidateWorkloadUpdate keeps the workload migration one-way. A Deployment-based Database may
+// move to a StatefulSet; once the migration completed there is no migration back.
+func ValidateWorkloadUpdate(old, database *databasev1.Database) field.ErrorList {
+	if old.Status.Workload == databasev1.WorkloadStatefulSet && database.Spec.Workload != databasev1.WorkloadStatefulSet {
+		return field.ErrorList{field.Forbidden(field.NewPath("spec", "workload"),
+			"a Database migrated to a StatefulSet cannot go back to a Deployment")}
+	}
+	return nil
+}
+
+// ValidateHighAvailabilityUpdate keeps spec.highAvailability as it was at creation. Each pod of
+// a highly available Database has its own volume, so neither the single volume of a Database
+// nor the volumes of the replicas can be carried over.
+func ValidateHighAvailabilityUpdate(old, database *databasev1.Database) field.ErrorList {
+	if old.Spec.HighAvailability != database.Spec.HighAvailability {
+		return field.ErrorList{field.Forbidden(field.NewPath("spec", "highAvailability"),
+			"can only be set when the Database is created")}
+	}
+	return nil
+}
+
+// splitImage splits an image reference without a digest into its name and tag
+func splitImage(image string) (name, tag string) {
+	if colon := strings.LastIndex(image, ":"); colon > strings.LastIndex(image, "/") && !strings.Contains(image, "@") {
+		return image[:colon], image[colon+1:]
+	}
+	return image, ""
+}
+
+// validateMaintenance checks the window and that each task is scheduled once
+func validateMaintenance(path *field.Path, maintenance *databasev1.MaintenanceSpec) field.ErrorList {
+	var errs field.ErrorList
+	window := path.Child("window")
+	if _, err := time.Parse("15:04", maintenance.Window.Start); err != nil || len(maintenance.Window.Start) != len("15:04") {
+		errs = append(errs, field.Invalid(window.Child("start"), maintenance.Window.Start, "must be a time of day as HH:MM"))
+	}
+	// Longer windows would overlap the next day's
+	if duration := maintenance.Window.Duration.Duration; duration <= 0 || duration > 24*time.Hour {
+		errs = append(errs, field.Invalid(window.Child("duration"), duration.String(), "must be positive and at most 24h"))
+	}
+
+	if len(maintenance.Tasks) == 0 {
+		errs = append(errs, field.Required(path.Child("tasks"), ""))
+	}
+	seen := map[databasev1.MaintenanceTaskType]bool{}
+	for i, task := range maintenance.Tasks {
+		if seen[task.Type] {
+			errs = append(errs, field.Duplicate(path.Child("tasks").Index(i).Child("type"), task.Type))
+		}
+		seen[task.Type] = true
+		if task.Interval != nil && task.Interval.Duration <= 0 {
+			errs = append(errs, field.Invalid(path.Child("tasks").Index(i).Child("interval"), task.Interval.Duration.String(), "must be positive"))
+		}
+	}
+	return errs
+}
+
+// validateVerticalScaling checks the bounds, and that applied recommendations have a
+// maintenance window to roll out in
+func validateVerticalScaling(path *field.Path, policy *databasev1.VerticalScalingPolicy,
+	maintenance *databasev1.MaintenanceSpec) field.ErrorList {
+	var errs field.ErrorList
+	if policy.Mode == databasev1.VerticalScalingApply && maintenance == nil {
+		errs = append(errs, field.Required(field.NewPath("spec", "maintenance"),
+			"applying recommendations requires a maintenance window"))
+	}
+
+	supported := []string{string(corev1.ResourceCPU), string(corev1.ResourceMemory)}
+	for _, bound := range []string{"minAllowed", "maxAllowed"} {
+		resources := policy.MinAllowed
+		if bound == "maxAllowed" {
+			resources = policy.MaxAllowed
+		}
+		names := make([]string, 0, len(resources))
+		for name := range resources {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if name != supported[0] && name != supported[1] {
+				errs = append(errs, field.NotSupported(path.Child(bound).Key(name), name, supported))
+			}
+		}
+	}
+	for _, name := range supported {
+		lower, hasLower := policy.MinAllowed[corev1.ResourceName(name)]
+		upper, hasUpper := policy.MaxAllowed[corev1.ResourceName(name)]
+		if hasLower && hasUpper && lower.Cmp(upper) > 0 {
+			errs = append(errs, field.Invalid(path.Child("minAllowed").Key(name), lower.String(),
+				fmt.Sprintf("must be less than or equal to the %s maxAllowed of %s", name, upper.String())))
+		}
+	}
+	return errs
+}
+
+// validateName checks an optional reference to an object name
+func validateName(path *field.Path, name string) field.ErrorList {
+	if name == "" {
+		return nil
+	}
+	var errs field.ErrorList
+	for _, msg := range validation.IsDNS1123Subdomain(name) {
+		errs = append(errs, field.Invalid(path, name, msg))
+	}
+	return errs
+}
+
+// validateParameters rejects setting names PostgreSQL does not accept and values with
+// control characters, which would end the -c argument early or inject another setting
+func validateParameters(path *field.Path, parameters map[string]string) field.ErrorList {
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs field.ErrorList
+	for _, name := range names {
+		if !parameterPattern.MatchString(name) {
+			errs = append(errs, field.Invalid(path.Key(name), name, "must be a PostgreSQL setting name, e.g. max_connections"))
+			continue
+		}
+		if value := parameters[name]; value == "" || !identifierPattern.MatchString(value) {
+			errs = append(errs, field.Invalid(path.Key(name), value, "must be non-empty and must not contain control characters"))
+		}
+	}
+	return errs
+}
+
+// validateResources rejects requests above their limits, which the API server only reports
+// once the Deployment creates pods
+func validateResources(path *field.Path, resources *corev1.ResourceRequirements) field.ErrorList {
+	names := make([]string, 0, len(resources.Requests))
+	for name := range resources.Requests {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	var errs field.ErrorList
+	for _, name := range names {
+		request := resources.Requests[corev1.ResourceName(name)]
+		if limit, ok := resources.Limits[corev1.ResourceName(name)]; ok && request.Cmp(limit) > 0 {
+			errs = append(errs, field.Invalid(path.Child("requests").Key(name), request.String(),
+				fmt.Sprintf("must be less than or equal to the %s limit of %s", name, limit.String())))
+		}
+	}
+	return errs
+}
+
+// unresolved reports whether value still holds a ${VAR} reference
+func unresolved(value string) bool {
+	return strings.Contains(value, "${")
+}
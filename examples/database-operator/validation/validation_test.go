@@ -0,0 +1,335 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	databasev1 "your.domain/project/api/v1"
+)
+
+func TestValidateDatabase(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(spec *databasev1.DatabaseSpec)
+		fields []string
+	}{
+		{
+			name:   "valid",
+			mutate: func(spec *databasev1.DatabaseSpec) {},
+		},
+		{
+			name: "valid with registry, port and digest",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.Image = "registry.example.com:5000/team/postgres:15.4@sha256:" + strings.Repeat("ab", 32)
+				spec.DatabaseName = "orders-eu"
+			},
+		},
+		{
+			name:   "invalid image",
+			mutate: func(spec *databasev1.DatabaseSpec) { spec.Image = "Postgres:15 " },
+			fields: []string{"spec.image"},
+		},
+		{
+			name:   "missing image",
+			mutate: func(spec *databasev1.DatabaseSpec) { spec.Image = "" },
+			fields: []string{"spec.image"},
+		},
+		{
+			name: "image from the class",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.Image = ""
+				spec.ClassName = "standard"
+			},
+		},
+		{
+			name: "parameters",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.Parameters = map[string]string{
+					"max_connections":          "200",
+					"pg_stat_statements.track": "all",
+					"Max-Connections":          "200",
+					"work_mem":                 "4MB\n-c fsync=off",
+					"shared_buffers":           "",
+				}
+			},
+			fields: []string{"spec.parameters[Max-Connections]", "spec.parameters[shared_buffers]", "spec.parameters[work_mem]"},
+		},
+		{
+			name: "unresolved variables are not checked",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.Image = "${REGISTRY}/postgres:15"
+				spec.StorageClass = "${STORAGE_TIER}"
+			},
+		},
+		{
+			name: "identifiers",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.DatabaseName = strings.Repeat("d", 64)
+				spec.UserName = "admin\n"
+			},
+			fields: []string{"spec.databaseName", "spec.userName"},
+		},
+		{
+			name: "object names",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.ConfigMapName = "Settings"
+				spec.StorageClass = "fast_ssd"
+				spec.PriorityClassName = "Critical"
+				spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}, {}}
+			},
+			fields: []string{"spec.configMapName", "spec.storageClass", "spec.priorityClassName", "spec.imagePullSecrets[1].name"},
+		},
+		{
+			name: "requests above limits",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.Resources = &corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("2"),
+						corev1.ResourceMemory: resource.MustParse("4Gi"),
+					},
+					Limits: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("1"),
+						corev1.ResourceMemory: resource.MustParse("4Gi"),
+					},
+				}
+			},
+			fields: []string{"spec.resources.requests[cpu]"},
+		},
+		{
+			name: "non-positive requeue interval",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.RequeuePolicy = &databasev1.RequeuePolicy{
+					ReadyInterval:    &metav1.Duration{Duration: time.Minute},
+					NotReadyInterval: &metav1.Duration{},
+				}
+			},
+			fields: []string{"spec.requeuePolicy.notReadyInterval"},
+		},
+		{
+			name: "requeue interval below the minimum",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.RequeuePolicy = &databasev1.RequeuePolicy{
+					ReadyInterval: &metav1.Duration{Duration: time.Nanosecond},
+				}
+			},
+			fields: []string{"spec.requeuePolicy.readyInterval"},
+		},
+		{
+			name: "invalid image update range",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.ImageUpdatePolicy = &databasev1.ImageUpdatePolicy{Range: "~15"}
+			},
+			fields: []string{"spec.imageUpdatePolicy.range"},
+		},
+		{
+			name: "invalid primary connection secret",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.Standby = &databasev1.StandbySpec{PrimaryConnectionSecret: "Primary"}
+			},
+			fields: []string{"spec.standby.primaryConnectionSecret"},
+		},
+		{
+			name: "invalid zone topology key",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.ZoneSpread = &databasev1.ZoneSpreadSpec{TopologyKey: "topology.kubernetes.io/zone name"}
+			},
+			fields: []string{"spec.zoneSpread.topologyKey"},
+		},
+		{
+			name: "invalid vertical scaling",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.VerticalScaling = &databasev1.VerticalScalingPolicy{
+					Mode: databasev1.VerticalScalingApply,
+					MinAllowed: corev1.ResourceList{
+						corev1.ResourceCPU:     resource.MustParse("2"),
+						corev1.ResourceStorage: resource.MustParse("1Gi"),
+					},
+					MaxAllowed: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+				}
+			},
+			fields: []string{"spec.maintenance", "spec.verticalScaling.minAllowed[storage]", "spec.verticalScaling.minAllowed[cpu]"},
+		},
+		{
+			name: "invalid maintenance",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.Maintenance = &databasev1.MaintenanceSpec{
+					Window: databasev1.MaintenanceWindow{Start: "3:00", Duration: metav1.Duration{Duration: 48 * time.Hour}},
+					Tasks: []databasev1.MaintenanceTask{
+						{Type: databasev1.MaintenanceVacuumAnalyze},
+						{Type: databasev1.MaintenanceVacuumAnalyze, Interval: &metav1.Duration{}},
+					},
+				}
+			},
+			fields: []string{"spec.maintenance.window.start", "spec.maintenance.window.duration",
+				"spec.maintenance.tasks[1].type", "spec.maintenance.tasks[1].interval"},
+		},
+		{
+			name: "high availability",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.Replicas = 3
+				spec.HighAvailability = true
+				spec.Workload = databasev1.WorkloadStatefulSet
+			},
+		},
+		{
+			name: "highly available standby on a Deployment",
+			mutate: func(spec *databasev1.DatabaseSpec) {
+				spec.HighAvailability = true
+				spec.Workload = databasev1.WorkloadDeployment
+				spec.Standby = &databasev1.StandbySpec{PrimaryConnectionSecret: "primary"}
+			},
+			fields: []string{"spec.highAvailability", "spec.workload"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := &databasev1.Database{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+				Spec:       databasev1.DatabaseSpec{Replicas: 1, Image: "postgres:15", Storage: 1024},
+			}
+			tt.mutate(&database.Spec)
+
+			var fields []string
+			for _, err := range ValidateDatabase(database) {
+				fields = append(fields, err.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
+
+func TestValidateImageUpdate(t *testing.T) {
+	old := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-db", Namespace: "default"},
+		Spec: databasev1.DatabaseSpec{
+			Replicas:          1,
+			Image:             "postgres:15.2-alpine",
+			Storage:           1024,
+			ImageUpdatePolicy: &databasev1.ImageUpdatePolicy{Range: ">=15.2 <16", Mode: databasev1.ImageUpdateApply},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		image     string
+		automated bool
+		mode      databasev1.ImageUpdateMode
+		allowed   bool
+	}{
+		{name: "within range", image: "postgres:15.4-alpine", automated: true, allowed: true},
+		{name: "outside range", image: "postgres:16.1-alpine", automated: true},
+		{name: "downgrade", image: "postgres:15.1-alpine", automated: true},
+		{name: "other variant", image: "postgres:15.4", automated: true},
+		{name: "other repository", image: "example.com/postgres:15.4-alpine", automated: true},
+		{name: "notify mode", image: "postgres:15.4-alpine", automated: true, mode: databasev1.ImageUpdateNotify},
+		{name: "manual update", image: "postgres:16.1", allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := old.DeepCopy()
+			database.Spec.Image = tt.image
+			if tt.automated {
+				database.Annotations = map[string]string{databasev1.AutomatedImageAnnotation: tt.image}
+			}
+			if tt.mode != "" {
+				database.Spec.ImageUpdatePolicy.Mode = tt.mode
+			}
+
+			errs := ValidateImageUpdate(old, database)
+			assert.Equal(t, tt.allowed, len(errs) == 0, "%v", errs)
+		})
+	}
+}
+
+func TestValidateStandbyUpdate(t *testing.T) {
+	standby := func(promote bool) *databasev1.Database {
+		return &databasev1.Database{Spec: databasev1.DatabaseSpec{
+			Standby: &databasev1.StandbySpec{PrimaryConnectionSecret: "primary", Promote: promote},
+		}}
+	}
+	primary := &databasev1.Database{}
+
+	tests := []struct {
+		name     string
+		old, new *databasev1.Database
+		allowed  bool
+	}{
+		{name: "promote", old: standby(false), new: standby(true), allowed: true},
+		{name: "drop standby after promotion", old: standby(true), new: primary, allowed: true},
+		{name: "unchanged primary", old: primary, new: primary, allowed: true},
+		{name: "become a standby", old: primary, new: standby(false)},
+		{name: "demote", old: standby(true), new: standby(false)},
+		{name: "drop standby without promotion", old: standby(false), new: primary},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateStandbyUpdate(tt.old, tt.new)
+			assert.Equal(t, tt.allowed, len(errs) == 0, "%v", errs)
+		})
+	}
+}
+
+func TestValidateWorkloadUpdate(t *testing.T) {
+	database := func(spec, status databasev1.WorkloadKind) *databasev1.Database {
+		return &databasev1.Database{
+			Spec:   databasev1.DatabaseSpec{Workload: spec},
+			Status: databasev1.DatabaseStatus{Workload: status},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		old, new *databasev1.Database
+		allowed  bool
+	}{
+		{name: "migrate", old: database("", ""), new: database(databasev1.WorkloadStatefulSet, ""), allowed: true},
+		{name: "cancel before the migration completed", old: database(databasev1.WorkloadStatefulSet, databasev1.WorkloadDeployment),
+			new: database(databasev1.WorkloadDeployment, databasev1.WorkloadDeployment), allowed: true},
+		{name: "migrate back", old: database(databasev1.WorkloadStatefulSet, databasev1.WorkloadStatefulSet),
+			new: database("", databasev1.WorkloadStatefulSet)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateWorkloadUpdate(tt.old, tt.new)
+			assert.Equal(t, tt.allowed, len(errs) == 0, "%v", errs)
+		})
+	}
+}
+
+func TestValidateHighAvailabilityUpdate(t *testing.T) {
+	database := func(highAvailability bool) *databasev1.Database {
+		return &databasev1.Database{Spec: databasev1.DatabaseSpec{HighAvailability: highAvailability}}
+	}
+
+	assert.Empty(t, ValidateHighAvailabilityUpdate(database(true), database(true)))
+	assert.Empty(t, ValidateHighAvailabilityUpdate(database(false), database(false)))
+	assert.Len(t, ValidateHighAvailabilityUpdate(database(false), database(true)), 1, "enable")
+	assert.Len(t, ValidateHighAvailabilityUpdate(database(true), database(false)), 1, "disable")
+}
+
+func TestVersionRange(t *testing.T) {
+	versions, err := ParseVersionRange(">=15.2 <16")
+	assert.NoError(t, err)
+
+	for tag, contained := range map[string]bool{
+		"15.2": true, "15.10": true, "15.4.1": true, "15": false, "16": false, "16.0.1": false, "v15.3": true,
+	} {
+		parsed, err := ParseTag(tag)
+		assert.NoError(t, err)
+		assert.Equal(t, contained, versions.Contains(parsed), tag)
+	}
+
+	for _, invalid := range []string{"", "~15", ">=15-alpine", "=>15"} {
+		_, err := ParseVersionRange(invalid)
+		assert.Error(t, err, invalid)
+	}
+	_, err = ParseTag("latest")
+	assert.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// tagPattern splits an image tag into a dotted version and a variant suffix, e.g.
+// 15.4-alpine into 15.4 and -alpine
+var tagPattern = regexp.MustCompile(`^v?([0-9]+(?:\.[0-9]+){0,3})(.*)$`)
+
+// Tag is an image tag that holds a version. Tags of database images are rarely strict semver
+// (postgres:15, postgres:15.4-alpine), so versions have one to four numeric components, and
+// missing components compare as zero.
+type Tag struct {
+	Version []int
+
+	// Variant is the rest of the tag after the version. Tags only compare within a variant:
+	// an image built on alpine is not an update of one built on debian.
+	Variant string
+}
+
+// ParseTag parses a versioned image tag; "latest" and other unversioned tags fail
+func ParseTag(tag string) (Tag, error) {
+	match := tagPattern.FindStringSubmatch(tag)
+	if match == nil {
+		return Tag{}, fmt.Errorf("tag %q does not start with a version", tag)
+	}
+	var parsed Tag
+	for _, component := range strings.Split(match[1], ".") {
+		n, err := strconv.Atoi(component)
+		if err != nil {
+			return Tag{}, fmt.Errorf("tag %q: %w", tag, err)
+		}
+		parsed.Version = append(parsed.Version, n)
+	}
+	parsed.Variant = match[2]
+	return parsed, nil
+}
+
+// Compare returns -1, 0 or 1 as t is older than, the same version as or newer than other,
+// ignoring the variant
+func (t Tag) Compare(other Tag) int {
+	for i := 0; i < len(t.Version) || i < len(other.Version); i++ {
+		a, b := component(t.Version, i), component(other.Version, i)
+		if a < b {
+			return -1
+		}
+		if a > b {
+			return 1
+		}
+	}
+	return 0
+}
+
+func component(version []int, i int) int {
+	if i < len(version) {
+		return version[i]
+	}
+	return 0
+}
+
+// VersionRange is a set of comparisons a version must all satisfy, e.g. ">=15.2 <16"
+type VersionRange struct {
+	constraints []versionConstraint
+}
+
+type versionConstraint struct {
+	op      string
+	version Tag
+}
+
+// ParseVersionRange parses space-separated comparisons using =, >, >=, < and <=
+func ParseVersionRange(s string) (VersionRange, error) {
+	var r VersionRange
+	for _, field := range strings.Fields(s) {
+		op := field[:len(field)-len(strings.TrimLeft(field, "<>="))]
+		if op != "" && op != "=" && op != ">" && op != ">=" && op != "<" && op != "<=" {
+			return VersionRange{}, fmt.Errorf("%q: unknown comparison %q", field, op)
+		}
+		version, err := ParseTag(field[len(op):])
+		if err != nil || version.Variant != "" {
+			return VersionRange{}, fmt.Errorf("%q: expected a comparison with a version such as >=15.2", field)
+		}
+		if op == "" {
+			op = "="
+		}
+		r.constraints = append(r.constraints, versionConstraint{op: op, version: version})
+	}
+	if len(r.constraints) == 0 {
+		return VersionRange{}, errors.New("empty version range")
+	}
+	return r, nil
+}
+
+// Contains reports whether tag satisfies every comparison
+func (r VersionRange) Contains(tag Tag) bool {
+	for _, c := range r.constraints {
+		cmp := tag.Compare(c.version)
+		var ok bool
+		switch c.op {
+		case "=":
+			ok = cmp == 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
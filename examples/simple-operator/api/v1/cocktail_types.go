@@ -28,6 +28,33 @@ type CocktailSpec struct {
 	// +kubebuilder:validation:Optional
 	// Instructions are custom preparation instructions
 	Instructions string `json:"instructions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Bartenders scales a Deployment of bartenders with the backlog of CocktailOrders for this
+	// Cocktail through a KEDA ScaledObject
+	Bartenders *BartendersSpec `json:"bartenders,omitempty"`
+}
+
+// BartendersSpec describes the bartenders serving the orders of a Cocktail
+type BartendersSpec struct {
+	// +kubebuilder:validation:MinLength=1
+	// Deployment is the name of the bartender Deployment, in the namespace of the Cocktail
+	Deployment string `json:"deployment"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// MinReplicas is the number of bartenders without a backlog; zero scales them away
+	MinReplicas int32 `json:"minReplicas,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// MaxReplicas bounds the number of bartenders
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=5
+	// OrdersPerBartender is the backlog one bartender is expected to work through
+	OrdersPerBartender int32 `json:"ordersPerBartender,omitempty"`
 }
 
 // CocktailStatus defines the observed state of Cocktail
@@ -47,9 +74,17 @@ type CocktailStatus struct {
 	// LastPrepared is the timestamp when the cocktail was last prepared
 	LastPrepared *metav1.Time `json:"lastPrepared,omitempty"`
 
+	// +kubebuilder:validation:Optional
+	// QueuedOrders is the number of CocktailOrders for this Cocktail not served yet
+	QueuedOrders int32 `json:"queuedOrders,omitempty"`
+
 	// +kubebuilder:validation:Optional
 	// Conditions represent the latest available observations
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchMergeKey:"type" patchStrategy:"merge"`
+
+	// +kubebuilder:validation:Optional
+	// ObservedGeneration is the most recent generation observed
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -57,6 +92,7 @@ type CocktailStatus struct {
 //+kubebuilder:resource:shortName=cocktail
 //+kubebuilder:printcolumn:name="PHASE",type=string,JSONPath=`.status.phase`
 //+kubebuilder:printcolumn:name="READY",type=string,JSONPath=`.status.servingsReady`
+//+kubebuilder:printcolumn:name="QUEUED",type=integer,JSONPath=`.status.queuedOrders`
 //+kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Cocktail is the Schema for the cocktails API
@@ -0,0 +1,76 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Phases of a CocktailOrder
+const (
+	// OrderQueued is the phase of an order waiting for a bartender
+	OrderQueued = "Queued"
+
+	// OrderServed is the phase a bartender sets once the order is served
+	OrderServed = "Served"
+)
+
+// CocktailOrderSpec defines the desired state of CocktailOrder
+type CocktailOrderSpec struct {
+	// +kubebuilder:validation:MinLength=1
+	// Cocktail is the name of the Cocktail ordered, in the namespace of the order
+	Cocktail string `json:"cocktail"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// Servings is the number of servings ordered
+	Servings int32 `json:"servings,omitempty"`
+}
+
+// CocktailOrderStatus defines the observed state of CocktailOrder
+type CocktailOrderStatus struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Queued;Served
+	// Phase is set to Served by the bartender serving the order; an order without a phase
+	// is queued
+	Phase string `json:"phase,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ServedAt is the time the order was served
+	ServedAt *metav1.Time `json:"servedAt,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=order
+//+kubebuilder:printcolumn:name="COCKTAIL",type=string,JSONPath=`.spec.cocktail`
+//+kubebuilder:printcolumn:name="SERVINGS",type=integer,JSONPath=`.spec.servings`
+//+kubebuilder:printcolumn:name="PHASE",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// CocktailOrder is an order for a Cocktail. Orders that are not served yet make up the
+// backlog of the Cocktail, which scales its bartenders.
+type CocktailOrder struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CocktailOrderSpec   `json:"spec,omitempty"`
+	Status CocktailOrderStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CocktailOrderList contains a list of CocktailOrder
+type CocktailOrderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CocktailOrder `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CocktailOrder{}, &CocktailOrderList{})
+}
+
+// IsServed returns true once a bartender served the order
+func (o *CocktailOrder) IsServed() bool {
+	return o.Status.Phase == OrderServed
+}
@@ -0,0 +1,16 @@
+package v1
+
+import "your.domain/project/health"
+
+// ComputeHealth returns the kstatus health of a Cocktail. Cocktails do not record the
+// observed generation, so one the controller has not looked at yet, without a phase, is
+// InProgress rather than Current.
+func ComputeHealth(cocktail *Cocktail) health.Result {
+	if cocktail.DeletionTimestamp == nil && cocktail.Status.Phase == "" && len(cocktail.Status.Conditions) == 0 {
+		return health.Result{Status: health.InProgress, Message: "Cocktail has not been reconciled yet"}
+	}
+	return health.Compute(health.Input{
+		Object:     cocktail,
+		Conditions: cocktail.Status.Conditions,
+	})
+}
@@ -0,0 +1,223 @@
+//go:build !ignore_autogenerated
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BartendersSpec) DeepCopyInto(out *BartendersSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BartendersSpec.
+func (in *BartendersSpec) DeepCopy() *BartendersSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BartendersSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Cocktail) DeepCopyInto(out *Cocktail) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Cocktail.
+func (in *Cocktail) DeepCopy() *Cocktail {
+	if in == nil {
+		return nil
+	}
+	out := new(Cocktail)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Cocktail) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CocktailList) DeepCopyInto(out *CocktailList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Cocktail, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CocktailList.
+func (in *CocktailList) DeepCopy() *CocktailList {
+	if in == nil {
+		return nil
+	}
+	out := new(CocktailList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CocktailList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CocktailOrder) DeepCopyInto(out *CocktailOrder) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CocktailOrder.
+func (in *CocktailOrder) DeepCopy() *CocktailOrder {
+	if in == nil {
+		return nil
+	}
+	out := new(CocktailOrder)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CocktailOrder) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CocktailOrderList) DeepCopyInto(out *CocktailOrderList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CocktailOrder, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CocktailOrderList.
+func (in *CocktailOrderList) DeepCopy() *CocktailOrderList {
+	if in == nil {
+		return nil
+	}
+	out := new(CocktailOrderList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CocktailOrderList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CocktailOrderSpec) DeepCopyInto(out *CocktailOrderSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CocktailOrderSpec.
+func (in *CocktailOrderSpec) DeepCopy() *CocktailOrderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CocktailOrderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CocktailOrderStatus) DeepCopyInto(out *CocktailOrderStatus) {
+	*out = *in
+	if in.ServedAt != nil {
+		in, out := &in.ServedAt, &out.ServedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CocktailOrderStatus.
+func (in *CocktailOrderStatus) DeepCopy() *CocktailOrderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CocktailOrderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CocktailSpec) DeepCopyInto(out *CocktailSpec) {
+	*out = *in
+	if in.Bartenders != nil {
+		in, out := &in.Bartenders, &out.Bartenders
+		*out = new(BartendersSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CocktailSpec.
+func (in *CocktailSpec) DeepCopy() *CocktailSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CocktailSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CocktailStatus) DeepCopyInto(out *CocktailStatus) {
+	*out = *in
+	if in.LastPrepared != nil {
+		in, out := &in.LastPrepared, &out.LastPrepared
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CocktailStatus.
+func (in *CocktailStatus) DeepCopy() *CocktailStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CocktailStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,225 @@
+// Package apiclient decorates the manager's client with request metrics, request tags and
+// opt-in retries of transient errors. main installs it with NewClientFunc, so the Cocktail
+// controller and everything else using mgr.GetClient() is measured the same way.
+//
+// Writes are only retried after errors that say nothing happened, throttling and refused
+// connections. Timeouts and reset connections may hide an applied write and are only retried
+// for reads.
+package apiclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// untagged labels requests without a tag
+const untagged = "none"
+
+var (
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "operator_api_request_duration_seconds",
+		Help:    "Latency of API client calls, including cache reads, by verb, kind, tag and result.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"verb", "kind", "tag", "result"})
+	requestRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "operator_api_request_retries_total",
+		Help: "API client calls retried after a transient error, by verb, kind and tag.",
+	}, []string{"verb", "kind", "tag"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(requestDuration, requestRetries)
+}
+
+type tagKey struct{}
+
+// WithTag tags the requests made with ctx
+func WithTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, tagKey{}, tag)
+}
+
+// TagFromContext returns the tag of ctx, "none" if it has none
+func TagFromContext(ctx context.Context) string {
+	if tag, ok := ctx.Value(tagKey{}).(string); ok && tag != "" {
+		return tag
+	}
+	return untagged
+}
+
+// Options configure the decorator
+type Options struct {
+	// Retry is the backoff between retries of transient errors. Steps is the number of
+	// retries; zero disables them.
+	Retry wait.Backoff
+}
+
+// Client measures, tags and retries the calls of the client it wraps
+type Client struct {
+	client.Client
+	retry wait.Backoff
+}
+
+var _ client.Client = &Client{}
+
+// New wraps c
+func New(c client.Client, opts Options) *Client {
+	return &Client{Client: c, retry: opts.Retry}
+}
+
+// NewClientFunc returns a manager NewClient function that wraps the default client
+func NewClientFunc(opts Options) client.NewClientFunc {
+	return func(config *rest.Config, options client.Options) (client.Client, error) {
+		c, err := client.New(config, options)
+		if err != nil {
+			return nil, err
+		}
+		return New(c, opts), nil
+	}
+}
+
+// Get reads an object
+func (c *Client) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return c.do(ctx, "get", obj, func() error { return c.Client.Get(ctx, key, obj, opts...) })
+}
+
+// List reads a list
+func (c *Client) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.do(ctx, "list", list, func() error { return c.Client.List(ctx, list, opts...) })
+}
+
+// Create creates an object
+func (c *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return c.do(ctx, "create", obj, func() error { return c.Client.Create(ctx, obj, opts...) })
+}
+
+// Update updates an object
+func (c *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return c.do(ctx, "update", obj, func() error { return c.Client.Update(ctx, obj, opts...) })
+}
+
+// Patch patches an object
+func (c *Client) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return c.do(ctx, "patch", obj, func() error { return c.Client.Patch(ctx, obj, patch, opts...) })
+}
+
+// Delete deletes an object
+func (c *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return c.do(ctx, "delete", obj, func() error { return c.Client.Delete(ctx, obj, opts...) })
+}
+
+// DeleteAllOf deletes the matching objects
+func (c *Client) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	return c.do(ctx, "deletecollection", obj, func() error { return c.Client.DeleteAllOf(ctx, obj, opts...) })
+}
+
+// Status returns a status writer whose calls are measured and retried as well
+func (c *Client) Status() client.SubResourceWriter {
+	return &subResourceClient{client: c, name: "status", writer: c.Client.Status()}
+}
+
+// SubResource returns a subresource client whose calls are measured and retried as well
+func (c *Client) SubResource(subResource string) client.SubResourceClient {
+	sub := c.Client.SubResource(subResource)
+	return &subResourceClient{client: c, name: subResource, reader: sub, writer: sub}
+}
+
+// do runs call, records it and retries it while it fails with a transient error
+func (c *Client) do(ctx context.Context, verb string, obj runtime.Object, call func() error) error {
+	tag := TagFromContext(ctx)
+	kind := c.kindOf(obj)
+	backoff := c.retry
+	for {
+		start := time.Now()
+		err := call()
+		requestDuration.WithLabelValues(verb, kind, tag, result(err)).Observe(time.Since(start).Seconds())
+
+		if err == nil || backoff.Steps < 1 || !transient(verb, err) {
+			return err
+		}
+		delay := backoff.Step()
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok && time.Duration(seconds)*time.Second > delay {
+			delay = time.Duration(seconds) * time.Second
+		}
+		requestRetries.WithLabelValues(verb, kind, tag).Inc()
+		log.FromContext(ctx).V(1).Info("Retrying API request", "verb", verb, "kind", kind, "tag", tag,
+			"delay", delay, "error", err.Error())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// kindOf labels a call by the kind of its object; lists by the kind of their items
+func (c *Client) kindOf(obj runtime.Object) string {
+	gvk, err := c.GroupVersionKindFor(obj)
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSuffix(gvk.Kind, "List")
+}
+
+// transient reports whether a failed call may be retried
+func transient(verb string, err error) bool {
+	if apierrors.IsTooManyRequests(err) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	if verb != "get" && verb != "list" && !strings.HasPrefix(verb, "get_") {
+		return false
+	}
+	return apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsServiceUnavailable(err) ||
+		utilnet.IsConnectionReset(err) || utilnet.IsProbableEOF(err)
+}
+
+// result labels the outcome of a call with the API status reason, bounded to a fixed set
+func result(err error) string {
+	if err == nil {
+		return "success"
+	}
+	if reason := apierrors.ReasonForError(err); reason != metav1.StatusReasonUnknown {
+		return string(reason)
+	}
+	return "error"
+}
+
+// subResourceClient measures and retries subresource calls, e.g. status updates
+type subResourceClient struct {
+	client *Client
+	name   string
+	reader client.SubResourceReader
+	writer client.SubResourceWriter
+}
+
+func (s *subResourceClient) Get(ctx context.Context, obj, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	return s.client.do(ctx, "get_"+s.name, obj, func() error { return s.reader.Get(ctx, obj, subResource, opts...) })
+}
+
+func (s *subResourceClient) Create(ctx context.Context, obj, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	return s.client.do(ctx, "create_"+s.name, obj, func() error { return s.writer.Create(ctx, obj, subResource, opts...) })
+}
+
+func (s *subResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	return s.client.do(ctx, "update_"+s.name, obj, func() error { return s.writer.Update(ctx, obj, opts...) })
+}
+
+func (s *subResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	return s.client.do(ctx, "patch_"+s.name, obj, func() error { return s.writer.Patch(ctx, obj, patch, opts...) })
+}
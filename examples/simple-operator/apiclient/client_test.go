@@ -0,0 +1,135 @@
+package apiclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// failing returns interceptor funcs that fail the first calls of each verb with err
+func failing(err error, times int) (interceptor.Funcs, map[string]int) {
+	calls := map[string]int{}
+	fail := func(verb string) error {
+		calls[verb]++
+		if calls[verb] <= times {
+			return err
+		}
+		return nil
+	}
+	return interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if err := fail("get"); err != nil {
+				return err
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if err := fail("create"); err != nil {
+				return err
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			if err := fail("update_" + subResource); err != nil {
+				return err
+			}
+			return c.SubResource(subResource).Update(ctx, obj, opts...)
+		},
+	}, calls
+}
+
+func TestClient_Retry(t *testing.T) {
+	retry := wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	timeout := apierrors.NewTimeoutError("request timed out", 0)
+
+	tests := []struct {
+		name    string
+		err     error
+		retry   wait.Backoff
+		verb    string
+		wantErr bool
+		calls   int
+	}{
+		{name: "throttled writes are retried", err: apierrors.NewTooManyRequests("slow down", 0), retry: retry, verb: "create", calls: 3},
+		{name: "refused connections are retried", err: refused, retry: retry, verb: "create", calls: 3},
+		{name: "timed out reads are retried", err: timeout, retry: retry, verb: "get", calls: 3},
+		{name: "timed out writes are not retried", err: timeout, retry: retry, verb: "create", wantErr: true, calls: 1},
+		{name: "status writes are retried", err: refused, retry: retry, verb: "update_status", calls: 3},
+		{name: "retries are opt-in", err: refused, verb: "get", wantErr: true, calls: 1},
+		{name: "other errors are not retried", err: apierrors.NewForbidden(corev1.Resource("configmaps"), "settings", fmt.Errorf("denied")),
+			retry: retry, verb: "get", wantErr: true, calls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			funcs, calls := failing(tt.err, 2)
+			configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"}}
+			builder := fake.NewClientBuilder().WithInterceptorFuncs(funcs)
+			if tt.verb != "create" {
+				builder = builder.WithObjects(configMap).WithStatusSubresource(configMap)
+			}
+			c := New(builder.Build(), Options{Retry: tt.retry})
+			ctx := context.Background()
+
+			var err error
+			switch tt.verb {
+			case "get":
+				err = c.Get(ctx, client.ObjectKeyFromObject(configMap), &corev1.ConfigMap{})
+			case "create":
+				err = c.Create(ctx, configMap)
+			case "update_status":
+				err = c.Status().Update(ctx, configMap)
+			}
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.calls, calls[tt.verb])
+		})
+	}
+}
+
+func TestClient_RetryStopsWithContext(t *testing.T) {
+	funcs, calls := failing(apierrors.NewTooManyRequests("slow down", 60), 10)
+	c := New(fake.NewClientBuilder().WithInterceptorFuncs(funcs).Build(),
+		Options{Retry: wait.Backoff{Duration: time.Millisecond, Steps: 5}})
+
+	// The server asks for a minute; the context ends first
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "settings"}, &corev1.ConfigMap{})
+	assert.True(t, apierrors.IsTooManyRequests(err))
+	assert.Equal(t, 1, calls["get"])
+}
+
+func TestClient_Metrics(t *testing.T) {
+	funcs, _ := failing(apierrors.NewTooManyRequests("slow down", 0), 1)
+	c := New(fake.NewClientBuilder().WithInterceptorFuncs(funcs).Build(),
+		Options{Retry: wait.Backoff{Duration: time.Millisecond, Steps: 1}})
+	ctx := WithTag(context.Background(), "metrics-test")
+
+	before := testutil.CollectAndCount(requestDuration)
+	err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "missing"}, &corev1.ConfigMap{})
+	require.True(t, apierrors.IsNotFound(err))
+	require.NoError(t, c.List(ctx, &corev1.ConfigMapList{}))
+
+	assert.Equal(t, before+3, testutil.CollectAndCount(requestDuration), "One series per verb, kind, tag and result")
+	assert.Equal(t, 1.0, testutil.ToFloat64(requestRetries.WithLabelValues("get", "ConfigMap", "metrics-test")))
+	assert.Equal(t, "none", TagFromContext(context.Background()))
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barv1 "your.domain/project/api/v1"
+)
+
+// scaledObjectGVK is the KEDA ScaledObject. It is handled as unstructured, so the operator
+// neither depends on KEDA's API module nor fails to start in clusters without KEDA.
+var scaledObjectGVK = schema.GroupVersionKind{Group: "keda.sh", Version: "v1alpha1", Kind: "ScaledObject"}
+
+// conditionAutoscaled reports whether the bartenders of a Cocktail are scaled with its backlog
+const conditionAutoscaled = "Autoscaled"
+
+// DefaultPrometheusAddress is the Prometheus KEDA queries for the backlog: the Service the
+// Prometheus Operator creates for the Prometheus instances of the monitoring namespace
+const DefaultPrometheusAddress = "http://prometheus-operated.monitoring.svc:9090"
+
+// cocktailQueuedOrders exports the backlog of every Cocktail. KEDA reads it back through
+// Prometheus, which closes the loop from the CocktailOrders to the bartender replicas.
+var cocktailQueuedOrders = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "cocktail_queued_orders",
+	Help: "CocktailOrders of a Cocktail that are not served yet.",
+}, []string{"namespace", "cocktail"})
+
+func init() {
+	metrics.Registry.MustRegister(cocktailQueuedOrders)
+}
+
+//+kubebuilder:rbac:groups=bar.my.domain,resources=cocktailorders,verbs=get;list;watch
+//+kubebuilder:rbac:groups=keda.sh,resources=scaledobjects,verbs=get;list;watch;create;update;patch;delete
+
+// observeBacklog counts the queued orders of a Cocktail into its status and the gauge
+func (r *CocktailReconciler) observeBacklog(ctx context.Context, cocktail *barv1.Cocktail) error {
+	orders := &barv1.CocktailOrderList{}
+	if err := r.List(ctx, orders, client.InNamespace(cocktail.Namespace)); err != nil {
+		return fmt.Errorf("failed to list CocktailOrders: %w", err)
+	}
+	var queued int32
+	for i := range orders.Items {
+		if orders.Items[i].Spec.Cocktail == cocktail.Name && !orders.Items[i].IsServed() {
+			queued++
+		}
+	}
+	cocktail.Status.QueuedOrders = queued
+	cocktailQueuedOrders.WithLabelValues(cocktail.Namespace, cocktail.Name).Set(float64(queued))
+	return nil
+}
+
+// reconcileScaledObject manages the KEDA ScaledObject scaling the bartenders of a Cocktail.
+// KEDA queries the backlog exported by observeBacklog and keeps OrdersPerBartender orders
+// per replica. Without KEDA installed the Cocktail is served as usual and only reports that
+// it is not autoscaled.
+func (r *CocktailReconciler) reconcileScaledObject(ctx context.Context, cocktail *barv1.Cocktail) error {
+	scaledObject := &unstructured.Unstructured{}
+	scaledObject.SetGroupVersionKind(scaledObjectGVK)
+	scaledObject.SetNamespace(cocktail.Namespace)
+	scaledObject.SetName(scaledObjectName(cocktail))
+
+	bartenders := cocktail.Spec.Bartenders
+	if bartenders == nil {
+		meta.RemoveStatusCondition(&cocktail.Status.Conditions, conditionAutoscaled)
+		err := r.Get(ctx, client.ObjectKeyFromObject(scaledObject), scaledObject)
+		if meta.IsNoMatchError(err) || apierrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !metav1.IsControlledBy(scaledObject, cocktail) {
+			return nil
+		}
+		return client.IgnoreNotFound(r.Delete(ctx, scaledObject))
+	}
+
+	ordersPerBartender := bartenders.OrdersPerBartender
+	if ordersPerBartender < 1 {
+		ordersPerBartender = 5
+	}
+	prometheusAddress := r.PrometheusAddress
+	if prometheusAddress == "" {
+		prometheusAddress = DefaultPrometheusAddress
+	}
+	query := fmt.Sprintf(`sum(cocktail_queued_orders{namespace=%q,cocktail=%q})`, cocktail.Namespace, cocktail.Name)
+
+	_, err := controllerutil.CreateOrPatch(ctx, r.Client, scaledObject, func() error {
+		scaledObject.Object["spec"] = map[string]interface{}{
+			"scaleTargetRef":  map[string]interface{}{"name": bartenders.Deployment},
+			"minReplicaCount": int64(bartenders.MinReplicas),
+			"maxReplicaCount": int64(bartenders.MaxReplicas),
+			"triggers": []interface{}{map[string]interface{}{
+				"type": "prometheus",
+				"metadata": map[string]interface{}{
+					"serverAddress": prometheusAddress,
+					"query":         query,
+					"threshold":     strconv.Itoa(int(ordersPerBartender)),
+				},
+			}},
+		}
+		return controllerutil.SetControllerReference(cocktail, scaledObject, r.Scheme)
+	})
+	if meta.IsNoMatchError(err) {
+		cocktail.SetCondition(conditionAutoscaled, metav1.ConditionFalse, "KEDANotInstalled",
+			"KEDA ScaledObjects are not available in this cluster; the bartenders are not scaled")
+		return nil
+	}
+	if err != nil {
+		cocktail.SetCondition(conditionAutoscaled, metav1.ConditionFalse, "ScaledObjectFailed", err.Error())
+		return fmt.Errorf("failed to reconcile ScaledObject: %w", err)
+	}
+	cocktail.SetCondition(conditionAutoscaled, metav1.ConditionTrue, "ScaledObjectReady",
+		fmt.Sprintf("Deployment %s is scaled with %d queued orders per bartender", bartenders.Deployment, ordersPerBartender))
+	return nil
+}
+
+// scaledObjectName is the name of the ScaledObject of a Cocktail
+func scaledObjectName(cocktail *barv1.Cocktail) string {
+	return cocktail.Name + "-bartenders"
+}
+
+// cocktailForOrder maps a CocktailOrder to the Cocktail whose backlog it belongs to
+func cocktailForOrder(ctx context.Context, obj client.Object) []reconcile.Request {
+	order, ok := obj.(*barv1.CocktailOrder)
+	if !ok || order.Spec.Cocktail == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: order.Namespace, Name: order.Spec.Cocktail}}}
+}
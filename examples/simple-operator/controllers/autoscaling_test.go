@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barv1 "your.domain/project/api/v1"
+)
+
+func newOrder(name, cocktail, phase string) *barv1.CocktailOrder {
+	return &barv1.CocktailOrder{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "bar"},
+		Spec:       barv1.CocktailOrderSpec{Cocktail: cocktail, Servings: 1},
+		Status:     barv1.CocktailOrderStatus{Phase: phase},
+	}
+}
+
+func newAutoscaledCocktail() *barv1.Cocktail {
+	return &barv1.Cocktail{
+		ObjectMeta: metav1.ObjectMeta{Name: "mojito", Namespace: "bar"},
+		Spec: barv1.CocktailSpec{
+			Size:   1,
+			Recipe: "Mojito",
+			Bartenders: &barv1.BartendersSpec{
+				Deployment:         "mojito-bartenders",
+				MaxReplicas:        4,
+				OrdersPerBartender: 3,
+			},
+		},
+	}
+}
+
+func TestCocktailReconciler_ScalesBartendersWithBacklog(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, barv1.AddToScheme(scheme))
+	// KEDA is installed
+	scheme.AddKnownTypeWithName(scaledObjectGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(scaledObjectGVK.GroupVersion().WithKind("ScaledObjectList"), &unstructured.UnstructuredList{})
+
+	cocktail := newAutoscaledCocktail()
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(cocktail,
+			newOrder("first", "mojito", ""),
+			newOrder("second", "mojito", barv1.OrderQueued),
+			newOrder("served", "mojito", barv1.OrderServed),
+			newOrder("other", "margarita", "")).
+		WithStatusSubresource(cocktail).
+		Build()
+	reconciler := &CocktailReconciler{Client: fakeClient, Scheme: scheme, PrometheusAddress: "http://prometheus:9090"}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "mojito", Namespace: "bar"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	require.NoError(t, fakeClient.Get(ctx, key, cocktail))
+	assert.Equal(t, int32(2), cocktail.Status.QueuedOrders, "Served orders and orders of other Cocktails do not count")
+	assert.Equal(t, 2.0, testutil.ToFloat64(cocktailQueuedOrders.WithLabelValues("bar", "mojito")))
+	condition := cocktail.GetCondition(conditionAutoscaled)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+
+	scaledObject := &unstructured.Unstructured{}
+	scaledObject.SetGroupVersionKind(scaledObjectGVK)
+	scaledObjectKey := types.NamespacedName{Name: "mojito-bartenders", Namespace: "bar"}
+	require.NoError(t, fakeClient.Get(ctx, scaledObjectKey, scaledObject))
+	assert.True(t, metav1.IsControlledBy(scaledObject, cocktail))
+	target, _, _ := unstructured.NestedString(scaledObject.Object, "spec", "scaleTargetRef", "name")
+	assert.Equal(t, "mojito-bartenders", target)
+	maxReplicas, _, _ := unstructured.NestedInt64(scaledObject.Object, "spec", "maxReplicaCount")
+	assert.Equal(t, int64(4), maxReplicas)
+	triggers, _, _ := unstructured.NestedSlice(scaledObject.Object, "spec", "triggers")
+	require.Len(t, triggers, 1)
+	assert.Equal(t, map[string]interface{}{
+		"serverAddress": "http://prometheus:9090",
+		"query":         `sum(cocktail_queued_orders{namespace="bar",cocktail="mojito"})`,
+		"threshold":     "3",
+	}, triggers[0].(map[string]interface{})["metadata"])
+
+	// Serving an order shrinks the backlog
+	served := newOrder("first", "mojito", "")
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(served), served))
+	served.Status.Phase = barv1.OrderServed
+	require.NoError(t, fakeClient.Update(ctx, served))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Get(ctx, key, cocktail))
+	assert.Equal(t, int32(1), cocktail.Status.QueuedOrders)
+	assert.Equal(t, 1.0, testutil.ToFloat64(cocktailQueuedOrders.WithLabelValues("bar", "mojito")))
+
+	// Without bartenders the ScaledObject goes away
+	cocktail.Spec.Bartenders = nil
+	require.NoError(t, fakeClient.Update(ctx, cocktail))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	err = fakeClient.Get(ctx, scaledObjectKey, scaledObject)
+	assert.True(t, apierrors.IsNotFound(err), "The ScaledObject is deleted")
+	require.NoError(t, fakeClient.Get(ctx, key, cocktail))
+	assert.Nil(t, cocktail.GetCondition(conditionAutoscaled))
+}
+
+func TestCocktailReconciler_WithoutKEDA(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, barv1.AddToScheme(scheme))
+
+	cocktail := newAutoscaledCocktail()
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(cocktail, newOrder("first", "mojito", "")).
+		WithStatusSubresource(cocktail).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				if obj.GetObjectKind().GroupVersionKind() == scaledObjectGVK {
+					return &meta.NoKindMatchError{GroupKind: scaledObjectGVK.GroupKind(), SearchedVersions: []string{"v1alpha1"}}
+				}
+				return c.Get(ctx, key, obj, opts...)
+			},
+		}).
+		Build()
+	reconciler := &CocktailReconciler{Client: fakeClient, Scheme: scheme}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "mojito", Namespace: "bar"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err, "A cluster without KEDA still serves Cocktails")
+
+	require.NoError(t, fakeClient.Get(ctx, key, cocktail))
+	assert.True(t, cocktail.IsReady())
+	assert.Equal(t, int32(1), cocktail.Status.QueuedOrders)
+	condition := cocktail.GetCondition(conditionAutoscaled)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "KEDANotInstalled", condition.Reason)
+}
+
+func TestCocktailForOrder(t *testing.T) {
+	requests := cocktailForOrder(context.Background(), newOrder("first", "mojito", ""))
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "bar", Name: "mojito"}}}, requests)
+	assert.Empty(t, cocktailForOrder(context.Background(), newOrder("first", "", "")))
+}
@@ -2,64 +2,78 @@ package controllers
 
 import (
 	"context"
-	"fmt"
 	"time"
 
-	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	barv1 "your.domain/project/api/v1"
+	"your.domain/project/generic"
+	"your.domain/project/middleware"
 )
 
 const cocktailFinalizer = "cocktails.bar.my.domain/finalizer"
 
+// cocktailPausedAnnotation set to "true" stops reconciling a Cocktail, e.g. during maintenance
+const cocktailPausedAnnotation = "cocktails.bar.my.domain/paused"
+
+// reconcileTimeout bounds a single reconcile
+const reconcileTimeout = time.Minute
+
+// defaultRequeueInterval is the freshness check interval used when RequeueInterval is unset
+const defaultRequeueInterval = time.Minute * 5
+
 // CocktailReconciler reconciles a Cocktail object
 type CocktailReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// RequeueInterval is how often a prepared Cocktail is re-checked
+	RequeueInterval time.Duration
+
+	// RequeueJitter is the maximum fraction of RequeueInterval added as random jitter
+	RequeueJitter float64
+
+	// PrometheusAddress is the Prometheus the ScaledObjects of Cocktails query for their
+	// backlog; empty uses DefaultPrometheusAddress
+	PrometheusAddress string
 }
 
 //+kubebuilder:rbac:groups=bar.my.domain,resources=cocktails,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=bar.my.domain,resources=cocktails/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=bar.my.domain,resources=cocktails/finalizers,verbs=update
 
-// Reconcile is the main reconciliation loop for Cocktail resources
+// Reconcile is the main reconciliation loop for Cocktail resources. The generic base fetches
+// the Cocktail, handles pausing, the finalizer and the status write, and calls ReconcileNormal
+// or ReconcileDelete; the middleware chain adds recovery, a timeout, logging and metrics.
 func (r *CocktailReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := log.FromContext(ctx)
-
-	// Fetch the Cocktail instance
-	cocktail := &barv1.Cocktail{}
-	err := r.Get(ctx, req.NamespacedName, cocktail)
-	if err != nil {
-		if errors.IsNotFound(err) {
-			// Object not found, return. Created objects are automatically garbage collected.
-			log.Info("Cocktail resource not found. Ignoring since object must be deleted")
-			return ctrl.Result{}, nil
-		}
-		// Error reading the object - requeue the request.
-		log.Error(err, "Failed to get Cocktail")
-		return ctrl.Result{}, err
-	}
-
-	// Check if the object is being deleted
-	if !cocktail.ObjectMeta.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(ctx, cocktail)
-	}
+	return middleware.Chain(
+		&generic.Reconciler[*barv1.Cocktail]{
+			Client:           r.Client,
+			NewObject:        newCocktail,
+			Finalizer:        cocktailFinalizer,
+			PausedAnnotation: cocktailPausedAnnotation,
+			Handler:          r,
+		},
+		middleware.Recover(),
+		middleware.Logging(),
+		middleware.Metrics("cocktail"),
+		middleware.Timeout(reconcileTimeout),
+	).Reconcile(ctx, req)
+}
 
-	// Add finalizer if not present
-	if !controllerutil.ContainsFinalizer(cocktail, cocktailFinalizer) {
-		controllerutil.AddFinalizer(cocktail, cocktailFinalizer)
-		if err := r.Update(ctx, cocktail); err != nil {
-			return ctrl.Result{}, err
-		}
-		return ctrl.Result{Requeue: true}, nil
-	}
+func newCocktail() *barv1.Cocktail {
+	return &barv1.Cocktail{}
+}
 
-	// Reconcile the cocktail
+// ReconcileNormal prepares a live Cocktail that carries the finalizer
+func (r *CocktailReconciler) ReconcileNormal(ctx context.Context, cocktail *barv1.Cocktail) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
 	log.Info("Reconciling Cocktail", "name", cocktail.Name, "recipe", cocktail.Spec.Recipe)
 
 	// Update observed generation
@@ -68,48 +82,29 @@ func (r *CocktailReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	// Prepare the cocktail
 	if err := r.prepareCocktail(ctx, cocktail); err != nil {
 		log.Error(err, "Failed to prepare Cocktail")
-		r.updateStatus(ctx, cocktail, "Failed", "PreparationError", err.Error())
+		setStatus(cocktail, "Failed", "False", "PreparationError", err.Error())
 		return ctrl.Result{}, err
 	}
 
 	// Update status to indicate success
-	r.updateStatus(ctx, cocktail, "Ready", "Prepared", "Cocktail is ready to serve")
-
-	// Requeue after 5 minutes for freshness check
-	return ctrl.Result{RequeueAfter: time.Minute * 5}, nil
-}
+	setStatus(cocktail, "Ready", "True", "Prepared", "Cocktail is ready to serve")
 
-// reconcileDelete handles deletion of Cocktail resources
-func (r *CocktailReconciler) reconcileDelete(ctx context.Context, cocktail *barv1.Cocktail) (ctrl.Result, error) {
-	log := log.FromContext(ctx)
-	log.Info("Reconciling delete for Cocktail", "name", cocktail.Name)
-
-	if controllerutil.ContainsFinalizer(cocktail, cocktailFinalizer) {
-		// Clean up resources (e.g., wash glasses, clean up)
-		if err := r.cleanupCocktail(ctx, cocktail); err != nil {
-			log.Error(err, "Failed to clean up Cocktail")
-			return ctrl.Result{}, err
-		}
-
-		// Remove finalizer
-		controllerutil.RemoveFinalizer(cocktail, cocktailFinalizer)
-		if err := r.Update(ctx, cocktail); err != nil {
-			return ctrl.Result{}, err
-		}
+	// Scale the bartenders with the orders waiting for them
+	if err := r.observeBacklog(ctx, cocktail); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.reconcileScaledObject(ctx, cocktail); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	// Stop reconciliation as the object is being deleted
-	return ctrl.Result{}, nil
+	// Requeue for freshness check
+	return ctrl.Result{RequeueAfter: r.requeueAfter()}, nil
 }
 
 // prepareCocktail contains the main logic for preparing a cocktail
 func (r *CocktailReconciler) prepareCocktail(ctx context.Context, cocktail *barv1.Cocktail) error {
 	log := log.FromContext(ctx)
 
-	// Update phase to "Preparing"
-	cocktail.Status.Phase = "Preparing"
-	r.Status().Update(ctx, cocktail)
-
 	// Simulate preparation time based on recipe
 	recipe := cocktail.Spec.Recipe
 	preparationTime := r.getPreparationTime(recipe)
@@ -132,6 +127,18 @@ func (r *CocktailReconciler) prepareCocktail(ctx context.Context, cocktail *barv
 	return nil
 }
 
+// requeueAfter returns the jittered freshness check interval
+func (r *CocktailReconciler) requeueAfter() time.Duration {
+	interval := r.RequeueInterval
+	if interval <= 0 {
+		interval = defaultRequeueInterval
+	}
+	if r.RequeueJitter <= 0 {
+		return interval
+	}
+	return wait.Jitter(interval, r.RequeueJitter)
+}
+
 // getPreparationTime returns the time needed to prepare a cocktail
 func (r *CocktailReconciler) getPreparationTime(recipe string) time.Duration {
 	switch recipe {
@@ -148,8 +155,8 @@ func (r *CocktailReconciler) getPreparationTime(recipe string) time.Duration {
 	}
 }
 
-// cleanupCocktail cleans up resources when a cocktail is deleted
-func (r *CocktailReconciler) cleanupCocktail(ctx context.Context, cocktail *barv1.Cocktail) error {
+// ReconcileDelete cleans up resources when a cocktail is deleted
+func (r *CocktailReconciler) ReconcileDelete(ctx context.Context, cocktail *barv1.Cocktail) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 	log.Info("Cleaning up Cocktail", "name", cocktail.Name)
 
@@ -158,26 +165,26 @@ func (r *CocktailReconciler) cleanupCocktail(ctx context.Context, cocktail *barv
 	// 2. Wash glass and equipment
 	// 3. Update inventory
 
-	return nil
+	// The ScaledObject is garbage collected with the Cocktail; its backlog is not exported any more
+	cocktailQueuedOrders.DeleteLabelValues(cocktail.Namespace, cocktail.Name)
+
+	return ctrl.Result{}, nil
 }
 
-// updateStatus updates the status of the Cocktail resource
-func (r *CocktailReconciler) updateStatus(ctx context.Context, cocktail *barv1.Cocktail, phase string, conditionStatus, reason, message string) {
+// setStatus sets the phase and Ready condition; the generic base writes the status back
+func setStatus(cocktail *barv1.Cocktail, phase string, conditionStatus, reason, message string) {
 	// Update phase
 	cocktail.Status.Phase = phase
 
 	// Update condition
 	cocktail.SetCondition("Ready", metav1.ConditionStatus(conditionStatus), reason, message)
-
-	// Update status
-	if err := r.Status().Update(ctx, cocktail); err != nil {
-		log.FromContext(ctx).Error(err, "Failed to update status")
-	}
 }
 
 // SetupWithManager sets up the controller with the Manager
 func (r *CocktailReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&barv1.Cocktail{}).
+		// Every order changes the backlog of its Cocktail
+		Watches(&barv1.CocktailOrder{}, handler.EnqueueRequestsFromMapFunc(cocktailForOrder)).
 		Complete(r)
 }
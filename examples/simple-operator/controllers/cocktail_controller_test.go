@@ -8,11 +8,11 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	barv1 "your.domain/project/api/v1"
@@ -24,10 +24,10 @@ func TestCocktailReconciler_Reconcile(t *testing.T) {
 	require.NoError(t, corev1.AddToScheme(scheme))
 
 	tests := []struct {
-		name           string
+		name            string
 		initialCocktail *barv1.Cocktail
-		expectError    bool
-		verifyStatus   func(*testing.T, *barv1.Cocktail)
+		expectError     bool
+		verifyStatus    func(*testing.T, *barv1.Cocktail)
 	}{
 		{
 			name: "successful reconciliation",
@@ -37,9 +37,9 @@ func TestCocktailReconciler_Reconcile(t *testing.T) {
 					Namespace: "default",
 				},
 				Spec: barv1.CocktailSpec{
-					Size:     2,
-					Recipe:   "Mojito",
-					Garnish:  true,
+					Size:    2,
+					Recipe:  "Mojito",
+					Garnish: true,
 				},
 			},
 			expectError: false,
@@ -67,6 +67,7 @@ func TestCocktailReconciler_Reconcile(t *testing.T) {
 			fakeClient := fake.NewClientBuilder().
 				WithScheme(scheme).
 				WithObjects(tt.initialCocktail).
+				WithStatusSubresource(tt.initialCocktail).
 				Build()
 
 			// Create reconciler
@@ -83,6 +84,9 @@ func TestCocktailReconciler_Reconcile(t *testing.T) {
 				},
 			}
 
+			// The first reconcile only adds the finalizer
+			_, err := reconciler.Reconcile(context.Background(), req)
+			require.NoError(t, err)
 			result, err := reconciler.Reconcile(context.Background(), req)
 
 			// Verify results
@@ -148,11 +152,9 @@ func TestCocktailReconciler_ReconcileWithFinalizer(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, ctrl.Result{}, result)
 
-	// Verify finalizer was removed
-	updatedCocktail := &barv1.Cocktail{}
-	err = fakeClient.Get(context.Background(), req.NamespacedName, updatedCocktail)
-	require.NoError(t, err)
-	assert.Empty(t, updatedCocktail.Finalizers, "Finalizer should be removed after cleanup")
+	// Removing the last finalizer of a deleted object deletes it
+	err = fakeClient.Get(context.Background(), req.NamespacedName, &barv1.Cocktail{})
+	assert.True(t, apierrors.IsNotFound(err), "Cocktail should be gone once the finalizer is removed")
 }
 
 func TestGetPreparationTime(t *testing.T) {
@@ -0,0 +1,50 @@
+// Package finalizer adds a finalizer to live objects and, once they are deleted, runs their
+// cleanup and removes the finalizer again, so reconcilers call one function instead of
+// repeating the sequence. It is patterns/finalizer.go packaged for this operator.
+//
+// Cleanup runs on every reconcile of a deleted object until it succeeds, so it must be
+// idempotent. The finalizer is removed with a patch: nothing can add a finalizer to an object
+// being deleted, and an Update would conflict with every other controller removing its own.
+package finalizer
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// CleanupFunc releases what a deleted object holds that owner references do not, e.g. an
+// external database or a cluster-scoped child. Return a non-zero Result to wait for cleanup
+// still in progress, e.g. RequeueAfter while children terminate; the finalizer stays until it
+// returns an empty Result and no error.
+type CleanupFunc[T client.Object] func(ctx context.Context, obj T) (ctrl.Result, error)
+
+// ReconcileWithFinalizer adds finalizerName to a live obj, and runs cleanup and then removes
+// finalizerName once obj is being deleted. It returns done when the reconcile ends here: obj is
+// being deleted, or adding the finalizer failed. Otherwise obj carries the finalizer and the
+// caller reconciles it as usual.
+func ReconcileWithFinalizer[T client.Object](ctx context.Context, c client.Client, obj T, finalizerName string, cleanup CleanupFunc[T]) (ctrl.Result, bool, error) {
+	if obj.GetDeletionTimestamp().IsZero() {
+		if controllerutil.AddFinalizer(obj, finalizerName) {
+			if err := c.Update(ctx, obj); err != nil {
+				return ctrl.Result{}, true, err
+			}
+		}
+		return ctrl.Result{}, false, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(obj, finalizerName) {
+		return ctrl.Result{}, true, nil
+	}
+	result, err := cleanup(ctx, obj)
+	if err != nil || !result.IsZero() {
+		return result, true, err
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	controllerutil.RemoveFinalizer(obj, finalizerName)
+	// The object may be gone as soon as the last finalizer is removed
+	return ctrl.Result{}, true, client.IgnoreNotFound(c.Patch(ctx, obj, patch))
+}
@@ -0,0 +1,136 @@
+package finalizer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testFinalizer = "test.my.domain/finalizer"
+
+var testKey = client.ObjectKey{Name: "test", Namespace: "default"}
+
+func unexpectedCleanup(t *testing.T) CleanupFunc[*corev1.ConfigMap] {
+	return func(context.Context, *corev1.ConfigMap) (ctrl.Result, error) {
+		t.Fatal("A live object must not be cleaned up")
+		return ctrl.Result{}, nil
+	}
+}
+
+func TestReconcileWithFinalizer_Add(t *testing.T) {
+	c := fake.NewClientBuilder().
+		WithObjects(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: testKey.Name, Namespace: testKey.Namespace}}).
+		Build()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, c.Get(ctx, testKey, cm))
+	version := cm.ResourceVersion
+	result, done, err := ReconcileWithFinalizer(ctx, c, cm, testFinalizer, unexpectedCleanup(t))
+	require.NoError(t, err)
+	assert.False(t, done, "The reconcile goes on with the finalizer in place")
+	assert.True(t, result.IsZero())
+	assert.NotEqual(t, version, cm.ResourceVersion, "The object was refreshed by the Update")
+
+	stored := &corev1.ConfigMap{}
+	require.NoError(t, c.Get(ctx, testKey, stored))
+	assert.Equal(t, []string{testFinalizer}, stored.Finalizers)
+
+	// Nothing is written once the finalizer is there
+	version = stored.ResourceVersion
+	_, done, err = ReconcileWithFinalizer(ctx, c, stored, testFinalizer, unexpectedCleanup(t))
+	require.NoError(t, err)
+	assert.False(t, done)
+	assert.Equal(t, version, stored.ResourceVersion)
+}
+
+func TestReconcileWithFinalizer_Delete(t *testing.T) {
+	now := metav1.Now()
+	c := fake.NewClientBuilder().
+		WithObjects(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:              testKey.Name,
+			Namespace:         testKey.Namespace,
+			DeletionTimestamp: &now,
+			Finalizers:        []string{testFinalizer, "other.my.domain/finalizer"},
+		}}).
+		Build()
+	ctx := context.Background()
+
+	reconcile := func(cleanup CleanupFunc[*corev1.ConfigMap]) (ctrl.Result, bool, error) {
+		cm := &corev1.ConfigMap{}
+		require.NoError(t, c.Get(ctx, testKey, cm))
+		return ReconcileWithFinalizer(ctx, c, cm, testFinalizer, cleanup)
+	}
+	stored := func() []string {
+		cm := &corev1.ConfigMap{}
+		require.NoError(t, c.Get(ctx, testKey, cm))
+		return cm.Finalizers
+	}
+
+	failed := errors.New("still in use")
+	_, done, err := reconcile(func(context.Context, *corev1.ConfigMap) (ctrl.Result, error) {
+		return ctrl.Result{}, failed
+	})
+	assert.True(t, done)
+	assert.ErrorIs(t, err, failed)
+	assert.Contains(t, stored(), testFinalizer, "The finalizer is kept while cleanup fails")
+
+	result, done, err := reconcile(func(context.Context, *corev1.ConfigMap) (ctrl.Result, error) {
+		return ctrl.Result{RequeueAfter: time.Second}, nil
+	})
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, time.Second, result.RequeueAfter)
+	assert.Contains(t, stored(), testFinalizer, "The finalizer is kept while cleanup is in progress")
+
+	cleanups := 0
+	cleanup := func(context.Context, *corev1.ConfigMap) (ctrl.Result, error) {
+		cleanups++
+		return ctrl.Result{}, nil
+	}
+	_, done, err = reconcile(cleanup)
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, []string{"other.my.domain/finalizer"}, stored(), "Only its own finalizer is removed")
+
+	// Once released, the object is left to the other finalizer
+	_, done, err = reconcile(cleanup)
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, 1, cleanups)
+}
+
+func TestReconcileWithFinalizer_LastFinalizer(t *testing.T) {
+	now := metav1.Now()
+	c := fake.NewClientBuilder().
+		WithObjects(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:              testKey.Name,
+			Namespace:         testKey.Namespace,
+			DeletionTimestamp: &now,
+			Finalizers:        []string{testFinalizer},
+		}}).
+		Build()
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, c.Get(ctx, testKey, cm))
+	_, done, err := ReconcileWithFinalizer(ctx, c, cm, testFinalizer, func(context.Context, *corev1.ConfigMap) (ctrl.Result, error) {
+		return ctrl.Result{}, nil
+	})
+	require.NoError(t, err)
+	assert.True(t, done)
+
+	// The fake client deletes the object once its last finalizer is removed
+	err = c.Get(ctx, testKey, &corev1.ConfigMap{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
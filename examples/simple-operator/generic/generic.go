@@ -0,0 +1,122 @@
+// Package generic provides a typed reconciler base: it fetches the object, ignores requests
+// for deleted objects, honours a pause annotation, manages the finalizer, dispatches deletion
+// to a Handler and patches the status once at the end. See CocktailReconciler for its use.
+//
+// Handlers change the status in memory only; it is written back when it differs from the
+// status as read, also when the handler failed.
+package generic
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"your.domain/project/finalizer"
+)
+
+// Handler holds the type-specific logic of a Reconciler
+type Handler[T client.Object] interface {
+	// ReconcileNormal reconciles a live object. The finalizer, if any, is already in place.
+	ReconcileNormal(ctx context.Context, obj T) (ctrl.Result, error)
+
+	// ReconcileDelete cleans up after an object that is being deleted. The finalizer is
+	// removed once it returns no error and an empty result; return RequeueAfter to wait
+	// for cleanup that is still in progress.
+	ReconcileDelete(ctx context.Context, obj T) (ctrl.Result, error)
+}
+
+// Reconciler reconciles objects of type T with a Handler
+type Reconciler[T client.Object] struct {
+	Client client.Client
+
+	// NewObject returns an empty object to read into
+	NewObject func() T
+
+	// Finalizer is added to every live object and removed after ReconcileDelete. Without a
+	// finalizer ReconcileDelete is never called; owner references clean up instead.
+	Finalizer string
+
+	// PausedAnnotation set to "true" skips reconciling a live object; empty disables pausing.
+	// Deletion is never paused.
+	PausedAnnotation string
+
+	Handler Handler[T]
+}
+
+var _ reconcile.Reconciler = &Reconciler[client.Object]{}
+
+// Reconcile fetches the object and dispatches it to the Handler
+func (r *Reconciler[T]) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	obj := r.NewObject()
+	if err := r.Client.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	deleting := !obj.GetDeletionTimestamp().IsZero()
+	if !deleting && r.PausedAnnotation != "" && obj.GetAnnotations()[r.PausedAnnotation] == "true" {
+		log.FromContext(ctx).V(1).Info("Reconcile paused", "annotation", r.PausedAnnotation)
+		return ctrl.Result{}, nil
+	}
+
+	if r.Finalizer != "" {
+		if result, done, err := r.reconcileFinalizer(ctx, obj); done {
+			return result, err
+		}
+	} else if deleting {
+		// Without a finalizer, owner references clean up
+		return ctrl.Result{}, nil
+	}
+
+	original := obj.DeepCopyObject().(T)
+	result, err := r.Handler.ReconcileNormal(ctx, obj)
+	return result, r.flushStatus(ctx, original, obj, err)
+}
+
+// reconcileFinalizer adds the finalizer to a live object, and runs the handler's cleanup and
+// releases the finalizer once the object is being deleted
+func (r *Reconciler[T]) reconcileFinalizer(ctx context.Context, obj T) (ctrl.Result, bool, error) {
+	original := obj.DeepCopyObject().(T)
+	result, done, err := finalizer.ReconcileWithFinalizer(ctx, r.Client, obj, r.Finalizer, r.Handler.ReconcileDelete)
+	if done && !obj.GetDeletionTimestamp().IsZero() && controllerutil.ContainsFinalizer(obj, r.Finalizer) {
+		// Cleanup is not done; its progress is only visible in the status
+		return result, true, r.flushStatus(ctx, original, obj, err)
+	}
+	return result, done, err
+}
+
+// flushStatus patches the status if the handler changed it and combines a failed patch with
+// the handler's error
+func (r *Reconciler[T]) flushStatus(ctx context.Context, original, obj T, err error) error {
+	changed, diffErr := statusChanged(original, obj)
+	if diffErr != nil {
+		return kerrors.NewAggregate([]error{err, diffErr})
+	}
+	if !changed {
+		return err
+	}
+	if patchErr := r.Client.Status().Patch(ctx, obj, client.MergeFrom(original)); client.IgnoreNotFound(patchErr) != nil {
+		return kerrors.NewAggregate([]error{err, fmt.Errorf("failed to update status: %w", patchErr)})
+	}
+	return err
+}
+
+// statusChanged compares the status field of two objects of any type
+func statusChanged(original, obj client.Object) (bool, error) {
+	before, err := runtime.DefaultUnstructuredConverter.ToUnstructured(original)
+	if err != nil {
+		return false, err
+	}
+	after, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return false, err
+	}
+	return !reflect.DeepEqual(before["status"], after["status"]), nil
+}
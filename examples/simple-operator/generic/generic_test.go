@@ -0,0 +1,142 @@
+package generic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+const testFinalizer = "test.my.domain/finalizer"
+
+var testKey = types.NamespacedName{Name: "test", Namespace: "default"}
+
+// podHandler records calls and sets the pod phase
+type podHandler struct {
+	normal, delete int
+	phase          corev1.PodPhase
+	err            error
+	deleteResult   ctrl.Result
+}
+
+func (h *podHandler) ReconcileNormal(ctx context.Context, pod *corev1.Pod) (ctrl.Result, error) {
+	h.normal++
+	pod.Status.Phase = h.phase
+	return ctrl.Result{}, h.err
+}
+
+func (h *podHandler) ReconcileDelete(ctx context.Context, pod *corev1.Pod) (ctrl.Result, error) {
+	h.delete++
+	return h.deleteResult, nil
+}
+
+func newFixture(t *testing.T, pod *corev1.Pod) (*Reconciler[*corev1.Pod], *podHandler, client.Client, *int) {
+	statusPatches := 0
+	c := fake.NewClientBuilder().
+		WithObjects(pod).
+		WithStatusSubresource(pod).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				statusPatches++
+				return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+
+	handler := &podHandler{phase: corev1.PodRunning}
+	return &Reconciler[*corev1.Pod]{
+		Client:           c,
+		NewObject:        func() *corev1.Pod { return &corev1.Pod{} },
+		Finalizer:        testFinalizer,
+		PausedAnnotation: "test.my.domain/paused",
+		Handler:          handler,
+	}, handler, c, &statusPatches
+}
+
+func TestReconciler_Normal(t *testing.T) {
+	r, handler, c, statusPatches := newFixture(t, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}})
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: testKey})
+	require.NoError(t, err)
+	assert.Equal(t, 1, handler.normal)
+	assert.Equal(t, 1, *statusPatches)
+
+	pod := &corev1.Pod{}
+	require.NoError(t, c.Get(ctx, testKey, pod))
+	assert.Contains(t, pod.Finalizers, testFinalizer)
+	assert.Equal(t, corev1.PodRunning, pod.Status.Phase)
+
+	// An unchanged status is not written again
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: testKey})
+	require.NoError(t, err)
+	assert.Equal(t, 2, handler.normal)
+	assert.Equal(t, 1, *statusPatches)
+}
+
+func TestReconciler_FlushesStatusOnError(t *testing.T) {
+	r, handler, c, _ := newFixture(t, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}})
+	handler.phase = corev1.PodFailed
+	handler.err = errors.New("boom")
+	ctx := context.Background()
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: testKey})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+
+	pod := &corev1.Pod{}
+	require.NoError(t, c.Get(ctx, testKey, pod))
+	assert.Equal(t, corev1.PodFailed, pod.Status.Phase, "The failure is recorded together with the error")
+}
+
+func TestReconciler_Paused(t *testing.T) {
+	r, handler, _, _ := newFixture(t, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:        "test",
+		Namespace:   "default",
+		Annotations: map[string]string{"test.my.domain/paused": "true"},
+	}})
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: testKey})
+	require.NoError(t, err)
+	assert.Zero(t, handler.normal)
+}
+
+func TestReconciler_Delete(t *testing.T) {
+	now := metav1.Now()
+	r, handler, c, _ := newFixture(t, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:              "test",
+		Namespace:         "default",
+		Finalizers:        []string{testFinalizer},
+		DeletionTimestamp: &now,
+	}})
+	ctx := context.Background()
+
+	// Cleanup in progress keeps the finalizer
+	handler.deleteResult = ctrl.Result{RequeueAfter: time.Second}
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: testKey})
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, result.RequeueAfter)
+	require.NoError(t, c.Get(ctx, testKey, &corev1.Pod{}))
+
+	handler.deleteResult = ctrl.Result{}
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: testKey})
+	require.NoError(t, err)
+	assert.Equal(t, 2, handler.delete)
+	assert.Zero(t, handler.normal)
+	assert.True(t, apierrors.IsNotFound(c.Get(ctx, testKey, &corev1.Pod{})), "Removing the last finalizer deletes the object")
+
+	// Requests for deleted objects end without an error
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: testKey})
+	require.NoError(t, err)
+}
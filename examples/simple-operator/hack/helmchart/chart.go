@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// Placeholders written into marshalled objects and replaced with Helm expressions afterwards,
+// so that objects can be rewritten as data instead of string templates
+const (
+	fullnamePlaceholder          = "__FULLNAME__"
+	namespacePlaceholder         = "__NAMESPACE__"
+	labelsPlaceholder            = "__LABELS__"
+	selectorLabelsPlaceholder    = "__SELECTOR_LABELS__"
+	caInjectionPlaceholder       = "__CA_INJECTION__"
+	namespaceSelectorPlaceholder = "__NAMESPACE_SELECTOR__"
+)
+
+// Options describe the chart to generate
+type Options struct {
+	// Name is the chart name, also used as the prefix of the template helpers
+	Name string
+
+	// Description is the chart description
+	Description string
+
+	// Version and AppVersion are written to Chart.yaml
+	Version    string
+	AppVersion string
+
+	// ConfigDir is the operator's kustomize config directory, the source of truth for
+	// the raw manifests: crd/bases, rbac, manager/manager.yaml and optionally webhook
+	ConfigDir string
+
+	// WebhookFlag is the manager flag that enables the webhook server
+	WebhookFlag string
+}
+
+// Generate renders the chart files, keyed by path relative to the chart directory
+func Generate(opts Options) (map[string][]byte, error) {
+	files := map[string][]byte{}
+
+	crds, err := filepath.Glob(filepath.Join(opts.ConfigDir, "crd", "bases", "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	if len(crds) == 0 {
+		return nil, fmt.Errorf("no CRDs found in %s", filepath.Join(opts.ConfigDir, "crd", "bases"))
+	}
+	// Helm installs crds/ before the templates and never templates or upgrades them
+	for _, crd := range crds {
+		content, err := os.ReadFile(crd)
+		if err != nil {
+			return nil, err
+		}
+		files["crds/"+filepath.Base(crd)] = content
+	}
+
+	deployment, err := readManagerDeployment(filepath.Join(opts.ConfigDir, "manager", "manager.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	rbac, err := readObjects(filepath.Join(opts.ConfigDir, "rbac"))
+	if err != nil {
+		return nil, err
+	}
+	webhooks, err := readObjects(filepath.Join(opts.ConfigDir, "webhook"))
+	if err != nil {
+		return nil, err
+	}
+	hasWebhooks := len(webhooks) > 0
+	if hasWebhooks && opts.WebhookFlag == "" {
+		return nil, fmt.Errorf("%s has webhooks but no webhook flag was given", opts.ConfigDir)
+	}
+
+	for _, obj := range rbac {
+		content, err := renderObject(opts, obj, "")
+		if err != nil {
+			return nil, err
+		}
+		files[templateFileName(obj)] = content
+	}
+	for _, obj := range webhooks {
+		content, err := renderObject(opts, obj, ".Values.webhooks.enabled")
+		if err != nil {
+			return nil, err
+		}
+		files[templateFileName(obj)] = content
+	}
+
+	container := deployment.Spec.Template.Spec.Containers[0]
+	repository, tag := splitImage(container.Image)
+	resources, err := yaml.Marshal(container.Resources)
+	if err != nil {
+		return nil, err
+	}
+
+	data := struct {
+		Options
+		Repository  string
+		Tag         string
+		Replicas    int32
+		Resources   string
+		Deployment  string
+		HasWebhooks bool
+	}{
+		Options:     opts,
+		Repository:  repository,
+		Tag:         tag,
+		Replicas:    1,
+		Resources:   indentLines(strings.TrimSpace(string(resources)), 2),
+		HasWebhooks: hasWebhooks,
+	}
+	if deployment.Spec.Replicas != nil {
+		data.Replicas = *deployment.Spec.Replicas
+	}
+	if data.Deployment, err = renderDeployment(opts, deployment, hasWebhooks); err != nil {
+		return nil, err
+	}
+
+	for path, text := range chartTemplates {
+		if strings.HasPrefix(path, "templates/webhook-") && !hasWebhooks {
+			continue
+		}
+		var buf bytes.Buffer
+		// [[ ]] delimiters leave the Helm {{ }} expressions untouched
+		tmpl, err := template.New(path).Delims("[[", "]]").Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", path, err)
+		}
+		files[path] = buf.Bytes()
+	}
+
+	return files, nil
+}
+
+// readManagerDeployment reads the controller manager Deployment, skipping the Namespace
+// that kustomize creates alongside it
+func readManagerDeployment(path string) (*appsv1.Deployment, error) {
+	objects, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objects {
+		if obj.GetKind() != "Deployment" {
+			continue
+		}
+		deployment := &appsv1.Deployment{}
+		if err := runtimeConvert(obj, deployment); err != nil {
+			return nil, err
+		}
+		if len(deployment.Spec.Template.Spec.Containers) != 1 {
+			return nil, fmt.Errorf("%s: expected exactly one manager container", path)
+		}
+		return deployment, nil
+	}
+	return nil, fmt.Errorf("%s: no Deployment found", path)
+}
+
+// readObjects reads every object in the YAML files of dir, sorted by file name.
+// A missing directory has no objects.
+func readObjects(dir string) ([]*unstructured.Unstructured, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var objects []*unstructured.Unstructured
+	for _, path := range paths {
+		if filepath.Base(path) == "kustomization.yaml" {
+			continue
+		}
+		fileObjects, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, fileObjects...)
+	}
+	return objects, nil
+}
+
+func readFile(path string) ([]*unstructured.Unstructured, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*unstructured.Unstructured
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(obj.Object) > 0 {
+			objects = append(objects, obj)
+		}
+	}
+	return objects, nil
+}
+
+// renderObject rewrites an object the way the kustomize default overlay would, with the
+// release name as name prefix and the release namespace as namespace. condition, if set,
+// wraps the object in an if block.
+func renderObject(opts Options, obj *unstructured.Unstructured, condition string) ([]byte, error) {
+	obj = obj.DeepCopy()
+	prefix := func(name string) string { return fullnamePlaceholder + "-" + name }
+
+	obj.SetName(prefix(obj.GetName()))
+	if obj.GetNamespace() != "" {
+		obj.SetNamespace(namespacePlaceholder)
+	}
+	obj.SetLabels(map[string]string{labelsPlaceholder: labelsPlaceholder})
+
+	switch obj.GetKind() {
+	case "RoleBinding", "ClusterRoleBinding":
+		name, _, _ := unstructured.NestedString(obj.Object, "roleRef", "name")
+		if err := unstructured.SetNestedField(obj.Object, prefix(name), "roleRef", "name"); err != nil {
+			return nil, err
+		}
+		subjects, _, _ := unstructured.NestedSlice(obj.Object, "subjects")
+		for _, s := range subjects {
+			subject := s.(map[string]interface{})
+			if subject["kind"] == "ServiceAccount" {
+				subject["name"] = prefix(subject["name"].(string))
+				subject["namespace"] = namespacePlaceholder
+			}
+		}
+		if err := unstructured.SetNestedSlice(obj.Object, subjects, "subjects"); err != nil {
+			return nil, err
+		}
+
+	case "MutatingWebhookConfiguration", "ValidatingWebhookConfiguration":
+		obj.SetAnnotations(map[string]string{caInjectionPlaceholder: caInjectionPlaceholder})
+		webhooks, _, _ := unstructured.NestedSlice(obj.Object, "webhooks")
+		for _, w := range webhooks {
+			webhook := w.(map[string]interface{})
+			service, _, _ := unstructured.NestedMap(webhook, "clientConfig", "service")
+			service["name"] = prefix(service["name"].(string))
+			service["namespace"] = namespacePlaceholder
+			if err := unstructured.SetNestedMap(webhook, service, "clientConfig", "service"); err != nil {
+				return nil, err
+			}
+			// Webhooks without a namespaceSelector of their own get the one from values
+			if _, ok := webhook["namespaceSelector"]; !ok {
+				webhook["namespaceSelector"] = map[string]interface{}{namespaceSelectorPlaceholder: namespaceSelectorPlaceholder}
+			}
+		}
+		if err := unstructured.SetNestedSlice(obj.Object, webhooks, "webhooks"); err != nil {
+			return nil, err
+		}
+
+	case "Service":
+		selector, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector")
+		selector[selectorLabelsPlaceholder] = selectorLabelsPlaceholder
+		if err := unstructured.SetNestedStringMap(obj.Object, selector, "spec", "selector"); err != nil {
+			return nil, err
+		}
+	}
+
+	content, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	text := helmify(opts, string(content))
+	if condition != "" {
+		text = "{{- if " + condition + " }}\n" + text + "{{- end }}\n"
+	}
+	return []byte(header + text), nil
+}
+
+// renderDeployment renders the manager Deployment with the image, replicas, resources,
+// watched namespaces and webhook settings taken from values
+func renderDeployment(opts Options, deployment *appsv1.Deployment, hasWebhooks bool) (string, error) {
+	podSpec := deployment.Spec.Template.Spec
+	container := podSpec.Containers[0]
+
+	section := func(key string, value interface{}, indent int) (string, error) {
+		content, err := yaml.Marshal(map[string]interface{}{key: value})
+		if err != nil {
+			return "", err
+		}
+		return indentLines(strings.TrimSuffix(string(content), "\n"), indent), nil
+	}
+
+	var b strings.Builder
+	write := func(s string) { b.WriteString(s + "\n") }
+
+	write("        command:")
+	for _, c := range container.Command {
+		write("        - " + c)
+	}
+	write("        args:")
+	for _, a := range container.Args {
+		write("        - " + a)
+	}
+	write("        {{- with .Values.watchNamespaces }}")
+	write(`        - --watch-namespaces={{ join "," . }}`)
+	write("        {{- end }}")
+	if hasWebhooks {
+		write("        {{- if .Values.webhooks.enabled }}")
+		write("        - " + opts.WebhookFlag)
+		write("        {{- end }}")
+	}
+	write("        {{- with .Values.extraArgs }}")
+	write("        {{- toYaml . | nindent 8 }}")
+	write("        {{- end }}")
+
+	parts := map[string]interface{}{}
+	if container.SecurityContext != nil {
+		parts["securityContext"] = container.SecurityContext
+	}
+	if container.LivenessProbe != nil {
+		parts["livenessProbe"] = container.LivenessProbe
+	}
+	if container.ReadinessProbe != nil {
+		parts["readinessProbe"] = container.ReadinessProbe
+	}
+	for _, key := range []string{"securityContext", "livenessProbe", "readinessProbe"} {
+		if value, ok := parts[key]; ok {
+			s, err := section(key, value, 8)
+			if err != nil {
+				return "", err
+			}
+			write(s)
+		}
+	}
+
+	write("        resources:")
+	write("          {{- toYaml .Values.resources | nindent 10 }}")
+
+	if hasWebhooks {
+		write("        {{- if .Values.webhooks.enabled }}")
+		write("        ports:")
+		write("        - containerPort: 9443")
+		write("          name: webhook-server")
+		write("          protocol: TCP")
+		write("        volumeMounts:")
+		write("        - mountPath: /tmp/k8s-webhook-server/serving-certs")
+		write("          name: cert")
+		write("          readOnly: true")
+		write("        {{- end }}")
+	}
+
+	if podSpec.SecurityContext != nil {
+		s, err := section("securityContext", podSpec.SecurityContext, 6)
+		if err != nil {
+			return "", err
+		}
+		write(s)
+	}
+	if podSpec.TerminationGracePeriodSeconds != nil {
+		write(fmt.Sprintf("      terminationGracePeriodSeconds: %d", *podSpec.TerminationGracePeriodSeconds))
+	}
+	if hasWebhooks {
+		write("      {{- if .Values.webhooks.enabled }}")
+		write("      volumes:")
+		write("      - name: cert")
+		write("        secret:")
+		write(`          secretName: {{ include "` + opts.Name + `.webhookCertSecret" . }}`)
+		write("      {{- end }}")
+	}
+
+	return helmify(opts, strings.TrimSuffix(b.String(), "\n")), nil
+}
+
+// helmify replaces the placeholders with Helm expressions
+func helmify(opts Options, text string) string {
+	include := func(helper string) string {
+		return `{{ include "` + opts.Name + "." + helper + `" . }}`
+	}
+	text = strings.ReplaceAll(text, "    "+labelsPlaceholder+": "+labelsPlaceholder,
+		`    {{- include "`+opts.Name+`.labels" . | nindent 4 }}`)
+	text = strings.ReplaceAll(text, "    "+selectorLabelsPlaceholder+": "+selectorLabelsPlaceholder,
+		`    {{- include "`+opts.Name+`.selectorLabels" . | nindent 4 }}`)
+	// cert-manager injects the CA of the serving certificate it issues
+	text = strings.ReplaceAll(text, "    "+caInjectionPlaceholder+": "+caInjectionPlaceholder,
+		"    {{- if .Values.webhooks.certManager.enabled }}\n"+
+			"    cert-manager.io/inject-ca-from: "+namespacePlaceholder+"/"+fullnamePlaceholder+"-serving-cert\n"+
+			"    {{- end }}")
+	text = strings.ReplaceAll(text, "    "+namespaceSelectorPlaceholder+": "+namespaceSelectorPlaceholder,
+		"    {{- toYaml .Values.webhooks.namespaceSelector | nindent 4 }}")
+	text = strings.ReplaceAll(text, fullnamePlaceholder, include("fullname"))
+	text = strings.ReplaceAll(text, namespacePlaceholder, "{{ .Release.Namespace }}")
+	return text
+}
+
+// templateFileName names the template file for an object, e.g. clusterrole-manager-role.yaml
+func templateFileName(obj *unstructured.Unstructured) string {
+	return "templates/" + strings.ToLower(obj.GetKind()) + "-" + obj.GetName() + ".yaml"
+}
+
+// splitImage splits an image reference into repository and tag
+func splitImage(image string) (string, string) {
+	slash := strings.LastIndex(image, "/")
+	if colon := strings.LastIndex(image, ":"); colon > slash {
+		return image[:colon], image[colon+1:]
+	}
+	return image, ""
+}
+
+func indentLines(s string, n int) string {
+	pad := strings.Repeat(" ", n)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func runtimeConvert(obj *unstructured.Unstructured, into interface{}) error {
+	content, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return err
+	}
+	return yaml.UnmarshalStrict(content, into)
+}
+
+// WriteChart writes files to dir, removing stale files from earlier runs
+func WriteChart(dir string, files map[string][]byte) error {
+	for _, sub := range []string{"crds", "templates"} {
+		if err := os.RemoveAll(filepath.Join(dir, sub)); err != nil {
+			return err
+		}
+	}
+	for path, content := range files {
+		target := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, content, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// charts are the checked-in charts and the options they are generated with
+var charts = map[string]Options{
+	"../../chart": {
+		Name:        "cocktail-operator",
+		Description: "A Helm chart for cocktail-operator",
+		Version:     "0.1.0",
+		AppVersion:  "0.1.0",
+		ConfigDir:   "../../config",
+	},
+	"../../../database-operator/chart": {
+		Name:        "database-operator",
+		Description: "A Helm chart for database-operator",
+		Version:     "0.1.0",
+		AppVersion:  "0.1.0",
+		ConfigDir:   "../../../database-operator/config",
+		WebhookFlag: "--enable-webhooks",
+	},
+}
+
+// TestChartsUpToDate fails when config/ changed without regenerating the chart
+func TestChartsUpToDate(t *testing.T) {
+	for dir, opts := range charts {
+		t.Run(opts.Name, func(t *testing.T) {
+			files, err := Generate(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for path, want := range files {
+				got, err := os.ReadFile(filepath.Join(dir, path))
+				if err != nil {
+					t.Errorf("%s: %v; run make helm-chart", path, err)
+					continue
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s is out of date; run make helm-chart", path)
+				}
+			}
+
+			for _, sub := range []string{"crds", "templates"} {
+				existing, err := filepath.Glob(filepath.Join(dir, sub, "*"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, path := range existing {
+					rel, _ := filepath.Rel(dir, path)
+					if _, ok := files[filepath.ToSlash(rel)]; !ok {
+						t.Errorf("%s is not generated from config/; run make helm-chart", rel)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSplitImage(t *testing.T) {
+	tests := map[string][2]string{
+		"controller:latest":                 {"controller", "latest"},
+		"registry.example.com:5000/op:v1.0": {"registry.example.com:5000/op", "v1.0"},
+		"registry.example.com:5000/op":      {"registry.example.com:5000/op", ""},
+	}
+	for image, want := range tests {
+		repository, tag := splitImage(image)
+		if repository != want[0] || tag != want[1] {
+			t.Errorf("splitImage(%q) = %q, %q; want %q, %q", image, repository, tag, want[0], want[1])
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Command helmchart generates a Helm chart for an example operator from its kustomize
+// config directory, so that the chart and the raw manifests share one source of truth:
+// CRDs and RBAC generated by controller-gen and the manager Deployment in config/manager.
+//
+// From examples/simple-operator:
+//
+//	go run ./hack/helmchart --name cocktail-operator --config-dir config --output chart
+//	go run ./hack/helmchart --name database-operator --config-dir ../database-operator/config \
+//		--output ../database-operator/chart --webhook-flag=--enable-webhooks
+//
+// The chart's tests fail when a checked-in chart is out of date with its config.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	var opts Options
+	var output string
+	flag.StringVar(&opts.Name, "name", "", "Chart name.")
+	flag.StringVar(&opts.Description, "description", "", "Chart description. Defaults to \"A Helm chart for <name>\".")
+	flag.StringVar(&opts.Version, "version", "0.1.0", "Chart version.")
+	flag.StringVar(&opts.AppVersion, "app-version", "0.1.0", "Operator version the chart deploys.")
+	flag.StringVar(&opts.ConfigDir, "config-dir", "config", "The operator's kustomize config directory.")
+	flag.StringVar(&opts.WebhookFlag, "webhook-flag", "", "Manager flag that enables the webhook server, if the operator has webhooks.")
+	flag.StringVar(&output, "output", "chart", "Directory the chart is written to.")
+	flag.Parse()
+
+	if opts.Name == "" {
+		fmt.Fprintln(os.Stderr, "--name is required")
+		os.Exit(2)
+	}
+	if opts.Description == "" {
+		opts.Description = "A Helm chart for " + opts.Name
+	}
+
+	files, err := Generate(opts)
+	if err == nil {
+		err = WriteChart(output, files)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
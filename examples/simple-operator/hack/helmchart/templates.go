@@ -0,0 +1,170 @@
+package main
+
+// header marks every generated file; edit config/ and regenerate instead
+const header = "# Code generated by hack/helmchart from config/. DO NOT EDIT.\n"
+
+// chartTemplates are the chart files that do not come from an object in config/.
+// They are rendered with [[ ]] delimiters; {{ }} is left for Helm.
+var chartTemplates = map[string]string{
+	"Chart.yaml": header + `apiVersion: v2
+name: [[ .Name ]]
+description: [[ .Description ]]
+type: application
+version: [[ .Version ]]
+appVersion: "[[ .AppVersion ]]"
+`,
+
+	"values.yaml": header + `nameOverride: ""
+fullnameOverride: ""
+
+replicaCount: [[ .Replicas ]]
+
+image:
+  repository: [[ .Repository ]]
+  # Defaults to the chart appVersion
+  tag: "[[ .Tag ]]"
+  pullPolicy: IfNotPresent
+
+# Namespaces the operator watches; empty watches all namespaces
+watchNamespaces: []
+
+# Additional manager flags, e.g. ["--kube-api-qps=100"]
+extraArgs: []
+
+resources:
+[[ .Resources ]]
+
+nodeSelector: {}
+tolerations: []
+affinity: {}
+[[- if .HasWebhooks ]]
+
+webhooks:
+  enabled: false
+  certManager:
+    # Issue the serving certificate with cert-manager and inject its CA into the webhook configuration
+    enabled: true
+  # Secret with tls.crt and tls.key to use when cert-manager is disabled; the CA must be
+  # set on the webhook configuration separately
+  certSecretName: ""
+  # Namespaces whose requests are sent to webhooks without a namespaceSelector of their own.
+  # kube-system is left out, so a slow or unavailable operator cannot block the control plane.
+  namespaceSelector:
+    matchExpressions:
+    - key: kubernetes.io/metadata.name
+      operator: NotIn
+      values:
+      - kube-system
+[[- end ]]
+`,
+
+	"templates/_helpers.tpl": `{{/* Code generated by hack/helmchart from config/. DO NOT EDIT. */}}
+
+{{- define "[[ .Name ]].name" -}}
+{{- default .Chart.Name .Values.nameOverride | trunc 63 | trimSuffix "-" }}
+{{- end }}
+
+{{/*
+Release-qualified name; every object name is prefixed with it, like namePrefix in config/default
+*/}}
+{{- define "[[ .Name ]].fullname" -}}
+{{- if .Values.fullnameOverride }}
+{{- .Values.fullnameOverride | trunc 63 | trimSuffix "-" }}
+{{- else }}
+{{- $name := default .Chart.Name .Values.nameOverride }}
+{{- if contains $name .Release.Name }}
+{{- .Release.Name | trunc 63 | trimSuffix "-" }}
+{{- else }}
+{{- printf "%s-%s" .Release.Name $name | trunc 63 | trimSuffix "-" }}
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{- define "[[ .Name ]].labels" -}}
+helm.sh/chart: {{ printf "%s-%s" .Chart.Name .Chart.Version | replace "+" "_" | trunc 63 | trimSuffix "-" }}
+{{ include "[[ .Name ]].selectorLabels" . }}
+app.kubernetes.io/version: {{ .Values.image.tag | default .Chart.AppVersion | quote }}
+app.kubernetes.io/managed-by: {{ .Release.Service }}
+{{- end }}
+
+{{- define "[[ .Name ]].selectorLabels" -}}
+app.kubernetes.io/name: {{ include "[[ .Name ]].name" . }}
+app.kubernetes.io/instance: {{ .Release.Name }}
+{{- end }}
+[[- if .HasWebhooks ]]
+
+{{- define "[[ .Name ]].webhookCertSecret" -}}
+{{- .Values.webhooks.certSecretName | default (printf "%s-webhook-server-cert" (include "[[ .Name ]].fullname" .)) }}
+{{- end }}
+[[- end ]]
+`,
+
+	"templates/deployment.yaml": header + `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ include "[[ .Name ]].fullname" . }}-controller-manager
+  namespace: {{ .Release.Namespace }}
+  labels:
+    {{- include "[[ .Name ]].labels" . | nindent 4 }}
+    control-plane: controller-manager
+spec:
+  replicas: {{ .Values.replicaCount }}
+  selector:
+    matchLabels:
+      {{- include "[[ .Name ]].selectorLabels" . | nindent 6 }}
+      control-plane: controller-manager
+  template:
+    metadata:
+      labels:
+        {{- include "[[ .Name ]].selectorLabels" . | nindent 8 }}
+        control-plane: controller-manager
+    spec:
+      serviceAccountName: {{ include "[[ .Name ]].fullname" . }}-controller-manager
+      containers:
+      - name: manager
+        image: "{{ .Values.image.repository }}:{{ .Values.image.tag | default .Chart.AppVersion }}"
+        imagePullPolicy: {{ .Values.image.pullPolicy }}
+[[ .Deployment ]]
+      {{- with .Values.nodeSelector }}
+      nodeSelector:
+        {{- toYaml . | nindent 8 }}
+      {{- end }}
+      {{- with .Values.tolerations }}
+      tolerations:
+        {{- toYaml . | nindent 8 }}
+      {{- end }}
+      {{- with .Values.affinity }}
+      affinity:
+        {{- toYaml . | nindent 8 }}
+      {{- end }}
+`,
+
+	"templates/webhook-certificate.yaml": header + `{{- if and .Values.webhooks.enabled .Values.webhooks.certManager.enabled }}
+apiVersion: cert-manager.io/v1
+kind: Issuer
+metadata:
+  name: {{ include "[[ .Name ]].fullname" . }}-selfsigned-issuer
+  namespace: {{ .Release.Namespace }}
+  labels:
+    {{- include "[[ .Name ]].labels" . | nindent 4 }}
+spec:
+  selfSigned: {}
+---
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: {{ include "[[ .Name ]].fullname" . }}-serving-cert
+  namespace: {{ .Release.Namespace }}
+  labels:
+    {{- include "[[ .Name ]].labels" . | nindent 4 }}
+spec:
+  dnsNames:
+  - {{ include "[[ .Name ]].fullname" . }}-webhook-service.{{ .Release.Namespace }}.svc
+  - {{ include "[[ .Name ]].fullname" . }}-webhook-service.{{ .Release.Namespace }}.svc.cluster.local
+  issuerRef:
+    kind: Issuer
+    name: {{ include "[[ .Name ]].fullname" . }}-selfsigned-issuer
+  secretName: {{ include "[[ .Name ]].webhookCertSecret" . }}
+{{- end }}
+`,
+}
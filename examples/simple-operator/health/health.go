@@ -0,0 +1,88 @@
+// Package health computes the kstatus health of a resource: Current, InProgress, Failed or
+// Terminating, from the deletion timestamp, the observed generation and the Reconciling,
+// Stalled and Ready conditions. barv1.ComputeHealth applies it to Cocktails.
+package health
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Status is the health of a resource, with the values kstatus uses
+type Status string
+
+const (
+	// InProgress means the resource is not yet in its desired state, and is expected to get there
+	InProgress Status = "InProgress"
+
+	// Failed means the resource will not get to its desired state without a change
+	Failed Status = "Failed"
+
+	// Current means the resource is in its desired state
+	Current Status = "Current"
+
+	// Terminating means the resource is being deleted
+	Terminating Status = "Terminating"
+)
+
+// Condition types with a meaning in kstatus
+const (
+	ConditionReady       = "Ready"
+	ConditionReconciling = "Reconciling"
+	ConditionStalled     = "Stalled"
+)
+
+// Result is the health of a resource and why
+type Result struct {
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Input is what Compute needs of a resource
+type Input struct {
+	Object metav1.Object
+
+	// ObservedGeneration is the generation the status describes; nil for types that do not
+	// record it
+	ObservedGeneration *int64
+
+	Conditions []metav1.Condition
+}
+
+// Compute returns the health of a resource
+func Compute(in Input) Result {
+	if in.Object.GetDeletionTimestamp() != nil {
+		return Result{Status: Terminating, Message: "Resource scheduled for deletion"}
+	}
+
+	if in.ObservedGeneration != nil && *in.ObservedGeneration < in.Object.GetGeneration() {
+		return Result{Status: InProgress, Message: fmt.Sprintf("Generation is %d, but latest observed generation is %d",
+			in.Object.GetGeneration(), *in.ObservedGeneration)}
+	}
+
+	if condition := meta.FindStatusCondition(in.Conditions, ConditionReconciling); condition != nil &&
+		condition.Status == metav1.ConditionTrue {
+		return Result{Status: InProgress, Message: describe(condition)}
+	}
+	if condition := meta.FindStatusCondition(in.Conditions, ConditionStalled); condition != nil &&
+		condition.Status == metav1.ConditionTrue {
+		return Result{Status: Failed, Message: describe(condition)}
+	}
+	if condition := meta.FindStatusCondition(in.Conditions, ConditionReady); condition != nil {
+		if condition.Status != metav1.ConditionTrue {
+			return Result{Status: InProgress, Message: describe(condition)}
+		}
+		return Result{Status: Current, Message: describe(condition)}
+	}
+	return Result{Status: Current, Message: "Resource is current"}
+}
+
+// describe returns the message of a condition, or its reason if it has none
+func describe(condition *metav1.Condition) string {
+	if condition.Message != "" {
+		return condition.Message
+	}
+	return fmt.Sprintf("%s: %s", condition.Type, condition.Reason)
+}
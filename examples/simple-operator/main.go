@@ -19,21 +19,27 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, AWS, GCP, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
-	k8sv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	barv1 "your.domain/project/api/v1"
+	"your.domain/project/apiclient"
 	"your.domain/project/controllers"
 	//+kubebuilder:scaffold:imports
 )
@@ -54,12 +60,34 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var requeueInterval time.Duration
+	var requeueJitter float64
+	var kubeAPIQPS float64
+	var kubeAPIBurst int
+	var kubeAPIRetries int
+	var watchNamespaces string
+	var prometheusAddress string
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&requeueInterval, "requeue-interval", 5*time.Minute,
+		"How often a prepared Cocktail is re-checked when no events arrive.")
+	flag.Float64Var(&requeueJitter, "requeue-jitter", 0.1,
+		"Maximum fraction of the requeue interval added as random jitter.")
+	// controller-runtime defaults to 20 QPS / 30 burst, which throttles once there are many Cocktails
+	flag.Float64Var(&kubeAPIQPS, "kube-api-qps", 50,
+		"Sustained queries per second to the Kubernetes API server.")
+	flag.IntVar(&kubeAPIBurst, "kube-api-burst", 100,
+		"Burst of queries allowed above --kube-api-qps.")
+	flag.IntVar(&kubeAPIRetries, "kube-api-retries", 0,
+		"How often a request failing with a transient error (throttling, refused connections, read timeouts) is retried. Zero disables retries.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma-separated namespaces whose Cocktails are reconciled. Empty watches all namespaces.")
+	flag.StringVar(&prometheusAddress, "prometheus-address", controllers.DefaultPrometheusAddress,
+		"Prometheus scraping the operator, queried by the KEDA ScaledObjects of Cocktails for their order backlog.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -68,10 +96,29 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	restConfig := ctrl.GetConfigOrDie()
+	restConfig.QPS = float32(kubeAPIQPS)
+	restConfig.Burst = kubeAPIBurst
+
+	var cacheOptions cache.Options
+	if watchNamespaces != "" {
+		cacheOptions.DefaultNamespaces = map[string]cache.Config{}
+		for _, namespace := range strings.Split(watchNamespaces, ",") {
+			cacheOptions.DefaultNamespaces[strings.TrimSpace(namespace)] = cache.Config{}
+		}
+	}
+
+	// Every request made through mgr.GetClient() is measured, tagged and retried
+	clientOptions := apiclient.Options{
+		Retry: wait.Backoff{Duration: 200 * time.Millisecond, Factor: 2, Jitter: 0.1, Steps: kubeAPIRetries, Cap: 5 * time.Second},
+	}
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
+		Cache:                  cacheOptions,
+		NewClient:              apiclient.NewClientFunc(clientOptions),
+		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
+		WebhookServer:          webhook.NewServer(webhook.Options{Port: 9443}),
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "cocktail.my.domain",
@@ -93,8 +140,11 @@ func main() {
 	}
 
 	if err = (&controllers.CocktailReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:            mgr.GetClient(),
+		Scheme:            mgr.GetScheme(),
+		RequeueInterval:   requeueInterval,
+		RequeueJitter:     requeueJitter,
+		PrometheusAddress: prometheusAddress,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Cocktail")
 		os.Exit(1)
@@ -111,11 +161,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := mgr.AddMetricsExtraHandler("/metrics", mgr.GetMetrics()); err != nil {
-		setupLog.Error(err, "unable to set up metrics server")
-		os.Exit(1)
-	}
-
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
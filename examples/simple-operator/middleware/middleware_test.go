@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const testFinalizer = "test.my.domain/finalizer"
+
+func newConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{}
+}
+
+var testRequest = reconcile.Request{NamespacedName: types.NamespacedName{Name: "test", Namespace: "default"}}
+
+func TestChain_Order(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next reconcile.Reconciler) reconcile.Reconciler {
+			return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+				calls = append(calls, name)
+				return next.Reconcile(ctx, req)
+			})
+		}
+	}
+	core := reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		calls = append(calls, "core")
+		return reconcile.Result{}, nil
+	})
+
+	_, err := Chain(core, trace("outer"), trace("inner")).Reconcile(context.Background(), testRequest)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"outer", "inner", "core"}, calls)
+}
+
+func TestRecover(t *testing.T) {
+	core := reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		panic("boom")
+	})
+
+	_, err := Chain(core, Recover()).Reconcile(context.Background(), testRequest)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+}
+
+func TestTimeout(t *testing.T) {
+	core := reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		<-ctx.Done()
+		return reconcile.Result{}, ctx.Err()
+	})
+
+	_, err := Chain(core, Timeout(10*time.Millisecond)).Reconcile(context.Background(), testRequest)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestChain_FetchesOnce(t *testing.T) {
+	gets := 0
+	c := fake.NewClientBuilder().
+		WithObjects(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				gets++
+				return c.Get(ctx, key, obj, opts...)
+			},
+		}).
+		Build()
+
+	reconciled := false
+	core := Object(c, newConfigMap, func(ctx context.Context, cm *corev1.ConfigMap) (reconcile.Result, error) {
+		reconciled = true
+		assert.Contains(t, cm.Finalizers, testFinalizer, "The core sees the object updated by Finalizer")
+		return reconcile.Result{}, nil
+	})
+	cleanup := func(ctx context.Context, cm *corev1.ConfigMap) error { return nil }
+
+	_, err := Chain(core,
+		Paused(c, newConfigMap, "test.my.domain/paused"),
+		Finalizer(c, newConfigMap, testFinalizer, cleanup),
+	).Reconcile(context.Background(), testRequest)
+	require.NoError(t, err)
+	assert.True(t, reconciled)
+	assert.Equal(t, 1, gets)
+}
+
+func TestPaused(t *testing.T) {
+	c := fake.NewClientBuilder().
+		WithObjects(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Namespace:   "default",
+			Annotations: map[string]string{"test.my.domain/paused": "true"},
+		}}).
+		Build()
+
+	core := Object(c, newConfigMap, func(ctx context.Context, cm *corev1.ConfigMap) (reconcile.Result, error) {
+		t.Fatal("A paused object must not be reconciled")
+		return reconcile.Result{}, nil
+	})
+
+	_, err := Chain(core, Paused(c, newConfigMap, "test.my.domain/paused")).Reconcile(context.Background(), testRequest)
+	assert.NoError(t, err)
+}
+
+func TestFinalizer_Deletion(t *testing.T) {
+	now := metav1.Now()
+	c := fake.NewClientBuilder().
+		WithObjects(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:              "test",
+			Namespace:         "default",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{testFinalizer},
+		}}).
+		Build()
+
+	core := Object(c, newConfigMap, func(ctx context.Context, cm *corev1.ConfigMap) (reconcile.Result, error) {
+		t.Fatal("A deleted object must not be reconciled")
+		return reconcile.Result{}, nil
+	})
+
+	failing := func(ctx context.Context, cm *corev1.ConfigMap) error { return errors.New("still in use") }
+	_, err := Chain(core, Finalizer(c, newConfigMap, testFinalizer, failing)).Reconcile(context.Background(), testRequest)
+	require.Error(t, err)
+	require.NoError(t, c.Get(context.Background(), testRequest.NamespacedName, &corev1.ConfigMap{}),
+		"The finalizer is kept while cleanup fails")
+
+	cleaned := false
+	cleanup := func(ctx context.Context, cm *corev1.ConfigMap) error { cleaned = true; return nil }
+	_, err = Chain(core, Finalizer(c, newConfigMap, testFinalizer, cleanup)).Reconcile(context.Background(), testRequest)
+	require.NoError(t, err)
+	assert.True(t, cleaned)
+
+	// The fake client deletes the object once its last finalizer is removed
+	err = c.Get(context.Background(), testRequest.NamespacedName, &corev1.ConfigMap{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
@@ -5,125 +5,40 @@ package patterns
 // This file demonstrates advanced patterns for building production-ready Kubernetes operators.
 // These patterns go beyond basic reconciliation to handle real-world scenarios.
 //
-// NOTE: This file uses placeholder types for demonstration purposes.
-// When using these patterns in your code, replace:
+// NOTE: This file uses the MyResource types of crd.go and the MyResourceReconciler of
+// reconciler.go. When using these patterns in your code, replace:
 // - MyResource -> Your custom resource type
 // - MyResourceList -> Your custom resource list type
 // - Adjust field names and types as needed
-//
-// ==============================================================================
-// PLACEHOLDER TYPES - Replace with your actual CRD types
-// ==============================================================================
-
-// MyResource represents a placeholder custom resource
-type MyResource struct {
-	metav1.TypeMeta
-	metav1.ObjectMeta
-	Spec   MyResourceSpec
-	Status MyResourceStatus
-}
-
-// MyResourceList is a list of MyResources
-type MyResourceList struct {
-	metav1.TypeMeta
-	metav1.ListMeta
-	Items []MyResource
-}
-
-// MyResourceSpec defines the desired state
-type MyResourceSpec struct {
-	Replicas        int32
-	ConfigMapName   string
-	SecretName      string
-	Image           string
-}
-
-// MyResourceStatus defines the observed state
-type MyResourceStatus struct {
-	Phase             string
-	ReadyReplicas     int32
-	ObservedGeneration int64
-	Conditions        []metav1.Condition
-}
-
-// SetCondition sets a condition
-func (m *MyResource) SetCondition(conditionType string, status metav1.ConditionStatus, reason, message string) {
-	newCondition := metav1.Condition{
-		Type:               conditionType,
-		Status:             status,
-		Reason:             reason,
-		Message:            message,
-		LastTransitionTime: metav1.Now(),
-	}
-
-	found := false
-	for i, condition := range m.Status.Conditions {
-		if condition.Type == conditionType {
-			if condition.Status != newCondition.Status {
-				condition.LastTransitionTime = newCondition.LastTransitionTime
-			}
-			m.Status.Conditions[i] = newCondition
-			found = true
-			break
-		}
-	}
-
-	if !found {
-		m.Status.Conditions = append(m.Status.Conditions, newCondition)
-	}
-}
-
-// IsReady returns true if ready
-func (m *MyResource) IsReady() bool {
-	for _, condition := range m.Status.Conditions {
-		if condition.Type == "Ready" && condition.Status == metav1.ConditionTrue {
-			return true
-		}
-	}
-	return false
-}
-
-// ==============================================================================
-// END PLACEHOLDER TYPES
-// ==============================================================================
 
 import (
 	"context"
 	"fmt"
 	"time"
 
+	"golang.org/x/time/rate"
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/wait"
-	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
-	"sigs.k8s.io/controller-runtime/pkg/source"
-	"sigs.k8s.io/controller-runtime/pkg/builder"
-)
 
-// MyResourceReconciler reconciles a MyResource object
-type MyResourceReconciler struct {
-	client.Client
-	Scheme   *runtime.Scheme
-	Recorder record.EventRecorder
-}
+	// patterns/pagination, copied into your project
+	"my.domain/myproject/pkg/pagination"
+)
 
 // ==============================================================================
 // PATTERN 1: Controller Options and Leader Election
@@ -147,7 +62,7 @@ func (r *MyResourceReconciler) SetupWithManagerWithOptions(mgr ctrl.Manager) err
 		// OPTIONS 3: Filter by Annotation
 		WithEventFilter(predicate.NewPredicateFuncs(func(obj client.Object) bool {
 			// Skip reconciliation if paused annotation is set
-			return !obj.GetAnnotations()["my.domain/paused"] == "true"
+			return obj.GetAnnotations()["my.domain/paused"] != "true"
 		})).
 		Complete(r)
 }
@@ -165,44 +80,42 @@ func (r *MyResourceReconciler) SetupWithManagerWatches(mgr ctrl.Manager) error {
 		// Owns(&appsv1.Deployment{}).
 
 		// WATCH 2: Watch ConfigMaps referenced in spec
-		// Uses EnqueueRequestsFromMapFunc to find affected MyResources
+		// Uses EnqueueRequestsFromMapFunc to find affected MyResources. Since
+		// controller-runtime v0.15 Watches takes the object; &source.Kind{Type: ...} is gone.
+		// Map functions written without a context can be wrapped in compat.MapFunc.
 		Watches(
-			&source.Kind{Type: &v1.ConfigMap{}},
+			&v1.ConfigMap{},
 			handler.EnqueueRequestsFromMapFunc(r.findObjectsForConfigMap),
 			// Optional: Add predicate to filter events
 			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
 		).
 		// WATCH 3: Watch Secrets referenced in spec
 		Watches(
-			&source.Kind{Type: &v1.Secret{}},
+			&v1.Secret{},
 			handler.EnqueueRequestsFromMapFunc(r.findObjectsForSecret),
 		).
 		Complete(r)
 }
 
-// findObjectsForConfigMap finds MyResources that reference a ConfigMap
+// findObjectsForConfigMap finds MyResources that reference a ConfigMap.
+//
+// Listing every MyResource of the namespace to keep the few that match holds the whole fleet
+// in memory on every ConfigMap event. pagination.Requests pages through the API server
+// instead and keeps only the matches. Use it when MyResources are too many to cache; when
+// they are cached, a field index on spec.configMapName with client.UnsafeDisableDeepCopy
+// finds the matches without copying anything.
 func (r *MyResourceReconciler) findObjectsForConfigMap(ctx context.Context, o client.Object) []reconcile.Request {
 	configMap := o.(*v1.ConfigMap)
 	log := log.FromContext(ctx)
 
-	// List all MyResources in the same namespace
-	var list MyResourceList
-	if err := r.List(ctx, &list, client.InNamespace(configMap.Namespace)); err != nil {
+	// Page through the MyResources in the same namespace, through the APIReader: the cache has
+	// no pages. client.Limit sets the page size.
+	requests, err := pagination.Requests(ctx, r.APIReader, &MyResourceList{},
+		func(item *MyResource) bool { return item.Spec.ConfigMapName == configMap.Name },
+		client.InNamespace(configMap.Namespace), client.Limit(100))
+	if err != nil {
+		// The requests of the pages listed so far are still worth enqueueing
 		log.Error(err, "Failed to list MyResources")
-		return nil
-	}
-
-	// Find MyResources that reference this ConfigMap
-	var requests []reconcile.Request
-	for _, item := range list.Items {
-		if item.Spec.ConfigMapName == configMap.Name {
-			requests = append(requests, reconcile.Request{
-				NamespacedName: types.NamespacedName{
-					Name:      item.Name,
-					Namespace: item.Namespace,
-				},
-			})
-		}
 	}
 
 	return requests
@@ -213,22 +126,11 @@ func (r *MyResourceReconciler) findObjectsForSecret(ctx context.Context, o clien
 	secret := o.(*v1.Secret)
 	log := log.FromContext(ctx)
 
-	var list MyResourceList
-	if err := r.List(ctx, &list, client.InNamespace(secret.Namespace)); err != nil {
+	requests, err := pagination.Requests(ctx, r.APIReader, &MyResourceList{},
+		func(item *MyResource) bool { return item.Spec.SecretName == secret.Name },
+		client.InNamespace(secret.Namespace))
+	if err != nil {
 		log.Error(err, "failed to list MyResources")
-		return nil
-	}
-
-	var requests []reconcile.Request
-	for _, item := range list.Items {
-		if item.Spec.SecretName == secret.Name {
-			requests = append(requests, reconcile.Request{
-				NamespacedName: types.NamespacedName{
-					Name:      item.Name,
-					Namespace: item.Namespace,
-				},
-			})
-		}
 	}
 
 	return requests
@@ -250,14 +152,14 @@ func (r *MyResourceReconciler) ReconcileWithSkip(ctx context.Context, req ctrl.R
 	// SKIP 1: Check for paused annotation
 	if instance.Annotations["my.domain/paused"] == "true" {
 		log.Info("Reconciliation paused via annotation")
-		r.Recorder.Event(instance, v1.EventTypeNormal, "Paused", "Reconciliation is paused")
+		r.Recorder.Eventf(instance, nil, v1.EventTypeNormal, "Paused", "Reconcile", "Reconciliation is paused")
 		return ctrl.Result{}, nil
 	}
 
 	// SKIP 2: Check if already in desired state
 	if instance.Status.ObservedGeneration == instance.Generation && instance.IsReady() {
 		log.Info("Resource is up-to-date and ready, skipping reconciliation")
-		return ctrl.Result{RequeueAfter: time.Minute * 5}, nil // Recheck in 5 minutes
+		return ctrl.Result{RequeueAfter: r.RequeuePolicy.RequeueAfter(instance)}, nil // Recheck later
 	}
 
 	// SKIP 3: Check if deletion timestamp is set
@@ -310,7 +212,7 @@ func (r *MyResourceReconciler) ReconcileWithRetry(ctx context.Context, req ctrl.
 		// Check if we should give up
 		if retryCount > 10 {
 			log.Error(err, "max retries exceeded, giving up")
-			r.Recorder.Event(instance, v1.EventTypeWarning, "ReconciliationFailed", "Max retries exceeded")
+			r.Recorder.Eventf(instance, nil, v1.EventTypeWarning, "ReconciliationFailed", "Reconcile", "Max retries exceeded")
 			return ctrl.Result{}, err
 		}
 
@@ -324,7 +226,7 @@ func (r *MyResourceReconciler) ReconcileWithRetry(ctx context.Context, req ctrl.
 		_ = r.Update(ctx, instance)
 	}
 
-	return ctrl.Result{RequeueAfter: time.Minute * 5}, nil
+	return ctrl.Result{RequeueAfter: r.RequeuePolicy.RequeueAfter(instance)}, nil
 }
 
 // ==============================================================================
@@ -383,10 +285,13 @@ func (r *MyResourceReconciler) ReconcileWithConflictHandling(ctx context.Context
 }
 
 // UpdateWithRetry retries updates on conflict
+// IMPORTANT: A conflict means the cache is behind the API server. Re-reading through r.Get
+// would often return the very version that just conflicted and burn every retry on it,
+// so the latest version is read through the APIReader instead.
 func (r *MyResourceReconciler) UpdateWithRetry(ctx context.Context, obj client.Object, mutate func() error) error {
-	return utilretry.RetryOnConflict(utilretry.DefaultRetry, func() error {
-		// Get latest version
-		if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Get latest version from the API server, not the cache
+		if err := r.GetFresh(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
 			return err
 		}
 
@@ -400,6 +305,44 @@ func (r *MyResourceReconciler) UpdateWithRetry(ctx context.Context, obj client.O
 	})
 }
 
+// ==============================================================================
+// PATTERN 6b: Cache Bypass Reads
+// ==============================================================================
+//
+// r.Get and r.List read from the informer cache, which lags the API server by the watch
+// latency. That is fine for almost everything a reconciler does: a stale read leads to a
+// conflict or a later reconcile that corrects it. A few decisions are not correctable
+// afterwards and must be made on fresh data:
+//
+// - Retrying after a conflict (see UpdateWithRetry)
+// - Removing a finalizer: a stale copy may miss a finalizer or annotation added since
+// - Acting on a backup, restore or other one-shot external operation
+// - Checking that something does NOT exist before creating it elsewhere
+//
+// Fresh reads cost an API server round trip each, so never use them on the hot path.
+// Wire the reader in main.go:
+//
+//	&MyResourceReconciler{Client: mgr.GetClient(), APIReader: mgr.GetAPIReader()}
+
+// GetFresh reads obj from the API server, bypassing the cache.
+// Use only where acting on stale data cannot be corrected by a later reconcile.
+func (r *MyResourceReconciler) GetFresh(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	return r.APIReader.Get(ctx, key, obj)
+}
+
+// RemoveFinalizerFresh re-reads the object before removing the finalizer,
+// so cleanup decisions are not made on a stale copy from the cache
+func (r *MyResourceReconciler) RemoveFinalizerFresh(ctx context.Context, instance *MyResource, finalizerName string) error {
+	if err := r.GetFresh(ctx, client.ObjectKeyFromObject(instance), instance); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if !controllerutil.RemoveFinalizer(instance, finalizerName) {
+		return nil
+	}
+	return r.Update(ctx, instance)
+}
+
 // ==============================================================================
 // PATTERN 7: Rate Limiting and Work Queue
 // ==============================================================================
@@ -408,11 +351,12 @@ func (r *MyResourceReconciler) UpdateWithRetry(ctx context.Context, obj client.O
 func (r *MyResourceReconciler) SetupWithRateLimiter(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&MyResource{}).
-		// Custom rate limiter: exponential with max 1000s base
-		WithControllerControllerOptions(controller.Options{
-			RateLimiter: controller.NewControllerRateLimiter(
-				// Items: exponentially increase from 5ms to 1000s
-				workqueue.NewExponentialFastSlowRateLimiter(5*time.Millisecond, 1000*time.Second),
+		// Custom rate limiter: the slower of a per-item exponential backoff from 5ms to
+		// 1000s and an overall token bucket of 10 qps with a burst of 100
+		WithOptions(controller.Options{
+			RateLimiter: workqueue.NewMaxOfRateLimiter(
+				workqueue.NewItemExponentialFailureRateLimiter(5*time.Millisecond, 1000*time.Second),
+				&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
 			),
 		}).
 		Complete(r)
@@ -431,18 +375,19 @@ func (r *MyResourceReconciler) ReconcileWithEvents(ctx context.Context, req ctrl
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	// Record normal event
-	r.Recorder.Event(instance, v1.EventTypeNormal, "Reconciling", "Starting reconciliation")
+	// Record normal event. events/v1 recorders group repeats of the same event into a
+	// series, so a constant note aggregates instead of creating an Event per reconcile.
+	r.Recorder.Eventf(instance, nil, v1.EventTypeNormal, "Reconciling", "Reconcile", "Starting reconciliation")
 
 	// Perform reconciliation
 	if err := r.reconcileLogic(ctx, instance); err != nil {
 		// Record warning event
-		r.Recorder.Event(instance, v1.EventTypeWarning, "ReconciliationFailed", err.Error())
+		r.Recorder.Eventf(instance, nil, v1.EventTypeWarning, "ReconciliationFailed", "Reconcile", "%s", err.Error())
 		return ctrl.Result{}, err
 	}
 
 	// Record success event
-	r.Recorder.Event(instance, v1.EventTypeNormal, "ReconciliationSucceeded", "Resource reconciled successfully")
+	r.Recorder.Eventf(instance, nil, v1.EventTypeNormal, "ReconciliationSucceeded", "Reconcile", "Resource reconciled successfully")
 	log.Info("reconciled successfully", "name", instance.Name)
 
 	return ctrl.Result{}, nil
@@ -462,8 +407,12 @@ func (r *MyResourceReconciler) AggregateStatus(ctx context.Context, instance *My
 
 	// Get service status
 	service := &v1.Service{}
-	if err := r.Get(ctx, types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, service); err != nil && !errors.IsNotFound(err) {
-		return err
+	serviceFound := true
+	if err := r.Get(ctx, types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, service); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		serviceFound = false
 	}
 
 	// Aggregate conditions
@@ -476,10 +425,10 @@ func (r *MyResourceReconciler) AggregateStatus(ctx context.Context, instance *My
 		reasons = append(reasons, fmt.Sprintf("Deployment not ready: %d/%d replicas", deployment.Status.ReadyReplicas, *deployment.Spec.Replicas))
 	}
 
-	// Check service
-	if service != nil && !service.Status.Conditions.IsTrue() {
+	// Check service; Services have no readiness of their own, so it only has to exist
+	if !serviceFound {
 		ready = false
-		reasons = append(reasons, "Service not ready")
+		reasons = append(reasons, "Service not found")
 	}
 
 	// Update aggregated status
@@ -510,7 +459,7 @@ func (r *MyResourceReconciler) SetupWithCustomPredicate(mgr ctrl.Manager) error
 			// Only process if spec changed
 			oldObj := e.ObjectOld.(*MyResource)
 			newObj := e.ObjectNew.(*MyResource)
-			return !oldObj.Spec.Equal(&newObj.Spec)
+			return !equality.Semantic.DeepEqual(oldObj.Spec, newObj.Spec)
 		},
 		DeleteFunc: func(e event.DeleteEvent) bool {
 			// Process all delete events
@@ -544,8 +493,7 @@ func (r *MyResourceReconciler) reconcileDelete(ctx context.Context, instance *My
 			return ctrl.Result{}, err
 		}
 
-		controllerutil.RemoveFinalizer(instance, finalizerName)
-		if err := r.Update(ctx, instance); err != nil {
+		if err := r.RemoveFinalizerFresh(ctx, instance, finalizerName); err != nil {
 			return ctrl.Result{}, err
 		}
 	}
@@ -558,21 +506,14 @@ func (r *MyResourceReconciler) reconcileNormal(ctx context.Context, instance *My
 	return ctrl.Result{}, nil
 }
 
-func (r *MyResourceReconciler) cleanupExternalResources(ctx context.Context, instance *MyResource) error {
-	return nil
-}
-
-func (r *MyResourceReconciler) reconcileLogic(ctx context.Context, instance *MyResource) error {
-	return nil
-}
-
 // ==============================================================================
 // NOTES:
 //
 // 1. Leader Election: Enable in main.go with --leader-elect flag
 // 2. Metrics: Available at :8080/metrics by default
 // 3. Health Probes: Available at :8081/healthz and :8081/readyz
-// 4. Event Recorder: Injected into reconciler, events appear in kubectl describe
+// 4. Event Recorder: events.k8s.io/v1 recorder injected into reconciler (see patterns/events),
+//    events appear in kubectl describe
 // 5. Work Queue: Automatically managed by controller-runtime
 // 6. Caching: Watched resources are cached for performance
 //
@@ -584,25 +525,10 @@ func (r *MyResourceReconciler) reconcileLogic(ctx context.Context, instance *MyR
 // - Retry with Backoff: Handle transient failures gracefully
 // - Patch Strategy: Avoid conflicts in high-update scenarios
 // - Conflict Resolution: Handle concurrent modifications
+// - Cache Bypass Reads: Finalizer removal and one-shot actions that must not act on stale data
 // - Rate Limiting: Control reconciliation rate for external API calls
 // - Event Recording: Provide visibility into reconciliation
 // - Status Aggregation: When managing multiple child resources
 // - Custom Predicates: Filter events to reduce unnecessary reconciliations
 //
 // ==============================================================================
-
-// Import statements needed (not imported to avoid circular dependencies in this example):
-// import (
-// 	"sigs.k8s.io/controller-runtime/pkg/manager"
-// 	"sigs.k8s.io/controller-runtime/pkg/controller"
-// 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-// 	"sigs.k8s.io/controller-runtime/pkg/event"
-// 	"sigs.k8s.io/controller-runtime/pkg/handler"
-// 	"sigs.k8s.io/controller-runtime/pkg/predicate"
-// 	"sigs.k8s.io/controller-runtime/pkg/source"
-// 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
-// 	"k8s.io/client-go/util/retry"
-// 	"k8s.io/apimachinery/pkg/util/wait"
-// 	"k8s.io/client-go/util/workqueue"
-// 	appsv1 "k8s.io/api/apps/v1"
-// )
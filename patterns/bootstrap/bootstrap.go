@@ -0,0 +1,207 @@
+// Package bootstrap registers many controllers, webhooks, indexes and runnables against
+// one manager with consistent options.
+//
+// As an operator grows past one or two controllers, main.go fills up with copies of the
+// same setup block, each with slightly different concurrency, rate limiting and error
+// handling. The Builder below keeps those choices in one place:
+//
+//	err := bootstrap.New(mgr, bootstrap.Options{
+//		MaxConcurrentReconciles: 4,
+//		RecorderPrefix:          "myoperator-",
+//	}).
+//		Index(&corev1.Pod{}, ".spec.nodeName", podNodeName).
+//		Controller("myresource", &v1.MyResource{}, &controllers.MyResourceReconciler{
+//			Client: mgr.GetClient(),
+//			Scheme: mgr.GetScheme(),
+//		}, func(b *builder.Builder) *builder.Builder {
+//			return b.Owns(&appsv1.Deployment{})
+//		}).
+//		Setup(&controllers.LegacyReconciler{Client: mgr.GetClient()}).
+//		Webhook(&v1.MyResource{}, &v1.MyResourceDefaulter{}, &v1.MyResourceValidator{}).
+//		Runnable(&cleanup.Janitor{}).
+//		Complete()
+//	if err != nil {
+//		setupLog.Error(err, "unable to set up controllers")
+//		os.Exit(1)
+//	}
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Options are applied to every controller registered through the Builder
+type Options struct {
+	// MaxConcurrentReconciles is the number of parallel workers per controller.
+	// Zero keeps the controller-runtime default of 1.
+	MaxConcurrentReconciles int
+
+	// RateLimiter is used by every controller's workqueue.
+	// Nil keeps the controller-runtime default (exponential per item, bucket overall).
+	RateLimiter workqueue.RateLimiter
+
+	// RecoverPanic turns panics in Reconcile into errors instead of crashing the manager
+	RecoverPanic *bool
+
+	// RecorderPrefix is prepended to every event recorder name, so events from all
+	// controllers of one operator share a recognisable source
+	RecorderPrefix string
+}
+
+// SetupWithManager is implemented by reconcilers that wire their own watches.
+// This is the signature kubebuilder scaffolds.
+type SetupWithManager interface {
+	SetupWithManager(mgr ctrl.Manager) error
+}
+
+// Builder registers components against a manager.
+// The first error stops further registration and is returned by Complete.
+type Builder struct {
+	mgr  ctrl.Manager
+	opts Options
+	err  error
+
+	// broadcaster is created by the first Recorder call
+	broadcaster events.EventBroadcaster
+}
+
+// New returns a Builder that registers components against mgr
+func New(mgr ctrl.Manager, opts Options) *Builder {
+	return &Builder{mgr: mgr, opts: opts}
+}
+
+// Recorder returns an events.k8s.io/v1 recorder named with the configured prefix.
+// Use it when constructing reconcilers so every controller reports events consistently.
+// All recorders share one broadcaster, which is added to the manager on first use;
+// see patterns/events for how events/v1 aggregates repeated events.
+func (b *Builder) Recorder(name string) events.EventRecorder {
+	if b.broadcaster == nil && b.err == nil {
+		clientset, err := kubernetes.NewForConfig(b.mgr.GetConfig())
+		if err != nil {
+			b.record("event broadcaster", err)
+		} else {
+			broadcaster := events.NewBroadcaster(&events.EventSinkImpl{Interface: clientset.EventsV1()})
+			b.record("event broadcaster", b.mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+				if err := broadcaster.StartRecordingToSinkWithContext(ctx); err != nil {
+					return err
+				}
+				<-ctx.Done()
+				broadcaster.Shutdown()
+				return nil
+			})))
+			b.broadcaster = broadcaster
+		}
+	}
+	if b.broadcaster == nil {
+		// Setup already failed and Complete reports why; events recorded here are discarded
+		return &events.FakeRecorder{}
+	}
+	return b.broadcaster.NewRecorder(b.mgr.GetScheme(), b.opts.RecorderPrefix+name)
+}
+
+// ControllerOptions returns the shared controller options.
+// Reconcilers that keep their own SetupWithManager can pass these to WithOptions.
+func (b *Builder) ControllerOptions() controller.Options {
+	return controller.Options{
+		MaxConcurrentReconciles: b.opts.MaxConcurrentReconciles,
+		RateLimiter:             b.opts.RateLimiter,
+		RecoverPanic:            b.opts.RecoverPanic,
+	}
+}
+
+// Controller registers a reconciler for forType with the shared options.
+// Each configure function may add Owns, Watches or predicates to the controller.
+func (b *Builder) Controller(name string, forType client.Object, r reconcile.Reconciler, configure ...func(*builder.Builder) *builder.Builder) *Builder {
+	if b.err != nil {
+		return b
+	}
+
+	bld := ctrl.NewControllerManagedBy(b.mgr).
+		Named(name).
+		For(forType).
+		WithOptions(b.ControllerOptions())
+	for _, fn := range configure {
+		bld = fn(bld)
+	}
+
+	return b.record("controller "+name, bld.Complete(r))
+}
+
+// Setup registers reconcilers that wire their own watches.
+// They do not receive the shared options unless they read ControllerOptions themselves.
+func (b *Builder) Setup(reconcilers ...SetupWithManager) *Builder {
+	for _, r := range reconcilers {
+		if b.err != nil {
+			return b
+		}
+		b.record(fmt.Sprintf("reconciler %T", r), r.SetupWithManager(b.mgr))
+	}
+	return b
+}
+
+// Webhook registers defaulting and validating webhooks for apiType.
+// Either defaulter or validator may be nil.
+func (b *Builder) Webhook(apiType client.Object, defaulter admission.CustomDefaulter, validator admission.CustomValidator) *Builder {
+	if b.err != nil {
+		return b
+	}
+
+	bld := ctrl.NewWebhookManagedBy(b.mgr).For(apiType)
+	if defaulter != nil {
+		bld = bld.WithDefaulter(defaulter)
+	}
+	if validator != nil {
+		bld = bld.WithValidator(validator)
+	}
+
+	return b.record(fmt.Sprintf("webhook %T", apiType), bld.Complete())
+}
+
+// Index registers a field index on the manager's cache.
+// Indexes must be registered before the manager starts, which Complete guarantees
+// as long as it is called before mgr.Start.
+func (b *Builder) Index(obj client.Object, field string, extract client.IndexerFunc) *Builder {
+	if b.err != nil {
+		return b
+	}
+
+	err := b.mgr.GetFieldIndexer().IndexField(context.Background(), obj, field, extract)
+	return b.record(fmt.Sprintf("index %s on %T", field, obj), err)
+}
+
+// Runnable adds a background task that starts and stops with the manager.
+// Runnables implementing manager.LeaderElectionRunnable decide themselves whether they need the lease.
+func (b *Builder) Runnable(runnables ...manager.Runnable) *Builder {
+	for _, r := range runnables {
+		if b.err != nil {
+			return b
+		}
+		b.record(fmt.Sprintf("runnable %T", r), b.mgr.Add(r))
+	}
+	return b
+}
+
+// Complete returns the first registration error, naming the component that failed
+func (b *Builder) Complete() error {
+	return b.err
+}
+
+// record keeps the first error, wrapped with the component that caused it
+func (b *Builder) record(component string, err error) *Builder {
+	if err != nil && b.err == nil {
+		b.err = fmt.Errorf("unable to set up %s: %w", component, err)
+	}
+	return b
+}
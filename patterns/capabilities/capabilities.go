@@ -0,0 +1,189 @@
+// Package capabilities discovers what the API server offers so controllers and webhooks
+// can adapt to the cluster they run in instead of failing on a missing API or field.
+//
+// A Detector reads the server version and served API resources at startup and refreshes
+// them on a timer, so an API installed later (e.g. the Prometheus Operator CRDs) is picked up
+// without restarting the operator:
+//
+//	caps, err := capabilities.New(mgr.GetConfig())
+//	if err != nil {
+//		return err
+//	}
+//	if err := caps.Refresh(ctx); err != nil {
+//		return err
+//	}
+//	if err := mgr.Add(caps); err != nil {
+//		return err
+//	}
+//
+//	reconciler := &MyResourceReconciler{Client: mgr.GetClient(), Caps: caps}
+//
+// Controllers then consult typed lookups before using an optional API or a field that only
+// newer servers accept:
+//
+//	if r.Caps.Has(capabilities.PodDisruptionBudgetV1) {
+//		// create a policy/v1 PodDisruptionBudget
+//	}
+//	if r.Caps.AtLeast(1, 29) {
+//		// native sidecar containers (restartPolicy: Always on init containers)
+//	}
+package capabilities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DefaultRefreshInterval is how often the Detector re-runs discovery
+const DefaultRefreshInterval = 5 * time.Minute
+
+// Capability is an API kind at a specific group version
+type Capability schema.GroupVersionKind
+
+func (c Capability) String() string {
+	return schema.GroupVersionKind(c).String()
+}
+
+// Well-known capabilities that vary between clusters, either by server version or by
+// which add-ons are installed. Define your own the same way for other CRDs.
+var (
+	PodDisruptionBudgetV1       = Capability{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"}
+	PodDisruptionBudgetV1beta1  = Capability{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"}
+	HorizontalPodAutoscalerV2   = Capability{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"}
+	CronJobV1                   = Capability{Group: "batch", Version: "v1", Kind: "CronJob"}
+	EndpointSliceV1             = Capability{Group: "discovery.k8s.io", Version: "v1", Kind: "EndpointSlice"}
+	ValidatingAdmissionPolicyV1 = Capability{Group: "admissionregistration.k8s.io", Version: "v1", Kind: "ValidatingAdmissionPolicy"}
+
+	VolumeSnapshotV1 = Capability{Group: "snapshot.storage.k8s.io", Version: "v1", Kind: "VolumeSnapshot"}
+	ServiceMonitorV1 = Capability{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"}
+	CertificateV1    = Capability{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+)
+
+// Detector holds the most recently discovered server version and API resources.
+// It is safe for concurrent use and implements manager.Runnable.
+type Detector struct {
+	// Discovery is the client used to query the API server
+	Discovery discovery.DiscoveryInterface
+
+	// Interval between refreshes; DefaultRefreshInterval when zero
+	Interval time.Duration
+
+	mu      sync.RWMutex
+	version *version.Version
+	kinds   map[Capability]bool
+	groups  map[schema.GroupVersion]bool
+}
+
+// New returns a Detector using a discovery client built from cfg
+func New(cfg *rest.Config) (*Detector, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	return &Detector{Discovery: dc}, nil
+}
+
+// Refresh re-runs discovery. Groups whose discovery fails (typically an aggregated API
+// whose backing service is down) are left out rather than failing the whole refresh.
+func (d *Detector) Refresh(ctx context.Context) error {
+	info, err := d.Discovery.ServerVersion()
+	if err != nil {
+		return fmt.Errorf("failed to get server version: %w", err)
+	}
+	serverVersion, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return fmt.Errorf("failed to parse server version %q: %w", info.GitVersion, err)
+	}
+
+	_, resourceLists, err := d.Discovery.ServerGroupsAndResources()
+	var groupErr *discovery.ErrGroupDiscoveryFailed
+	if errors.As(err, &groupErr) {
+		log.FromContext(ctx).Info("Some API groups could not be discovered", "error", err.Error())
+	} else if err != nil {
+		return fmt.Errorf("failed to discover API resources: %w", err)
+	}
+
+	kinds := make(map[Capability]bool)
+	groups := make(map[schema.GroupVersion]bool)
+	for _, list := range resourceLists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		groups[gv] = true
+		for _, resource := range list.APIResources {
+			kinds[Capability{Group: gv.Group, Version: gv.Version, Kind: resource.Kind}] = true
+		}
+	}
+
+	d.mu.Lock()
+	changed := d.version == nil || d.version.String() != serverVersion.String() || len(d.kinds) != len(kinds)
+	d.version, d.kinds, d.groups = serverVersion, kinds, groups
+	d.mu.Unlock()
+
+	if changed {
+		log.FromContext(ctx).Info("Discovered cluster capabilities",
+			"version", serverVersion.String(), "groupVersions", len(groups), "kinds", len(kinds))
+	}
+	return nil
+}
+
+// Start refreshes on every Interval until ctx is done. A failed refresh keeps the
+// previous snapshot, so a brief API server outage does not disable features.
+func (d *Detector) Start(ctx context.Context) error {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := d.Refresh(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to refresh cluster capabilities")
+		}
+	}, interval)
+	return nil
+}
+
+// NeedLeaderElection returns false: webhooks and standby replicas need capabilities too
+func (d *Detector) NeedLeaderElection() bool {
+	return false
+}
+
+// Has reports whether the server serves the capability's kind at its group version.
+// It returns false before the first successful Refresh.
+func (d *Detector) Has(c Capability) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.kinds[c]
+}
+
+// HasGroupVersion reports whether the server serves any resource in gv
+func (d *Detector) HasGroupVersion(gv schema.GroupVersion) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.groups[gv]
+}
+
+// ServerVersion returns the discovered server version, or nil before the first Refresh
+func (d *Detector) ServerVersion() *version.Version {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.version
+}
+
+// AtLeast reports whether the server is at least major.minor. Use it for fields that
+// exist on an already-served kind but are only honoured by newer servers.
+func (d *Detector) AtLeast(major, minor uint) bool {
+	v := d.ServerVersion()
+	return v != nil && v.AtLeast(version.MajorMinor(major, minor))
+}
@@ -0,0 +1,116 @@
+// Package compat holds the few places where the patterns touch controller-runtime APIs that
+// changed between releases, so moving the pinned version means changing this package rather
+// than every pattern. The patterns target controller-runtime v0.17 (the version pinned in
+// examples/simple-operator/go.mod); the adapters also take code written for older releases.
+//
+// What changed, and the adapter for it:
+//
+//   - v0.15 removed &source.Kind{Type: obj}. The builder takes the object itself,
+//     Watches(&corev1.ConfigMap{}, handler); a controller built by hand watches through
+//     Watch, which also covers the v0.18 change of source.Kind taking the handler.
+//   - v0.15 added a context to handler.MapFunc. MapFunc adapts map functions without one.
+//   - v0.15 removed decoder injection (InjectDecoder). Webhook handlers get their decoder
+//     when they are constructed, from NewDecoder, and name its type as Decoder, which is a
+//     pointer up to v0.17 and an interface from v0.18.
+//   - v0.16 moved the metrics, webhook server and namespace options of the manager into
+//     nested options. ManagerOptions keeps the flat pre-v0.16 fields and converts them.
+//
+// Not covered, because there is a single way to write them in every supported release:
+// CustomValidator returning admission.Warnings (v0.15), and client.Object in predicates.
+package compat
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Decoder is the type of the decoder admission handlers hold:
+//
+//	type MyResourceValidator struct {
+//		Decoder compat.Decoder
+//	}
+//
+//	mgr.GetWebhookServer().Register("/validate-myresource", &webhook.Admission{
+//		Handler: &MyResourceValidator{Decoder: compat.NewDecoder(mgr.GetScheme())},
+//	})
+type Decoder = *admission.Decoder
+
+// NewDecoder returns a decoder for the types of a scheme
+func NewDecoder(scheme *runtime.Scheme) Decoder {
+	return admission.NewDecoder(scheme)
+}
+
+// MapFunc adapts a map function written before handler.MapFunc took a context:
+//
+//	handler.EnqueueRequestsFromMapFunc(compat.MapFunc(r.findObjectsForConfigMap))
+//
+// New map functions should take the context, which carries the logger and cancellation.
+func MapFunc(fn func(client.Object) []reconcile.Request) handler.MapFunc {
+	return func(_ context.Context, obj client.Object) []reconcile.Request {
+		return fn(obj)
+	}
+}
+
+// Watch makes a controller built with controller.New watch objects of the type of obj in the
+// cache. Controllers built with ctrl.NewControllerManagedBy use Watches(obj, ...) instead.
+func Watch(c controller.Controller, informers cache.Cache, obj client.Object, h handler.EventHandler, predicates ...predicate.Predicate) error {
+	return c.Watch(source.Kind(informers, obj), h, predicates...)
+}
+
+// ManagerOptions are the manager options as they were before v0.16, when they were flat
+type ManagerOptions struct {
+	Scheme *runtime.Scheme
+
+	// MetricsBindAddress is the address of the metrics endpoint; "0" disables it
+	MetricsBindAddress string
+
+	// HealthProbeBindAddress is the address of the healthz and readyz endpoints
+	HealthProbeBindAddress string
+
+	// Host, Port and CertDir configure the webhook server; zero values keep its defaults
+	Host    string
+	Port    int
+	CertDir string
+
+	// Namespaces restricts the cache to these namespaces; empty watches all namespaces
+	Namespaces []string
+
+	// SyncPeriod is how often every watched object is reconciled again; nil keeps the default
+	SyncPeriod *time.Duration
+
+	LeaderElection   bool
+	LeaderElectionID string
+}
+
+// Options converts the flat options into the nested options of the current manager
+func (o ManagerOptions) Options() ctrl.Options {
+	options := ctrl.Options{
+		Scheme:                 o.Scheme,
+		Metrics:                metricsserver.Options{BindAddress: o.MetricsBindAddress},
+		HealthProbeBindAddress: o.HealthProbeBindAddress,
+		WebhookServer:          webhook.NewServer(webhook.Options{Host: o.Host, Port: o.Port, CertDir: o.CertDir}),
+		Cache:                  cache.Options{SyncPeriod: o.SyncPeriod},
+		LeaderElection:         o.LeaderElection,
+		LeaderElectionID:       o.LeaderElectionID,
+	}
+	if len(o.Namespaces) > 0 {
+		options.Cache.DefaultNamespaces = map[string]cache.Config{}
+		for _, namespace := range o.Namespaces {
+			options.Cache.DefaultNamespaces[namespace] = cache.Config{}
+		}
+	}
+	return options
+}
@@ -0,0 +1,158 @@
+// Package concurrency bounds how many reconciles run at once for a single tenant.
+//
+// MaxConcurrentReconciles limits a controller as a whole. With many tenants sharing one
+// controller, a single namespace holding thousands of objects can occupy every worker and
+// starve everyone else. Wrapping the reconciler in a Limiter caps concurrent reconciles per
+// key (namespace, parent object, ...) while leaving the global worker count untouched:
+//
+//	limited := &concurrency.Limiter{
+//		Name:       "myresource",
+//		Reconciler: reconciler,
+//		Key:        concurrency.ByNamespace,
+//		Limit:      2,
+//	}
+//	ctrl.NewControllerManagedBy(mgr).
+//		For(&v1.MyResource{}).
+//		WithOptions(controller.Options{MaxConcurrentReconciles: 10}).
+//		Complete(limited)
+//
+// A request over its tenant's limit does not block a worker: it is requeued after
+// RetryInterval so the worker can pick up another tenant's request instead.
+package concurrency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var (
+	// tenantWait is the time a request spent deferred before its tenant had a free slot
+	tenantWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "reconcile_tenant_wait_seconds",
+		Help:    "Time requests waited for a free per-tenant reconcile slot.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"controller", "tenant"})
+
+	// tenantDeferred counts requests requeued because their tenant was at its limit
+	tenantDeferred = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "reconcile_tenant_deferred_total",
+		Help: "Number of reconcile requests deferred because their tenant was at its concurrency limit.",
+	}, []string{"controller", "tenant"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(tenantWait, tenantDeferred)
+}
+
+// DefaultRetryInterval is how long a deferred request waits before it is retried
+const DefaultRetryInterval = 500 * time.Millisecond
+
+// KeyFunc maps a request to the tenant whose limit it counts against.
+// Keys are used as metric labels, so they must have bounded cardinality.
+type KeyFunc func(req reconcile.Request) string
+
+// ByNamespace limits concurrent reconciles per namespace
+func ByNamespace(req reconcile.Request) string {
+	return req.Namespace
+}
+
+// Limiter wraps a reconciler and caps concurrent reconciles per tenant key
+type Limiter struct {
+	// Name is the controller name used in metric labels
+	Name string
+
+	// Reconciler is the wrapped reconciler
+	Reconciler reconcile.Reconciler
+
+	// Key maps requests to tenants; nil means ByNamespace
+	Key KeyFunc
+
+	// Limit is the maximum number of concurrent reconciles per tenant; zero or less disables the limit
+	Limit int
+
+	// RetryInterval is how long a deferred request waits before being retried; zero means DefaultRetryInterval
+	RetryInterval time.Duration
+
+	mu sync.Mutex
+	// active counts running reconciles per tenant; tenants with none are removed
+	active map[string]int
+	// deferredSince remembers when a request was first deferred, to report its total wait.
+	// Entries for objects deleted while deferred stay until the same key is reconciled again.
+	deferredSince map[reconcile.Request]time.Time
+}
+
+var _ reconcile.Reconciler = &Limiter{}
+
+// Reconcile runs the wrapped reconciler if the request's tenant has a free slot
+func (l *Limiter) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	if l.Limit <= 0 {
+		return l.Reconciler.Reconcile(ctx, req)
+	}
+
+	tenant := l.key(req)
+	if !l.acquire(req, tenant) {
+		tenantDeferred.WithLabelValues(l.Name, tenant).Inc()
+		return reconcile.Result{RequeueAfter: l.retryInterval()}, nil
+	}
+	defer l.release(tenant)
+
+	return l.Reconciler.Reconcile(ctx, req)
+}
+
+// acquire takes a slot for the tenant if one is free
+func (l *Limiter) acquire(req reconcile.Request, tenant string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active == nil {
+		l.active = make(map[string]int)
+		l.deferredSince = make(map[reconcile.Request]time.Time)
+	}
+
+	if l.active[tenant] >= l.Limit {
+		if _, ok := l.deferredSince[req]; !ok {
+			l.deferredSince[req] = time.Now()
+		}
+		return false
+	}
+
+	l.active[tenant]++
+
+	var waited time.Duration
+	if since, ok := l.deferredSince[req]; ok {
+		waited = time.Since(since)
+		delete(l.deferredSince, req)
+	}
+	tenantWait.WithLabelValues(l.Name, tenant).Observe(waited.Seconds())
+	return true
+}
+
+// release frees the tenant's slot
+func (l *Limiter) release(tenant string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active[tenant]--
+	if l.active[tenant] <= 0 {
+		delete(l.active, tenant)
+	}
+}
+
+func (l *Limiter) key(req reconcile.Request) string {
+	if l.Key == nil {
+		return ByNamespace(req)
+	}
+	return l.Key(req)
+}
+
+func (l *Limiter) retryInterval() time.Duration {
+	if l.RetryInterval <= 0 {
+		return DefaultRetryInterval
+	}
+	return l.RetryInterval
+}
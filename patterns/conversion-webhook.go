@@ -0,0 +1,256 @@
+package patterns
+
+// Conversion Webhook Pattern
+//
+// This file shows how to serve a second version of a CRD whose schema differs from the first.
+// The API server stores every object in one version only; when a client asks for another, it
+// calls the operator's conversion webhook. The v2 types below live in api/v2 of your project,
+// where they are named MyResource like the v1 types of crd.go; here they carry a V2 suffix
+// and convert to the MyResource of crd.go in the same package.
+// examples/database-operator/api/v2 is a worked example.
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+	conversionwebhook "sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+// WHEN YOU NEED A NEW VERSION
+// ===========================
+//
+// Adding an optional field is not a new version: old clients ignore it and old objects
+// read it as unset. A new version is needed when a field is renamed, regrouped, changes type
+// or meaning, or is removed. Without conversion the API server only rewrites apiVersion
+// ("conversion: None"), so a renamed field is silently dropped. Never serve two versions
+// with different schemas without a webhook.
+
+// HUB AND SPOKES
+// ==============
+//
+// With N versions, converting every pair needs N*(N-1) functions. controller-runtime
+// converts through one hub instead: the hub implements conversion.Hub (an empty Hub()
+// method), every other version implements conversion.Convertible (ConvertTo/ConvertFrom
+// the hub). Make the storage version the hub, so stored objects are never converted twice.
+//
+// api/v1:
+//
+//	//+kubebuilder:object:root=true
+//	//+kubebuilder:subresource:status
+//	//+kubebuilder:storageversion
+//	type MyResource struct { ... }
+//
+//	// Hub marks v1 as the version the other versions convert through
+//	func (*MyResource) Hub() {}
+//
+// Exactly one version carries +kubebuilder:storageversion. Until the conversion webhook is
+// deployed, mark the new version +kubebuilder:unservedversion, and serve it with the CRD
+// patch below; a CRD serving it without the webhook would lose the renamed fields.
+
+// Hub marks v1 as the version the other versions convert through
+func (*MyResource) Hub() {}
+
+// +kubebuilder:object:generate=true
+
+// MyResourceV2Spec renames replicas to instances and groups the configuration fields
+type MyResourceV2Spec struct {
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// Instances is the desired number of replicas
+	Instances int32 `json:"instances"`
+
+	// +kubebuilder:validation:MinLength=1
+	// Image is the container image to deploy
+	Image string `json:"image"`
+
+	// +kubebuilder:validation:Optional
+	// Config groups the configuration of the container
+	Config *ConfigSpec `json:"config,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Paused stops the reconciliation of the resource; v1 has no such field
+	Paused bool `json:"paused,omitempty"`
+
+	// RestartGeneration did not change, so it keeps its v1 name and type
+	RestartGeneration int64 `json:"restartGeneration,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+
+// ConfigSpec is the configuration of the container
+type ConfigSpec struct {
+	// +kubebuilder:validation:Optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// The status did not change, so v2 reuses the v1 type; a schema change there would need
+// converting too.
+//
+// +kubebuilder:object:generate=true
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:unservedversion
+
+// MyResourceV2 is the v2 Schema for the myresources API
+type MyResourceV2 struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MyResourceV2Spec `json:"spec,omitempty"`
+	Status MyResourceStatus `json:"status,omitempty"`
+}
+
+// LOSSLESS ROUND TRIPS
+// ====================
+//
+// An object written in v2 is stored in v1 and read back in v2, possibly by a controller
+// that then updates it. Fields the hub cannot hold must survive that trip, or the update
+// silently resets them. Keep them in an annotation on the converted object and restore them
+// on the way back; remove the annotation once there is nothing to keep, so objects that
+// never needed it do not carry it.
+
+// myResourceConversionAnnotation keeps the v2 fields that v1 cannot hold
+const myResourceConversionAnnotation = "mygroup.my.domain/conversion"
+
+// myResourceConversionData are the fields kept in the annotation
+type myResourceConversionData struct {
+	Paused bool `json:"paused,omitempty"`
+}
+
+// ConvertTo converts this MyResourceV2 to the hub version, v1
+func (src *MyResourceV2) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*MyResource)
+
+	// Deep copy: the converted object must not share maps or pointers with the source
+	spec := src.Spec.DeepCopy()
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = MyResourceSpec{
+		Replicas:          spec.Instances,
+		Image:             spec.Image,
+		RestartGeneration: spec.RestartGeneration,
+	}
+	if spec.Config != nil {
+		dst.Spec.ConfigMapName = spec.Config.ConfigMapName
+		dst.Spec.Parameters = spec.Config.Parameters
+	}
+	dst.Status = *src.Status.DeepCopy()
+	return setMyResourceConversionData(dst, myResourceConversionData{Paused: spec.Paused})
+}
+
+// ConvertFrom converts the hub version, v1, to this MyResourceV2
+func (dst *MyResourceV2) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*MyResource)
+	var kept myResourceConversionData
+	if value, ok := src.Annotations[myResourceConversionAnnotation]; ok {
+		if err := json.Unmarshal([]byte(value), &kept); err != nil {
+			return fmt.Errorf("invalid annotation %s: %w", myResourceConversionAnnotation, err)
+		}
+	}
+
+	spec := src.Spec.DeepCopy()
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = MyResourceV2Spec{
+		Instances:         spec.Replicas,
+		Image:             spec.Image,
+		Paused:            kept.Paused,
+		RestartGeneration: spec.RestartGeneration,
+	}
+	if spec.ConfigMapName != "" || len(spec.Parameters) > 0 {
+		dst.Spec.Config = &ConfigSpec{ConfigMapName: spec.ConfigMapName, Parameters: spec.Parameters}
+	}
+	dst.Status = *src.Status.DeepCopy()
+	// v2 holds every field it kept, so the annotation is not shown to v2 clients
+	return setMyResourceConversionData(dst, myResourceConversionData{})
+}
+
+// setMyResourceConversionData replaces the conversion annotation of obj with data
+func setMyResourceConversionData(obj metav1.Object, data myResourceConversionData) error {
+	annotations := obj.GetAnnotations()
+	delete(annotations, myResourceConversionAnnotation)
+	if data != (myResourceConversionData{}) {
+		value, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[myResourceConversionAnnotation] = string(value)
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// SERVING THE WEBHOOK
+// ===================
+//
+// Register every version in the manager's scheme:
+//
+//	utilruntime.Must(mygroupv1.AddToScheme(scheme))
+//	utilruntime.Must(mygroupv2.AddToScheme(scheme))
+//
+// The webhook builder, ctrl.NewWebhookManagedBy(mgr).For(&mygroupv1.MyResource{}), serves
+// /convert on its own once the type has a hub and convertible spokes in the scheme. Handlers
+// registered by hand, as in webhook.go, need it registered beside them. One handler converts
+// every type of the scheme.
+func SetupConversionWebhookWithManager(mgr ctrl.Manager) error {
+	convertible, err := conversionwebhook.IsConvertible(mgr.GetScheme(), &MyResource{})
+	if err != nil {
+		return err
+	}
+	if !convertible {
+		return fmt.Errorf("MyResource has no hub and spokes in the scheme")
+	}
+	mgr.GetWebhookServer().Register("/convert", conversionwebhook.NewWebhookHandler(mgr.GetScheme()))
+	return nil
+}
+
+// Admission webhooks registered for v1 also receive v2 writes, converted to v1 first
+// (matchPolicy: Equivalent, the default), so validation and defaulting stay in one place.
+//
+// The CRD points the API server at the webhook; controller-gen does not generate this.
+// kubebuilder scaffolds a patch, config/crd/patches/webhook_in_myresources.yaml, enabled from
+// config/crd/kustomization.yaml together with a CA injection patch:
+//
+//	spec:
+//	  conversion:
+//	    strategy: Webhook
+//	    webhook:
+//	      clientConfig:
+//	        service:
+//	          namespace: system
+//	          name: webhook-service
+//	          path: /convert
+//	      conversionReviewVersions: [v1]
+//
+// The same patch sets served: true on v2. A Helm chart cannot template CRDs under crds/, so
+// a chart either templates the CRD itself or serves only the storage version.
+
+// TESTING
+// =======
+//
+// 1. Round trips in unit tests: hub -> spoke -> hub and spoke -> hub -> spoke must both give
+//    back the object they started from, including fields only one version has. Table-test
+//    the lossy cases (unit changes, rounding, defaults) explicitly.
+// 2. envtest: load the CRD with v2 served, start a manager with the webhook server on
+//    WebhookInstallOptions, and write in one version, read in the other. envtest points the
+//    CRD's conversion webhook at the local server for every convertible type in
+//    Environment.Scheme.
+
+// CHANGING THE STORAGE VERSION
+// ============================
+//
+// Moving +kubebuilder:storageversion to v2 only affects objects written afterwards; the rest
+// stay stored in v1, and the CRD's status.storedVersions keeps listing v1. Before v1 can be
+// removed from the CRD, every object must be rewritten (e.g. by the kube-storage-version-migrator,
+// or by reading and writing each object back unchanged) and v1 removed from
+// status.storedVersions. Keep v1 served, and the webhook running, until then.
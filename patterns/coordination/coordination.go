@@ -0,0 +1,270 @@
+// Package coordination lets several controllers reconcile the same object without clobbering
+// each other.
+//
+// A Database may be provisioned by one controller and backed up by another. If both read the
+// object, change their part and Update it, each write replaces the other's status, conditions
+// and finalizers whenever the two interleave. Instead, each controller is a Participant that
+// owns a disjoint part of the object and writes only that part with server-side apply under
+// its own field manager:
+//
+//   - finalizers: metadata.finalizers is a set, so each participant applies only its own
+//     entry and the API server merges them
+//   - conditions: each participant owns the condition types with its prefix; conditions is a
+//     map keyed by type, so applied conditions of different managers are merged too
+//   - other status fields: each is written by exactly one participant
+//
+// The CRD must declare status.conditions as a map list, or the whole list is replaced on
+// every apply:
+//
+//	// +listType=map
+//	// +listMapKey=type
+//	Conditions []metav1.Condition `json:"conditions,omitempty"`
+//
+// Declare the participants once, next to each other, so the split stays visible:
+//
+//	var (
+//		provisioner = coordination.Participant{
+//			FieldManager:    "database-provisioner",
+//			Finalizer:       "database.my.domain/provisioner",
+//			ConditionPrefix: "Provisioning",
+//		}
+//		backup = coordination.Participant{
+//			FieldManager:    "database-backup",
+//			Finalizer:       "database.my.domain/backup",
+//			ConditionPrefix: "Backup",
+//		}
+//	)
+//
+// The backup controller then handles only its own share:
+//
+//	if !db.DeletionTimestamp.IsZero() {
+//		if !backup.Finalizing(db) {
+//			return ctrl.Result{}, nil
+//		}
+//		if err := r.deleteSchedules(ctx, db); err != nil {
+//			return ctrl.Result{}, err
+//		}
+//		return ctrl.Result{}, backup.ReleaseFinalizer(ctx, r.Client, db)
+//	}
+//	if err := backup.EnsureFinalizer(ctx, r.Client, db); err != nil {
+//		return ctrl.Result{}, err
+//	}
+//	...
+//	return ctrl.Result{}, backup.ApplyStatus(ctx, r.Client, db,
+//		map[string]interface{}{"lastBackupTime": last.Format(time.RFC3339)},
+//		metav1.Condition{Type: backup.Condition("Ready"), Status: metav1.ConditionTrue, Reason: "Scheduled"})
+//
+// and watches the object without reacting to the other participant's status writes, except
+// for the conditions it depends on:
+//
+//	For(&dbv1.Database{}, builder.WithPredicates(backup.WatchConditions(provisioner.Condition("Ready"))))
+//
+// Neither participant may own an unprefixed summary condition such as Ready: whichever
+// applied it last would win.
+package coordination
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Participant is one of several controllers reconciling the same object
+type Participant struct {
+	// FieldManager identifies the participant's server-side apply requests. It must be
+	// unique among the participants and stable across releases.
+	FieldManager string
+
+	// Finalizer is the participant's own finalizer; it is never shared
+	Finalizer string
+
+	// ConditionPrefix namespaces the participant's condition types: "Backup" owns
+	// BackupReady, BackupFailed and so on
+	ConditionPrefix string
+}
+
+// Condition returns the participant's condition type with the given suffix
+func (p Participant) Condition(suffix string) string {
+	return p.ConditionPrefix + suffix
+}
+
+// Owns reports whether the condition type belongs to the participant
+func (p Participant) Owns(conditionType string) bool {
+	return p.ConditionPrefix != "" && strings.HasPrefix(conditionType, p.ConditionPrefix)
+}
+
+// Finalizing reports whether obj is being deleted and still waits for this participant
+func (p Participant) Finalizing(obj client.Object) bool {
+	if obj.GetDeletionTimestamp().IsZero() {
+		return false
+	}
+	for _, finalizer := range obj.GetFinalizers() {
+		if finalizer == p.Finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureFinalizer applies the participant's finalizer to obj. Other participants' finalizers
+// are left alone, whatever the cached copy of obj holds.
+func (p Participant) EnsureFinalizer(ctx context.Context, c client.Client, obj client.Object) error {
+	for _, finalizer := range obj.GetFinalizers() {
+		if finalizer == p.Finalizer {
+			return nil
+		}
+	}
+	patch, err := p.applyConfiguration(c, obj)
+	if err != nil {
+		return err
+	}
+	patch.SetFinalizers([]string{p.Finalizer})
+	return c.Patch(ctx, patch, client.Apply, client.FieldOwner(p.FieldManager))
+}
+
+// ReleaseFinalizer removes the participant's finalizer from obj by applying no finalizers.
+// The object is deleted once every participant has released its finalizer.
+//
+// Only a finalizer this field manager applied is removed; one added with Update, e.g. by an
+// older release of the controller, must be removed with Update once.
+func (p Participant) ReleaseFinalizer(ctx context.Context, c client.Client, obj client.Object) error {
+	patch, err := p.applyConfiguration(c, obj)
+	if err != nil {
+		return err
+	}
+	return client.IgnoreNotFound(c.Patch(ctx, patch, client.Apply, client.FieldOwner(p.FieldManager)))
+}
+
+// ApplyStatus applies the participant's status fields and conditions to the status subresource
+// of obj. Every call must include all of them: a field or condition the participant applied
+// before but omits now is removed. Conditions keep their lastTransitionTime while their status
+// is unchanged.
+//
+// obj must be the object as last read; it provides the current conditions and is not modified.
+func (p Participant) ApplyStatus(ctx context.Context, c client.Client, obj client.Object, fields map[string]interface{}, conditions ...metav1.Condition) error {
+	existing, err := conditionsOf(obj)
+	if err != nil {
+		return err
+	}
+
+	applied := make([]interface{}, 0, len(conditions))
+	for _, condition := range conditions {
+		if !p.Owns(condition.Type) {
+			return fmt.Errorf("condition %s does not belong to %s", condition.Type, p.FieldManager)
+		}
+		condition.LastTransitionTime = metav1.Now()
+		if current := meta.FindStatusCondition(existing, condition.Type); current != nil && current.Status == condition.Status {
+			condition.LastTransitionTime = current.LastTransitionTime
+		}
+		if condition.ObservedGeneration == 0 {
+			condition.ObservedGeneration = obj.GetGeneration()
+		}
+		value, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&condition)
+		if err != nil {
+			return err
+		}
+		applied = append(applied, value)
+	}
+
+	status := make(map[string]interface{}, len(fields)+1)
+	for name, value := range fields {
+		if name == "conditions" {
+			return fmt.Errorf("status.conditions must be passed as conditions")
+		}
+		status[name] = value
+	}
+	status["conditions"] = applied
+
+	patch, err := p.applyConfiguration(c, obj)
+	if err != nil {
+		return err
+	}
+	patch.Object["status"] = status
+	return c.Status().Patch(ctx, patch, client.Apply, client.FieldOwner(p.FieldManager))
+}
+
+// WatchConditions passes the events a participant must react to: spec changes, deletion,
+// finalizer changes and changes of the given conditions, typically other participants'
+// conditions it depends on. Status writes of other participants are filtered out, so that two
+// participants do not trigger each other on every status update.
+func (p Participant) WatchConditions(conditionTypes ...string) predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return true
+			}
+			if e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() ||
+				!e.ObjectNew.GetDeletionTimestamp().IsZero() ||
+				!reflect.DeepEqual(e.ObjectOld.GetFinalizers(), e.ObjectNew.GetFinalizers()) {
+				return true
+			}
+			oldConditions, err := conditionsOf(e.ObjectOld)
+			if err != nil {
+				return true
+			}
+			newConditions, err := conditionsOf(e.ObjectNew)
+			if err != nil {
+				return true
+			}
+			for _, conditionType := range conditionTypes {
+				oldCondition := meta.FindStatusCondition(oldConditions, conditionType)
+				newCondition := meta.FindStatusCondition(newConditions, conditionType)
+				if (oldCondition == nil) != (newCondition == nil) ||
+					oldCondition != nil && oldCondition.Status != newCondition.Status {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// applyConfiguration returns an apply patch for obj that holds only its identity. The UID
+// makes the apply fail instead of touching an object recreated under the same name.
+func (p Participant) applyConfiguration(c client.Client, obj client.Object) (*unstructured.Unstructured, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	patch := &unstructured.Unstructured{}
+	patch.SetGroupVersionKind(gvk)
+	patch.SetNamespace(obj.GetNamespace())
+	patch.SetName(obj.GetName())
+	patch.SetUID(obj.GetUID())
+	return patch, nil
+}
+
+// conditionsOf reads status.conditions from any object with metav1.Condition conditions
+func conditionsOf(obj client.Object) ([]metav1.Condition, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	values, found, err := unstructured.NestedSlice(content, "status", "conditions")
+	if err != nil || !found {
+		return nil, err
+	}
+	conditions := make([]metav1.Condition, 0, len(values))
+	for _, value := range values {
+		entry, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var condition metav1.Condition
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(entry, &condition); err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
@@ -9,6 +9,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// +kubebuilder:object:generate=true
+
 // MyResourceSpec defines the desired state of MyResource
 // Add fields here that represent the desired state
 type MyResourceSpec struct {
@@ -27,11 +29,21 @@ type MyResourceSpec struct {
 	// ConfigMapName is an optional ConfigMap reference
 	ConfigMapName string `json:"configMapName,omitempty"`
 
+	// +kubebuilder:validation:Optional
+	// SecretName is an optional Secret reference
+	SecretName string `json:"secretName,omitempty"`
+
 	// +kubebuilder:validation:Optional
 	// Parameters for custom configuration
 	Parameters map[string]string `json:"parameters,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// RestartGeneration restarts the pods when increased; the webhook rejects lowering it
+	RestartGeneration int64 `json:"restartGeneration,omitempty"`
 }
 
+// +kubebuilder:object:generate=true
+
 // MyResourceStatus defines the observed state of MyResource
 // Add fields here that represent the current state
 type MyResourceStatus struct {
@@ -44,6 +56,10 @@ type MyResourceStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 
 	// Add other status fields here
+	// Phase is a one-word summary of the state, for kubectl get
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
 	// ReadyReplicas is the number of ready replicas
 	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
 
@@ -52,6 +68,7 @@ type MyResourceStatus struct {
 	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
 }
 
+// +kubebuilder:object:generate=true
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:shortName=mr
@@ -68,6 +85,7 @@ type MyResource struct {
 	Status MyResourceStatus `json:"status,omitempty"`
 }
 
+// +kubebuilder:object:generate=true
 // +kubebuilder:object:root=true
 
 // MyResourceList contains a list of MyResource
@@ -0,0 +1,233 @@
+package patterns
+
+// Deletion Pattern
+//
+// This file shows how to delete a resource and its children in a guaranteed order.
+// By default Kubernetes deletes children in the background: the parent disappears first
+// and the garbage collector removes children afterwards. That is fine for stateless children,
+// but some operators must be sure every child is gone before the parent is (e.g. the parent's
+// finalizer releases an external resource the children still use).
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// PROPAGATION POLICIES
+// ====================
+//
+// client.PropagationPolicy(metav1.DeletePropagationBackground)
+//   Default for most resources. The object is removed immediately, the garbage
+//   collector deletes dependents afterwards. Use when nothing depends on ordering.
+//
+// client.PropagationPolicy(metav1.DeletePropagationForeground)
+//   The object gets a deletionTimestamp and a "foregroundDeletion" finalizer.
+//   The garbage collector first deletes every dependent whose owner reference has
+//   blockOwnerDeletion=true, then removes the finalizer so the object goes away.
+//   Use when the caller must know that children are gone once the parent is gone.
+//
+// client.PropagationPolicy(metav1.DeletePropagationOrphan)
+//   Dependents are kept and their owner references removed. Use when handing
+//   children over to another owner (e.g. migrating a Deployment to a StatefulSet).
+
+// BLOCKING OWNER REFERENCES
+// =========================
+//
+// Foreground deletion only waits for dependents whose owner reference has
+// BlockOwnerDeletion=true. controllerutil.SetControllerReference sets it for you;
+// controllerutil.SetOwnerReference does NOT. Setting it requires the "update"
+// verb on the owner's finalizers subresource, which is why kubebuilder scaffolds:
+//
+// +kubebuilder:rbac:groups=mygroup.my.domain,resources=myresources/finalizers,verbs=update
+
+// ChildDeletionPropagation is the propagation policy used when the reconciler deletes its own children.
+// Foreground makes each child wait for its own dependents (e.g. a Deployment waits for its Pods).
+// envtest runs no garbage collector, so nothing would ever remove the foregroundDeletion finalizer:
+// integration tests set this to Background.
+var ChildDeletionPropagation = metav1.DeletePropagationForeground
+
+// childDeletionPollInterval is how often deletion progress is re-checked.
+// Children being deleted also trigger reconciles through Owns() watches.
+const childDeletionPollInterval = 2 * time.Second
+
+// FOREGROUND DELETION FROM A CLIENT
+// =================================
+
+// DeleteWithForeground deletes a parent so that it only disappears after its blocking children.
+// Use this from tools, tests or higher-level controllers that delete MyResources.
+func DeleteWithForeground(ctx context.Context, c client.Client, instance *MyResource) error {
+	return client.IgnoreNotFound(c.Delete(ctx, instance,
+		client.PropagationPolicy(metav1.DeletePropagationForeground)))
+}
+
+// FINALIZER-DRIVEN ORDERED DELETION
+// =================================
+//
+// Foreground deletion is chosen by whoever deletes the parent; the controller cannot
+// rely on it. When ordering matters regardless of how the parent was deleted, the
+// controller's finalizer deletes the children itself and only removes the finalizer
+// once they are fully gone:
+//
+//   1. Parent gets a deletionTimestamp, our finalizer keeps it in place
+//   2. Controller deletes every child with ChildDeletionPropagation
+//   3. Controller requeues until Get returns NotFound for every child
+//   4. Controller removes the finalizer, the parent disappears
+//
+// reconciler.go passes reconcileDeleteOrdered to ReconcileWithFinalizer (finalizer.go),
+// which runs steps 2 and 3 through it and does step 4 once it returns an empty Result.
+
+// reconcileDeleteOrdered keeps the finalizer until all children are gone
+func (r *MyResourceReconciler) reconcileDeleteOrdered(ctx context.Context, instance *MyResource) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	gone, err := DeleteChildrenAndWait(ctx, r.Client, r.childrenOf(instance))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !gone {
+		log.Info("Waiting for children to be deleted", "name", instance.Name)
+		return ctrl.Result{RequeueAfter: childDeletionPollInterval}, nil
+	}
+
+	// Every child is gone: clean up external resources, then let the parent go
+	return r.finalize(ctx, instance)
+}
+
+// DeleteChildrenAndWait issues deletes for the given children and reports whether all of them are gone.
+// It is safe to call repeatedly: children that are already terminating are not deleted again.
+func DeleteChildrenAndWait(ctx context.Context, c client.Client, children []client.Object) (bool, error) {
+	allGone := true
+
+	for _, child := range children {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(child), child); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+
+		allGone = false
+		if child.GetDeletionTimestamp() != nil {
+			// Already terminating, e.g. waiting for its own dependents
+			continue
+		}
+
+		if err := c.Delete(ctx, child, client.PropagationPolicy(ChildDeletionPropagation)); client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+	}
+
+	return allGone, nil
+}
+
+// childrenOf lists the children that must be gone before the parent may disappear.
+// Each call returns fresh objects because DeleteChildrenAndWait reads into them.
+func (r *MyResourceReconciler) childrenOf(instance *MyResource) []client.Object {
+	return []client.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      instance.Name,
+				Namespace: instance.Namespace,
+			},
+		},
+	}
+}
+
+// TWO-PHASE FINALIZER REMOVAL
+// ===========================
+//
+// Returning the cleanup error from Reconcile hides the failure: the object sits in
+// Terminating forever, the only trace is a log line, and there is no way out short of
+// editing finalizers by hand. Instead, split finalization into two phases:
+//
+//   Phase 1: clean up external resources. On failure, record the attempt, set a
+//            DeletionBlocked condition carrying the error and requeue with backoff.
+//   Phase 2: remove the finalizer. Only reached once phase 1 succeeded, or once a
+//            human explicitly gave up on the external resources.
+//
+// r.finalize (reconciler.go) is phase 1: it returns an empty Result only when cleanup
+// succeeded or was skipped, and ReconcileWithFinalizer (finalizer.go) then does phase 2.
+//
+// After MaxCleanupAttempts failures, a user may set the force-delete annotation to skip
+// phase 1. The annotation is ignored before that, so it cannot be used to bypass cleanup
+// by accident; the DeletionBlocked message tells the user when it becomes effective.
+//
+//   kubectl annotate myresource <name> myresource.my.domain/force-delete=true
+
+const (
+	// conditionDeletionBlocked is True while external cleanup is failing
+	conditionDeletionBlocked = "DeletionBlocked"
+
+	// forceDeleteAnnotation skips external cleanup once MaxCleanupAttempts is reached
+	forceDeleteAnnotation = "myresource.my.domain/force-delete"
+
+	// cleanupAttemptsAnnotation counts failed cleanup attempts.
+	// It lives in metadata rather than status so it survives status being rewritten.
+	cleanupAttemptsAnnotation = "myresource.my.domain/cleanup-attempts"
+)
+
+// MaxCleanupAttempts is the number of failed cleanups after which force-delete is honored
+var MaxCleanupAttempts = 5
+
+// blockDeletion records a failed cleanup attempt and schedules the next one.
+// The error is surfaced in the DeletionBlocked condition and not returned, so the
+// backoff below is honored instead of the controller's rate limiter.
+func (r *MyResourceReconciler) blockDeletion(ctx context.Context, instance *MyResource, cleanupErr error) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	attempts := cleanupAttempts(instance) + 1
+
+	patch := client.MergeFrom(instance.DeepCopy())
+	if instance.Annotations == nil {
+		instance.Annotations = make(map[string]string)
+	}
+	instance.Annotations[cleanupAttemptsAnnotation] = strconv.Itoa(attempts)
+	if err := r.Patch(ctx, instance, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	message := fmt.Sprintf("External cleanup failed (attempt %d): %v", attempts, cleanupErr)
+	if attempts >= MaxCleanupAttempts {
+		message += fmt.Sprintf("; set annotation %s=true to skip cleanup", forceDeleteAnnotation)
+	}
+	instance.SetCondition(conditionDeletionBlocked, metav1.ConditionTrue, "CleanupFailed", message)
+	if err := r.Status().Update(ctx, instance); err != nil {
+		log.Error(err, "Failed to update DeletionBlocked condition")
+	}
+
+	backoff := cleanupBackoff(attempts)
+	log.Error(cleanupErr, "External cleanup failed, retrying", "attempt", attempts, "backoff", backoff)
+	return ctrl.Result{RequeueAfter: backoff}, nil
+}
+
+// cleanupAttempts returns the number of failed cleanups recorded on the resource
+func cleanupAttempts(instance *MyResource) int {
+	attempts, err := strconv.Atoi(instance.Annotations[cleanupAttemptsAnnotation])
+	if err != nil {
+		return 0
+	}
+	return attempts
+}
+
+// forceDeleteAllowed reports whether the user asked to skip cleanup and enough attempts have failed
+func forceDeleteAllowed(instance *MyResource) bool {
+	return instance.Annotations[forceDeleteAnnotation] == "true" &&
+		cleanupAttempts(instance) >= MaxCleanupAttempts
+}
+
+// cleanupBackoff returns 2^attempts seconds, capped at 5 minutes
+func cleanupBackoff(attempts int) time.Duration {
+	// Checked before shifting so large attempt counts cannot overflow
+	if attempts >= 9 {
+		return 5 * time.Minute
+	}
+	return time.Duration(1<<uint(attempts)) * time.Second
+}
@@ -0,0 +1,304 @@
+// Package drill verifies backups by restoring them. A backup that was never restored is only
+// a hope: the dump may be truncated, encrypted with a lost key or taken from the wrong
+// database, and nobody finds out until the restore that matters.
+//
+// A Drill periodically runs a throwaway Job that restores the latest backup into a scratch
+// database inside the pod, queries it and reports what it found through its termination
+// message. The result is recorded as a BackupVerified condition on the owner, whose message
+// carries the time of the drill:
+//
+//	result, err := r.Drill.Reconcile(ctx, db, &db.Status.Conditions, drill.Spec{
+//		Backup:   latest.Name,
+//		Expected: drill.Result{Rows: latest.Status.Rows, Checksum: latest.Status.Checksum},
+//		Pod: corev1.PodSpec{Containers: []corev1.Container{{
+//			Name:    "restore",
+//			Image:   db.Spec.Image,
+//			Command: []string{"/scripts/restore-and-verify.sh", latest.Status.Location},
+//		}}},
+//	})
+//
+// The container restores the backup and writes a JSON Result to /dev/termination-log, e.g.
+// {"backup": "db-20240101", "rows": 1042, "checksum": "9f2c..."}. Expected values the backup
+// recorded when it was taken are compared with it; an empty Expected only requires a
+// non-empty restore.
+//
+// The controller must own Jobs so a finished drill triggers a reconcile:
+//
+//	ctrl.NewControllerManagedBy(mgr).For(&dbv1.Database{}).Owns(&batchv1.Job{})
+//
+// The finished Job is kept until the next drill is due. It is the record of the last drill,
+// so the schedule survives operator restarts without extra status fields, and its pod logs
+// remain available for investigating a failed drill.
+package drill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// ConditionType is the condition a Drill records on its owner
+const ConditionType = "BackupVerified"
+
+// Condition reasons
+const (
+	ReasonRunning          = "DrillRunning"
+	ReasonVerified         = "Verified"
+	ReasonFailed           = "DrillFailed"
+	ReasonEmpty            = "EmptyRestore"
+	ReasonChecksumMismatch = "ChecksumMismatch"
+	ReasonRowCountMismatch = "RowCountMismatch"
+)
+
+// Label marks the Job and pods of a drill with the Job name; BackupAnnotation records the
+// backup the Job restores
+const (
+	Label            = "drill.my.domain/job"
+	BackupAnnotation = "drill.my.domain/backup"
+)
+
+// Defaults used when the corresponding Drill field is zero
+const (
+	DefaultInterval = 24 * time.Hour
+	DefaultTimeout  = time.Hour
+)
+
+// Result is what a drill pod reports in its termination message
+type Result struct {
+	// Backup identifies the backup that was restored
+	Backup string `json:"backup,omitempty"`
+
+	// Rows is the row count of the restored database
+	Rows int64 `json:"rows"`
+
+	// Checksum is a checksum over the restored data
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// Spec describes the drill for one owner
+type Spec struct {
+	// Backup identifies the backup to restore; it is recorded on the Job and in the condition
+	Backup string
+
+	// Expected are the values recorded when the backup was taken; zero values are not compared
+	Expected Result
+
+	// Pod restores the backup and reports a Result. RestartPolicy is forced to Never.
+	Pod corev1.PodSpec
+}
+
+// Drill runs restore drills and records their outcome
+type Drill struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+
+	// Interval between the end of one drill and the start of the next; DefaultInterval when zero
+	Interval time.Duration
+
+	// Timeout bounds a single drill; DefaultTimeout when zero
+	Timeout time.Duration
+}
+
+// JobName is the name of the drill Job of an owner
+func JobName(owner client.Object) string {
+	return owner.GetName() + "-restore-drill"
+}
+
+// Reconcile starts a drill when one is due, records the outcome of a finished one in
+// conditions and returns when to look again
+func (d *Drill) Reconcile(ctx context.Context, owner client.Object, conditions *[]metav1.Condition, spec Spec) (ctrl.Result, error) {
+	job := &batchv1.Job{}
+	err := d.Client.Get(ctx, client.ObjectKey{Namespace: owner.GetNamespace(), Name: JobName(owner)}, job)
+	if apierrors.IsNotFound(err) {
+		return ctrl.Result{}, d.start(ctx, owner, conditions, spec)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !job.DeletionTimestamp.IsZero() {
+		// The previous drill is being removed; its deletion triggers the next reconcile
+		return ctrl.Result{}, nil
+	}
+
+	finished, failed := finishedAt(job)
+	if finished.IsZero() {
+		// Owns(&batchv1.Job{}) reconciles again when it finishes
+		return ctrl.Result{}, nil
+	}
+
+	if failed {
+		setCondition(owner, conditions, metav1.ConditionFalse, ReasonFailed,
+			fmt.Sprintf("Restore drill of backup %s failed at %s: %s", job.Annotations[BackupAnnotation], finished.Format(time.RFC3339), d.failureMessage(ctx, job)))
+	} else {
+		result, err := d.result(ctx, job)
+		if err != nil {
+			setCondition(owner, conditions, metav1.ConditionFalse, ReasonFailed,
+				fmt.Sprintf("Restore drill of backup %s at %s reported no result: %v", job.Annotations[BackupAnnotation], finished.Format(time.RFC3339), err))
+		} else {
+			// Expected describes spec.Backup; a newer backup may have been taken since the drill started
+			expected := spec.Expected
+			if job.Annotations[BackupAnnotation] != spec.Backup {
+				expected = Result{}
+			}
+			status, reason, message := evaluate(result, expected)
+			setCondition(owner, conditions, status, reason,
+				fmt.Sprintf("Backup %s restored at %s: %s", job.Annotations[BackupAnnotation], finished.Format(time.RFC3339), message))
+		}
+	}
+
+	interval := d.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if wait := time.Until(finished.Add(interval)); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	// Due: remove the record of the last drill; the next reconcile starts a new one
+	err = d.Client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	return ctrl.Result{}, client.IgnoreNotFound(err)
+}
+
+// start creates the drill Job
+func (d *Drill) start(ctx context.Context, owner client.Object, conditions *[]metav1.Condition, spec Spec) error {
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	deadline := int64(timeout.Seconds())
+	backoffLimit := int32(0)
+
+	name := JobName(owner)
+	pod := *spec.Pod.DeepCopy()
+	pod.RestartPolicy = corev1.RestartPolicyNever
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   owner.GetNamespace(),
+			Labels:      map[string]string{Label: name},
+			Annotations: map[string]string{BackupAnnotation: spec.Backup},
+		},
+		Spec: batchv1.JobSpec{
+			// A failed restore is a finding, not something to retry until it passes
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &deadline,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{Label: name}},
+				Spec:       pod,
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(owner, job, d.Scheme); err != nil {
+		return err
+	}
+	if err := d.Client.Create(ctx, job); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to start restore drill: %w", err)
+	}
+
+	if meta.FindStatusCondition(*conditions, ConditionType) == nil {
+		setCondition(owner, conditions, metav1.ConditionUnknown, ReasonRunning,
+			fmt.Sprintf("Restoring backup %s", spec.Backup))
+	}
+	return nil
+}
+
+// result reads the Result a succeeded drill pod left in its termination message
+func (d *Drill) result(ctx context.Context, job *batchv1.Job) (Result, error) {
+	message, err := d.terminationMessage(ctx, job, true)
+	if err != nil {
+		return Result{}, err
+	}
+	var result Result
+	if err := json.Unmarshal([]byte(message), &result); err != nil {
+		return Result{}, fmt.Errorf("invalid result %q: %w", message, err)
+	}
+	return result, nil
+}
+
+// failureMessage explains a failed drill from its pod, or from the Job when the pod is gone
+func (d *Drill) failureMessage(ctx context.Context, job *batchv1.Job) string {
+	if message, err := d.terminationMessage(ctx, job, false); err == nil && message != "" {
+		return message
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed {
+			return condition.Reason + ": " + condition.Message
+		}
+	}
+	return "unknown failure"
+}
+
+// terminationMessage returns the termination message of the first container of a finished
+// drill pod that succeeded or failed as asked
+func (d *Drill) terminationMessage(ctx context.Context, job *batchv1.Job, succeeded bool) (string, error) {
+	var pods corev1.PodList
+	if err := d.Client.List(ctx, &pods, client.InNamespace(job.Namespace), client.MatchingLabels{Label: job.Name}); err != nil {
+		return "", err
+	}
+	want := corev1.PodFailed
+	if succeeded {
+		want = corev1.PodSucceeded
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != want {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Terminated != nil {
+				return strings.TrimSpace(status.State.Terminated.Message), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no %s pod of Job %s", strings.ToLower(string(want)), job.Name)
+}
+
+// evaluate compares a drill result with the values recorded for the backup
+func evaluate(result, expected Result) (metav1.ConditionStatus, string, string) {
+	summary := fmt.Sprintf("%d rows, checksum %q", result.Rows, result.Checksum)
+	switch {
+	case expected.Checksum != "" && result.Checksum != expected.Checksum:
+		return metav1.ConditionFalse, ReasonChecksumMismatch, fmt.Sprintf("%s, expected checksum %q", summary, expected.Checksum)
+	case expected.Rows > 0 && result.Rows != expected.Rows:
+		return metav1.ConditionFalse, ReasonRowCountMismatch, fmt.Sprintf("%s, expected %d rows", summary, expected.Rows)
+	case result.Rows == 0:
+		return metav1.ConditionFalse, ReasonEmpty, summary
+	}
+	return metav1.ConditionTrue, ReasonVerified, summary
+}
+
+// finishedAt returns when a Job completed or failed, or the zero time while it runs
+func finishedAt(job *batchv1.Job) (time.Time, bool) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return condition.LastTransitionTime.Time, false
+		case batchv1.JobFailed:
+			return condition.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func setCondition(owner client.Object, conditions *[]metav1.Condition, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               ConditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: owner.GetGeneration(),
+	})
+}
@@ -0,0 +1,91 @@
+package errors
+
+import (
+	stderrors "errors"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Condition reasons set by Classify
+const (
+	ReasonTransientError     = "TransientError"
+	ReasonDependencyNotReady = "DependencyNotReady"
+	ReasonInvalidSpec        = "InvalidSpec"
+	ReasonTerminalError      = "TerminalError"
+)
+
+// Outcome is how a reconcile error is surfaced
+type Outcome struct {
+	// Result and Err are returned from Reconcile
+	Result ctrl.Result
+	Err    error
+
+	// Reason and Message are set on the Ready condition
+	Reason  string
+	Message string
+
+	// EventType is corev1.EventTypeNormal or corev1.EventTypeWarning
+	EventType string
+}
+
+// Classify maps err to its Outcome. It looks through wrapping, so an error wrapped
+// with fmt.Errorf("...: %w", err) keeps its classification.
+func Classify(err error) Outcome {
+	var (
+		transient  *TransientError
+		dependency *DependencyNotReadyError
+		invalid    *ValidationError
+		terminal   *TerminalError
+	)
+
+	switch {
+	case stderrors.As(err, &dependency):
+		return Outcome{
+			Result:    ctrl.Result{RequeueAfter: dependency.RetryAfter},
+			Reason:    ReasonDependencyNotReady,
+			Message:   err.Error(),
+			EventType: corev1.EventTypeNormal,
+		}
+
+	case stderrors.As(err, &invalid):
+		// reconcile.TerminalError is logged and counted but never requeued
+		return Outcome{
+			Err:       reconcile.TerminalError(err),
+			Reason:    ReasonInvalidSpec,
+			Message:   err.Error(),
+			EventType: corev1.EventTypeWarning,
+		}
+
+	case stderrors.As(err, &terminal):
+		reason := terminal.Reason
+		if reason == "" {
+			reason = ReasonTerminalError
+		}
+		return Outcome{
+			Err:       reconcile.TerminalError(err),
+			Reason:    reason,
+			Message:   err.Error(),
+			EventType: corev1.EventTypeWarning,
+		}
+
+	case stderrors.As(err, &transient) && transient.RetryAfter > 0:
+		// Returning the error would make controller-runtime ignore RequeueAfter
+		return Outcome{
+			Result:    ctrl.Result{RequeueAfter: transient.RetryAfter},
+			Reason:    ReasonTransientError,
+			Message:   err.Error(),
+			EventType: corev1.EventTypeWarning,
+		}
+
+	default:
+		// Unclassified errors are treated as transient and retried with backoff
+		return Outcome{
+			Err:       err,
+			Reason:    ReasonTransientError,
+			Message:   err.Error(),
+			EventType: corev1.EventTypeWarning,
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package errors classifies reconcile failures so they are handled the same way everywhere.
+//
+// Without a taxonomy every call site decides on its own whether to return the error,
+// requeue, set a condition or emit an event, and the decisions drift apart. Instead,
+// reconcile logic wraps failures in one of four types and the Reconciler wrapper in this
+// package maps them uniformly:
+//
+//	Type                Requeue                          Ready condition           Event
+//	TransientError      rate-limited backoff or After    False/TransientError      Warning
+//	DependencyNotReady  after RetryAfter, no backoff     False/DependencyNotReady  Normal
+//	ValidationError     none, a spec change re-triggers  False/InvalidSpec         Warning
+//	TerminalError       none                             False/<Reason>            Warning
+//
+// Errors that are not wrapped are treated as transient.
+//
+// Import it under an alias to keep the standard library's errors available:
+//
+//	import reconcileerrors "my.domain/myproject/pkg/errors"
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"time"
+)
+
+// DefaultDependencyRetry is how long to wait for a dependency when none is given
+const DefaultDependencyRetry = 10 * time.Second
+
+// TransientError is a failure expected to go away on its own (API server hiccup, timeout, conflict)
+type TransientError struct {
+	Err error
+
+	// RetryAfter requeues after a fixed delay instead of the workqueue's exponential backoff
+	RetryAfter time.Duration
+}
+
+func (e *TransientError) Error() string { return e.Err.Error() }
+func (e *TransientError) Unwrap() error { return e.Err }
+
+// TerminalError is a failure retrying cannot fix (unsupported configuration, resource gone for good)
+type TerminalError struct {
+	Err error
+
+	// Reason is the condition reason, e.g. "UnsupportedVersion"
+	Reason string
+}
+
+func (e *TerminalError) Error() string { return e.Err.Error() }
+func (e *TerminalError) Unwrap() error { return e.Err }
+
+// DependencyNotReadyError means something the resource needs exists but is not ready yet.
+// This is normal during startup and is not reported as a failure.
+type DependencyNotReadyError struct {
+	// Dependency names what is being waited for, e.g. "Secret db-credentials"
+	Dependency string
+
+	// RetryAfter is how long to wait before checking again
+	RetryAfter time.Duration
+}
+
+func (e *DependencyNotReadyError) Error() string {
+	return fmt.Sprintf("waiting for %s to become ready", e.Dependency)
+}
+
+// ValidationError means the spec is invalid. Only the user can fix it, by changing the
+// spec, which triggers a new reconcile, so there is nothing to retry.
+type ValidationError struct {
+	// Field is the path of the offending field, e.g. "spec.replicas"
+	Field string
+
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Transient wraps err as a TransientError retried with backoff
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TransientError{Err: err}
+}
+
+// TransientAfter wraps err as a TransientError retried after a fixed delay
+func TransientAfter(err error, after time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &TransientError{Err: err, RetryAfter: after}
+}
+
+// Terminal wraps err as a TerminalError with the given condition reason
+func Terminal(reason string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TerminalError{Err: err, Reason: reason}
+}
+
+// DependencyNotReady reports that dependency is not ready; zero after means DefaultDependencyRetry
+func DependencyNotReady(dependency string, after time.Duration) error {
+	if after <= 0 {
+		after = DefaultDependencyRetry
+	}
+	return &DependencyNotReadyError{Dependency: dependency, RetryAfter: after}
+}
+
+// Invalid reports an invalid spec field
+func Invalid(field, format string, args ...interface{}) error {
+	return &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// IsTerminal reports whether err, or any error it wraps, is terminal or a validation failure
+func IsTerminal(err error) bool {
+	var terminal *TerminalError
+	var invalid *ValidationError
+	return stderrors.As(err, &terminal) || stderrors.As(err, &invalid)
+}
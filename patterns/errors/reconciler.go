@@ -0,0 +1,97 @@
+package errors
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Object is a resource whose status carries conditions
+type Object interface {
+	client.Object
+	GetConditions() []metav1.Condition
+	SetConditions(conditions []metav1.Condition)
+}
+
+// Reconciler fetches the object, runs Do and maps its error to requeue behavior,
+// the Ready condition and an event. Do only decides what went wrong:
+//
+//	func (r *MyResourceReconciler) reconcile(ctx context.Context, obj *v1.MyResource) (ctrl.Result, error) {
+//		if obj.Spec.Replicas > 10 {
+//			return ctrl.Result{}, errors.Invalid("spec.replicas", "must be at most 10, got %d", obj.Spec.Replicas)
+//		}
+//		if !secretReady {
+//			return ctrl.Result{}, errors.DependencyNotReady("Secret "+obj.Spec.SecretName, 0)
+//		}
+//		...
+//	}
+//
+//	ctrl.NewControllerManagedBy(mgr).For(&v1.MyResource{}).Complete(&errors.Reconciler[*v1.MyResource]{
+//		Client:    mgr.GetClient(),
+//		Recorder:  broadcaster.Recorder("myresource-controller"), // see patterns/events
+//		NewObject: func() *v1.MyResource { return &v1.MyResource{} },
+//		Do:        r.reconcile,
+//	})
+type Reconciler[T Object] struct {
+	Client   client.Client
+	Recorder events.EventRecorder
+
+	// NewObject returns an empty object to read the request into
+	NewObject func() T
+
+	// Do reconciles the fetched object. On success it should set the Ready condition itself.
+	Do func(ctx context.Context, obj T) (ctrl.Result, error)
+
+	// ConditionType is the condition set on failure; empty means "Ready"
+	ConditionType string
+}
+
+// Reconcile implements reconcile.Reconciler
+func (r *Reconciler[T]) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	obj := r.NewObject()
+	if err := r.Client.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	result, err := r.Do(ctx, obj)
+	if err == nil {
+		return result, nil
+	}
+
+	outcome := Classify(err)
+	r.report(ctx, obj, outcome)
+	return outcome.Result, outcome.Err
+}
+
+// report sets the condition and emits the event for a failed reconcile.
+// Failing to report must not change the outcome, so errors are only logged.
+func (r *Reconciler[T]) report(ctx context.Context, obj T, outcome Outcome) {
+	conditionType := r.ConditionType
+	if conditionType == "" {
+		conditionType = "Ready"
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	conditions := obj.GetConditions()
+	meta.SetStatusCondition(&conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionFalse,
+		Reason:             outcome.Reason,
+		Message:            outcome.Message,
+		ObservedGeneration: obj.GetGeneration(),
+	})
+	obj.SetConditions(conditions)
+
+	if err := r.Client.Status().Patch(ctx, obj, patch); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update condition", "reason", outcome.Reason)
+	}
+
+	if r.Recorder != nil {
+		r.Recorder.Eventf(obj, nil, outcome.EventType, outcome.Reason, "Reconcile", "%s", outcome.Message)
+	}
+}
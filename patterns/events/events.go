@@ -0,0 +1,104 @@
+// Package events emits Kubernetes Events through the events.k8s.io/v1 API.
+//
+// The legacy recorder returned by mgr.GetEventRecorderFor writes core/v1 Events and only
+// aggregates by bumping a counter on the client. A controller that reports the same problem
+// on every reconcile still creates a new Event object every few minutes, and an operator
+// managing thousands of objects can flood etcd during an outage. The events/v1 recorder
+// groups isomorphic events into a series instead: the first occurrence creates the Event,
+// repeats within six minutes only increment series.count, and the series is patched on a
+// heartbeat rather than on every occurrence.
+//
+// Create one Broadcaster per manager and one recorder per controller:
+//
+//	broadcaster, err := events.NewForManager(mgr)
+//	if err != nil {
+//		return err
+//	}
+//	reconciler := &MyResourceReconciler{
+//		Client:   mgr.GetClient(),
+//		Recorder: broadcaster.Recorder("myresource-controller"),
+//	}
+//
+// An events/v1 Event names what the controller did (action) and may reference a second,
+// related object, e.g. the child that failed:
+//
+//	r.Recorder.Eventf(obj, deployment, corev1.EventTypeWarning, "RolloutStalled", "UpdateDeployment",
+//		"Deployment has not progressed for %s", progressDeadline)
+//
+// Occurrences are only grouped when regarding, related, type, reason, action and the
+// formatted note are all equal. Keep notes stable: a note that embeds an elapsed time, a
+// timestamp or a retry count is different on every reconcile and starts a new Event each time.
+//
+// events.k8s.io/v1 is served by Kubernetes 1.19 and later. Reading Events with
+// kubectl describe or kubectl get events works for both APIs.
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+//+kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch;update
+
+// Broadcaster sends the events of its recorders to the API server. It is a manager
+// Runnable; events recorded before the manager starts are dropped.
+type Broadcaster struct {
+	broadcaster events.EventBroadcaster
+	scheme      *runtime.Scheme
+}
+
+var _ manager.LeaderElectionRunnable = &Broadcaster{}
+
+// New returns a Broadcaster writing to the cluster cfg points at. scheme resolves the kind
+// of typed objects, so it must contain the operator's own API types.
+func New(cfg *rest.Config, scheme *runtime.Scheme) (*Broadcaster, error) {
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create events client: %w", err)
+	}
+	return &Broadcaster{
+		broadcaster: events.NewBroadcaster(&events.EventSinkImpl{Interface: clientset.EventsV1()}),
+		scheme:      scheme,
+	}, nil
+}
+
+// NewForManager returns a Broadcaster for the manager's cluster and scheme and adds it to
+// the manager
+func NewForManager(mgr manager.Manager) (*Broadcaster, error) {
+	b, err := New(mgr.GetConfig(), mgr.GetScheme())
+	if err != nil {
+		return nil, err
+	}
+	if err := mgr.Add(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Recorder returns a recorder reporting as controller name. The name is the Event's
+// reportingController and should identify the controller, e.g. "database-controller".
+func (b *Broadcaster) Recorder(name string) events.EventRecorder {
+	return b.broadcaster.NewRecorder(b.scheme, name)
+}
+
+// Start records events until ctx is done
+func (b *Broadcaster) Start(ctx context.Context) error {
+	if err := b.broadcaster.StartRecordingToSinkWithContext(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	b.broadcaster.Shutdown()
+	return nil
+}
+
+// NeedLeaderElection is false: standby replicas record nothing, and a replica that loses
+// leadership should still deliver the events it already recorded
+func (b *Broadcaster) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,186 @@
+// Package external feeds reconcile requests from triggers outside the cluster: a message queue
+// consumer, a webhook receiver or a timer that polls an external service. A change there
+// produces no Kubernetes watch event, so without a trigger the controller only notices it on
+// its next periodic resync.
+//
+// A Source owns a channel and the goroutines producing into it. It runs as a manager
+// Runnable, so producers start with the manager, stop when it shuts down and, by default, run
+// only on the leader, where the controller reading the channel runs:
+//
+//	triggers := &external.Source{
+//		Producers: []external.Producer{
+//			&external.Ticker{Interval: time.Minute, Keys: backupsDueNow},
+//			&external.Receiver{Addr: ":9444", Path: "/hooks/database", Token: os.Getenv("HOOK_TOKEN")},
+//			external.ProducerFunc(consumeQueue),
+//		},
+//	}
+//	if err := mgr.Add(triggers); err != nil {
+//		return err
+//	}
+//
+//	return ctrl.NewControllerManagedBy(mgr).
+//		For(&v1.MyResource{}).
+//		WatchesRawSource(triggers.Channel(), &handler.EnqueueRequestForObject{}).
+//		Complete(r)
+//
+// A queue consumer acknowledges a message only after Enqueue returned, so a trigger is never
+// lost between the queue and the workqueue:
+//
+//	func consumeQueue(ctx context.Context, enqueue external.EnqueueFunc) error {
+//		for msg := range subscription.Messages(ctx) {
+//			if err := enqueue(ctx, types.NamespacedName{Namespace: msg.Namespace, Name: msg.Name}); err != nil {
+//				return err
+//			}
+//			msg.Ack()
+//		}
+//		return ctx.Err()
+//	}
+//
+// The trigger only names the object; the reconciler reads the external state itself, as it
+// would for any other event. Triggers for the same object that arrive before it is reconciled
+// collapse into one request in the workqueue.
+package external
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// DefaultBufferSize is used when Source.BufferSize is zero
+const DefaultBufferSize = 1024
+
+// A failing producer is restarted after InitialRestartDelay, doubling up to MaxRestartDelay
+const (
+	InitialRestartDelay = time.Second
+	MaxRestartDelay     = 5 * time.Minute
+)
+
+// EnqueueFunc requests a reconcile of the named object. It blocks until the request is
+// accepted and fails only when ctx is done.
+type EnqueueFunc func(ctx context.Context, key types.NamespacedName) error
+
+// Producer turns external events into reconcile requests. Run returns when ctx is done; an
+// error before that restarts it with backoff.
+type Producer interface {
+	Run(ctx context.Context, enqueue EnqueueFunc) error
+}
+
+// ProducerFunc adapts a function to a Producer
+type ProducerFunc func(ctx context.Context, enqueue EnqueueFunc) error
+
+// Run calls f
+func (f ProducerFunc) Run(ctx context.Context, enqueue EnqueueFunc) error {
+	return f(ctx, enqueue)
+}
+
+// Source runs Producers and delivers their requests to one controller. It implements
+// manager.Runnable.
+type Source struct {
+	// Producers run concurrently while the Source is started
+	Producers []Producer
+
+	// BufferSize bounds requests waiting for the controller; zero means DefaultBufferSize.
+	// Enqueue blocks while the buffer is full, pushing back on the producer.
+	BufferSize int
+
+	// AllReplicas runs producers on every replica instead of only the leader. Set it for a
+	// Receiver behind a Service that routes to any replica; requests on a standby replica
+	// wait in the buffer until it becomes the leader.
+	AllReplicas bool
+
+	once   sync.Once
+	events chan event.GenericEvent
+}
+
+func (s *Source) init() {
+	s.once.Do(func() {
+		size := s.BufferSize
+		if size <= 0 {
+			size = DefaultBufferSize
+		}
+		s.events = make(chan event.GenericEvent, size)
+	})
+}
+
+// Channel returns the source for the controller's WatchesRawSource. A Source feeds exactly one
+// controller: two channel sources on the same Source would split the requests between them.
+func (s *Source) Channel() source.Source {
+	s.init()
+	return &source.Channel{Source: s.events}
+}
+
+// Enqueue requests a reconcile of the named object
+func (s *Source) Enqueue(ctx context.Context, key types.NamespacedName) error {
+	s.init()
+	// The channel source only reads the name and namespace
+	obj := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+	select {
+	case s.events <- event.GenericEvent{Object: obj}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to enqueue %s: %w", key, ctx.Err())
+	}
+}
+
+// Start runs every producer until ctx is done and waits for them to return. The channel is
+// never closed: a producer stopping late must not send on a closed channel.
+func (s *Source) Start(ctx context.Context) error {
+	s.init()
+	var wg sync.WaitGroup
+	for i, producer := range s.Producers {
+		wg.Add(1)
+		go func(i int, producer Producer) {
+			defer wg.Done()
+			s.run(log.IntoContext(ctx, log.FromContext(ctx).WithValues("producer", i)), producer)
+		}(i, producer)
+	}
+	wg.Wait()
+	return nil
+}
+
+// run restarts a failing producer with exponential backoff. A producer that ran for longer
+// than the maximum backoff starts over at the initial delay.
+func (s *Source) run(ctx context.Context, producer Producer) {
+	logger := log.FromContext(ctx)
+	backoff := restartBackoff()
+	for {
+		started := time.Now()
+		err := producer.Run(ctx, s.Enqueue)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			logger.Info("External event producer finished")
+			return
+		}
+		if time.Since(started) > MaxRestartDelay {
+			backoff = restartBackoff()
+		}
+		delay := backoff.Step()
+		logger.Error(err, "External event producer failed, restarting", "after", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func restartBackoff() wait.Backoff {
+	return wait.Backoff{Duration: InitialRestartDelay, Factor: 2, Jitter: 0.1, Steps: 32, Cap: MaxRestartDelay}
+}
+
+// NeedLeaderElection is true unless AllReplicas is set: the controller reading the channel
+// only runs on the leader
+func (s *Source) NeedLeaderElection() bool {
+	return !s.AllReplicas
+}
@@ -0,0 +1,129 @@
+package external
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Ticker polls an external service on an interval and enqueues the objects it reports, e.g.
+// databases whose backup window opened or whose upstream release changed
+type Ticker struct {
+	// Interval between polls
+	Interval time.Duration
+
+	// Keys returns the objects to reconcile now. A failed poll is logged and retried on the
+	// next tick.
+	Keys func(ctx context.Context) ([]types.NamespacedName, error)
+}
+
+// Run polls until ctx is done
+func (t *Ticker) Run(ctx context.Context, enqueue EnqueueFunc) error {
+	if t.Interval <= 0 {
+		return errors.New("ticker interval must be positive")
+	}
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		keys, err := t.Keys(ctx)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Failed to poll external service")
+			continue
+		}
+		for _, key := range keys {
+			if err := enqueue(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ReceiverRequest is the body a Receiver accepts
+type ReceiverRequest struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Receiver accepts webhook callbacks from an external system, e.g. a CI pipeline announcing a
+// new schema migration. It serves its own listener so the callback endpoint is independent
+// of the admission webhook server and its certificates.
+type Receiver struct {
+	// Addr the server listens on, e.g. ":9444"
+	Addr string
+
+	// Path the callback is posted to; "/" when empty
+	Path string
+
+	// Token, if set, must be sent as "Authorization: Bearer <token>"
+	Token string
+}
+
+// Run serves callbacks until ctx is done and then shuts the server down
+func (r *Receiver) Run(ctx context.Context, enqueue EnqueueFunc) error {
+	path := r.Path
+	if path == "" {
+		path = "/"
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, r.Handler(enqueue))
+	server := &http.Server{Addr: r.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// Handler returns the HTTP handler for callbacks, for mounting on an existing server
+func (r *Receiver) Handler(enqueue EnqueueFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Token != "" {
+			token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(r.Token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		var body ReceiverRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 1<<16)).Decode(&body); err != nil || body.Name == "" {
+			http.Error(w, "expected {\"namespace\": ..., \"name\": ...}", http.StatusBadRequest)
+			return
+		}
+
+		// A full buffer is reported to the caller, which retries, instead of holding the request
+		ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+		defer cancel()
+		if err := enqueue(ctx, types.NamespacedName{Namespace: body.Namespace, Name: body.Name}); err != nil {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
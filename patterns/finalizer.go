@@ -0,0 +1,73 @@
+package patterns
+
+// Finalizer Pattern
+//
+// This file provides the add-finalizer / cleanup / remove-finalizer sequence every reconciler
+// with external state needs, so controllers call one helper instead of repeating it:
+//
+//	func (r *MyResourceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+//		instance := &MyResource{}
+//		if err := r.Get(ctx, req.NamespacedName, instance); err != nil {
+//			return ctrl.Result{}, client.IgnoreNotFound(err)
+//		}
+//		if result, done, err := ReconcileWithFinalizer(ctx, r.Client, instance, myResourceFinalizer, r.finalize); done {
+//			return result, err
+//		}
+//		// instance is live and carries the finalizer
+//		...
+//	}
+//
+// The rules it encodes:
+// - The finalizer is added before anything outside the object is created, or an object
+//   deleted in between leaks it. The Update refreshes the object, so the reconcile goes on with
+//   the new resourceVersion instead of waiting for the requeue its own write triggers.
+// - Cleanup runs on every reconcile of a deleted object until it succeeds, also after the
+//   operator restarted half way, so it must be idempotent: deleting something already gone is
+//   success, not an error.
+// - The finalizer is removed only after cleanup succeeded. Its removal is a patch: while the
+//   object is being deleted no finalizer can be added, and every controller holding one races
+//   to remove its own, so an Update would conflict with each of them for nothing.
+// - A deleted object without the finalizer is left alone: it was never set up, or a previous
+//   reconcile already released it. The API server rejects adding a finalizer to it.
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// CleanupFunc releases what a deleted object holds that owner references do not, e.g. an
+// external database or a cluster-scoped child. Return a non-zero Result to wait for cleanup
+// still in progress, e.g. RequeueAfter while children terminate; the finalizer stays until it
+// returns an empty Result and no error.
+type CleanupFunc[T client.Object] func(ctx context.Context, obj T) (ctrl.Result, error)
+
+// ReconcileWithFinalizer adds finalizerName to a live obj, and runs cleanup and then removes
+// finalizerName once obj is being deleted. It returns done when the reconcile ends here: obj is
+// being deleted, or adding the finalizer failed. Otherwise obj carries the finalizer and the
+// caller reconciles it as usual.
+func ReconcileWithFinalizer[T client.Object](ctx context.Context, c client.Client, obj T, finalizerName string, cleanup CleanupFunc[T]) (ctrl.Result, bool, error) {
+	if obj.GetDeletionTimestamp().IsZero() {
+		if controllerutil.AddFinalizer(obj, finalizerName) {
+			if err := c.Update(ctx, obj); err != nil {
+				return ctrl.Result{}, true, err
+			}
+		}
+		return ctrl.Result{}, false, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(obj, finalizerName) {
+		return ctrl.Result{}, true, nil
+	}
+	result, err := cleanup(ctx, obj)
+	if err != nil || !result.IsZero() {
+		return result, true, err
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	controllerutil.RemoveFinalizer(obj, finalizerName)
+	// The object may be gone as soon as the last finalizer is removed
+	return ctrl.Result{}, true, client.IgnoreNotFound(c.Patch(ctx, obj, patch))
+}
@@ -0,0 +1,147 @@
+// Package generic provides a typed reconciler base that handles the steps every reconciler of
+// a single kind repeats: fetching the object, ignoring requests for deleted objects, a pause
+// annotation, the finalizer, dispatching deletion, and writing the status back.
+//
+// A controller implements Handler for its type and only deals with a fetched, live object:
+//
+//	func (r *CocktailReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+//		return (&generic.Reconciler[*barv1.Cocktail]{
+//			Client:           r.Client,
+//			NewObject:        func() *barv1.Cocktail { return &barv1.Cocktail{} },
+//			Finalizer:        cocktailFinalizer,
+//			PausedAnnotation: cocktailPausedAnnotation,
+//			Handler:          r,
+//		}).Reconcile(ctx, req)
+//	}
+//
+//	func (r *CocktailReconciler) ReconcileNormal(ctx context.Context, cocktail *barv1.Cocktail) (ctrl.Result, error)
+//	func (r *CocktailReconciler) ReconcileDelete(ctx context.Context, cocktail *barv1.Cocktail) (ctrl.Result, error)
+//
+// Handlers change the status in memory only. The base compares it with the status as read and
+// patches the status subresource once, also when the handler failed, so a failure condition is
+// recorded together with the error. A handler therefore never needs Status().Update, and a
+// reconcile that changes nothing writes nothing.
+//
+// Reconciler is a reconcile.Reconciler, so it composes with the middleware package for
+// logging, metrics, panic recovery and timeouts.
+package generic
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Handler holds the type-specific logic of a Reconciler
+type Handler[T client.Object] interface {
+	// ReconcileNormal reconciles a live object. The finalizer, if any, is already in place.
+	ReconcileNormal(ctx context.Context, obj T) (ctrl.Result, error)
+
+	// ReconcileDelete cleans up after an object that is being deleted. The finalizer is
+	// removed once it returns no error and an empty result; return RequeueAfter to wait
+	// for cleanup that is still in progress.
+	ReconcileDelete(ctx context.Context, obj T) (ctrl.Result, error)
+}
+
+// Reconciler reconciles objects of type T with a Handler
+type Reconciler[T client.Object] struct {
+	Client client.Client
+
+	// NewObject returns an empty object to read into
+	NewObject func() T
+
+	// Finalizer is added to every live object and removed after ReconcileDelete. Without a
+	// finalizer ReconcileDelete is never called; owner references clean up instead.
+	Finalizer string
+
+	// PausedAnnotation set to "true" skips reconciling a live object; empty disables pausing.
+	// Deletion is never paused.
+	PausedAnnotation string
+
+	Handler Handler[T]
+}
+
+var _ reconcile.Reconciler = &Reconciler[client.Object]{}
+
+// Reconcile fetches the object and dispatches it to the Handler
+func (r *Reconciler[T]) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	obj := r.NewObject()
+	if err := r.Client.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !obj.GetDeletionTimestamp().IsZero() {
+		return r.reconcileDelete(ctx, obj)
+	}
+
+	if r.PausedAnnotation != "" && obj.GetAnnotations()[r.PausedAnnotation] == "true" {
+		log.FromContext(ctx).V(1).Info("Reconcile paused", "annotation", r.PausedAnnotation)
+		return ctrl.Result{}, nil
+	}
+
+	// Update refreshes obj, so the handler sees the new resourceVersion
+	if r.Finalizer != "" && controllerutil.AddFinalizer(obj, r.Finalizer) {
+		if err := r.Client.Update(ctx, obj); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	original := obj.DeepCopyObject().(T)
+	result, err := r.Handler.ReconcileNormal(ctx, obj)
+	return result, r.flushStatus(ctx, original, obj, err)
+}
+
+// reconcileDelete runs the handler's cleanup and releases the finalizer once it is done
+func (r *Reconciler[T]) reconcileDelete(ctx context.Context, obj T) (ctrl.Result, error) {
+	if r.Finalizer == "" || !controllerutil.ContainsFinalizer(obj, r.Finalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	original := obj.DeepCopyObject().(T)
+	result, err := r.Handler.ReconcileDelete(ctx, obj)
+	if err != nil || !result.IsZero() {
+		// Cleanup is not done; its progress is only visible in the status
+		return result, r.flushStatus(ctx, original, obj, err)
+	}
+
+	controllerutil.RemoveFinalizer(obj, r.Finalizer)
+	// The object may be gone as soon as the last finalizer is removed
+	return ctrl.Result{}, client.IgnoreNotFound(r.Client.Update(ctx, obj))
+}
+
+// flushStatus patches the status if the handler changed it and combines a failed patch with
+// the handler's error
+func (r *Reconciler[T]) flushStatus(ctx context.Context, original, obj T, err error) error {
+	changed, diffErr := statusChanged(original, obj)
+	if diffErr != nil {
+		return kerrors.NewAggregate([]error{err, diffErr})
+	}
+	if !changed {
+		return err
+	}
+	if patchErr := r.Client.Status().Patch(ctx, obj, client.MergeFrom(original)); client.IgnoreNotFound(patchErr) != nil {
+		return kerrors.NewAggregate([]error{err, fmt.Errorf("failed to update status: %w", patchErr)})
+	}
+	return err
+}
+
+// statusChanged compares the status field of two objects of any type
+func statusChanged(original, obj client.Object) (bool, error) {
+	before, err := runtime.DefaultUnstructuredConverter.ToUnstructured(original)
+	if err != nil {
+		return false, err
+	}
+	after, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return false, err
+	}
+	return !reflect.DeepEqual(before["status"], after["status"]), nil
+}
@@ -0,0 +1,24 @@
+package patterns
+
+// API Group Registration Pattern
+//
+// kubebuilder scaffolds this as api/v1/groupversion_info.go. The package markers tell
+// controller-gen to generate DeepCopy methods (zz_generated.deepcopy.go) and the API group of
+// the CRDs; here they are set on the types instead, so the reconcilers of this package are
+// left alone.
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+var (
+	// GroupVersion is group version used to register these objects
+	GroupVersion = schema.GroupVersion{Group: "mygroup.my.domain", Version: "v1"}
+
+	// SchemeBuilder is used to add go types to the GroupVersionKind scheme
+	SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+
+	// AddToScheme adds the types in this group-version to the given scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
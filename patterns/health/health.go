@@ -0,0 +1,117 @@
+// Package health computes the health of a custom resource the way kstatus does, so CLI
+// tools, tests, deployment pipelines and controllers that aggregate the state of other
+// objects all share one definition of "done" and "broken" instead of each reading the
+// conditions its own way.
+//
+// The statuses and rules are those of sigs.k8s.io/cli-utils/pkg/kstatus for custom
+// resources, in order:
+//
+//   - a deletion timestamp means Terminating
+//   - an observedGeneration behind metadata.generation means InProgress: the controller has
+//     not seen the latest spec yet, so nothing else in the status can be trusted
+//   - a Reconciling condition that is True means InProgress
+//   - a Stalled condition that is True means Failed: the controller gave up until the spec
+//     changes
+//   - a Ready condition that is not True means InProgress; errors the controller retries are
+//     not failures
+//   - otherwise the resource is Current
+//
+// Each API package exports a ComputeHealth function for its types, which adds what only
+// the type knows, e.g. a phase recorded before any condition:
+//
+//	// ComputeHealth returns the kstatus health of a MyResource
+//	func ComputeHealth(resource *MyResource) health.Result {
+//		return health.Compute(health.Input{
+//			Object:             resource,
+//			ObservedGeneration: &resource.Status.ObservedGeneration,
+//			Conditions:         resource.Status.Conditions,
+//		})
+//	}
+//
+// Keeping the function next to the type means a CLI only imports the API package, and a
+// change to the conditions a controller writes is reviewed together with what they mean.
+package health
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Status is the health of a resource, with the values kstatus uses
+type Status string
+
+const (
+	// InProgress means the resource is not yet in its desired state, and is expected to get there
+	InProgress Status = "InProgress"
+
+	// Failed means the resource will not get to its desired state without a change
+	Failed Status = "Failed"
+
+	// Current means the resource is in its desired state
+	Current Status = "Current"
+
+	// Terminating means the resource is being deleted
+	Terminating Status = "Terminating"
+)
+
+// Condition types with a meaning in kstatus
+const (
+	ConditionReady       = "Ready"
+	ConditionReconciling = "Reconciling"
+	ConditionStalled     = "Stalled"
+)
+
+// Result is the health of a resource and why
+type Result struct {
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Input is what Compute needs of a resource
+type Input struct {
+	Object metav1.Object
+
+	// ObservedGeneration is the generation the status describes; nil for types that do not
+	// record it
+	ObservedGeneration *int64
+
+	Conditions []metav1.Condition
+}
+
+// Compute returns the health of a resource
+func Compute(in Input) Result {
+	if in.Object.GetDeletionTimestamp() != nil {
+		return Result{Status: Terminating, Message: "Resource scheduled for deletion"}
+	}
+
+	if in.ObservedGeneration != nil && *in.ObservedGeneration < in.Object.GetGeneration() {
+		return Result{Status: InProgress, Message: fmt.Sprintf("Generation is %d, but latest observed generation is %d",
+			in.Object.GetGeneration(), *in.ObservedGeneration)}
+	}
+
+	if condition := meta.FindStatusCondition(in.Conditions, ConditionReconciling); condition != nil &&
+		condition.Status == metav1.ConditionTrue {
+		return Result{Status: InProgress, Message: describe(condition)}
+	}
+	if condition := meta.FindStatusCondition(in.Conditions, ConditionStalled); condition != nil &&
+		condition.Status == metav1.ConditionTrue {
+		return Result{Status: Failed, Message: describe(condition)}
+	}
+	if condition := meta.FindStatusCondition(in.Conditions, ConditionReady); condition != nil {
+		if condition.Status != metav1.ConditionTrue {
+			return Result{Status: InProgress, Message: describe(condition)}
+		}
+		return Result{Status: Current, Message: describe(condition)}
+	}
+	return Result{Status: Current, Message: "Resource is current"}
+}
+
+// describe returns the message of a condition, or its reason if it has none
+func describe(condition *metav1.Condition) string {
+	if condition.Message != "" {
+		return condition.Message
+	}
+	return fmt.Sprintf("%s: %s", condition.Type, condition.Reason)
+}
@@ -0,0 +1,219 @@
+// Package idempotency checks that a reconciler stops changing objects once it has converged.
+//
+// A reconciler must be idempotent: reconciling an object that is already in its desired state
+// must not write anything. Violations are easy to miss in review and cost an API write, a
+// watch event and often another reconcile every time, e.g. a retry-count annotation bumped on
+// every pass (PATTERN 4 in advanced-reconciler.go), a timestamp written to status on every
+// reconcile, or a map rendered into a list in random order.
+//
+// Checker reconciles until nothing changes, snapshots every object of the listed kinds, runs
+// a few more reconciles and fails if any object changed, appeared or disappeared:
+//
+//	func TestMyResourceReconciler_Idempotent(t *testing.T) {
+//		c := fake.NewClientBuilder().WithScheme(scheme).
+//			WithObjects(newMyResource("test")).
+//			WithStatusSubresource(&v1.MyResource{}).
+//			Build()
+//
+//		idempotency.Check(t, idempotency.Checker{
+//			Client:     c,
+//			Reconciler: &MyResourceReconciler{Client: c, Scheme: scheme},
+//			Requests:   []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "test", Namespace: "default"}}},
+//			Lists:      []client.ObjectList{&v1.MyResourceList{}, &appsv1.DeploymentList{}, &corev1.ServiceList{}},
+//		})
+//	}
+//
+// The same test runs against envtest by passing the envtest client. resourceVersion and
+// managedFields are ignored, because the fake client bumps the resourceVersion even for
+// writes that change nothing; fields that legitimately change on every reconcile, such as a
+// heartbeat timestamp, can be removed with Normalize.
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// DefaultMaxRounds bounds the reconciles allowed to reach a stable state
+	DefaultMaxRounds = 10
+
+	// DefaultRounds is the number of reconciles after convergence that must change nothing
+	DefaultRounds = 3
+)
+
+// Checker runs a reconciler to convergence and checks that further reconciles are no-ops
+type Checker struct {
+	// Client reads the snapshots; usually the client the reconciler writes through
+	Client client.Client
+
+	// Reconciler is the reconciler under test
+	Reconciler reconcile.Reconciler
+
+	// Requests are reconciled in order in every round
+	Requests []reconcile.Request
+
+	// Lists are the kinds to snapshot, e.g. the reconciled kind and every kind it creates
+	Lists []client.ObjectList
+
+	// MaxRounds is the number of rounds allowed to converge; zero means DefaultMaxRounds
+	MaxRounds int
+
+	// Rounds is the number of rounds after convergence; zero means DefaultRounds
+	Rounds int
+
+	// Normalize, if set, is applied to every object before it is compared
+	Normalize func(obj *unstructured.Unstructured)
+}
+
+// Check runs the checker and fails the test with the changed objects
+func Check(t testing.TB, c Checker) {
+	t.Helper()
+	if err := c.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// snapshot maps "group/Kind namespace/name" to the normalized object
+type snapshot map[string]map[string]interface{}
+
+// Check reconciles until a round changes nothing, then runs Rounds more rounds and returns an
+// error describing every object they changed
+func (c *Checker) Check(ctx context.Context) error {
+	maxRounds := c.MaxRounds
+	if maxRounds <= 0 {
+		maxRounds = DefaultMaxRounds
+	}
+	rounds := c.Rounds
+	if rounds <= 0 {
+		rounds = DefaultRounds
+	}
+
+	before, err := c.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	converged := false
+	var lastDiff string
+	for i := 0; i < maxRounds && !converged; i++ {
+		requeue, err := c.round(ctx)
+		after, snapErr := c.snapshot(ctx)
+		if snapErr != nil {
+			return snapErr
+		}
+		lastDiff = diff(before, after)
+		converged = err == nil && !requeue && lastDiff == ""
+		before = after
+	}
+	if !converged {
+		return fmt.Errorf("reconciler did not converge within %d rounds; last round changed:\n%s", maxRounds, lastDiff)
+	}
+
+	for i := 1; i <= rounds; i++ {
+		if _, err := c.round(ctx); err != nil {
+			return fmt.Errorf("reconcile %d after convergence failed: %w", i, err)
+		}
+		after, err := c.snapshot(ctx)
+		if err != nil {
+			return err
+		}
+		if d := diff(before, after); d != "" {
+			return fmt.Errorf("reconcile %d after convergence changed objects, the reconciler is not idempotent:\n%s", i, d)
+		}
+	}
+	return nil
+}
+
+// round reconciles every request once and reports whether any asked for an immediate requeue.
+// Periodic requeues (RequeueAfter) do not count, a converged reconciler may still poll.
+func (c *Checker) round(ctx context.Context) (bool, error) {
+	requeue := false
+	for _, req := range c.Requests {
+		result, err := c.Reconciler.Reconcile(ctx, req)
+		if err != nil {
+			return false, fmt.Errorf("reconcile %s: %w", req.NamespacedName, err)
+		}
+		requeue = requeue || result.Requeue
+	}
+	return requeue, nil
+}
+
+// snapshot lists every object of the checked kinds
+func (c *Checker) snapshot(ctx context.Context) (snapshot, error) {
+	snap := snapshot{}
+	for _, list := range c.Lists {
+		list = list.DeepCopyObject().(client.ObjectList)
+		if err := c.Client.List(ctx, list); err != nil {
+			return nil, fmt.Errorf("failed to list %T: %w", list, err)
+		}
+		gvk, err := apiutil.GVKForObject(list, c.Client.Scheme())
+		if err != nil {
+			return nil, err
+		}
+		kind := strings.TrimSuffix(gvk.Kind, "List")
+
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(item)
+			if err != nil {
+				return nil, err
+			}
+			obj := &unstructured.Unstructured{Object: content}
+			unstructured.RemoveNestedField(obj.Object, "metadata", "resourceVersion")
+			unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+			if c.Normalize != nil {
+				c.Normalize(obj)
+			}
+			snap[fmt.Sprintf("%s/%s %s/%s", gvk.Group, kind, obj.GetNamespace(), obj.GetName())] = obj.Object
+		}
+	}
+	return snap, nil
+}
+
+// diff describes the objects that were created, deleted or changed between two snapshots
+func diff(before, after snapshot) string {
+	keys := map[string]struct{}{}
+	for key := range before {
+		keys[key] = struct{}{}
+	}
+	for key := range after {
+		keys[key] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var b strings.Builder
+	for _, key := range sorted {
+		old, existed := before[key]
+		current, exists := after[key]
+		switch {
+		case !existed:
+			fmt.Fprintf(&b, "created %s\n", key)
+		case !exists:
+			fmt.Fprintf(&b, "deleted %s\n", key)
+		default:
+			if d := cmp.Diff(old, current); d != "" {
+				fmt.Fprintf(&b, "changed %s (-before +after):\n%s", key, d)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package idempotency
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// configMapReconciler copies the "source" key of a ConfigMap into a "-copy" ConfigMap and,
+// when countPasses is set, counts its passes in an annotation like a retry counter does
+type configMapReconciler struct {
+	client.Client
+	countPasses bool
+}
+
+func (r *configMapReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	source := &corev1.ConfigMap{}
+	if err := r.Get(ctx, req.NamespacedName, source); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if r.countPasses {
+		passes, _ := strconv.Atoi(source.Annotations["test.my.domain/passes"])
+		if source.Annotations == nil {
+			source.Annotations = map[string]string{}
+		}
+		source.Annotations["test.my.domain/passes"] = strconv.Itoa(passes + 1)
+		if err := r.Update(ctx, source); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	copied := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: source.Name + "-copy", Namespace: source.Namespace}}
+	err := r.Get(ctx, client.ObjectKeyFromObject(copied), copied)
+	if client.IgnoreNotFound(err) != nil {
+		return reconcile.Result{}, err
+	}
+	copied.Data = map[string]string{"source": source.Data["source"]}
+	if err != nil {
+		return reconcile.Result{Requeue: true}, r.Create(ctx, copied)
+	}
+	return reconcile.Result{}, r.Update(ctx, copied)
+}
+
+func newChecker(countPasses bool) Checker {
+	c := fake.NewClientBuilder().
+		WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Data:       map[string]string{"source": "value"},
+		}).
+		Build()
+
+	return Checker{
+		Client:     c,
+		Reconciler: &configMapReconciler{Client: c, countPasses: countPasses},
+		Requests:   []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "test", Namespace: "default"}}},
+		Lists:      []client.ObjectList{&corev1.ConfigMapList{}},
+	}
+}
+
+func TestChecker_Idempotent(t *testing.T) {
+	// The copy is updated on every pass, but with the same content
+	Check(t, newChecker(false))
+}
+
+func TestChecker_NotIdempotent(t *testing.T) {
+	checker := newChecker(true)
+	err := checker.Check(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "did not converge")
+	assert.Contains(t, err.Error(), "changed /ConfigMap default/test")
+}
+
+func TestChecker_Normalize(t *testing.T) {
+	checker := newChecker(true)
+	checker.Normalize = func(obj *unstructured.Unstructured) {
+		annotations := obj.GetAnnotations()
+		delete(annotations, "test.my.domain/passes")
+		obj.SetAnnotations(annotations)
+	}
+	assert.NoError(t, checker.Check(context.Background()))
+}
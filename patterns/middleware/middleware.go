@@ -0,0 +1,228 @@
+// Package middleware composes cross-cutting reconcile concerns as wrappers around a core reconciler.
+//
+// Every controller repeats the same steps before and after its actual work: fetch the object,
+// ignore NotFound, skip paused objects, add or run the finalizer, log, time and recover. Each
+// step here is a Middleware, and Chain stacks them around a core that only sees a fetched,
+// live object:
+//
+//	ctrl.NewControllerManagedBy(mgr).
+//		For(&v1.MyResource{}).
+//		Complete(middleware.Chain(
+//			middleware.Object(mgr.GetClient(), newMyResource, r.reconcile),
+//			middleware.Recover(),
+//			middleware.Logging(),
+//			middleware.Metrics("myresource"),
+//			middleware.Timeout(time.Minute),
+//			middleware.Paused(mgr.GetClient(), newMyResource, "my.domain/paused"),
+//			middleware.Finalizer(mgr.GetClient(), newMyResource, "my.domain/finalizer", r.cleanup),
+//		))
+//
+// The first middleware is the outermost. Recover goes first so that a panic anywhere in the
+// chain is turned into an error before the others unwind; Paused and Finalizer go last because
+// they end the reconcile early and the outer middlewares should still see that.
+//
+// Any existing wrapper is a Middleware already, e.g. the slow-reconcile tracing from
+// patterns/tracing:
+//
+//	func(next reconcile.Reconciler) reconcile.Reconciler {
+//		return &tracing.Reconciler{Name: "myresource", Reconciler: next, Threshold: time.Second}
+//	}
+//
+// Paused, Finalizer and Object share one Get per reconcile: the first of them to read the
+// object keeps it in the context for the others.
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Middleware wraps a reconciler with behavior that runs around it
+type Middleware func(next reconcile.Reconciler) reconcile.Reconciler
+
+// Chain wraps core in middlewares, the first one outermost
+func Chain(core reconcile.Reconciler, middlewares ...Middleware) reconcile.Reconciler {
+	r := core
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		r = middlewares[i](r)
+	}
+	return r
+}
+
+// reconcileDuration is the reconcile latency by outcome. controller-runtime's
+// controller_runtime_reconcile_time_seconds has no outcome label, so slow failures and slow
+// successes cannot be told apart there.
+var reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "controller_reconcile_outcome_duration_seconds",
+	Help:    "Reconcile latency by controller and outcome (success, requeue, error).",
+	Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+}, []string{"controller", "outcome"})
+
+func init() {
+	metrics.Registry.MustRegister(reconcileDuration)
+}
+
+// Logging logs the start and the result of every reconcile at debug level. The logger from
+// controller-runtime already carries the controller, the object and the reconcile ID; errors
+// are logged by controller-runtime as well, so only their duration is added here.
+func Logging() Middleware {
+	return func(next reconcile.Reconciler) reconcile.Reconciler {
+		return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+			logger := log.FromContext(ctx)
+			logger.V(1).Info("Reconcile started")
+
+			start := time.Now()
+			result, err := next.Reconcile(ctx, req)
+			elapsed := time.Since(start)
+
+			if err != nil {
+				logger.V(1).Info("Reconcile failed", "duration", elapsed.String(), "error", err.Error())
+			} else {
+				logger.V(1).Info("Reconcile finished", "duration", elapsed.String(),
+					"requeue", result.Requeue, "requeueAfter", result.RequeueAfter.String())
+			}
+			return result, err
+		})
+	}
+}
+
+// Metrics records the reconcile latency by outcome for the named controller
+func Metrics(controller string) Middleware {
+	return func(next reconcile.Reconciler) reconcile.Reconciler {
+		return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+			start := time.Now()
+			result, err := next.Reconcile(ctx, req)
+
+			outcome := "success"
+			switch {
+			case err != nil:
+				outcome = "error"
+			case result.Requeue || result.RequeueAfter > 0:
+				outcome = "requeue"
+			}
+			reconcileDuration.WithLabelValues(controller, outcome).Observe(time.Since(start).Seconds())
+			return result, err
+		})
+	}
+}
+
+// Recover turns a panic in the wrapped reconciler into an error, so the request is retried
+// with backoff instead of crashing the manager. controller-runtime only recovers panics when
+// RecoverPanic is set, and then outside of every middleware.
+func Recover() Middleware {
+	return func(next reconcile.Reconciler) reconcile.Reconciler {
+		return reconcile.Func(func(ctx context.Context, req reconcile.Request) (result reconcile.Result, err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					log.FromContext(ctx).Error(fmt.Errorf("%v", p), "Recovered from panic in reconcile",
+						"stack", string(debug.Stack()))
+					result, err = reconcile.Result{}, fmt.Errorf("panic in reconcile: %v", p)
+				}
+			}()
+			return next.Reconcile(ctx, req)
+		})
+	}
+}
+
+// Timeout bounds a reconcile. Client calls and anything else that honours the context return
+// once the deadline passes, so one stuck external call does not hold a worker indefinitely.
+func Timeout(d time.Duration) Middleware {
+	return func(next reconcile.Reconciler) reconcile.Reconciler {
+		return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			result, err := next.Reconcile(ctx, req)
+			if err != nil && ctx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("reconcile timed out after %s: %w", d, err)
+			}
+			return result, err
+		})
+	}
+}
+
+// Paused skips the reconcile while the object has annotation set to "true", e.g. during
+// manual maintenance. Pausing does not block deletion: the finalizer still runs.
+func Paused[T client.Object](c client.Reader, newObject func() T, annotation string) Middleware {
+	return func(next reconcile.Reconciler) reconcile.Reconciler {
+		return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+			obj, ctx, err := fetch(ctx, c, req, newObject)
+			if err != nil {
+				return reconcile.Result{}, client.IgnoreNotFound(err)
+			}
+			if obj.GetAnnotations()[annotation] == "true" && obj.GetDeletionTimestamp().IsZero() {
+				log.FromContext(ctx).V(1).Info("Reconcile paused", "annotation", annotation)
+				return reconcile.Result{}, nil
+			}
+			return next.Reconcile(ctx, req)
+		})
+	}
+}
+
+// Finalizer adds finalizer to the object before the wrapped reconciler runs. Once the object
+// is being deleted it runs cleanup instead and removes the finalizer when cleanup succeeds.
+func Finalizer[T client.Object](c client.Client, newObject func() T, finalizer string, cleanup func(ctx context.Context, obj T) error) Middleware {
+	return func(next reconcile.Reconciler) reconcile.Reconciler {
+		return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+			obj, ctx, err := fetch(ctx, c, req, newObject)
+			if err != nil {
+				return reconcile.Result{}, client.IgnoreNotFound(err)
+			}
+
+			if !obj.GetDeletionTimestamp().IsZero() {
+				if !controllerutil.ContainsFinalizer(obj, finalizer) {
+					return reconcile.Result{}, nil
+				}
+				if err := cleanup(ctx, obj); err != nil {
+					return reconcile.Result{}, fmt.Errorf("failed to clean up: %w", err)
+				}
+				controllerutil.RemoveFinalizer(obj, finalizer)
+				return reconcile.Result{}, client.IgnoreNotFound(c.Update(ctx, obj))
+			}
+
+			// Update refreshes obj, and the reconciler below sees the new resourceVersion
+			if controllerutil.AddFinalizer(obj, finalizer) {
+				if err := c.Update(ctx, obj); err != nil {
+					return reconcile.Result{}, err
+				}
+			}
+			return next.Reconcile(ctx, req)
+		})
+	}
+}
+
+// Object adapts a function of the fetched object into the core reconciler of a chain.
+// Requests for objects that no longer exist end here without an error.
+func Object[T client.Object](c client.Reader, newObject func() T, do func(ctx context.Context, obj T) (reconcile.Result, error)) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		obj, ctx, err := fetch(ctx, c, req, newObject)
+		if err != nil {
+			return reconcile.Result{}, client.IgnoreNotFound(err)
+		}
+		return do(ctx, obj)
+	})
+}
+
+type objectKey struct{}
+
+// fetch returns the object of the request, reading it only if an outer middleware has not
+// already done so, and a context that carries it for the inner ones
+func fetch[T client.Object](ctx context.Context, c client.Reader, req reconcile.Request, newObject func() T) (T, context.Context, error) {
+	if obj, ok := ctx.Value(objectKey{}).(T); ok {
+		return obj, ctx, nil
+	}
+	obj := newObject()
+	if err := c.Get(ctx, req.NamespacedName, obj); err != nil {
+		return obj, ctx, err
+	}
+	return obj, context.WithValue(ctx, objectKey{}, obj), nil
+}
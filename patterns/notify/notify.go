@@ -0,0 +1,255 @@
+// Package notify forwards significant operator events to people: a chat channel, an incident
+// webhook or just the operator log. Events in the cluster are short-lived and rarely watched;
+// a failed backup or a completed failover should reach someone without them running
+// kubectl describe.
+//
+// A Notifier runs as a manager Runnable. It selects notifications by type and reason,
+// suppresses repeats of the same reason for the same object, renders the message from a
+// template and delivers it to every sink without blocking the reconciler:
+//
+//	notifier := &notify.Notifier{
+//		Sinks: []notify.Sink{
+//			&notify.SlackSink{WebhookURL: os.Getenv("SLACK_WEBHOOK_URL")},
+//			&notify.StdoutSink{},
+//		},
+//		Reasons: []string{"BackupFailed", "FailoverCompleted", "Stalled"},
+//	}
+//	if err := mgr.Add(notifier); err != nil {
+//		return err
+//	}
+//
+// Warning events reach the Notifier by wrapping the controller's event recorder, so existing
+// r.Recorder.Event calls need no change:
+//
+//	reconciler.Recorder = notify.Recorder(broadcaster.Recorder("myresource-controller"), notifier)
+//
+// Phase transitions that are not events are sent directly:
+//
+//	if previous != obj.Status.Phase {
+//		notifier.Notify(notify.ForObject(obj, corev1.EventTypeNormal, "Phase"+obj.Status.Phase,
+//			"phase changed from "+previous))
+//	}
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Defaults used when the corresponding Notifier field is zero
+const (
+	DefaultRepeatInterval = 15 * time.Minute
+	DefaultQueueSize      = 100
+	DefaultTemplate       = `[{{ .Type }}] {{ .Object.Kind }} {{ with .Object.Namespace }}{{ . }}/{{ end }}{{ .Object.Name }}: ` +
+		`{{ .Reason }}: {{ .Message }}{{ if .Suppressed }} ({{ .Suppressed }} similar suppressed){{ end }}`
+)
+
+// ObjectReference identifies the object a notification is about
+type ObjectReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// Notification is a significant event about an object
+type Notification struct {
+	Object ObjectReference `json:"object"`
+
+	// Type is corev1.EventTypeNormal or corev1.EventTypeWarning
+	Type    string    `json:"type"`
+	Reason  string    `json:"reason"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+
+	// Suppressed counts notifications with the same object and reason dropped since the
+	// last one that was sent
+	Suppressed int `json:"suppressed,omitempty"`
+
+	// Text is the rendered message; set by the Notifier before the sinks see it
+	Text string `json:"text"`
+}
+
+// ForObject builds a notification about obj
+func ForObject(obj client.Object, eventType, reason, message string) Notification {
+	return Notification{
+		Object: ObjectReference{
+			Kind:      obj.GetObjectKind().GroupVersionKind().Kind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+		},
+		Type:    eventType,
+		Reason:  reason,
+		Message: message,
+		Time:    time.Now(),
+	}
+}
+
+// Sink delivers notifications. Send is called from a single goroutine.
+type Sink interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// Notifier selects, rate limits, renders and delivers notifications
+type Notifier struct {
+	// Sinks receive every notification that is sent
+	Sinks []Sink
+
+	// Reasons are always forwarded, whatever their type
+	Reasons []string
+
+	// IncludeWarnings forwards every Warning in addition to Reasons
+	IncludeWarnings bool
+
+	// RepeatInterval is the minimum time between two notifications with the same object and
+	// reason; zero means DefaultRepeatInterval
+	RepeatInterval time.Duration
+
+	// Limit caps notifications per second across all objects, protecting the sinks during
+	// an incident that affects many objects; zero means no limit
+	Limit rate.Limit
+
+	// Template renders Notification.Text; empty means DefaultTemplate
+	Template string
+
+	// QueueSize bounds notifications waiting for delivery; zero means DefaultQueueSize.
+	// Notify drops notifications when the queue is full.
+	QueueSize int
+
+	once     sync.Once
+	initErr  error
+	tmpl     *template.Template
+	queue    chan Notification
+	limiter  *rate.Limiter
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+	dropped  map[string]int
+}
+
+func (n *Notifier) init() error {
+	n.once.Do(func() {
+		text := n.Template
+		if text == "" {
+			text = DefaultTemplate
+		}
+		n.tmpl, n.initErr = template.New("notification").Option("missingkey=error").Parse(text)
+
+		size := n.QueueSize
+		if size <= 0 {
+			size = DefaultQueueSize
+		}
+		n.queue = make(chan Notification, size)
+		if n.Limit > 0 {
+			n.limiter = rate.NewLimiter(n.Limit, 1)
+		}
+		n.lastSent = map[string]time.Time{}
+		n.dropped = map[string]int{}
+	})
+	return n.initErr
+}
+
+// Selected reports whether a notification of this type and reason is forwarded
+func (n *Notifier) Selected(eventType, reason string) bool {
+	if n.IncludeWarnings && eventType == corev1.EventTypeWarning {
+		return true
+	}
+	for _, r := range n.Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify queues a notification for delivery. It never blocks: notifications that are not
+// selected, repeat a recent one or find the queue full are dropped.
+func (n *Notifier) Notify(notification Notification) {
+	if n.init() != nil || !n.Selected(notification.Type, notification.Reason) {
+		return
+	}
+	if notification.Time.IsZero() {
+		notification.Time = time.Now()
+	}
+
+	interval := n.RepeatInterval
+	if interval <= 0 {
+		interval = DefaultRepeatInterval
+	}
+	key := notification.Object.Kind + "/" + notification.Object.Namespace + "/" +
+		notification.Object.Name + "/" + notification.Reason
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if last, ok := n.lastSent[key]; ok && notification.Time.Sub(last) < interval {
+		n.dropped[key]++
+		return
+	}
+
+	notification.Suppressed = n.dropped[key]
+	select {
+	case n.queue <- notification:
+		n.lastSent[key] = notification.Time
+		delete(n.dropped, key)
+	default:
+		// Delivery is behind; keep counting so the next notification reports it
+		n.dropped[key]++
+	}
+}
+
+// Start delivers queued notifications until ctx is done
+func (n *Notifier) Start(ctx context.Context) error {
+	if err := n.init(); err != nil {
+		return fmt.Errorf("invalid notification template: %w", err)
+	}
+	logger := log.FromContext(ctx).WithName("notify")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case notification := <-n.queue:
+			if n.limiter != nil {
+				if err := n.limiter.Wait(ctx); err != nil {
+					return nil
+				}
+			}
+			if err := n.deliver(ctx, notification); err != nil {
+				logger.Error(err, "Failed to deliver notification", "reason", notification.Reason,
+					"object", notification.Object)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection is false: only the leader reconciles and records events, and
+// notifications queued before losing leadership should still be delivered
+func (n *Notifier) NeedLeaderElection() bool {
+	return false
+}
+
+// deliver renders the notification and sends it to every sink. A failing sink does not
+// keep the others from receiving it.
+func (n *Notifier) deliver(ctx context.Context, notification Notification) error {
+	var buf bytes.Buffer
+	if err := n.tmpl.Execute(&buf, notification); err != nil {
+		return fmt.Errorf("failed to render notification: %w", err)
+	}
+	notification.Text = buf.String()
+
+	var errs []error
+	for _, sink := range n.Sinks {
+		if err := sink.Send(ctx, notification); err != nil {
+			errs = append(errs, fmt.Errorf("%T: %w", sink, err))
+		}
+	}
+	return errors.Join(errs...)
+}
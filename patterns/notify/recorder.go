@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+)
+
+// Recorder returns an events.k8s.io/v1 recorder that records events with inner and also
+// passes the events the notifier selects to it
+func Recorder(inner events.EventRecorder, notifier *Notifier) events.EventRecorder {
+	return &recorder{inner: inner, notifier: notifier}
+}
+
+type recorder struct {
+	inner    events.EventRecorder
+	notifier *Notifier
+}
+
+func (r *recorder) Eventf(regarding runtime.Object, related runtime.Object, eventType, reason, action, note string, args ...interface{}) {
+	r.inner.Eventf(regarding, related, eventType, reason, action, note, args...)
+
+	if !r.notifier.Selected(eventType, reason) {
+		return
+	}
+	accessor, err := meta.Accessor(regarding)
+	if err != nil {
+		return
+	}
+	r.notifier.Notify(Notification{
+		Object: ObjectReference{
+			Kind:      regarding.GetObjectKind().GroupVersionKind().Kind,
+			Namespace: accessor.GetNamespace(),
+			Name:      accessor.GetName(),
+		},
+		Type:    eventType,
+		Reason:  reason,
+		Message: fmt.Sprintf(note, args...),
+		Time:    time.Now(),
+	})
+}
@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DefaultTimeout bounds a single delivery to an HTTP sink
+const DefaultTimeout = 10 * time.Second
+
+// WebhookSink posts every notification as JSON to a URL, e.g. an incident management
+// or chat-ops endpoint. The body is the Notification, including the rendered text.
+type WebhookSink struct {
+	URL string
+
+	// Headers are added to every request, e.g. an Authorization header
+	Headers map[string]string
+
+	// Client defaults to an http.Client with DefaultTimeout
+	Client *http.Client
+}
+
+// Send implements Sink
+func (s *WebhookSink) Send(ctx context.Context, n Notification) error {
+	return postJSON(ctx, s.Client, s.URL, s.Headers, n)
+}
+
+// SlackSink posts the rendered text to a Slack incoming webhook
+type SlackSink struct {
+	WebhookURL string
+
+	// Client defaults to an http.Client with DefaultTimeout
+	Client *http.Client
+}
+
+// Send implements Sink
+func (s *SlackSink) Send(ctx context.Context, n Notification) error {
+	text := n.Text
+	if n.Type == corev1.EventTypeWarning {
+		text = ":warning: " + text
+	}
+	return postJSON(ctx, s.Client, s.WebhookURL, nil, map[string]string{"text": text})
+}
+
+// StdoutSink writes one line per notification, useful during development and as
+// a record of what was sent to the other sinks
+type StdoutSink struct {
+	// Writer defaults to os.Stdout
+	Writer io.Writer
+}
+
+// Send implements Sink
+func (s *StdoutSink) Send(_ context.Context, n Notification) error {
+	w := s.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", n.Time.UTC().Format(time.RFC3339), n.Text)
+	return err
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body interface{}) error {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+// Package operations tracks external operations that take minutes, such as provisioning a
+// cloud database or restoring a large backup, without blocking a reconcile. The operation is
+// started once, its ID is persisted in the owner's status, and every later reconcile polls it
+// until it finishes:
+//
+//	// In the API types
+//	type BucketStatus struct {
+//		Provisioning operations.Status `json:"provisioning,omitempty"`
+//	}
+//
+//	// In Reconcile
+//	result, err := r.Operations.Reconcile(ctx, bucket, &bucket.Status.Conditions,
+//		&bucket.Status.Provisioning, operations.Request{Key: fmt.Sprintf("%s-%d", bucket.UID, bucket.Generation), Kind: "CreateBucket"})
+//	if statusErr := r.Status().Update(ctx, bucket); statusErr != nil {
+//		return ctrl.Result{}, statusErr
+//	}
+//	if err != nil || bucket.Status.Provisioning.Phase != operations.Succeeded {
+//		return result, err
+//	}
+//	// The bucket exists; continue with what depends on it
+//
+// The status update after Reconcile is what makes restarts safe: an operator that restarts,
+// or a new leader, reads the ID back and resumes polling instead of starting the operation
+// again. The window between Start returning and the status write is covered by the Key: the
+// Provider must return the operation already started for a key instead of starting another,
+// which is what the client tokens and idempotency keys of cloud APIs are for.
+//
+// A failed or timed-out operation is terminal for its key. Retrying means a new key, usually
+// from a spec change bumping the generation, so a broken request is not resubmitted forever.
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ConditionType is the condition a Tracker records on its owner
+const ConditionType = "OperationSucceeded"
+
+// Condition reasons
+const (
+	ReasonInProgress = "OperationInProgress"
+	ReasonSucceeded  = "OperationSucceeded"
+	ReasonFailed     = "OperationFailed"
+	ReasonTimedOut   = "OperationTimedOut"
+	ReasonLost       = "OperationLost"
+)
+
+// Defaults used when the corresponding Tracker field is zero
+const (
+	DefaultPollInterval = 30 * time.Second
+	DefaultTimeout      = time.Hour
+)
+
+// Phase is the phase of an operation
+type Phase string
+
+const (
+	Running   Phase = "Running"
+	Succeeded Phase = "Succeeded"
+	Failed    Phase = "Failed"
+)
+
+// ErrNotFound is returned by Provider.Poll for an ID the provider does not know, e.g. because
+// it expired or belongs to another account
+var ErrNotFound = errors.New("operation not found")
+
+// Request identifies the operation an owner needs
+type Request struct {
+	// Key identifies the request; the same key always means the same operation
+	Key string
+
+	// Kind describes the operation in conditions and logs, e.g. CreateBucket or Restore
+	Kind string
+}
+
+// Progress is what a provider reports about an operation
+type Progress struct {
+	Phase Phase
+
+	// Percent is the completion estimate, if the provider has one
+	Percent int32
+
+	// Message describes the current step, or why the operation failed
+	Message string
+}
+
+// Provider starts and polls operations on an external system
+type Provider interface {
+	// Start starts the operation for a request and returns its ID without waiting for it.
+	// It must be idempotent by key: a key it has seen returns the ID it returned before.
+	Start(ctx context.Context, request Request) (string, error)
+
+	// Poll returns the progress of an operation, or ErrNotFound
+	Poll(ctx context.Context, id string) (Progress, error)
+}
+
+// Status is the state of an operation as persisted in the owner's status
+type Status struct {
+	// ID identifies the operation at the provider
+	ID string `json:"id,omitempty"`
+
+	// Key is the request the operation was started for
+	Key string `json:"key,omitempty"`
+
+	Phase   Phase  `json:"phase,omitempty"`
+	Percent int32  `json:"percent,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	StartedAt  *metav1.Time `json:"startedAt,omitempty"`
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+}
+
+// DeepCopyInto copies the status, for API types embedding it
+func (in *Status) DeepCopyInto(out *Status) {
+	*out = *in
+	if in.StartedAt != nil {
+		out.StartedAt = in.StartedAt.DeepCopy()
+	}
+	if in.FinishedAt != nil {
+		out.FinishedAt = in.FinishedAt.DeepCopy()
+	}
+}
+
+// Tracker starts and polls operations
+type Tracker struct {
+	Provider Provider
+
+	// PollInterval between polls of a running operation; DefaultPollInterval when zero
+	PollInterval time.Duration
+
+	// Timeout after which a running operation is given up; DefaultTimeout when zero. The
+	// provider may still finish it, so the owner must not assume nothing happened.
+	Timeout time.Duration
+}
+
+// Reconcile starts the operation for a request or advances the one recorded in status, sets
+// the condition and returns when to poll again. The caller must persist status, also when an
+// error is returned.
+func (t *Tracker) Reconcile(ctx context.Context, owner client.Object, conditions *[]metav1.Condition, status *Status, request Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("operation", request.Kind, "key", request.Key)
+
+	if status.Key != request.Key || status.ID == "" {
+		id, err := t.Provider.Start(ctx, request)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to start %s: %w", request.Kind, err)
+		}
+		now := metav1.Now()
+		*status = Status{ID: id, Key: request.Key, Phase: Running, StartedAt: &now}
+		logger.Info("Started operation", "id", id)
+		setCondition(owner, conditions, metav1.ConditionFalse, ReasonInProgress, fmt.Sprintf("%s %s started", request.Kind, id))
+		// Poll after the ID is persisted, not before, so a crash cannot lose it
+		return ctrl.Result{RequeueAfter: t.pollInterval()}, nil
+	}
+	if status.Phase != Running {
+		return ctrl.Result{}, nil
+	}
+
+	progress, err := t.Provider.Poll(ctx, status.ID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		t.finish(owner, conditions, status, Failed, ReasonLost,
+			fmt.Sprintf("%s %s is no longer known to the provider", request.Kind, status.ID))
+		return ctrl.Result{}, nil
+	case err != nil:
+		return ctrl.Result{}, fmt.Errorf("failed to poll %s %s: %w", request.Kind, status.ID, err)
+	}
+
+	status.Percent, status.Message = progress.Percent, progress.Message
+	switch progress.Phase {
+	case Succeeded:
+		logger.Info("Operation succeeded", "id", status.ID)
+		t.finish(owner, conditions, status, Succeeded, ReasonSucceeded, fmt.Sprintf("%s %s succeeded", request.Kind, status.ID))
+		return ctrl.Result{}, nil
+	case Failed:
+		logger.Info("Operation failed", "id", status.ID, "message", progress.Message)
+		t.finish(owner, conditions, status, Failed, ReasonFailed, fmt.Sprintf("%s %s failed: %s", request.Kind, status.ID, progress.Message))
+		return ctrl.Result{}, nil
+	}
+
+	if status.StartedAt != nil && time.Since(status.StartedAt.Time) > t.timeout() {
+		t.finish(owner, conditions, status, Failed, ReasonTimedOut,
+			fmt.Sprintf("%s %s did not finish within %s", request.Kind, status.ID, t.timeout()))
+		return ctrl.Result{}, nil
+	}
+	message := fmt.Sprintf("%s %s running", request.Kind, status.ID)
+	if progress.Percent > 0 {
+		message = fmt.Sprintf("%s, %d%% done", message, progress.Percent)
+	}
+	setCondition(owner, conditions, metav1.ConditionFalse, ReasonInProgress, message)
+	return ctrl.Result{RequeueAfter: t.pollInterval()}, nil
+}
+
+// finish records the end of an operation
+func (t *Tracker) finish(owner client.Object, conditions *[]metav1.Condition, status *Status, phase Phase, reason, message string) {
+	now := metav1.Now()
+	status.Phase, status.FinishedAt = phase, &now
+	conditionStatus := metav1.ConditionFalse
+	if phase == Succeeded {
+		conditionStatus = metav1.ConditionTrue
+	}
+	setCondition(owner, conditions, conditionStatus, reason, message)
+}
+
+func (t *Tracker) pollInterval() time.Duration {
+	if t.PollInterval <= 0 {
+		return DefaultPollInterval
+	}
+	return t.PollInterval
+}
+
+func (t *Tracker) timeout() time.Duration {
+	if t.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return t.Timeout
+}
+
+func setCondition(owner client.Object, conditions *[]metav1.Condition, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               ConditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: owner.GetGeneration(),
+	})
+}
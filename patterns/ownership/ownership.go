@@ -0,0 +1,195 @@
+// Package ownership links children to their owner when an owner reference is not allowed.
+//
+// The garbage collector only honours owner references that point to an owner in the same
+// namespace or to a cluster-scoped owner. A namespaced owner therefore cannot own a
+// ClusterRoleBinding, a Namespace or an object in another namespace; the API server accepts
+// such a reference but the garbage collector treats the owner as missing and deletes the child.
+// For those children this package records the owner in a tracking label and annotation, and
+// the owner's finalizer deletes them instead of the garbage collector.
+//
+// Register the index once, and let SetOwner pick the right mechanism for each child:
+//
+//	if err := ownership.IndexOwner(ctx, mgr.GetFieldIndexer(), &rbacv1.ClusterRoleBinding{}); err != nil {
+//		return err
+//	}
+//
+//	// in the mutate function of CreateOrUpdate
+//	return ownership.SetOwner(r.Client, tenant, binding)
+//
+// Owns() cannot watch tracked children; map their events back to the owner instead:
+//
+//	Watches(&rbacv1.ClusterRoleBinding{}, ownership.EnqueueOwner(tenantGroupKind))
+//
+// On deletion, keep the finalizer until every tracked child is gone:
+//
+//	if !tenant.DeletionTimestamp.IsZero() {
+//		done, err := ownership.Cleanup(ctx, r.Client, tenant, &rbacv1.ClusterRoleBindingList{})
+//		if err != nil || !done {
+//			return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+//		}
+//		controllerutil.RemoveFinalizer(tenant, ownership.Finalizer)
+//		return ctrl.Result{}, r.Update(ctx, tenant)
+//	}
+//	if controllerutil.AddFinalizer(tenant, ownership.Finalizer) {
+//		return ctrl.Result{}, r.Update(ctx, tenant)
+//	}
+//
+// The finalizer must be added before the first tracked child is created, otherwise an owner
+// deleted in between leaks the child.
+package ownership
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// Finalizer is held by owners with tracked children until Cleanup has deleted them
+	Finalizer = "ownership.my.domain/cleanup"
+
+	// OwnerUIDLabel selects the tracked children of an owner. The UID is used rather than
+	// the name because it fits a label value and is never reused by a recreated owner.
+	OwnerUIDLabel = "ownership.my.domain/owner-uid"
+
+	// OwnerAnnotation holds the owner as group/kind/namespace/name, for mapping events back
+	// to the owner; names can be longer than a label value allows
+	OwnerAnnotation = "ownership.my.domain/owner"
+
+	// OwnerIndex is the field index on OwnerUIDLabel registered by IndexOwner
+	OwnerIndex = "metadata.ownerUID"
+)
+
+// CanOwn reports whether owner may be set as an owner reference on child: either the owner
+// is cluster-scoped, or both are namespaced in the same namespace
+func CanOwn(c client.Client, owner, child client.Object) (bool, error) {
+	ownerNamespaced, err := c.IsObjectNamespaced(owner)
+	if err != nil {
+		return false, err
+	}
+	if !ownerNamespaced {
+		return true, nil
+	}
+	childNamespaced, err := c.IsObjectNamespaced(child)
+	if err != nil {
+		return false, err
+	}
+	return childNamespaced && child.GetNamespace() == owner.GetNamespace(), nil
+}
+
+// SetOwner makes owner the controller of child. It sets a controller reference when one is
+// allowed and tracking metadata otherwise, so the same call works for every child.
+func SetOwner(c client.Client, owner, child client.Object) error {
+	allowed, err := CanOwn(c, owner, child)
+	if err != nil {
+		return err
+	}
+	if allowed {
+		return controllerutil.SetControllerReference(owner, child, c.Scheme())
+	}
+
+	gvk, err := apiutil.GVKForObject(owner, c.Scheme())
+	if err != nil {
+		return err
+	}
+
+	labels := child.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	if uid, ok := labels[OwnerUIDLabel]; ok && uid != string(owner.GetUID()) {
+		return fmt.Errorf("%s is already tracked by owner %s", child.GetName(), child.GetAnnotations()[OwnerAnnotation])
+	}
+	labels[OwnerUIDLabel] = string(owner.GetUID())
+	child.SetLabels(labels)
+
+	annotations := child.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[OwnerAnnotation] = formatOwner(gvk.GroupKind(), owner.GetNamespace(), owner.GetName())
+	child.SetAnnotations(annotations)
+	return nil
+}
+
+// IndexOwner registers the OwnerIndex on a tracked child kind, so that Cleanup and other
+// lookups by owner are served from the cache
+func IndexOwner(ctx context.Context, indexer client.FieldIndexer, obj client.Object) error {
+	return indexer.IndexField(ctx, obj, OwnerIndex, func(o client.Object) []string {
+		if uid, ok := o.GetLabels()[OwnerUIDLabel]; ok {
+			return []string{uid}
+		}
+		return nil
+	})
+}
+
+// ListTracked lists the tracked children of owner of one kind, across all namespaces.
+// The kind must have been indexed with IndexOwner.
+func ListTracked(ctx context.Context, c client.Reader, owner client.Object, list client.ObjectList) error {
+	return c.List(ctx, list, client.MatchingFields{OwnerIndex: string(owner.GetUID())})
+}
+
+// Cleanup deletes the tracked children of owner of the given kinds and reports whether all
+// of them are gone. Children with finalizers of their own may take several calls.
+func Cleanup(ctx context.Context, c client.Client, owner client.Object, lists ...client.ObjectList) (bool, error) {
+	done := true
+	for _, list := range lists {
+		if err := ListTracked(ctx, c, owner, list); err != nil {
+			return false, fmt.Errorf("failed to list tracked children: %w", err)
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return false, err
+		}
+		for _, item := range items {
+			child, ok := item.(client.Object)
+			if !ok {
+				continue
+			}
+			done = false
+			if !child.GetDeletionTimestamp().IsZero() {
+				continue
+			}
+			// Background propagation matches what the garbage collector would have done
+			if err := c.Delete(ctx, child, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+				return false, fmt.Errorf("failed to delete tracked child %s: %w", child.GetName(), err)
+			}
+		}
+	}
+	return done, nil
+}
+
+// EnqueueOwner maps events on tracked children to a request for their owner of the given
+// kind, the equivalent of Owns() for children that cannot carry an owner reference
+func EnqueueOwner(ownerKind schema.GroupKind) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		kind, key, ok := parseOwner(obj.GetAnnotations()[OwnerAnnotation])
+		if !ok || kind != ownerKind {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: key}}
+	})
+}
+
+func formatOwner(kind schema.GroupKind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", kind.Group, kind.Kind, namespace, name)
+}
+
+func parseOwner(value string) (schema.GroupKind, types.NamespacedName, bool) {
+	parts := strings.SplitN(value, "/", 4)
+	if len(parts) != 4 || parts[3] == "" {
+		return schema.GroupKind{}, types.NamespacedName{}, false
+	}
+	return schema.GroupKind{Group: parts[0], Kind: parts[1]},
+		types.NamespacedName{Namespace: parts[2], Name: parts[3]}, true
+}
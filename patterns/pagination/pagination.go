@@ -0,0 +1,131 @@
+// Package pagination lists large collections page by page and hands the items to a callback,
+// so memory stays bounded by the page size instead of growing with the fleet.
+//
+// A plain List returns every object of a namespace, or of the cluster, in one response, and
+// the caller holds all of them until it returns. A map function that lists every MyResource to
+// find the few referencing a ConfigMap, or a sweeper that lists every labeled Secret, needs
+// memory, and API server time, proportional to the whole fleet on every call. The API server
+// can return a list in chunks instead: client.Limit caps a page and the continue token of each
+// page asks for the next one.
+//
+// ForEach pages through a list and calls a function per item; the list object is reused, so
+// only one page is held at a time. Requests is the map function form: it keeps a
+// reconcile.Request for the items that match and drops the rest with their page.
+//
+//	func (r *MyResourceReconciler) findObjectsForSecret(ctx context.Context, o client.Object) []reconcile.Request {
+//		requests, err := pagination.Requests(ctx, r.APIReader, &MyResourceList{},
+//			func(item *MyResource) bool { return item.Spec.SecretName == o.GetName() },
+//			client.InNamespace(o.GetNamespace()))
+//		if err != nil {
+//			log.FromContext(ctx).Error(err, "failed to list MyResources")
+//		}
+//		return requests
+//	}
+//
+// Page through the API server, i.e. mgr.GetAPIReader(), not the manager's client. The informer
+// cache has no pages: it rejects a continue token and cuts a list at the limit without one, so
+// through the cache ForEach would silently see the first page only. Paging trades memory for
+// round trips: a map function runs for every event of the watched kind, and each call pages
+// through the API server. Use it for kinds the operator does not cache, e.g. because the
+// fleet is too large to keep in memory (client.CacheOptions.DisableFor). For a cached kind the
+// objects are in memory already; list them with a field index and client.UnsafeDisableDeepCopy
+// instead, which copies neither the objects nor the ones that do not match.
+//
+// A continue token expires once etcd compacts the revision the list started at, five minutes
+// by default. The API server then answers 410 Gone with a token that continues the list at the
+// next key, but no longer from one snapshot: objects changed since the first page may be
+// missed or seen in their newer state. ForEach continues with it, and starts over once if the
+// server sent none, so fn may see an item twice; functions must therefore accept seeing the
+// fleet as it changes, which sweepers and map functions do anyway.
+package pagination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// DefaultPageSize is the page size of lists without a client.Limit option
+const DefaultPageSize = 500
+
+// ErrStop stops ForEach without an error when the function returns it, e.g. once the
+// function found what it was looking for
+var ErrStop = errors.New("stop listing")
+
+// ForEach lists into list page by page and calls fn for every item. The page size is the
+// client.Limit option, DefaultPageSize without one. T is the pointer type of the items, e.g.
+// *corev1.Secret for a *corev1.SecretList. fn must not keep the item: the next page overwrites
+// it. An error of fn stops the list and is returned, except ErrStop, which stops it cleanly.
+func ForEach[T client.Object](ctx context.Context, r client.Reader, list client.ObjectList, fn func(T) error, opts ...client.ListOption) error {
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+	if listOpts.Limit <= 0 {
+		listOpts.Limit = DefaultPageSize
+	}
+	restarted := false
+	for {
+		if err := r.List(ctx, list, listOpts); err != nil {
+			token, expired := expiredContinue(err)
+			if !expired || listOpts.Continue == "" || (token == "" && restarted) {
+				return err
+			}
+			// Continue inconsistently, or start over once when the server gave no token
+			restarted = restarted || token == ""
+			listOpts.Continue = token
+			continue
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			obj, ok := item.(T)
+			if !ok {
+				return fmt.Errorf("list item %T is not a %T", item, *new(T))
+			}
+			if err := fn(obj); err != nil {
+				if errors.Is(err, ErrStop) {
+					return nil
+				}
+				return err
+			}
+		}
+		listOpts.Continue = list.GetContinue()
+		if listOpts.Continue == "" {
+			return nil
+		}
+	}
+}
+
+// Requests pages through list and returns a request for every item match accepts, for map
+// functions. On an error it returns the requests of the pages listed before it.
+func Requests[T client.Object](ctx context.Context, r client.Reader, list client.ObjectList, match func(T) bool, opts ...client.ListOption) ([]reconcile.Request, error) {
+	var requests []reconcile.Request
+	err := ForEach(ctx, r, list, func(item T) error {
+		if match(item) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: item.GetNamespace(), Name: item.GetName()},
+			})
+		}
+		return nil
+	}, opts...)
+	return requests, err
+}
+
+// expiredContinue reports whether err is a list whose continue token expired, and the token
+// the server sent to continue it inconsistently; empty when it sent none
+func expiredContinue(err error) (string, bool) {
+	if !apierrors.IsResourceExpired(err) {
+		return "", false
+	}
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return "", true
+	}
+	return status.Status().ListMeta.Continue, true
+}
@@ -0,0 +1,223 @@
+// Package readonly reads objects from the shared informer cache without changing them.
+//
+// The manager's client reads from an informer cache that every controller of the process
+// shares. By default each Get and List deep-copies the cached objects, which costs an
+// allocation per object and, for a List of a large namespace, most of a reconcile. Lists with
+// client.UnsafeDisableDeepCopy, caches configured with cache.ByObject{UnsafeDisableDeepCopy},
+// and the objects of watch events handed to map functions and predicates skip the copy: the
+// caller gets the cache's own object. Changing it corrupts the cache for every controller
+// until the next watch event replaces it, and nothing fails at the point of the change:
+//
+//	var pods corev1.PodList
+//	_ = r.List(ctx, &pods, client.InNamespace(ns), client.UnsafeDisableDeepCopy)
+//	for i := range pods.Items {
+//		pods.Items[i].Labels["seen"] = "true" // writes into the cache's map
+//	}
+//
+// Go has no const, so the pattern is a convention made visible and a test that enforces it:
+//
+//   - View wraps an object shared with the cache. A function taking a View declares that it
+//     only reads; Read returns the object to read and Edit returns a copy to change
+//     (copy-on-write), so the fields you change never reach the cache.
+//   - List lists without copying and returns the items as Views.
+//   - Guard is a client for tests that snapshots every object shared with the cache when it
+//     is read and fails the test when code under test changed one. The fake client copies
+//     on every read even with UnsafeDisableDeepCopy, so without it such a change passes every
+//     unit test and only shows in production.
+//
+// Reconcile through a Guard and check it at the end of the test, like a vet check:
+//
+//	func TestMyResourceReconciler_ReadOnlyCache(t *testing.T) {
+//		guard := readonly.NewGuard(fakeClient, &corev1.Pod{})
+//		r := &MyResourceReconciler{Client: guard, Scheme: scheme}
+//		_, err := r.Reconcile(ctx, req)
+//		require.NoError(t, err)
+//
+//		// Watch event objects come from the cache too
+//		guard.Track(configMap)
+//		r.findObjectsForConfigMap(ctx, configMap)
+//
+//		guard.Check(t)
+//	}
+//
+// Get into an object you allocated, without an UnsafeDisableDeepCopy cache for its type, is a
+// copy that is yours to change, and Guard does not track it.
+package readonly
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// View is a read-only handle on an object shared with the cache
+type View[T runtime.Object] struct {
+	obj T
+}
+
+// NewView wraps an object shared with the cache, e.g. the object of a watch event
+func NewView[T runtime.Object](obj T) View[T] {
+	return View[T]{obj: obj}
+}
+
+// Read returns the shared object. It must not be changed, nor anything it points to: maps,
+// slices and pointers are the cache's.
+func (v View[T]) Read() T {
+	return v.obj
+}
+
+// Edit returns a deep copy of the object to change, e.g. to update it
+func (v View[T]) Edit() T {
+	return Copy(v.obj)
+}
+
+// Copy returns a deep copy of obj
+func Copy[T runtime.Object](obj T) T {
+	return obj.DeepCopyObject().(T)
+}
+
+// List lists into list without copying the cached objects and returns its items as Views.
+// T is the pointer type of the items, e.g. *corev1.Pod for a *corev1.PodList.
+func List[T runtime.Object](ctx context.Context, r client.Reader, list client.ObjectList, opts ...client.ListOption) ([]View[T], error) {
+	if err := r.List(ctx, list, append(opts, client.UnsafeDisableDeepCopy)...); err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]View[T], 0, len(items))
+	for _, item := range items {
+		obj, ok := item.(T)
+		if !ok {
+			return nil, fmt.Errorf("list item %T is not a %T", item, *new(T))
+		}
+		views = append(views, View[T]{obj: obj})
+	}
+	return views, nil
+}
+
+// Guard is a client for tests that records every object it returns shared with the cache and
+// reports those changed since. Objects are shared when listed with client.UnsafeDisableDeepCopy,
+// when their type is one of the Shared types, or when passed to Track.
+type Guard struct {
+	client.Client
+
+	// Shared are the types the cache of the code under test serves without copying, e.g.
+	// &corev1.Pod{} for cache.ByObject{Object: &corev1.Pod{}, UnsafeDisableDeepCopy: ptr.To(true)}.
+	// Every Get and List of them is tracked.
+	Shared []client.Object
+
+	mu    sync.Mutex
+	reads []read
+}
+
+// read is an object returned shared with the cache and its content at the time
+type read struct {
+	obj      runtime.Object
+	snapshot map[string]interface{}
+}
+
+// NewGuard guards the reads through c; shared are the types cached without copying
+func NewGuard(c client.Client, shared ...client.Object) *Guard {
+	return &Guard{Client: c, Shared: shared}
+}
+
+// Get reads the object and tracks it when its type is shared
+func (g *Guard) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if err := g.Client.Get(ctx, key, obj, opts...); err != nil {
+		return err
+	}
+	if g.isShared(obj) {
+		g.Track(obj)
+	}
+	return nil
+}
+
+// List lists the objects and tracks the items that the cache would have shared
+func (g *Guard) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := g.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+	uncopied := listOpts.UnsafeDisableDeepCopy != nil && *listOpts.UnsafeDisableDeepCopy
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if obj, ok := item.(client.Object); ok && (uncopied || g.isShared(obj)) {
+			g.Track(obj)
+		}
+	}
+	return nil
+}
+
+// Track records objects handed to the code under test shared with the cache, such as the
+// object of a watch event passed to a map function or a predicate
+func (g *Guard) Track(objs ...client.Object) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, obj := range objs {
+		snapshot, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			panic(fmt.Sprintf("readonly: cannot snapshot %T: %v", obj, err))
+		}
+		g.reads = append(g.reads, read{obj: obj, snapshot: snapshot})
+	}
+}
+
+// Mutations describes every tracked object that changed since it was read, sorted
+func (g *Guard) Mutations() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var mutations []string
+	for _, r := range g.reads {
+		current, err := runtime.DefaultUnstructuredConverter.ToUnstructured(r.obj)
+		if err != nil {
+			mutations = append(mutations, fmt.Sprintf("%s: %v", describe(r.obj), err))
+			continue
+		}
+		if d := cmp.Diff(r.snapshot, current); d != "" {
+			mutations = append(mutations, fmt.Sprintf("%s changed after it was read from the cache (-read +now):\n%s", describe(r.obj), d))
+		}
+	}
+	sort.Strings(mutations)
+	return mutations
+}
+
+// Check fails the test for every tracked object that changed since it was read
+func (g *Guard) Check(t testing.TB) {
+	t.Helper()
+	if mutations := g.Mutations(); len(mutations) > 0 {
+		t.Errorf("objects shared with the cache were changed; change a copy (readonly.Copy) instead:\n%s",
+			strings.Join(mutations, "\n"))
+	}
+}
+
+// isShared returns true if obj is of one of the Shared types
+func (g *Guard) isShared(obj client.Object) bool {
+	for _, shared := range g.Shared {
+		if reflect.TypeOf(shared) == reflect.TypeOf(obj) {
+			return true
+		}
+	}
+	return false
+}
+
+// describe names an object for a mutation report
+func describe(obj runtime.Object) string {
+	if o, ok := obj.(client.Object); ok {
+		return fmt.Sprintf("%T %s", obj, client.ObjectKeyFromObject(o))
+	}
+	return fmt.Sprintf("%T", obj)
+}
@@ -7,23 +7,42 @@ package patterns
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // MyResourceReconciler reconciles a MyResource object
 type MyResourceReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder events.EventRecorder
+
+	// APIReader reads directly from the API server, bypassing the cache.
+	// Set it to mgr.GetAPIReader(); see PATTERN 6b in advanced-reconciler.go.
+	APIReader client.Reader
+
+	// RequeuePolicy controls periodic resyncs; zero intervals fall back to DefaultRequeuePolicy
+	RequeuePolicy RequeuePolicy
 }
 
+// myResourceFinalizer keeps a MyResource until its external resources are cleaned up
+const myResourceFinalizer = "myresource.my.domain/finalizer"
+
 // IMPORTANT: Add RBAC markers for permissions needed
 // The reconciler needs these permissions to operate
 
@@ -56,24 +75,14 @@ func (r *MyResourceReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 
-	// STEP 2: Check if the object is being deleted
-	if !instance.ObjectMeta.DeletionTimestamp.IsZero() {
-		// The object is being deleted
-		return r.reconcileDelete(ctx, instance)
-	}
-
-	// STEP 3: Add finalizer if not present
-	// IMPORTANT: This ensures we can clean up external resources before deletion
-	finalizerName := "myresource.my.domain/finalizer"
-	if !controllerutil.ContainsFinalizer(instance, finalizerName) {
-		controllerutil.AddFinalizer(instance, finalizerName)
-		if err := r.Update(ctx, instance); err != nil {
-			return ctrl.Result{}, err
-		}
-		return ctrl.Result{Requeue: true}, nil
+	// STEP 2: Add the finalizer, or clean up and remove it if the object is being deleted
+	// IMPORTANT: The finalizer ensures we can clean up external resources before deletion
+	// Children are deleted, and gone, before external cleanup (see deletion.go)
+	if result, done, err := ReconcileWithFinalizer(ctx, r.Client, instance, myResourceFinalizer, r.reconcileDeleteOrdered); done {
+		return result, err
 	}
 
-	// STEP 4: Reconcile the actual state with desired state
+	// STEP 3: Reconcile the actual state with desired state
 	// This is where your business logic goes
 	log.Info("Reconciling MyResource", "name", instance.Name)
 
@@ -81,43 +90,38 @@ func (r *MyResourceReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	instance.Status.ObservedGeneration = instance.Generation
 
 	// Call the main reconcile logic
+	// With many failure modes, classify errors instead (see patterns/errors)
 	if err := r.reconcileLogic(ctx, instance); err != nil {
 		log.Error(err, "Failed to reconcile MyResource")
 		r.updateStatus(ctx, instance, metav1.ConditionFalse, "ReconcileError", err.Error())
 		return ctrl.Result{}, err
 	}
 
-	// STEP 5: Update status to indicate success
+	// STEP 4: Update status to indicate success
 	r.updateStatus(ctx, instance, metav1.ConditionTrue, "Ready", "MyResource is ready")
 
-	// STEP 6: Determine if we should requeue
+	// STEP 5: Determine if we should requeue
 	// Return with RequeueAfter for periodic reconciliation (e.g., polling external systems)
 	// Return without requeue if everything is stable
-	return ctrl.Result{RequeueAfter: r.getRequeueInterval(instance)}, nil
+	return ctrl.Result{RequeueAfter: r.RequeuePolicy.RequeueAfter(instance)}, nil
 }
 
-// reconcileDelete handles deletion of the resource
-func (r *MyResourceReconciler) reconcileDelete(ctx context.Context, instance *MyResource) (ctrl.Result, error) {
+// finalize cleans up external resources once the children of a deleted resource are gone. It
+// runs until it succeeds, so it must be idempotent; ReconcileWithFinalizer removes the
+// finalizer afterwards. A failed cleanup is reported in a DeletionBlocked condition and
+// retried with backoff, and can be skipped with force-delete (see deletion.go).
+func (r *MyResourceReconciler) finalize(ctx context.Context, instance *MyResource) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 	log.Info("Reconciling delete for MyResource", "name", instance.Name)
 
-	finalizerName := "myresource.my.domain/finalizer"
-
-	if controllerutil.ContainsFinalizer(instance, finalizerName) {
-		// Clean up external resources
-		if err := r.cleanupExternalResources(ctx, instance); err != nil {
-			log.Error(err, "Failed to clean up external resources")
-			return ctrl.Result{}, err
-		}
-
-		// Remove finalizer
-		controllerutil.RemoveFinalizer(instance, finalizerName)
-		if err := r.Update(ctx, instance); err != nil {
-			return ctrl.Result{}, err
-		}
+	if forceDeleteAllowed(instance) {
+		log.Info("Force-delete requested, skipping external cleanup",
+			"name", instance.Name, "attempts", cleanupAttempts(instance))
+		return ctrl.Result{}, nil
+	}
+	if err := r.cleanupExternalResources(ctx, instance); err != nil {
+		return r.blockDeletion(ctx, instance, err)
 	}
-
-	// Stop reconciliation as the object is being deleted
 	return ctrl.Result{}, nil
 }
 
@@ -126,7 +130,10 @@ func (r *MyResourceReconciler) reconcileLogic(ctx context.Context, instance *MyR
 	log := log.FromContext(ctx)
 
 	// Example: Create or update a Deployment
-	deployment := r.constructDeployment(instance)
+	deployment, err := r.constructDeployment(instance)
+	if err != nil {
+		return err
+	}
 
 	// Create the Deployment if it doesn't exist
 	op, err := controllerutil.CreateOrPatch(ctx, r.Client, deployment, func() error {
@@ -159,24 +166,68 @@ func (r *MyResourceReconciler) updateStatus(ctx context.Context, instance *MyRes
 	}
 }
 
-// getRequeueInterval returns the duration before next reconciliation
-func (r *MyResourceReconciler) getRequeueInterval(instance *MyResource) time.Duration {
+// RequeuePolicy holds the operator-wide resync intervals, so every reconcile path requeues
+// the same way instead of hardcoding its own interval
+type RequeuePolicy struct {
+	// ReadyInterval is the resync interval for ready resources
+	ReadyInterval time.Duration
+
+	// NotReadyInterval is the resync interval for resources that are not ready yet
+	NotReadyInterval time.Duration
+
+	// JitterFactor spreads resyncs over [interval, interval*(1+JitterFactor)]
+	// so that many resources created together do not resync in lockstep
+	JitterFactor float64
+}
+
+// DefaultRequeuePolicy is used for any interval left unset
+var DefaultRequeuePolicy = RequeuePolicy{
+	ReadyInterval:    5 * time.Minute,
+	NotReadyInterval: 30 * time.Second,
+	JitterFactor:     0.1,
+}
+
+// BindFlags registers flags for the requeue policy, using the current values as defaults
+func (p *RequeuePolicy) BindFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&p.ReadyInterval, "requeue-ready-interval", p.ReadyInterval,
+		"How often a ready MyResource is resynced when no events arrive.")
+	fs.DurationVar(&p.NotReadyInterval, "requeue-not-ready-interval", p.NotReadyInterval,
+		"How often a MyResource that is not ready is resynced when no events arrive.")
+	fs.Float64Var(&p.JitterFactor, "requeue-jitter", p.JitterFactor,
+		"Maximum fraction of the interval added as random jitter to each resync.")
+}
+
+// RequeueAfter returns the jittered duration before the next reconciliation
+func (p RequeuePolicy) RequeueAfter(instance *MyResource) time.Duration {
 	// Example: Requeue more frequently if not ready
+	interval := p.NotReadyInterval
+	if interval <= 0 {
+		interval = DefaultRequeuePolicy.NotReadyInterval
+	}
 	if instance.IsReady() {
-		return 5 * time.Minute
+		interval = p.ReadyInterval
+		if interval <= 0 {
+			interval = DefaultRequeuePolicy.ReadyInterval
+		}
 	}
-	return 30 * time.Second
+	if p.JitterFactor <= 0 {
+		return interval
+	}
+	return wait.Jitter(interval, p.JitterFactor)
 }
 
 // constructDeployment creates a Deployment object from the MyResource spec
-func (r *MyResourceReconciler) constructDeployment(instance *MyResource) *appsv1.Deployment {
+func (r *MyResourceReconciler) constructDeployment(instance *MyResource) (*appsv1.Deployment, error) {
 	dep := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      instance.Name,
 			Namespace: instance.Namespace,
-		}
-		controllerutil.SetControllerReference(instance, dep, r.Scheme)
-	return dep
+		},
+	}
+	if err := controllerutil.SetControllerReference(instance, dep, r.Scheme); err != nil {
+		return nil, err
+	}
+	return dep, nil
 }
 
 // constructDeploymentSpec creates the Deployment spec from MyResource
@@ -224,7 +275,7 @@ func (r *MyResourceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		// Watch for changes in dependent resources
 		// Example: Watch ConfigMaps referenced in spec
 		// Watches(
-		// 	&source.Kind{Type: &corev1.ConfigMap{}},
+		// 	&corev1.ConfigMap{},
 		// 	handler.EnqueueRequestsFromMapFunc(r.findConfigMaps),
 		// ).
 		Complete(r)
@@ -0,0 +1,202 @@
+// Package sidecar injects a sidecar container and its volumes into Pods with a mutating
+// admission webhook.
+//
+// This differs from the CR defaulting webhook (admission.CustomDefaulter): the object being
+// mutated is a built-in type the operator does not own, so the webhook is registered on the
+// webhook server by path instead of with ctrl.NewWebhookManagedBy, and it must coexist with
+// other mutators (service meshes, policy engines, image rewriters) acting on the same Pod.
+//
+// Three rules keep injection correct when several mutators run:
+//
+//   - Idempotency: the API server may call the webhook more than once for the same Pod
+//     (reinvocation, retries, a Pod re-submitted by a controller). Every change is keyed by
+//     name, so a second call finds the sidecar, volumes and mounts present and returns no patch.
+//   - Ordering: mutating webhooks run one after another, ordered by configuration name and then
+//     by webhook order within a configuration. A mutator that runs later may add containers the
+//     injector never saw. reinvocationPolicy=IfNeeded makes the API server call the injector
+//     again after a later webhook changed the Pod, so those containers get the shared mounts too.
+//   - Scope: select Pods with an objectSelector on the webhook configuration, so the API server
+//     does not call the webhook for every Pod in the cluster, and check the label again in the
+//     handler in case the configuration is edited. failurePolicy=Ignore keeps Pods schedulable
+//     when the operator is down, at the cost of Pods created meanwhile running without the sidecar.
+//
+// controller-gen cannot emit an objectSelector; add it with a kustomize patch on the generated
+// MutatingWebhookConfiguration:
+//
+//	patches:
+//	- target:
+//	    kind: MutatingWebhookConfiguration
+//	  patch: |-
+//	    - op: add
+//	      path: /webhooks/0/objectSelector
+//	      value:
+//	        matchLabels:
+//	          sidecar.my.domain/inject: "true"
+//
+// Register the injector with the manager:
+//
+//	injector := &sidecar.Injector{
+//		Decoder: admission.NewDecoder(mgr.GetScheme()),
+//		Sidecar: corev1.Container{
+//			Name:  "log-shipper",
+//			Image: "registry.example.com/log-shipper:v1.4.0",
+//			VolumeMounts: []corev1.VolumeMount{{Name: "shared-logs", MountPath: "/var/log/app"}},
+//		},
+//		Volumes: []corev1.Volume{{
+//			Name:         "shared-logs",
+//			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+//		}},
+//		Mounts: []corev1.VolumeMount{{Name: "shared-logs", MountPath: "/var/log/app"}},
+//	}
+//	injector.SetupWithManager(mgr)
+package sidecar
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/mutate-v1-pod-sidecar,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=sidecar.my.domain,admissionReviewVersions=v1,reinvocationPolicy=IfNeeded
+
+const (
+	// Path is the webhook server path the injector is served on
+	Path = "/mutate-v1-pod-sidecar"
+
+	// InjectLabel opts a Pod in to injection when set to "true"
+	InjectLabel = "sidecar.my.domain/inject"
+
+	// StatusAnnotation records that the Pod was injected, for humans and other tools;
+	// idempotency does not rely on it, since another mutator may copy or drop annotations
+	StatusAnnotation = "sidecar.my.domain/status"
+)
+
+// Injector is a mutating admission handler that adds a sidecar container, the volumes it
+// needs and shared volume mounts to Pods labeled with InjectLabel
+type Injector struct {
+	Decoder *admission.Decoder
+
+	// Sidecar is the container to inject; it is matched by name
+	Sidecar corev1.Container
+
+	// Volumes are added to the Pod unless a volume with the same name exists
+	Volumes []corev1.Volume
+
+	// Mounts are added to every other container in the Pod, including containers added by
+	// mutators that ran after the injector, unless the container already mounts that volume
+	Mounts []corev1.VolumeMount
+}
+
+var _ admission.Handler = &Injector{}
+
+// SetupWithManager serves the injector on the manager's webhook server
+func (i *Injector) SetupWithManager(mgr ctrl.Manager) {
+	mgr.GetWebhookServer().Register(Path, &webhook.Admission{Handler: i})
+}
+
+// Handle injects the sidecar into a Pod being created
+func (i *Injector) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := log.FromContext(ctx)
+
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("sidecars are only injected on create")
+	}
+
+	pod := &corev1.Pod{}
+	if err := i.Decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if pod.Labels[InjectLabel] != "true" {
+		return admission.Allowed("injection not requested")
+	}
+
+	if !i.Inject(pod) {
+		// Returning no patch on reinvocation keeps the API server from looping between mutators
+		return admission.Allowed("already injected")
+	}
+
+	marshaled, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	log.Info("Injected sidecar", "pod", pod.GenerateName+pod.Name, "namespace", req.Namespace,
+		"sidecar", i.Sidecar.Name)
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// Inject adds the sidecar, volumes and mounts the Pod is missing and reports whether it
+// changed the Pod. Calling it on an injected Pod is a no-op.
+func (i *Injector) Inject(pod *corev1.Pod) bool {
+	changed := false
+
+	for _, volume := range i.Volumes {
+		if !hasVolume(pod.Spec.Volumes, volume.Name) {
+			pod.Spec.Volumes = append(pod.Spec.Volumes, *volume.DeepCopy())
+			changed = true
+		}
+	}
+
+	for idx := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[idx]
+		if container.Name == i.Sidecar.Name {
+			continue
+		}
+		for _, mount := range i.Mounts {
+			if !hasMount(container.VolumeMounts, mount.Name) {
+				container.VolumeMounts = append(container.VolumeMounts, mount)
+				changed = true
+			}
+		}
+	}
+
+	// Appended after the app containers so the first container, which kubectl exec and
+	// logs pick by default, stays the application
+	if !hasContainer(pod.Spec.Containers, i.Sidecar.Name) {
+		pod.Spec.Containers = append(pod.Spec.Containers, *i.Sidecar.DeepCopy())
+		changed = true
+	}
+
+	if changed {
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[StatusAnnotation] = "injected"
+	}
+	return changed
+}
+
+func hasContainer(containers []corev1.Container, name string) bool {
+	for _, container := range containers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasVolume(volumes []corev1.Volume, name string) bool {
+	for _, volume := range volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasMount(mounts []corev1.VolumeMount, name string) bool {
+	for _, mount := range mounts {
+		if mount.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+package sidecar
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newInjector() *Injector {
+	return &Injector{
+		Decoder: admission.NewDecoder(clientgoscheme.Scheme),
+		Sidecar: corev1.Container{
+			Name:         "log-shipper",
+			Image:        "log-shipper:v1",
+			VolumeMounts: []corev1.VolumeMount{{Name: "shared-logs", MountPath: "/var/log/app"}},
+		},
+		Volumes: []corev1.Volume{{
+			Name:         "shared-logs",
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		}},
+		Mounts: []corev1.VolumeMount{{Name: "shared-logs", MountPath: "/var/log/app"}},
+	}
+}
+
+func newPod(name string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "app:v1"}},
+		},
+	}
+}
+
+var injectLabels = map[string]string{InjectLabel: "true"}
+
+func TestInjector_InjectIsIdempotent(t *testing.T) {
+	injector := newInjector()
+	pod := newPod("app", injectLabels)
+
+	assert.True(t, injector.Inject(pod))
+	assert.False(t, injector.Inject(pod), "A second call must not change an injected Pod")
+
+	require.Len(t, pod.Spec.Containers, 2)
+	assert.Equal(t, "app", pod.Spec.Containers[0].Name, "The application stays the first container")
+	assert.Equal(t, "log-shipper", pod.Spec.Containers[1].Name)
+	assert.Len(t, pod.Spec.Volumes, 1)
+	assert.Len(t, pod.Spec.Containers[0].VolumeMounts, 1)
+	assert.Len(t, pod.Spec.Containers[1].VolumeMounts, 1)
+	assert.Equal(t, "injected", pod.Annotations[StatusAnnotation])
+
+	// A container added by a later mutator only needs the shared mount
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "agent", Image: "agent:v1"})
+	assert.True(t, injector.Inject(pod))
+	assert.Len(t, pod.Spec.Containers[2].VolumeMounts, 1)
+	assert.Len(t, pod.Spec.Containers, 3)
+}
+
+func TestInjector_Handle(t *testing.T) {
+	injector := newInjector()
+
+	request := func(pod *corev1.Pod, operation admissionv1.Operation) admission.Request {
+		raw, err := json.Marshal(pod)
+		require.NoError(t, err)
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: operation,
+			Namespace: pod.Namespace,
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+	}
+
+	resp := injector.Handle(context.Background(), request(newPod("app", injectLabels), admissionv1.Create))
+	assert.True(t, resp.Allowed)
+	assert.NotEmpty(t, resp.Patches)
+
+	resp = injector.Handle(context.Background(), request(newPod("plain", nil), admissionv1.Create))
+	assert.True(t, resp.Allowed)
+	assert.Empty(t, resp.Patches, "Pods without the label are left alone")
+
+	injected := newPod("app", injectLabels)
+	injector.Inject(injected)
+	resp = injector.Handle(context.Background(), request(injected, admissionv1.Create))
+	assert.True(t, resp.Allowed)
+	assert.Empty(t, resp.Patches, "Reinvocation on an injected Pod returns no patch")
+
+	resp = injector.Handle(context.Background(), request(newPod("app", injectLabels), admissionv1.Update))
+	assert.True(t, resp.Allowed)
+	assert.Empty(t, resp.Patches)
+
+	resp = injector.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: []byte("not json")},
+	}})
+	assert.False(t, resp.Allowed)
+	assert.EqualValues(t, http.StatusBadRequest, resp.Result.Code)
+}
+
+// agentAdder stands in for another mutator that runs after the injector and adds a container
+type agentAdder struct {
+	decoder *admission.Decoder
+}
+
+func (a *agentAdder) Handle(_ context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := a.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if hasContainer(pod.Spec.Containers, "agent") {
+		return admission.Allowed("")
+	}
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "agent", Image: "agent:v1"})
+	marshaled, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// TestInjector_Envtest runs the injector behind a real API server. It needs the envtest
+// binaries; run it with KUBEBUILDER_ASSETS set, e.g. via setup-envtest.
+func TestInjector_Envtest(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+
+	podWebhook := func(name, path string, reinvocation admissionregistrationv1.ReinvocationPolicyType) admissionregistrationv1.MutatingWebhook {
+		failurePolicy := admissionregistrationv1.Fail
+		sideEffects := admissionregistrationv1.SideEffectClassNone
+		return admissionregistrationv1.MutatingWebhook{
+			Name:                    name,
+			AdmissionReviewVersions: []string{"v1"},
+			SideEffects:             &sideEffects,
+			FailurePolicy:           &failurePolicy,
+			ReinvocationPolicy:      &reinvocation,
+			ObjectSelector:          &metav1.LabelSelector{MatchLabels: injectLabels},
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{Name: "webhook-service", Namespace: "default", Path: &path},
+			},
+			Rules: []admissionregistrationv1.RuleWithOperations{{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{""},
+					APIVersions: []string{"v1"},
+					Resources:   []string{"pods"},
+				},
+			}},
+		}
+	}
+
+	// Webhooks in one configuration run in order, so the agent is added after injection
+	testEnv := &envtest.Environment{
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			MutatingWebhooks: []*admissionregistrationv1.MutatingWebhookConfiguration{{
+				ObjectMeta: metav1.ObjectMeta{Name: "pod-mutators"},
+				Webhooks: []admissionregistrationv1.MutatingWebhook{
+					podWebhook("sidecar.my.domain", Path, admissionregistrationv1.IfNeededReinvocationPolicy),
+					podWebhook("agent.my.domain", "/mutate-v1-pod-agent", admissionregistrationv1.NeverReinvocationPolicy),
+				},
+			}},
+		},
+	}
+	cfg, err := testEnv.Start()
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, testEnv.Stop()) }()
+
+	install := testEnv.WebhookInstallOptions
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:  clientgoscheme.Scheme,
+		Metrics: metricsserver.Options{BindAddress: "0"},
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Host:    install.LocalServingHost,
+			Port:    install.LocalServingPort,
+			CertDir: install.LocalServingCertDir,
+		}),
+	})
+	require.NoError(t, err)
+	newInjector().SetupWithManager(mgr)
+	mgr.GetWebhookServer().Register("/mutate-v1-pod-agent",
+		&webhook.Admission{Handler: &agentAdder{decoder: admission.NewDecoder(mgr.GetScheme())}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = mgr.Start(ctx) }()
+
+	started := mgr.GetWebhookServer().StartedChecker()
+	require.Eventually(t, func() bool { return started(nil) == nil }, 10*time.Second, 100*time.Millisecond)
+
+	c, err := client.New(cfg, client.Options{Scheme: clientgoscheme.Scheme})
+	require.NoError(t, err)
+
+	pod := newPod("injected", injectLabels)
+	require.NoError(t, c.Create(ctx, pod))
+
+	names := []string{}
+	for _, container := range pod.Spec.Containers {
+		names = append(names, container.Name)
+		// The agent was added after the injector ran; reinvocation mounted the shared volume into it
+		if container.Name != "log-shipper" {
+			assert.Len(t, container.VolumeMounts, 1, "container %s", container.Name)
+		}
+	}
+	assert.Equal(t, []string{"app", "log-shipper", "agent"}, names)
+	assert.Len(t, pod.Spec.Volumes, 1)
+
+	plain := newPod("plain", nil)
+	require.NoError(t, c.Create(ctx, plain))
+	assert.Len(t, plain.Spec.Containers, 1)
+}
@@ -7,21 +7,30 @@ package patterns
 
 import (
 	"context"
+	"encoding/json"
+	"path/filepath"
 	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	// patterns/compat, copied into your project
+	"my.domain/myproject/pkg/compat"
 )
 
 // UNIT TEST WITH FAKE CLIENT
@@ -32,7 +41,7 @@ func TestMyResourceReconciler(t *testing.T) {
 
 	// Create a scheme with all the types we need
 	scheme := runtime.NewScheme()
-	err := MyGroupV1AddToScheme(scheme)
+	err := AddToScheme(scheme)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	err = appsv1.AddToScheme(scheme)
@@ -89,7 +98,7 @@ func TestMyResourceReconciler_Deletion(t *testing.T) {
 	g := NewWithT(t)
 
 	scheme := runtime.NewScheme()
-	err := MyGroupV1AddToScheme(scheme)
+	err := AddToScheme(scheme)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	now := metav1.Now()
@@ -138,7 +147,7 @@ func TestMyResourceReconciler_Conflict(t *testing.T) {
 	g := NewWithT(t)
 
 	scheme := runtime.NewScheme()
-	err := MyGroupV1AddToScheme(scheme)
+	err := AddToScheme(scheme)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	instance := &MyResource{
@@ -186,19 +195,75 @@ func TestMyResourceReconciler_Conflict(t *testing.T) {
 	g.Expect(result.Requeue).To(BeTrue()) // Should requeue on conflict
 }
 
+func TestForceDeleteAllowed(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{
+			name: "no annotation",
+			annotations: map[string]string{
+				cleanupAttemptsAnnotation: "10",
+			},
+			want: false,
+		},
+		{
+			name: "annotation set before enough attempts",
+			annotations: map[string]string{
+				forceDeleteAnnotation:     "true",
+				cleanupAttemptsAnnotation: "1",
+			},
+			want: false,
+		},
+		{
+			name: "annotation set after enough attempts",
+			annotations: map[string]string{
+				forceDeleteAnnotation:     "true",
+				cleanupAttemptsAnnotation: "5",
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			instance := &MyResource{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations},
+			}
+			g.Expect(forceDeleteAllowed(instance)).To(Equal(tt.want))
+		})
+	}
+}
+
 // INTEGRATION TEST WITH ENVTEST
 // ==============================
 
 var (
-	testEnv   *envtest.Environment
-	testCtx   context.Context
-	cancel    context.CancelFunc
+	testEnv    *envtest.Environment
+	testCtx    context.Context
+	cancel     context.CancelFunc
 	testClient client.Client
+
+	// testReader reads from the API server, so the order of deletions is observed as it
+	// happened instead of in the order the informers caught up
+	testReader client.Reader
+)
+
+// timeout and interval bound the Eventually checks of the integration tests
+const (
+	timeout  = 10 * time.Second
+	interval = 250 * time.Millisecond
 )
 
 var _ = BeforeSuite(func() {
 	testCtx, cancel = context.WithCancel(context.Background())
 
+	// envtest has no garbage collector to clear foregroundDeletion finalizers (see deletion.go)
+	ChildDeletionPropagation = metav1.DeletePropagationBackground
+
 	// Create the test environment
 	testEnv = &envtest.Environment{
 		CRDDirectoryPaths:     []string{filepath.Join("..", "config", "crd", "bases")},
@@ -213,12 +278,16 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(cfg).NotTo(BeNil())
 
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(AddToScheme(scheme)).To(Succeed())
+
 	// Create the manager
 	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
-		Scheme:             scheme,
-		Metrics:            metricsserver.Options{BindAddress: "0"},
-		LeaderElection:     false,
-		LeaderElectionID:   "test-operator",
+		Scheme:           scheme,
+		Metrics:          metricsserver.Options{BindAddress: "0"},
+		LeaderElection:   false,
+		LeaderElectionID: "test-operator",
 	})
 	Expect(err).NotTo(HaveOccurred())
 
@@ -236,6 +305,7 @@ var _ = BeforeSuite(func() {
 	}()
 
 	testClient = mgr.GetClient()
+	testReader = mgr.GetAPIReader()
 })
 
 var _ = AfterSuite(func() {
@@ -332,7 +402,7 @@ var _ = Describe("MyResource Integration Tests", func() {
 				return errors.IsNotFound(err)
 			}, timeout, interval).Should(BeTrue())
 
-			// Verify deployment was deleted (by garbage collector)
+			// Verify deployment was deleted (by the finalizer, see deletion.go)
 			Eventually(func() bool {
 				err := testClient.Get(testCtx, types.NamespacedName{
 					Name:      "cleanup-test",
@@ -342,6 +412,62 @@ var _ = Describe("MyResource Integration Tests", func() {
 			}, timeout, interval).Should(BeTrue())
 		})
 	})
+
+	// The reconciler deletes through reconcileDeleteOrdered (see deletion.go)
+	Context("When deleting a MyResource whose children must go first", func() {
+		It("Should remove the children before the parent disappears", func() {
+			key := types.NamespacedName{
+				Name:      "ordered-delete-test",
+				Namespace: "default",
+			}
+
+			By("Creating a MyResource")
+			instance := &MyResource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+				},
+				Spec: MyResourceSpec{
+					Replicas: 1,
+					Image:    "nginx:latest",
+				},
+			}
+			Expect(testClient.Create(testCtx, instance)).To(Succeed())
+
+			// Wait for the child deployment to be created
+			Eventually(func() error {
+				return testClient.Get(testCtx, key, &appsv1.Deployment{})
+			}, timeout, interval).Should(Succeed())
+
+			// Without the finalizer nothing would hold the parent back
+			Eventually(func() ([]string, error) {
+				err := testClient.Get(testCtx, key, instance)
+				return instance.Finalizers, err
+			}, timeout, interval).Should(ContainElement(myResourceFinalizer))
+
+			// Foreground would wait for a garbage collector envtest does not run; with
+			// Background only the reconciler's finalizer orders the deletions
+			By("Deleting the MyResource with background propagation")
+			Expect(testClient.Delete(testCtx, instance,
+				client.PropagationPolicy(metav1.DeletePropagationBackground))).To(Succeed())
+
+			By("Observing that the parent never outlives its child")
+			parentOutlivedChild := false
+			Eventually(func() bool {
+				// Read the parent first: once it is gone, the child must already be gone
+				parentErr := testReader.Get(testCtx, key, &MyResource{})
+				childErr := testReader.Get(testCtx, key, &appsv1.Deployment{})
+
+				if errors.IsNotFound(parentErr) {
+					parentOutlivedChild = !errors.IsNotFound(childErr)
+					return true
+				}
+				return false
+			}, timeout, interval).Should(BeTrue())
+
+			Expect(parentOutlivedChild).To(BeFalse(), "parent was removed before its child")
+		})
+	})
 })
 
 // WEBHOOK TESTS
@@ -373,7 +499,7 @@ func TestMyResourceValidator(t *testing.T) {
 				},
 			},
 			wantErr:   true,
-			errString: "replicas must be between 0 and 100",
+			errString: "spec.replicas: Invalid value: -1: must be between 0 and 100",
 		},
 		{
 			name: "missing image",
@@ -384,7 +510,7 @@ func TestMyResourceValidator(t *testing.T) {
 				},
 			},
 			wantErr:   true,
-			errString: "image must be specified",
+			errString: "spec.image: Required value",
 		},
 	}
 
@@ -393,10 +519,10 @@ func TestMyResourceValidator(t *testing.T) {
 			g := NewWithT(t)
 
 			scheme := runtime.NewScheme()
-			err := MyGroupV1AddToScheme(scheme)
+			err := AddToScheme(scheme)
 			g.Expect(err).NotTo(HaveOccurred())
 
-			decoder := admission.NewDecoder(scheme)
+			decoder := compat.NewDecoder(scheme)
 			validator := &MyResourceValidator{
 				Decoder: decoder,
 			}
@@ -417,6 +543,78 @@ func TestMyResourceValidator(t *testing.T) {
 			if tt.wantErr {
 				g.Expect(response.Allowed).To(BeFalse())
 				g.Expect(response.Result.Message).To(ContainSubstring(tt.errString))
+				// kubectl shows each cause with the path of its field
+				g.Expect(response.Result.Reason).To(Equal(metav1.StatusReasonInvalid))
+				g.Expect(response.Result.Details.Causes).NotTo(BeEmpty())
+			} else {
+				g.Expect(response.Allowed).To(BeTrue())
+			}
+		})
+	}
+}
+
+func TestMyResourceValidator_Update(t *testing.T) {
+	tests := []struct {
+		name      string
+		old       *MyResource
+		instance  *MyResource
+		wantErr   bool
+		errString string
+	}{
+		{
+			name:     "restart requested",
+			old:      &MyResource{Spec: MyResourceSpec{Replicas: 3, Image: "nginx:latest", RestartGeneration: 1}},
+			instance: &MyResource{Spec: MyResourceSpec{Replicas: 3, Image: "nginx:latest", RestartGeneration: 2}},
+			wantErr:  false,
+		},
+		{
+			name:      "immutable field changed",
+			old:       &MyResource{Spec: MyResourceSpec{Replicas: 3, Image: "nginx:latest", ConfigMapName: "config"}},
+			instance:  &MyResource{Spec: MyResourceSpec{Replicas: 3, Image: "nginx:latest", ConfigMapName: "other"}},
+			wantErr:   true,
+			errString: "spec.configMapName: Invalid value: \"other\": field is immutable once set",
+		},
+		{
+			name:      "counter lowered",
+			old:       &MyResource{Spec: MyResourceSpec{Replicas: 3, Image: "nginx:latest", RestartGeneration: 2}},
+			instance:  &MyResource{Spec: MyResourceSpec{Replicas: 3, Image: "nginx:latest", RestartGeneration: 1}},
+			wantErr:   true,
+			errString: "spec.restartGeneration: Invalid value: 1: may only increase, it was 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(AddToScheme(scheme)).To(Succeed())
+			validator := &MyResourceValidator{
+				Decoder: compat.NewDecoder(scheme),
+			}
+
+			// An UPDATE request carries both objects
+			raw, err := json.Marshal(tt.instance)
+			g.Expect(err).NotTo(HaveOccurred())
+			oldRaw, err := json.Marshal(tt.old)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			req := admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Update,
+					Object:    runtime.RawExtension{Raw: raw},
+					OldObject: runtime.RawExtension{Raw: oldRaw},
+				},
+			}
+
+			response := validator.Handle(context.Background(), req)
+
+			if tt.wantErr {
+				g.Expect(response.Allowed).To(BeFalse())
+				g.Expect(response.Result.Message).To(ContainSubstring(tt.errString))
+				// kubectl shows each cause with the path of its field
+				g.Expect(response.Result.Reason).To(Equal(metav1.StatusReasonInvalid))
+				g.Expect(response.Result.Details.Causes).NotTo(BeEmpty())
 			} else {
 				g.Expect(response.Allowed).To(BeTrue())
 			}
@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Client attributes the time of every call to the reconcile's Trace.
+// CreateOrUpdate and CreateOrPatch show up as their Get plus their write.
+type Client struct {
+	client.Client
+}
+
+var _ client.Client = &Client{}
+
+// NewClient wraps c so its calls are attributed to the calling reconcile
+func NewClient(c client.Client) *Client {
+	return &Client{Client: c}
+}
+
+// Get reads an object, attributed to PhaseGet
+func (c *Client) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	defer Start(ctx, PhaseGet)()
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+// List reads a list, attributed to PhaseList
+func (c *Client) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	defer Start(ctx, PhaseList)()
+	return c.Client.List(ctx, list, opts...)
+}
+
+// Create is attributed to PhaseWrite
+func (c *Client) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	defer Start(ctx, PhaseWrite)()
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+// Update is attributed to PhaseWrite
+func (c *Client) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	defer Start(ctx, PhaseWrite)()
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// Patch is attributed to PhaseWrite
+func (c *Client) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	defer Start(ctx, PhaseWrite)()
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+// Delete is attributed to PhaseWrite
+func (c *Client) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	defer Start(ctx, PhaseWrite)()
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+// DeleteAllOf is attributed to PhaseWrite
+func (c *Client) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	defer Start(ctx, PhaseWrite)()
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+// Status returns a status writer whose calls are attributed to PhaseWrite
+func (c *Client) Status() client.SubResourceWriter {
+	return &subResourceWriter{SubResourceWriter: c.Client.Status()}
+}
+
+// SubResource returns a subresource client whose writes are attributed to PhaseWrite
+func (c *Client) SubResource(subResource string) client.SubResourceClient {
+	return &subResourceClient{SubResourceClient: c.Client.SubResource(subResource)}
+}
+
+type subResourceWriter struct {
+	client.SubResourceWriter
+}
+
+func (w *subResourceWriter) Create(ctx context.Context, obj, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	defer Start(ctx, PhaseWrite)()
+	return w.SubResourceWriter.Create(ctx, obj, subResource, opts...)
+}
+
+func (w *subResourceWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	defer Start(ctx, PhaseWrite)()
+	return w.SubResourceWriter.Update(ctx, obj, opts...)
+}
+
+func (w *subResourceWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	defer Start(ctx, PhaseWrite)()
+	return w.SubResourceWriter.Patch(ctx, obj, patch, opts...)
+}
+
+type subResourceClient struct {
+	client.SubResourceClient
+}
+
+func (s *subResourceClient) Get(ctx context.Context, obj, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	defer Start(ctx, PhaseGet)()
+	return s.SubResourceClient.Get(ctx, obj, subResource, opts...)
+}
+
+func (s *subResourceClient) Create(ctx context.Context, obj, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	defer Start(ctx, PhaseWrite)()
+	return s.SubResourceClient.Create(ctx, obj, subResource, opts...)
+}
+
+func (s *subResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	defer Start(ctx, PhaseWrite)()
+	return s.SubResourceClient.Update(ctx, obj, opts...)
+}
+
+func (s *subResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	defer Start(ctx, PhaseWrite)()
+	return s.SubResourceClient.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// WORKQUEUE DEDUPLICATION
+// =======================
+//
+// The workqueue drops a request that is already queued, so a burst of events for one
+// object costs a single reconcile. controller-runtime's workqueue_adds_total only counts
+// requests that were actually queued; counting what handlers tried to enqueue as well
+// shows how much deduplication is saving:
+//
+//	deduplicated = controller_handler_enqueued_total - workqueue_adds_total
+//
+// A ratio close to zero means nearly every event causes its own reconcile, and predicates
+// (e.g. GenerationChangedPredicate) are worth adding.
+
+// handlerEnqueued counts requests event handlers tried to enqueue, before deduplication
+var handlerEnqueued = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "controller_handler_enqueued_total",
+	Help: "Number of requests event handlers tried to enqueue, including ones the workqueue deduplicated.",
+}, []string{"controller"})
+
+func init() {
+	metrics.Registry.MustRegister(handlerEnqueued)
+}
+
+// CountingHandler wraps an event handler and counts every request it enqueues.
+// Use the controller's name so the metric lines up with workqueue_adds_total{name=...}:
+//
+//	Watches(&corev1.Pod{}, tracing.CountingHandler("myresource", handler.EnqueueRequestsFromMapFunc(r.findForPod)))
+func CountingHandler(controller string, h handler.EventHandler) handler.EventHandler {
+	return &countingHandler{handler: h, counter: handlerEnqueued.WithLabelValues(controller)}
+}
+
+type countingHandler struct {
+	handler handler.EventHandler
+	counter prometheus.Counter
+}
+
+func (h *countingHandler) Create(ctx context.Context, evt event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.handler.Create(ctx, evt, h.wrap(q))
+}
+
+func (h *countingHandler) Update(ctx context.Context, evt event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.handler.Update(ctx, evt, h.wrap(q))
+}
+
+func (h *countingHandler) Delete(ctx context.Context, evt event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	h.handler.Delete(ctx, evt, h.wrap(q))
+}
+
+func (h *countingHandler) Generic(ctx context.Context, evt event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.handler.Generic(ctx, evt, h.wrap(q))
+}
+
+func (h *countingHandler) wrap(q workqueue.RateLimitingInterface) workqueue.RateLimitingInterface {
+	return &countingQueue{RateLimitingInterface: q, counter: h.counter}
+}
+
+// countingQueue counts adds before handing them to the real queue
+type countingQueue struct {
+	workqueue.RateLimitingInterface
+	counter prometheus.Counter
+}
+
+func (q *countingQueue) Add(item interface{}) {
+	q.counter.Inc()
+	q.RateLimitingInterface.Add(item)
+}
+
+func (q *countingQueue) AddAfter(item interface{}, duration time.Duration) {
+	q.counter.Inc()
+	q.RateLimitingInterface.AddAfter(item, duration)
+}
+
+func (q *countingQueue) AddRateLimited(item interface{}) {
+	q.counter.Inc()
+	q.RateLimitingInterface.AddRateLimited(item)
+}
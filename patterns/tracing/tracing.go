@@ -0,0 +1,176 @@
+// Package tracing shows where slow reconciles spend their time.
+//
+// Reconcile latency alone does not say whether a reconcile was slow because of the API
+// server, a large List, or an external system. The pieces below record a per-reconcile
+// breakdown and report it only for reconciles over a threshold, so normal reconciles
+// cost a few map updates and no log lines:
+//
+//	traced := tracing.NewClient(mgr.GetClient())
+//	r := &MyResourceReconciler{Client: traced}
+//	ctrl.NewControllerManagedBy(mgr).
+//		For(&v1.MyResource{}).
+//		Complete(&tracing.Reconciler{Name: "myresource", Reconciler: r, Threshold: time.Second})
+//
+// Inside Reconcile, time steps that are not client calls:
+//
+//	defer tracing.Start(ctx, tracing.PhaseExternal)()
+//	err := r.DB.CreateUser(ctx, user)
+//
+// A slow reconcile then logs e.g.
+//
+//	"Slow reconcile" duration="2.4s" get="12ms" list="310ms" write="45ms" external="2s" other="33ms"
+package tracing
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Phases a reconcile's time is attributed to.
+// Custom phase names may be passed to Start; keep them few, they become metric labels.
+const (
+	PhaseGet      = "get"
+	PhaseList     = "list"
+	PhaseWrite    = "write"
+	PhaseExternal = "external"
+	// PhaseOther is the time not attributed to any other phase
+	PhaseOther = "other"
+)
+
+var (
+	// slowReconciles counts reconciles that took longer than the threshold
+	slowReconciles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "controller_slow_reconcile_total",
+		Help: "Number of reconciles that exceeded the slow-reconcile threshold.",
+	}, []string{"controller"})
+
+	// slowReconcilePhase is the time slow reconciles spent in each phase
+	slowReconcilePhase = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "controller_slow_reconcile_phase_seconds",
+		Help:    "Time spent per phase by reconciles that exceeded the slow-reconcile threshold.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"controller", "phase"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(slowReconciles, slowReconcilePhase)
+}
+
+// Trace accumulates the time one reconcile spends in each phase.
+// Client calls may run concurrently, so it is safe for concurrent use.
+type Trace struct {
+	mu     sync.Mutex
+	phases map[string]time.Duration
+}
+
+type traceKey struct{}
+
+// FromContext returns the reconcile's trace, or nil outside a traced reconcile
+func FromContext(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceKey{}).(*Trace)
+	return trace
+}
+
+// Add attributes d to phase. It is a no-op on a nil Trace.
+func (t *Trace) Add(phase string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phases[phase] += d
+}
+
+// Start times a phase until the returned function is called.
+// Outside a traced reconcile it costs only the context lookup.
+func Start(ctx context.Context, phase string) func() {
+	trace := FromContext(ctx)
+	if trace == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { trace.Add(phase, time.Since(start)) }
+}
+
+// breakdown returns a copy of the phase durations, with the remainder of total as PhaseOther
+func (t *Trace) breakdown(total time.Duration) map[string]time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	phases := make(map[string]time.Duration, len(t.phases)+1)
+	attributed := time.Duration(0)
+	for phase, d := range t.phases {
+		phases[phase] = d
+		attributed += d
+	}
+	// Concurrent calls can attribute more time than elapsed
+	if other := total - attributed; other > 0 {
+		phases[PhaseOther] = other
+	}
+	return phases
+}
+
+// Reconciler wraps a reconciler and reports reconciles slower than Threshold
+type Reconciler struct {
+	// Name is the controller name used in logs and metric labels
+	Name string
+
+	// Reconciler is the wrapped reconciler
+	Reconciler reconcile.Reconciler
+
+	// Threshold is the latency above which a reconcile is reported; zero reports every reconcile
+	Threshold time.Duration
+}
+
+var _ reconcile.Reconciler = &Reconciler{}
+
+// Reconcile runs the wrapped reconciler with a Trace in its context
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	trace := &Trace{phases: make(map[string]time.Duration)}
+	ctx = context.WithValue(ctx, traceKey{}, trace)
+
+	start := time.Now()
+	result, err := r.Reconciler.Reconcile(ctx, req)
+	elapsed := time.Since(start)
+
+	if elapsed >= r.Threshold {
+		r.report(ctx, trace.breakdown(elapsed), elapsed)
+	}
+	return result, err
+}
+
+// report logs and records the phase breakdown of a slow reconcile
+func (r *Reconciler) report(ctx context.Context, phases map[string]time.Duration, elapsed time.Duration) {
+	slowReconciles.WithLabelValues(r.Name).Inc()
+
+	// Report phases in a stable order: built-in phases first, then custom ones by name
+	order := []string{PhaseGet, PhaseList, PhaseWrite, PhaseExternal}
+	var custom []string
+	for phase := range phases {
+		if !slices.Contains(order, phase) && phase != PhaseOther {
+			custom = append(custom, phase)
+		}
+	}
+	sort.Strings(custom)
+	order = append(append(order, custom...), PhaseOther)
+
+	keysAndValues := []interface{}{"duration", elapsed.String()}
+	for _, phase := range order {
+		d, ok := phases[phase]
+		if !ok {
+			continue
+		}
+		keysAndValues = append(keysAndValues, phase, d.String())
+		slowReconcilePhase.WithLabelValues(r.Name, phase).Observe(d.Seconds())
+	}
+
+	log.FromContext(ctx).Info("Slow reconcile", keysAndValues...)
+}
@@ -7,13 +7,29 @@ package patterns
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	// patterns/compat, copied into your project
+	"my.domain/myproject/pkg/compat"
 )
 
 // VALIDATION WEBHOOK PATTERN
@@ -22,45 +38,73 @@ import (
 // MyResourceValidator validates MyResource objects
 type MyResourceValidator struct {
 	Client  client.Client
-	Decoder *admission.Decoder
+	Decoder compat.Decoder
 }
 
 // Handle handles admission requests for validation
 func (v *MyResourceValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
 	log := log.FromContext(ctx)
 
-	// Decode the object
-	instance := &MyResource{}
-	err := v.Decoder.Decode(req, instance)
+	// Decode the object, and on UPDATE the stored object it replaces
+	instance, old := &MyResource{}, &MyResource{}
+	isUpdate, err := decodeWithOld(v.Decoder, req, instance, old)
 	if err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
-	// Validate the object
-	if err := v.validateMyResource(ctx, instance); err != nil {
-		log.Error(err, "Validation failed for MyResource", "name", instance.Name)
-		return admission.Denied(err.Error())
+	// Collect every violated rule, of the object and, on UPDATE, of the transition
+	errs, err := v.validateMyResource(ctx, instance)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if isUpdate {
+		errs = append(errs, validateMyResourceUpdate(old, instance)...)
+	}
+	if len(errs) > 0 {
+		log.Info("Validation failed for MyResource", "name", instance.Name, "errors", errs.ToAggregate().Error())
+		return invalidResponse(schema.GroupKind{Group: "mygroup.my.domain", Kind: "MyResource"}, instance.Name, errs)
 	}
 
 	log.Info("Validation passed for MyResource", "name", instance.Name)
 	return admission.Allowed("")
 }
 
-// validateMyResource contains the validation logic
-func (v *MyResourceValidator) validateMyResource(ctx context.Context, instance *MyResource) error {
+// invalidResponse denies a request with an Invalid status listing one cause per field, the
+// same status the API server returns for its own validation. kubectl prints each field
+// path with its error, where admission.Denied(err.Error()) would give one flattened string:
+//
+//	The MyResource "example" is invalid:
+//	* spec.replicas: Invalid value: -1: must be between 0 and 100
+//	* spec.image: Required value
+func invalidResponse(gk schema.GroupKind, name string, errs field.ErrorList) admission.Response {
+	status := apierrors.NewInvalid(gk, name, errs).Status()
+	return admission.Response{
+		AdmissionResponse: admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &status,
+		},
+	}
+}
+
+// validateMyResource returns every rule the object violates, each with the path of its
+// field. The error is for failed lookups, which are not the user's fault.
+//
+// With admission.CustomValidator return the same list as
+// apierrors.NewInvalid(gk, name, errs); controller-runtime keeps its status.
+func (v *MyResourceValidator) validateMyResource(ctx context.Context, instance *MyResource) (field.ErrorList, error) {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+
 	// Example: Validate replicas
 	if instance.Spec.Replicas < 0 || instance.Spec.Replicas > 100 {
-		return fmt.Errorf("replicas must be between 0 and 100, got %d", instance.Spec.Replicas)
+		errs = append(errs, field.Invalid(spec.Child("replicas"), instance.Spec.Replicas, "must be between 0 and 100"))
 	}
 
-	// Example: Validate image is not empty
+	// Example: Validate image is set and well formed
 	if instance.Spec.Image == "" {
-		return fmt.Errorf("image must be specified")
-	}
-
-	// Example: Validate image format
-	if !isValidImageReference(instance.Spec.Image) {
-		return fmt.Errorf("invalid image reference: %s", instance.Spec.Image)
+		errs = append(errs, field.Required(spec.Child("image"), ""))
+	} else if !isValidImageReference(instance.Spec.Image) {
+		errs = append(errs, field.Invalid(spec.Child("image"), instance.Spec.Image, "must be a valid image reference"))
 	}
 
 	// Example: Validate that referenced ConfigMap exists
@@ -70,22 +114,68 @@ func (v *MyResourceValidator) validateMyResource(ctx context.Context, instance *
 			Name:      instance.Spec.ConfigMapName,
 			Namespace: instance.Namespace,
 		}, configMap)
-		if err != nil {
-			return fmt.Errorf("configmap %s not found: %w", instance.Spec.ConfigMapName, err)
+		if apierrors.IsNotFound(err) {
+			errs = append(errs, field.NotFound(spec.Child("configMapName"), instance.Spec.ConfigMapName))
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get ConfigMap %s: %w", instance.Spec.ConfigMapName, err)
 		}
 	}
 
-	// Example: Validate parameters
+	// Example: Validate parameters; map entries are addressed by key
 	for key, value := range instance.Spec.Parameters {
 		if key == "" {
-			return fmt.Errorf("parameter key cannot be empty")
+			errs = append(errs, field.Invalid(spec.Child("parameters"), key, "keys must not be empty"))
 		}
 		if value == "" {
-			return fmt.Errorf("parameter value for key %s cannot be empty", key)
+			errs = append(errs, field.Required(spec.Child("parameters").Key(key), ""))
 		}
 	}
 
-	return nil
+	return errs, nil
+}
+
+// validateMyResourceUpdate returns the rules that compare the new object with the old one
+func validateMyResourceUpdate(old, instance *MyResource) field.ErrorList {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+
+	// Example: Immutable field - the ConfigMap is only read when the pods are created
+	if old.Spec.ConfigMapName != "" && instance.Spec.ConfigMapName != old.Spec.ConfigMapName {
+		errs = append(errs, field.Invalid(spec.Child("configMapName"), instance.Spec.ConfigMapName, "field is immutable once set"))
+	}
+
+	// Example: Monotonic counter - lowering it would look like a restart already done
+	if instance.Spec.RestartGeneration < old.Spec.RestartGeneration {
+		errs = append(errs, field.Invalid(spec.Child("restartGeneration"), instance.Spec.RestartGeneration,
+			fmt.Sprintf("may only increase, it was %d", old.Spec.RestartGeneration)))
+	}
+
+	return errs
+}
+
+// DECODE HELPERS
+// ==============
+
+// decodeWithOld decodes the object of a request into obj and, on UPDATE, the stored object
+// it replaces into old. It reports whether old was decoded; CREATE has no old object, and
+// DELETE only has one (decode req.OldObject directly for DELETE webhooks).
+//
+// With admission.CustomValidator (WithValidator) controller-runtime does this for you and
+// passes both objects to ValidateUpdate; a raw admission.Handler has to do it itself.
+func decodeWithOld(decoder compat.Decoder, req admission.Request, obj, old runtime.Object) (bool, error) {
+	if err := decoder.Decode(req, obj); err != nil {
+		return false, err
+	}
+	if req.Operation != admissionv1.Update {
+		return false, nil
+	}
+	if len(req.OldObject.Raw) == 0 {
+		return false, fmt.Errorf("UPDATE request for %s has no old object", req.Name)
+	}
+	if err := decoder.DecodeRaw(req.OldObject, old); err != nil {
+		return false, fmt.Errorf("failed to decode the old object: %w", err)
+	}
+	return true, nil
 }
 
 // DEFAULTING WEBHOOK PATTERN
@@ -93,7 +183,7 @@ func (v *MyResourceValidator) validateMyResource(ctx context.Context, instance *
 
 // MyResourceDefaulter sets default values for MyResource objects
 type MyResourceDefaulter struct {
-	Decoder *admission.Decoder
+	Decoder compat.Decoder
 }
 
 // Handle handles admission requests for defaulting
@@ -146,46 +236,133 @@ func (d *MyResourceDefaulter) setDefaults(instance *MyResource) {
 	}
 }
 
+// Defaults and the controller: the spec the webhook persisted is authoritative. Defaults
+// that depend on configuration, e.g. a ConfigMap, change over time, and the webhook runs on
+// every UPDATE too, so a controller that writes the spec back re-defaults it with today's
+// configuration, or takes a field away from the user:
+//   - fill defaults in the controller only in memory, for objects admitted without the
+//     webhook, and never Update the object after filling them
+//   - make metadata writes such as adding the finalizer before filling defaults, or use a
+//     patch computed from a copy taken before
+// examples/database-operator/controllers/defaulting_race_test.go checks this by creating
+// objects rapid-fire while the defaults change, with the webhook and the controller running.
+
 // WEBHOOK REGISTRATION
 // ====================
 
 // SetupWebhookWithManager registers the webhooks with the manager
 // Add this to your main.go
+//
+// Handlers get their decoder here, when they are constructed. Decoder injection
+// (InjectDecoder) was removed in controller-runtime v0.15, and a handler that still relies
+// on it has a nil decoder.
 func SetupWebhookWithManager(mgr ctrl.Manager) error {
 	// Validation webhook
 	validator := &MyResourceValidator{
 		Client:  mgr.GetClient(),
-		Decoder: admission.NewDecoder(mgr.GetScheme()),
+		Decoder: compat.NewDecoder(mgr.GetScheme()),
 	}
 
 	// Defaulting webhook
 	defaulter := &MyResourceDefaulter{
-		Decoder: admission.NewDecoder(mgr.GetScheme()),
-	}
-
-	// Register validation webhook
-	if err := ctrl.NewWebhookManagedBy(mgr).
-		For(&MyResource{}).
-		WithValidator(validator).
-		Complete(); err != nil {
-		return err
+		Decoder: compat.NewDecoder(mgr.GetScheme()),
 	}
 
-	// Register defaulting webhook
-	if err := ctrl.NewWebhookManagedBy(mgr).
-		For(&MyResource{}).
-		WithDefaulter(defaulter).
-		Complete(); err != nil {
-		return err
-	}
+	// Register both behind a budget shorter than their timeoutSeconds (see the markers below)
+	server := mgr.GetWebhookServer()
+	server.Register("/validate-mygroup-my-domain-v1-myresource", &webhook.Admission{
+		Handler: &GuardedHandler{Name: "myresource-validation", Budget: 4 * time.Second, FailOpen: true, Handler: validator},
+	})
+	server.Register("/mutate-mygroup-my-domain-v1-myresource", &webhook.Admission{
+		Handler: &GuardedHandler{Name: "myresource-defaulting", Budget: 4 * time.Second, Handler: defaulter},
+	})
 
 	return nil
 }
 
 // IMPORTANT: Add these markers to your API types to enable webhooks
-// +kubebuilder:webhook:path=/validate-mygroup-my-domain-v1-myresource,mutating=false,failurePolicy=fail,sideEffects=None,groups=mygroup.my.domain,resources=myresources,verbs=create;update,versions=v1,name=vmyresource.kb.io,admissionReviewVersions=v1
+//
+// Every webhook sits in the write path of its resources, so bound it explicitly:
+// - timeoutSeconds: the API server waits 10s by default and at most 30s. Keep it short;
+//   the handler budget must be shorter still, so the handler answers before the API server
+//   stops waiting and applies the failurePolicy on its own.
+// - failurePolicy: choose per webhook. Fail closed (fail) when a request admitted without
+//   the webhook does harm, e.g. a security policy or a default other code relies on. Fail
+//   open (ignore) when the controller checks the same rules again, so an outage of the
+//   operator does not block every write.
+// +kubebuilder:webhook:path=/validate-mygroup-my-domain-v1-myresource,mutating=false,failurePolicy=ignore,timeoutSeconds=5,sideEffects=None,groups=mygroup.my.domain,resources=myresources,verbs=create;update,versions=v1,name=vmyresource.kb.io,admissionReviewVersions=v1
+
+// +kubebuilder:webhook:path=/mutate-mygroup-my-domain-v1-myresource,mutating=true,failurePolicy=fail,timeoutSeconds=5,sideEffects=None,groups=mygroup.my.domain,resources=myresources,verbs=create;update,versions=v1,name=myresource.kb.io,admissionReviewVersions=v1
+
+// NAMESPACE SELECTOR
+// ==================
+//
+// The markers cannot set a namespaceSelector. Keep kube-system out of webhooks that fail
+// closed, or an operator outage can block the control plane from repairing itself. With
+// kustomize, patch the generated configurations in config/webhook/kustomization.yaml:
+//
+//	patches:
+//	- target:
+//	    group: admissionregistration.k8s.io
+//	    kind: (Mutating|Validating)WebhookConfiguration
+//	  patch: |-
+//	    - op: add
+//	      path: /webhooks/0/namespaceSelector
+//	      value:
+//	        matchExpressions:
+//	        - key: kubernetes.io/metadata.name
+//	          operator: NotIn
+//	          values: [kube-system]
+//
+// In a Helm chart, render it from a value (webhooks.namespaceSelector) with that default.
+
+// GUARDED HANDLER PATTERN
+// =======================
+
+// webhookDuration records how long each webhook handler takes, so slow webhooks show up
+// before they reach their timeoutSeconds
+var webhookDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "myoperator_webhook_handler_duration_seconds",
+	Help:    "Time the webhook handlers take, by webhook and result.",
+	Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 4},
+}, []string{"webhook", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(webhookDuration)
+}
+
+// GuardedHandler bounds the time a handler may take and records it. Budget must be shorter
+// than the timeoutSeconds of the webhook, and FailOpen must match its failurePolicy.
+type GuardedHandler struct {
+	Name     string
+	Budget   time.Duration
+	FailOpen bool
+	Handler  admission.Handler
+}
 
-// +kubebuilder:webhook:path=/mutate-mygroup-my-domain-v1-myresource,mutating=true,failurePolicy=fail,sideEffects=None,groups=mygroup.my.domain,resources=myresources,verbs=create;update,versions=v1,name=myresource.kb.io,admissionReviewVersions=v1
+// Handle calls the handler with a deadline; its API calls are cancelled once it passes
+func (g *GuardedHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	ctx, cancel := context.WithTimeout(ctx, g.Budget)
+	defer cancel()
+	start := time.Now()
+	resp := g.Handler.Handle(ctx, req)
+
+	result := "allowed"
+	switch {
+	case ctx.Err() != nil:
+		result = "timeout"
+		msg := fmt.Sprintf("the %s webhook did not finish within %s", g.Name, g.Budget)
+		if g.FailOpen {
+			resp = admission.Allowed("").WithWarnings(msg + "; the object was admitted without validation")
+		} else {
+			resp = admission.Errored(http.StatusGatewayTimeout, errors.New(msg))
+		}
+	case !resp.Allowed:
+		result = "denied"
+	}
+	webhookDuration.WithLabelValues(g.Name, result).Observe(time.Since(start).Seconds())
+	return resp
+}
 
 // HELPER FUNCTIONS
 // ================